@@ -8,6 +8,7 @@ package fs
 import "C"
 
 import (
+	"io"
 	"time"
 
 	"github.com/NVIDIA/proxyfs/inode"
@@ -43,6 +44,9 @@ type ContainerEntry struct {
 	NumWrites        uint64
 	InodeNumber      uint64
 	Metadata         []byte
+	VersionID        uint64 // only set if versions are listed
+	IsLatest         bool   // only set if versions are listed
+	IsDeleteMarker   bool   // only set if versions are listed
 }
 
 type HeadResponse struct {
@@ -136,10 +140,67 @@ const (
 	StatVFSFilesystemID                         // statvfs.f_fsid  - Our filesystem ID
 	StatVFSMountFlags                           // statvfs.f_flag  - mount flags
 	StatVFSMaxFilenameLen                       // statvfs.f_namemax - maximum filename length
+	StatVFSChunkedBytes                         // (ProxyFS-specific) cumulative bytes written subject to deduplication
+	StatVFSDedupedBytes                         // (ProxyFS-specific) ...of which were found to already be stored
 )
 
 type StatVFS map[StatVFSKey]uint64 // key is one of StatVFSKey consts
 
+// Returned by SnapShotDiff
+type SnapShotDiffEntry struct {
+	inode.SnapShotDiffEntry
+	Paths []string // all paths (hard links) to the Inode in the view in which it was found
+}
+
+// ChangeEventType identifies the kind of change described by a ChangeEvent
+type ChangeEventType uint8
+
+const (
+	ChangeEventCreate     ChangeEventType = iota + 1 // a file, directory, symlink, or hard link was created
+	ChangeEventUnlink                                // a directory entry was removed
+	ChangeEventRename                                // a directory entry was renamed (see OldDirInodeNumber & OldPath)
+	ChangeEventWriteClose                            // a written file was flushed (e.g. upon close)
+	ChangeEventSetattr                               // attributes (including size) of an Inode were changed
+	ChangeEventXAttr                                 // an xattr of an Inode was set or removed
+)
+
+// ChangeEventsMaxWait is the longest FetchChangeEvents will wait for a ChangeEvent to arrive
+const ChangeEventsMaxWait = time.Minute
+
+// Returned by FetchChangeEvents
+type ChangeEvent struct {
+	Epoch             uint64 // differs each time the volume is served (restarting SequenceNumbers)
+	SequenceNumber    uint64
+	Time              time.Time
+	Type              ChangeEventType
+	InodeNumber       inode.InodeNumber
+	DirInodeNumber    inode.InodeNumber // directory containing InodeNumber (0 if unknown)
+	Path              string            // "" if unknown
+	OldDirInodeNumber inode.InodeNumber // ChangeEventRename only
+	OldPath           string            // ChangeEventRename only
+}
+
+// Returned by RecycleBinList
+type RecycleBinEntry struct {
+	Name         string // name of the entry in the RecycleBin (used to restore or purge it)
+	InodeNumber  inode.InodeNumber
+	InodeType    inode.InodeType
+	Size         uint64
+	UserID       inode.InodeUserID
+	GroupID      inode.InodeGroupID
+	OriginalPath string    // "" if unknown
+	DeletionTime time.Time // zero if unknown
+}
+
+// Passed to MiddlewareMultipartComplete (which only consults PartNumber and ETag) and
+// returned by MiddlewareMultipartListParts
+type MultipartPart struct {
+	PartNumber       uint32
+	ETag             string
+	Size             uint64
+	ModificationTime uint64
+}
+
 type JobHandle interface {
 	Active() (active bool)
 	Wait()
@@ -148,6 +209,52 @@ type JobHandle interface {
 	Info() (info []string)
 }
 
+// FindPredicate selects the Inodes reported by a Find job. Zero-valued fields impose no constraint.
+type FindPredicate struct {
+	NameGlob       string             // basename must match (see path.Match() for syntax)
+	MinSize        uint64             // Size must be at least MinSize
+	MaxSize        uint64             // if non-zero, Size must be at most MaxSize
+	ModifiedAfter  time.Time          // ModificationTime must be after ModifiedAfter
+	ModifiedBefore time.Time          // ModificationTime must be before ModifiedBefore
+	MatchUserID    bool               // if true, the Inode's UserID must equal UserID
+	UserID         inode.InodeUserID  // (used only if MatchUserID)
+	MatchGroupID   bool               // if true, the Inode's GroupID must equal GroupID
+	GroupID        inode.InodeGroupID // (used only if MatchGroupID)
+	InodeTypes     []inode.InodeType  // if non-empty, InodeType must be one of InodeTypes
+	XAttrName      string             // xattr XAttrName must be present
+}
+
+// Returned by FindJobHandle.FetchResults
+type FindResult struct {
+	InodeNumber      inode.InodeNumber
+	Path             string
+	InodeType        inode.InodeType
+	Size             uint64
+	ModificationTime time.Time
+	UserID           inode.InodeUserID
+	GroupID          inode.InodeGroupID
+}
+
+// FindJobHandle extends JobHandle to return the results of a Find job. Results are retained until
+// fetched... so a Find job pauses should FetchResults() not be called often enough to keep up.
+//
+// A marker of zero fetches the first results. Passing the returned nextMarker acknowledges (and
+// discards) the results previously returned and fetches those that follow. If no results are
+// available, FetchResults() waits for up to maxWait. A maxResults of zero imposes no limit. Once
+// done is returned, the Find job has finished and all of its results have been fetched.
+type FindJobHandle interface {
+	JobHandle
+	FetchResults(marker uint64, maxResults uint64, maxWait time.Duration) (results []FindResult, nextMarker uint64, done bool)
+}
+
+// ViewChangeListener is notified prior to the contents of the volume being changed out from under
+// any state cached by clients (e.g. via SnapShotRevert() or RetainedCheckpointInspect()). Such state
+// should be recalled before ViewChanging() returns. The returned viewChanged func is called once the
+// change has completed (successfully or not).
+type ViewChangeListener interface {
+	ViewChanging() (viewChanged func())
+}
+
 // Volume handle interface
 
 func FetchVolumeHandleByAccountName(accountName string) (volumeHandle VolumeHandle, err error) {
@@ -166,11 +273,13 @@ type VolumeHandle interface {
 	Create(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (fileInodeNumber inode.InodeNumber, err error)
 	DefragmentFile(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fileInodeNumber inode.InodeNumber) (err error)
 	Destroy(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error)
+	FetchChangeEvents(lastEpoch uint64, lastSequenceNumber uint64, maxEvents uint64, maxWait time.Duration) (changeEvents []ChangeEvent, epoch uint64, latestSequenceNumber uint64, eventsLost bool, err error)
 	FetchExtentMapChunk(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fileInodeNumber inode.InodeNumber, fileOffset uint64, maxEntriesFromFileOffset int64, maxEntriesBeforeFileOffset int64) (extentMapChunk *inode.ExtentMapChunkStruct, err error)
 	Flush(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error)
 	Flock(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, lockCmd int32, inFlockStruct *FlockStruct) (outFlockStruct *FlockStruct, err error)
 	Getstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (stat Stat, err error)
 	GetType(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeType inode.InodeType, err error)
+	GetWORMState(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (wormState inode.WORMStateStruct, err error)
 	GetXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (value []byte, err error)
 	IsDir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeIsDir bool, err error)
 	IsFile(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (inodeIsFile bool, err error)
@@ -180,30 +289,46 @@ type VolumeHandle interface {
 	Lookup(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string) (inodeNumber inode.InodeNumber, err error)
 	LookupPath(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, fullpath string) (inodeNumber inode.InodeNumber, err error)
 	MiddlewareCoalesce(destPath string, metaData []byte, elementPaths []string) (ino uint64, numWrites uint64, attrChangeTime uint64, modificationTime uint64, err error)
-	MiddlewareDelete(parentDir string, baseName string) (err error)
+	MiddlewareDelete(parentDir string, baseName string, versionID uint64) (err error)
 	MiddlewareGetAccount(maxEntries uint64, marker string, endmarker string) (accountEnts []AccountEntry, mtime uint64, ctime uint64, err error)
-	MiddlewareGetContainer(vContainerName string, maxEntries uint64, marker string, endmarker string, prefix string, delimiter string) (containerEnts []ContainerEntry, err error)
-	MiddlewareGetObject(containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (response HeadResponse, err error)
-	MiddlewareHeadResponse(entityPath string) (response HeadResponse, err error)
+	MiddlewareGetContainer(vContainerName string, maxEntries uint64, marker string, endmarker string, prefix string, delimiter string, versions bool) (containerEnts []ContainerEntry, err error)
+	MiddlewareGetObject(containerObjectPath string, versionID uint64, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (response HeadResponse, err error)
+	MiddlewareHeadResponse(entityPath string, versionID uint64) (response HeadResponse, err error)
 	MiddlewareMkdir(vContainerName string, vObjectPath string, metadata []byte) (mtime uint64, ctime uint64, inodeNumber inode.InodeNumber, numWrites uint64, err error)
+	MiddlewareMultipartAbort(vContainerName string, vObjectPath string, uploadID string) (err error)
+	MiddlewareMultipartComplete(vContainerName string, vObjectPath string, uploadID string, parts []MultipartPart, metadata []byte) (mtime uint64, ctime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error)
+	MiddlewareMultipartInitiate(vContainerName string, vObjectPath string, metadata []byte) (uploadID string, err error)
+	MiddlewareMultipartListParts(vContainerName string, vObjectPath string, uploadID string, partNumberMarker uint32, maxParts uint64) (parts []MultipartPart, moreParts bool, err error)
+	MiddlewareMultipartPutPart(vContainerName string, vObjectPath string, uploadID string, partNumber uint32, pObjectPaths []string, pObjectLengths []uint64, eTag string) (err error)
 	MiddlewarePost(parentDir string, baseName string, newMetaData []byte, oldMetaData []byte) (err error)
 	MiddlewarePutComplete(vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte) (mtime uint64, ctime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error)
 	MiddlewarePutContainer(containerName string, oldMetadata []byte, newMetadata []byte) (err error)
 	Mkdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (newDirInodeNumber inode.InodeNumber, err error)
-	Move(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (toDestroyInodeNumber inode.InodeNumber, err error)
+	Move(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags inode.MoveFlags) (toDestroyInodeNumber inode.InodeNumber, err error)
 	RemoveXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (err error)
-	Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (err error)
+	Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags inode.MoveFlags) (err error)
 	Read(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error)
 	Readdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, maxEntries uint64, prevReturned ...interface{}) (entries []inode.DirEntry, numEntries uint64, areMoreEntries bool, err error)
 	ReaddirPlus(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, maxEntries uint64, prevReturned ...interface{}) (dirEntries []inode.DirEntry, statEntries []Stat, numEntries uint64, areMoreEntries bool, err error)
 	Readsymlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (target string, err error)
+	RecycleBinList(prevName string, maxEntries uint64) (recycleBinEntries []RecycleBinEntry, moreEntries bool, err error)
+	RecycleBinPurge(name string) (err error)
+	RecycleBinRestore(name string, path string) (restoredPath string, err error)
+	RegisterForViewChanges(listener ViewChangeListener)
 	Resize(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, newSize uint64) (err error)
+	RetainedCheckpointInspect(id uint64) (err error)
+	RetainedCheckpointRevert(id uint64) (err error)
 	Rmdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error)
 	Setstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, stat Stat) (err error)
+	SetWORMState(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, wormState inode.WORMStateStruct) (err error)
 	SetXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, value []byte, flags int) (err error)
+	SnapShotDiff(fromSnapShotID uint64, toSnapShotID uint64, lastInodeNumber inode.InodeNumber, maxEntries uint64) (diffEntries []SnapShotDiffEntry, moreEntries bool, err error)
+	SnapShotRestore(snapShotID uint64, path string) (err error)
+	SnapShotRevert(snapShotID uint64) (err error)
 	StatVfs() (statVFS StatVFS, err error)
 	Symlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, target string) (symlinkInodeNumber inode.InodeNumber, err error)
 	Unlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error)
+	UnregisterForViewChanges(listener ViewChangeListener)
 	VolumeName() (volumeName string)
 	Write(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, buf []byte, profiler *utils.Profiler) (size uint64, err error)
 	Wrote(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, containerName string, objectName string, fileOffset []uint64, objectOffset []uint64, length []uint64, wroteTime uint64) (err error)
@@ -263,6 +388,131 @@ func ScrubVolume(volumeName string) (scrubVolumeHandle JobHandle) {
 	return
 }
 
+// Find reports the Inodes at or beneath path in the specified volumeName that satisfy predicate.
+// Subtrees are walked in parallel, so results are not returned in any particular order. Symbolic
+// links are not followed. Directories that userID/groupID/otherGroupIDs may not read are skipped.
+func Find(volumeName string, userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, path string, predicate FindPredicate) (findJobHandle FindJobHandle) {
+	var (
+		fVS *findVolumeStruct
+	)
+	startTime := time.Now()
+	defer func() {
+		globals.FindUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+	}()
+
+	fVS = &findVolumeStruct{}
+
+	fVS.jobType = "FIND"
+	fVS.volumeName = volumeName
+	fVS.active = true
+	fVS.stopFlag = false
+	fVS.err = make([]string, 0)
+	fVS.info = make([]string, 0)
+	fVS.userID = userID
+	fVS.groupID = groupID
+	fVS.otherGroupIDs = otherGroupIDs
+	fVS.path = path
+	fVS.predicate = predicate
+	fVS.results = make([]FindResult, 0)
+	fVS.resultsChangedChan = make(chan struct{})
+
+	fVS.globalWaitGroup.Add(1)
+	go fVS.findVolume()
+
+	findJobHandle = fVS
+
+	return
+}
+
+// MigrateLayout makes the PhysicalContainerLayout of all {Dir|File}Inodes beneath the directory at path in the
+// specified volumeName match that of the directory (see inode.PhysicalContainerLayoutStreamName), moving the
+// data of FileInodes to PhysicalContainers of their PhysicalContainerLayout as necessary.
+func MigrateLayout(volumeName string, path string) (migrateLayoutHandle JobHandle) {
+	var (
+		mLS *migrateLayoutStruct
+	)
+	startTime := time.Now()
+	defer func() {
+		globals.MigrateLayoutUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+	}()
+
+	mLS = &migrateLayoutStruct{}
+
+	mLS.jobType = "MIGRATE LAYOUT"
+	mLS.volumeName = volumeName
+	mLS.active = true
+	mLS.stopFlag = false
+	mLS.err = make([]string, 0)
+	mLS.info = make([]string, 0)
+	mLS.path = path
+
+	mLS.globalWaitGroup.Add(1)
+	go mLS.migrateLayout()
+
+	migrateLayoutHandle = mLS
+
+	return
+}
+
+// ExportVolume writes the directory tree of the specified volumeName (or, if snapShotName != "", of that
+// SnapShot of it) to archive as a PAX format tar stream that ImportVolume can recreate in another volume.
+func ExportVolume(volumeName string, snapShotName string, archive io.Writer) (exportVolumeHandle JobHandle) {
+	var (
+		eVS *exportVolumeStruct
+	)
+	startTime := time.Now()
+	defer func() {
+		globals.ExportVolumeUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+	}()
+
+	eVS = &exportVolumeStruct{}
+
+	eVS.jobType = "EXPORT"
+	eVS.volumeName = volumeName
+	eVS.active = true
+	eVS.stopFlag = false
+	eVS.err = make([]string, 0)
+	eVS.info = make([]string, 0)
+	eVS.snapShotName = snapShotName
+	eVS.archive = archive
+
+	eVS.globalWaitGroup.Add(1)
+	go eVS.exportVolume()
+
+	exportVolumeHandle = eVS
+
+	return
+}
+
+// ImportVolume recreates the directory tree written to archive by ExportVolume in the specified volumeName,
+// which must be empty (e.g. freshly formatted by mkproxyfs).
+func ImportVolume(volumeName string, archive io.Reader) (importVolumeHandle JobHandle) {
+	var (
+		iVS *importVolumeStruct
+	)
+	startTime := time.Now()
+	defer func() {
+		globals.ImportVolumeUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+	}()
+
+	iVS = &importVolumeStruct{}
+
+	iVS.jobType = "IMPORT"
+	iVS.volumeName = volumeName
+	iVS.active = true
+	iVS.stopFlag = false
+	iVS.err = make([]string, 0)
+	iVS.info = make([]string, 0)
+	iVS.archive = archive
+
+	iVS.globalWaitGroup.Add(1)
+	go iVS.importVolume()
+
+	importVolumeHandle = iVS
+
+	return
+}
+
 // Utility functions
 
 func ValidateBaseName(baseName string) (err error) {
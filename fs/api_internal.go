@@ -24,6 +24,19 @@ import (
 // Shorthand for our internal API debug log id; global to the package
 var internalDebug = logger.DbgInternal
 
+// inheritedStreamNames lists the Streams (i.e. xattrs) of a DirInode copied to each
+// {Dir|File}Inode created within it
+var inheritedStreamNames = []string{
+	inode.CompressionStreamName,
+	inode.PhysicalContainerLayoutStreamName,
+}
+
+// dirInheritedStreamNames lists the Streams (i.e. xattrs) of a DirInode copied only
+// to each DirInode created within it
+var dirInheritedStreamNames = []string{
+	inode.CaseInsensitiveStreamName,
+}
+
 type symlinkFollowState struct {
 	seen      map[inode.InodeNumber]bool
 	traversed int
@@ -312,6 +325,9 @@ func (vS *volumeStruct) Create(userID inode.InodeUserID, groupID inode.InodeGrou
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	err = validateBaseName(basename)
 	if err != nil {
 		return 0, err
@@ -336,6 +352,10 @@ func (vS *volumeStruct) Create(userID inode.InodeUserID, groupID inode.InodeGrou
 		inode.NoOverride) {
 		return 0, blunder.NewError(blunder.PermDeniedError, "EACCES")
 	}
+	err = vS.wormCheck(dirInodeNumber, wormOpAddEntry)
+	if err != nil {
+		return 0, err
+	}
 
 	// create the file and add it to the directory
 	fileInodeNumber, err = vS.inodeVolumeHandle.CreateFile(filePerm, userID, groupID)
@@ -343,7 +363,10 @@ func (vS *volumeStruct) Create(userID inode.InodeUserID, groupID inode.InodeGrou
 		return 0, err
 	}
 
-	err = vS.inodeVolumeHandle.Link(dirInodeNumber, basename, fileInodeNumber, false)
+	err = vS.inheritStreams(dirInodeNumber, fileInodeNumber, inode.FileType)
+	if err == nil {
+		err = vS.inodeVolumeHandle.Link(dirInodeNumber, basename, fileInodeNumber, false)
+	}
 	if err != nil {
 		destroyErr := vS.inodeVolumeHandle.Destroy(fileInodeNumber)
 		if destroyErr != nil {
@@ -352,6 +375,8 @@ func (vS *volumeStruct) Create(userID inode.InodeUserID, groupID inode.InodeGrou
 		return 0, err
 	}
 
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventCreate, inodeNumber: fileInodeNumber, dirInodeNumber: dirInodeNumber, basename: basename})
+
 	return fileInodeNumber, nil
 }
 
@@ -530,6 +555,9 @@ func (vS *volumeStruct) Flush(userID inode.InodeUserID, groupID inode.InodeGroup
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	inodeLock, err := vS.inodeVolumeHandle.InitInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -554,6 +582,10 @@ func (vS *volumeStruct) Flush(userID inode.InodeUserID, groupID inode.InodeGroup
 	err = vS.inodeVolumeHandle.Flush(inodeNumber, false)
 	vS.untrackInFlightFileInodeData(inodeNumber, false)
 
+	if (nil == err) && vS.fetchAndClearWritten(inodeNumber) {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventWriteClose, inodeNumber: inodeNumber})
+	}
+
 	vS.doInlineCheckpointIfEnabled()
 
 	return
@@ -1160,6 +1192,9 @@ func (vS *volumeStruct) Link(userID inode.InodeUserID, groupID inode.InodeGroupI
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	var (
 		inodeType inode.InodeType
 	)
@@ -1235,6 +1270,14 @@ func (vS *volumeStruct) Link(userID inode.InodeUserID, groupID inode.InodeGroupI
 		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
 		return
 	}
+	err = vS.wormCheck(dirInodeNumber, wormOpAddEntry)
+	if err != nil {
+		return
+	}
+	err = vS.wormCheck(targetInodeNumber, wormOpModify)
+	if err != nil {
+		return
+	}
 
 	err = vS.inodeVolumeHandle.Link(dirInodeNumber, basename, targetInodeNumber, false)
 
@@ -1244,6 +1287,10 @@ func (vS *volumeStruct) Link(userID inode.InodeUserID, groupID inode.InodeGroupI
 		vS.untrackInFlightFileInodeData(targetInodeNumber, false)
 	}
 
+	if err == nil {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventCreate, inodeNumber: targetInodeNumber, dirInodeNumber: dirInodeNumber, basename: basename})
+	}
+
 	return err
 }
 
@@ -1324,6 +1371,9 @@ func (vS *volumeStruct) Lookup(userID inode.InodeUserID, groupID inode.InodeGrou
 	}
 
 	inodeNumber, err = vS.inodeVolumeHandle.Lookup(dirInodeNumber, basename)
+	if nil == err {
+		vS.noteChangeEventParent(inodeNumber, dirInodeNumber, basename)
+	}
 	return inodeNumber, err
 }
 
@@ -1412,6 +1462,9 @@ func (vS *volumeStruct) MiddlewareCoalesce(destPath string, metaData []byte, ele
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	// First create the destination file if necessary and ensure that it is empty
 
 	tryLockBackoffContext = &tryLockBackoffContextStruct{}
@@ -1442,6 +1495,12 @@ RestartDestinationFileCreation:
 		goto RestartDestinationFileCreation
 	}
 
+	err = vS.wormCheck(destFileInodeNumber, wormOpModify)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
 	vS.inodeVolumeHandle.SetSize(destFileInodeNumber, 0)
 
 	heldLocks.free()
@@ -1490,6 +1549,14 @@ RestartDestinationFileCreation:
 				goto RestartCoalesceChunk
 			}
 
+			// Each element is consumed (i.e. unlinked) by the Coalesce()
+
+			err = vS.wormCheckUnlink(dirInodeNumber, dirEntryInodeNumber)
+			if nil != err {
+				heldLocks.free()
+				return
+			}
+
 			coalesceElementList = append(coalesceElementList, &inode.CoalesceElement{
 				ContainingDirectoryInodeNumber: dirInodeNumber,
 				ElementInodeNumber:             dirEntryInodeNumber,
@@ -1528,6 +1595,8 @@ RestartDestinationFileCreation:
 		elementPathIndexAtChunkStart = elementPathIndexAtChunkEnd
 	}
 
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventWriteClose, inodeNumber: destFileInodeNumber, path: "/" + strings.TrimLeft(destPath, "/")})
+
 	// Regardless of err return, fill in other return values
 
 	ino = uint64(destFileInodeNumber)
@@ -1537,7 +1606,7 @@ RestartDestinationFileCreation:
 	return
 }
 
-func (vS *volumeStruct) MiddlewareDelete(parentDir string, basename string) (err error) {
+func (vS *volumeStruct) MiddlewareDelete(parentDir string, basename string, versionID uint64) (err error) {
 	var (
 		dirEntryBasename      string
 		dirEntryInodeNumber   inode.InodeNumber
@@ -1548,9 +1617,11 @@ func (vS *volumeStruct) MiddlewareDelete(parentDir string, basename string) (err
 		inodeVolumeHandle     inode.VolumeHandle
 		linkCount             uint64
 		numDirEntries         uint64
+		recycleBinPath        string
 		retryRequired         bool
 		toDestroyInodeNumber  inode.InodeNumber
 		tryLockBackoffContext *tryLockBackoffContextStruct
+		versionPath           string
 	)
 
 	startTime := time.Now()
@@ -1561,6 +1632,17 @@ func (vS *volumeStruct) MiddlewareDelete(parentDir string, basename string) (err
 		}
 	}()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
+	if 0 != versionID {
+		err = vS.deleteObjectVersion(parentDir+"/"+basename, inode.InodeNumber(versionID), changeEvents)
+		return
+	}
+
+	recycleBinPath = vS.recycleBinCanonicalPath(parentDir + "/" + basename)
+	versionPath = vS.objectVersionsPath(parentDir + "/" + basename)
+
 	// Retry until done or failure (starting with ZERO backoff)
 
 	tryLockBackoffContext = &tryLockBackoffContextStruct{}
@@ -1628,6 +1710,31 @@ Restart:
 		doDestroy = (1 == linkCount)
 	}
 
+	// In a container retaining prior versions, the object is instead archived behind a delete marker
+
+	if ("" != versionPath) && (inode.FileType == inodeType) {
+		err = vS.retireObjectVersion(dirInodeNumber, dirEntryBasename, dirEntryInodeNumber, versionPath)
+		if nil != err {
+			heldLocks.free()
+			return
+		}
+
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventUnlink, inodeNumber: dirEntryInodeNumber, dirInodeNumber: dirInodeNumber, basename: dirEntryBasename})
+
+		heldLocks.free()
+
+		err = nil
+		return
+	}
+
+	// Absent a retained prior version, the WORM state of the object must permit its removal
+
+	err = vS.wormCheckUnlink(dirInodeNumber, dirEntryInodeNumber)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
 	// Now perform the Unlink() and (potentially) Destroy()
 
 	toDestroyInodeNumber, err = inodeVolumeHandle.Unlink(dirInodeNumber, dirEntryBasename, false)
@@ -1636,13 +1743,15 @@ Restart:
 		return
 	}
 
-	if doDestroy && (inode.InodeNumber(0) != toDestroyInodeNumber) {
+	if doDestroy && (inode.InodeNumber(0) != toDestroyInodeNumber) && !vS.recycle(toDestroyInodeNumber, recycleBinPath) {
 		err = inodeVolumeHandle.Destroy(toDestroyInodeNumber)
 		if nil != err {
 			logger.Errorf("fs.MiddlewareDelete() failed to Destroy dirEntryInodeNumber 0x%016X: %v", dirEntryInodeNumber, err)
 		}
 	}
 
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventUnlink, inodeNumber: dirEntryInodeNumber, dirInodeNumber: dirInodeNumber, basename: dirEntryBasename})
+
 	// Release heldLocks and exit with success (even if Destroy() failed earlier)
 
 	heldLocks.free()
@@ -1766,8 +1875,8 @@ func (vS *volumeStruct) MiddlewareGetAccount(maxEntries uint64, marker string, e
 				break
 			}
 			if ("." != dirEntrySliceElement.Basename) && (".." != dirEntrySliceElement.Basename) {
-				// So we've skipped "." & ".." - now also skip non-DirInodes
-				if inode.DirType == dirEntrySliceElement.Type {
+				// So we've skipped "." & ".." - now also skip non-DirInodes (and /<objectVersionsDirName>/ & /<multipartDirName>/)
+				if (inode.DirType == dirEntrySliceElement.Type) && (objectVersionsDirName != dirEntrySliceElement.Basename) && (multipartDirName != dirEntrySliceElement.Basename) {
 					statResult, err = vS.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirEntrySliceElement.InodeNumber)
 					if nil != err {
 						return
@@ -1806,7 +1915,7 @@ type dirEntrySliceStackElementStruct struct {
 	moreEntries   bool
 }
 
-func (vS *volumeStruct) MiddlewareGetContainer(vContainerName string, maxEntries uint64, marker string, endmarker string, prefix string, delimiter string) (containerEnts []ContainerEntry, err error) {
+func (vS *volumeStruct) MiddlewareGetContainer(vContainerName string, maxEntries uint64, marker string, endmarker string, prefix string, delimiter string, versions bool) (containerEnts []ContainerEntry, err error) {
 	var (
 		containerEntry                ContainerEntry
 		containerEntryBasename        string // Misnamed... this is actually everything after ContainerName
@@ -1848,6 +1957,11 @@ func (vS *volumeStruct) MiddlewareGetContainer(vContainerName string, maxEntries
 		tryLockBackoffContext         *tryLockBackoffContextStruct
 	)
 
+	if versions {
+		containerEnts, err = vS.middlewareGetContainerVersions(vContainerName, maxEntries, marker, endmarker, prefix, delimiter)
+		return
+	}
+
 	// Validate marker, endmarker, and prefix
 
 	if "" == marker {
@@ -2403,7 +2517,7 @@ Restart:
 	return
 }
 
-func (vS *volumeStruct) MiddlewareGetObject(containerObjectPath string,
+func (vS *volumeStruct) MiddlewareGetObject(containerObjectPath string, versionID uint64,
 	readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (
 	response HeadResponse, err error) {
 
@@ -2414,6 +2528,7 @@ func (vS *volumeStruct) MiddlewareGetObject(containerObjectPath string,
 		inodeVolumeHandle     inode.VolumeHandle
 		readPlan              []inode.ReadPlanStep
 		readRangeInIndex      int
+		resolvePathOptions    resolvePathOption
 		retryRequired         bool
 		stat                  Stat
 		tryLockBackoffContext *tryLockBackoffContextStruct
@@ -2447,13 +2562,18 @@ Restart:
 
 	heldLocks = newHeldLocks()
 
-	_, dirEntryInodeNumber, _, _, retryRequired, err =
-		vS.resolvePath(
-			inode.RootDirInodeNumber,
-			containerObjectPath,
-			heldLocks,
-			resolvePathFollowDirEntrySymlinks|
-				resolvePathFollowDirSymlinks)
+	resolvePathOptions = resolvePathFollowDirEntrySymlinks | resolvePathFollowDirSymlinks
+
+	if 0 == versionID {
+		_, dirEntryInodeNumber, _, _, retryRequired, err =
+			vS.resolvePath(
+				inode.RootDirInodeNumber,
+				containerObjectPath,
+				heldLocks,
+				resolvePathOptions)
+	} else {
+		dirEntryInodeNumber, retryRequired, err = vS.resolveObjectVersion(containerObjectPath, inode.InodeNumber(versionID), heldLocks, resolvePathOptions)
+	}
 
 	if nil != err {
 		heldLocks.free()
@@ -2505,6 +2625,11 @@ Restart:
 	}
 
 	inodeVolumeHandle = vS.inodeVolumeHandle
+
+	// Note that inlined file data has no ObjectPath to return... so such ReadPlanSteps are
+	// returned with their Data in its place. Compressed frames are returned as is for the
+	// middleware to decompress (see inode.ReadPlanStep.CompressedLength).
+
 	if len(readRangeIn) == 0 {
 		// Get ReadPlan for entire file
 
@@ -2537,7 +2662,7 @@ Restart:
 	return
 }
 
-func (vS *volumeStruct) MiddlewareHeadResponse(entityPath string) (response HeadResponse, err error) {
+func (vS *volumeStruct) MiddlewareHeadResponse(entityPath string, versionID uint64) (response HeadResponse, err error) {
 	var (
 		dirEntryInodeNumber   inode.InodeNumber
 		heldLocks             *heldLocksStruct
@@ -2568,13 +2693,17 @@ Restart:
 
 	heldLocks = newHeldLocks()
 
-	_, dirEntryInodeNumber, _, _, retryRequired, err =
-		vS.resolvePath(
-			inode.RootDirInodeNumber,
-			entityPath,
-			heldLocks,
-			resolvePathFollowDirEntrySymlinks|
-				resolvePathFollowDirSymlinks)
+	if 0 == versionID {
+		_, dirEntryInodeNumber, _, _, retryRequired, err =
+			vS.resolvePath(
+				inode.RootDirInodeNumber,
+				entityPath,
+				heldLocks,
+				resolvePathFollowDirEntrySymlinks|
+					resolvePathFollowDirSymlinks)
+	} else {
+		dirEntryInodeNumber, retryRequired, err = vS.resolveObjectVersion(entityPath, inode.InodeNumber(versionID), heldLocks, resolvePathFollowDirEntrySymlinks|resolvePathFollowDirSymlinks)
+	}
 
 	if nil != err {
 		heldLocks.free()
@@ -2644,6 +2773,9 @@ func (vS *volumeStruct) MiddlewarePost(parentDir string, baseName string, newMet
 		}
 	}()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	// Retry until done or failure (starting with ZERO backoff)
 
 	tryLockBackoffContext = &tryLockBackoffContextStruct{}
@@ -2678,6 +2810,12 @@ Restart:
 		goto Restart
 	}
 
+	err = vS.wormCheck(dirEntryInodeNumber, wormOpModify)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
 	// Now apply MiddlewareStream update
 
 	// Compare oldMetaData to existing existingStreamData to make sure that the HTTP metadata has not changed.
@@ -2714,60 +2852,21 @@ Restart:
 
 	vS.untrackInFlightFileInodeData(dirEntryInodeNumber, false)
 
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventXAttr, inodeNumber: dirEntryInodeNumber, path: "/" + parentDir + "/" + baseName})
+
 	heldLocks.free()
 	return
 }
 
-func (vS *volumeStruct) MiddlewarePutComplete(vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte) (mtime uint64, ctime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+// middlewarePutCompleteHelper resolves (creating as necessary) the FileInode at vContainerName/vObjectPath
+// into which an object PUT is to be written, first removing any (empty) directory or symlink in its way.
+// In a container retaining prior versions (i.e. versionPath != ""), a fresh FileInode takes the place of
+// an existing one. On return, heldLocks includes exclusive locks on dirInodeNumber and dirEntryInodeNumber.
+func (vS *volumeStruct) middlewarePutCompleteHelper(vContainerName string, vObjectPath string, versionPath string, heldLocks *heldLocksStruct) (dirInodeNumber inode.InodeNumber, dirEntryInodeNumber inode.InodeNumber, dirEntryBasename string, retryRequired bool, err error) {
 	var (
-		containerName         string
-		dirInodeNumber        inode.InodeNumber
-		dirEntryInodeNumber   inode.InodeNumber
-		dirEntryBasename      string
-		dirEntryInodeType     inode.InodeType
-		fileOffset            uint64
-		heldLocks             *heldLocksStruct
-		inodeVolumeHandle     inode.VolumeHandle = vS.inodeVolumeHandle
-		inodeWroteTime        time.Time
-		numPObjects           int
-		objectName            string
-		pObjectIndex          int
-		retryRequired         bool
-		stat                  Stat
-		tryLockBackoffContext *tryLockBackoffContextStruct
+		dirEntryInodeType inode.InodeType
 	)
 
-	startTime := time.Now()
-	defer func() {
-		globals.MiddlewarePutCompleteUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
-		if err != nil {
-			globals.MiddlewarePutCompleteErrors.Add(1)
-		}
-	}()
-
-	// Validate (pObjectPaths,pObjectLengths) args
-
-	numPObjects = len(pObjectPaths)
-
-	if numPObjects != len(pObjectLengths) {
-		blunder.NewError(blunder.InvalidArgError, "MiddlewarePutComplete() expects len(pObjectPaths) == len(pObjectLengths)")
-		return
-	}
-
-	// Retry until done or failure (starting with ZERO backoff)
-
-	tryLockBackoffContext = &tryLockBackoffContextStruct{}
-
-Restart:
-
-	// Perform backoff and update for each restart (starting with ZERO backoff of course)
-
-	tryLockBackoffContext.backoff()
-
-	// Construct fresh heldLocks for this restart
-
-	heldLocks = newHeldLocks()
-
 	dirInodeNumber, dirEntryInodeNumber, dirEntryBasename, dirEntryInodeType, retryRequired, err =
 		vS.resolvePath(
 			inode.RootDirInodeNumber,
@@ -2778,14 +2877,9 @@ Restart:
 				resolvePathCreateMissingPathElements|
 				resolvePathRequireExclusiveLockOnDirInode|
 				resolvePathRequireExclusiveLockOnDirEntryInode)
-	if nil != err {
-		heldLocks.free()
+	if (nil != err) || retryRequired {
 		return
 	}
-	if retryRequired {
-		heldLocks.free()
-		goto Restart
-	}
 
 	// The semantics of PUT mean that the existing object is discarded; with
 	// a file we can just overwrite it, but symlinks or directories must be
@@ -2795,24 +2889,22 @@ Restart:
 		if dirEntryInodeType == inode.DirType {
 
 			// try to unlink the directory (rmdir flushes the inodes)
-			err = vS.rmdirActual(dirInodeNumber, dirEntryBasename, dirEntryInodeNumber)
+			err = vS.rmdirActual(dirInodeNumber, dirEntryBasename, dirEntryInodeNumber, "")
 			if err != nil {
 				// the directory was probably not empty
-				heldLocks.free()
 				return
 
 			}
 
 		} else {
 			// unlink the symlink (unlink flushes the inodes)
-			err = vS.unlinkActual(dirInodeNumber, dirEntryBasename, dirEntryInodeNumber)
+			err = vS.unlinkActual(dirInodeNumber, dirEntryBasename, dirEntryInodeNumber, "")
 			if err != nil {
 
 				// ReadOnlyError is my best guess for the failure
 				err = blunder.NewError(blunder.ReadOnlyError,
 					"MiddlewareMkdir(): vol '%s' failed to unlink '%s': %v",
 					vS.volumeName, vContainerName+"/"+vObjectPath, err)
-				heldLocks.free()
 				return
 			}
 		}
@@ -2828,14 +2920,96 @@ Restart:
 					resolvePathDirEntryInodeMustBeFile|
 					resolvePathRequireExclusiveLockOnDirInode|
 					resolvePathRequireExclusiveLockOnDirEntryInode)
+		if (nil != err) || retryRequired {
+			return
+		}
+	}
+
+	// Absent a retained prior version, the WORM state of the FileInode must permit erasing it
+
+	if ("" == versionPath) && (inode.FileType == dirEntryInodeType) {
+		err = vS.wormCheck(dirEntryInodeNumber, wormOpModify)
 		if nil != err {
-			heldLocks.free()
 			return
 		}
-		if retryRequired {
-			heldLocks.free()
-			goto Restart
+	}
+
+	// In a container retaining prior versions, write to a fresh FileInode rather than erasing it
+
+	if ("" != versionPath) && (inode.FileType == dirEntryInodeType) {
+		dirEntryInodeNumber, err = vS.replaceObjectVersion(dirInodeNumber, dirEntryBasename, dirEntryInodeNumber, versionPath, heldLocks)
+		if nil != err {
+			return
+		}
+	}
+
+	return
+}
+
+func (vS *volumeStruct) MiddlewarePutComplete(vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte) (mtime uint64, ctime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+	var (
+		containerName         string
+		dirInodeNumber        inode.InodeNumber
+		dirEntryInodeNumber   inode.InodeNumber
+		dirEntryBasename      string
+		fileOffset            uint64
+		heldLocks             *heldLocksStruct
+		inodeVolumeHandle     inode.VolumeHandle = vS.inodeVolumeHandle
+		inodeWroteTime        time.Time
+		numPObjects           int
+		objectName            string
+		pObjectIndex          int
+		retryRequired         bool
+		stat                  Stat
+		tryLockBackoffContext *tryLockBackoffContextStruct
+		versionPath           string
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.MiddlewarePutCompleteUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.MiddlewarePutCompleteErrors.Add(1)
 		}
+	}()
+
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
+	// Validate (pObjectPaths,pObjectLengths) args
+
+	numPObjects = len(pObjectPaths)
+
+	if numPObjects != len(pObjectLengths) {
+		blunder.NewError(blunder.InvalidArgError, "MiddlewarePutComplete() expects len(pObjectPaths) == len(pObjectLengths)")
+		return
+	}
+
+	versionPath = vS.objectVersionsPath(vContainerName + "/" + vObjectPath)
+
+	// Retry until done or failure (starting with ZERO backoff)
+
+	tryLockBackoffContext = &tryLockBackoffContextStruct{}
+
+Restart:
+
+	// Perform backoff and update for each restart (starting with ZERO backoff of course)
+
+	tryLockBackoffContext.backoff()
+
+	// Construct fresh heldLocks for this restart
+
+	heldLocks = newHeldLocks()
+
+	dirInodeNumber, dirEntryInodeNumber, dirEntryBasename, retryRequired, err =
+		vS.middlewarePutCompleteHelper(vContainerName, vObjectPath, versionPath, heldLocks)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
 	}
 
 	// Apply (pObjectPaths,pObjectLengths) to (erased) FileInode
@@ -2890,6 +3064,8 @@ Restart:
 	fileInodeNumber = dirEntryInodeNumber
 	numWrites = stat[StatNumWrites]
 
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventWriteClose, inodeNumber: dirEntryInodeNumber, dirInodeNumber: dirInodeNumber, basename: dirEntryBasename})
+
 	heldLocks.free()
 	return
 }
@@ -2914,6 +3090,9 @@ func (vS *volumeStruct) MiddlewareMkdir(vContainerName string, vObjectPath strin
 		}
 	}()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	// Retry until done or failure (starting with ZERO backoff)
 
 	tryLockBackoffContext = &tryLockBackoffContextStruct{}
@@ -2953,7 +3132,7 @@ Restart:
 	if dirEntryInodeType != inode.DirType {
 
 		// unlink the file or symlink (unlink flushes the inodes)
-		err = vS.unlinkActual(dirInodeNumber, dirEntryBasename, dirEntryInodeNumber)
+		err = vS.unlinkActual(dirInodeNumber, dirEntryBasename, dirEntryInodeNumber, "")
 		if err != nil {
 
 			// ReadOnlyError is my best guess for the failure
@@ -3003,6 +3182,8 @@ Restart:
 	inodeNumber = dirEntryInodeNumber
 	numWrites = stat[StatNumWrites]
 
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventCreate, inodeNumber: dirEntryInodeNumber, dirInodeNumber: dirInodeNumber, basename: dirEntryBasename})
+
 	heldLocks.free()
 	return
 }
@@ -3105,6 +3286,9 @@ func (vS *volumeStruct) Mkdir(userID inode.InodeUserID, groupID inode.InodeGroup
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	// Make sure the file basename is not too long
 	err = validateBaseName(basename)
 	if err != nil {
@@ -3148,7 +3332,13 @@ func (vS *volumeStruct) Mkdir(userID inode.InodeUserID, groupID inode.InodeGroup
 		return 0, err
 	}
 
-	err = vS.inodeVolumeHandle.Link(inodeNumber, basename, newDirInodeNumber, false)
+	err = vS.wormCheck(inodeNumber, wormOpAddEntry)
+	if err == nil {
+		err = vS.inheritStreams(inodeNumber, newDirInodeNumber, inode.DirType)
+	}
+	if err == nil {
+		err = vS.inodeVolumeHandle.Link(inodeNumber, basename, newDirInodeNumber, false)
+	}
 	if err != nil {
 		destroyErr := vS.inodeVolumeHandle.Destroy(newDirInodeNumber)
 		if destroyErr != nil {
@@ -3157,6 +3347,8 @@ func (vS *volumeStruct) Mkdir(userID inode.InodeUserID, groupID inode.InodeGroup
 		return 0, err
 	}
 
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventCreate, inodeNumber: newDirInodeNumber, dirInodeNumber: inodeNumber, basename: basename})
+
 	return newDirInodeNumber, nil
 }
 
@@ -3172,6 +3364,9 @@ func (vS *volumeStruct) RemoveXAttr(userID inode.InodeUserID, groupID inode.Inod
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	inodeLock, err := vS.inodeVolumeHandle.InitInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -3192,10 +3387,16 @@ func (vS *volumeStruct) RemoveXAttr(userID inode.InodeUserID, groupID inode.Inod
 		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
 		return
 	}
+	err = vS.wormCheckXAttr(userID, groupID, otherGroupIDs, inodeNumber, streamName, nil)
+	if err != nil {
+		return
+	}
 
 	err = vS.inodeVolumeHandle.DeleteStream(inodeNumber, streamName)
 	if err != nil {
 		logger.ErrorfWithError(err, "Failed to delete XAttr %v of inode %v", streamName, inodeNumber)
+	} else {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventXAttr, inodeNumber: inodeNumber})
 	}
 
 	vS.untrackInFlightFileInodeData(inodeNumber, false)
@@ -3203,12 +3404,14 @@ func (vS *volumeStruct) RemoveXAttr(userID inode.InodeUserID, groupID inode.Inod
 	return
 }
 
-func (vS *volumeStruct) workerForMoveAndRename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (toDestroyInodeNumber inode.InodeNumber, heldLocks *heldLocksStruct, err error) {
+func (vS *volumeStruct) workerForMoveAndRename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags inode.MoveFlags) (toDestroyInodeNumber inode.InodeNumber, replacedInodeNumber inode.InodeNumber, heldLocks *heldLocksStruct, err error) {
 	var (
 		dirEntryBasename      string
 		dirEntryInodeNumber   inode.InodeNumber
 		dirInodeNumber        inode.InodeNumber
+		dstInodeNumber        inode.InodeNumber
 		retryRequired         bool
+		srcInodeNumber        inode.InodeNumber
 		tryLockBackoffContext *tryLockBackoffContextStruct
 	)
 
@@ -3240,7 +3443,7 @@ Restart:
 
 	// Acquire WriteLock on {srcDirInodeNumber,srcBasename} & perform Access Check
 
-	dirInodeNumber, _, dirEntryBasename, _, retryRequired, err =
+	dirInodeNumber, srcInodeNumber, dirEntryBasename, _, retryRequired, err =
 		vS.resolvePath(
 			srcDirInodeNumber,
 			srcBasename,
@@ -3312,7 +3515,9 @@ Restart:
 
 	// Acquire WriteLock on dstBasename if it exists
 
-	dirInodeNumber, _, dirEntryBasename, _, retryRequired, err =
+	dstInodeNumber = inode.InodeNumber(0)
+
+	dirInodeNumber, dirEntryInodeNumber, dirEntryBasename, _, retryRequired, err =
 		vS.resolvePath(
 			dstDirInodeNumber,
 			dstBasename,
@@ -3331,21 +3536,44 @@ Restart:
 			err = blunder.NewError(blunder.InvalidArgError, "EINVAL")
 			return
 		}
+
+		dstInodeNumber = dirEntryInodeNumber
 	} else {
 		// This is actually OK... it means the target path of the Rename() isn't being potentially replaced
 	}
 
+	// Both the source and any replaced destination are removed from their directories
+
+	err = vS.wormCheckUnlink(srcDirInodeNumber, srcInodeNumber)
+	if nil == err {
+		err = vS.wormCheck(dstDirInodeNumber, wormOpAddEntry)
+	}
+	if (nil == err) && (inode.InodeNumber(0) != dstInodeNumber) {
+		err = vS.wormCheckUnlink(dstDirInodeNumber, dstInodeNumber)
+	}
+	if nil != err {
+		heldLocks.free()
+		heldLocks = nil
+		return
+	}
+
 	// Locks held & Access Checks succeeded... time to do the Move
 
-	toDestroyInodeNumber, err = vS.inodeVolumeHandle.Move(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename)
+	toDestroyInodeNumber, err = vS.inodeVolumeHandle.Move(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, flags)
+
+	if (nil == err) && (inode.MoveFlagExchange != flags) && (dstInodeNumber != srcInodeNumber) {
+		replacedInodeNumber = dstInodeNumber
+	}
 
 	return // err returned from inode.Move() suffices here
 }
 
-func (vS *volumeStruct) Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (err error) {
+func (vS *volumeStruct) Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags inode.MoveFlags) (err error) {
 	var (
 		destroyErr           error
 		heldLocks            *heldLocksStruct
+		recycleBinPath       string
+		replacedInodeNumber  inode.InodeNumber
 		toDestroyInodeNumber inode.InodeNumber
 	)
 
@@ -3360,10 +3588,28 @@ func (vS *volumeStruct) Rename(userID inode.InodeUserID, groupID inode.InodeGrou
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
-	toDestroyInodeNumber, heldLocks, err = vS.workerForMoveAndRename(userID, groupID, otherGroupIDs, srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename)
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
 
-	if (nil == err) && (inode.InodeNumber(0) != toDestroyInodeNumber) {
-		destroyErr = vS.inodeVolumeHandle.Destroy(toDestroyInodeNumber)
+	if inode.MoveFlagsNone == flags {
+		recycleBinPath = vS.recycleBinEntryPath(dstDirInodeNumber, dstBasename)
+	} else {
+		recycleBinPath = ""
+	}
+
+	toDestroyInodeNumber, replacedInodeNumber, heldLocks, err = vS.workerForMoveAndRename(userID, groupID, otherGroupIDs, srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, flags)
+
+	if nil == err {
+		vS.addRenameChangeEvents(changeEvents, srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, flags, replacedInodeNumber)
+	}
+
+	if (nil != heldLocks) && heldLocks.holds(vS.recycleBinDirInodeNumber) {
+		// Avoid self-deadlock (e.g. when renaming an entry out of the RecycleBin)
+		recycleBinPath = ""
+	}
+
+	if (nil == err) && (inode.InodeNumber(0) != toDestroyInodeNumber) && !vS.recycle(toDestroyInodeNumber, recycleBinPath) {
+		destroyErr = vS.inodeVolumeHandle.Destroy(toDestroyInodeNumber)
 		if nil != destroyErr {
 			logger.ErrorWithError(destroyErr)
 		}
@@ -3376,9 +3622,10 @@ func (vS *volumeStruct) Rename(userID inode.InodeUserID, groupID inode.InodeGrou
 	return
 }
 
-func (vS *volumeStruct) Move(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (toDestroyInodeNumber inode.InodeNumber, err error) {
+func (vS *volumeStruct) Move(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags inode.MoveFlags) (toDestroyInodeNumber inode.InodeNumber, err error) {
 	var (
-		heldLocks *heldLocksStruct
+		heldLocks           *heldLocksStruct
+		replacedInodeNumber inode.InodeNumber
 	)
 
 	startTime := time.Now()
@@ -3392,7 +3639,14 @@ func (vS *volumeStruct) Move(userID inode.InodeUserID, groupID inode.InodeGroupI
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
-	toDestroyInodeNumber, heldLocks, err = vS.workerForMoveAndRename(userID, groupID, otherGroupIDs, srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename)
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
+	toDestroyInodeNumber, replacedInodeNumber, heldLocks, err = vS.workerForMoveAndRename(userID, groupID, otherGroupIDs, srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, flags)
+
+	if nil == err {
+		vS.addRenameChangeEvents(changeEvents, srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, flags, replacedInodeNumber)
+	}
 
 	if nil != heldLocks {
 		heldLocks.free()
@@ -3441,6 +3695,12 @@ func (vS *volumeStruct) Destroy(userID inode.InodeUserID, groupID inode.InodeGro
 		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
 		return
 	}
+	err = vS.wormCheck(inodeNumber, wormOpRemove)
+	if err != nil {
+		_ = inodeLock.Unlock()
+		vS.jobRWMutex.RUnlock()
+		return
+	}
 
 	err = vS.inodeVolumeHandle.Destroy(inodeNumber)
 
@@ -3676,6 +3936,9 @@ func (vS *volumeStruct) Resize(userID inode.InodeUserID, groupID inode.InodeGrou
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	inodeLock, err := vS.inodeVolumeHandle.InitInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -3698,10 +3961,18 @@ func (vS *volumeStruct) Resize(userID inode.InodeUserID, groupID inode.InodeGrou
 		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
 		return
 	}
+	err = vS.wormCheck(inodeNumber, wormOpModify)
+	if err != nil {
+		return
+	}
 
 	err = vS.inodeVolumeHandle.SetSize(inodeNumber, newSize)
 	vS.untrackInFlightFileInodeData(inodeNumber, false)
 
+	if nil == err {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventSetattr, inodeNumber: inodeNumber})
+	}
+
 	return err
 }
 
@@ -3717,6 +3988,11 @@ func (vS *volumeStruct) Rmdir(userID inode.InodeUserID, groupID inode.InodeGroup
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
+	recycleBinPath := vS.recycleBinEntryPath(inodeNumber, basename)
+
 	callerID := dlm.GenerateCallerID()
 	inodeLock, err := vS.inodeVolumeHandle.InitInodeLock(inodeNumber, callerID)
 	if err != nil {
@@ -3756,11 +4032,14 @@ func (vS *volumeStruct) Rmdir(userID inode.InodeUserID, groupID inode.InodeGroup
 
 	// no permissions are required on the target directory
 
-	err = vS.rmdirActual(inodeNumber, basename, basenameInodeNumber)
+	err = vS.rmdirActual(inodeNumber, basename, basenameInodeNumber, recycleBinPath)
+	if nil == err {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventUnlink, inodeNumber: basenameInodeNumber, dirInodeNumber: inodeNumber, basename: basename})
+	}
 	return
 }
 
-func (vS *volumeStruct) rmdirActual(inodeNumber inode.InodeNumber, basename string, basenameInodeNumber inode.InodeNumber) (err error) {
+func (vS *volumeStruct) rmdirActual(inodeNumber inode.InodeNumber, basename string, basenameInodeNumber inode.InodeNumber, recycleBinPath string) (err error) {
 	var (
 		basenameInodeType    inode.InodeType
 		dirEntries           uint64
@@ -3789,12 +4068,17 @@ func (vS *volumeStruct) rmdirActual(inodeNumber inode.InodeNumber, basename stri
 		return
 	}
 
+	err = vS.wormCheckUnlink(inodeNumber, basenameInodeNumber)
+	if nil != err {
+		return
+	}
+
 	toDestroyInodeNumber, err = vS.inodeVolumeHandle.Unlink(inodeNumber, basename, false)
 	if nil != err {
 		return
 	}
 
-	if inode.InodeNumber(0) != toDestroyInodeNumber {
+	if (inode.InodeNumber(0) != toDestroyInodeNumber) && !vS.recycle(toDestroyInodeNumber, recycleBinPath) {
 		err = vS.inodeVolumeHandle.Destroy(basenameInodeNumber)
 		if nil != err {
 			return
@@ -3816,6 +4100,9 @@ func (vS *volumeStruct) Setstat(userID inode.InodeUserID, groupID inode.InodeGro
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	inodeLock, err := vS.inodeVolumeHandle.InitInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -3831,6 +4118,10 @@ func (vS *volumeStruct) Setstat(userID inode.InodeUserID, groupID inode.InodeGro
 		err = blunder.NewError(blunder.NotPermError, "EPERM")
 		return
 	}
+	err = vS.wormCheck(inodeNumber, wormOpModify)
+	if err != nil {
+		return
+	}
 
 	// perform all permissions checks before making any changes
 	//
@@ -3995,6 +4286,10 @@ func (vS *volumeStruct) Setstat(userID inode.InodeUserID, groupID inode.InodeGro
 		}
 	}
 
+	if nil == err {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventSetattr, inodeNumber: inodeNumber})
+	}
+
 	return
 }
 
@@ -4010,6 +4305,9 @@ func (vS *volumeStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGr
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	inodeLock, err := vS.inodeVolumeHandle.InitInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
@@ -4030,6 +4328,10 @@ func (vS *volumeStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGr
 		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
 		return
 	}
+	err = vS.wormCheckXAttr(userID, groupID, otherGroupIDs, inodeNumber, streamName, value)
+	if err != nil {
+		return
+	}
 
 	switch flags {
 	case SetXAttrCreateOrReplace:
@@ -4051,6 +4353,8 @@ func (vS *volumeStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGr
 	err = vS.inodeVolumeHandle.PutStream(inodeNumber, streamName, value)
 	if err != nil {
 		logger.ErrorfWithError(err, "Failed to set XAttr %v to inode %v", streamName, inodeNumber)
+	} else {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventXAttr, inodeNumber: inodeNumber})
 	}
 
 	vS.untrackInFlightFileInodeData(inodeNumber, false)
@@ -4058,6 +4362,663 @@ func (vS *volumeStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGr
 	return
 }
 
+// SnapShotDiff finds the Paths of every Inode beyond lastInodeNumber that differs between the two views.
+// Differing Inodes are compared (and their Paths found with a single walk of each view) in batches of at
+// least snapShotDiffBatchMinEntries. Should a batch exceed maxEntries, the remainder is retained such that
+// the call continuing the enumeration (i.e. passing the InodeNumber of the last SnapShotDiffEntry returned)
+// need not walk the namespace again. Up to snapShotDiffCursorsMax such enumerations are retained.
+func (vS *volumeStruct) SnapShotDiff(fromSnapShotID uint64, toSnapShotID uint64, lastInodeNumber inode.InodeNumber, maxEntries uint64) (diffEntries []SnapShotDiffEntry, moreEntries bool, err error) {
+	var (
+		batchMaxEntries uint64
+		cursor          *snapShotDiffCursorStruct
+		cursorKey       snapShotDiffCursorKeyStruct
+		ok              bool
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.SnapShotDiffUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		globals.SnapShotDiffEntries.Add(uint64(len(diffEntries)))
+		if err != nil {
+			globals.SnapShotDiffErrors.Add(1)
+		}
+	}()
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	cursorKey = snapShotDiffCursorKeyStruct{
+		fromSnapShotID:  fromSnapShotID,
+		toSnapShotID:    toSnapShotID,
+		lastInodeNumber: lastInodeNumber,
+	}
+
+	vS.snapShotDiffMutex.Lock()
+	cursor, ok = vS.snapShotDiffCursorMap[cursorKey]
+	if ok {
+		delete(vS.snapShotDiffCursorMap, cursorKey)
+		_ = vS.snapShotDiffCursorLRU.Remove(cursor.lruElement)
+	}
+	vS.snapShotDiffMutex.Unlock()
+
+	if !ok {
+		if (0 == maxEntries) || (snapShotDiffBatchMinEntries <= maxEntries) {
+			batchMaxEntries = maxEntries
+		} else {
+			batchMaxEntries = snapShotDiffBatchMinEntries
+		}
+
+		cursor = &snapShotDiffCursorStruct{}
+
+		cursor.diffEntries, cursor.moreInodeEntries, err = vS.snapShotDiffEntries(fromSnapShotID, toSnapShotID, lastInodeNumber, batchMaxEntries)
+		if nil != err {
+			return
+		}
+	}
+
+	if (0 == maxEntries) || (uint64(len(cursor.diffEntries)) <= maxEntries) {
+		diffEntries = cursor.diffEntries
+		moreEntries = cursor.moreInodeEntries
+		return
+	}
+
+	diffEntries = cursor.diffEntries[:maxEntries]
+	moreEntries = true
+
+	cursor.key = snapShotDiffCursorKeyStruct{
+		fromSnapShotID:  fromSnapShotID,
+		toSnapShotID:    toSnapShotID,
+		lastInodeNumber: diffEntries[maxEntries-1].InodeNumber,
+	}
+	cursor.diffEntries = cursor.diffEntries[maxEntries:]
+
+	vS.snapShotDiffMutex.Lock()
+	_, ok = vS.snapShotDiffCursorMap[cursor.key]
+	if ok {
+		// Another client is continuing the identical enumeration... its cursor serves us both
+
+		vS.snapShotDiffMutex.Unlock()
+		return
+	}
+	if snapShotDiffCursorsMax == vS.snapShotDiffCursorLRU.Len() {
+		delete(vS.snapShotDiffCursorMap, vS.snapShotDiffCursorLRU.Remove(vS.snapShotDiffCursorLRU.Front()).(*snapShotDiffCursorStruct).key)
+	}
+	vS.snapShotDiffCursorMap[cursor.key] = cursor
+	cursor.lruElement = vS.snapShotDiffCursorLRU.PushBack(cursor)
+	vS.snapShotDiffMutex.Unlock()
+
+	return
+}
+
+// snapShotDiffEntries returns up to maxEntries (0 meaning all) Inodes beyond lastInodeNumber that differ
+// between the two views along with their Paths.
+func (vS *volumeStruct) snapShotDiffEntries(fromSnapShotID uint64, toSnapShotID uint64, lastInodeNumber inode.InodeNumber, maxEntries uint64) (diffEntries []SnapShotDiffEntry, moreEntries bool, err error) {
+	var (
+		deletedEntryMap    map[inode.InodeNumber]*SnapShotDiffEntry
+		diffEntryIndex     int
+		inodeDiffEntries   []inode.SnapShotDiffEntry
+		inodeDiffEntry     inode.SnapShotDiffEntry
+		notDeletedEntryMap map[inode.InodeNumber]*SnapShotDiffEntry
+	)
+
+	inodeDiffEntries, moreEntries, err = vS.inodeVolumeHandle.SnapShotDiff(fromSnapShotID, toSnapShotID, lastInodeNumber, maxEntries)
+	if nil != err {
+		return
+	}
+
+	diffEntries = make([]SnapShotDiffEntry, len(inodeDiffEntries))
+
+	deletedEntryMap = make(map[inode.InodeNumber]*SnapShotDiffEntry)
+	notDeletedEntryMap = make(map[inode.InodeNumber]*SnapShotDiffEntry)
+
+	for diffEntryIndex, inodeDiffEntry = range inodeDiffEntries {
+		diffEntries[diffEntryIndex] = SnapShotDiffEntry{
+			SnapShotDiffEntry: inodeDiffEntry,
+			Paths:             make([]string, 0, 1),
+		}
+
+		if inode.SnapShotDiffDeleted == inodeDiffEntry.DiffType {
+			deletedEntryMap[inodeDiffEntry.InodeNumber] = &diffEntries[diffEntryIndex]
+		} else {
+			notDeletedEntryMap[inodeDiffEntry.InodeNumber] = &diffEntries[diffEntryIndex]
+		}
+	}
+
+	// Paths of deleted Inodes are found in the "from" view... all others in the "to" view
+
+	if 0 < len(deletedEntryMap) {
+		err = vS.snapShotDiffPathsInView(fromSnapShotID, deletedEntryMap)
+		if nil != err {
+			return
+		}
+	}
+	if 0 < len(notDeletedEntryMap) {
+		err = vS.snapShotDiffPathsInView(toSnapShotID, notDeletedEntryMap)
+		if nil != err {
+			return
+		}
+	}
+
+	return
+}
+
+// snapShotDiffPathsInView walks the namespace of the view selected by snapShotID (zero for the live view)
+// appending each path found for an Inode in diffEntryMap (keyed by live view InodeNumber). A full walk is
+// required since FileInodes (unlike DirInodes) have no reference back to their parent directory (and may
+// have several due to hard links).
+func (vS *volumeStruct) snapShotDiffPathsInView(snapShotID uint64, diffEntryMap map[inode.InodeNumber]*SnapShotDiffEntry) (err error) {
+	type dirToWalkStruct struct {
+		dirInodeNumber inode.InodeNumber
+		dirPath        string
+	}
+
+	var (
+		diffEntry          *SnapShotDiffEntry
+		dirEntry           inode.DirEntry
+		dirEntryNonce      uint64
+		dirEntryPath       string
+		dirEntrySlice      []inode.DirEntry
+		dirInodeLock       *dlm.RWLockStruct
+		dirToWalk          dirToWalkStruct
+		dirsToWalk         []dirToWalkStruct
+		dlmCallerID        dlm.CallerID
+		inodeType          inode.InodeType
+		moreEntries        bool
+		ok                 bool
+		prevReturned       string
+		rootDirInodeNumber inode.InodeNumber
+	)
+
+	dlmCallerID = dlm.GenerateCallerID()
+
+	rootDirInodeNumber = inode.InodeNumber(vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(snapShotID, uint64(inode.RootDirInodeNumber)))
+
+	diffEntry, ok = diffEntryMap[inode.RootDirInodeNumber]
+	if ok {
+		diffEntry.Paths = append(diffEntry.Paths, "/")
+	}
+
+	dirsToWalk = []dirToWalkStruct{{dirInodeNumber: rootDirInodeNumber, dirPath: ""}}
+
+	for 0 < len(dirsToWalk) {
+		dirToWalk = dirsToWalk[len(dirsToWalk)-1]
+		dirsToWalk = dirsToWalk[:len(dirsToWalk)-1]
+
+		prevReturned = ""
+		moreEntries = true
+
+		for moreEntries {
+			dirInodeLock, err = vS.inodeVolumeHandle.GetReadLock(dirToWalk.dirInodeNumber, dlmCallerID)
+			if nil != err {
+				return
+			}
+
+			if "" == prevReturned {
+				dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(dirToWalk.dirInodeNumber, snapShotDiffReadDirMaxEntries, 0)
+			} else {
+				dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(dirToWalk.dirInodeNumber, snapShotDiffReadDirMaxEntries, 0, prevReturned)
+			}
+
+			_ = dirInodeLock.Unlock()
+
+			if nil != err {
+				if blunder.Is(err, blunder.NotFoundError) {
+					// Directory (in the live view) was removed while we were walking... just skip it
+					err = nil
+					break
+				}
+				return
+			}
+
+			for _, dirEntry = range dirEntrySlice {
+				prevReturned = dirEntry.Basename
+
+				if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+					continue
+				}
+				if (rootDirInodeNumber == dirToWalk.dirInodeNumber) && (inode.SnapShotDirName == dirEntry.Basename) {
+					continue
+				}
+
+				dirEntryPath = dirToWalk.dirPath + "/" + dirEntry.Basename
+
+				_, _, dirEntryNonce = vS.headhunterVolumeHandle.SnapShotU64Decode(uint64(dirEntry.InodeNumber))
+
+				diffEntry, ok = diffEntryMap[inode.InodeNumber(dirEntryNonce)]
+				if ok {
+					diffEntry.Paths = append(diffEntry.Paths, dirEntryPath)
+				}
+
+				inodeType, err = vS.inodeVolumeHandle.GetType(dirEntry.InodeNumber)
+				if nil != err {
+					// DirEntry (in the live view) was removed while we were walking... just skip it
+					err = nil
+					continue
+				}
+
+				if inode.DirType == inodeType {
+					dirsToWalk = append(dirsToWalk, dirToWalkStruct{dirInodeNumber: dirEntry.InodeNumber, dirPath: dirEntryPath})
+				}
+			}
+		}
+	}
+
+	err = nil
+	return
+}
+
+// SnapShotRestore restores the file, symlink, or directory subtree found at path in the SnapShot
+// identified by snapShotID into the live view at the same path. Restored Inodes retain their
+// InodeNumbers and FileInodes share the SnapShot's LogSegments rather than copying their data.
+// Directories are merged: live entries absent from the SnapShot are retained while conflicting
+// non-directory entries are replaced. Should the WORM state of any live Inode to be replaced
+// prohibit its modification (or removal), the restore fails with NotPermError...possibly
+// having restored other Inodes. All other activity on the volume is blocked meanwhile.
+func (vS *volumeStruct) SnapShotRestore(snapShotID uint64, path string) (err error) {
+	var (
+		canonicalizedPathSplit []string
+		dirEntryInodeNumber    inode.InodeNumber
+		inodeType              inode.InodeType
+		liveDirInodeNumber     inode.InodeNumber
+		pathIndex              int
+		snapShotInodeNumber    inode.InodeNumber
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.SnapShotRestoreUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.SnapShotRestoreErrors.Add(1)
+		}
+	}()
+
+	if 0 == snapShotID {
+		err = blunder.NewError(blunder.InvalidArgError, "SnapShotRestore() requires a non-zero snapShotID")
+		return
+	}
+
+	canonicalizedPathSplit, err = canonicalizePath(path)
+	if nil != err {
+		return
+	}
+
+	if (0 < len(canonicalizedPathSplit)) && (inode.SnapShotDirName == canonicalizedPathSplit[0]) {
+		err = blunder.NewError(blunder.InvalidArgError, "SnapShotRestore() of path \"%v\" not allowed", path)
+		return
+	}
+
+	defer vS.viewChanging()()
+
+	vS.jobRWMutex.Lock()
+	defer vS.jobRWMutex.Unlock()
+
+	vS.untrackInFlightFileInodeDataAll()
+
+	// Locate path in the SnapShot and its parent directory in the live view
+
+	snapShotInodeNumber = inode.InodeNumber(vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(snapShotID, uint64(inode.RootDirInodeNumber)))
+	liveDirInodeNumber = inode.RootDirInodeNumber
+
+	for pathIndex = range canonicalizedPathSplit {
+		inodeType, err = vS.inodeVolumeHandle.GetType(snapShotInodeNumber)
+		if nil != err {
+			return
+		}
+		if inode.DirType != inodeType {
+			err = blunder.NewError(blunder.NotDirError, "SnapShotRestore() of path \"%v\" traverses a non-directory in SnapShot %v", path, snapShotID)
+			return
+		}
+
+		snapShotInodeNumber, err = vS.inodeVolumeHandle.Lookup(snapShotInodeNumber, canonicalizedPathSplit[pathIndex])
+		if nil != err {
+			return
+		}
+
+		if pathIndex < (len(canonicalizedPathSplit) - 1) {
+			dirEntryInodeNumber, err = vS.inodeVolumeHandle.Lookup(liveDirInodeNumber, canonicalizedPathSplit[pathIndex])
+			if nil != err {
+				return
+			}
+			inodeType, err = vS.inodeVolumeHandle.GetType(dirEntryInodeNumber)
+			if nil != err {
+				return
+			}
+			if inode.DirType != inodeType {
+				err = blunder.NewError(blunder.NotDirError, "SnapShotRestore() of path \"%v\" traverses a non-directory in the live view", path)
+				return
+			}
+			liveDirInodeNumber = dirEntryInodeNumber
+		}
+	}
+
+	if 0 == len(canonicalizedPathSplit) {
+		err = vS.snapShotRestoreInode(snapShotID, inode.RootDirInodeNumber)
+	} else {
+		err = vS.snapShotRestoreDirEntry(snapShotID, liveDirInodeNumber, canonicalizedPathSplit[len(canonicalizedPathSplit)-1], snapShotInodeNumber)
+	}
+
+	return
+}
+
+// snapShotRestoreDirEntry ensures the live directory dirInodeNumber contains an entry named basename
+// referencing the live view's version of snapShotInodeNumber (restoring it in the process).
+func (vS *volumeStruct) snapShotRestoreDirEntry(snapShotID uint64, dirInodeNumber inode.InodeNumber, basename string, snapShotInodeNumber inode.InodeNumber) (err error) {
+	var (
+		existingInodeNumber  inode.InodeNumber
+		existingInodeType    inode.InodeType
+		inodeNumber          inode.InodeNumber
+		inodeNumberAsUint64  uint64
+		inodeType            inode.InodeType
+		toDestroyInodeNumber inode.InodeNumber
+	)
+
+	_, _, inodeNumberAsUint64 = vS.headhunterVolumeHandle.SnapShotU64Decode(uint64(snapShotInodeNumber))
+	inodeNumber = inode.InodeNumber(inodeNumberAsUint64)
+
+	inodeType, err = vS.inodeVolumeHandle.GetType(snapShotInodeNumber)
+	if nil != err {
+		return
+	}
+
+	existingInodeNumber, err = vS.inodeVolumeHandle.Lookup(dirInodeNumber, basename)
+	if nil == err {
+		if inodeNumber == existingInodeNumber {
+			err = vS.snapShotRestoreInode(snapShotID, inodeNumber)
+			return
+		}
+
+		existingInodeType, err = vS.inodeVolumeHandle.GetType(existingInodeNumber)
+		if nil != err {
+			return
+		}
+		if inode.DirType == existingInodeType {
+			err = blunder.NewError(blunder.FileExistsError, "SnapShotRestore() conflicts with existing directory %v", basename)
+			return
+		}
+
+		err = vS.wormCheckUnlink(dirInodeNumber, existingInodeNumber)
+		if nil != err {
+			return
+		}
+
+		toDestroyInodeNumber, err = vS.inodeVolumeHandle.Unlink(dirInodeNumber, basename, false)
+		if nil != err {
+			return
+		}
+		if inode.InodeNumber(0) != toDestroyInodeNumber {
+			err = vS.inodeVolumeHandle.Destroy(toDestroyInodeNumber)
+			if nil != err {
+				return
+			}
+		}
+	} else if !blunder.Is(err, blunder.NotFoundError) {
+		return
+	}
+
+	if inode.DirType == inodeType {
+		// A directory may only be linked from a single parent
+
+		_, err = vS.inodeVolumeHandle.GetType(inodeNumber)
+		if nil == err {
+			err = blunder.NewError(blunder.FileExistsError, "SnapShotRestore() of directory %v found it elsewhere in the live view", basename)
+			return
+		}
+	}
+
+	err = vS.wormCheck(dirInodeNumber, wormOpAddEntry)
+	if nil != err {
+		return
+	}
+	err = vS.wormCheckSnapShotRestore(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	err = vS.inodeVolumeHandle.SnapShotRestore(snapShotID, inodeNumber)
+	if nil != err {
+		return
+	}
+
+	err = vS.inodeVolumeHandle.Link(dirInodeNumber, basename, inodeNumber, false)
+	if nil != err {
+		return
+	}
+
+	if inode.DirType == inodeType {
+		err = vS.snapShotRestoreDirEntries(snapShotID, inodeNumber)
+	}
+
+	return
+}
+
+// snapShotRestoreInode restores the live Inode inodeNumber from the SnapShot identified by snapShotID
+// (and, if it is a directory, each of its entries as well).
+func (vS *volumeStruct) snapShotRestoreInode(snapShotID uint64, inodeNumber inode.InodeNumber) (err error) {
+	var (
+		inodeType inode.InodeType
+	)
+
+	err = vS.wormCheckSnapShotRestore(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	err = vS.inodeVolumeHandle.SnapShotRestore(snapShotID, inodeNumber)
+	if nil != err {
+		return
+	}
+
+	inodeType, err = vS.inodeVolumeHandle.GetType(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if inode.DirType == inodeType {
+		err = vS.snapShotRestoreDirEntries(snapShotID, inodeNumber)
+	}
+
+	return
+}
+
+// snapShotRestoreDirEntries restores each entry of the SnapShot's version of dirInodeNumber into the live directory.
+func (vS *volumeStruct) snapShotRestoreDirEntries(snapShotID uint64, dirInodeNumber inode.InodeNumber) (err error) {
+	var (
+		dirEntry            inode.DirEntry
+		dirEntrySlice       []inode.DirEntry
+		moreEntries         bool
+		prevReturned        string
+		snapShotInodeNumber inode.InodeNumber
+	)
+
+	snapShotInodeNumber = inode.InodeNumber(vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(snapShotID, uint64(dirInodeNumber)))
+
+	prevReturned = ""
+	moreEntries = true
+
+	for moreEntries {
+		if "" == prevReturned {
+			dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(snapShotInodeNumber, snapShotDiffReadDirMaxEntries, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(snapShotInodeNumber, snapShotDiffReadDirMaxEntries, 0, prevReturned)
+		}
+		if nil != err {
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			prevReturned = dirEntry.Basename
+
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+			if (inode.RootDirInodeNumber == dirInodeNumber) && (inode.SnapShotDirName == dirEntry.Basename) {
+				continue
+			}
+
+			err = vS.snapShotRestoreDirEntry(snapShotID, dirInodeNumber, dirEntry.Basename, dirEntry.InodeNumber)
+			if nil != err {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (vS *volumeStruct) RegisterForViewChanges(listener ViewChangeListener) {
+	var (
+		ok bool
+	)
+
+	vS.viewChangeMutex.Lock()
+
+	_, ok = vS.viewChangeListeners[listener]
+	if ok {
+		logger.Fatalf("fs.RegisterForViewChanges() called for volume %v listener %p already active", vS.volumeName, listener)
+	}
+
+	vS.viewChangeListeners[listener] = struct{}{}
+
+	vS.viewChangeMutex.Unlock()
+}
+
+func (vS *volumeStruct) UnregisterForViewChanges(listener ViewChangeListener) {
+	var (
+		ok bool
+	)
+
+	vS.viewChangeMutex.Lock()
+
+	_, ok = vS.viewChangeListeners[listener]
+	if !ok {
+		logger.Errorf("fs.UnregisterForViewChanges() called for volume %v listener %p not active", vS.volumeName, listener)
+	}
+
+	delete(vS.viewChangeListeners, listener)
+
+	vS.viewChangeMutex.Unlock()
+}
+
+// viewChanging informs each ViewChangeListener that the contents of the volume are about to change
+// out from under them. It must be called before vS.jobRWMutex is exclusively locked as listeners
+// may await clients flushing what they have cached. The returned func must be called once the
+// change has completed...after vS.jobRWMutex has been unlocked.
+func (vS *volumeStruct) viewChanging() (viewChanged func()) {
+	var (
+		listener         ViewChangeListener
+		listeners        []ViewChangeListener
+		viewChangedFuncs []func()
+	)
+
+	vS.viewChangeMutex.Lock()
+	listeners = make([]ViewChangeListener, 0, len(vS.viewChangeListeners))
+	for listener = range vS.viewChangeListeners {
+		listeners = append(listeners, listener)
+	}
+	vS.viewChangeMutex.Unlock()
+
+	viewChangedFuncs = make([]func(), 0, len(listeners))
+
+	for _, listener = range listeners {
+		viewChangedFuncs = append(viewChangedFuncs, listener.ViewChanging())
+	}
+
+	viewChanged = func() {
+		var (
+			viewChangedFunc func()
+		)
+
+		for _, viewChangedFunc = range viewChangedFuncs {
+			viewChangedFunc()
+		}
+	}
+
+	return
+}
+
+// SnapShotRevert reverts the entire live view to the SnapShot identified by snapShotID. Only the most
+// recent SnapShot may be reverted to. As this would discard (or alter) any Inode whose RetainUntil
+// time has yet to pass, the revert is refused with NotPermError while any such Inode remains in the
+// live view. All other activity on the volume is blocked meanwhile.
+func (vS *volumeStruct) SnapShotRevert(snapShotID uint64) (err error) {
+	startTime := time.Now()
+	defer func() {
+		globals.SnapShotRevertUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.SnapShotRevertErrors.Add(1)
+		}
+	}()
+
+	defer vS.viewChanging()()
+
+	vS.jobRWMutex.Lock()
+	defer vS.jobRWMutex.Unlock()
+
+	vS.untrackInFlightFileInodeDataAll()
+
+	err = vS.wormCheckRevert()
+	if nil != err {
+		return
+	}
+
+	err = vS.inodeVolumeHandle.SnapShotRevert(snapShotID)
+
+	return
+}
+
+// RetainedCheckpointInspect presents (read-only) the retained checkpoint identified by id in place of
+// the live view. An id of zero resumes presenting the live view. All other activity on the volume is
+// blocked meanwhile.
+func (vS *volumeStruct) RetainedCheckpointInspect(id uint64) (err error) {
+	startTime := time.Now()
+	defer func() {
+		globals.RetainedCheckpointInspectUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.RetainedCheckpointInspectErrors.Add(1)
+		}
+	}()
+
+	defer vS.viewChanging()()
+
+	vS.jobRWMutex.Lock()
+	defer vS.jobRWMutex.Unlock()
+
+	vS.untrackInFlightFileInodeDataAll()
+
+	err = vS.inodeVolumeHandle.RetainedCheckpointInspect(id)
+
+	return
+}
+
+// RetainedCheckpointRevert makes the retained checkpoint identified by id the new live view. No
+// SnapShots may exist. As with SnapShotRevert(), the revert is refused with NotPermError while
+// any Inode in the live view is Retained. All other activity on the volume is blocked meanwhile.
+func (vS *volumeStruct) RetainedCheckpointRevert(id uint64) (err error) {
+	startTime := time.Now()
+	defer func() {
+		globals.RetainedCheckpointRevertUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.RetainedCheckpointRevertErrors.Add(1)
+		}
+	}()
+
+	defer vS.viewChanging()()
+
+	vS.jobRWMutex.Lock()
+	defer vS.jobRWMutex.Unlock()
+
+	vS.untrackInFlightFileInodeDataAll()
+
+	err = vS.wormCheckRevert()
+	if nil != err {
+		return
+	}
+
+	err = vS.inodeVolumeHandle.RetainedCheckpointRevert(id)
+
+	return
+}
+
 func (vS *volumeStruct) StatVfs() (statVFS StatVFS, err error) {
 	startTime := time.Now()
 	defer func() {
@@ -4084,6 +5045,11 @@ func (vS *volumeStruct) StatVfs() (statVFS StatVFS, err error) {
 	statVFS[StatVFSMountFlags] = 0
 	statVFS[StatVFSMaxFilenameLen] = FileNameMax
 
+	dedupStats := vS.inodeVolumeHandle.FetchDedupStats()
+
+	statVFS[StatVFSChunkedBytes] = dedupStats.ChunkedBytes
+	statVFS[StatVFSDedupedBytes] = dedupStats.DedupedBytes
+
 	return statVFS, nil
 }
 
@@ -4099,6 +5065,9 @@ func (vS *volumeStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGro
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
 	err = validateBaseName(basename)
 	if err != nil {
 		return
@@ -4146,7 +5115,10 @@ func (vS *volumeStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGro
 		return
 	}
 
-	err = vS.inodeVolumeHandle.Link(inodeNumber, basename, symlinkInodeNumber, false)
+	err = vS.wormCheck(inodeNumber, wormOpAddEntry)
+	if err == nil {
+		err = vS.inodeVolumeHandle.Link(inodeNumber, basename, symlinkInodeNumber, false)
+	}
 	if err != nil {
 		destroyErr := vS.inodeVolumeHandle.Destroy(symlinkInodeNumber)
 		if destroyErr != nil {
@@ -4155,6 +5127,8 @@ func (vS *volumeStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGro
 		return
 	}
 
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventCreate, inodeNumber: symlinkInodeNumber, dirInodeNumber: inodeNumber, basename: basename})
+
 	return
 }
 
@@ -4170,6 +5144,11 @@ func (vS *volumeStruct) Unlink(userID inode.InodeUserID, groupID inode.InodeGrou
 	vS.jobRWMutex.RLock()
 	defer vS.jobRWMutex.RUnlock()
 
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
+	recycleBinPath := vS.recycleBinEntryPath(inodeNumber, basename)
+
 	callerID := dlm.GenerateCallerID()
 	inodeLock, err := vS.inodeVolumeHandle.InitInodeLock(inodeNumber, callerID)
 	if err != nil {
@@ -4207,11 +5186,14 @@ func (vS *volumeStruct) Unlink(userID inode.InodeUserID, groupID inode.InodeGrou
 	}
 	defer basenameInodeLock.Unlock()
 
-	err = vS.unlinkActual(inodeNumber, basename, basenameInodeNumber)
+	err = vS.unlinkActual(inodeNumber, basename, basenameInodeNumber, recycleBinPath)
+	if nil == err {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventUnlink, inodeNumber: basenameInodeNumber, dirInodeNumber: inodeNumber, basename: basename})
+	}
 	return
 }
 
-func (vS *volumeStruct) unlinkActual(inodeNumber inode.InodeNumber, basename string, basenameInodeNumber inode.InodeNumber) (err error) {
+func (vS *volumeStruct) unlinkActual(inodeNumber inode.InodeNumber, basename string, basenameInodeNumber inode.InodeNumber, recycleBinPath string) (err error) {
 	var (
 		basenameInodeType    inode.InodeType
 		toDestroyInodeNumber inode.InodeNumber
@@ -4228,12 +5210,17 @@ func (vS *volumeStruct) unlinkActual(inodeNumber inode.InodeNumber, basename str
 		return
 	}
 
+	err = vS.wormCheckUnlink(inodeNumber, basenameInodeNumber)
+	if nil != err {
+		return
+	}
+
 	toDestroyInodeNumber, err = vS.inodeVolumeHandle.Unlink(inodeNumber, basename, false)
 	if nil != err {
 		return
 	}
 
-	if inode.InodeNumber(0) != toDestroyInodeNumber {
+	if (inode.InodeNumber(0) != toDestroyInodeNumber) && !vS.recycle(toDestroyInodeNumber, recycleBinPath) {
 		vS.untrackInFlightFileInodeData(basenameInodeNumber, false)
 		err = vS.inodeVolumeHandle.Destroy(toDestroyInodeNumber)
 	}
@@ -4285,6 +5272,10 @@ func (vS *volumeStruct) Write(userID inode.InodeUserID, groupID inode.InodeGroup
 		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
 		return
 	}
+	err = vS.wormCheckWrite(inodeNumber, offset)
+	if err != nil {
+		return
+	}
 
 	profiler.AddEventNow("before inode.Write()")
 	err = vS.inodeVolumeHandle.Write(inodeNumber, offset, buf, profiler)
@@ -4296,6 +5287,7 @@ func (vS *volumeStruct) Write(userID inode.InodeUserID, groupID inode.InodeGroup
 
 	logger.Tracef("fs.Write(): tracking write volume '%s' inode %v", vS.volumeName, inodeNumber)
 	vS.trackInFlightFileInodeData(inodeNumber)
+	vS.noteWrite(inodeNumber)
 	size = uint64(len(buf))
 
 	return
@@ -4325,6 +5317,10 @@ func (vS *volumeStruct) Wrote(userID inode.InodeUserID, groupID inode.InodeGroup
 		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
 		return
 	}
+	err = vS.wormCheckWrite(inodeNumber, wroteMinFileOffset(fileOffset))
+	if err != nil {
+		return
+	}
 
 	err = vS.inodeVolumeHandle.Flush(inodeNumber, false)
 	vS.untrackInFlightFileInodeData(inodeNumber, false)
@@ -4332,6 +5328,9 @@ func (vS *volumeStruct) Wrote(userID inode.InodeUserID, groupID inode.InodeGroup
 	inodeWroteTime := time.Unix(0, int64(wroteTime))
 
 	err = vS.inodeVolumeHandle.Wrote(inodeNumber, containerName, objectName, fileOffset, objectOffset, length, inodeWroteTime, true)
+	if nil == err {
+		vS.noteWrite(inodeNumber)
+	}
 
 	return // err, as set by inode.Wrote(), is sufficient
 }
@@ -4438,10 +5437,49 @@ func (vS *volumeStruct) removeObstacleToObjectPut(callerID dlm.CallerID, dirInod
 	return
 }
 
+// inheritStreams copies each of inheritedStreamNames (and, if newInodeType is a DirType, each of
+// dirInheritedStreamNames) present on dirInodeNumber to newInodeNumber (which is about to be linked
+// into dirInodeNumber).
+func (vS *volumeStruct) inheritStreams(dirInodeNumber inode.InodeNumber, newInodeNumber inode.InodeNumber, newInodeType inode.InodeType) (err error) {
+	var (
+		streamName  string
+		streamNames []string
+		streamValue []byte
+	)
+
+	streamNames = make([]string, 0, len(inheritedStreamNames)+len(dirInheritedStreamNames))
+	streamNames = append(streamNames, inheritedStreamNames...)
+	if inode.DirType == newInodeType {
+		streamNames = append(streamNames, dirInheritedStreamNames...)
+	}
+
+	for _, streamName = range streamNames {
+		streamValue, err = vS.inodeVolumeHandle.GetStream(dirInodeNumber, streamName)
+		if nil != err {
+			if blunder.Is(err, blunder.StreamNotFound) {
+				continue
+			}
+			return
+		}
+
+		err = vS.inodeVolumeHandle.PutStream(newInodeNumber, streamName, streamValue)
+		if nil != err {
+			if blunder.Is(err, blunder.InvalidArgError) {
+				// No longer valid (e.g. a PhysicalContainerLayout since removed from the volume)
+				continue
+			}
+			return
+		}
+	}
+
+	err = nil
+	return
+}
+
 // Utility function to append entries to reply
 func appendReadPlanEntries(readPlan []inode.ReadPlanStep, readRangeOut *[]inode.ReadPlanStep) (numEntries uint64) {
 	for i := range readPlan {
-		entry := inode.ReadPlanStep{ObjectPath: readPlan[i].ObjectPath, Offset: readPlan[i].Offset, Length: readPlan[i].Length}
+		entry := inode.ReadPlanStep{ObjectPath: readPlan[i].ObjectPath, Offset: readPlan[i].Offset, Length: readPlan[i].Length, Data: readPlan[i].Data, CompressedLength: readPlan[i].CompressedLength, FrameOffset: readPlan[i].FrameOffset}
 		*readRangeOut = append(*readRangeOut, entry)
 		numEntries++
 	}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/NVIDIA/proxyfs/blunder"
 	"github.com/NVIDIA/proxyfs/inode"
+	"github.com/NVIDIA/proxyfs/transitions"
 )
 
 // TODO: Enhance this to do a stat() as well and check number of files
@@ -372,6 +373,351 @@ func TestAllAPIPositiveCases(t *testing.T) {
 	testTeardown(t)
 }
 
+func TestSnapShotDiff(t *testing.T) {
+	var (
+		diffEntries    []SnapShotDiffEntry
+		diffEntry      SnapShotDiffEntry
+		diffEntryCount map[inode.SnapShotDiffType]int
+		diffEntryMap   map[inode.InodeNumber]SnapShotDiffEntry
+		err            error
+		fileAInode     inode.InodeNumber
+		fileBInode     inode.InodeNumber
+		fileCInode     inode.InodeNumber
+		fileDInode     inode.InodeNumber
+		lastInode      inode.InodeNumber
+		moreEntries    bool
+		ok             bool
+		snapShotID     uint64
+		testDirInode   inode.InodeNumber
+		totalEntries   int
+	)
+
+	testSetup(t, false)
+
+	testDirInode = createTestDirectory(t, "SnapShotDiff")
+
+	fileAInode, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "A", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(,,,,\"A\",) failed: %v", err)
+	}
+	fileBInode, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "B", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(,,,,\"B\",) failed: %v", err)
+	}
+	fileCInode, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "C", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(,,,,\"C\",) failed: %v", err)
+	}
+
+	snapShotID, err = testVolumeStruct.inodeVolumeHandle.SnapShotCreate("SnapShotDiff")
+	if nil != err {
+		t.Fatalf("SnapShotCreate() failed: %v", err)
+	}
+
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileAInode, 0, []byte{0x00, 0x01, 0x02, 0x03}, nil)
+	if nil != err {
+		t.Fatalf("Write() to \"A\" failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileAInode)
+	if nil != err {
+		t.Fatalf("Flush() of \"A\" failed: %v", err)
+	}
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "B")
+	if nil != err {
+		t.Fatalf("Unlink(,,,,\"B\") failed: %v", err)
+	}
+	fileDInode, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "D", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(,,,,\"D\",) failed: %v", err)
+	}
+
+	// Fetch the diff one entry at a time to exercise continuation
+
+	diffEntryMap = make(map[inode.InodeNumber]SnapShotDiffEntry)
+	lastInode = 0
+	moreEntries = true
+
+	for moreEntries {
+		diffEntries, moreEntries, err = testVolumeStruct.SnapShotDiff(snapShotID, 0, lastInode, 1)
+		if nil != err {
+			t.Fatalf("SnapShotDiff() failed: %v", err)
+		}
+		if 1 < len(diffEntries) {
+			t.Fatalf("SnapShotDiff() returned %v entries (maxEntries == 1)", len(diffEntries))
+		}
+		for _, diffEntry = range diffEntries {
+			if diffEntry.InodeNumber <= lastInode {
+				t.Fatalf("SnapShotDiff() returned out of order InodeNumber")
+			}
+			diffEntryMap[diffEntry.InodeNumber] = diffEntry
+			lastInode = diffEntry.InodeNumber
+		}
+		totalEntries += len(diffEntries)
+
+		// Each continuation should be served without walking the namespace again...even if another
+		// enumeration is interleaved
+
+		_, _, err = testVolumeStruct.SnapShotDiff(0, snapShotID, 0, 1)
+		if nil != err {
+			t.Fatalf("SnapShotDiff() [interleaved] failed: %v", err)
+		}
+
+		_, ok = testVolumeStruct.snapShotDiffCursorMap[snapShotDiffCursorKeyStruct{fromSnapShotID: snapShotID, toSnapShotID: 0, lastInodeNumber: lastInode}]
+		if moreEntries != ok {
+			t.Fatalf("SnapShotDiff() should have retained a cursor continuing from the last returned InodeNumber (only) while moreEntries")
+		}
+	}
+
+	for _, cursor := range testVolumeStruct.snapShotDiffCursorMap {
+		if (snapShotID == cursor.key.fromSnapShotID) && (0 == cursor.key.toSnapShotID) {
+			t.Fatalf("SnapShotDiff() should not have retained a cursor once the enumeration completed")
+		}
+	}
+
+	diffEntry, ok = diffEntryMap[fileAInode]
+	if !ok || (inode.SnapShotDiffModified != diffEntry.DiffType) || (inode.FileType != diffEntry.Type) {
+		t.Fatalf("SnapShotDiff() should have reported \"A\" as a Modified FileType")
+	}
+	if (1 != len(diffEntry.Paths)) || ("/SnapShotDiff/A" != diffEntry.Paths[0]) {
+		t.Fatalf("SnapShotDiff() returned unexpected Paths for \"A\": %v", diffEntry.Paths)
+	}
+	diffEntry, ok = diffEntryMap[fileBInode]
+	if !ok || (inode.SnapShotDiffDeleted != diffEntry.DiffType) {
+		t.Fatalf("SnapShotDiff() should have reported \"B\" as Deleted")
+	}
+	if (1 != len(diffEntry.Paths)) || ("/SnapShotDiff/B" != diffEntry.Paths[0]) {
+		t.Fatalf("SnapShotDiff() returned unexpected Paths for \"B\": %v", diffEntry.Paths)
+	}
+	_, ok = diffEntryMap[fileCInode]
+	if ok {
+		t.Fatalf("SnapShotDiff() should not have reported \"C\"")
+	}
+	diffEntry, ok = diffEntryMap[fileDInode]
+	if !ok || (inode.SnapShotDiffCreated != diffEntry.DiffType) {
+		t.Fatalf("SnapShotDiff() should have reported \"D\" as Created")
+	}
+	if (1 != len(diffEntry.Paths)) || ("/SnapShotDiff/D" != diffEntry.Paths[0]) {
+		t.Fatalf("SnapShotDiff() returned unexpected Paths for \"D\": %v", diffEntry.Paths)
+	}
+	diffEntry, ok = diffEntryMap[testDirInode]
+	if !ok || (inode.SnapShotDiffModified != diffEntry.DiffType) || (inode.DirType != diffEntry.Type) {
+		t.Fatalf("SnapShotDiff() should have reported \"SnapShotDiff\" as a Modified DirType")
+	}
+
+	// A single unbounded fetch should return the same set
+
+	diffEntries, moreEntries, err = testVolumeStruct.SnapShotDiff(snapShotID, 0, 0, 0)
+	if nil != err {
+		t.Fatalf("SnapShotDiff() [unbounded] failed: %v", err)
+	}
+	if moreEntries || (totalEntries != len(diffEntries)) {
+		t.Fatalf("SnapShotDiff() [unbounded] returned %v entries (moreEntries == %v)... expected %v", len(diffEntries), moreEntries, totalEntries)
+	}
+
+	diffEntryCount = make(map[inode.SnapShotDiffType]int)
+	for _, diffEntry = range diffEntries {
+		diffEntryCount[diffEntry.DiffType]++
+	}
+	if 1 != diffEntryCount[inode.SnapShotDiffDeleted] {
+		t.Fatalf("SnapShotDiff() [unbounded] returned %v Deleted entries... expected 1", diffEntryCount[inode.SnapShotDiffDeleted])
+	}
+
+	_, _, err = testVolumeStruct.SnapShotDiff(snapShotID+1, 0, 0, 0)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("SnapShotDiff() of non-existent SnapShotID should have failed with NotFoundError")
+	}
+
+	err = testVolumeStruct.inodeVolumeHandle.SnapShotDelete(snapShotID)
+	if nil != err {
+		t.Fatalf("SnapShotDelete() failed: %v", err)
+	}
+
+	testTeardown(t)
+}
+
+type testViewChangeListenerStruct struct {
+	viewChangingCount uint64
+	viewChangedCount  uint64
+}
+
+func (listener *testViewChangeListenerStruct) ViewChanging() (viewChanged func()) {
+	listener.viewChangingCount++
+	viewChanged = func() {
+		listener.viewChangedCount++
+	}
+	return
+}
+
+func TestSnapShotRestoreAndRevert(t *testing.T) {
+	var (
+		buf          []byte
+		err          error
+		fileAInode   inode.InodeNumber
+		fileBInode   inode.InodeNumber
+		inodeNumber  inode.InodeNumber
+		listener     *testViewChangeListenerStruct
+		snapShotID   uint64
+		snapShotID2  uint64
+		testDirInode inode.InodeNumber
+	)
+
+	testSetup(t, false)
+
+	listener = &testViewChangeListenerStruct{}
+	testVolumeStruct.RegisterForViewChanges(listener)
+
+	testDirInode = createTestDirectory(t, "SnapShotRestore")
+
+	fileAInode, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "A", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(,,,,\"A\",) failed: %v", err)
+	}
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileAInode, 0, []byte("abcd"), nil)
+	if nil != err {
+		t.Fatalf("Write() to \"A\" failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileAInode)
+	if nil != err {
+		t.Fatalf("Flush() of \"A\" failed: %v", err)
+	}
+	fileBInode, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "B", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(,,,,\"B\",) failed: %v", err)
+	}
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileBInode, 0, []byte("efgh"), nil)
+	if nil != err {
+		t.Fatalf("Write() to \"B\" failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileBInode)
+	if nil != err {
+		t.Fatalf("Flush() of \"B\" failed: %v", err)
+	}
+
+	snapShotID, err = testVolumeStruct.inodeVolumeHandle.SnapShotCreate("SnapShotRestore")
+	if nil != err {
+		t.Fatalf("SnapShotCreate() failed: %v", err)
+	}
+
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileAInode, 0, []byte("WXYZ"), nil)
+	if nil != err {
+		t.Fatalf("Write() to \"A\" failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileAInode)
+	if nil != err {
+		t.Fatalf("Flush() of \"A\" failed: %v", err)
+	}
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "B")
+	if nil != err {
+		t.Fatalf("Unlink(,,,,\"B\") failed: %v", err)
+	}
+	_, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "C", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(,,,,\"C\",) failed: %v", err)
+	}
+
+	// Restore a single (deleted) file... which should retain its InodeNumber
+
+	err = testVolumeStruct.SnapShotRestore(snapShotID, "/SnapShotRestore/B")
+	if nil != err {
+		t.Fatalf("SnapShotRestore(,\"/SnapShotRestore/B\") failed: %v", err)
+	}
+	inodeNumber, err = testVolumeStruct.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "B")
+	if nil != err {
+		t.Fatalf("Lookup(,,,,\"B\") after SnapShotRestore() failed: %v", err)
+	}
+	if fileBInode != inodeNumber {
+		t.Fatalf("SnapShotRestore() of \"B\" should have retained its InodeNumber")
+	}
+	buf, err = testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileBInode, 0, 4, nil)
+	if nil != err {
+		t.Fatalf("Read() from \"B\" failed: %v", err)
+	}
+	if "efgh" != string(buf) {
+		t.Fatalf("Read() from restored \"B\" returned %v", string(buf))
+	}
+
+	// Restore the directory... which should retain live entries not in the SnapShot
+
+	err = testVolumeStruct.SnapShotRestore(snapShotID, "/SnapShotRestore")
+	if nil != err {
+		t.Fatalf("SnapShotRestore(,\"/SnapShotRestore\") failed: %v", err)
+	}
+	buf, err = testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileAInode, 0, 4, nil)
+	if nil != err {
+		t.Fatalf("Read() from \"A\" failed: %v", err)
+	}
+	if "abcd" != string(buf) {
+		t.Fatalf("Read() from restored \"A\" returned %v", string(buf))
+	}
+	_, err = testVolumeStruct.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "C")
+	if nil != err {
+		t.Fatalf("Lookup(,,,,\"C\") after SnapShotRestore() failed: %v", err)
+	}
+
+	// Now diverge once again and revert the entire volume
+
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileAInode, 0, []byte("WXYZ"), nil)
+	if nil != err {
+		t.Fatalf("Write() to \"A\" failed: %v", err)
+	}
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "B")
+	if nil != err {
+		t.Fatalf("Unlink(,,,,\"B\") failed: %v", err)
+	}
+
+	snapShotID2, err = testVolumeStruct.inodeVolumeHandle.SnapShotCreate("SnapShotRevert")
+	if nil != err {
+		t.Fatalf("SnapShotCreate() failed: %v", err)
+	}
+	err = testVolumeStruct.SnapShotRevert(snapShotID)
+	if nil == err {
+		t.Fatalf("SnapShotRevert() to other than the most recent SnapShot should have failed")
+	}
+	err = testVolumeStruct.inodeVolumeHandle.SnapShotDelete(snapShotID2)
+	if nil != err {
+		t.Fatalf("SnapShotDelete() failed: %v", err)
+	}
+
+	err = testVolumeStruct.SnapShotRevert(snapShotID)
+	if nil != err {
+		t.Fatalf("SnapShotRevert() failed: %v", err)
+	}
+
+	buf, err = testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileAInode, 0, 4, nil)
+	if nil != err {
+		t.Fatalf("Read() from \"A\" failed: %v", err)
+	}
+	if "abcd" != string(buf) {
+		t.Fatalf("Read() from reverted \"A\" returned %v", string(buf))
+	}
+	buf, err = testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileBInode, 0, 4, nil)
+	if nil != err {
+		t.Fatalf("Read() from \"B\" failed: %v", err)
+	}
+	if "efgh" != string(buf) {
+		t.Fatalf("Read() from reverted \"B\" returned %v", string(buf))
+	}
+	_, err = testVolumeStruct.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "C")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup(,,,,\"C\") after SnapShotRevert() should have failed with NotFoundError")
+	}
+
+	err = testVolumeStruct.inodeVolumeHandle.SnapShotDelete(snapShotID)
+	if nil != err {
+		t.Fatalf("SnapShotDelete() failed: %v", err)
+	}
+
+	// Each SnapShotRestore() and SnapShotRevert() (even the failed one) should have notified listener
+
+	testVolumeStruct.UnregisterForViewChanges(listener)
+
+	if (4 != listener.viewChangingCount) || (4 != listener.viewChangedCount) {
+		t.Fatalf("ViewChangeListener notified %v/%v times...expected 4/4", listener.viewChangingCount, listener.viewChangedCount)
+	}
+
+	testTeardown(t)
+}
+
 // TODO: flesh this out with other boundary condition testing for Link
 func TestBadLinks(t *testing.T) {
 	testSetup(t, false)
@@ -448,6 +794,58 @@ func TestMkdir(t *testing.T) {
 	testTeardown(t)
 }
 
+func TestInheritedStreams(t *testing.T) {
+	testSetup(t, false)
+
+	testDirInode := createTestDirectory(t, "InheritedStreams")
+
+	err := testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, inode.CompressionStreamName, []byte(inode.CompressionStreamValueOn), SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr() returned error: %v", err)
+	}
+	err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, inode.CaseInsensitiveStreamName, []byte(inode.CaseInsensitiveStreamValueOn), SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr() returned error: %v", err)
+	}
+
+	subDirInode, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, "SubDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() returned error: %v", err)
+	}
+
+	fileInode, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subDirInode, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	for _, inodeNumber := range []inode.InodeNumber{subDirInode, fileInode} {
+		value, err := testVolumeStruct.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber, inode.CompressionStreamName)
+		if nil != err {
+			t.Fatalf("GetXAttr() of inode %v returned error: %v", inodeNumber, err)
+		}
+		if inode.CompressionStreamValueOn != string(value) {
+			t.Fatalf("GetXAttr() of inode %v returned %q", inodeNumber, value)
+		}
+	}
+
+	// CaseInsensitiveStreamName only applies to (and is thus only inherited by) DirInodes
+
+	value, err := testVolumeStruct.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subDirInode, inode.CaseInsensitiveStreamName)
+	if nil != err {
+		t.Fatalf("GetXAttr() of subDirInode returned error: %v", err)
+	}
+	if inode.CaseInsensitiveStreamValueOn != string(value) {
+		t.Fatalf("GetXAttr() of subDirInode returned %q", value)
+	}
+
+	_, err = testVolumeStruct.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInode, inode.CaseInsensitiveStreamName)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("GetXAttr() of fileInode should have failed with StreamNotFound - got: %v", err)
+	}
+
+	testTeardown(t)
+}
+
 func TestRmdir(t *testing.T) {
 	testSetup(t, false)
 	defer testTeardown(t)
@@ -498,7 +896,7 @@ func TestBadRename(t *testing.T) {
 	}
 
 	// Try to rename a valid file to a name that is too long
-	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, validFile, testDirInode, nameTooLong)
+	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, validFile, testDirInode, nameTooLong, inode.MoveFlagsNone)
 	if nil != err {
 		if blunder.IsNot(err, blunder.NameTooLongError) {
 			t.Fatalf("Link() returned error %v, expected %v(%d).", blunder.Errno(err), blunder.NameTooLongError, blunder.NameTooLongError.Value())
@@ -511,7 +909,7 @@ func TestBadRename(t *testing.T) {
 	expectDirectory(t, inode.InodeRootUserID, inode.InodeGroupID(0), testDirInode, entriesExpected)
 
 	// Try to rename a nonexistent file with a name that is too long
-	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, nameTooLong, testDirInode, "AlsoAGoodFilename")
+	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, testDirInode, nameTooLong, testDirInode, "AlsoAGoodFilename", inode.MoveFlagsNone)
 	if nil != err {
 		if blunder.IsNot(err, blunder.NameTooLongError) {
 			t.Fatalf("Link() returned error %v, expected %v(%d).", blunder.Errno(err), blunder.NameTooLongError, blunder.NameTooLongError.Value())
@@ -1054,7 +1452,7 @@ func TestStaleInodes(t *testing.T) {
 
 	// Rename -- two cases, one with stale src directory and one with stale dest
 	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil,
-		testDirInodeNumber, "fubar", staleDirInodeNumber, "barfu")
+		testDirInodeNumber, "fubar", staleDirInodeNumber, "barfu", inode.MoveFlagsNone)
 	if nil == err {
 		t.Fatalf("Rename(1) should not have returned success")
 	}
@@ -1063,7 +1461,7 @@ func TestStaleInodes(t *testing.T) {
 	}
 
 	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil,
-		staleDirInodeNumber, "fubar", testDirInodeNumber, "barfu")
+		staleDirInodeNumber, "fubar", testDirInodeNumber, "barfu", inode.MoveFlagsNone)
 	if nil == err {
 		t.Fatalf("Rename(2) should not have returned success")
 	}
@@ -1103,7 +1501,7 @@ func TestMiddlewareGetContainer(t *testing.T) {
 		t.Fatalf("Create() returned error: %v", err)
 	}
 
-	ents, err = testVolumeStruct.MiddlewareGetContainer("container", 10, "a", "", "", "")
+	ents, err = testVolumeStruct.MiddlewareGetContainer("container", 10, "a", "", "", "", false)
 	if nil != err {
 		t.Fatalf("got some error: %v", err)
 	}
@@ -1111,7 +1509,7 @@ func TestMiddlewareGetContainer(t *testing.T) {
 		t.Fatalf("marker a gave wrong number of entries: %v", ents)
 	}
 
-	ents, err = testVolumeStruct.MiddlewareGetContainer("container", 10, "b", "", "", "")
+	ents, err = testVolumeStruct.MiddlewareGetContainer("container", 10, "b", "", "", "", false)
 	if nil != err {
 		t.Fatalf("got some error: %v", err)
 	}
@@ -1119,7 +1517,7 @@ func TestMiddlewareGetContainer(t *testing.T) {
 		t.Fatalf("marker b gave wrong number of entries: %v", ents)
 	}
 
-	ents, err = testVolumeStruct.MiddlewareGetContainer("container", 10, "a_marker", "", "", "")
+	ents, err = testVolumeStruct.MiddlewareGetContainer("container", 10, "a_marker", "", "", "", false)
 	if nil != err {
 		t.Fatalf("got some error: %v", err)
 	}
@@ -1149,7 +1547,7 @@ func verifyMetadata(t *testing.T, containerObjPath string,
 	)
 
 	// fetch the current metadata (implicit and explicit)
-	headMeta, err = testVolumeStruct.MiddlewareHeadResponse(containerObjPath)
+	headMeta, err = testVolumeStruct.MiddlewareHeadResponse(containerObjPath, 0)
 	if err != nil {
 		t.Errorf("MiddlewareHeadResponse() for '%s' op %s '%s' failed: %v",
 			containerObjPath, opName, stepName, err)
@@ -1240,7 +1638,7 @@ func TestMiddlewarePuts(t *testing.T) {
 	if err != nil {
 		t.Fatalf("MiddlewarePutContainer() failed: %v", err)
 	}
-	opMeta, err = testVolumeStruct.MiddlewareHeadResponse(containerName)
+	opMeta, err = testVolumeStruct.MiddlewareHeadResponse(containerName, 0)
 	if err != nil {
 		t.Fatalf("MiddlewareHeadResponse() for container '%s' failed: %v", containerName, err)
 	}
@@ -1282,7 +1680,7 @@ func TestMiddlewarePuts(t *testing.T) {
 	// verify the metadata (explicit and implicit) returned by
 	// MiddlewareGetObject() matches MiddlewareHeadResponse() for a
 	// directory
-	opMeta, err = testVolumeStruct.MiddlewareGetObject(containerObjectPath,
+	opMeta, err = testVolumeStruct.MiddlewareGetObject(containerObjectPath, 0,
 		[]ReadRangeIn{}, &[]inode.ReadPlanStep{})
 	if err != nil {
 		t.Errorf("MiddlewareGetObject() for object '%s' failed: %v", containerObjectPath, err)
@@ -1307,7 +1705,7 @@ func TestMiddlewarePuts(t *testing.T) {
 
 	// verify the metadata (explicit and implicit) returned by
 	// MiddlewareGetObject() matches MiddlewareHeadResponse()
-	opMeta, err = testVolumeStruct.MiddlewareGetObject(containerObjectPath,
+	opMeta, err = testVolumeStruct.MiddlewareGetObject(containerObjectPath, 0,
 		[]ReadRangeIn{}, &[]inode.ReadPlanStep{})
 	if err != nil {
 		t.Errorf("MiddlewareGetObject() for object '%s' failed: %v", containerObjectPath, err)
@@ -1356,3 +1754,62 @@ func TestMiddlewarePuts(t *testing.T) {
 	// verify that the file (child of the directory) is unchanged
 	verifyMetadata(t, containerObjectPath, "step 5", "verify", &file0Meta)
 }
+
+func TestMiddlewareGetObjectInlineData(t *testing.T) {
+	testSetup(t, false)
+
+	// Remount TestVolume inlining small files
+
+	err := transitions.Down(testConfMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() failed: %v", err)
+	}
+	err = testConfMap.UpdateFromString("Volume:TestVolume.MaxInlineFileSize=64")
+	if nil != err {
+		t.Fatalf("testConfMap.UpdateFromString() failed: %v", err)
+	}
+	err = transitions.Up(testConfMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() failed: %v", err)
+	}
+	testVolumeHandle, err := FetchVolumeHandleByVolumeName("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandleByVolumeName() failed: %v", err)
+	}
+	testVolumeStruct = testVolumeHandle.(*volumeStruct)
+
+	fileInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Inline", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	fileData := []byte("inlined")
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, fileData, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	// The inlined data should be returned in the read plan itself (and remain inlined)
+
+	readRangeOut := []inode.ReadPlanStep{}
+	_, err = testVolumeStruct.MiddlewareGetObject("Inline", 0, []ReadRangeIn{}, &readRangeOut)
+	if nil != err {
+		t.Fatalf("MiddlewareGetObject() failed: %v", err)
+	}
+	if (1 != len(readRangeOut)) || ("" != readRangeOut[0].ObjectPath) || !bytes.Equal(fileData, readRangeOut[0].Data) {
+		t.Fatalf("MiddlewareGetObject() returned unexpected read plan %+v", readRangeOut)
+	}
+
+	extentMapChunk, err := testVolumeStruct.FetchExtentMapChunk(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, 1, 0)
+	if nil != err {
+		t.Fatalf("FetchExtentMapChunk() failed: %v", err)
+	}
+	if (1 != len(extentMapChunk.ExtentMapEntry)) || !bytes.Equal(fileData, extentMapChunk.ExtentMapEntry[0].Data) {
+		t.Fatalf("FetchExtentMapChunk() of inlined data returned unexpected extentMapChunk %+v", extentMapChunk)
+	}
+
+	testTeardown(t)
+}
@@ -0,0 +1,1122 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/dlm"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+// An ExportVolume job walks the directory tree of a volume (or of one of its SnapShots) depth-first
+// writing each Inode to a PAX format tar stream. The stream begins with a global header identifying
+// it as a ProxyFS archive. Each entry then carries:
+//
+//   mode, uid, gid, mtime, atime, and ctime in their standard PAX/USTAR fields
+//   crtime (in nanoseconds) in a PROXYFS.crtime record
+//   each Stream (i.e. xattr) in a SCHILY.xattr.<StreamName> record
+//   for a FileInode, the [offset,length) pairs of its non-hole data in a PROXYFS.extents record
+//
+// Holes are written as zeroes so that the stream remains extractable by any tar implementation.
+// The second and subsequent paths to a FileInode or SymlinkInode with a LinkCount > 1 are written
+// as hard links to the first. The /<SnapShotDirName>, /<recycleBinDirName>, /<objectVersionsDirName>,
+// and /<multipartDirName> directories are not exported. As a live volume may change while it is
+// being walked, exporting a SnapShot is the way to obtain a consistent archive.
+//
+// An ImportVolume job recreates the Inodes of such a stream in an empty volume (e.g. one freshly
+// formatted by mkproxyfs). Attributes of DirInodes are applied only once all entries have been
+// created as creating each entry would otherwise update its parent's mtime. Similarly, WORM Streams
+// (see SetWORMState()) are applied last of all...RetainUntil ahead of the Immutable and AppendOnly
+// flags...as these would otherwise refuse the subsequent writing of data, Setstat() of attributes,
+// and creation of children. Note that ctime cannot be set (see Setstat()) so will reflect the time
+// of import.
+
+const (
+	archiveVersion           = "1"
+	archiveChunkSize         = uint64(1024 * 1024)
+	archiveReadDirMaxEntries = uint64(1024)
+
+	archivePAXRecordVersion      = "PROXYFS.archive.version"
+	archivePAXRecordVolumeName   = "PROXYFS.archive.volume"
+	archivePAXRecordSnapShotName = "PROXYFS.archive.snapshot"
+	archivePAXRecordCreationTime = "PROXYFS.crtime"
+	archivePAXRecordExtents      = "PROXYFS.extents"
+	archivePAXRecordStreamPrefix = "SCHILY.xattr."
+)
+
+type archiveExtentStruct struct {
+	offset uint64
+	length uint64
+}
+
+type exportVolumeStruct struct {
+	jobStruct
+	snapShotName      string
+	archive           io.Writer
+	tarWriter         *tar.Writer
+	startInodeNumber  inode.InodeNumber
+	firstPathMap      map[inode.InodeNumber]string // first path at which a multiply linked Inode was exported
+	zeroBuf           []byte
+	inodesExported    uint64
+	dataBytesExported uint64
+}
+
+type importVolumeDirStruct struct {
+	inodeNumber inode.InodeNumber
+	stat        Stat
+}
+
+type importVolumeWORMStruct struct {
+	inodeNumber inode.InodeNumber
+	streams     map[string]string // key is StreamName of each WORM Stream to apply
+}
+
+type importVolumeStruct struct {
+	jobStruct
+	archive           io.Reader
+	tarReader         *tar.Reader
+	dirInodeNumberMap map[string]inode.InodeNumber // key is the cleaned, absolute path of each DirInode imported
+	dirList           []importVolumeDirStruct      // DirInode attributes applied once all entries are created
+	wormList          []importVolumeWORMStruct     // WORM Streams applied once all attributes are applied
+	inodesImported    uint64
+	dataBytesImported uint64
+}
+
+// archiveFormatExtents encodes extents in the form "offset,length[,offset,length]*".
+func archiveFormatExtents(extents []archiveExtentStruct) (extentsAsString string) {
+	var (
+		extent         archiveExtentStruct
+		extentsAsSlice []string
+	)
+
+	extentsAsSlice = make([]string, 0, 2*len(extents))
+
+	for _, extent = range extents {
+		extentsAsSlice = append(extentsAsSlice, strconv.FormatUint(extent.offset, 10), strconv.FormatUint(extent.length, 10))
+	}
+
+	extentsAsString = strings.Join(extentsAsSlice, ",")
+
+	return
+}
+
+// archiveParseExtents decodes extents formatted by archiveFormatExtents() ensuring they are in order,
+// non-overlapping, and lie within [0:size).
+func archiveParseExtents(extentsAsString string, size uint64) (extents []archiveExtentStruct, err error) {
+	var (
+		extentsAsSlice []string
+		extent         archiveExtentStruct
+		index          int
+		nextOffset     uint64
+	)
+
+	extents = make([]archiveExtentStruct, 0)
+
+	if "" == extentsAsString {
+		return
+	}
+
+	extentsAsSlice = strings.Split(extentsAsString, ",")
+	if 0 != (len(extentsAsSlice) % 2) {
+		err = fmt.Errorf("%s record must contain an even number of values", archivePAXRecordExtents)
+		return
+	}
+
+	nextOffset = 0
+
+	for index = 0; index < len(extentsAsSlice); index += 2 {
+		extent.offset, err = strconv.ParseUint(extentsAsSlice[index], 10, 64)
+		if nil != err {
+			return
+		}
+		extent.length, err = strconv.ParseUint(extentsAsSlice[index+1], 10, 64)
+		if nil != err {
+			return
+		}
+		if (extent.offset < nextOffset) || (extent.length > size) || (extent.offset > (size - extent.length)) {
+			err = fmt.Errorf("%s record contains an invalid extent (%v,%v)", archivePAXRecordExtents, extent.offset, extent.length)
+			return
+		}
+		extents = append(extents, extent)
+		nextOffset = extent.offset + extent.length
+	}
+
+	return
+}
+
+// exportVolumeFetchMetadata fetches the MetadataStruct of inodeNumber under its read lock.
+func (eVS *exportVolumeStruct) exportVolumeFetchMetadata(inodeNumber inode.InodeNumber) (metadata *inode.MetadataStruct, err error) {
+	var (
+		inodeLock *dlm.RWLockStruct
+	)
+
+	eVS.volume.jobRWMutex.RLock()
+	defer eVS.volume.jobRWMutex.RUnlock()
+
+	inodeLock, err = eVS.inodeVolumeHandle.GetReadLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+
+	metadata, err = eVS.inodeVolumeHandle.GetMetadata(inodeNumber)
+
+	_ = inodeLock.Unlock()
+
+	return
+}
+
+// exportVolumeFetchExtents returns the non-hole extents of fileInodeNumber within [0:size). The
+// ReadPlan of each archiveChunkSize chunk of the file is examined (under a read lock) rather than
+// reading its data.
+func (eVS *exportVolumeStruct) exportVolumeFetchExtents(fileInodeNumber inode.InodeNumber, size uint64) (extents []archiveExtentStruct, err error) {
+	var (
+		chunkOffset   uint64
+		chunkLength   uint64
+		inodeLock     *dlm.RWLockStruct
+		readPlan      []inode.ReadPlanStep
+		readPlanStep  inode.ReadPlanStep
+		stepOffset    uint64
+		lastExtentEnd uint64
+	)
+
+	extents = make([]archiveExtentStruct, 0)
+
+	for chunkOffset = 0; chunkOffset < size; chunkOffset += chunkLength {
+		if eVS.stopFlag {
+			err = fmt.Errorf("job stopped")
+			return
+		}
+
+		chunkLength = size - chunkOffset
+		if chunkLength > archiveChunkSize {
+			chunkLength = archiveChunkSize
+		}
+
+		eVS.volume.jobRWMutex.RLock()
+
+		inodeLock, err = eVS.inodeVolumeHandle.GetReadLock(fileInodeNumber, nil)
+		if nil != err {
+			eVS.volume.jobRWMutex.RUnlock()
+			return
+		}
+
+		readPlan, err = eVS.inodeVolumeHandle.GetReadPlan(fileInodeNumber, &chunkOffset, &chunkLength)
+
+		_ = inodeLock.Unlock()
+
+		eVS.volume.jobRWMutex.RUnlock()
+
+		if nil != err {
+			return
+		}
+
+		stepOffset = chunkOffset
+
+		for _, readPlanStep = range readPlan {
+			if (0 != readPlanStep.LogSegmentNumber) || (nil != readPlanStep.Data) {
+				lastExtentEnd = 0
+				if 0 < len(extents) {
+					lastExtentEnd = extents[len(extents)-1].offset + extents[len(extents)-1].length
+				}
+				if (0 < len(extents)) && (lastExtentEnd == stepOffset) {
+					extents[len(extents)-1].length += readPlanStep.Length
+				} else {
+					extents = append(extents, archiveExtentStruct{offset: stepOffset, length: readPlanStep.Length})
+				}
+			}
+			stepOffset += readPlanStep.Length
+		}
+	}
+
+	return
+}
+
+// exportVolumeWriteZeroes writes length zeroes to the current entry of the archive.
+func (eVS *exportVolumeStruct) exportVolumeWriteZeroes(length uint64) (err error) {
+	var (
+		chunkLength uint64
+	)
+
+	for 0 < length {
+		chunkLength = length
+		if chunkLength > uint64(len(eVS.zeroBuf)) {
+			chunkLength = uint64(len(eVS.zeroBuf))
+		}
+		_, err = eVS.tarWriter.Write(eVS.zeroBuf[:chunkLength])
+		if nil != err {
+			return
+		}
+		length -= chunkLength
+	}
+
+	return
+}
+
+// exportVolumeWriteFileData writes the data of fileInodeNumber to the current entry of the archive.
+// Should the file have been truncated since its extents were fetched, the balance is zero-filled.
+func (eVS *exportVolumeStruct) exportVolumeWriteFileData(fileInodeNumber inode.InodeNumber, size uint64, extents []archiveExtentStruct) (err error) {
+	var (
+		buf          []byte
+		chunkLength  uint64
+		extent       archiveExtentStruct
+		extentOffset uint64
+		fileOffset   uint64
+	)
+
+	fileOffset = 0
+
+	for _, extent = range extents {
+		err = eVS.exportVolumeWriteZeroes(extent.offset - fileOffset)
+		if nil != err {
+			return
+		}
+
+		for extentOffset = 0; extentOffset < extent.length; extentOffset += chunkLength {
+			if eVS.stopFlag {
+				err = fmt.Errorf("job stopped")
+				return
+			}
+
+			chunkLength = extent.length - extentOffset
+			if chunkLength > archiveChunkSize {
+				chunkLength = archiveChunkSize
+			}
+
+			buf, err = eVS.volume.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, extent.offset+extentOffset, chunkLength, nil)
+			if nil != err {
+				return
+			}
+			if uint64(len(buf)) > chunkLength {
+				buf = buf[:chunkLength]
+			}
+
+			_, err = eVS.tarWriter.Write(buf)
+			if nil != err {
+				return
+			}
+
+			err = eVS.exportVolumeWriteZeroes(chunkLength - uint64(len(buf)))
+			if nil != err {
+				return
+			}
+		}
+
+		fileOffset = extent.offset + extent.length
+	}
+
+	err = eVS.exportVolumeWriteZeroes(size - fileOffset)
+	if nil != err {
+		return
+	}
+
+	eVS.dataBytesExported += size
+
+	return
+}
+
+// exportVolumeInode writes the entry for inodeNumber (found at inodePath relative to the starting
+// directory) to the archive, returning whether it was a DirInode to be descended into. If the Inode
+// has since been removed, ok == true is returned without anything having been written. Any other
+// failure (or the job having been cancelled) is fatal to the archive so ok == false is returned.
+func (eVS *exportVolumeStruct) exportVolumeInode(inodeNumber inode.InodeNumber, inodePath string) (isDir bool, ok bool) {
+	var (
+		err         error
+		extents     []archiveExtentStruct
+		firstPath   string
+		header      *tar.Header
+		metadata    *inode.MetadataStruct
+		streamName  string
+		streamValue []byte
+		target      string
+	)
+
+	if eVS.stopFlag {
+		ok = false
+		return
+	}
+
+	metadata, err = eVS.exportVolumeFetchMetadata(inodeNumber)
+	if nil != err {
+		// Most likely removed since the containing directory was read
+		isDir = false
+		ok = true
+		return
+	}
+
+	header = &tar.Header{
+		Mode:       int64(metadata.Mode & 07777),
+		Uid:        int(metadata.UserID),
+		Gid:        int(metadata.GroupID),
+		ModTime:    metadata.ModificationTime,
+		AccessTime: metadata.AccessTime,
+		ChangeTime: metadata.AttrChangeTime,
+		PAXRecords: map[string]string{archivePAXRecordCreationTime: strconv.FormatInt(metadata.CreationTime.UnixNano(), 10)},
+		Format:     tar.FormatPAX,
+	}
+
+	if (inode.DirType != metadata.InodeType) && (1 < metadata.LinkCount) {
+		firstPath, ok = eVS.firstPathMap[inodeNumber]
+		if ok {
+			header.Typeflag = tar.TypeLink
+			header.Name = inodePath
+			header.Linkname = firstPath
+			header.PAXRecords = nil
+
+			err = eVS.tarWriter.WriteHeader(header)
+			if nil != err {
+				eVS.jobLogErr("Got tarWriter.WriteHeader(\"%s\") failure: %v", inodePath, err)
+				ok = false
+				return
+			}
+
+			eVS.inodesExported++
+			ok = true
+			return
+		}
+
+		eVS.firstPathMap[inodeNumber] = inodePath
+	}
+
+	for _, streamName = range metadata.InodeStreamNameSlice {
+		streamValue, err = eVS.volume.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber, streamName)
+		if nil != err {
+			if blunder.Is(err, blunder.StreamNotFound) {
+				continue
+			}
+			eVS.jobLogErr("Got GetXAttr(0x%016X,\"%s\") failure: %v", inodeNumber, streamName, err)
+			ok = false
+			return
+		}
+		header.PAXRecords[archivePAXRecordStreamPrefix+streamName] = string(streamValue)
+	}
+
+	switch metadata.InodeType {
+	case inode.DirType:
+		header.Typeflag = tar.TypeDir
+		if "" == inodePath {
+			header.Name = "./"
+		} else {
+			header.Name = inodePath + "/"
+		}
+	case inode.FileType:
+		extents, err = eVS.exportVolumeFetchExtents(inodeNumber, metadata.Size)
+		if nil != err {
+			eVS.jobLogErr("Got extents of 0x%016X failure: %v", inodeNumber, err)
+			ok = false
+			return
+		}
+		header.Typeflag = tar.TypeReg
+		header.Name = inodePath
+		header.Size = int64(metadata.Size)
+		header.PAXRecords[archivePAXRecordExtents] = archiveFormatExtents(extents)
+	case inode.SymlinkType:
+		target, err = eVS.volume.Readsymlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber)
+		if nil != err {
+			eVS.jobLogErr("Got Readsymlink(0x%016X) failure: %v", inodeNumber, err)
+			ok = false
+			return
+		}
+		header.Typeflag = tar.TypeSymlink
+		header.Name = inodePath
+		header.Linkname = target
+	default:
+		eVS.jobLogErr("Inode 0x%016X at \"%s\" has unexpected InodeType %v", inodeNumber, inodePath, metadata.InodeType)
+		ok = false
+		return
+	}
+
+	err = eVS.tarWriter.WriteHeader(header)
+	if nil != err {
+		eVS.jobLogErr("Got tarWriter.WriteHeader(\"%s\") failure: %v", header.Name, err)
+		ok = false
+		return
+	}
+
+	if inode.FileType == metadata.InodeType {
+		err = eVS.exportVolumeWriteFileData(inodeNumber, metadata.Size, extents)
+		if nil != err {
+			eVS.jobLogErr("Got data of 0x%016X failure: %v", inodeNumber, err)
+			ok = false
+			return
+		}
+	}
+
+	eVS.inodesExported++
+
+	isDir = (inode.DirType == metadata.InodeType)
+	ok = true
+	return
+}
+
+// exportVolumeWalkDir exports each entry of dirInodeNumber (found at dirPath relative to the starting
+// directory), descending into sub-directories as they are encountered.
+func (eVS *exportVolumeStruct) exportVolumeWalkDir(dirInodeNumber inode.InodeNumber, dirPath string) (ok bool) {
+	var (
+		dirEntry      inode.DirEntry
+		dirEntryPath  string
+		dirEntrySlice []inode.DirEntry
+		dirInodeLock  *dlm.RWLockStruct
+		err           error
+		isDir         bool
+		moreEntries   bool
+		prevReturned  string
+	)
+
+	prevReturned = ""
+	moreEntries = true
+
+	for moreEntries {
+		eVS.volume.jobRWMutex.RLock()
+
+		dirInodeLock, err = eVS.inodeVolumeHandle.GetReadLock(dirInodeNumber, nil)
+		if nil != err {
+			eVS.volume.jobRWMutex.RUnlock()
+			eVS.jobLogErr("Got GetReadLock(0x%016X) failure: %v", dirInodeNumber, err)
+			ok = false
+			return
+		}
+
+		if "" == prevReturned {
+			dirEntrySlice, moreEntries, err = eVS.inodeVolumeHandle.ReadDir(dirInodeNumber, archiveReadDirMaxEntries, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = eVS.inodeVolumeHandle.ReadDir(dirInodeNumber, archiveReadDirMaxEntries, 0, prevReturned)
+		}
+
+		_ = dirInodeLock.Unlock()
+
+		eVS.volume.jobRWMutex.RUnlock()
+
+		if nil != err {
+			// Most likely removed since it was found in its parent directory
+			ok = true
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			prevReturned = dirEntry.Basename
+
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+			if (eVS.startInodeNumber == dirInodeNumber) && ((inode.SnapShotDirName == dirEntry.Basename) || (recycleBinDirName == dirEntry.Basename) || (objectVersionsDirName == dirEntry.Basename) || (multipartDirName == dirEntry.Basename)) {
+				continue
+			}
+
+			dirEntryPath = path.Join(dirPath, dirEntry.Basename)
+
+			isDir, ok = eVS.exportVolumeInode(dirEntry.InodeNumber, dirEntryPath)
+			if !ok {
+				return
+			}
+
+			if isDir {
+				ok = eVS.exportVolumeWalkDir(dirEntry.InodeNumber, dirEntryPath)
+				if !ok {
+					return
+				}
+			}
+		}
+	}
+
+	ok = true
+	return
+}
+
+func (eVS *exportVolumeStruct) exportVolume() {
+	var (
+		err       error
+		globalPAX map[string]string
+		ok        bool
+	)
+
+	eVS.jobLogInfo("EXPORT job initiated")
+
+	defer func(eVS *exportVolumeStruct) {
+		if eVS.stopFlag {
+			eVS.jobLogInfo("EXPORT job stopped")
+		} else if 0 == len(eVS.err) {
+			eVS.jobLogInfo("EXPORT job completed without error (%v Inodes, %v data bytes exported)", eVS.inodesExported, eVS.dataBytesExported)
+		} else if 1 == len(eVS.err) {
+			eVS.jobLogInfo("EXPORT job exited with one error")
+		} else {
+			eVS.jobLogInfo("EXPORT job exited with errors")
+		}
+	}(eVS)
+
+	defer func(eVS *exportVolumeStruct) {
+		eVS.active = false
+	}(eVS)
+
+	defer eVS.globalWaitGroup.Done()
+
+	// Find specified volume
+
+	globals.Lock()
+
+	eVS.volume, ok = globals.volumeMap[eVS.volumeName]
+	if !ok {
+		globals.Unlock()
+		eVS.jobLogErr("Couldn't find fs.volumeStruct")
+		return
+	}
+
+	globals.Unlock()
+
+	eVS.inodeVolumeHandle = eVS.volume.inodeVolumeHandle
+
+	if "" == eVS.snapShotName {
+		eVS.startInodeNumber = inode.RootDirInodeNumber
+	} else {
+		eVS.startInodeNumber, err = eVS.volume.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, "/"+inode.SnapShotDirName+"/"+eVS.snapShotName)
+		if nil != err {
+			eVS.jobLogErr("Got LookupPath() of SnapShot \"%s\" failure: %v", eVS.snapShotName, err)
+			return
+		}
+	}
+
+	eVS.tarWriter = tar.NewWriter(eVS.archive)
+	eVS.firstPathMap = make(map[inode.InodeNumber]string)
+	eVS.zeroBuf = make([]byte, archiveChunkSize)
+
+	globalPAX = map[string]string{
+		archivePAXRecordVersion:    archiveVersion,
+		archivePAXRecordVolumeName: eVS.volumeName,
+	}
+	if "" != eVS.snapShotName {
+		globalPAX[archivePAXRecordSnapShotName] = eVS.snapShotName
+	}
+
+	err = eVS.tarWriter.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: globalPAX,
+		Format:     tar.FormatPAX,
+	})
+	if nil != err {
+		eVS.jobLogErr("Got tarWriter.WriteHeader() of global header failure: %v", err)
+		return
+	}
+
+	_, ok = eVS.exportVolumeInode(eVS.startInodeNumber, "")
+	if !ok {
+		return
+	}
+
+	ok = eVS.exportVolumeWalkDir(eVS.startInodeNumber, "")
+	if !ok {
+		return
+	}
+
+	err = eVS.tarWriter.Close()
+	if nil != err {
+		eVS.jobLogErr("Got tarWriter.Close() failure: %v", err)
+		return
+	}
+}
+
+// importVolumeApplyStreams makes the Streams of inodeNumber match the SCHILY.xattr.* records of header,
+// removing any (e.g. inherited from its parent directory) not present in the archive. WORM Streams
+// are instead queued on iVS.wormList for importVolumeApplyWORMStreams().
+func (iVS *importVolumeStruct) importVolumeApplyStreams(inodeNumber inode.InodeNumber, header *tar.Header) (err error) {
+	var (
+		ok          bool
+		recordKey   string
+		recordValue string
+		streamName  string
+		streamNames []string
+		wormStreams map[string]string
+	)
+
+	streamNames, err = iVS.volume.ListXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber)
+	if nil != err {
+		return
+	}
+
+	for _, streamName = range streamNames {
+		_, ok = header.PAXRecords[archivePAXRecordStreamPrefix+streamName]
+		if !ok {
+			err = iVS.volume.RemoveXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber, streamName)
+			if nil != err {
+				return
+			}
+		}
+	}
+
+	for recordKey, recordValue = range header.PAXRecords {
+		if strings.HasPrefix(recordKey, archivePAXRecordStreamPrefix) {
+			streamName = strings.TrimPrefix(recordKey, archivePAXRecordStreamPrefix)
+			if isWORMStreamName(streamName) {
+				if nil == wormStreams {
+					wormStreams = make(map[string]string)
+				}
+				wormStreams[streamName] = recordValue
+				continue
+			}
+			err = iVS.volume.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber, streamName, []byte(recordValue), SetXAttrCreateOrReplace)
+			if nil != err {
+				return
+			}
+		}
+	}
+
+	if nil != wormStreams {
+		iVS.wormList = append(iVS.wormList, importVolumeWORMStruct{inodeNumber: inodeNumber, streams: wormStreams})
+	}
+
+	return
+}
+
+// importVolumeApplyWORMStreams applies the WORM Streams queued by importVolumeApplyStreams(). As the
+// Immutable and AppendOnly flags refuse any subsequent modification, RetainUntil is applied first.
+func (iVS *importVolumeStruct) importVolumeApplyWORMStreams() (err error) {
+	var (
+		ok          bool
+		streamName  string
+		streamValue string
+		worm        importVolumeWORMStruct
+	)
+
+	for _, worm = range iVS.wormList {
+		for _, streamName = range []string{inode.RetainUntilStreamName, inode.ImmutableStreamName, inode.AppendOnlyStreamName} {
+			streamValue, ok = worm.streams[streamName]
+			if !ok {
+				continue
+			}
+			err = iVS.volume.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, worm.inodeNumber, streamName, []byte(streamValue), SetXAttrCreateOrReplace)
+			if nil != err {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// importVolumeStat returns the Stat to apply to an Inode imported from header.
+func (iVS *importVolumeStruct) importVolumeStat(header *tar.Header) (stat Stat, err error) {
+	var (
+		creationTime         int64
+		creationTimeAsString string
+		ok                   bool
+	)
+
+	stat = Stat{
+		StatMode:    uint64(header.Mode & 07777),
+		StatUserID:  uint64(header.Uid),
+		StatGroupID: uint64(header.Gid),
+		StatMTime:   uint64(header.ModTime.UnixNano()),
+	}
+
+	if !header.AccessTime.IsZero() {
+		stat[StatATime] = uint64(header.AccessTime.UnixNano())
+	}
+
+	creationTimeAsString, ok = header.PAXRecords[archivePAXRecordCreationTime]
+	if ok {
+		creationTime, err = strconv.ParseInt(creationTimeAsString, 10, 64)
+		if nil != err {
+			return
+		}
+		stat[StatCRTime] = uint64(creationTime)
+	}
+
+	return
+}
+
+// importVolumeWriteFileData writes the data of the current entry of the archive to fileInodeNumber.
+// Only the extents described by the entry's PROXYFS.extents record (if any) are written such that the
+// holes of the exported file are preserved.
+func (iVS *importVolumeStruct) importVolumeWriteFileData(fileInodeNumber inode.InodeNumber, header *tar.Header) (err error) {
+	var (
+		buf             []byte
+		chunkLength     uint64
+		chunkOffset     uint64
+		extent          archiveExtentStruct
+		extentIndex     int
+		extents         []archiveExtentStruct
+		extentsAsString string
+		ok              bool
+		size            uint64
+		writeEnd        uint64
+		writeOffset     uint64
+	)
+
+	size = uint64(header.Size)
+
+	extentsAsString, ok = header.PAXRecords[archivePAXRecordExtents]
+	if ok {
+		extents, err = archiveParseExtents(extentsAsString, size)
+		if nil != err {
+			return
+		}
+	} else if 0 < size {
+		extents = []archiveExtentStruct{{offset: 0, length: size}}
+	}
+
+	extentIndex = 0
+
+	for chunkOffset = 0; chunkOffset < size; chunkOffset += chunkLength {
+		if iVS.stopFlag {
+			err = fmt.Errorf("job stopped")
+			return
+		}
+
+		chunkLength = size - chunkOffset
+		if chunkLength > archiveChunkSize {
+			chunkLength = archiveChunkSize
+		}
+
+		buf = make([]byte, chunkLength)
+
+		_, err = io.ReadFull(iVS.tarReader, buf)
+		if nil != err {
+			return
+		}
+
+		for extentIndex < len(extents) {
+			extent = extents[extentIndex]
+
+			if extent.offset >= (chunkOffset + chunkLength) {
+				break
+			}
+
+			writeOffset = extent.offset
+			if writeOffset < chunkOffset {
+				writeOffset = chunkOffset
+			}
+			writeEnd = extent.offset + extent.length
+			if writeEnd > (chunkOffset + chunkLength) {
+				writeEnd = chunkOffset + chunkLength
+			}
+
+			_, err = iVS.volume.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, writeOffset, buf[writeOffset-chunkOffset:writeEnd-chunkOffset], nil)
+			if nil != err {
+				return
+			}
+
+			if writeEnd < (extent.offset + extent.length) {
+				break
+			}
+
+			extentIndex++
+		}
+	}
+
+	err = iVS.volume.Resize(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, size)
+	if nil != err {
+		return
+	}
+
+	iVS.dataBytesImported += size
+
+	return
+}
+
+// importVolumeEntry recreates the Inode described by header. Should this fail, ok == false is returned.
+func (iVS *importVolumeStruct) importVolumeEntry(header *tar.Header) (ok bool) {
+	var (
+		basename          string
+		dirInodeNumber    inode.InodeNumber
+		entryPath         string
+		err               error
+		inodeNumber       inode.InodeNumber
+		stat              Stat
+		targetInodeNumber inode.InodeNumber
+	)
+
+	entryPath = path.Clean("/" + header.Name)
+
+	stat, err = iVS.importVolumeStat(header)
+	if nil != err {
+		iVS.jobLogErr("Entry \"%s\" has invalid %s record: %v", header.Name, archivePAXRecordCreationTime, err)
+		ok = false
+		return
+	}
+
+	if "/" == entryPath {
+		if tar.TypeDir != header.Typeflag {
+			iVS.jobLogErr("Entry \"%s\" must be a directory", header.Name)
+			ok = false
+			return
+		}
+
+		err = iVS.importVolumeApplyStreams(inode.RootDirInodeNumber, header)
+		if nil != err {
+			iVS.jobLogErr("Got Streams of \"/\" failure: %v", err)
+			ok = false
+			return
+		}
+
+		iVS.dirList = append(iVS.dirList, importVolumeDirStruct{inodeNumber: inode.RootDirInodeNumber, stat: stat})
+
+		ok = true
+		return
+	}
+
+	dirInodeNumber, ok = iVS.dirInodeNumberMap[path.Dir(entryPath)]
+	if !ok {
+		iVS.jobLogErr("Entry \"%s\" precedes (or lacks) an entry for its directory", header.Name)
+		return
+	}
+
+	basename = path.Base(entryPath)
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		inodeNumber, err = iVS.volume.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename, inode.PosixModePerm)
+		if nil != err {
+			iVS.jobLogErr("Got Mkdir(\"%s\") failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+
+		iVS.dirInodeNumberMap[entryPath] = inodeNumber
+
+		err = iVS.importVolumeApplyStreams(inodeNumber, header)
+		if nil != err {
+			iVS.jobLogErr("Got Streams of \"%s\" failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+
+		iVS.dirList = append(iVS.dirList, importVolumeDirStruct{inodeNumber: inodeNumber, stat: stat})
+	case tar.TypeReg:
+		inodeNumber, err = iVS.volume.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename, inode.PosixModePerm)
+		if nil != err {
+			iVS.jobLogErr("Got Create(\"%s\") failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+
+		err = iVS.importVolumeWriteFileData(inodeNumber, header)
+		if nil != err {
+			iVS.jobLogErr("Got data of \"%s\" failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+
+		err = iVS.importVolumeApplyStreams(inodeNumber, header)
+		if nil != err {
+			iVS.jobLogErr("Got Streams of \"%s\" failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+
+		err = iVS.volume.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber, stat)
+		if nil != err {
+			iVS.jobLogErr("Got Setstat(\"%s\") failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+	case tar.TypeSymlink:
+		inodeNumber, err = iVS.volume.Symlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename, header.Linkname)
+		if nil != err {
+			iVS.jobLogErr("Got Symlink(\"%s\") failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+
+		err = iVS.importVolumeApplyStreams(inodeNumber, header)
+		if nil != err {
+			iVS.jobLogErr("Got Streams of \"%s\" failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+
+		err = iVS.volume.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber, stat)
+		if nil != err {
+			iVS.jobLogErr("Got Setstat(\"%s\") failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+	case tar.TypeLink:
+		targetInodeNumber, err = iVS.volume.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, path.Clean("/"+header.Linkname))
+		if nil != err {
+			iVS.jobLogErr("Got LookupPath() of \"%s\" (hard linked to by \"%s\") failure: %v", header.Linkname, entryPath, err)
+			ok = false
+			return
+		}
+
+		err = iVS.volume.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename, targetInodeNumber)
+		if nil != err {
+			iVS.jobLogErr("Got Link(\"%s\") failure: %v", entryPath, err)
+			ok = false
+			return
+		}
+	default:
+		iVS.jobLogInfo("Skipping entry \"%s\" of unsupported type '%c'", header.Name, header.Typeflag)
+		ok = true
+		return
+	}
+
+	iVS.inodesImported++
+
+	ok = true
+	return
+}
+
+// importVolumeIsEmpty returns whether or not the volume's root directory contains only those entries
+// present in a freshly formatted (and served) volume.
+func (iVS *importVolumeStruct) importVolumeIsEmpty() (isEmpty bool, err error) {
+	var (
+		dirEntry      inode.DirEntry
+		dirEntrySlice []inode.DirEntry
+		dirInodeLock  *dlm.RWLockStruct
+		moreEntries   bool
+		prevReturned  string
+	)
+
+	iVS.volume.jobRWMutex.RLock()
+	defer iVS.volume.jobRWMutex.RUnlock()
+
+	dirInodeLock, err = iVS.inodeVolumeHandle.GetReadLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer dirInodeLock.Unlock()
+
+	moreEntries = true
+
+	for moreEntries {
+		if "" == prevReturned {
+			dirEntrySlice, moreEntries, err = iVS.inodeVolumeHandle.ReadDir(inode.RootDirInodeNumber, archiveReadDirMaxEntries, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = iVS.inodeVolumeHandle.ReadDir(inode.RootDirInodeNumber, archiveReadDirMaxEntries, 0, prevReturned)
+		}
+		if nil != err {
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			switch dirEntry.Basename {
+			case ".", "..", inode.SnapShotDirName, recycleBinDirName, objectVersionsDirName, multipartDirName:
+				// Present even in a freshly formatted volume
+			default:
+				isEmpty = false
+				return
+			}
+		}
+
+		if 0 == len(dirEntrySlice) {
+			break
+		}
+
+		prevReturned = dirEntrySlice[len(dirEntrySlice)-1].Basename
+	}
+
+	isEmpty = true
+	return
+}
+
+func (iVS *importVolumeStruct) importVolume() {
+	var (
+		dir     importVolumeDirStruct
+		err     error
+		header  *tar.Header
+		isEmpty bool
+		ok      bool
+		version string
+	)
+
+	iVS.jobLogInfo("IMPORT job initiated")
+
+	defer func(iVS *importVolumeStruct) {
+		if iVS.stopFlag {
+			iVS.jobLogInfo("IMPORT job stopped")
+		} else if 0 == len(iVS.err) {
+			iVS.jobLogInfo("IMPORT job completed without error (%v Inodes, %v data bytes imported)", iVS.inodesImported, iVS.dataBytesImported)
+		} else if 1 == len(iVS.err) {
+			iVS.jobLogInfo("IMPORT job exited with one error")
+		} else {
+			iVS.jobLogInfo("IMPORT job exited with errors")
+		}
+	}(iVS)
+
+	defer func(iVS *importVolumeStruct) {
+		iVS.active = false
+	}(iVS)
+
+	defer iVS.globalWaitGroup.Done()
+
+	// Find specified volume
+
+	globals.Lock()
+
+	iVS.volume, ok = globals.volumeMap[iVS.volumeName]
+	if !ok {
+		globals.Unlock()
+		iVS.jobLogErr("Couldn't find fs.volumeStruct")
+		return
+	}
+
+	globals.Unlock()
+
+	iVS.inodeVolumeHandle = iVS.volume.inodeVolumeHandle
+
+	isEmpty, err = iVS.importVolumeIsEmpty()
+	if nil != err {
+		iVS.jobLogErr("Got ReadDir() of \"/\" failure: %v", err)
+		return
+	}
+	if !isEmpty {
+		iVS.jobLogErr("Volume must be empty (e.g. freshly formatted) to be imported into")
+		return
+	}
+
+	iVS.tarReader = tar.NewReader(iVS.archive)
+	iVS.dirInodeNumberMap = map[string]inode.InodeNumber{"/": inode.RootDirInodeNumber}
+	iVS.dirList = make([]importVolumeDirStruct, 0)
+	iVS.wormList = make([]importVolumeWORMStruct, 0)
+
+	for {
+		if iVS.stopFlag {
+			return
+		}
+
+		header, err = iVS.tarReader.Next()
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			iVS.jobLogErr("Got tarReader.Next() failure: %v", err)
+			return
+		}
+
+		if tar.TypeXGlobalHeader == header.Typeflag {
+			version, ok = header.PAXRecords[archivePAXRecordVersion]
+			if ok {
+				if archiveVersion != version {
+					iVS.jobLogErr("Archive version %s not supported", version)
+					return
+				}
+				iVS.jobLogInfo("Importing archive of volume \"%s\" (SnapShot \"%s\")", header.PAXRecords[archivePAXRecordVolumeName], header.PAXRecords[archivePAXRecordSnapShotName])
+			}
+			continue
+		}
+
+		if !iVS.importVolumeEntry(header) {
+			return
+		}
+	}
+
+	for _, dir = range iVS.dirList {
+		err = iVS.volume.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dir.inodeNumber, dir.stat)
+		if nil != err {
+			iVS.jobLogErr("Got Setstat(0x%016X) failure: %v", dir.inodeNumber, err)
+			return
+		}
+	}
+
+	err = iVS.importVolumeApplyWORMStreams()
+	if nil != err {
+		iVS.jobLogErr("Got WORM Streams failure: %v", err)
+		return
+	}
+}
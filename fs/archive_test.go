@@ -0,0 +1,279 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func TestExportImportVolume(t *testing.T) {
+	testSetup(t, false)
+
+	dirInodeNumber, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Dir", inode.InodeMode(0750))
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "user.color", []byte("blue"), SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr() failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	headData := bytes.Repeat([]byte{0x11}, 4096)
+	tailData := bytes.Repeat([]byte{0x22}, 4096)
+	tailOffset := uint64(3 * 1024 * 1024)
+
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, headData, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, tailOffset, tailData, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	modificationTime := time.Date(2020, time.January, 2, 3, 4, 5, 6, time.UTC)
+
+	err = testVolumeStruct.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, Stat{
+		StatMode:    uint64(0640),
+		StatUserID:  uint64(1000),
+		StatGroupID: uint64(1001),
+		StatMTime:   uint64(modificationTime.UnixNano()),
+	})
+	if nil != err {
+		t.Fatalf("Setstat() failed: %v", err)
+	}
+
+	err = testVolumeStruct.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "FileLink", fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() failed: %v", err)
+	}
+
+	_, err = testVolumeStruct.Symlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Symlink", "File")
+	if nil != err {
+		t.Fatalf("Symlink() failed: %v", err)
+	}
+
+	err = testVolumeStruct.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, Stat{StatMTime: uint64(modificationTime.UnixNano())})
+	if nil != err {
+		t.Fatalf("Setstat() failed: %v", err)
+	}
+
+	// An Immutable directory must still be importable (i.e. its WORM Streams applied last)
+
+	err = testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, inode.WORMStateStruct{Immutable: true})
+	if nil != err {
+		t.Fatalf("SetWORMState() failed: %v", err)
+	}
+
+	// Export the volume and check its archive
+
+	archive := &bytes.Buffer{}
+
+	exportVolumeHandle := ExportVolume("TestVolume", "", archive)
+	exportVolumeHandle.Wait()
+	if 0 != len(exportVolumeHandle.Error()) {
+		t.Fatalf("ExportVolume() reported errors: %v", exportVolumeHandle.Error())
+	}
+
+	archiveBytes := archive.Bytes()
+
+	tarReader := tar.NewReader(bytes.NewReader(archiveBytes))
+	headerMap := make(map[string]*tar.Header)
+
+	for {
+		header, err := tarReader.Next()
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			t.Fatalf("tarReader.Next() failed: %v", err)
+		}
+		headerMap[header.Name] = header
+	}
+
+	header, ok := headerMap["Dir/File"]
+	if !ok || (tar.TypeReg != header.Typeflag) || (int64(tailOffset)+int64(len(tailData)) != header.Size) {
+		t.Fatalf("Archive entry for Dir/File missing or unexpected: %#v", header)
+	}
+	if "0,4096,3145728,4096" != header.PAXRecords[archivePAXRecordExtents] {
+		t.Fatalf("Archive entry for Dir/File has unexpected %s record: \"%s\"", archivePAXRecordExtents, header.PAXRecords[archivePAXRecordExtents])
+	}
+	header, ok = headerMap["Dir/FileLink"]
+	if !ok || (tar.TypeLink != header.Typeflag) || ("Dir/File" != header.Linkname) {
+		t.Fatalf("Archive entry for Dir/FileLink missing or unexpected: %#v", header)
+	}
+	header, ok = headerMap["Dir/"]
+	if !ok || ("blue" != header.PAXRecords[archivePAXRecordStreamPrefix+"user.color"]) {
+		t.Fatalf("Archive entry for Dir/ missing or unexpected: %#v", header)
+	}
+
+	// An import into a non-empty volume fails
+
+	importVolumeHandle := ImportVolume("TestVolume", bytes.NewReader(archiveBytes))
+	importVolumeHandle.Wait()
+	if 0 == len(importVolumeHandle.Error()) {
+		t.Fatalf("ImportVolume() into a non-empty volume should have reported an error")
+	}
+
+	// Empty the volume and import the archive into it
+
+	err = testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, inode.WORMStateStruct{})
+	if nil != err {
+		t.Fatalf("SetWORMState() failed: %v", err)
+	}
+	for _, basename := range []string{"FileLink", "File", "Symlink"} {
+		err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink(\"%s\") failed: %v", basename, err)
+		}
+	}
+	err = testVolumeStruct.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Dir")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+
+	importVolumeHandle = ImportVolume("TestVolume", bytes.NewReader(archiveBytes))
+	importVolumeHandle.Wait()
+	if 0 != len(importVolumeHandle.Error()) {
+		t.Fatalf("ImportVolume() reported errors: %v", importVolumeHandle.Error())
+	}
+
+	dirInodeNumber, err = testVolumeStruct.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, "/Dir")
+	if nil != err {
+		t.Fatalf("LookupPath(\"/Dir\") failed: %v", err)
+	}
+	stat, err := testVolumeStruct.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() failed: %v", err)
+	}
+	if (uint64(0750) != (stat[StatMode] & 07777)) || (uint64(modificationTime.UnixNano()) != stat[StatMTime]) {
+		t.Fatalf("Getstat() of /Dir returned unexpected mode (0%o) or mtime (%v)", stat[StatMode], stat[StatMTime])
+	}
+	streamValue, err := testVolumeStruct.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "user.color")
+	if (nil != err) || ("blue" != string(streamValue)) {
+		t.Fatalf("GetXAttr() of /Dir returned \"%s\" (err: %v)", string(streamValue), err)
+	}
+	wormState, err := testVolumeStruct.GetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber)
+	if (nil != err) || !wormState.Immutable {
+		t.Fatalf("GetWORMState() of /Dir returned %+v (err: %v)", wormState, err)
+	}
+
+	fileInodeNumber, err = testVolumeStruct.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, "/Dir/File")
+	if nil != err {
+		t.Fatalf("LookupPath(\"/Dir/File\") failed: %v", err)
+	}
+	fileLinkInodeNumber, err := testVolumeStruct.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, "/Dir/FileLink")
+	if (nil != err) || (fileLinkInodeNumber != fileInodeNumber) {
+		t.Fatalf("LookupPath(\"/Dir/FileLink\") returned 0x%016X (err: %v) but expected 0x%016X", fileLinkInodeNumber, err, fileInodeNumber)
+	}
+	stat, err = testVolumeStruct.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() failed: %v", err)
+	}
+	if (uint64(0640) != (stat[StatMode] & 07777)) || (uint64(1000) != stat[StatUserID]) || (uint64(1001) != stat[StatGroupID]) {
+		t.Fatalf("Getstat() of /Dir/File returned unexpected mode (0%o), uid (%v), or gid (%v)", stat[StatMode], stat[StatUserID], stat[StatGroupID])
+	}
+	if (uint64(modificationTime.UnixNano()) != stat[StatMTime]) || (uint64(2) != stat[StatNLink]) || (tailOffset+uint64(len(tailData)) != stat[StatSize]) {
+		t.Fatalf("Getstat() of /Dir/File returned unexpected mtime (%v), nlink (%v), or size (%v)", stat[StatMTime], stat[StatNLink], stat[StatSize])
+	}
+
+	readData, err := testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, uint64(len(headData)), nil)
+	if (nil != err) || !bytes.Equal(headData, readData) {
+		t.Fatalf("Read() of /Dir/File head returned unexpected data (err: %v)", err)
+	}
+	readData, err = testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, tailOffset, uint64(len(tailData)), nil)
+	if (nil != err) || !bytes.Equal(tailData, readData) {
+		t.Fatalf("Read() of /Dir/File tail returned unexpected data (err: %v)", err)
+	}
+
+	extentMapChunk, err := testVolumeStruct.FetchExtentMapChunk(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, 64, 0)
+	if nil != err {
+		t.Fatalf("FetchExtentMapChunk() failed: %v", err)
+	}
+	if 2 != len(extentMapChunk.ExtentMapEntry) {
+		t.Fatalf("FetchExtentMapChunk() of /Dir/File returned %v ExtentMapEntries (expected 2 as its hole should be preserved)", len(extentMapChunk.ExtentMapEntry))
+	}
+
+	symlinkInodeNumber, err := testVolumeStruct.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, "/Dir/Symlink")
+	if nil != err {
+		t.Fatalf("LookupPath(\"/Dir/Symlink\") failed: %v", err)
+	}
+	target, err := testVolumeStruct.Readsymlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, symlinkInodeNumber)
+	if (nil != err) || ("File" != target) {
+		t.Fatalf("Readsymlink() of /Dir/Symlink returned \"%s\" (err: %v)", target, err)
+	}
+
+	testTeardown(t)
+}
+
+func TestExportImportVolumeManyRootEntries(t *testing.T) {
+	testSetup(t, false)
+
+	// More root entries than are returned by a single ReadDir() during export
+
+	numFiles := int(archiveReadDirMaxEntries) + 1
+
+	for i := 0; i < numFiles; i++ {
+		_, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, fmt.Sprintf("File%05d", i), inode.PosixModePerm)
+		if nil != err {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+
+	archive := &bytes.Buffer{}
+
+	exportVolumeHandle := ExportVolume("TestVolume", "", archive)
+	exportVolumeHandle.Wait()
+	if 0 != len(exportVolumeHandle.Error()) {
+		t.Fatalf("ExportVolume() reported errors: %v", exportVolumeHandle.Error())
+	}
+
+	archiveBytes := archive.Bytes()
+
+	importVolumeHandle := ImportVolume("TestVolume", bytes.NewReader(archiveBytes))
+	importVolumeHandle.Wait()
+	if 0 == len(importVolumeHandle.Error()) {
+		t.Fatalf("ImportVolume() into a non-empty volume should have reported an error")
+	}
+
+	// Empty the volume and import the archive into it
+
+	for i := 0; i < numFiles; i++ {
+		err := testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, fmt.Sprintf("File%05d", i))
+		if nil != err {
+			t.Fatalf("Unlink() failed: %v", err)
+		}
+	}
+
+	importVolumeHandle = ImportVolume("TestVolume", bytes.NewReader(archiveBytes))
+	importVolumeHandle.Wait()
+	if 0 != len(importVolumeHandle.Error()) {
+		t.Fatalf("ImportVolume() reported errors: %v", importVolumeHandle.Error())
+	}
+
+	for i := 0; i < numFiles; i++ {
+		_, err := testVolumeStruct.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fmt.Sprintf("/File%05d", i))
+		if nil != err {
+			t.Fatalf("LookupPath(\"/File%05d\") failed: %v", i, err)
+		}
+	}
+
+	testTeardown(t)
+}
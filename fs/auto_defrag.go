@@ -0,0 +1,341 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/dlm"
+	"github.com/NVIDIA/proxyfs/inode"
+	"github.com/NVIDIA/proxyfs/logger"
+)
+
+const (
+	autoDefragCheckpointPollInterval = 100 * time.Millisecond
+)
+
+// fetchAutoDefragConfig fetches the (optional) AutoDefrag* options for a volume. Note that
+// auto-defragmentation is disabled unless AutoDefragInterval is specified (and non-zero).
+func (vS *volumeStruct) fetchAutoDefragConfig(confMap conf.ConfMap, volumeSectionName string) (err error) {
+	var (
+		autoDefragTimeWindow      string
+		autoDefragTimeWindowSplit []string
+	)
+
+	vS.autoDefragInterval, err = confMap.FetchOptionValueDuration(volumeSectionName, "AutoDefragInterval")
+	if nil != err {
+		vS.autoDefragInterval = time.Duration(0) // Default to auto-defragmentation being disabled
+	}
+	vS.autoDefragInodesPerPass, err = confMap.FetchOptionValueUint64(volumeSectionName, "AutoDefragInodesPerPass")
+	if nil != err {
+		vS.autoDefragInodesPerPass = 1000
+	}
+	vS.autoDefragFragmentThreshold, err = confMap.FetchOptionValueUint64(volumeSectionName, "AutoDefragFragmentThreshold")
+	if nil != err {
+		vS.autoDefragFragmentThreshold = 256
+	}
+	vS.autoDefragBytesTrappedThreshold, err = confMap.FetchOptionValueUint64(volumeSectionName, "AutoDefragBytesTrappedThreshold")
+	if nil != err {
+		vS.autoDefragBytesTrappedThreshold = 67108864
+	}
+	vS.autoDefragBytesPerSecond, err = confMap.FetchOptionValueUint64(volumeSectionName, "AutoDefragBytesPerSecond")
+	if nil != err {
+		vS.autoDefragBytesPerSecond = 10485760
+	}
+
+	autoDefragTimeWindow, err = confMap.FetchOptionValueString(volumeSectionName, "AutoDefragTimeWindow")
+	if (nil != err) || ("" == autoDefragTimeWindow) {
+		vS.autoDefragTimeWindowEnabled = false
+	} else {
+		autoDefragTimeWindowSplit = strings.Split(autoDefragTimeWindow, "-")
+		if 2 != len(autoDefragTimeWindowSplit) {
+			err = fmt.Errorf("%s.AutoDefragTimeWindow (\"%s\") must be of the form \"HH:MM-HH:MM\"", volumeSectionName, autoDefragTimeWindow)
+			return
+		}
+		vS.autoDefragTimeWindowStart, err = parseTimeOfDay(autoDefragTimeWindowSplit[0])
+		if nil != err {
+			err = fmt.Errorf("%s.AutoDefragTimeWindow (\"%s\") start invalid: %v", volumeSectionName, autoDefragTimeWindow, err)
+			return
+		}
+		vS.autoDefragTimeWindowStop, err = parseTimeOfDay(autoDefragTimeWindowSplit[1])
+		if nil != err {
+			err = fmt.Errorf("%s.AutoDefragTimeWindow (\"%s\") stop invalid: %v", volumeSectionName, autoDefragTimeWindow, err)
+			return
+		}
+		vS.autoDefragTimeWindowEnabled = true
+	}
+
+	err = nil
+	return
+}
+
+// parseTimeOfDay converts "HH:MM" to the time.Duration since midnight.
+func parseTimeOfDay(timeOfDayAsString string) (timeOfDay time.Duration, err error) {
+	var (
+		timeOfDayAsTime time.Time
+	)
+
+	timeOfDayAsTime, err = time.Parse("15:04", strings.TrimSpace(timeOfDayAsString))
+	if nil != err {
+		return
+	}
+
+	timeOfDay = time.Duration(timeOfDayAsTime.Hour())*time.Hour + time.Duration(timeOfDayAsTime.Minute())*time.Minute
+
+	return
+}
+
+// autoDefragInTimeWindow returns whether or not now falls within the AutoDefragTimeWindow (if any).
+// Note that a window whose stop precedes its start is taken to span midnight.
+func (vS *volumeStruct) autoDefragInTimeWindow(now time.Time) (inTimeWindow bool) {
+	var (
+		timeOfDay time.Duration
+	)
+
+	if !vS.autoDefragTimeWindowEnabled {
+		inTimeWindow = true
+		return
+	}
+
+	timeOfDay = time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+
+	if vS.autoDefragTimeWindowStart <= vS.autoDefragTimeWindowStop {
+		inTimeWindow = (vS.autoDefragTimeWindowStart <= timeOfDay) && (timeOfDay < vS.autoDefragTimeWindowStop)
+	} else {
+		inTimeWindow = (vS.autoDefragTimeWindowStart <= timeOfDay) || (timeOfDay < vS.autoDefragTimeWindowStop)
+	}
+
+	return
+}
+
+func (vS *volumeStruct) startAutoDefragDaemon() {
+	if time.Duration(0) == vS.autoDefragInterval {
+		vS.autoDefragStopChan = nil
+		return
+	}
+
+	vS.autoDefragStopChan = make(chan struct{})
+	vS.autoDefragWG.Add(1)
+
+	go vS.autoDefragDaemon()
+}
+
+func (vS *volumeStruct) stopAutoDefragDaemon() {
+	if nil == vS.autoDefragStopChan {
+		return
+	}
+
+	close(vS.autoDefragStopChan)
+	vS.autoDefragWG.Wait()
+
+	vS.autoDefragStopChan = nil
+}
+
+// autoDefragSleep waits for duration returning false if the daemon should stop.
+func (vS *volumeStruct) autoDefragSleep(duration time.Duration) (keepGoing bool) {
+	select {
+	case <-vS.autoDefragStopChan:
+		keepGoing = false
+	case <-time.After(duration):
+		keepGoing = true
+	}
+
+	return
+}
+
+func (vS *volumeStruct) autoDefragDaemon() {
+	defer vS.autoDefragWG.Done()
+
+	for vS.autoDefragSleep(vS.autoDefragInterval) {
+		if vS.autoDefragInTimeWindow(time.Now()) {
+			if !vS.autoDefragPass() {
+				return
+			}
+		}
+	}
+}
+
+// autoDefragPass samples up to autoDefragInodesPerPass Inodes (resuming where the prior pass
+// left off) defragmenting those FileInodes exceeding either the fragment count or BytesTrapped
+// threshold. It returns false if the daemon should stop.
+func (vS *volumeStruct) autoDefragPass() (keepGoing bool) {
+	var (
+		err                     error
+		fragmentationReport     inode.FragmentationReport
+		inodeNumber             inode.InodeNumber
+		inodesSampled           uint64
+		nextInodeNumberAsUint64 uint64
+		ok                      bool
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.AutoDefragPassUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+	}()
+
+	for inodesSampled = 0; inodesSampled < vS.autoDefragInodesPerPass; inodesSampled++ {
+		if !vS.autoDefragInTimeWindow(time.Now()) {
+			keepGoing = true
+			return
+		}
+
+		nextInodeNumberAsUint64, ok, err = vS.headhunterVolumeHandle.NextInodeNumber(vS.autoDefragLastInodeNumber)
+		if nil != err {
+			logger.ErrorfWithError(err, "Auto-defragmentation of volume %s unable to walk Inode Table", vS.volumeName)
+			globals.AutoDefragErrors.Add(1)
+			keepGoing = true
+			return
+		}
+		if !ok {
+			// Wrap around for the next pass
+
+			vS.autoDefragLastInodeNumber = 0
+			keepGoing = true
+			return
+		}
+
+		vS.autoDefragLastInodeNumber = nextInodeNumberAsUint64
+		inodeNumber = inode.InodeNumber(nextInodeNumberAsUint64)
+
+		globals.AutoDefragInodesSampled.Add(1)
+
+		fragmentationReport, err = vS.autoDefragFetchFragmentationReport(inodeNumber)
+		if nil != err {
+			continue
+		}
+
+		if (fragmentationReport.NumberOfFragments < vS.autoDefragFragmentThreshold) &&
+			(fragmentationReport.BytesTrapped < vS.autoDefragBytesTrappedThreshold) {
+			continue
+		}
+
+		keepGoing, err = vS.autoDefragFile(inodeNumber)
+		if nil == err {
+			globals.AutoDefragFilesDefragmented.Add(1)
+		} else {
+			logger.WarnfWithError(err, "Auto-defragmentation of volume %s inode 0x%016X failed", vS.volumeName, inodeNumber)
+			globals.AutoDefragErrors.Add(1)
+		}
+		if !keepGoing {
+			return
+		}
+	}
+
+	keepGoing = true
+	return
+}
+
+// autoDefragFetchFragmentationReport returns the FragmentationReport for inodeNumber (failing if
+// it is not a FileInode) while holding the same locks as any other consumer of the FileInode.
+func (vS *volumeStruct) autoDefragFetchFragmentationReport(inodeNumber inode.InodeNumber) (fragmentationReport inode.FragmentationReport, err error) {
+	var (
+		inodeLock *dlm.RWLockStruct
+		inodeType inode.InodeType
+	)
+
+	inodeLock, err = vS.inodeVolumeHandle.InitInodeLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	err = inodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	inodeType, err = vS.inodeVolumeHandle.GetType(inodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.FileType != inodeType {
+		err = blunder.NewError(blunder.NotFileError, "inode 0x%016X is not a FileInode", inodeNumber)
+		return
+	}
+
+	fragmentationReport, err = vS.inodeVolumeHandle.FetchFragmentationReport(inodeNumber)
+
+	return // err as returned by FetchFragmentationReport() is sufficient
+}
+
+// autoDefragFile defragments fileInodeNumber one chunk at a time much like DefragmentFile(). Between
+// chunks, it pauses for any checkpoint in progress and delays sufficiently to remain within the
+// AutoDefragBytesPerSecond budget. It returns false for keepGoing if the daemon should stop.
+func (vS *volumeStruct) autoDefragFile(fileInodeNumber inode.InodeNumber) (keepGoing bool, err error) {
+	var (
+		chunkDelay     time.Duration
+		chunkStartTime time.Time
+		eofReached     bool
+		fileOffset     uint64
+		inodeLock      *dlm.RWLockStruct
+		nextFileOffset uint64
+	)
+
+	inodeLock, err = vS.inodeVolumeHandle.InitInodeLock(fileInodeNumber, nil)
+	if nil != err {
+		keepGoing = true
+		return
+	}
+
+	fileOffset = 0
+
+	for {
+		for vS.headhunterVolumeHandle.CheckpointInProgress() {
+			if !vS.autoDefragSleep(autoDefragCheckpointPollInterval) {
+				keepGoing = false
+				err = nil
+				return
+			}
+		}
+
+		chunkStartTime = time.Now()
+
+		vS.jobRWMutex.RLock()
+		err = inodeLock.WriteLock()
+		if nil != err {
+			vS.jobRWMutex.RUnlock()
+			keepGoing = true
+			return
+		}
+
+		nextFileOffset, eofReached, err = vS.inodeVolumeHandle.DefragmentFile(fileInodeNumber, fileOffset, vS.fileDefragmentChunkSize)
+
+		_ = inodeLock.Unlock()
+		vS.jobRWMutex.RUnlock()
+
+		if nil != err {
+			keepGoing = true
+			return
+		}
+
+		globals.AutoDefragBytes.Add(nextFileOffset - fileOffset)
+
+		if eofReached {
+			keepGoing = true
+			return
+		}
+
+		chunkDelay = vS.fileDefragmentChunkDelay
+		if 0 != vS.autoDefragBytesPerSecond {
+			chunkDelay = time.Duration((nextFileOffset-fileOffset)*uint64(time.Second)/vS.autoDefragBytesPerSecond) - time.Since(chunkStartTime)
+			if chunkDelay < vS.fileDefragmentChunkDelay {
+				chunkDelay = vS.fileDefragmentChunkDelay
+			}
+		}
+
+		if !vS.autoDefragSleep(chunkDelay) {
+			keepGoing = false
+			return
+		}
+
+		fileOffset = nextFileOffset
+	}
+}
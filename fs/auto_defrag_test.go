@@ -0,0 +1,128 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func TestAutoDefragTimeWindow(t *testing.T) {
+	var (
+		err error
+		vS  volumeStruct
+	)
+
+	if !vS.autoDefragInTimeWindow(time.Now()) {
+		t.Fatalf("autoDefragInTimeWindow() should always be true if no AutoDefragTimeWindow")
+	}
+
+	vS.autoDefragTimeWindowEnabled = true
+
+	vS.autoDefragTimeWindowStart, err = parseTimeOfDay("01:30")
+	if nil != err {
+		t.Fatalf("parseTimeOfDay(\"01:30\") failed: %v", err)
+	}
+	vS.autoDefragTimeWindowStop, err = parseTimeOfDay("05:00")
+	if nil != err {
+		t.Fatalf("parseTimeOfDay(\"05:00\") failed: %v", err)
+	}
+
+	if !vS.autoDefragInTimeWindow(time.Date(2021, 1, 1, 2, 0, 0, 0, time.Local)) {
+		t.Fatalf("02:00 should be in time window 01:30-05:00")
+	}
+	if vS.autoDefragInTimeWindow(time.Date(2021, 1, 1, 5, 0, 0, 0, time.Local)) {
+		t.Fatalf("05:00 should not be in time window 01:30-05:00")
+	}
+
+	vS.autoDefragTimeWindowStart, _ = parseTimeOfDay("22:00")
+	vS.autoDefragTimeWindowStop, _ = parseTimeOfDay("06:00")
+
+	if !vS.autoDefragInTimeWindow(time.Date(2021, 1, 1, 23, 0, 0, 0, time.Local)) {
+		t.Fatalf("23:00 should be in time window 22:00-06:00")
+	}
+	if !vS.autoDefragInTimeWindow(time.Date(2021, 1, 1, 1, 0, 0, 0, time.Local)) {
+		t.Fatalf("01:00 should be in time window 22:00-06:00")
+	}
+	if vS.autoDefragInTimeWindow(time.Date(2021, 1, 1, 12, 0, 0, 0, time.Local)) {
+		t.Fatalf("12:00 should not be in time window 22:00-06:00")
+	}
+
+	_, err = parseTimeOfDay("25:00")
+	if nil == err {
+		t.Fatalf("parseTimeOfDay(\"25:00\") should have failed")
+	}
+}
+
+func TestAutoDefragPass(t *testing.T) {
+	testSetup(t, false)
+
+	fileInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "FragmentedFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	// Each Flush()'d Write() lands in a distinct LogSegment... yielding distinct fragments
+
+	for _, offset := range []uint64{8, 4, 0} {
+		_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, offset, []byte{byte(offset), byte(offset + 1), byte(offset + 2), byte(offset + 3)}, nil)
+		if nil != err {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+		if nil != err {
+			t.Fatalf("Flush() failed: %v", err)
+		}
+	}
+
+	fragmentationReport, err := testVolumeStruct.inodeVolumeHandle.FetchFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("FetchFragmentationReport() failed: %v", err)
+	}
+	if (3 != fragmentationReport.NumberOfFragments) || (12 != fragmentationReport.BytesInFragments) {
+		t.Fatalf("FetchFragmentationReport() before auto-defragmentation returned unexpected %#v", fragmentationReport)
+	}
+
+	testVolumeStruct.autoDefragInodesPerPass = 1000
+	testVolumeStruct.autoDefragFragmentThreshold = 2
+	testVolumeStruct.autoDefragBytesTrappedThreshold = 1 << 30
+	testVolumeStruct.autoDefragBytesPerSecond = 0
+	testVolumeStruct.autoDefragLastInodeNumber = 0
+
+	filesDefragmentedBefore := globals.AutoDefragFilesDefragmented.TotalGet()
+
+	if !testVolumeStruct.autoDefragPass() {
+		t.Fatalf("autoDefragPass() should have returned keepGoing == true")
+	}
+
+	if globals.AutoDefragFilesDefragmented.TotalGet() == filesDefragmentedBefore {
+		t.Fatalf("autoDefragPass() should have defragmented FragmentedFile")
+	}
+
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	fragmentationReport, err = testVolumeStruct.inodeVolumeHandle.FetchFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("FetchFragmentationReport() failed: %v", err)
+	}
+	if 1 != fragmentationReport.NumberOfFragments {
+		t.Fatalf("FetchFragmentationReport() after auto-defragmentation returned unexpected %#v", fragmentationReport)
+	}
+
+	readBuf, err := testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, 12, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if 0 != bytes.Compare([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, readBuf) {
+		t.Fatalf("Read() after auto-defragmentation returned %v", readBuf)
+	}
+
+	testTeardown(t)
+}
@@ -0,0 +1,628 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/dlm"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+// When a volume's ChangeNotificationBufferSize is non-zero, each successful namespace or
+// metadata changing operation appends a ChangeEvent to a ring buffer retaining the most recent
+// ChangeNotificationBufferSize of them. Consumers fetch ChangeEvents following the last one
+// they've seen (by Epoch & SequenceNumber) via FetchChangeEvents(), optionally waiting for one
+// to arrive. Sequence numbers restart at 1 (under a new Epoch) each time the volume is served.
+//
+// Each ChangeEvent is assigned its SequenceNumber while the operation producing it still holds
+// its Inode locks such that SequenceNumbers reflect the order in which operations took effect.
+// Paths are then resolved (and ChangeEvents published in SequenceNumber order) by a per-volume
+// daemon rather than by the operation itself. Should the daemon fall more than
+// ChangeNotificationBufferSize ChangeEvents behind, those about to be overwritten are published
+// without resolving their paths.
+//
+// As FileInodes have no reference back to their parent directory, the directory & basename by
+// which each Inode was last reached (via Lookup() or a namespace operation) is remembered in a
+// bounded (LRU) map. DirInodes not found there are resolved via their ".." entry. ChangeEvents
+// for Inodes whose path cannot be resolved carry an empty Path.
+
+const (
+	changeEventParentMapMaxEntries = 65536
+	changeEventMaxPathDepth        = 1024
+	changeEventReadDirMaxEntries   = uint64(1024)
+)
+
+type changeEventParentStruct struct {
+	dirInodeNumber inode.InodeNumber
+	basename       string
+}
+
+// changeEventParentLRUEntryStruct is the Value of each element of volumeStruct.changeEventParentLRU.
+type changeEventParentLRUEntryStruct struct {
+	inodeNumber inode.InodeNumber
+	parent      changeEventParentStruct
+}
+
+// changeEventPendingStruct describes a ChangeEvent awaiting publication.
+type changeEventPendingStruct struct {
+	changeEventType   ChangeEventType
+	inodeNumber       inode.InodeNumber
+	dirInodeNumber    inode.InodeNumber // if != 0, basename is the name of inodeNumber in dirInodeNumber
+	basename          string
+	oldDirInodeNumber inode.InodeNumber // ChangeEventRename only
+	oldBasename       string            // ChangeEventRename only
+	path              string            // if != "", supplied by caller in lieu of being resolved
+	sequenceNumber    uint64            // assigned by add()
+	time              time.Time         // assigned by add()
+}
+
+// changeEventPendingListStruct tracks the ChangeEvents produced by an operation. Operations only
+// add to it upon success (and while still holding the locks protecting what was changed).
+type changeEventPendingListStruct struct {
+	volume *volumeStruct
+	added  bool
+}
+
+func (vS *volumeStruct) newChangeEventPendingList() (changeEventPendingList *changeEventPendingListStruct) {
+	changeEventPendingList = &changeEventPendingListStruct{volume: vS}
+	return
+}
+
+// add assigns changeEvent the next SequenceNumber and queues it for publication.
+func (changeEventPendingList *changeEventPendingListStruct) add(changeEvent changeEventPendingStruct) {
+	var (
+		vS = changeEventPendingList.volume
+	)
+
+	if !vS.changeNotificationEnabled() {
+		return
+	}
+
+	vS.changeEventMutex.Lock()
+
+	vS.changeEventNextSequenceNumber++
+
+	changeEvent.sequenceNumber = vS.changeEventNextSequenceNumber
+	changeEvent.time = time.Now()
+
+	vS.changeEventPendingQueue = append(vS.changeEventPendingQueue, changeEvent)
+
+	vS.changeEventMutex.Unlock()
+
+	changeEventPendingList.added = true
+}
+
+func (changeEventType ChangeEventType) String() (changeEventTypeAsString string) {
+	switch changeEventType {
+	case ChangeEventCreate:
+		changeEventTypeAsString = "create"
+	case ChangeEventUnlink:
+		changeEventTypeAsString = "unlink"
+	case ChangeEventRename:
+		changeEventTypeAsString = "rename"
+	case ChangeEventWriteClose:
+		changeEventTypeAsString = "write-close"
+	case ChangeEventSetattr:
+		changeEventTypeAsString = "setattr"
+	case ChangeEventXAttr:
+		changeEventTypeAsString = "xattr"
+	default:
+		changeEventTypeAsString = fmt.Sprintf("ChangeEventType(%d)", uint8(changeEventType))
+	}
+
+	return
+}
+
+func (vS *volumeStruct) changeNotificationEnabled() (enabled bool) {
+	enabled = (0 != vS.changeEventBufferSize)
+	return
+}
+
+// noteChangeEventParent remembers that inodeNumber was reached as basename in dirInodeNumber.
+func (vS *volumeStruct) noteChangeEventParent(inodeNumber inode.InodeNumber, dirInodeNumber inode.InodeNumber, basename string) {
+	var (
+		lruElement *list.Element
+		lruEntry   *changeEventParentLRUEntryStruct
+		ok         bool
+	)
+
+	if !vS.changeNotificationEnabled() || ("." == basename) || (".." == basename) {
+		return
+	}
+
+	vS.changeEventMutex.Lock()
+
+	lruElement, ok = vS.changeEventParentMap[inodeNumber]
+	if ok {
+		lruEntry = lruElement.Value.(*changeEventParentLRUEntryStruct)
+		lruEntry.parent = changeEventParentStruct{dirInodeNumber: dirInodeNumber, basename: basename}
+		vS.changeEventParentLRU.MoveToBack(lruElement)
+	} else {
+		if changeEventParentMapMaxEntries <= len(vS.changeEventParentMap) {
+			lruElement = vS.changeEventParentLRU.Front()
+			lruEntry = vS.changeEventParentLRU.Remove(lruElement).(*changeEventParentLRUEntryStruct)
+			delete(vS.changeEventParentMap, lruEntry.inodeNumber)
+		}
+
+		lruEntry = &changeEventParentLRUEntryStruct{
+			inodeNumber: inodeNumber,
+			parent:      changeEventParentStruct{dirInodeNumber: dirInodeNumber, basename: basename},
+		}
+
+		vS.changeEventParentMap[inodeNumber] = vS.changeEventParentLRU.PushBack(lruEntry)
+	}
+
+	vS.changeEventMutex.Unlock()
+}
+
+// forgetChangeEventParent drops the remembered parent of inodeNumber if it was basename in dirInodeNumber.
+func (vS *volumeStruct) forgetChangeEventParent(inodeNumber inode.InodeNumber, dirInodeNumber inode.InodeNumber, basename string) {
+	var (
+		lruElement *list.Element
+		lruEntry   *changeEventParentLRUEntryStruct
+		ok         bool
+	)
+
+	vS.changeEventMutex.Lock()
+
+	lruElement, ok = vS.changeEventParentMap[inodeNumber]
+	if ok {
+		lruEntry = lruElement.Value.(*changeEventParentLRUEntryStruct)
+		if (lruEntry.parent.dirInodeNumber == dirInodeNumber) && (lruEntry.parent.basename == basename) {
+			_ = vS.changeEventParentLRU.Remove(lruElement)
+			delete(vS.changeEventParentMap, inodeNumber)
+		}
+	}
+
+	vS.changeEventMutex.Unlock()
+}
+
+// noteWrite records that inodeNumber has been written such that its next Flush() posts a ChangeEventWriteClose.
+func (vS *volumeStruct) noteWrite(inodeNumber inode.InodeNumber) {
+	if !vS.changeNotificationEnabled() {
+		return
+	}
+
+	vS.changeEventMutex.Lock()
+	vS.changeEventWrittenMap[inodeNumber] = struct{}{}
+	vS.changeEventMutex.Unlock()
+}
+
+// fetchAndClearWritten returns whether or not inodeNumber has been written since noteWrite() was last cleared.
+func (vS *volumeStruct) fetchAndClearWritten(inodeNumber inode.InodeNumber) (written bool) {
+	if !vS.changeNotificationEnabled() {
+		written = false
+		return
+	}
+
+	vS.changeEventMutex.Lock()
+	_, written = vS.changeEventWrittenMap[inodeNumber]
+	delete(vS.changeEventWrittenMap, inodeNumber)
+	vS.changeEventMutex.Unlock()
+
+	return
+}
+
+// findChangeEventParent returns the directory & basename by which inodeNumber was last reached or, for a
+// DirInode, found by consulting its ".." entry. Callers must not hold any Inode locks.
+func (vS *volumeStruct) findChangeEventParent(inodeNumber inode.InodeNumber, dlmCallerID dlm.CallerID) (parent changeEventParentStruct, ok bool) {
+	var (
+		dirEntry          inode.DirEntry
+		dirEntrySlice     []inode.DirEntry
+		err               error
+		inodeLock         *dlm.RWLockStruct
+		lruElement        *list.Element
+		moreEntries       bool
+		parentInodeLock   *dlm.RWLockStruct
+		parentInodeNumber inode.InodeNumber
+		prevReturned      string
+	)
+
+	vS.changeEventMutex.Lock()
+	lruElement, ok = vS.changeEventParentMap[inodeNumber]
+	if ok {
+		parent = lruElement.Value.(*changeEventParentLRUEntryStruct).parent
+		vS.changeEventParentLRU.MoveToBack(lruElement)
+	}
+	vS.changeEventMutex.Unlock()
+
+	if ok {
+		return
+	}
+
+	inodeLock, err = vS.inodeVolumeHandle.GetReadLock(inodeNumber, dlmCallerID)
+	if nil != err {
+		return
+	}
+	parentInodeNumber, err = vS.inodeVolumeHandle.Lookup(inodeNumber, "..")
+	_ = inodeLock.Unlock()
+	if (nil != err) || (parentInodeNumber == inodeNumber) {
+		// Not a DirInode (or is the RootDirInode)
+		return
+	}
+
+	prevReturned = ""
+	moreEntries = true
+
+	for moreEntries {
+		parentInodeLock, err = vS.inodeVolumeHandle.GetReadLock(parentInodeNumber, dlmCallerID)
+		if nil != err {
+			return
+		}
+
+		if "" == prevReturned {
+			dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(parentInodeNumber, changeEventReadDirMaxEntries, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(parentInodeNumber, changeEventReadDirMaxEntries, 0, prevReturned)
+		}
+
+		_ = parentInodeLock.Unlock()
+
+		if nil != err {
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			prevReturned = dirEntry.Basename
+
+			if (dirEntry.InodeNumber == inodeNumber) && ("." != dirEntry.Basename) && (".." != dirEntry.Basename) {
+				parent = changeEventParentStruct{dirInodeNumber: parentInodeNumber, basename: dirEntry.Basename}
+				ok = true
+				vS.noteChangeEventParent(inodeNumber, parentInodeNumber, dirEntry.Basename)
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// changeEventInodePath returns a path to inodeNumber (or "" if one cannot be resolved) as well as the
+// InodeNumber of the directory containing it.
+func (vS *volumeStruct) changeEventInodePath(inodeNumber inode.InodeNumber) (path string, dirInodeNumber inode.InodeNumber) {
+	var (
+		componentIndex     int
+		components         []string
+		currentInodeNumber inode.InodeNumber
+		dlmCallerID        dlm.CallerID
+		ok                 bool
+		parent             changeEventParentStruct
+	)
+
+	if inode.RootDirInodeNumber == inodeNumber {
+		path = "/"
+		dirInodeNumber = inode.RootDirInodeNumber
+		return
+	}
+
+	dlmCallerID = dlm.GenerateCallerID()
+
+	components = make([]string, 0)
+
+	for currentInodeNumber = inodeNumber; inode.RootDirInodeNumber != currentInodeNumber; currentInodeNumber = parent.dirInodeNumber {
+		if changeEventMaxPathDepth <= len(components) {
+			path = ""
+			dirInodeNumber = 0
+			return
+		}
+
+		parent, ok = vS.findChangeEventParent(currentInodeNumber, dlmCallerID)
+		if !ok {
+			path = ""
+			dirInodeNumber = 0
+			return
+		}
+
+		if 0 == len(components) {
+			dirInodeNumber = parent.dirInodeNumber
+		}
+
+		components = append(components, parent.basename)
+	}
+
+	for componentIndex = 0; componentIndex < len(components)/2; componentIndex++ {
+		components[componentIndex], components[len(components)-1-componentIndex] = components[len(components)-1-componentIndex], components[componentIndex]
+	}
+
+	path = "/" + strings.Join(components, "/")
+
+	return
+}
+
+// changeEventEntryPath returns the path to basename in dirInodeNumber (or "" if one cannot be resolved).
+func (vS *volumeStruct) changeEventEntryPath(dirInodeNumber inode.InodeNumber, basename string) (path string) {
+	var (
+		dirPath string
+	)
+
+	dirPath, _ = vS.changeEventInodePath(dirInodeNumber)
+
+	switch dirPath {
+	case "":
+		path = ""
+	case "/":
+		path = "/" + basename
+	default:
+		path = dirPath + "/" + basename
+	}
+
+	return
+}
+
+// postChangeEvents wakes the volume's ChangeEvent daemon to publish those ChangeEvents added to
+// changeEventPendingList. It is intended to be deferred (prior to obtaining any Inode locks) by
+// operations that may produce ChangeEvents.
+func (vS *volumeStruct) postChangeEvents(changeEventPendingList *changeEventPendingListStruct) {
+	if !changeEventPendingList.added {
+		return
+	}
+
+	select {
+	case vS.changeEventWakeChan <- struct{}{}:
+	default:
+		// The daemon has already been woken
+	}
+}
+
+func (vS *volumeStruct) startChangeEventDaemon() {
+	if !vS.changeNotificationEnabled() {
+		return
+	}
+
+	vS.changeEventEpoch = uint64(time.Now().UnixNano())
+	vS.changeEventBuffer = make([]ChangeEvent, vS.changeEventBufferSize)
+	vS.changeEventNextSequenceNumber = 0
+	vS.changeEventLastSequenceNumber = 0
+	vS.changeEventPendingQueue = make([]changeEventPendingStruct, 0)
+	vS.changeEventArrivedChan = make(chan struct{})
+	vS.changeEventWakeChan = make(chan struct{}, 1)
+	vS.changeEventStopChan = make(chan struct{})
+	vS.changeEventParentMap = make(map[inode.InodeNumber]*list.Element)
+	vS.changeEventParentLRU = list.New()
+	vS.changeEventWrittenMap = make(map[inode.InodeNumber]struct{})
+
+	vS.changeEventWG.Add(1)
+
+	go vS.changeEventDaemon()
+}
+
+func (vS *volumeStruct) stopChangeEventDaemon() {
+	if !vS.changeNotificationEnabled() {
+		return
+	}
+
+	close(vS.changeEventStopChan)
+	vS.changeEventWG.Wait()
+}
+
+// changeEventDaemon publishes queued ChangeEvents (in SequenceNumber order) until stopped.
+func (vS *volumeStruct) changeEventDaemon() {
+	for {
+		select {
+		case <-vS.changeEventWakeChan:
+			vS.publishChangeEvents()
+		case <-vS.changeEventStopChan:
+			vS.changeEventWG.Done()
+			return
+		}
+	}
+}
+
+// publishChangeEvents resolves the paths of each queued ChangeEvent and appends it to the volume's
+// ring buffer until the queue is empty.
+func (vS *volumeStruct) publishChangeEvents() {
+	var (
+		changeEvent       changeEventPendingStruct
+		postedChangeEvent ChangeEvent
+		resolvePaths      bool
+	)
+
+	for {
+		vS.changeEventMutex.Lock()
+
+		if 0 == len(vS.changeEventPendingQueue) {
+			vS.changeEventMutex.Unlock()
+			return
+		}
+
+		changeEvent = vS.changeEventPendingQueue[0]
+		vS.changeEventPendingQueue = vS.changeEventPendingQueue[1:]
+
+		// A ChangeEvent that will be overwritten by those queued behind it isn't worth resolving
+
+		resolvePaths = (uint64(len(vS.changeEventPendingQueue)) < vS.changeEventBufferSize)
+
+		vS.changeEventMutex.Unlock()
+
+		switch changeEvent.changeEventType {
+		case ChangeEventCreate:
+			vS.noteChangeEventParent(changeEvent.inodeNumber, changeEvent.dirInodeNumber, changeEvent.basename)
+		case ChangeEventUnlink:
+			vS.forgetChangeEventParent(changeEvent.inodeNumber, changeEvent.dirInodeNumber, changeEvent.basename)
+		case ChangeEventRename:
+			vS.forgetChangeEventParent(changeEvent.inodeNumber, changeEvent.oldDirInodeNumber, changeEvent.oldBasename)
+			vS.noteChangeEventParent(changeEvent.inodeNumber, changeEvent.dirInodeNumber, changeEvent.basename)
+		}
+
+		postedChangeEvent = ChangeEvent{
+			Epoch:             vS.changeEventEpoch,
+			SequenceNumber:    changeEvent.sequenceNumber,
+			Time:              changeEvent.time,
+			Type:              changeEvent.changeEventType,
+			InodeNumber:       changeEvent.inodeNumber,
+			DirInodeNumber:    changeEvent.dirInodeNumber,
+			Path:              changeEvent.path,
+			OldDirInodeNumber: changeEvent.oldDirInodeNumber,
+		}
+
+		if resolvePaths {
+			if "" == postedChangeEvent.Path {
+				if inode.InodeNumber(0) == changeEvent.dirInodeNumber {
+					postedChangeEvent.Path, postedChangeEvent.DirInodeNumber = vS.changeEventInodePath(changeEvent.inodeNumber)
+				} else {
+					postedChangeEvent.Path = vS.changeEventEntryPath(changeEvent.dirInodeNumber, changeEvent.basename)
+				}
+			}
+
+			if ChangeEventRename == changeEvent.changeEventType {
+				postedChangeEvent.OldPath = vS.changeEventEntryPath(changeEvent.oldDirInodeNumber, changeEvent.oldBasename)
+			}
+		}
+
+		vS.changeEventMutex.Lock()
+
+		vS.changeEventBuffer[(postedChangeEvent.SequenceNumber-1)%vS.changeEventBufferSize] = postedChangeEvent
+		vS.changeEventLastSequenceNumber = postedChangeEvent.SequenceNumber
+
+		close(vS.changeEventArrivedChan)
+		vS.changeEventArrivedChan = make(chan struct{})
+
+		vS.changeEventMutex.Unlock()
+
+		globals.ChangeEventsPosted.Add(1)
+	}
+}
+
+func (vS *volumeStruct) FetchChangeEvents(lastEpoch uint64, lastSequenceNumber uint64, maxEvents uint64, maxWait time.Duration) (changeEvents []ChangeEvent, epoch uint64, latestSequenceNumber uint64, eventsLost bool, err error) {
+	var (
+		changeEventArrivedChan chan struct{}
+		maxWaitTimer           *time.Timer
+		oldestSequenceNumber   uint64
+		sequenceNumber         uint64
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.FetchChangeEventsUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.FetchChangeEventsErrors.Add(1)
+		}
+	}()
+
+	if !vS.changeNotificationEnabled() {
+		err = blunder.NewError(blunder.NotSupportedError, "Change notification not enabled for volume %s", vS.volumeName)
+		return
+	}
+
+	if maxWait > ChangeEventsMaxWait {
+		maxWait = ChangeEventsMaxWait
+	}
+
+	maxWaitTimer = time.NewTimer(maxWait)
+	defer maxWaitTimer.Stop()
+
+	epoch = vS.changeEventEpoch
+
+	if ((0 != lastEpoch) || (0 != lastSequenceNumber)) && (lastEpoch != epoch) {
+		// Sequence numbers have restarted (i.e. the volume has been re-served)... so start over
+
+		lastSequenceNumber = 0
+		eventsLost = true
+	}
+
+	for {
+		vS.changeEventMutex.Lock()
+
+		latestSequenceNumber = vS.changeEventLastSequenceNumber
+
+		if lastSequenceNumber > latestSequenceNumber {
+			// Not a SequenceNumber ever assigned in this Epoch... so start over
+
+			lastSequenceNumber = 0
+			eventsLost = true
+		}
+
+		if lastSequenceNumber < latestSequenceNumber {
+			if latestSequenceNumber > vS.changeEventBufferSize {
+				oldestSequenceNumber = latestSequenceNumber - vS.changeEventBufferSize + 1
+			} else {
+				oldestSequenceNumber = 1
+			}
+
+			if lastSequenceNumber+1 < oldestSequenceNumber {
+				lastSequenceNumber = oldestSequenceNumber - 1
+				eventsLost = true
+			}
+
+			changeEvents = make([]ChangeEvent, 0, latestSequenceNumber-lastSequenceNumber)
+
+			for sequenceNumber = lastSequenceNumber + 1; sequenceNumber <= latestSequenceNumber; sequenceNumber++ {
+				if (0 != maxEvents) && (uint64(len(changeEvents)) == maxEvents) {
+					break
+				}
+				changeEvents = append(changeEvents, vS.changeEventBuffer[(sequenceNumber-1)%vS.changeEventBufferSize])
+			}
+
+			// Report the sequence number of the last ChangeEvent returned so that it may be resumed from
+
+			latestSequenceNumber = sequenceNumber - 1
+
+			vS.changeEventMutex.Unlock()
+
+			err = nil
+			return
+		}
+
+		changeEventArrivedChan = vS.changeEventArrivedChan
+
+		vS.changeEventMutex.Unlock()
+
+		if eventsLost {
+			// Report the restart without waiting
+
+			changeEvents = make([]ChangeEvent, 0)
+			err = nil
+			return
+		}
+
+		select {
+		case <-changeEventArrivedChan:
+			// Loop back to fetch the newly arrived ChangeEvent(s)
+		case <-maxWaitTimer.C:
+			changeEvents = make([]ChangeEvent, 0)
+			err = nil
+			return
+		}
+	}
+}
+
+// addRenameChangeEvents adds the ChangeEvent(s) produced by a successful Move() of srcBasename in
+// srcDirInodeNumber to dstBasename in dstDirInodeNumber. If non-zero, replacedInodeNumber was the
+// Inode previously at dstBasename. Callers must still hold the locks obtained for the Move().
+func (vS *volumeStruct) addRenameChangeEvents(changeEvents *changeEventPendingListStruct, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags inode.MoveFlags, replacedInodeNumber inode.InodeNumber) {
+	var (
+		err                  error
+		exchangedInodeNumber inode.InodeNumber
+		movedInodeNumber     inode.InodeNumber
+	)
+
+	if !vS.changeNotificationEnabled() {
+		return
+	}
+
+	movedInodeNumber, err = vS.inodeVolumeHandle.Lookup(dstDirInodeNumber, dstBasename)
+	if nil != err {
+		return
+	}
+
+	if inode.InodeNumber(0) != replacedInodeNumber {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventUnlink, inodeNumber: replacedInodeNumber, dirInodeNumber: dstDirInodeNumber, basename: dstBasename})
+	}
+
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventRename, inodeNumber: movedInodeNumber, dirInodeNumber: dstDirInodeNumber, basename: dstBasename, oldDirInodeNumber: srcDirInodeNumber, oldBasename: srcBasename})
+
+	if inode.MoveFlagExchange == flags {
+		exchangedInodeNumber, err = vS.inodeVolumeHandle.Lookup(srcDirInodeNumber, srcBasename)
+		if nil != err {
+			return
+		}
+
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventRename, inodeNumber: exchangedInodeNumber, dirInodeNumber: srcDirInodeNumber, basename: srcBasename, oldDirInodeNumber: dstDirInodeNumber, oldBasename: dstBasename})
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+// testWaitForChangeEventsPublished waits for the ChangeEvent daemon to publish through sequenceNumber.
+func testWaitForChangeEventsPublished(t *testing.T, sequenceNumber uint64) {
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		testVolumeStruct.changeEventMutex.Lock()
+		lastSequenceNumber := testVolumeStruct.changeEventLastSequenceNumber
+		testVolumeStruct.changeEventMutex.Unlock()
+
+		if lastSequenceNumber >= sequenceNumber {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ChangeEvents through SequenceNumber %v not published (only %v)", sequenceNumber, lastSequenceNumber)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChangeEvents(t *testing.T) {
+	testSetup(t, false)
+
+	_, _, _, _, err := testVolumeStruct.FetchChangeEvents(0, 0, 0, 0)
+	if !blunder.Is(err, blunder.NotSupportedError) {
+		t.Fatalf("FetchChangeEvents() with change notification disabled should have failed with NotSupportedError - got: %v", err)
+	}
+
+	testVolumeStruct.changeEventBufferSize = 8
+	testVolumeStruct.startChangeEventDaemon()
+
+	epoch := testVolumeStruct.changeEventEpoch
+
+	dirInodeNumber, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "ChangeDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	fileInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, []byte{0x00, 0x01, 0x02, 0x03}, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File", dirInodeNumber, "Renamed", 0)
+	if nil != err {
+		t.Fatalf("Rename() failed: %v", err)
+	}
+	otherInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Other", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Other", dirInodeNumber, "Renamed", 0)
+	if nil != err {
+		t.Fatalf("Rename() over existing entry failed: %v", err)
+	}
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Renamed")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+
+	testWaitForChangeEventsPublished(t, 8)
+
+	changeEvents, fetchedEpoch, latestSequenceNumber, eventsLost, err := testVolumeStruct.FetchChangeEvents(0, 0, 0, 0)
+	if nil != err {
+		t.Fatalf("FetchChangeEvents() failed: %v", err)
+	}
+	if (epoch != fetchedEpoch) || (8 != latestSequenceNumber) || eventsLost || (8 != len(changeEvents)) {
+		t.Fatalf("FetchChangeEvents() returned unexpected epoch (%v), latestSequenceNumber (%v), eventsLost (%v), or len(changeEvents) (%v)", fetchedEpoch, latestSequenceNumber, eventsLost, len(changeEvents))
+	}
+
+	expectedChangeEvents := []ChangeEvent{
+		{Epoch: epoch, SequenceNumber: 1, Type: ChangeEventCreate, InodeNumber: dirInodeNumber, DirInodeNumber: inode.RootDirInodeNumber, Path: "/ChangeDir"},
+		{Epoch: epoch, SequenceNumber: 2, Type: ChangeEventCreate, InodeNumber: fileInodeNumber, DirInodeNumber: dirInodeNumber, Path: "/ChangeDir/File"},
+		{Epoch: epoch, SequenceNumber: 3, Type: ChangeEventWriteClose, InodeNumber: fileInodeNumber, DirInodeNumber: dirInodeNumber, Path: "/ChangeDir/File"},
+		{Epoch: epoch, SequenceNumber: 4, Type: ChangeEventRename, InodeNumber: fileInodeNumber, DirInodeNumber: dirInodeNumber, Path: "/ChangeDir/Renamed", OldDirInodeNumber: dirInodeNumber, OldPath: "/ChangeDir/File"},
+		{Epoch: epoch, SequenceNumber: 5, Type: ChangeEventCreate, InodeNumber: otherInodeNumber, DirInodeNumber: dirInodeNumber, Path: "/ChangeDir/Other"},
+		{Epoch: epoch, SequenceNumber: 6, Type: ChangeEventUnlink, InodeNumber: fileInodeNumber, DirInodeNumber: dirInodeNumber, Path: "/ChangeDir/Renamed"},
+		{Epoch: epoch, SequenceNumber: 7, Type: ChangeEventRename, InodeNumber: otherInodeNumber, DirInodeNumber: dirInodeNumber, Path: "/ChangeDir/Renamed", OldDirInodeNumber: dirInodeNumber, OldPath: "/ChangeDir/Other"},
+		{Epoch: epoch, SequenceNumber: 8, Type: ChangeEventUnlink, InodeNumber: otherInodeNumber, DirInodeNumber: dirInodeNumber, Path: "/ChangeDir/Renamed"},
+	}
+
+	for changeEventIndex, changeEvent := range changeEvents {
+		changeEvent.Time = time.Time{}
+		if expectedChangeEvents[changeEventIndex] != changeEvent {
+			t.Fatalf("FetchChangeEvents() returned unexpected changeEvents[%v]: %+v (expected %+v)", changeEventIndex, changeEvent, expectedChangeEvents[changeEventIndex])
+		}
+	}
+
+	// A limited fetch reports the last ChangeEvent returned as the latest
+
+	changeEvents, _, latestSequenceNumber, _, err = testVolumeStruct.FetchChangeEvents(epoch, 0, 2, 0)
+	if nil != err {
+		t.Fatalf("FetchChangeEvents() failed: %v", err)
+	}
+	if (2 != latestSequenceNumber) || (2 != len(changeEvents)) {
+		t.Fatalf("FetchChangeEvents() with maxEvents == 2 returned unexpected latestSequenceNumber (%v) or len(changeEvents) (%v)", latestSequenceNumber, len(changeEvents))
+	}
+
+	// A consumer resuming from a prior Epoch (i.e. before the volume was re-served) starts over
+
+	changeEvents, _, latestSequenceNumber, eventsLost, err = testVolumeStruct.FetchChangeEvents(epoch-1, 2, 0, 0)
+	if nil != err {
+		t.Fatalf("FetchChangeEvents() failed: %v", err)
+	}
+	if !eventsLost || (8 != latestSequenceNumber) || (8 != len(changeEvents)) {
+		t.Fatalf("FetchChangeEvents() from a prior Epoch returned unexpected latestSequenceNumber (%v), eventsLost (%v), or len(changeEvents) (%v)", latestSequenceNumber, eventsLost, len(changeEvents))
+	}
+
+	// A caught up consumer waits for up to maxWait
+
+	startTime := time.Now()
+	changeEvents, _, latestSequenceNumber, _, err = testVolumeStruct.FetchChangeEvents(epoch, 8, 0, 100*time.Millisecond)
+	if nil != err {
+		t.Fatalf("FetchChangeEvents() failed: %v", err)
+	}
+	if (8 != latestSequenceNumber) || (0 != len(changeEvents)) || (time.Since(startTime) < 100*time.Millisecond) {
+		t.Fatalf("FetchChangeEvents() of caught up consumer should have waited and returned no changeEvents")
+	}
+
+	// ...but returns as soon as a ChangeEvent is published
+
+	fetchDoneChan := make(chan []ChangeEvent, 1)
+	go func() {
+		waitedForChangeEvents, _, _, _, _ := testVolumeStruct.FetchChangeEvents(epoch, 8, 0, time.Minute)
+		fetchDoneChan <- waitedForChangeEvents
+	}()
+
+	_, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Waited", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	select {
+	case changeEvents = <-fetchDoneChan:
+		if (1 != len(changeEvents)) || ("/ChangeDir/Waited" != changeEvents[0].Path) {
+			t.Fatalf("FetchChangeEvents() long-poll returned unexpected changeEvents: %+v", changeEvents)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("FetchChangeEvents() long-poll should have returned upon Create()")
+	}
+
+	// Overrunning the buffer is reported to a lagging consumer
+
+	for fileIndex := 0; fileIndex < 8; fileIndex++ {
+		_, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, fmt.Sprintf("File%v", fileIndex), inode.PosixModePerm)
+		if nil != err {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+
+	testWaitForChangeEventsPublished(t, 17)
+
+	changeEvents, _, latestSequenceNumber, eventsLost, err = testVolumeStruct.FetchChangeEvents(epoch, 8, 0, 0)
+	if nil != err {
+		t.Fatalf("FetchChangeEvents() failed: %v", err)
+	}
+	if (17 != latestSequenceNumber) || !eventsLost || (8 != len(changeEvents)) || (10 != changeEvents[0].SequenceNumber) {
+		t.Fatalf("FetchChangeEvents() of lagging consumer returned unexpected latestSequenceNumber (%v), eventsLost (%v), or changeEvents", latestSequenceNumber, eventsLost)
+	}
+
+	// Remembered parents are evicted least recently used first
+
+	for inodeNumber := inode.InodeNumber(1); inodeNumber <= changeEventParentMapMaxEntries+1; inodeNumber++ {
+		testVolumeStruct.noteChangeEventParent(inodeNumber+0x10000000, dirInodeNumber, "Evictable")
+	}
+
+	testVolumeStruct.changeEventMutex.Lock()
+	parentMapLen := len(testVolumeStruct.changeEventParentMap)
+	_, firstRemembered := testVolumeStruct.changeEventParentMap[0x10000001]
+	_, lastRemembered := testVolumeStruct.changeEventParentMap[0x10000000+changeEventParentMapMaxEntries+1]
+	testVolumeStruct.changeEventMutex.Unlock()
+
+	if (changeEventParentMapMaxEntries != parentMapLen) || firstRemembered || !lastRemembered {
+		t.Fatalf("noteChangeEventParent() should have evicted only the least recently used parent")
+	}
+
+	testVolumeStruct.stopChangeEventDaemon()
+	testVolumeStruct.changeEventBufferSize = 0
+
+	testTeardown(t)
+}
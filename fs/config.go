@@ -30,6 +30,30 @@ type inFlightFileInodeDataStruct struct {
 // Note: There are potentially multiple initiators of this signal
 const inFlightFileInodeDataControlBuffering = 100
 
+const snapShotDiffReadDirMaxEntries = uint64(1024)
+
+// snapShotDiffBatchMinEntries is the fewest differing Inodes whose Paths are found per walk of the namespace
+// by a SnapShotDiff() limited to maxEntries
+const snapShotDiffBatchMinEntries = uint64(1024)
+
+// snapShotDiffCursorsMax limits the number of SnapShotDiff() enumerations retained per volume
+const snapShotDiffCursorsMax = 16
+
+// snapShotDiffCursorKeyStruct identifies the SnapShotDiff() call that would continue an enumeration
+type snapShotDiffCursorKeyStruct struct {
+	fromSnapShotID  uint64
+	toSnapShotID    uint64
+	lastInodeNumber inode.InodeNumber // InodeNumber of the last SnapShotDiffEntry returned
+}
+
+// snapShotDiffCursorStruct retains the remainder of a SnapShotDiff() enumeration that has yet to be returned
+type snapShotDiffCursorStruct struct {
+	key              snapShotDiffCursorKeyStruct
+	diffEntries      []SnapShotDiffEntry // Paths already found
+	moreInodeEntries bool                // if true, Inodes beyond those in diffEntries have yet to be compared
+	lruElement       *list.Element       // Back-pointer to wrapper used to insert into volumeStruct.snapShotDiffCursorLRU
+}
+
 type volumeStruct struct {
 	dataMutex                trackedlock.Mutex
 	volumeName               string
@@ -46,6 +70,58 @@ type volumeStruct struct {
 	jobRWMutex               trackedlock.RWMutex
 	inodeVolumeHandle        inode.VolumeHandle
 	headhunterVolumeHandle   headhunter.VolumeHandle
+
+	dedupRebuildWG sync.WaitGroup // tracks any rebuild of dedup reference counts begun by ServeVolume()
+
+	autoDefragInterval              time.Duration // if == 0, auto-defragmentation is disabled
+	autoDefragInodesPerPass         uint64
+	autoDefragFragmentThreshold     uint64
+	autoDefragBytesTrappedThreshold uint64
+	autoDefragBytesPerSecond        uint64 // if == 0, auto-defragmentation is not throttled
+	autoDefragTimeWindowEnabled     bool
+	autoDefragTimeWindowStart       time.Duration // since midnight
+	autoDefragTimeWindowStop        time.Duration // since midnight
+	autoDefragLastInodeNumber       uint64        // where the next auto-defragmentation pass resumes
+	autoDefragStopChan              chan struct{}
+	autoDefragWG                    sync.WaitGroup
+
+	changeEventMutex              trackedlock.Mutex
+	changeEventBufferSize         uint64        // if == 0, change notification is disabled
+	changeEventEpoch              uint64        // distinguishes this serving of the volume from others
+	changeEventBuffer             []ChangeEvent // ring buffer indexed by (SequenceNumber-1) % changeEventBufferSize
+	changeEventNextSequenceNumber uint64        // last SequenceNumber assigned
+	changeEventLastSequenceNumber uint64        // last SequenceNumber published
+	changeEventPendingQueue       []changeEventPendingStruct
+	changeEventArrivedChan        chan struct{} // closed (and replaced) as each ChangeEvent is published
+	changeEventWakeChan           chan struct{}
+	changeEventStopChan           chan struct{}
+	changeEventWG                 sync.WaitGroup
+	changeEventParentMap          map[inode.InodeNumber]*list.Element // Value is a *changeEventParentLRUEntryStruct
+	changeEventParentLRU          *list.List                          // Front() is least recently used
+	changeEventWrittenMap         map[inode.InodeNumber]struct{}
+
+	recycleBinRetention      time.Duration // if == 0, the RecycleBin is disabled
+	recycleBinPurgeInterval  time.Duration
+	recycleBinDirInodeNumber inode.InodeNumber
+	recycleBinStopChan       chan struct{}
+	recycleBinWG             sync.WaitGroup
+
+	objectVersioning             bool // if false, MiddlewarePutComplete() and MiddlewareDelete() never retain prior versions
+	objectVersionsDirInodeNumber inode.InodeNumber
+
+	multipartMutex            trackedlock.Mutex
+	multipartDirInodeNumber   inode.InodeNumber // if == 0, /<multipartDirName>/ has yet to be established
+	multipartUploadExpiration time.Duration     // if == 0, uploads never expire
+	multipartPurgeInterval    time.Duration
+	multipartStopChan         chan struct{}
+	multipartWG               sync.WaitGroup
+
+	snapShotDiffMutex     trackedlock.Mutex
+	snapShotDiffCursorMap map[snapShotDiffCursorKeyStruct]*snapShotDiffCursorStruct
+	snapShotDiffCursorLRU *list.List // LRU of snapShotDiffCursorMap values (at most snapShotDiffCursorsMax)
+
+	viewChangeMutex     trackedlock.Mutex
+	viewChangeListeners map[ViewChangeListener]struct{}
 }
 
 type tryLockBackoffContextStruct struct {
@@ -164,6 +240,12 @@ type globalsStruct struct {
 	MiddlewarePutCompleteBytes     bucketstats.BucketLog2Round
 	MiddlewarePutContainerUsec     bucketstats.BucketLog2Round
 	MiddlewarePutContainerBytes    bucketstats.BucketLog2Round
+	SnapShotDiffUsec               bucketstats.BucketLog2Round
+	SnapShotDiffEntries            bucketstats.BucketLog2Round
+	SnapShotRestoreUsec            bucketstats.BucketLog2Round
+	SnapShotRevertUsec             bucketstats.BucketLog2Round
+	AutoDefragPassUsec             bucketstats.BucketLog2Round
+	AutoDefragBytes                bucketstats.BucketLog2Round
 
 	CallInodeToProvisionObjectErrors bucketstats.Total
 	MiddlewareCoalesceErrors         bucketstats.Total
@@ -176,11 +258,63 @@ type globalsStruct struct {
 	MiddlewarePostErrors             bucketstats.Total
 	MiddlewarePutCompleteErrors      bucketstats.Total
 	MiddlewarePutContainerErrors     bucketstats.Total
+	SnapShotDiffErrors               bucketstats.Total
+	SnapShotRestoreErrors            bucketstats.Total
+	SnapShotRevertErrors             bucketstats.Total
+	AutoDefragErrors                 bucketstats.Total
+
+	AutoDefragInodesSampled     bucketstats.Total
+	AutoDefragFilesDefragmented bucketstats.Total
+
+	FetchChangeEventsUsec   bucketstats.BucketLog2Round
+	FetchChangeEventsErrors bucketstats.Total
+	ChangeEventsPosted      bucketstats.Total
+
+	RecycleBinListUsec       bucketstats.BucketLog2Round
+	RecycleBinListErrors     bucketstats.Total
+	RecycleBinRestoreUsec    bucketstats.BucketLog2Round
+	RecycleBinRestoreErrors  bucketstats.Total
+	RecycleBinPurgeUsec      bucketstats.BucketLog2Round
+	RecycleBinPurgeErrors    bucketstats.Total
+	RecycleBinInodesRecycled bucketstats.Total
+	RecycleBinInodesPurged   bucketstats.Total
+
+	ObjectVersionsArchived      bucketstats.Total
+	ObjectVersionsDeleteMarkers bucketstats.Total
+	ObjectVersionsDestroyed     bucketstats.Total
+	ObjectVersionsPromoted      bucketstats.Total
+
+	GetWORMStateUsec   bucketstats.BucketLog2Round
+	GetWORMStateErrors bucketstats.Total
+	SetWORMStateUsec   bucketstats.BucketLog2Round
+	SetWORMStateErrors bucketstats.Total
+
+	MiddlewareMultipartAbortUsec       bucketstats.BucketLog2Round
+	MiddlewareMultipartAbortErrors     bucketstats.Total
+	MiddlewareMultipartCompleteUsec    bucketstats.BucketLog2Round
+	MiddlewareMultipartCompleteParts   bucketstats.BucketLog2Round
+	MiddlewareMultipartCompleteErrors  bucketstats.Total
+	MiddlewareMultipartInitiateUsec    bucketstats.BucketLog2Round
+	MiddlewareMultipartInitiateErrors  bucketstats.Total
+	MiddlewareMultipartListPartsUsec   bucketstats.BucketLog2Round
+	MiddlewareMultipartListPartsErrors bucketstats.Total
+	MiddlewareMultipartPutPartUsec     bucketstats.BucketLog2Round
+	MiddlewareMultipartPutPartBytes    bucketstats.BucketLog2Round
+	MiddlewareMultipartPutPartErrors   bucketstats.Total
+
+	RetainedCheckpointInspectUsec   bucketstats.BucketLog2Round
+	RetainedCheckpointInspectErrors bucketstats.Total
+	RetainedCheckpointRevertUsec    bucketstats.BucketLog2Round
+	RetainedCheckpointRevertErrors  bucketstats.Total
 
 	FetchVolumeHandleUsec                   bucketstats.BucketLog2Round
 	FetchVolumeHandleErrors                 bucketstats.BucketLog2Round
 	ValidateVolumeUsec                      bucketstats.BucketLog2Round
 	ScrubVolumeUsec                         bucketstats.BucketLog2Round
+	FindUsec                                bucketstats.BucketLog2Round
+	MigrateLayoutUsec                       bucketstats.BucketLog2Round
+	ExportVolumeUsec                        bucketstats.BucketLog2Round
+	ImportVolumeUsec                        bucketstats.BucketLog2Round
 	ValidateBaseNameUsec                    bucketstats.BucketLog2Round
 	ValidateBaseNameErrors                  bucketstats.Total
 	ValidateFullPathUsec                    bucketstats.BucketLog2Round
@@ -258,6 +392,9 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 		volumeName:               volumeName,
 		FLockMap:                 make(map[inode.InodeNumber]*list.List),
 		inFlightFileInodeDataMap: make(map[inode.InodeNumber]*inFlightFileInodeDataStruct),
+		snapShotDiffCursorMap:    make(map[snapShotDiffCursorKeyStruct]*snapShotDiffCursorStruct),
+		snapShotDiffCursorLRU:    list.New(),
+		viewChangeListeners:      make(map[ViewChangeListener]struct{}),
 	}
 
 	volumeSectionName = "Volume:" + volumeName
@@ -302,6 +439,20 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 		volume.reportedNumInodes = DefaultReportedNumInodes // TODO: Eventually, just return
 	}
 
+	err = volume.fetchAutoDefragConfig(confMap, volumeSectionName)
+	if nil != err {
+		return
+	}
+
+	volume.changeEventBufferSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "ChangeNotificationBufferSize")
+	if nil != err {
+		volume.changeEventBufferSize = 0 // Default to change notification being disabled
+	}
+
+	volume.fetchRecycleBinConfig(confMap, volumeSectionName)
+	volume.fetchObjectVersionsConfig(confMap, volumeSectionName)
+	volume.fetchMultipartConfig(confMap, volumeSectionName)
+
 	volume.inodeVolumeHandle, err = inode.FetchVolumeHandle(volumeName)
 	if nil != err {
 		return
@@ -311,8 +462,19 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 		return
 	}
 
+	volume.startChangeEventDaemon()
+
 	globals.volumeMap[volumeName] = volume
 
+	volume.establishRecycleBin()
+	volume.establishObjectVersions()
+
+	volume.startDedupRebuild()
+
+	volume.startAutoDefragDaemon()
+	volume.startRecycleBinPurgeDaemon()
+	volume.startMultipartPurgeDaemon()
+
 	err = nil
 	return
 }
@@ -330,6 +492,14 @@ func (dummy *globalsStruct) UnserveVolume(confMap conf.ConfMap, volumeName strin
 		return
 	}
 
+	volume.stopMultipartPurgeDaemon()
+	volume.stopRecycleBinPurgeDaemon()
+	volume.stopAutoDefragDaemon()
+
+	volume.dedupRebuildWG.Wait()
+
+	volume.stopChangeEventDaemon()
+
 	volume.untrackInFlightFileInodeDataAll()
 
 	delete(globals.volumeMap, volumeName)
@@ -338,6 +508,25 @@ func (dummy *globalsStruct) UnserveVolume(confMap conf.ConfMap, volumeName strin
 	return
 }
 
+// startDedupRebuild launches, if the dedup B+Tree may no longer reflect the live view (e.g. following
+// the replay of a Replay Log), the rebuild of its reference counts. As this visits every FileInode, it
+// is done in the background (holding off other operations on the volume) rather than in ServeVolume().
+func (vS *volumeStruct) startDedupRebuild() {
+	if !vS.headhunterVolumeHandle.FetchDedupStats().RebuildRequired || vS.headhunterVolumeHandle.IsReplica() {
+		return
+	}
+
+	vS.dedupRebuildWG.Add(1)
+
+	go func() {
+		vS.jobRWMutex.Lock()
+		vS.untrackInFlightFileInodeDataAll()
+		vS.inodeVolumeHandle.RebuildDedupRefCountsIfNecessary()
+		vS.jobRWMutex.Unlock()
+		vS.dedupRebuildWG.Done()
+	}()
+}
+
 func (dummy *globalsStruct) VolumeToBeUnserved(confMap conf.ConfMap, volumeName string) (err error) {
 	return nil
 }
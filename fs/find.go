@@ -0,0 +1,472 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/dlm"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+// A Find job walks the directory tree beneath its starting path examining each Inode against its
+// FindPredicate. Each directory is read a page at a time (and each Inode examined) under a read
+// lock so that the volume remains fully usable during the walk. Sub-directories are walked by
+// separate goroutines while findVolumeParallelism permits... otherwise they are walked inline.
+//
+// Matching Inodes are appended to a bounded results slice awaiting FetchResults(). Should the
+// consumer fall behind, the walk pauses until results are acknowledged (or the job is cancelled).
+// The resultsChangedChan is closed (and replaced) whenever results are appended or acknowledged
+// as well as when the job ends or is cancelled, waking any goroutine waiting on either condition.
+//
+// The walk is performed on behalf of the supplied identity: a directory is only read (and thus
+// its entries reported and sub-directories descended) if that identity has both read and search
+// permission on it. Each directory skipped for lack of permission is reported via Error().
+
+const (
+	findVolumeParallelism        = uint64(32)
+	findVolumeReadDirMaxEntries  = uint64(1024)
+	findVolumeMaxBufferedResults = 4096
+)
+
+type findVolumeStruct struct {
+	jobStruct
+	userID             inode.InodeUserID
+	groupID            inode.InodeGroupID
+	otherGroupIDs      []inode.InodeGroupID
+	path               string
+	predicate          FindPredicate
+	results            []FindResult // results awaiting acknowledgement
+	resultsAcked       uint64       // marker of results[0]
+	resultsChangedChan chan struct{}
+	inodesExamined     uint64
+	inodesMatched      uint64
+}
+
+func (fVS *findVolumeStruct) Cancel() {
+	fVS.Lock()
+	fVS.stopFlag = true
+	fVS.findVolumeResultsChangedWhileLocked()
+	fVS.Unlock()
+
+	fVS.Wait()
+}
+
+func (fVS *findVolumeStruct) FetchResults(marker uint64, maxResults uint64, maxWait time.Duration) (results []FindResult, nextMarker uint64, done bool) {
+	var (
+		maxWaitTimer       *time.Timer
+		numResults         uint64
+		numResultsToAck    uint64
+		resultsChangedChan chan struct{}
+	)
+
+	maxWaitTimer = time.NewTimer(maxWait)
+	defer maxWaitTimer.Stop()
+
+	for {
+		fVS.Lock()
+
+		if marker > fVS.resultsAcked {
+			numResultsToAck = marker - fVS.resultsAcked
+			if numResultsToAck > uint64(len(fVS.results)) {
+				numResultsToAck = uint64(len(fVS.results))
+			}
+
+			fVS.results = fVS.results[numResultsToAck:]
+			fVS.resultsAcked += numResultsToAck
+
+			fVS.findVolumeResultsChangedWhileLocked()
+		}
+
+		nextMarker = fVS.resultsAcked
+
+		if 0 < len(fVS.results) {
+			numResults = uint64(len(fVS.results))
+			if (0 != maxResults) && (numResults > maxResults) {
+				numResults = maxResults
+			}
+
+			results = make([]FindResult, numResults)
+			copy(results, fVS.results[:numResults])
+
+			nextMarker += numResults
+
+			fVS.Unlock()
+
+			done = false
+			return
+		}
+
+		if !fVS.active {
+			fVS.Unlock()
+
+			results = make([]FindResult, 0)
+			done = true
+			return
+		}
+
+		resultsChangedChan = fVS.resultsChangedChan
+
+		fVS.Unlock()
+
+		select {
+		case <-resultsChangedChan:
+			// Loop back to fetch the newly arrived results (or notice the job has ended)
+		case <-maxWaitTimer.C:
+			results = make([]FindResult, 0)
+			done = false
+			return
+		}
+	}
+}
+
+// findVolumeStopped returns whether or not the job has been cancelled.
+func (fVS *findVolumeStruct) findVolumeStopped() (stopped bool) {
+	fVS.Lock()
+	stopped = fVS.stopFlag
+	fVS.Unlock()
+
+	return
+}
+
+func (fVS *findVolumeStruct) findVolumeResultsChangedWhileLocked() {
+	close(fVS.resultsChangedChan)
+	fVS.resultsChangedChan = make(chan struct{})
+}
+
+// findVolumeAppendResult appends result awaiting FetchResults(), first waiting for the consumer to
+// acknowledge prior results if necessary. If the job has been cancelled, false is returned.
+func (fVS *findVolumeStruct) findVolumeAppendResult(result FindResult) (ok bool) {
+	var (
+		resultsChangedChan chan struct{}
+	)
+
+	for {
+		fVS.Lock()
+
+		if fVS.stopFlag {
+			fVS.Unlock()
+			ok = false
+			return
+		}
+
+		if len(fVS.results) < findVolumeMaxBufferedResults {
+			fVS.results = append(fVS.results, result)
+			fVS.inodesMatched++
+			fVS.findVolumeResultsChangedWhileLocked()
+			fVS.Unlock()
+			ok = true
+			return
+		}
+
+		resultsChangedChan = fVS.resultsChangedChan
+
+		fVS.Unlock()
+
+		<-resultsChangedChan
+	}
+}
+
+// findVolumeTryGrabParallelism returns whether or not a parallelism slot was available.
+func (fVS *findVolumeStruct) findVolumeTryGrabParallelism() (grabbed bool) {
+	select {
+	case <-fVS.parallelismChan:
+		grabbed = true
+	default:
+		grabbed = false
+	}
+
+	return
+}
+
+// findVolumeMatch returns whether or not the Inode described by metadata and found at basename
+// satisfies the job's FindPredicate.
+func (fVS *findVolumeStruct) findVolumeMatch(basename string, metadata *inode.MetadataStruct) (match bool) {
+	var (
+		err             error
+		inodeStreamName string
+		inodeType       inode.InodeType
+	)
+
+	if "" != fVS.predicate.NameGlob {
+		match, err = path.Match(fVS.predicate.NameGlob, basename)
+		if (nil != err) || !match {
+			match = false
+			return
+		}
+	}
+
+	if metadata.Size < fVS.predicate.MinSize {
+		match = false
+		return
+	}
+	if (0 != fVS.predicate.MaxSize) && (metadata.Size > fVS.predicate.MaxSize) {
+		match = false
+		return
+	}
+
+	if !fVS.predicate.ModifiedAfter.IsZero() && !metadata.ModificationTime.After(fVS.predicate.ModifiedAfter) {
+		match = false
+		return
+	}
+	if !fVS.predicate.ModifiedBefore.IsZero() && !metadata.ModificationTime.Before(fVS.predicate.ModifiedBefore) {
+		match = false
+		return
+	}
+
+	if fVS.predicate.MatchUserID && (metadata.UserID != fVS.predicate.UserID) {
+		match = false
+		return
+	}
+	if fVS.predicate.MatchGroupID && (metadata.GroupID != fVS.predicate.GroupID) {
+		match = false
+		return
+	}
+
+	if 0 < len(fVS.predicate.InodeTypes) {
+		match = false
+		for _, inodeType = range fVS.predicate.InodeTypes {
+			if metadata.InodeType == inodeType {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return
+		}
+	}
+
+	if "" != fVS.predicate.XAttrName {
+		match = false
+		for _, inodeStreamName = range metadata.InodeStreamNameSlice {
+			if fVS.predicate.XAttrName == inodeStreamName {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return
+		}
+	}
+
+	match = true
+	return
+}
+
+// findVolumeExamineInode reports inodeNumber (found at inodePath) if it satisfies the job's
+// FindPredicate. The InodeType of inodeNumber is returned to inform the caller of the need to
+// descend into it. If the Inode could not be examined (e.g. it has since been removed) or the
+// job has been cancelled, ok == false is returned.
+func (fVS *findVolumeStruct) findVolumeExamineInode(inodeNumber inode.InodeNumber, inodePath string) (inodeType inode.InodeType, ok bool) {
+	var (
+		err       error
+		inodeLock *dlm.RWLockStruct
+		metadata  *inode.MetadataStruct
+	)
+
+	fVS.volume.jobRWMutex.RLock()
+
+	inodeLock, err = fVS.inodeVolumeHandle.GetReadLock(inodeNumber, nil)
+	if nil != err {
+		fVS.volume.jobRWMutex.RUnlock()
+		fVS.jobLogErr("Got GetReadLock(0x%016X) failure: %v", inodeNumber, err)
+		ok = false
+		return
+	}
+
+	metadata, err = fVS.inodeVolumeHandle.GetMetadata(inodeNumber)
+
+	_ = inodeLock.Unlock()
+
+	fVS.volume.jobRWMutex.RUnlock()
+
+	if nil != err {
+		// Most likely removed since the containing directory was read
+		ok = false
+		return
+	}
+
+	fVS.Lock()
+	fVS.inodesExamined++
+	fVS.Unlock()
+
+	inodeType = metadata.InodeType
+
+	if fVS.findVolumeMatch(path.Base(inodePath), metadata) {
+		ok = fVS.findVolumeAppendResult(FindResult{
+			InodeNumber:      inodeNumber,
+			Path:             inodePath,
+			InodeType:        metadata.InodeType,
+			Size:             metadata.Size,
+			ModificationTime: metadata.ModificationTime,
+			UserID:           metadata.UserID,
+			GroupID:          metadata.GroupID,
+		})
+	} else {
+		ok = !fVS.findVolumeStopped()
+	}
+
+	return
+}
+
+// findVolumeDir is launched as a goroutine (holding a parallelism slot) to walk a sub-directory.
+func (fVS *findVolumeStruct) findVolumeDir(dirInodeNumber inode.InodeNumber, dirPath string) {
+	defer fVS.childrenWaitGroup.Done()
+
+	defer fVS.jobReleaseParallelism()
+
+	fVS.findVolumeWalkDir(dirInodeNumber, dirPath)
+}
+
+// findVolumeWalkDir examines each entry of dirInodeNumber, descending into sub-directories.
+func (fVS *findVolumeStruct) findVolumeWalkDir(dirInodeNumber inode.InodeNumber, dirPath string) {
+	var (
+		dirEntry      inode.DirEntry
+		dirEntryPath  string
+		dirEntrySlice []inode.DirEntry
+		dirInodeLock  *dlm.RWLockStruct
+		err           error
+		inodeType     inode.InodeType
+		moreEntries   bool
+		ok            bool
+		prevReturned  string
+	)
+
+	prevReturned = ""
+	moreEntries = true
+
+	for moreEntries {
+		if fVS.findVolumeStopped() {
+			return
+		}
+
+		fVS.volume.jobRWMutex.RLock()
+
+		dirInodeLock, err = fVS.inodeVolumeHandle.GetReadLock(dirInodeNumber, nil)
+		if nil != err {
+			fVS.volume.jobRWMutex.RUnlock()
+			fVS.jobLogErr("Got GetReadLock(0x%016X) failure: %v", dirInodeNumber, err)
+			return
+		}
+
+		if ("" == prevReturned) && !fVS.inodeVolumeHandle.Access(dirInodeNumber, fVS.userID, fVS.groupID, fVS.otherGroupIDs, inode.R_OK|inode.X_OK, inode.NoOverride) {
+			_ = dirInodeLock.Unlock()
+			fVS.volume.jobRWMutex.RUnlock()
+			fVS.jobLogErr("Permission denied reading \"%s\"", dirPath)
+			return
+		}
+
+		if "" == prevReturned {
+			dirEntrySlice, moreEntries, err = fVS.inodeVolumeHandle.ReadDir(dirInodeNumber, findVolumeReadDirMaxEntries, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = fVS.inodeVolumeHandle.ReadDir(dirInodeNumber, findVolumeReadDirMaxEntries, 0, prevReturned)
+		}
+
+		_ = dirInodeLock.Unlock()
+
+		fVS.volume.jobRWMutex.RUnlock()
+
+		if nil != err {
+			// Most likely removed since it was found in its parent directory
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			prevReturned = dirEntry.Basename
+
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+
+			dirEntryPath = path.Join(dirPath, dirEntry.Basename)
+
+			inodeType, ok = fVS.findVolumeExamineInode(dirEntry.InodeNumber, dirEntryPath)
+			if !ok {
+				if fVS.findVolumeStopped() {
+					return
+				}
+				continue
+			}
+
+			if inode.DirType == inodeType {
+				if fVS.findVolumeTryGrabParallelism() {
+					fVS.childrenWaitGroup.Add(1)
+					go fVS.findVolumeDir(dirEntry.InodeNumber, dirEntryPath)
+				} else {
+					fVS.findVolumeWalkDir(dirEntry.InodeNumber, dirEntryPath)
+				}
+			}
+		}
+	}
+}
+
+func (fVS *findVolumeStruct) findVolume() {
+	var (
+		err              error
+		inodeType        inode.InodeType
+		ok               bool
+		startInodeNumber inode.InodeNumber
+	)
+
+	fVS.jobLogInfo("FIND job initiated")
+
+	defer func(fVS *findVolumeStruct) {
+		if fVS.findVolumeStopped() {
+			fVS.jobLogInfo("FIND job stopped")
+		} else if 0 == len(fVS.err) {
+			fVS.jobLogInfo("FIND job completed without error (%v Inodes examined, %v matched)", fVS.inodesExamined, fVS.inodesMatched)
+		} else if 1 == len(fVS.err) {
+			fVS.jobLogInfo("FIND job exited with one error")
+		} else {
+			fVS.jobLogInfo("FIND job exited with errors")
+		}
+	}(fVS)
+
+	defer func(fVS *findVolumeStruct) {
+		fVS.Lock()
+		fVS.active = false
+		fVS.findVolumeResultsChangedWhileLocked()
+		fVS.Unlock()
+	}(fVS)
+
+	defer fVS.globalWaitGroup.Done()
+
+	// Find specified volume
+
+	globals.Lock()
+
+	fVS.volume, ok = globals.volumeMap[fVS.volumeName]
+	if !ok {
+		globals.Unlock()
+		fVS.jobLogErr("Couldn't find fs.volumeStruct")
+		return
+	}
+
+	globals.Unlock()
+
+	fVS.inodeVolumeHandle = fVS.volume.inodeVolumeHandle
+
+	startInodeNumber, err = fVS.volume.LookupPath(fVS.userID, fVS.groupID, fVS.otherGroupIDs, fVS.path)
+	if nil != err {
+		fVS.jobLogErr("Got LookupPath(\"%s\") failure: %v", fVS.path, err)
+		return
+	}
+
+	fVS.path = "/" + strings.Trim(fVS.path, "/")
+
+	inodeType, ok = fVS.findVolumeExamineInode(startInodeNumber, fVS.path)
+	if !ok || (inode.DirType != inodeType) {
+		return
+	}
+
+	fVS.jobStartParallelism(findVolumeParallelism)
+
+	fVS.findVolumeWalkDir(startInodeNumber, fVS.path)
+
+	fVS.childrenWaitGroup.Wait()
+
+	fVS.jobEndParallelism()
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func testFindPaths(t *testing.T, path string, predicate FindPredicate, maxResults uint64) (paths []string) {
+	var (
+		done          bool
+		findJobHandle FindJobHandle
+		marker        uint64
+		results       []FindResult
+	)
+
+	findJobHandle = Find("TestVolume", inode.InodeRootUserID, inode.InodeGroupID(0), nil, path, predicate)
+
+	paths = make([]string, 0)
+
+	for !done {
+		results, marker, done = findJobHandle.FetchResults(marker, maxResults, time.Second)
+		if (0 != maxResults) && (uint64(len(results)) > maxResults) {
+			t.Fatalf("FetchResults() returned more than maxResults (%v) results", maxResults)
+		}
+		for _, result := range results {
+			paths = append(paths, result.Path)
+		}
+	}
+
+	if 0 != len(findJobHandle.Error()) {
+		t.Fatalf("Find(,\"%s\",) reported errors: %v", path, findJobHandle.Error())
+	}
+
+	sort.Strings(paths)
+
+	return
+}
+
+func testFindExpectPaths(t *testing.T, what string, paths []string, expectedPaths []string) {
+	if len(paths) != len(expectedPaths) {
+		t.Fatalf("Find() %s returned %v (expected %v)", what, paths, expectedPaths)
+	}
+	for pathIndex := range paths {
+		if paths[pathIndex] != expectedPaths[pathIndex] {
+			t.Fatalf("Find() %s returned %v (expected %v)", what, paths, expectedPaths)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	testSetup(t, false)
+
+	findDirInodeNumber, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "FindDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	subDirInodeNumber, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, findDirInodeNumber, "Sub", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+
+	for _, file := range []struct {
+		dirInodeNumber inode.InodeNumber
+		basename       string
+		size           int
+	}{
+		{findDirInodeNumber, "a.txt", 10},
+		{findDirInodeNumber, "b.log", 0},
+		{subDirInodeNumber, "c.txt", 100},
+	} {
+		fileInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file.dirInodeNumber, file.basename, inode.PosixModePerm)
+		if nil != err {
+			t.Fatalf("Create() of %s failed: %v", file.basename, err)
+		}
+		if 0 < file.size {
+			_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, make([]byte, file.size), nil)
+			if nil != err {
+				t.Fatalf("Write() to %s failed: %v", file.basename, err)
+			}
+		}
+		if "c.txt" == file.basename {
+			err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, "user.tag", []byte("tagged"), SetXAttrCreateOrReplace)
+			if nil != err {
+				t.Fatalf("SetXAttr() failed: %v", err)
+			}
+		}
+	}
+
+	_, err = testVolumeStruct.Symlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subDirInodeNumber, "link.txt", "../a.txt")
+	if nil != err {
+		t.Fatalf("Symlink() failed: %v", err)
+	}
+
+	testFindExpectPaths(t, "of everything", testFindPaths(t, "/FindDir", FindPredicate{}, 0),
+		[]string{"/FindDir", "/FindDir/Sub", "/FindDir/Sub/c.txt", "/FindDir/Sub/link.txt", "/FindDir/a.txt", "/FindDir/b.log"})
+
+	testFindExpectPaths(t, "with paging", testFindPaths(t, "FindDir/", FindPredicate{}, 1),
+		[]string{"/FindDir", "/FindDir/Sub", "/FindDir/Sub/c.txt", "/FindDir/Sub/link.txt", "/FindDir/a.txt", "/FindDir/b.log"})
+
+	testFindExpectPaths(t, "by NameGlob", testFindPaths(t, "/FindDir", FindPredicate{NameGlob: "*.txt", InodeTypes: []inode.InodeType{inode.FileType}}, 0),
+		[]string{"/FindDir/Sub/c.txt", "/FindDir/a.txt"})
+
+	testFindExpectPaths(t, "by size", testFindPaths(t, "/FindDir", FindPredicate{MinSize: 5, MaxSize: 50, InodeTypes: []inode.InodeType{inode.FileType}}, 0),
+		[]string{"/FindDir/a.txt"})
+
+	testFindExpectPaths(t, "by type", testFindPaths(t, "/FindDir", FindPredicate{InodeTypes: []inode.InodeType{inode.DirType, inode.SymlinkType}}, 0),
+		[]string{"/FindDir", "/FindDir/Sub", "/FindDir/Sub/link.txt"})
+
+	testFindExpectPaths(t, "by xattr", testFindPaths(t, "/FindDir", FindPredicate{XAttrName: "user.tag"}, 0),
+		[]string{"/FindDir/Sub/c.txt"})
+
+	testFindExpectPaths(t, "by owner", testFindPaths(t, "/FindDir", FindPredicate{MatchUserID: true, UserID: inode.InodeUserID(1)}, 0),
+		[]string{})
+
+	testFindExpectPaths(t, "by time", testFindPaths(t, "/FindDir", FindPredicate{ModifiedAfter: time.Now().Add(time.Hour)}, 0),
+		[]string{})
+
+	var results []FindResult
+
+	findJobHandle := Find("TestVolume", inode.InodeRootUserID, inode.InodeGroupID(0), nil, "/NoSuchDir", FindPredicate{})
+	findJobHandle.Wait()
+	if 0 == len(findJobHandle.Error()) {
+		t.Fatalf("Find() of non-existent path should have reported an error")
+	}
+
+	// Directories the caller may not read are skipped (and reported)
+
+	err = testVolumeStruct.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subDirInodeNumber, Stat{StatMode: uint64(0700)})
+	if nil != err {
+		t.Fatalf("Setstat() failed: %v", err)
+	}
+
+	findJobHandle = Find("TestVolume", inode.InodeUserID(1000), inode.InodeGroupID(1000), nil, "/FindDir", FindPredicate{})
+	paths := make([]string, 0)
+	for done, marker := false, uint64(0); !done; {
+		results, marker, done = findJobHandle.FetchResults(marker, 0, time.Second)
+		for _, result := range results {
+			paths = append(paths, result.Path)
+		}
+	}
+	sort.Strings(paths)
+	testFindExpectPaths(t, "by non-owner", paths, []string{"/FindDir", "/FindDir/Sub", "/FindDir/a.txt", "/FindDir/b.log"})
+	if 1 != len(findJobHandle.Error()) {
+		t.Fatalf("Find() by non-owner should have reported one error: %v", findJobHandle.Error())
+	}
+
+	// A cancelled job (even one awaiting acknowledgement of its results) ends
+
+	findJobHandle = Find("TestVolume", inode.InodeRootUserID, inode.InodeGroupID(0), nil, "/", FindPredicate{})
+	results, _, _ = findJobHandle.FetchResults(0, 1, time.Second)
+	if 1 != len(results) {
+		t.Fatalf("FetchResults() should have returned a single result")
+	}
+	findJobHandle.Cancel()
+	if findJobHandle.Active() {
+		t.Fatalf("Find() job should not be active after Cancel()")
+	}
+
+	testTeardown(t)
+}
@@ -209,7 +209,7 @@ func loopOp(fileRequest *testRequest, threadID int, inodeNumber inode.InodeNumbe
 			maxEntries = 10
 			totalEntriesRead = 0 // Useful for debugging
 			for areMoreEntries {
-				containerEnts, err = testVolumeStruct.MiddlewareGetContainer(testDirName, maxEntries, lastBasename, "", "", "")
+				containerEnts, err = testVolumeStruct.MiddlewareGetContainer(testDirName, maxEntries, lastBasename, "", "", "", false)
 				if nil != err {
 					return
 				}
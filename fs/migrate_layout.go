@@ -0,0 +1,285 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"bytes"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/dlm"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+// A MigrateLayout job makes the PhysicalContainerLayoutStreamName Stream of every {Dir|File}Inode beneath
+// its starting directory match that of the starting directory (including its absence). FileInodes having
+// data in LogSegments outside of their (possibly just changed) PhysicalContainerLayout are then rewritten
+// via DefragmentFile() such that their data is moved to PhysicalContainers of that PhysicalContainerLayout.
+// As the check is made of each FileInode's data (rather than only those whose Stream was changed), an
+// interrupted MigrateLayout job may simply be restarted.
+
+const (
+	migrateLayoutFileParallelism   = uint64(8)
+	migrateLayoutReadDirMaxEntries = uint64(1024)
+)
+
+type migrateLayoutStruct struct {
+	jobStruct
+	path                string
+	layoutStreamPresent bool
+	layoutStreamValue   []byte
+	inodesRelabeled     uint64
+	fileInodesMigrated  uint64
+}
+
+// migrateLayoutRelabelInode ensures the PhysicalContainerLayoutStreamName Stream of inodeNumber matches
+// that of the MigrateLayout job's starting directory.
+func (mLS *migrateLayoutStruct) migrateLayoutRelabelInode(inodeNumber inode.InodeNumber) (ok bool) {
+	var (
+		err               error
+		layoutStreamValue []byte
+	)
+
+	layoutStreamValue, err = mLS.volume.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber, inode.PhysicalContainerLayoutStreamName)
+	if nil == err {
+		if mLS.layoutStreamPresent && bytes.Equal(mLS.layoutStreamValue, layoutStreamValue) {
+			ok = true
+			return
+		}
+	} else {
+		if !blunder.Is(err, blunder.StreamNotFound) {
+			mLS.jobLogErr("Got GetXAttr(0x%016X,) failure: %v", inodeNumber, err)
+			ok = false
+			return
+		}
+		if !mLS.layoutStreamPresent {
+			ok = true
+			return
+		}
+	}
+
+	if mLS.layoutStreamPresent {
+		err = mLS.volume.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber, inode.PhysicalContainerLayoutStreamName, mLS.layoutStreamValue, SetXAttrCreateOrReplace)
+	} else {
+		err = mLS.volume.RemoveXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inodeNumber, inode.PhysicalContainerLayoutStreamName)
+	}
+	if nil != err {
+		mLS.jobLogErr("Got {Set|Remove}XAttr(0x%016X,) failure: %v", inodeNumber, err)
+		ok = false
+		return
+	}
+
+	mLS.Lock()
+	mLS.inodesRelabeled++
+	mLS.Unlock()
+
+	ok = true
+	return
+}
+
+// migrateLayoutFileInode is launched as a goroutine (holding a parallelism slot) to move the data
+// of fileInodeNumber to its PhysicalContainerLayout if necessary.
+func (mLS *migrateLayoutStruct) migrateLayoutFileInode(fileInodeNumber inode.InodeNumber) {
+	var (
+		err           error
+		fileInodeLock *dlm.RWLockStruct
+		inLayout      bool
+	)
+
+	defer mLS.childrenWaitGroup.Done()
+
+	defer mLS.jobReleaseParallelism()
+
+	if !mLS.migrateLayoutRelabelInode(fileInodeNumber) {
+		return
+	}
+
+	mLS.volume.jobRWMutex.RLock()
+
+	fileInodeLock, err = mLS.inodeVolumeHandle.GetReadLock(fileInodeNumber, nil)
+	if nil != err {
+		mLS.volume.jobRWMutex.RUnlock()
+		mLS.jobLogErr("Got GetReadLock(0x%016X) failure: %v", fileInodeNumber, err)
+		return
+	}
+
+	inLayout, err = mLS.inodeVolumeHandle.FileInPhysicalContainerLayout(fileInodeNumber)
+
+	_ = fileInodeLock.Unlock()
+
+	mLS.volume.jobRWMutex.RUnlock()
+
+	if nil != err {
+		// Most likely removed since the containing directory was read
+		return
+	}
+
+	if inLayout {
+		return
+	}
+
+	err = mLS.volume.DefragmentFile(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		mLS.jobLogErr("Got DefragmentFile(0x%016X) failure: %v", fileInodeNumber, err)
+		return
+	}
+
+	mLS.Lock()
+	mLS.fileInodesMigrated++
+	mLS.Unlock()
+}
+
+// migrateLayoutWalkDir relabels (and, for FileInodes, migrates) each entry of dirInodeNumber,
+// descending into sub-directories.
+func (mLS *migrateLayoutStruct) migrateLayoutWalkDir(dirInodeNumber inode.InodeNumber) {
+	var (
+		dirEntry      inode.DirEntry
+		dirEntrySlice []inode.DirEntry
+		dirInodeLock  *dlm.RWLockStruct
+		err           error
+		inodeType     inode.InodeType
+		moreEntries   bool
+		prevReturned  string
+	)
+
+	prevReturned = ""
+	moreEntries = true
+
+	for moreEntries {
+		if mLS.stopFlag {
+			return
+		}
+
+		mLS.volume.jobRWMutex.RLock()
+
+		dirInodeLock, err = mLS.inodeVolumeHandle.GetReadLock(dirInodeNumber, nil)
+		if nil != err {
+			mLS.volume.jobRWMutex.RUnlock()
+			mLS.jobLogErr("Got GetReadLock(0x%016X) failure: %v", dirInodeNumber, err)
+			return
+		}
+
+		if "" == prevReturned {
+			dirEntrySlice, moreEntries, err = mLS.inodeVolumeHandle.ReadDir(dirInodeNumber, migrateLayoutReadDirMaxEntries, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = mLS.inodeVolumeHandle.ReadDir(dirInodeNumber, migrateLayoutReadDirMaxEntries, 0, prevReturned)
+		}
+
+		_ = dirInodeLock.Unlock()
+
+		mLS.volume.jobRWMutex.RUnlock()
+
+		if nil != err {
+			// Most likely removed since it was found in its parent directory
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			if mLS.stopFlag {
+				return
+			}
+
+			prevReturned = dirEntry.Basename
+
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+
+			inodeType, err = mLS.inodeVolumeHandle.GetType(dirEntry.InodeNumber)
+			if nil != err {
+				continue
+			}
+
+			switch inodeType {
+			case inode.DirType:
+				if mLS.migrateLayoutRelabelInode(dirEntry.InodeNumber) {
+					mLS.migrateLayoutWalkDir(dirEntry.InodeNumber)
+				}
+			case inode.FileType:
+				mLS.jobGrabParallelism()
+				mLS.childrenWaitGroup.Add(1)
+				go mLS.migrateLayoutFileInode(dirEntry.InodeNumber)
+			}
+		}
+	}
+}
+
+func (mLS *migrateLayoutStruct) migrateLayout() {
+	var (
+		err              error
+		inodeType        inode.InodeType
+		ok               bool
+		startInodeNumber inode.InodeNumber
+	)
+
+	mLS.jobLogInfo("MIGRATE LAYOUT job initiated")
+
+	defer func(mLS *migrateLayoutStruct) {
+		if mLS.stopFlag {
+			mLS.jobLogInfo("MIGRATE LAYOUT job stopped")
+		} else if 0 == len(mLS.err) {
+			mLS.jobLogInfo("MIGRATE LAYOUT job completed without error (%v Inodes relabeled, %v FileInodes migrated)", mLS.inodesRelabeled, mLS.fileInodesMigrated)
+		} else if 1 == len(mLS.err) {
+			mLS.jobLogInfo("MIGRATE LAYOUT job exited with one error")
+		} else {
+			mLS.jobLogInfo("MIGRATE LAYOUT job exited with errors")
+		}
+	}(mLS)
+
+	defer func(mLS *migrateLayoutStruct) {
+		mLS.active = false
+	}(mLS)
+
+	defer mLS.globalWaitGroup.Done()
+
+	// Find specified volume
+
+	globals.Lock()
+
+	mLS.volume, ok = globals.volumeMap[mLS.volumeName]
+	if !ok {
+		globals.Unlock()
+		mLS.jobLogErr("Couldn't find fs.volumeStruct")
+		return
+	}
+
+	globals.Unlock()
+
+	mLS.inodeVolumeHandle = mLS.volume.inodeVolumeHandle
+
+	startInodeNumber, err = mLS.volume.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, mLS.path)
+	if nil != err {
+		mLS.jobLogErr("Got LookupPath(\"%s\") failure: %v", mLS.path, err)
+		return
+	}
+
+	inodeType, err = mLS.inodeVolumeHandle.GetType(startInodeNumber)
+	if nil != err {
+		mLS.jobLogErr("Got GetType(0x%016X) failure: %v", startInodeNumber, err)
+		return
+	}
+	if inode.DirType != inodeType {
+		mLS.jobLogErr("Path \"%s\" is not a directory", mLS.path)
+		return
+	}
+
+	mLS.layoutStreamValue, err = mLS.volume.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, startInodeNumber, inode.PhysicalContainerLayoutStreamName)
+	if nil == err {
+		mLS.layoutStreamPresent = true
+		mLS.jobLogInfo("Migrating \"%s\" to PhysicalContainerLayout \"%s\"", mLS.path, string(mLS.layoutStreamValue))
+	} else if blunder.Is(err, blunder.StreamNotFound) {
+		mLS.layoutStreamPresent = false
+		mLS.jobLogInfo("Migrating \"%s\" to DefaultPhysicalContainerLayout", mLS.path)
+	} else {
+		mLS.jobLogErr("Got GetXAttr(0x%016X,) failure: %v", startInodeNumber, err)
+		return
+	}
+
+	mLS.jobStartParallelism(migrateLayoutFileParallelism)
+
+	mLS.migrateLayoutWalkDir(startInodeNumber)
+
+	mLS.childrenWaitGroup.Wait()
+
+	mLS.jobEndParallelism()
+}
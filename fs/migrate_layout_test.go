@@ -0,0 +1,117 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func testMigrateLayoutExpectContainerNamePrefix(t *testing.T, fileInodeNumber inode.InodeNumber, containerNamePrefix string) {
+	extentMapChunk, err := testVolumeStruct.FetchExtentMapChunk(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, 64, 0)
+	if nil != err {
+		t.Fatalf("FetchExtentMapChunk() failed: %v", err)
+	}
+	if 0 == len(extentMapChunk.ExtentMapEntry) {
+		t.Fatalf("FetchExtentMapChunk() returned no ExtentMapEntries")
+	}
+	for _, extentMapEntry := range extentMapChunk.ExtentMapEntry {
+		if !strings.HasPrefix(extentMapEntry.ContainerName, containerNamePrefix) {
+			t.Fatalf("ExtentMapEntry.ContainerName (\"%s\") should have started with \"%s\"", extentMapEntry.ContainerName, containerNamePrefix)
+		}
+	}
+}
+
+func TestMigrateLayout(t *testing.T) {
+	testSetup(t, false)
+
+	layoutDirInodeNumber, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "LayoutDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+
+	err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, layoutDirInodeNumber, inode.PhysicalContainerLayoutStreamName, []byte("NoSuchLayout"), SetXAttrCreateOrReplace)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("SetXAttr() of unknown PhysicalContainerLayout should have failed with InvalidArgError - got: %v", err)
+	}
+
+	err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, layoutDirInodeNumber, inode.PhysicalContainerLayoutStreamName, []byte("PhysicalContainerLayoutCold"), SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr() failed: %v", err)
+	}
+
+	subDirInodeNumber, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, layoutDirInodeNumber, "Sub", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	fileInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subDirInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	// PhysicalContainerLayout is inherited by newly created Inodes
+
+	layoutStreamValue, err := testVolumeStruct.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, inode.PhysicalContainerLayoutStreamName)
+	if nil != err {
+		t.Fatalf("GetXAttr() failed: %v", err)
+	}
+	if "PhysicalContainerLayoutCold" != string(layoutStreamValue) {
+		t.Fatalf("GetXAttr() returned \"%s\" (expected \"PhysicalContainerLayoutCold\")", string(layoutStreamValue))
+	}
+
+	fileData := bytes.Repeat([]byte{0x5A}, 65536)
+
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, fileData, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	testMigrateLayoutExpectContainerNamePrefix(t, fileInodeNumber, "Cold_")
+
+	// Returning LayoutDir to the DefaultPhysicalContainerLayout migrates File's data
+
+	err = testVolumeStruct.RemoveXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, layoutDirInodeNumber, inode.PhysicalContainerLayoutStreamName)
+	if nil != err {
+		t.Fatalf("RemoveXAttr() failed: %v", err)
+	}
+
+	migrateLayoutHandle := MigrateLayout("TestVolume", "/LayoutDir")
+	migrateLayoutHandle.Wait()
+	if 0 != len(migrateLayoutHandle.Error()) {
+		t.Fatalf("MigrateLayout() reported errors: %v", migrateLayoutHandle.Error())
+	}
+
+	_, err = testVolumeStruct.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, inode.PhysicalContainerLayoutStreamName)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("GetXAttr() after MigrateLayout() should have failed with StreamNotFound - got: %v", err)
+	}
+
+	testMigrateLayoutExpectContainerNamePrefix(t, fileInodeNumber, "Replicated3Way_")
+
+	readData, err := testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, uint64(len(fileData)), nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if !bytes.Equal(fileData, readData) {
+		t.Fatalf("Read() after MigrateLayout() returned unexpected data")
+	}
+
+	// A MigrateLayout of other than a directory fails
+
+	migrateLayoutHandle = MigrateLayout("TestVolume", "/LayoutDir/Sub/File")
+	migrateLayoutHandle.Wait()
+	if 0 == len(migrateLayoutHandle.Error()) {
+		t.Fatalf("MigrateLayout() of a file should have reported an error")
+	}
+
+	testTeardown(t)
+}
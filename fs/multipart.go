@@ -0,0 +1,1109 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/dlm"
+	"github.com/NVIDIA/proxyfs/inode"
+	"github.com/NVIDIA/proxyfs/logger"
+	"github.com/NVIDIA/proxyfs/utils"
+)
+
+// A multipart upload assembles an object from parts ingested independently (and in parallel). Each
+// upload is a DirInode in the volume's /<multipartDirName>/ directory named by its uploadID (its
+// InodeNumber) recording the path ("/<container>/<object>") being uploaded, when the upload was
+// initiated, and (in its MiddlewareStream) the metadata to be applied to the object. Each part is a
+// FileInode within it named by its part number recording its ETag. As parts are only reachable via
+// their upload's DirInode, holding a lock on it suffices to access them.
+//
+// The lock on /<multipartDirName>/ is only ever held briefly (to find, add, or remove an upload) and
+// never while acquiring another lock. Ingesting a part first links an empty FileInode into its upload
+// under a pending name, populates it while holding only a shared lock on the upload, then renames it
+// to its part number. A part is thus always reachable from its upload...should an ingest fail (or the
+// server crash), the pending part is discarded along with the upload. Completing an upload splices the
+// extents of the selected parts into a new FileInode (see inode.Coalesce())...no data is copied...that
+// only then replaces the object's FileInode.
+// Parts not selected (as well as all parts of an aborted upload) are destroyed along with the upload's
+// DirInode. An upload being discarded is first marked (via multipartDoneStreamName) such that it is no
+// longer found even before it is removed from /<multipartDirName>/.
+//
+// Uploads neither completed nor aborted within MultipartUploadExpiration are aborted every
+// MultipartUploadPurgeInterval.
+//
+// Note that /<multipartDirName>/ is only created upon the first MiddlewareMultipartInitiate().
+
+const (
+	multipartDirName           = ".Multipart+Uploads"
+	multipartPathStreamName    = "proxyfs.multipart.path"
+	multipartTimeStreamName    = "proxyfs.multipart.time"
+	multipartDoneStreamName    = "proxyfs.multipart.done"
+	multipartETagStreamName    = "proxyfs.multipart.etag"
+	multipartPendingPartPrefix = ".pending."
+	multipartMaxPartNumber     = uint32(10000)
+	multipartReadDirMaxEntries = uint64(1024)
+)
+
+// fetchMultipartConfig fetches the (optional) MultipartUpload* options for a volume. Note that uploads
+// never expire if MultipartUploadExpiration is specified as zero.
+func (vS *volumeStruct) fetchMultipartConfig(confMap conf.ConfMap, volumeSectionName string) {
+	var (
+		err error
+	)
+
+	vS.multipartUploadExpiration, err = confMap.FetchOptionValueDuration(volumeSectionName, "MultipartUploadExpiration")
+	if nil != err {
+		vS.multipartUploadExpiration = 7 * 24 * time.Hour
+	}
+	vS.multipartPurgeInterval, err = confMap.FetchOptionValueDuration(volumeSectionName, "MultipartUploadPurgeInterval")
+	if (nil != err) || (time.Duration(0) == vS.multipartPurgeInterval) {
+		vS.multipartPurgeInterval = 10 * time.Minute
+	}
+}
+
+func multipartUploadID(uploadDirInodeNumber inode.InodeNumber) (uploadID string) {
+	uploadID = fmt.Sprintf("%016X", uint64(uploadDirInodeNumber))
+	return
+}
+
+func multipartPartName(partNumber uint32) (partName string) {
+	partName = fmt.Sprintf("%05d", partNumber)
+	return
+}
+
+func multipartPendingPartName(partInodeNumber inode.InodeNumber) (pendingPartName string) {
+	pendingPartName = fmt.Sprintf("%s%016X", multipartPendingPartPrefix, uint64(partInodeNumber))
+	return
+}
+func multipartPartNumber(partName string) (partNumber uint32, err error) {
+	var (
+		partNumberAsU64 uint64
+	)
+
+	partNumberAsU64, err = strconv.ParseUint(partName, 10, 32)
+	if nil == err {
+		partNumber = uint32(partNumberAsU64)
+	}
+
+	return
+}
+
+func multipartValidatePartNumber(partNumber uint32) (err error) {
+	if (0 == partNumber) || (multipartMaxPartNumber < partNumber) {
+		err = blunder.NewError(blunder.InvalidArgError, "part number %d must be between 1 and %d", partNumber, multipartMaxPartNumber)
+	}
+	return
+}
+
+func multipartNormalizeETag(eTag string) (normalizedETag string) {
+	normalizedETag = strings.Trim(eTag, "\"")
+	return
+}
+
+// multipartCanonicalPath returns the canonicalized "/<container>/<object>" form of the path recorded
+// in an upload along with the name of its container.
+func multipartCanonicalPath(vContainerName string, vObjectPath string) (uploadPath string, containerName string, err error) {
+	var (
+		pathSplit []string
+	)
+
+	pathSplit, err = canonicalizePath(vContainerName + "/" + vObjectPath)
+	if nil != err {
+		return
+	}
+
+	if 2 > len(pathSplit) {
+		err = blunder.NewError(blunder.InvalidArgError, "\"%s/%s\" does not name an object", vContainerName, vObjectPath)
+		return
+	}
+
+	switch pathSplit[0] {
+	case inode.SnapShotDirName, recycleBinDirName, objectVersionsDirName, multipartDirName:
+		err = blunder.NewError(blunder.InvalidArgError, "\"%s\" is not a container", pathSplit[0])
+		return
+	}
+
+	uploadPath = "/" + strings.Join(pathSplit, "/")
+	containerName = pathSplit[0]
+
+	return
+}
+
+// multipartDir returns the InodeNumber of /<multipartDirName>/...creating it if necessary.
+func (vS *volumeStruct) multipartDir() (multipartDirInodeNumber inode.InodeNumber, err error) {
+	vS.multipartMutex.Lock()
+	defer vS.multipartMutex.Unlock()
+
+	if inode.InodeNumber(0) == vS.multipartDirInodeNumber {
+		vS.multipartDirInodeNumber, err = vS.establishRootDir(multipartDirName)
+		if nil != err {
+			vS.multipartDirInodeNumber = inode.InodeNumber(0)
+			err = blunder.NewError(blunder.NotSupportedError, "multipart uploads of volume %s unavailable: %v", vS.volumeName, err)
+			return
+		}
+	}
+
+	multipartDirInodeNumber = vS.multipartDirInodeNumber
+
+	return
+}
+
+// multipartLookupUpload returns the InodeNumber of the DirInode of the upload named uploadID. The
+// upload must subsequently be verified (see multipartCheckUpload()) once locked.
+func (vS *volumeStruct) multipartLookupUpload(multipartDirInodeNumber inode.InodeNumber, uploadID string) (uploadDirInodeNumber inode.InodeNumber, err error) {
+	var (
+		multipartDirInodeLock *dlm.RWLockStruct
+	)
+
+	multipartDirInodeLock, err = vS.inodeVolumeHandle.GetReadLock(multipartDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+
+	uploadDirInodeNumber, err = vS.inodeVolumeHandle.Lookup(multipartDirInodeNumber, uploadID)
+
+	_ = multipartDirInodeLock.Unlock()
+
+	if nil != err {
+		err = blunder.NewError(blunder.NotFoundError, "no upload %s", uploadID)
+		return
+	}
+
+	return
+}
+
+// multipartCheckUpload verifies that the (locked) DirInode of the upload named uploadID is of
+// uploadPath and is not being discarded.
+func (vS *volumeStruct) multipartCheckUpload(uploadDirInodeNumber inode.InodeNumber, uploadID string, uploadPath string) (err error) {
+	var (
+		pathAsBuf []byte
+	)
+
+	pathAsBuf, err = vS.inodeVolumeHandle.GetStream(uploadDirInodeNumber, multipartPathStreamName)
+	if (nil == err) && (uploadPath == string(pathAsBuf)) {
+		_, err = vS.inodeVolumeHandle.GetStream(uploadDirInodeNumber, multipartDoneStreamName)
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+			return
+		}
+	}
+
+	err = blunder.NewError(blunder.NotFoundError, "%s has no upload %s", uploadPath, uploadID)
+	return
+}
+
+// multipartLockUpload returns the InodeNumber of the DirInode of the upload named uploadID of
+// uploadPath along with a lock on it.
+func (vS *volumeStruct) multipartLockUpload(multipartDirInodeNumber inode.InodeNumber, uploadID string, uploadPath string, exclusive bool) (uploadDirInodeNumber inode.InodeNumber, uploadDirInodeLock *dlm.RWLockStruct, err error) {
+	uploadDirInodeNumber, err = vS.multipartLookupUpload(multipartDirInodeNumber, uploadID)
+	if nil != err {
+		err = blunder.NewError(blunder.NotFoundError, "%s has no upload %s", uploadPath, uploadID)
+		return
+	}
+
+	if exclusive {
+		uploadDirInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(uploadDirInodeNumber, nil)
+	} else {
+		uploadDirInodeLock, err = vS.inodeVolumeHandle.GetReadLock(uploadDirInodeNumber, nil)
+	}
+	if nil != err {
+		return
+	}
+
+	err = vS.multipartCheckUpload(uploadDirInodeNumber, uploadID, uploadPath)
+	if nil != err {
+		_ = uploadDirInodeLock.Unlock()
+		uploadDirInodeLock = nil
+		return
+	}
+
+	return
+}
+
+// multipartDiscardUpload destroys all remaining parts (including pending ones) of the upload as well
+// as its DirInode. Callers hold an exclusive lock on uploadDirInodeNumber. The upload is first marked
+// as being discarded such that, should this fail part way, it is not found and is later purged.
+func (vS *volumeStruct) multipartDiscardUpload(multipartDirInodeNumber inode.InodeNumber, uploadID string, uploadDirInodeNumber inode.InodeNumber) (err error) {
+	var (
+		dirEntry              inode.DirEntry
+		dirEntrySlice         []inode.DirEntry
+		moreEntries           bool
+		multipartDirInodeLock *dlm.RWLockStruct
+	)
+
+	err = vS.inodeVolumeHandle.PutStream(uploadDirInodeNumber, multipartDoneStreamName, []byte{})
+	if nil != err {
+		return
+	}
+
+	moreEntries = true
+
+	for moreEntries {
+		dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(uploadDirInodeNumber, multipartReadDirMaxEntries, 0)
+		if nil != err {
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+
+			_, err = vS.inodeVolumeHandle.Unlink(uploadDirInodeNumber, dirEntry.Basename, false)
+			if nil != err {
+				return
+			}
+
+			err = vS.inodeVolumeHandle.Destroy(dirEntry.InodeNumber)
+			if nil != err {
+				logger.ErrorfWithError(err, "multipart upload %s of volume %s unable to destroy part inode 0x%016X", uploadID, vS.volumeName, dirEntry.InodeNumber)
+			}
+
+			moreEntries = true
+		}
+
+		if 2 >= len(dirEntrySlice) {
+			moreEntries = false
+		}
+	}
+
+	multipartDirInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(multipartDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+
+	_, err = vS.inodeVolumeHandle.Unlink(multipartDirInodeNumber, uploadID, false)
+
+	_ = multipartDirInodeLock.Unlock()
+
+	if nil != err {
+		return
+	}
+
+	err = vS.inodeVolumeHandle.Destroy(uploadDirInodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "multipart upload %s of volume %s unable to destroy its inode", uploadID, vS.volumeName)
+		err = nil
+	}
+
+	return
+}
+
+// multipartReplaceObject coalesces the parts listed in coalesceElementList into a new FileInode with
+// the mode, ownership, and Streams (other than its MiddlewareStream) of fileInodeNumber (named basename
+// in dirInodeNumber). Only once that has succeeded does the new FileInode replace fileInodeNumber (which
+// is then destroyed)...so a failure leaves the object untouched. As other links to fileInodeNumber would
+// not follow the replacement, an object with more than one link is refused. Callers hold exclusive locks
+// on dirInodeNumber and fileInodeNumber.
+func (vS *volumeStruct) multipartReplaceObject(dirInodeNumber inode.InodeNumber, basename string, fileInodeNumber inode.InodeNumber, metadata []byte, coalesceElementList []*inode.CoalesceElement, heldLocks *heldLocksStruct) (newFileInodeNumber inode.InodeNumber, err error) {
+	var (
+		fileMetadata         *inode.MetadataStruct
+		retryRequired        bool
+		streamName           string
+		streamValue          []byte
+		toDestroyInodeNumber inode.InodeNumber
+	)
+
+	fileMetadata, err = vS.inodeVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	if 1 < fileMetadata.LinkCount {
+		err = blunder.NewError(blunder.TooManyLinksError, "multipart upload cannot replace inode 0x%016X with LinkCount %v (> 1)", fileInodeNumber, fileMetadata.LinkCount)
+		return
+	}
+
+	newFileInodeNumber, err = vS.inodeVolumeHandle.CreateFile(fileMetadata.Mode, fileMetadata.UserID, fileMetadata.GroupID)
+	if nil != err {
+		return
+	}
+
+	retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), newFileInodeNumber)
+	if retryRequired {
+		logger.Fatalf("multipartReplaceObject(): failed to exclusively lock just-created Inode 0x%016X", newFileInodeNumber)
+	}
+
+	for _, streamName = range fileMetadata.InodeStreamNameSlice {
+		if MiddlewareStream == streamName {
+			continue
+		}
+
+		streamValue, err = vS.inodeVolumeHandle.GetStream(fileInodeNumber, streamName)
+		if nil == err {
+			err = vS.inodeVolumeHandle.PutStream(newFileInodeNumber, streamName, streamValue)
+		}
+		if nil != err {
+			heldLocks.unlock(newFileInodeNumber)
+			_ = vS.inodeVolumeHandle.Destroy(newFileInodeNumber)
+			return
+		}
+	}
+
+	_, _, _, _, err = vS.inodeVolumeHandle.Coalesce(newFileInodeNumber, MiddlewareStream, metadata, coalesceElementList)
+	if nil != err {
+		heldLocks.unlock(newFileInodeNumber)
+		_ = vS.inodeVolumeHandle.Destroy(newFileInodeNumber)
+		return
+	}
+
+	toDestroyInodeNumber, err = vS.inodeVolumeHandle.Unlink(dirInodeNumber, basename, false)
+	if nil == err {
+		err = vS.inodeVolumeHandle.Link(dirInodeNumber, basename, newFileInodeNumber, false)
+	}
+	if nil != err {
+		logger.Fatalf("multipartReplaceObject(): failed to replace inode 0x%016X named \"%s\" of volume %s: %v", fileInodeNumber, basename, vS.volumeName, err)
+	}
+
+	if inode.InodeNumber(0) != toDestroyInodeNumber {
+		vS.untrackInFlightFileInodeData(fileInodeNumber, false)
+		err = vS.inodeVolumeHandle.Destroy(toDestroyInodeNumber)
+		if nil != err {
+			logger.ErrorfWithError(err, "multipartReplaceObject(): failed to destroy replaced inode 0x%016X of volume %s", toDestroyInodeNumber, vS.volumeName)
+			err = nil
+		}
+	}
+
+	return
+}
+
+func (vS *volumeStruct) MiddlewareMultipartInitiate(vContainerName string, vObjectPath string, metadata []byte) (uploadID string, err error) {
+	var (
+		containerInodeNumber    inode.InodeNumber
+		containerInodeType      inode.InodeType
+		containerName           string
+		multipartDirInodeLock   *dlm.RWLockStruct
+		multipartDirInodeNumber inode.InodeNumber
+		rootInodeLock           *dlm.RWLockStruct
+		uploadDirInodeLock      *dlm.RWLockStruct
+		uploadDirInodeNumber    inode.InodeNumber
+		uploadPath              string
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.MiddlewareMultipartInitiateUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.MiddlewareMultipartInitiateErrors.Add(1)
+		}
+	}()
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	uploadPath, containerName, err = multipartCanonicalPath(vContainerName, vObjectPath)
+	if nil != err {
+		return
+	}
+
+	// The container must already exist
+
+	rootInodeLock, err = vS.inodeVolumeHandle.GetReadLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	containerInodeNumber, err = vS.inodeVolumeHandle.Lookup(inode.RootDirInodeNumber, containerName)
+	if nil == err {
+		containerInodeType, err = vS.inodeVolumeHandle.GetType(containerInodeNumber)
+	}
+	_ = rootInodeLock.Unlock()
+	if (nil != err) || (inode.DirType != containerInodeType) {
+		err = blunder.NewError(blunder.NotFoundError, "container %s not found", containerName)
+		return
+	}
+
+	multipartDirInodeNumber, err = vS.multipartDir()
+	if nil != err {
+		return
+	}
+
+	uploadDirInodeNumber, err = vS.inodeVolumeHandle.CreateDir(inode.InodeMode(0700), inode.InodeRootUserID, inode.InodeGroupID(0))
+	if nil != err {
+		return
+	}
+
+	uploadID = multipartUploadID(uploadDirInodeNumber)
+
+	// Link the upload before recording anything in it such that it is purged should that not complete
+
+	uploadDirInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(uploadDirInodeNumber, nil)
+	if nil != err {
+		_ = vS.inodeVolumeHandle.Destroy(uploadDirInodeNumber)
+		uploadID = ""
+		return
+	}
+	defer uploadDirInodeLock.Unlock()
+
+	multipartDirInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(multipartDirInodeNumber, nil)
+	if nil != err {
+		_ = vS.inodeVolumeHandle.Destroy(uploadDirInodeNumber)
+		uploadID = ""
+		return
+	}
+	err = vS.inodeVolumeHandle.Link(multipartDirInodeNumber, uploadID, uploadDirInodeNumber, false)
+	_ = multipartDirInodeLock.Unlock()
+	if nil != err {
+		_ = vS.inodeVolumeHandle.Destroy(uploadDirInodeNumber)
+		uploadID = ""
+		return
+	}
+
+	err = vS.inodeVolumeHandle.PutStream(uploadDirInodeNumber, multipartTimeStreamName, []byte(time.Now().UTC().Format(time.RFC3339Nano)))
+	if nil == err {
+		err = vS.inodeVolumeHandle.PutStream(uploadDirInodeNumber, MiddlewareStream, metadata)
+	}
+	if nil == err {
+		err = vS.inodeVolumeHandle.PutStream(uploadDirInodeNumber, multipartPathStreamName, []byte(uploadPath))
+	}
+	if nil != err {
+		_ = vS.multipartDiscardUpload(multipartDirInodeNumber, uploadID, uploadDirInodeNumber)
+		uploadID = ""
+		return
+	}
+
+	return
+}
+
+func (vS *volumeStruct) MiddlewareMultipartPutPart(vContainerName string, vObjectPath string, uploadID string, partNumber uint32, pObjectPaths []string, pObjectLengths []uint64, eTag string) (err error) {
+	var (
+		containerName           string
+		fileOffset              uint64
+		inodeWroteTime          time.Time
+		multipartDirInodeNumber inode.InodeNumber
+		objectName              string
+		oldPartInodeNumber      inode.InodeNumber
+		partInodeNumber         inode.InodeNumber
+		pendingPartName         string
+		pObjectIndex            int
+		populateErr             error
+		uploadDirInodeLock      *dlm.RWLockStruct
+		uploadDirInodeNumber    inode.InodeNumber
+		uploadPath              string
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.MiddlewareMultipartPutPartUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		globals.MiddlewareMultipartPutPartBytes.Add(fileOffset)
+		if err != nil {
+			globals.MiddlewareMultipartPutPartErrors.Add(1)
+		}
+	}()
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	err = multipartValidatePartNumber(partNumber)
+	if nil != err {
+		return
+	}
+
+	if len(pObjectPaths) != len(pObjectLengths) {
+		err = blunder.NewError(blunder.InvalidArgError, "MiddlewareMultipartPutPart() expects len(pObjectPaths) == len(pObjectLengths)")
+		return
+	}
+
+	uploadPath, _, err = multipartCanonicalPath(vContainerName, vObjectPath)
+	if nil != err {
+		return
+	}
+
+	multipartDirInodeNumber, err = vS.multipartDir()
+	if nil != err {
+		return
+	}
+
+	// Link an empty part into the upload under a pending name
+
+	uploadDirInodeNumber, uploadDirInodeLock, err = vS.multipartLockUpload(multipartDirInodeNumber, uploadID, uploadPath, true)
+	if nil != err {
+		return
+	}
+
+	partInodeNumber, err = vS.inodeVolumeHandle.CreateFile(inode.InodeMode(0600), inode.InodeRootUserID, inode.InodeGroupID(0))
+	if nil != err {
+		_ = uploadDirInodeLock.Unlock()
+		return
+	}
+
+	pendingPartName = multipartPendingPartName(partInodeNumber)
+
+	err = vS.inodeVolumeHandle.Link(uploadDirInodeNumber, pendingPartName, partInodeNumber, false)
+	_ = uploadDirInodeLock.Unlock()
+	if nil != err {
+		_ = vS.inodeVolumeHandle.Destroy(partInodeNumber)
+		return
+	}
+
+	// Populate the part holding only a shared lock on the upload (so parts are ingested in parallel)
+
+	uploadDirInodeLock, err = vS.inodeVolumeHandle.GetReadLock(uploadDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+
+	populateErr = vS.multipartCheckUpload(uploadDirInodeNumber, uploadID, uploadPath)
+	if nil != populateErr {
+		// The upload (including the pending part) was completed or aborted in the meantime
+		_ = uploadDirInodeLock.Unlock()
+		err = populateErr
+		return
+	}
+
+	inodeWroteTime = time.Now()
+
+	for pObjectIndex = 0; (nil == populateErr) && (pObjectIndex < len(pObjectPaths)); pObjectIndex++ {
+		_, containerName, objectName, populateErr = utils.PathToAcctContObj(pObjectPaths[pObjectIndex])
+		if nil == populateErr {
+			populateErr = vS.inodeVolumeHandle.Wrote(
+				partInodeNumber,
+				containerName,
+				objectName,
+				[]uint64{fileOffset},
+				[]uint64{0},
+				[]uint64{pObjectLengths[pObjectIndex]},
+				inodeWroteTime,
+				pObjectIndex > 0)
+		}
+		if nil == populateErr {
+			fileOffset += pObjectLengths[pObjectIndex]
+		}
+	}
+
+	if nil == populateErr {
+		populateErr = vS.inodeVolumeHandle.PutStream(partInodeNumber, multipartETagStreamName, []byte(multipartNormalizeETag(eTag)))
+	}
+
+	_ = uploadDirInodeLock.Unlock()
+
+	// Now rename the part into place (replacing any prior upload of the same part number) or discard it
+
+	uploadDirInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(uploadDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer uploadDirInodeLock.Unlock()
+
+	err = vS.multipartCheckUpload(uploadDirInodeNumber, uploadID, uploadPath)
+	if nil != err {
+		return
+	}
+
+	if nil != populateErr {
+		_, err = vS.inodeVolumeHandle.Unlink(uploadDirInodeNumber, pendingPartName, false)
+		if nil == err {
+			_ = vS.inodeVolumeHandle.Destroy(partInodeNumber)
+		}
+		err = populateErr
+		return
+	}
+
+	oldPartInodeNumber, err = vS.inodeVolumeHandle.Move(uploadDirInodeNumber, pendingPartName, uploadDirInodeNumber, multipartPartName(partNumber), inode.MoveFlagsNone)
+	if nil != err {
+		return
+	}
+
+	if inode.InodeNumber(0) != oldPartInodeNumber {
+		err = vS.inodeVolumeHandle.Destroy(oldPartInodeNumber)
+		if nil != err {
+			logger.ErrorfWithError(err, "multipart upload %s of volume %s unable to destroy replaced part inode 0x%016X", uploadID, vS.volumeName, oldPartInodeNumber)
+			err = nil
+		}
+	}
+
+	return
+}
+
+func (vS *volumeStruct) MiddlewareMultipartListParts(vContainerName string, vObjectPath string, uploadID string, partNumberMarker uint32, maxParts uint64) (parts []MultipartPart, moreParts bool, err error) {
+	var (
+		dirEntry                inode.DirEntry
+		dirEntrySlice           []inode.DirEntry
+		eTagAsBuf               []byte
+		metadata                *inode.MetadataStruct
+		multipartDirInodeNumber inode.InodeNumber
+		partNumber              uint32
+		readDirMaxEntries       uint64
+		uploadDirInodeLock      *dlm.RWLockStruct
+		uploadDirInodeNumber    inode.InodeNumber
+		uploadPath              string
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.MiddlewareMultipartListPartsUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.MiddlewareMultipartListPartsErrors.Add(1)
+		}
+	}()
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	uploadPath, _, err = multipartCanonicalPath(vContainerName, vObjectPath)
+	if nil != err {
+		return
+	}
+
+	multipartDirInodeNumber, err = vS.multipartDir()
+	if nil != err {
+		return
+	}
+
+	uploadDirInodeNumber, uploadDirInodeLock, err = vS.multipartLockUpload(multipartDirInodeNumber, uploadID, uploadPath, false)
+	if nil != err {
+		return
+	}
+	defer uploadDirInodeLock.Unlock()
+
+	// Note that "." and ".." (if returned) count against readDirMaxEntries
+
+	if (0 == maxParts) || (multipartReadDirMaxEntries < maxParts) {
+		readDirMaxEntries = multipartReadDirMaxEntries
+	} else {
+		readDirMaxEntries = maxParts
+	}
+
+	if 0 == partNumberMarker {
+		dirEntrySlice, moreParts, err = vS.inodeVolumeHandle.ReadDir(uploadDirInodeNumber, readDirMaxEntries, 0)
+	} else {
+		dirEntrySlice, moreParts, err = vS.inodeVolumeHandle.ReadDir(uploadDirInodeNumber, readDirMaxEntries, 0, multipartPartName(partNumberMarker))
+	}
+	if nil != err {
+		return
+	}
+
+	parts = make([]MultipartPart, 0, len(dirEntrySlice))
+
+	for _, dirEntry = range dirEntrySlice {
+		if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+			continue
+		}
+
+		if strings.HasPrefix(dirEntry.Basename, multipartPendingPartPrefix) {
+			// Pending parts sort ahead of all part numbers and are not listed
+			continue
+		}
+
+		partNumber, err = multipartPartNumber(dirEntry.Basename)
+		if nil != err {
+			logger.Errorf("multipart upload %s of volume %s contains unexpected entry \"%s\"", uploadID, vS.volumeName, dirEntry.Basename)
+			continue
+		}
+
+		eTagAsBuf, err = vS.inodeVolumeHandle.GetStream(dirEntry.InodeNumber, multipartETagStreamName)
+		if nil != err {
+			return
+		}
+
+		metadata, err = vS.inodeVolumeHandle.GetMetadata(dirEntry.InodeNumber)
+		if nil != err {
+			return
+		}
+
+		parts = append(parts, MultipartPart{
+			PartNumber:       partNumber,
+			ETag:             string(eTagAsBuf),
+			Size:             metadata.Size,
+			ModificationTime: uint64(metadata.ModificationTime.UnixNano()),
+		})
+	}
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) MiddlewareMultipartComplete(vContainerName string, vObjectPath string, uploadID string, parts []MultipartPart, metadata []byte) (mtime uint64, ctime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+	var (
+		coalesceElementList     []*inode.CoalesceElement
+		dirEntryBasename        string
+		dirEntryInodeNumber     inode.InodeNumber
+		dirInodeNumber          inode.InodeNumber
+		eTagAsBuf               []byte
+		heldLocks               *heldLocksStruct
+		multipartDirInodeNumber inode.InodeNumber
+		part                    MultipartPart
+		partIndex               int
+		partInodeNumber         inode.InodeNumber
+		partName                string
+		retryRequired           bool
+		stat                    Stat
+		tryLockBackoffContext   *tryLockBackoffContextStruct
+		uploadDirInodeNumber    inode.InodeNumber
+		uploadPath              string
+		versionPath             string
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.MiddlewareMultipartCompleteUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		globals.MiddlewareMultipartCompleteParts.Add(uint64(len(parts)))
+		if err != nil {
+			globals.MiddlewareMultipartCompleteErrors.Add(1)
+		}
+	}()
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
+	// Parts must be listed in strictly ascending order of part number
+
+	if 0 == len(parts) {
+		err = blunder.NewError(blunder.InvalidArgError, "MiddlewareMultipartComplete() requires at least one part")
+		return
+	}
+
+	for partIndex, part = range parts {
+		err = multipartValidatePartNumber(part.PartNumber)
+		if nil != err {
+			return
+		}
+		if (0 < partIndex) && (parts[partIndex-1].PartNumber >= part.PartNumber) {
+			err = blunder.NewError(blunder.InvalidArgError, "part number %d out of order", part.PartNumber)
+			return
+		}
+	}
+
+	uploadPath, _, err = multipartCanonicalPath(vContainerName, vObjectPath)
+	if nil != err {
+		return
+	}
+
+	multipartDirInodeNumber, err = vS.multipartDir()
+	if nil != err {
+		return
+	}
+
+	versionPath = vS.objectVersionsPath(vContainerName + "/" + vObjectPath)
+
+	// Retry until done or failure (starting with ZERO backoff)
+
+	tryLockBackoffContext = &tryLockBackoffContextStruct{}
+
+Restart:
+
+	// Perform backoff and update for each restart (starting with ZERO backoff of course)
+
+	tryLockBackoffContext.backoff()
+
+	// Construct fresh heldLocks for this restart
+
+	heldLocks = newHeldLocks()
+
+	// Only the upload itself (not /<multipartDirName>/) remains locked while completing it
+
+	uploadDirInodeNumber, err = vS.multipartLookupUpload(multipartDirInodeNumber, uploadID)
+	if nil != err {
+		heldLocks.free()
+		err = blunder.NewError(blunder.NotFoundError, "%s has no upload %s", uploadPath, uploadID)
+		return
+	}
+
+	retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), uploadDirInodeNumber)
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	err = vS.multipartCheckUpload(uploadDirInodeNumber, uploadID, uploadPath)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	// Validate each part (before touching the namespace)
+
+	coalesceElementList = make([]*inode.CoalesceElement, 0, len(parts))
+
+	for _, part = range parts {
+		partName = multipartPartName(part.PartNumber)
+
+		partInodeNumber, err = vS.inodeVolumeHandle.Lookup(uploadDirInodeNumber, partName)
+		if nil != err {
+			heldLocks.free()
+			err = blunder.NewError(blunder.InvalidArgError, "part number %d of upload %s not found", part.PartNumber, uploadID)
+			return
+		}
+
+		eTagAsBuf, err = vS.inodeVolumeHandle.GetStream(partInodeNumber, multipartETagStreamName)
+		if (nil != err) || (multipartNormalizeETag(part.ETag) != string(eTagAsBuf)) {
+			heldLocks.free()
+			err = blunder.NewError(blunder.InvalidArgError, "part number %d of upload %s has a different ETag", part.PartNumber, uploadID)
+			return
+		}
+
+		coalesceElementList = append(coalesceElementList, &inode.CoalesceElement{
+			ContainingDirectoryInodeNumber: uploadDirInodeNumber,
+			ElementInodeNumber:             partInodeNumber,
+			ElementName:                    partName,
+		})
+	}
+
+	if nil == metadata {
+		metadata, err = vS.inodeVolumeHandle.GetStream(uploadDirInodeNumber, MiddlewareStream)
+		if nil != err {
+			if blunder.Is(err, blunder.StreamNotFound) {
+				metadata = []byte{}
+			} else {
+				heldLocks.free()
+				return
+			}
+		}
+	}
+
+	// Resolve the object just as a PUT would
+
+	dirInodeNumber, dirEntryInodeNumber, dirEntryBasename, retryRequired, err =
+		vS.middlewarePutCompleteHelper(vContainerName, vObjectPath, versionPath, heldLocks)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	// Splice the parts into a new FileInode that then replaces the object's
+
+	dirEntryInodeNumber, err = vS.multipartReplaceObject(dirInodeNumber, dirEntryBasename, dirEntryInodeNumber, metadata, coalesceElementList, heldLocks)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	// Discard any parts not selected along with the upload itself
+
+	err = vS.multipartDiscardUpload(multipartDirInodeNumber, uploadID, uploadDirInodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "multipart upload %s of volume %s unable to be discarded", uploadID, vS.volumeName)
+	}
+
+	stat, err = vS.getstatHelperWhileLocked(dirEntryInodeNumber)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	mtime = stat[StatMTime]
+	ctime = stat[StatCTime]
+	fileInodeNumber = dirEntryInodeNumber
+	numWrites = stat[StatNumWrites]
+
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventWriteClose, inodeNumber: dirEntryInodeNumber, dirInodeNumber: dirInodeNumber, basename: dirEntryBasename})
+
+	heldLocks.free()
+	return
+}
+
+func (vS *volumeStruct) MiddlewareMultipartAbort(vContainerName string, vObjectPath string, uploadID string) (err error) {
+	var (
+		multipartDirInodeNumber inode.InodeNumber
+		uploadDirInodeLock      *dlm.RWLockStruct
+		uploadDirInodeNumber    inode.InodeNumber
+		uploadPath              string
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.MiddlewareMultipartAbortUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.MiddlewareMultipartAbortErrors.Add(1)
+		}
+	}()
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	uploadPath, _, err = multipartCanonicalPath(vContainerName, vObjectPath)
+	if nil != err {
+		return
+	}
+
+	multipartDirInodeNumber, err = vS.multipartDir()
+	if nil != err {
+		return
+	}
+
+	uploadDirInodeNumber, uploadDirInodeLock, err = vS.multipartLockUpload(multipartDirInodeNumber, uploadID, uploadPath, true)
+	if nil != err {
+		return
+	}
+	defer uploadDirInodeLock.Unlock()
+
+	err = vS.multipartDiscardUpload(multipartDirInodeNumber, uploadID, uploadDirInodeNumber)
+
+	return
+}
+
+func (vS *volumeStruct) startMultipartPurgeDaemon() {
+	if time.Duration(0) == vS.multipartUploadExpiration {
+		vS.multipartStopChan = nil
+		return
+	}
+
+	vS.multipartStopChan = make(chan struct{})
+	vS.multipartWG.Add(1)
+
+	go vS.multipartPurgeDaemon()
+}
+
+func (vS *volumeStruct) stopMultipartPurgeDaemon() {
+	if nil == vS.multipartStopChan {
+		return
+	}
+
+	close(vS.multipartStopChan)
+	vS.multipartWG.Wait()
+
+	vS.multipartStopChan = nil
+}
+
+func (vS *volumeStruct) multipartPurgeDaemon() {
+	defer vS.multipartWG.Done()
+
+	for {
+		select {
+		case <-vS.multipartStopChan:
+			return
+		case <-time.After(vS.multipartPurgeInterval):
+			vS.multipartPurgeExpired()
+		}
+	}
+}
+
+// multipartPurgeExpired aborts all uploads initiated more than MultipartUploadExpiration ago. Uploads
+// left behind by a failed initiate or discard are also purged.
+func (vS *volumeStruct) multipartPurgeExpired() {
+	var (
+		dirEntry                inode.DirEntry
+		dirEntrySlice           []inode.DirEntry
+		err                     error
+		moreEntries             bool
+		multipartDirInodeLock   *dlm.RWLockStruct
+		multipartDirInodeNumber inode.InodeNumber
+		olderThan               time.Time
+		prevBasename            string
+		rootInodeLock           *dlm.RWLockStruct
+	)
+
+	olderThan = time.Now().Add(-vS.multipartUploadExpiration)
+
+	// Avoid creating /<multipartDirName>/ if no upload was ever initiated
+
+	rootInodeLock, err = vS.inodeVolumeHandle.GetReadLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		logger.ErrorfWithError(err, "multipart uploads of volume %s unable to lock /", vS.volumeName)
+		return
+	}
+	_, err = vS.inodeVolumeHandle.Lookup(inode.RootDirInodeNumber, multipartDirName)
+	_ = rootInodeLock.Unlock()
+	if nil != err {
+		return
+	}
+
+	multipartDirInodeNumber, err = vS.multipartDir()
+	if nil != err {
+		logger.ErrorfWithError(err, "multipart uploads of volume %s unable to be purged", vS.volumeName)
+		return
+	}
+
+	prevBasename = ""
+	moreEntries = true
+
+	for moreEntries {
+		multipartDirInodeLock, err = vS.inodeVolumeHandle.GetReadLock(multipartDirInodeNumber, nil)
+		if nil != err {
+			logger.ErrorfWithError(err, "multipart uploads of volume %s unable to be listed", vS.volumeName)
+			return
+		}
+		if "" == prevBasename {
+			dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(multipartDirInodeNumber, multipartReadDirMaxEntries, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(multipartDirInodeNumber, multipartReadDirMaxEntries, 0, prevBasename)
+		}
+		_ = multipartDirInodeLock.Unlock()
+		if nil != err {
+			logger.ErrorfWithError(err, "multipart uploads of volume %s unable to be listed", vS.volumeName)
+			return
+		}
+
+		if 0 == len(dirEntrySlice) {
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			select {
+			case <-vS.multipartStopChan:
+				return
+			default:
+			}
+
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+
+			vS.jobRWMutex.RLock()
+			err = vS.multipartPurgeUpload(multipartDirInodeNumber, dirEntry.Basename, dirEntry.InodeNumber, olderThan)
+			vS.jobRWMutex.RUnlock()
+			if nil != err {
+				logger.WarnfWithError(err, "multipart upload %s of volume %s unable to be purged", dirEntry.Basename, vS.volumeName)
+			}
+		}
+
+		prevBasename = dirEntrySlice[len(dirEntrySlice)-1].Basename
+	}
+}
+
+// multipartPurgeUpload discards the upload named uploadID if it was initiated before olderThan (or if
+// it was never fully initiated or was partially discarded).
+func (vS *volumeStruct) multipartPurgeUpload(multipartDirInodeNumber inode.InodeNumber, uploadID string, uploadDirInodeNumber inode.InodeNumber, olderThan time.Time) (err error) {
+	var (
+		initiateTime       time.Time
+		timeAsBuf          []byte
+		uploadDirInodeLock *dlm.RWLockStruct
+	)
+
+	uploadDirInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(uploadDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer uploadDirInodeLock.Unlock()
+
+	_, err = vS.inodeVolumeHandle.GetStream(uploadDirInodeNumber, multipartPathStreamName)
+	if nil == err {
+		_, err = vS.inodeVolumeHandle.GetStream(uploadDirInodeNumber, multipartDoneStreamName)
+		if blunder.Is(err, blunder.StreamNotFound) {
+			timeAsBuf, err = vS.inodeVolumeHandle.GetStream(uploadDirInodeNumber, multipartTimeStreamName)
+			if nil != err {
+				return
+			}
+			initiateTime, err = time.Parse(time.RFC3339Nano, string(timeAsBuf))
+			if nil != err {
+				return
+			}
+			if !initiateTime.Before(olderThan) {
+				return
+			}
+		} else if nil != err {
+			return
+		}
+	} else if !blunder.Is(err, blunder.StreamNotFound) {
+		// Presumably the upload was discarded since it was listed
+		err = nil
+		return
+	}
+
+	err = vS.multipartDiscardUpload(multipartDirInodeNumber, uploadID, uploadDirInodeNumber)
+
+	return
+}
@@ -0,0 +1,298 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func testMultipartPutPart(t *testing.T, uploadID string, partNumber uint32, length uint64, eTag string) (pObjectPath string) {
+	pObjectPath, err := testVolumeStruct.CallInodeToProvisionObject()
+	if nil != err {
+		t.Fatalf("CallInodeToProvisionObject() failed: %v", err)
+	}
+	err = testVolumeStruct.MiddlewareMultipartPutPart("Container", "Dir/Object", uploadID, partNumber, []string{pObjectPath}, []uint64{length}, eTag)
+	if nil != err {
+		t.Fatalf("MiddlewareMultipartPutPart(,,,%d,,,) failed: %v", partNumber, err)
+	}
+	return
+}
+
+func TestMultipart(t *testing.T) {
+	testSetup(t, false)
+
+	err := testVolumeStruct.MiddlewarePutContainer("Container", []byte(""), []byte("{}"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() failed: %v", err)
+	}
+
+	_, err = testVolumeStruct.MiddlewareMultipartInitiate("NoSuchContainer", "Object", []byte("metadata"))
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareMultipartInitiate() in missing container should have failed with NotFoundError: %v", err)
+	}
+
+	uploadID, err := testVolumeStruct.MiddlewareMultipartInitiate("Container", "Dir/Object", []byte("metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewareMultipartInitiate() failed: %v", err)
+	}
+
+	// Parts may arrive in any order and be replaced...none are visible in the namespace
+
+	pObjectPath3 := testMultipartPutPart(t, uploadID, 3, 300, "etag3")
+	_ = testMultipartPutPart(t, uploadID, 1, 111, "etag1-old")
+	pObjectPath1 := testMultipartPutPart(t, uploadID, 1, 100, "\"etag1\"")
+	_ = testMultipartPutPart(t, uploadID, 2, 200, "etag2")
+
+	err = testVolumeStruct.MiddlewareMultipartPutPart("Container", "Dir/Object", uploadID, 0, []string{}, []uint64{}, "etag0")
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewareMultipartPutPart() of part 0 should have failed with InvalidArgError: %v", err)
+	}
+	err = testVolumeStruct.MiddlewareMultipartPutPart("Container", "Other", uploadID, 1, []string{}, []uint64{}, "etag1")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareMultipartPutPart() to a different object should have failed with NotFoundError: %v", err)
+	}
+
+	// A part that fails to be ingested leaves nothing behind
+
+	err = testVolumeStruct.MiddlewareMultipartPutPart("Container", "Dir/Object", uploadID, 4, []string{"NotAnObjectPath"}, []uint64{400}, "etag4")
+	if nil == err {
+		t.Fatalf("MiddlewareMultipartPutPart() of a bad pObjectPath should have failed")
+	}
+
+	uploadDirInodeNumber, err := testVolumeStruct.multipartLookupUpload(testVolumeStruct.multipartDirInodeNumber, uploadID)
+	if nil != err {
+		t.Fatalf("multipartLookupUpload() failed: %v", err)
+	}
+	dirEntrySlice, _, err := testVolumeStruct.inodeVolumeHandle.ReadDir(uploadDirInodeNumber, 0, 0)
+	if (nil != err) || (5 != len(dirEntrySlice)) {
+		t.Fatalf("upload should only contain \".\", \"..\", and parts 1, 2, and 3 (err: %v)", err)
+	}
+
+	_, err = testVolumeStruct.MiddlewareHeadResponse("Container/Dir/Object", 0)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareHeadResponse() of incomplete upload should have failed with NotFoundError: %v", err)
+	}
+
+	parts, moreParts, err := testVolumeStruct.MiddlewareMultipartListParts("Container", "Dir/Object", uploadID, 0, 0)
+	if nil != err {
+		t.Fatalf("MiddlewareMultipartListParts() failed: %v", err)
+	}
+	if moreParts || (3 != len(parts)) {
+		t.Fatalf("MiddlewareMultipartListParts() returned %d parts (moreParts: %v)", len(parts), moreParts)
+	}
+	if (1 != parts[0].PartNumber) || ("etag1" != parts[0].ETag) || (100 != parts[0].Size) ||
+		(2 != parts[1].PartNumber) || (3 != parts[2].PartNumber) || (300 != parts[2].Size) {
+		t.Fatalf("MiddlewareMultipartListParts() returned unexpected parts %+v", parts)
+	}
+
+	parts, _, err = testVolumeStruct.MiddlewareMultipartListParts("Container", "Dir/Object", uploadID, 1, 0)
+	if (nil != err) || (2 != len(parts)) || (2 != parts[0].PartNumber) {
+		t.Fatalf("MiddlewareMultipartListParts() following part 1 returned %+v (err: %v)", parts, err)
+	}
+
+	// Completion validates order and ETags without disturbing the upload
+
+	_, _, _, _, err = testVolumeStruct.MiddlewareMultipartComplete("Container", "Dir/Object", uploadID, []MultipartPart{{PartNumber: 3, ETag: "etag3"}, {PartNumber: 1, ETag: "etag1"}}, nil)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewareMultipartComplete() with parts out of order should have failed with InvalidArgError: %v", err)
+	}
+	_, _, _, _, err = testVolumeStruct.MiddlewareMultipartComplete("Container", "Dir/Object", uploadID, []MultipartPart{{PartNumber: 1, ETag: "etag1-old"}}, nil)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewareMultipartComplete() with wrong ETag should have failed with InvalidArgError: %v", err)
+	}
+	_, _, _, _, err = testVolumeStruct.MiddlewareMultipartComplete("Container", "Dir/Object", uploadID, []MultipartPart{{PartNumber: 1, ETag: "etag1"}, {PartNumber: 4, ETag: "etag4"}}, nil)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("MiddlewareMultipartComplete() with missing part should have failed with InvalidArgError: %v", err)
+	}
+
+	// Complete (omitting part 2) and verify the object is the concatenation of the selected parts
+
+	_, _, fileInodeNumber, _, err := testVolumeStruct.MiddlewareMultipartComplete("Container", "Dir/Object", uploadID, []MultipartPart{{PartNumber: 1, ETag: "\"etag1\""}, {PartNumber: 3, ETag: "etag3"}}, []byte("completed-metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewareMultipartComplete() failed: %v", err)
+	}
+
+	readRangeOut := []inode.ReadPlanStep{}
+	response, err := testVolumeStruct.MiddlewareGetObject("Container/Dir/Object", 0, []ReadRangeIn{}, &readRangeOut)
+	if nil != err {
+		t.Fatalf("MiddlewareGetObject() failed: %v", err)
+	}
+	if (fileInodeNumber != response.InodeNumber) || (400 != response.FileSize) || ("completed-metadata" != string(response.Metadata)) {
+		t.Fatalf("MiddlewareGetObject() returned unexpected response %+v", response)
+	}
+	if (2 != len(readRangeOut)) ||
+		(pObjectPath1 != readRangeOut[0].ObjectPath) || (100 != readRangeOut[0].Length) ||
+		(pObjectPath3 != readRangeOut[1].ObjectPath) || (300 != readRangeOut[1].Length) {
+		t.Fatalf("MiddlewareGetObject() returned unexpected read plan %+v", readRangeOut)
+	}
+
+	_, _, err = testVolumeStruct.MiddlewareMultipartListParts("Container", "Dir/Object", uploadID, 0, 0)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareMultipartListParts() of completed upload should have failed with NotFoundError: %v", err)
+	}
+
+	// A failed completion over an existing object leaves it untouched...a successful one retains its xattrs
+
+	err = testVolumeStruct.inodeVolumeHandle.PutStream(fileInodeNumber, "user.test", []byte("xattr"))
+	if nil != err {
+		t.Fatalf("PutStream() failed: %v", err)
+	}
+
+	uploadID, err = testVolumeStruct.MiddlewareMultipartInitiate("Container", "Dir/Object", []byte("metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewareMultipartInitiate() failed: %v", err)
+	}
+	pObjectPath2 := testMultipartPutPart(t, uploadID, 1, 200, "etag1")
+
+	uploadDirInodeNumber, err = testVolumeStruct.multipartLookupUpload(testVolumeStruct.multipartDirInodeNumber, uploadID)
+	if nil != err {
+		t.Fatalf("multipartLookupUpload() failed: %v", err)
+	}
+	partInodeNumber, err := testVolumeStruct.inodeVolumeHandle.Lookup(uploadDirInodeNumber, multipartPartName(1))
+	if nil != err {
+		t.Fatalf("Lookup() of part 1 failed: %v", err)
+	}
+	err = testVolumeStruct.inodeVolumeHandle.Link(uploadDirInodeNumber, "ExtraLink", partInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Link() failed: %v", err)
+	}
+
+	_, _, _, _, err = testVolumeStruct.MiddlewareMultipartComplete("Container", "Dir/Object", uploadID, []MultipartPart{{PartNumber: 1, ETag: "etag1"}}, []byte("replaced-metadata"))
+	if !blunder.Is(err, blunder.TooManyLinksError) {
+		t.Fatalf("MiddlewareMultipartComplete() of a part with multiple links should have failed with TooManyLinksError: %v", err)
+	}
+
+	response, err = testVolumeStruct.MiddlewareHeadResponse("Container/Dir/Object", 0)
+	if nil != err {
+		t.Fatalf("MiddlewareHeadResponse() failed: %v", err)
+	}
+	if (fileInodeNumber != response.InodeNumber) || (400 != response.FileSize) || ("completed-metadata" != string(response.Metadata)) {
+		t.Fatalf("MiddlewareMultipartComplete() failure should have left object untouched: %+v", response)
+	}
+
+	_, err = testVolumeStruct.inodeVolumeHandle.Unlink(uploadDirInodeNumber, "ExtraLink", false)
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+
+	_, _, newFileInodeNumber, _, err := testVolumeStruct.MiddlewareMultipartComplete("Container", "Dir/Object", uploadID, []MultipartPart{{PartNumber: 1, ETag: "etag1"}}, []byte("replaced-metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewareMultipartComplete() failed: %v", err)
+	}
+
+	readRangeOut = []inode.ReadPlanStep{}
+	response, err = testVolumeStruct.MiddlewareGetObject("Container/Dir/Object", 0, []ReadRangeIn{}, &readRangeOut)
+	if nil != err {
+		t.Fatalf("MiddlewareGetObject() failed: %v", err)
+	}
+	if (newFileInodeNumber != response.InodeNumber) || (200 != response.FileSize) || ("replaced-metadata" != string(response.Metadata)) {
+		t.Fatalf("MiddlewareGetObject() returned unexpected response %+v", response)
+	}
+	if (1 != len(readRangeOut)) || (pObjectPath2 != readRangeOut[0].ObjectPath) || (200 != readRangeOut[0].Length) {
+		t.Fatalf("MiddlewareGetObject() returned unexpected read plan %+v", readRangeOut)
+	}
+
+	streamValue, err := testVolumeStruct.inodeVolumeHandle.GetStream(newFileInodeNumber, "user.test")
+	if (nil != err) || ("xattr" != string(streamValue)) {
+		t.Fatalf("MiddlewareMultipartComplete() should have retained xattr \"user.test\" (err: %v)", err)
+	}
+
+	_, err = testVolumeStruct.inodeVolumeHandle.GetType(fileInodeNumber)
+	if nil == err {
+		t.Fatalf("MiddlewareMultipartComplete() should have destroyed the replaced FileInode")
+	}
+
+	// Aborting discards the upload along with its parts (including one left pending by a crash)
+
+	uploadID, err = testVolumeStruct.MiddlewareMultipartInitiate("Container", "Dir/Object", []byte("metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewareMultipartInitiate() failed: %v", err)
+	}
+	_ = testMultipartPutPart(t, uploadID, 1, 100, "etag1")
+
+	uploadDirInodeNumber, err = testVolumeStruct.multipartLookupUpload(testVolumeStruct.multipartDirInodeNumber, uploadID)
+	if nil != err {
+		t.Fatalf("multipartLookupUpload() failed: %v", err)
+	}
+	pendingPartInodeNumber, err := testVolumeStruct.inodeVolumeHandle.CreateFile(inode.InodeMode(0600), inode.InodeRootUserID, inode.InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeStruct.inodeVolumeHandle.Link(uploadDirInodeNumber, multipartPendingPartName(pendingPartInodeNumber), pendingPartInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Link() failed: %v", err)
+	}
+
+	parts, _, err = testVolumeStruct.MiddlewareMultipartListParts("Container", "Dir/Object", uploadID, 0, 0)
+	if (nil != err) || (1 != len(parts)) || (1 != parts[0].PartNumber) {
+		t.Fatalf("MiddlewareMultipartListParts() should not have listed a pending part: %+v (err: %v)", parts, err)
+	}
+
+	err = testVolumeStruct.MiddlewareMultipartAbort("Container", "Dir/Object", uploadID)
+	if nil != err {
+		t.Fatalf("MiddlewareMultipartAbort() failed: %v", err)
+	}
+	err = testVolumeStruct.MiddlewareMultipartAbort("Container", "Dir/Object", uploadID)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareMultipartAbort() of aborted upload should have failed with NotFoundError: %v", err)
+	}
+
+	_, err = testVolumeStruct.inodeVolumeHandle.GetType(pendingPartInodeNumber)
+	if nil == err {
+		t.Fatalf("MiddlewareMultipartAbort() should have destroyed the pending part")
+	}
+
+	dirEntrySlice, _, err = testVolumeStruct.inodeVolumeHandle.ReadDir(testVolumeStruct.multipartDirInodeNumber, 0, 0)
+	if (nil != err) || (2 != len(dirEntrySlice)) {
+		t.Fatalf("/%s should only contain \".\" and \"..\" (err: %v)", multipartDirName, err)
+	}
+
+	// Uploads initiated more than MultipartUploadExpiration ago are purged
+
+	uploadID, err = testVolumeStruct.MiddlewareMultipartInitiate("Container", "Dir/Object", []byte("metadata"))
+	if nil != err {
+		t.Fatalf("MiddlewareMultipartInitiate() failed: %v", err)
+	}
+	_ = testMultipartPutPart(t, uploadID, 1, 100, "etag1")
+
+	testVolumeStruct.multipartPurgeExpired()
+
+	_, _, err = testVolumeStruct.MiddlewareMultipartListParts("Container", "Dir/Object", uploadID, 0, 0)
+	if nil != err {
+		t.Fatalf("multipartPurgeExpired() should not have purged an unexpired upload: %v", err)
+	}
+
+	savedMultipartUploadExpiration := testVolumeStruct.multipartUploadExpiration
+	testVolumeStruct.multipartUploadExpiration = time.Nanosecond
+
+	testVolumeStruct.multipartPurgeExpired()
+
+	testVolumeStruct.multipartUploadExpiration = savedMultipartUploadExpiration
+
+	_, _, err = testVolumeStruct.MiddlewareMultipartListParts("Container", "Dir/Object", uploadID, 0, 0)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareMultipartListParts() of purged upload should have failed with NotFoundError: %v", err)
+	}
+
+	dirEntrySlice, _, err = testVolumeStruct.inodeVolumeHandle.ReadDir(testVolumeStruct.multipartDirInodeNumber, 0, 0)
+	if (nil != err) || (2 != len(dirEntrySlice)) {
+		t.Fatalf("/%s should only contain \".\" and \"..\" after multipartPurgeExpired() (err: %v)", multipartDirName, err)
+	}
+
+	accountEnts, _, _, err := testVolumeStruct.MiddlewareGetAccount(0, "", "")
+	if nil != err {
+		t.Fatalf("MiddlewareGetAccount() failed: %v", err)
+	}
+	for _, accountEntry := range accountEnts {
+		if multipartDirName == accountEntry.Basename {
+			t.Fatalf("MiddlewareGetAccount() should not have returned /%s", multipartDirName)
+		}
+	}
+
+	testTeardown(t)
+}
@@ -0,0 +1,1064 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/dlm"
+	"github.com/NVIDIA/proxyfs/inode"
+	"github.com/NVIDIA/proxyfs/logger"
+)
+
+// When a volume's ObjectVersioning is true, any container whose (Swift) metadata sets the
+// X-Versions-Enabled header to "true" retains the prior versions of its objects. Rather than
+// overwriting the FileInode of an existing object, MiddlewarePutComplete() links it into the
+// volume's /<objectVersionsDirName>/ directory and writes into a fresh FileInode. Similarly,
+// MiddlewareDelete() moves the current FileInode there and records a (zero length) delete marker
+// in its place. No file data is copied... archived FileInodes continue to reference the same
+// LogSegments. The exception is a FileInode also reachable via other (hard) links. As an archived
+// version must not change, a copy of it is archived instead.
+//
+// An object version is identified by its InodeNumber. It is linked into /<objectVersionsDirName>/<container>/
+// named by the SHA-256 digest of its object name followed by its InodeNumber. Thus the versions of
+// an object are adjacent and may be enumerated without visiting those of other objects. Streams
+// record the path ("/<container>/<object>") from which it was archived and when. Filesystem clients
+// see only the current version at that path.
+
+const (
+	objectVersionsDirName                = ".Object+Versions"
+	objectVersionsPathStreamName         = "proxyfs.version.path"
+	objectVersionsTimeStreamName         = "proxyfs.version.time"
+	objectVersionsDeleteMarkerStreamName = "proxyfs.version.deletemarker"
+	objectVersionsEnabledHeader          = "X-Versions-Enabled"
+	objectVersionsReadDirMaxEntries      = uint64(1024)
+	objectVersionsCopyChunkSize          = uint64(1024 * 1024)
+)
+
+type objectVersionStruct struct {
+	dirInodeNumber inode.InodeNumber // of /<objectVersionsDirName>/<container>/
+	name           string            // of the entry in /<objectVersionsDirName>/<container>/
+	inodeNumber    inode.InodeNumber
+	path           string // "/<container>/<object>" from which the version was archived
+	time           time.Time
+	deleteMarker   bool
+}
+
+// fetchObjectVersionsConfig fetches the (optional) ObjectVersioning option for a volume.
+func (vS *volumeStruct) fetchObjectVersionsConfig(confMap conf.ConfMap, volumeSectionName string) {
+	var (
+		err error
+	)
+
+	vS.objectVersioning, err = confMap.FetchOptionValueBool(volumeSectionName, "ObjectVersioning")
+	if nil != err {
+		vS.objectVersioning = false // Default to object versioning being disabled
+	}
+}
+
+// establishObjectVersions locates (creating if necessary) /<objectVersionsDirName>/. Should that
+// fail, object versioning is disabled.
+func (vS *volumeStruct) establishObjectVersions() {
+	var (
+		err error
+	)
+
+	if !vS.objectVersioning {
+		return
+	}
+
+	vS.objectVersionsDirInodeNumber, err = vS.establishRootDir(objectVersionsDirName)
+	if nil != err {
+		logger.ErrorfWithError(err, "ObjectVersioning of volume %s disabled", vS.volumeName)
+		vS.objectVersioning = false
+	}
+}
+
+// objectVersionsCanonicalPath returns the canonicalized form of path (e.g. "/<container>/<object>")
+// for recording with a version of the object there or "" if path cannot name an object.
+func objectVersionsCanonicalPath(path string) (versionPath string) {
+	var (
+		err       error
+		pathSplit []string
+	)
+
+	pathSplit, err = canonicalizePath(path)
+	if (nil != err) || (2 > len(pathSplit)) || (objectVersionsDirName == pathSplit[0]) || (recycleBinDirName == pathSplit[0]) {
+		versionPath = ""
+		return
+	}
+
+	versionPath = "/" + strings.Join(pathSplit, "/")
+
+	return
+}
+
+// objectVersionsPath returns the canonicalized form of path if the object there is in a container
+// retaining prior versions, else "". Callers must not hold any Inode locks.
+func (vS *volumeStruct) objectVersionsPath(path string) (versionPath string) {
+	var (
+		containerInodeLock   *dlm.RWLockStruct
+		containerInodeNumber inode.InodeNumber
+		containerMetadata    map[string]interface{}
+		err                  error
+		metadataAsBuf        []byte
+		rootInodeLock        *dlm.RWLockStruct
+		versionsEnabled      bool
+	)
+
+	if !vS.objectVersioning {
+		versionPath = ""
+		return
+	}
+
+	versionPath = objectVersionsCanonicalPath(path)
+	if "" == versionPath {
+		return
+	}
+
+	rootInodeLock, err = vS.inodeVolumeHandle.GetReadLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		versionPath = ""
+		return
+	}
+	containerInodeNumber, err = vS.inodeVolumeHandle.Lookup(inode.RootDirInodeNumber, strings.SplitN(versionPath[1:], "/", 2)[0])
+	_ = rootInodeLock.Unlock()
+	if nil != err {
+		versionPath = ""
+		return
+	}
+
+	containerInodeLock, err = vS.inodeVolumeHandle.GetReadLock(containerInodeNumber, nil)
+	if nil != err {
+		versionPath = ""
+		return
+	}
+	metadataAsBuf, err = vS.inodeVolumeHandle.GetStream(containerInodeNumber, MiddlewareStream)
+	_ = containerInodeLock.Unlock()
+	if nil != err {
+		versionPath = ""
+		return
+	}
+
+	err = json.Unmarshal(metadataAsBuf, &containerMetadata)
+	if nil != err {
+		versionPath = ""
+		return
+	}
+
+	switch value := containerMetadata[objectVersionsEnabledHeader].(type) {
+	case bool:
+		versionsEnabled = value
+	case string:
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "true", "1", "yes", "on":
+			versionsEnabled = true
+		}
+	}
+
+	if !versionsEnabled {
+		versionPath = ""
+	}
+
+	return
+}
+
+// objectVersionsSplitPath splits versionPath (as returned by objectVersionsCanonicalPath()) into the
+// names of its container and its object.
+func objectVersionsSplitPath(versionPath string) (containerName string, objectName string) {
+	var (
+		pathSplit []string
+	)
+
+	pathSplit = strings.SplitN(versionPath[1:], "/", 2)
+
+	containerName = pathSplit[0]
+	objectName = pathSplit[1]
+
+	return
+}
+
+// objectVersionsEntryPrefix returns the prefix shared by the names of every version of objectName
+// in /<objectVersionsDirName>/<container>/.
+func objectVersionsEntryPrefix(objectName string) (prefix string) {
+	prefix = fmt.Sprintf("%x.", sha256.Sum256([]byte(objectName)))
+	return
+}
+
+func objectVersionsEntryName(objectName string, inodeNumber inode.InodeNumber) (name string) {
+	name = objectVersionsEntryPrefix(objectName) + fmt.Sprintf("%016X", uint64(inodeNumber))
+	return
+}
+
+// objectVersionsContainerDir locates (creating if requested) /<objectVersionsDirName>/<container>/.
+// Callers hold a lock on /<objectVersionsDirName>/ (an exclusive one if create is set).
+func (vS *volumeStruct) objectVersionsContainerDir(containerName string, create bool) (dirInodeNumber inode.InodeNumber, err error) {
+	dirInodeNumber, err = vS.inodeVolumeHandle.Lookup(vS.objectVersionsDirInodeNumber, containerName)
+	if (nil == err) || !create || !blunder.Is(err, blunder.NotFoundError) {
+		return
+	}
+
+	dirInodeNumber, err = vS.inodeVolumeHandle.CreateDir(inode.InodeMode(0700), inode.InodeRootUserID, inode.InodeGroupID(0))
+	if nil != err {
+		return
+	}
+
+	err = vS.inodeVolumeHandle.Link(vS.objectVersionsDirInodeNumber, containerName, dirInodeNumber, false)
+	if nil != err {
+		_ = vS.inodeVolumeHandle.Destroy(dirInodeNumber)
+	}
+
+	return
+}
+
+// objectVersionsLink records versionPath (and the current time) in inodeNumber and links it into
+// /<objectVersionsDirName>/<container>/. Callers hold an exclusive lock on /<objectVersionsDirName>/
+// but must not hold one on /<objectVersionsDirName>/<container>/.
+func (vS *volumeStruct) objectVersionsLink(inodeNumber inode.InodeNumber, versionPath string) (err error) {
+	var (
+		containerName  string
+		dirInodeLock   *dlm.RWLockStruct
+		dirInodeNumber inode.InodeNumber
+		objectName     string
+	)
+
+	containerName, objectName = objectVersionsSplitPath(versionPath)
+
+	dirInodeNumber, err = vS.objectVersionsContainerDir(containerName, true)
+	if nil != err {
+		return
+	}
+
+	dirInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer dirInodeLock.Unlock()
+
+	err = vS.inodeVolumeHandle.PutStream(inodeNumber, objectVersionsPathStreamName, []byte(versionPath))
+	if nil != err {
+		return
+	}
+	err = vS.inodeVolumeHandle.PutStream(inodeNumber, objectVersionsTimeStreamName, []byte(time.Now().UTC().Format(time.RFC3339Nano)))
+	if nil != err {
+		return
+	}
+	err = vS.inodeVolumeHandle.Link(dirInodeNumber, objectVersionsEntryName(objectName, inodeNumber), inodeNumber, false)
+	return
+}
+
+// copyObjectVersion creates a FileInode holding a copy of the data and Streams of fileInodeNumber.
+// Callers hold an exclusive lock on fileInodeNumber.
+func (vS *volumeStruct) copyObjectVersion(fileInodeNumber inode.InodeNumber) (copyInodeNumber inode.InodeNumber, err error) {
+	var (
+		buf        []byte
+		length     uint64
+		metadata   *inode.MetadataStruct
+		offset     uint64
+		streamName string
+	)
+
+	metadata, err = vS.inodeVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	copyInodeNumber, err = vS.inodeVolumeHandle.CreateFile(metadata.Mode, metadata.UserID, metadata.GroupID)
+	if nil != err {
+		return
+	}
+
+	for offset = 0; offset < metadata.Size; offset += length {
+		length = metadata.Size - offset
+		if length > objectVersionsCopyChunkSize {
+			length = objectVersionsCopyChunkSize
+		}
+
+		buf, err = vS.inodeVolumeHandle.Read(fileInodeNumber, offset, length, nil)
+		if nil == err {
+			err = vS.inodeVolumeHandle.Write(copyInodeNumber, offset, buf, nil)
+		}
+		if nil != err {
+			_ = vS.inodeVolumeHandle.Destroy(copyInodeNumber)
+			return
+		}
+	}
+
+	err = vS.inodeVolumeHandle.SetSize(copyInodeNumber, metadata.Size)
+	if nil == err {
+		err = vS.inodeVolumeHandle.Flush(copyInodeNumber, false)
+	}
+	if nil != err {
+		_ = vS.inodeVolumeHandle.Destroy(copyInodeNumber)
+		return
+	}
+
+	for _, streamName = range metadata.InodeStreamNameSlice {
+		buf, err = vS.inodeVolumeHandle.GetStream(fileInodeNumber, streamName)
+		if nil == err {
+			err = vS.inodeVolumeHandle.PutStream(copyInodeNumber, streamName, buf)
+		}
+		if nil != err {
+			_ = vS.inodeVolumeHandle.Destroy(copyInodeNumber)
+			return
+		}
+	}
+
+	err = vS.inodeVolumeHandle.SetModificationTime(copyInodeNumber, metadata.ModificationTime)
+	if nil != err {
+		_ = vS.inodeVolumeHandle.Destroy(copyInodeNumber)
+	}
+
+	return
+}
+
+// archiveObjectVersion retains fileInodeNumber as a prior version of the object at versionPath. If
+// fileInodeNumber is also reachable via other (hard) links, a copy of it is retained instead.
+// Callers hold an exclusive lock on fileInodeNumber but must not hold one on /<objectVersionsDirName>/.
+func (vS *volumeStruct) archiveObjectVersion(fileInodeNumber inode.InodeNumber, versionPath string) (err error) {
+	var (
+		archivedInodeNumber  inode.InodeNumber
+		linkCount            uint64
+		versionsDirInodeLock *dlm.RWLockStruct
+	)
+
+	linkCount, err = vS.inodeVolumeHandle.GetLinkCount(fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	if 1 < linkCount {
+		archivedInodeNumber, err = vS.copyObjectVersion(fileInodeNumber)
+		if nil != err {
+			logger.ErrorfWithError(err, "ObjectVersioning of volume %s unable to copy inode 0x%016X (\"%s\")", vS.volumeName, fileInodeNumber, versionPath)
+			return
+		}
+	} else {
+		archivedInodeNumber = fileInodeNumber
+	}
+
+	versionsDirInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(vS.objectVersionsDirInodeNumber, nil)
+	if nil != err {
+		if archivedInodeNumber != fileInodeNumber {
+			_ = vS.inodeVolumeHandle.Destroy(archivedInodeNumber)
+		}
+		return
+	}
+	defer versionsDirInodeLock.Unlock()
+
+	err = vS.objectVersionsLink(archivedInodeNumber, versionPath)
+	if nil != err {
+		logger.ErrorfWithError(err, "ObjectVersioning of volume %s unable to archive inode 0x%016X (\"%s\")", vS.volumeName, fileInodeNumber, versionPath)
+		if archivedInodeNumber != fileInodeNumber {
+			_ = vS.inodeVolumeHandle.Destroy(archivedInodeNumber)
+		}
+		return
+	}
+
+	globals.ObjectVersionsArchived.Add(1)
+
+	return
+}
+
+// replaceObjectVersion archives fileInodeNumber (the current version of the object at versionPath
+// named basename in dirInodeNumber) and links a fresh FileInode in its place. If fileInodeNumber has
+// never been written (e.g. it was just created by resolvePath()), there is nothing to retain and it
+// is returned unchanged. Callers hold exclusive locks (recorded in heldLocks) on dirInodeNumber and
+// fileInodeNumber but must not hold one on /<objectVersionsDirName>/.
+func (vS *volumeStruct) replaceObjectVersion(dirInodeNumber inode.InodeNumber, basename string, fileInodeNumber inode.InodeNumber, versionPath string, heldLocks *heldLocksStruct) (newFileInodeNumber inode.InodeNumber, err error) {
+	var (
+		hasMiddlewareStream bool
+		metadata            *inode.MetadataStruct
+		retryRequired       bool
+		streamName          string
+	)
+
+	metadata, err = vS.inodeVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	for _, streamName = range metadata.InodeStreamNameSlice {
+		if MiddlewareStream == streamName {
+			hasMiddlewareStream = true
+		}
+	}
+
+	if (0 == metadata.NumWrites) && !hasMiddlewareStream {
+		newFileInodeNumber = fileInodeNumber
+		return
+	}
+
+	newFileInodeNumber, err = vS.inodeVolumeHandle.CreateFile(metadata.Mode, metadata.UserID, metadata.GroupID)
+	if nil != err {
+		return
+	}
+
+	retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), newFileInodeNumber)
+	if retryRequired {
+		logger.Fatalf("replaceObjectVersion(): failed to exclusively lock just-created Inode 0x%016X", newFileInodeNumber)
+	}
+
+	err = vS.inheritStreams(dirInodeNumber, newFileInodeNumber, inode.FileType)
+	if nil == err {
+		err = vS.archiveObjectVersion(fileInodeNumber, versionPath)
+	}
+	if nil != err {
+		heldLocks.unlock(newFileInodeNumber)
+		_ = vS.inodeVolumeHandle.Destroy(newFileInodeNumber)
+		return
+	}
+
+	// fileInodeNumber remains linked into /<objectVersionsDirName>/<container>/ (or, if a copy of it
+	// was archived, elsewhere) so will not be destroyed
+
+	_, err = vS.inodeVolumeHandle.Unlink(dirInodeNumber, basename, false)
+	if nil == err {
+		err = vS.inodeVolumeHandle.Link(dirInodeNumber, basename, newFileInodeNumber, false)
+	}
+	if nil != err {
+		logger.Fatalf("replaceObjectVersion(): failed to replace inode 0x%016X at \"%s\" of volume %s: %v", fileInodeNumber, versionPath, vS.volumeName, err)
+	}
+
+	return
+}
+
+// retireObjectVersion archives fileInodeNumber (the current version of the object at versionPath
+// named basename in dirInodeNumber), unlinks it, and records a delete marker as the newest version.
+// Callers hold exclusive locks on dirInodeNumber and fileInodeNumber but must not hold one on
+// /<objectVersionsDirName>/.
+func (vS *volumeStruct) retireObjectVersion(dirInodeNumber inode.InodeNumber, basename string, fileInodeNumber inode.InodeNumber, versionPath string) (err error) {
+	var (
+		deleteMarkerInodeNumber inode.InodeNumber
+		versionsDirInodeLock    *dlm.RWLockStruct
+	)
+
+	err = vS.archiveObjectVersion(fileInodeNumber, versionPath)
+	if nil != err {
+		return
+	}
+
+	_, err = vS.inodeVolumeHandle.Unlink(dirInodeNumber, basename, false)
+	if nil != err {
+		logger.Fatalf("retireObjectVersion(): failed to unlink inode 0x%016X at \"%s\" of volume %s: %v", fileInodeNumber, versionPath, vS.volumeName, err)
+	}
+
+	versionsDirInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(vS.objectVersionsDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer versionsDirInodeLock.Unlock()
+
+	deleteMarkerInodeNumber, err = vS.inodeVolumeHandle.CreateFile(inode.InodeMode(0600), inode.InodeRootUserID, inode.InodeGroupID(0))
+	if nil != err {
+		return
+	}
+
+	err = vS.inodeVolumeHandle.PutStream(deleteMarkerInodeNumber, objectVersionsDeleteMarkerStreamName, []byte("true"))
+	if nil == err {
+		err = vS.objectVersionsLink(deleteMarkerInodeNumber, versionPath)
+	}
+	if nil != err {
+		logger.ErrorfWithError(err, "ObjectVersioning of volume %s unable to record delete marker for \"%s\"", vS.volumeName, versionPath)
+		_ = vS.inodeVolumeHandle.Destroy(deleteMarkerInodeNumber)
+		return
+	}
+
+	globals.ObjectVersionsDeleteMarkers.Add(1)
+
+	return
+}
+
+// fetchObjectVersion returns the objectVersionStruct describing the entry name in dirInodeNumber
+// (i.e. /<objectVersionsDirName>/<container>/) referencing inodeNumber. As the Streams describing a
+// version are only modified while holding an exclusive lock on /<objectVersionsDirName>/, callers
+// need only hold a lock on it.
+func (vS *volumeStruct) fetchObjectVersion(dirInodeNumber inode.InodeNumber, name string, inodeNumber inode.InodeNumber) (version objectVersionStruct, err error) {
+	var (
+		pathAsBuf []byte
+		timeAsBuf []byte
+	)
+
+	version = objectVersionStruct{
+		dirInodeNumber: dirInodeNumber,
+		name:           name,
+		inodeNumber:    inodeNumber,
+	}
+
+	pathAsBuf, err = vS.inodeVolumeHandle.GetStream(inodeNumber, objectVersionsPathStreamName)
+	if nil != err {
+		return
+	}
+	version.path = string(pathAsBuf)
+
+	timeAsBuf, err = vS.inodeVolumeHandle.GetStream(inodeNumber, objectVersionsTimeStreamName)
+	if nil == err {
+		version.time, err = time.Parse(time.RFC3339Nano, string(timeAsBuf))
+	}
+	if nil != err {
+		version.time = time.Time{}
+	}
+
+	_, err = vS.inodeVolumeHandle.GetStream(inodeNumber, objectVersionsDeleteMarkerStreamName)
+	version.deleteMarker = (nil == err)
+
+	err = nil
+	return
+}
+
+// scanObjectVersions returns those archived versions in dirInodeNumber (i.e. /<objectVersionsDirName>/<container>/)
+// whose entry names begin with entryPrefix and whose paths begin with pathPrefix sorted by path and
+// then newest first. As entry names are ordered, only those beginning with entryPrefix are visited.
+// Callers hold locks on /<objectVersionsDirName>/ and dirInodeNumber.
+func (vS *volumeStruct) scanObjectVersions(dirInodeNumber inode.InodeNumber, entryPrefix string, pathPrefix string) (versions []objectVersionStruct, err error) {
+	var (
+		dirEntry      inode.DirEntry
+		dirEntrySlice []inode.DirEntry
+		moreEntries   bool
+		prevName      string
+		version       objectVersionStruct
+	)
+
+	versions = make([]objectVersionStruct, 0)
+
+	// Every name beginning with entryPrefix follows entryPrefix itself (but "." and ".." may not)
+
+	prevName = entryPrefix
+	moreEntries = true
+
+	for moreEntries {
+		if "" == prevName {
+			dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(dirInodeNumber, objectVersionsReadDirMaxEntries, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(dirInodeNumber, objectVersionsReadDirMaxEntries, 0, prevName)
+		}
+		if nil != err {
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+
+			if !strings.HasPrefix(dirEntry.Basename, entryPrefix) {
+				moreEntries = false
+				break
+			}
+
+			version, err = vS.fetchObjectVersion(dirInodeNumber, dirEntry.Basename, dirEntry.InodeNumber)
+			if nil != err {
+				// Not placed here by archiveObjectVersion() or retireObjectVersion()
+				err = nil
+				continue
+			}
+
+			if strings.HasPrefix(version.path, pathPrefix) {
+				versions = append(versions, version)
+			}
+		}
+
+		if 0 < len(dirEntrySlice) {
+			prevName = dirEntrySlice[len(dirEntrySlice)-1].Basename
+		} else {
+			moreEntries = false
+		}
+	}
+
+	sort.Slice(versions, func(i int, j int) bool {
+		if versions[i].path != versions[j].path {
+			return versions[i].path < versions[j].path
+		}
+		if !versions[i].time.Equal(versions[j].time) {
+			return versions[i].time.After(versions[j].time)
+		}
+		return versions[i].inodeNumber > versions[j].inodeNumber
+	})
+
+	return
+}
+
+// objectVersionsOf returns the archived versions in dirInodeNumber (i.e. /<objectVersionsDirName>/<container>/)
+// of the object at versionPath, newest first. Callers hold locks on /<objectVersionsDirName>/ and
+// dirInodeNumber.
+func (vS *volumeStruct) objectVersionsOf(dirInodeNumber inode.InodeNumber, versionPath string) (versions []objectVersionStruct, err error) {
+	var (
+		objectName string
+		version    objectVersionStruct
+	)
+
+	_, objectName = objectVersionsSplitPath(versionPath)
+
+	versions, err = vS.scanObjectVersions(dirInodeNumber, objectVersionsEntryPrefix(objectName), versionPath)
+	if nil != err {
+		return
+	}
+
+	// Discard versions of objects whose path merely begins with versionPath
+
+	archivedVersions := versions
+	versions = make([]objectVersionStruct, 0, len(archivedVersions))
+
+	for _, version = range archivedVersions {
+		if versionPath == version.path {
+			versions = append(versions, version)
+		}
+	}
+
+	return
+}
+
+// resolveObjectVersion locates version versionID of the object at path (be it the current or an
+// archived version) and locks it. Delete markers are not found.
+func (vS *volumeStruct) resolveObjectVersion(path string, versionID inode.InodeNumber, heldLocks *heldLocksStruct, options resolvePathOption) (inodeNumber inode.InodeNumber, retryRequired bool, err error) {
+	var (
+		containerName  string
+		dirInodeNumber inode.InodeNumber
+		objectName     string
+		version        objectVersionStruct
+		versionPath    string
+	)
+
+	versionPath = objectVersionsCanonicalPath(path)
+
+	if vS.objectVersioning && ("" != versionPath) {
+		retryRequired = heldLocks.attemptSharedLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), vS.objectVersionsDirInodeNumber)
+		if retryRequired {
+			return
+		}
+
+		containerName, objectName = objectVersionsSplitPath(versionPath)
+
+		dirInodeNumber, err = vS.objectVersionsContainerDir(containerName, false)
+		if nil == err {
+			retryRequired = heldLocks.attemptSharedLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), dirInodeNumber)
+			if retryRequired {
+				return
+			}
+
+			inodeNumber, err = vS.inodeVolumeHandle.Lookup(dirInodeNumber, objectVersionsEntryName(objectName, versionID))
+		}
+		if nil == err {
+			if resolvePathOptionsCheck(options, resolvePathRequireExclusiveLockOnDirEntryInode) {
+				retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), inodeNumber)
+			} else {
+				retryRequired = heldLocks.attemptSharedLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), inodeNumber)
+			}
+			if retryRequired {
+				return
+			}
+
+			version, err = vS.fetchObjectVersion(dirInodeNumber, objectVersionsEntryName(objectName, versionID), inodeNumber)
+			if (nil != err) || (versionPath != version.path) || version.deleteMarker {
+				err = blunder.NewError(blunder.NotFoundError, "%s has no version %d", path, versionID)
+			}
+			return
+		}
+		if !blunder.Is(err, blunder.NotFoundError) {
+			return
+		}
+	}
+
+	// Not archived... so versionID must be the current version
+
+	_, inodeNumber, _, _, retryRequired, err =
+		vS.resolvePath(
+			inode.RootDirInodeNumber,
+			path,
+			heldLocks,
+			options)
+	if (nil != err) || retryRequired {
+		return
+	}
+
+	if versionID != inodeNumber {
+		err = blunder.NewError(blunder.NotFoundError, "%s has no version %d", path, versionID)
+	}
+
+	return
+}
+
+// deleteObjectVersion destroys version versionID of the object at path (be it the current or an
+// archived version). Should the current version be destroyed, the newest archived version (unless
+// it is a delete marker) takes its place. Similarly, destroying the newest version when it is a
+// delete marker restores the version before it.
+func (vS *volumeStruct) deleteObjectVersion(path string, versionID inode.InodeNumber, changeEvents *changeEventPendingListStruct) (err error) {
+	var (
+		archivedInodeNumber             inode.InodeNumber
+		basename                        string
+		containerName                   string
+		containerVersionsDirInodeNumber inode.InodeNumber
+		currentInodeNumber              inode.InodeNumber
+		dirInodeNumber                  inode.InodeNumber
+		heldLocks                       *heldLocksStruct
+		inodeType                       inode.InodeType
+		isArchived                      bool
+		objectName                      string
+		pathSplit                       []string
+		promoteVersion                  *objectVersionStruct
+		retryRequired                   bool
+		toDestroyInodeNumber            inode.InodeNumber
+		tryLockBackoffContext           *tryLockBackoffContextStruct
+		version                         objectVersionStruct
+		versionPath                     string
+		versions                        []objectVersionStruct
+	)
+
+	if !vS.objectVersioning {
+		err = blunder.NewError(blunder.NotSupportedError, "ObjectVersioning not enabled for volume %s", vS.volumeName)
+		return
+	}
+
+	versionPath = objectVersionsCanonicalPath(path)
+	if "" == versionPath {
+		err = blunder.NewError(blunder.InvalidArgError, "%s cannot name a versioned object", path)
+		return
+	}
+
+	pathSplit = strings.Split(versionPath[1:], "/")
+	basename = pathSplit[len(pathSplit)-1]
+
+	containerName, objectName = objectVersionsSplitPath(versionPath)
+
+	// Retry until done or failure (starting with ZERO backoff)
+
+	tryLockBackoffContext = &tryLockBackoffContextStruct{}
+
+Restart:
+
+	// Perform backoff and update for each restart (starting with ZERO backoff of course)
+
+	tryLockBackoffContext.backoff()
+
+	// Construct fresh heldLocks for this restart
+
+	heldLocks = newHeldLocks()
+
+	retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), vS.objectVersionsDirInodeNumber)
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	containerVersionsDirInodeNumber, err = vS.objectVersionsContainerDir(containerName, false)
+	if nil == err {
+		retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), containerVersionsDirInodeNumber)
+		if retryRequired {
+			heldLocks.free()
+			goto Restart
+		}
+	} else {
+		if !blunder.Is(err, blunder.NotFoundError) {
+			heldLocks.free()
+			return
+		}
+		containerVersionsDirInodeNumber = inode.InodeNumber(0) // No version of any object in the container was ever archived
+	}
+
+	// Archived versions may outlive the directory that held them
+
+	_, dirInodeNumber, _, _, retryRequired, err =
+		vS.resolvePath(
+			inode.RootDirInodeNumber,
+			"/"+strings.Join(pathSplit[:len(pathSplit)-1], "/"),
+			heldLocks,
+			resolvePathFollowDirSymlinks|
+				resolvePathDirEntryInodeMustBeDirectory|
+				resolvePathRequireExclusiveLockOnDirEntryInode)
+	if nil != err {
+		if !blunder.Is(err, blunder.NotFoundError) {
+			heldLocks.free()
+			return
+		}
+		dirInodeNumber = inode.InodeNumber(0)
+	}
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	if inode.InodeNumber(0) == dirInodeNumber {
+		currentInodeNumber = inode.InodeNumber(0)
+	} else {
+		currentInodeNumber, err = vS.inodeVolumeHandle.Lookup(dirInodeNumber, basename)
+		if nil != err {
+			if !blunder.Is(err, blunder.NotFoundError) {
+				heldLocks.free()
+				return
+			}
+			currentInodeNumber = inode.InodeNumber(0)
+		}
+	}
+
+	// Locate the version to destroy
+
+	if inode.InodeNumber(0) == containerVersionsDirInodeNumber {
+		err = blunder.NewError(blunder.NotFoundError, "%s has no archived versions", path)
+	} else {
+		archivedInodeNumber, err = vS.inodeVolumeHandle.Lookup(containerVersionsDirInodeNumber, objectVersionsEntryName(objectName, versionID))
+	}
+	if nil == err {
+		version, err = vS.fetchObjectVersion(containerVersionsDirInodeNumber, objectVersionsEntryName(objectName, versionID), archivedInodeNumber)
+		if (nil != err) || (versionID != archivedInodeNumber) || (versionPath != version.path) {
+			heldLocks.free()
+			err = blunder.NewError(blunder.NotFoundError, "%s has no version %d", path, versionID)
+			return
+		}
+		isArchived = true
+	} else if blunder.Is(err, blunder.NotFoundError) && (versionID == currentInodeNumber) {
+		inodeType, err = vS.inodeVolumeHandle.GetType(currentInodeNumber)
+		if nil != err {
+			heldLocks.free()
+			return
+		}
+		if inode.FileType != inodeType {
+			heldLocks.free()
+			err = blunder.NewError(blunder.NotFoundError, "%s has no version %d", path, versionID)
+			return
+		}
+		isArchived = false
+	} else {
+		heldLocks.free()
+		err = blunder.NewError(blunder.NotFoundError, "%s has no version %d", path, versionID)
+		return
+	}
+
+	retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), versionID)
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	err = vS.wormCheck(versionID, wormOpRemove)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	// Determine which version (if any) will take the place of the destroyed one
+
+	promoteVersion = nil
+
+	if (inode.InodeNumber(0) != dirInodeNumber) && (inode.InodeNumber(0) != containerVersionsDirInodeNumber) {
+		versions, err = vS.objectVersionsOf(containerVersionsDirInodeNumber, versionPath)
+		if nil != err {
+			heldLocks.free()
+			return
+		}
+
+		if isArchived {
+			if version.deleteMarker && (inode.InodeNumber(0) == currentInodeNumber) && (1 < len(versions)) && (versionID == versions[0].inodeNumber) && !versions[1].deleteMarker {
+				promoteVersion = &versions[1]
+			}
+		} else {
+			if (0 < len(versions)) && !versions[0].deleteMarker {
+				promoteVersion = &versions[0]
+			}
+		}
+	}
+
+	if nil != promoteVersion {
+		retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), promoteVersion.inodeNumber)
+		if retryRequired {
+			heldLocks.free()
+			goto Restart
+		}
+	}
+
+	// Now destroy the version
+
+	if isArchived {
+		toDestroyInodeNumber, err = vS.inodeVolumeHandle.Unlink(version.dirInodeNumber, version.name, false)
+	} else {
+		toDestroyInodeNumber, err = vS.inodeVolumeHandle.Unlink(dirInodeNumber, basename, false)
+	}
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	if !isArchived {
+		changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventUnlink, inodeNumber: versionID, dirInodeNumber: dirInodeNumber, basename: basename})
+	}
+
+	if inode.InodeNumber(0) != toDestroyInodeNumber {
+		vS.untrackInFlightFileInodeData(toDestroyInodeNumber, false)
+		err = vS.inodeVolumeHandle.Destroy(toDestroyInodeNumber)
+		if nil != err {
+			logger.ErrorfWithError(err, "ObjectVersioning of volume %s failed to Destroy inode 0x%016X", vS.volumeName, toDestroyInodeNumber)
+		}
+	}
+
+	globals.ObjectVersionsDestroyed.Add(1)
+
+	// And, if appropriate, make the prior version current
+
+	if nil != promoteVersion {
+		_, err = vS.inodeVolumeHandle.Move(promoteVersion.dirInodeNumber, promoteVersion.name, dirInodeNumber, basename, inode.MoveFlagNoReplace)
+		if nil != err {
+			logger.ErrorfWithError(err, "ObjectVersioning of volume %s unable to restore inode 0x%016X to \"%s\"", vS.volumeName, promoteVersion.inodeNumber, versionPath)
+		} else {
+			_ = vS.inodeVolumeHandle.DeleteStream(promoteVersion.inodeNumber, objectVersionsPathStreamName)
+			_ = vS.inodeVolumeHandle.DeleteStream(promoteVersion.inodeNumber, objectVersionsTimeStreamName)
+
+			changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventCreate, inodeNumber: promoteVersion.inodeNumber, dirInodeNumber: dirInodeNumber, basename: basename, path: versionPath})
+
+			globals.ObjectVersionsPromoted.Add(1)
+		}
+	}
+
+	heldLocks.free()
+
+	err = nil
+	return
+}
+
+// middlewareGetContainerVersions returns, for each object name that MiddlewareGetContainer() would,
+// its current version (if any) followed by its archived versions (newest first). Note that
+// maxEntries limits the number of object names rather than versions returned.
+func (vS *volumeStruct) middlewareGetContainerVersions(vContainerName string, maxEntries uint64, marker string, endmarker string, prefix string, delimiter string) (containerEnts []ContainerEntry, err error) {
+	var (
+		archivedEnts                    map[string][]ContainerEntry
+		containerEntry                  ContainerEntry
+		containerPathPrefix             string
+		containerPathSplit              []string
+		containerVersionsDirInodeLock   *dlm.RWLockStruct
+		containerVersionsDirInodeNumber inode.InodeNumber
+		currentEnts                     []ContainerEntry
+		currentEntsMap                  map[string]ContainerEntry
+		lastName                        string
+		metadata                        *inode.MetadataStruct
+		name                            string
+		names                           []string
+		ok                              bool
+		version                         objectVersionStruct
+		versions                        []objectVersionStruct
+		versionsDirInodeLock            *dlm.RWLockStruct
+	)
+
+	currentEnts, err = vS.MiddlewareGetContainer(vContainerName, maxEntries, marker, endmarker, prefix, delimiter, false)
+	if (nil != err) || (0 == maxEntries) {
+		containerEnts = currentEnts
+		return
+	}
+
+	currentEntsMap = make(map[string]ContainerEntry)
+	names = make([]string, 0, len(currentEnts))
+
+	for _, containerEntry = range currentEnts {
+		if !containerEntry.IsDir {
+			containerEntry.VersionID = containerEntry.InodeNumber
+			containerEntry.IsLatest = true
+		}
+		currentEntsMap[containerEntry.Basename] = containerEntry
+		names = append(names, containerEntry.Basename)
+	}
+
+	archivedEnts = make(map[string][]ContainerEntry)
+
+	containerPathSplit, err = canonicalizePath(vContainerName)
+	if (nil == err) && (1 == len(containerPathSplit)) && vS.objectVersioning {
+		// Only archived versions of names up to the last one MiddlewareGetContainer() returned are
+		// known to fall within maxEntries
+
+		if uint64(len(currentEnts)) == maxEntries {
+			lastName = currentEnts[len(currentEnts)-1].Basename
+		}
+
+		containerPathPrefix = "/" + containerPathSplit[0] + "/"
+
+		versionsDirInodeLock, err = vS.inodeVolumeHandle.GetReadLock(vS.objectVersionsDirInodeNumber, nil)
+		if nil != err {
+			return
+		}
+		defer versionsDirInodeLock.Unlock()
+
+		containerVersionsDirInodeNumber, err = vS.objectVersionsContainerDir(containerPathSplit[0], false)
+		if nil == err {
+			containerVersionsDirInodeLock, err = vS.inodeVolumeHandle.GetReadLock(containerVersionsDirInodeNumber, nil)
+			if nil != err {
+				return
+			}
+			defer containerVersionsDirInodeLock.Unlock()
+
+			versions, err = vS.scanObjectVersions(containerVersionsDirInodeNumber, "", containerPathPrefix+prefix)
+			if nil != err {
+				return
+			}
+		} else if blunder.Is(err, blunder.NotFoundError) {
+			versions = make([]objectVersionStruct, 0) // No version of any object in the container was ever archived
+		} else {
+			return
+		}
+
+		for _, version = range versions {
+			name = strings.TrimPrefix(version.path, containerPathPrefix)
+			if (("" != marker) && (name <= marker)) || (("" != endmarker) && (name >= endmarker)) || (("" != lastName) && (name > lastName)) {
+				continue
+			}
+			if ("" != delimiter) && strings.Contains(name[len(prefix):], delimiter) {
+				continue
+			}
+
+			metadata, err = vS.inodeVolumeHandle.GetMetadata(version.inodeNumber)
+			if nil != err {
+				return
+			}
+
+			containerEntry = ContainerEntry{
+				Basename:         name,
+				FileSize:         metadata.Size,
+				ModificationTime: uint64(metadata.ModificationTime.UnixNano()),
+				AttrChangeTime:   uint64(metadata.AttrChangeTime.UnixNano()),
+				NumWrites:        metadata.NumWrites,
+				InodeNumber:      uint64(version.inodeNumber),
+				VersionID:        uint64(version.inodeNumber),
+				IsDeleteMarker:   version.deleteMarker,
+			}
+
+			containerEntry.Metadata, err = vS.inodeVolumeHandle.GetStream(version.inodeNumber, MiddlewareStream)
+			if nil != err {
+				containerEntry.Metadata = []byte{}
+				err = nil
+			}
+
+			_, ok = archivedEnts[name]
+			if !ok {
+				_, ok = currentEntsMap[name]
+				if !ok {
+					names = append(names, name)
+					containerEntry.IsLatest = true
+				}
+			}
+
+			archivedEnts[name] = append(archivedEnts[name], containerEntry)
+		}
+	}
+
+	sort.Strings(names)
+
+	if uint64(len(names)) > maxEntries {
+		names = names[:maxEntries]
+	}
+
+	containerEnts = make([]ContainerEntry, 0, len(names))
+
+	for _, name = range names {
+		containerEntry, ok = currentEntsMap[name]
+		if ok {
+			containerEnts = append(containerEnts, containerEntry)
+		}
+		containerEnts = append(containerEnts, archivedEnts[name]...)
+	}
+
+	err = nil
+	return
+}
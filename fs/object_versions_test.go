@@ -0,0 +1,256 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func testObjectVersionsGetObject(t *testing.T, versionID uint64) (response HeadResponse, err error) {
+	readRangeOut := []inode.ReadPlanStep{}
+	response, err = testVolumeStruct.MiddlewareGetObject("VersionedContainer/Object", versionID, []ReadRangeIn{}, &readRangeOut)
+	return
+}
+
+func testObjectVersionsList(t *testing.T) (containerEnts []ContainerEntry) {
+	containerEnts, err := testVolumeStruct.MiddlewareGetContainer("VersionedContainer", 10, "", "", "", "", true)
+	if nil != err {
+		t.Fatalf("MiddlewareGetContainer() listing versions failed: %v", err)
+	}
+	return
+}
+
+func TestObjectVersions(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeStruct.objectVersioning = true
+	testVolumeStruct.establishObjectVersions()
+	if !testVolumeStruct.objectVersioning {
+		t.Fatalf("establishObjectVersions() failed")
+	}
+
+	err := testVolumeStruct.MiddlewarePutContainer("VersionedContainer", []byte(""), []byte("{\"X-Versions-Enabled\": \"true\"}"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() of VersionedContainer failed: %v", err)
+	}
+	err = testVolumeStruct.MiddlewarePutContainer("PlainContainer", []byte(""), []byte("{}"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() of PlainContainer failed: %v", err)
+	}
+
+	// Overwriting an object in a container without versioning reuses its FileInode
+
+	_, _, firstInodeNumber, _, err := testVolumeStruct.MiddlewarePutComplete("PlainContainer", "Object", nil, nil, []byte("first"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() failed: %v", err)
+	}
+	_, _, secondInodeNumber, _, err := testVolumeStruct.MiddlewarePutComplete("PlainContainer", "Object", nil, nil, []byte("second"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() failed: %v", err)
+	}
+	if firstInodeNumber != secondInodeNumber {
+		t.Fatalf("MiddlewarePutComplete() in PlainContainer should not have retained the prior version")
+	}
+
+	// Overwriting an object in a container with versioning retains the prior version
+
+	_, _, firstInodeNumber, _, err = testVolumeStruct.MiddlewarePutComplete("VersionedContainer", "Object", nil, nil, []byte("first"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() failed: %v", err)
+	}
+	_, _, secondInodeNumber, _, err = testVolumeStruct.MiddlewarePutComplete("VersionedContainer", "Object", nil, nil, []byte("second"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() failed: %v", err)
+	}
+	if firstInodeNumber == secondInodeNumber {
+		t.Fatalf("MiddlewarePutComplete() in VersionedContainer should have retained the prior version")
+	}
+
+	response, err := testObjectVersionsGetObject(t, 0)
+	if (nil != err) || ("second" != string(response.Metadata)) || (secondInodeNumber != response.InodeNumber) {
+		t.Fatalf("MiddlewareGetObject() of current version returned unexpected result (err: %v)", err)
+	}
+	response, err = testObjectVersionsGetObject(t, uint64(firstInodeNumber))
+	if (nil != err) || ("first" != string(response.Metadata)) || (firstInodeNumber != response.InodeNumber) {
+		t.Fatalf("MiddlewareGetObject() of prior version returned unexpected result (err: %v)", err)
+	}
+	_, err = testObjectVersionsGetObject(t, uint64(firstInodeNumber)+1000)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareGetObject() of unknown version should have failed with NotFoundError: %v", err)
+	}
+
+	containerEnts := testObjectVersionsList(t)
+	if (2 != len(containerEnts)) ||
+		(uint64(secondInodeNumber) != containerEnts[0].VersionID) || !containerEnts[0].IsLatest ||
+		(uint64(firstInodeNumber) != containerEnts[1].VersionID) || containerEnts[1].IsLatest {
+		t.Fatalf("MiddlewareGetContainer() listing versions returned unexpected result: %+v", containerEnts)
+	}
+
+	containerEnts, err = testVolumeStruct.MiddlewareGetContainer("VersionedContainer", 10, "", "", "", "", false)
+	if (nil != err) || (1 != len(containerEnts)) {
+		t.Fatalf("MiddlewareGetContainer() should only have returned the current version (err: %v)", err)
+	}
+
+	// Deleting the object leaves a delete marker as its newest version
+
+	err = testVolumeStruct.MiddlewareDelete("VersionedContainer", "Object", 0)
+	if nil != err {
+		t.Fatalf("MiddlewareDelete() failed: %v", err)
+	}
+
+	_, err = testObjectVersionsGetObject(t, 0)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareGetObject() of deleted object should have failed with NotFoundError: %v", err)
+	}
+
+	containerEnts = testObjectVersionsList(t)
+	if (3 != len(containerEnts)) ||
+		!containerEnts[0].IsDeleteMarker || !containerEnts[0].IsLatest ||
+		(uint64(secondInodeNumber) != containerEnts[1].VersionID) ||
+		(uint64(firstInodeNumber) != containerEnts[2].VersionID) {
+		t.Fatalf("MiddlewareGetContainer() listing versions after MiddlewareDelete() returned unexpected result: %+v", containerEnts)
+	}
+
+	_, err = testObjectVersionsGetObject(t, containerEnts[0].VersionID)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareGetObject() of delete marker should have failed with NotFoundError: %v", err)
+	}
+
+	// Deleting the delete marker restores the version before it
+
+	err = testVolumeStruct.MiddlewareDelete("VersionedContainer", "Object", containerEnts[0].VersionID)
+	if nil != err {
+		t.Fatalf("MiddlewareDelete() of delete marker failed: %v", err)
+	}
+
+	response, err = testObjectVersionsGetObject(t, 0)
+	if (nil != err) || (secondInodeNumber != response.InodeNumber) {
+		t.Fatalf("MiddlewareGetObject() after deleting delete marker returned unexpected result (err: %v)", err)
+	}
+
+	// Deleting the current version promotes the newest prior version
+
+	err = testVolumeStruct.MiddlewareDelete("VersionedContainer", "Object", uint64(secondInodeNumber))
+	if nil != err {
+		t.Fatalf("MiddlewareDelete() of current version failed: %v", err)
+	}
+
+	response, err = testObjectVersionsGetObject(t, 0)
+	if (nil != err) || ("first" != string(response.Metadata)) || (firstInodeNumber != response.InodeNumber) {
+		t.Fatalf("MiddlewareGetObject() after deleting current version returned unexpected result (err: %v)", err)
+	}
+
+	containerEnts = testObjectVersionsList(t)
+	if (1 != len(containerEnts)) || (uint64(firstInodeNumber) != containerEnts[0].VersionID) || !containerEnts[0].IsLatest {
+		t.Fatalf("MiddlewareGetContainer() listing versions after deleting current version returned unexpected result: %+v", containerEnts)
+	}
+
+	// HEAD of a version describes that version
+
+	headResponse, err := testVolumeStruct.MiddlewareHeadResponse("VersionedContainer/Object", uint64(firstInodeNumber))
+	if (nil != err) || ("first" != string(headResponse.Metadata)) || (firstInodeNumber != headResponse.InodeNumber) {
+		t.Fatalf("MiddlewareHeadResponse() of version returned unexpected result (err: %v)", err)
+	}
+	_, err = testVolumeStruct.MiddlewareHeadResponse("VersionedContainer/Object", uint64(secondInodeNumber))
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareHeadResponse() of destroyed version should have failed with NotFoundError: %v", err)
+	}
+
+	// Versions of an object are not confused with those of an object whose name it prefixes
+
+	_, _, prefixedInodeNumber, _, err := testVolumeStruct.MiddlewarePutComplete("VersionedContainer", "ObjectPrefixed", nil, nil, []byte("prefixed"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() failed: %v", err)
+	}
+	_, _, _, _, err = testVolumeStruct.MiddlewarePutComplete("VersionedContainer", "ObjectPrefixed", nil, nil, []byte("prefixed again"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() failed: %v", err)
+	}
+	_, err = testVolumeStruct.MiddlewareHeadResponse("VersionedContainer/Object", uint64(prefixedInodeNumber))
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("MiddlewareHeadResponse() of another object's version should have failed with NotFoundError: %v", err)
+	}
+
+	containerEnts, err = testVolumeStruct.MiddlewareGetContainer("VersionedContainer", 10, "", "", "ObjectP", "", true)
+	if (nil != err) || (2 != len(containerEnts)) ||
+		("ObjectPrefixed" != containerEnts[0].Basename) || !containerEnts[0].IsLatest ||
+		("ObjectPrefixed" != containerEnts[1].Basename) || (uint64(prefixedInodeNumber) != containerEnts[1].VersionID) {
+		t.Fatalf("MiddlewareGetContainer() listing versions with prefix returned unexpected result: %+v (err: %v)", containerEnts, err)
+	}
+
+	// A version also reachable via a hard link is archived as a copy so that it cannot change
+
+	_, _, linkedInodeNumber, _, err := testVolumeStruct.MiddlewarePutComplete("VersionedContainer", "Linked", nil, nil, []byte("linked"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() failed: %v", err)
+	}
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, linkedInodeNumber, 0, []byte("data"), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, linkedInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+	containerInodeNumber, err := testVolumeStruct.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "VersionedContainer")
+	if nil != err {
+		t.Fatalf("Lookup() failed: %v", err)
+	}
+	err = testVolumeStruct.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, containerInodeNumber, "LinkedAlias", linkedInodeNumber)
+	if nil != err {
+		t.Fatalf("Link() failed: %v", err)
+	}
+
+	_, _, _, _, err = testVolumeStruct.MiddlewarePutComplete("VersionedContainer", "Linked", nil, nil, []byte("replaced"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() failed: %v", err)
+	}
+
+	containerEnts, err = testVolumeStruct.MiddlewareGetContainer("VersionedContainer", 10, "", "", "Linked", "", true)
+	if (nil != err) || (3 != len(containerEnts)) || ("Linked" != containerEnts[1].Basename) || ("LinkedAlias" != containerEnts[2].Basename) {
+		t.Fatalf("MiddlewareGetContainer() listing versions of hard linked object returned unexpected result: %+v (err: %v)", containerEnts, err)
+	}
+	if uint64(linkedInodeNumber) == containerEnts[1].VersionID {
+		t.Fatalf("MiddlewarePutComplete() should have archived a copy of the hard linked object")
+	}
+	linkedVersionID := containerEnts[1].VersionID
+
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, linkedInodeNumber, 4, []byte("more data"), nil)
+	if nil != err {
+		t.Fatalf("Write() via hard link failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, linkedInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	readRangeOut := []inode.ReadPlanStep{}
+	response, err = testVolumeStruct.MiddlewareGetObject("VersionedContainer/Linked", linkedVersionID, []ReadRangeIn{}, &readRangeOut)
+	if (nil != err) || ("linked" != string(response.Metadata)) || (4 != response.FileSize) {
+		t.Fatalf("MiddlewareGetObject() of archived copy returned unexpected result: %+v (err: %v)", response, err)
+	}
+	buf, err := testVolumeStruct.inodeVolumeHandle.Read(inode.InodeNumber(linkedVersionID), 0, 4, nil)
+	if (nil != err) || ("data" != string(buf)) {
+		t.Fatalf("Read() of archived copy returned unexpected result: \"%s\" (err: %v)", string(buf), err)
+	}
+
+	// /<objectVersionsDirName>/ is not a container
+
+	accountEnts, _, _, err := testVolumeStruct.MiddlewareGetAccount(0, "", "")
+	if nil != err {
+		t.Fatalf("MiddlewareGetAccount() failed: %v", err)
+	}
+	for _, accountEntry := range accountEnts {
+		if objectVersionsDirName == accountEntry.Basename {
+			t.Fatalf("MiddlewareGetAccount() should not have returned /%s", objectVersionsDirName)
+		}
+	}
+
+	testVolumeStruct.objectVersioning = false
+
+	testTeardown(t)
+}
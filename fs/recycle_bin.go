@@ -0,0 +1,648 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/dlm"
+	"github.com/NVIDIA/proxyfs/inode"
+	"github.com/NVIDIA/proxyfs/logger"
+)
+
+// When a volume's RecycleBinRetention is non-zero, Inodes that Unlink(), Rmdir(), Rename() (over an
+// existing entry), and MiddlewareDelete() would otherwise destroy are instead moved into the volume's
+// /<recycleBinDirName>/ directory. Each is named there by its InodeNumber and records the path from
+// which it was removed and when in a pair of Streams. Entries may be listed, restored (to their
+// original or some other path), or purged. Entries older than RecycleBinRetention are purged every
+// RecycleBinPurgeInterval.
+//
+// Note that Inodes removed from within /<recycleBinDirName>/ are destroyed as before. As a DirInode
+// must be empty to be removed, restoring the contents of a removed directory tree is accomplished by
+// restoring its entries in the reverse order of their removal (i.e. most recently removed first).
+
+const (
+	recycleBinDirName           = ".Recycle+Bin"
+	recycleBinPathStreamName    = "proxyfs.recyclebin.path"
+	recycleBinTimeStreamName    = "proxyfs.recyclebin.time"
+	recycleBinReadDirMaxEntries = uint64(1024)
+)
+
+// fetchRecycleBinConfig fetches the (optional) RecycleBin* options for a volume. Note that the
+// RecycleBin is disabled unless RecycleBinRetention is specified (and non-zero).
+func (vS *volumeStruct) fetchRecycleBinConfig(confMap conf.ConfMap, volumeSectionName string) {
+	var (
+		err error
+	)
+
+	vS.recycleBinRetention, err = confMap.FetchOptionValueDuration(volumeSectionName, "RecycleBinRetention")
+	if nil != err {
+		vS.recycleBinRetention = time.Duration(0) // Default to the RecycleBin being disabled
+	}
+	vS.recycleBinPurgeInterval, err = confMap.FetchOptionValueDuration(volumeSectionName, "RecycleBinPurgeInterval")
+	if (nil != err) || (time.Duration(0) == vS.recycleBinPurgeInterval) {
+		vS.recycleBinPurgeInterval = 10 * time.Minute
+	}
+}
+
+func (vS *volumeStruct) recycleBinEnabled() (enabled bool) {
+	enabled = (time.Duration(0) != vS.recycleBinRetention)
+	return
+}
+
+// establishRecycleBin locates (creating if necessary) /<recycleBinDirName>/. Should that fail, the
+// RecycleBin is disabled.
+func (vS *volumeStruct) establishRecycleBin() {
+	var (
+		err error
+	)
+
+	if !vS.recycleBinEnabled() {
+		return
+	}
+
+	vS.recycleBinDirInodeNumber, err = vS.establishRootDir(recycleBinDirName)
+	if nil != err {
+		logger.ErrorfWithError(err, "RecycleBin of volume %s disabled", vS.volumeName)
+		vS.recycleBinRetention = time.Duration(0)
+	}
+}
+
+// establishRootDir locates (creating if necessary) the root-only accessible /<dirName>/.
+func (vS *volumeStruct) establishRootDir(dirName string) (dirInodeNumber inode.InodeNumber, err error) {
+	var (
+		inodeType     inode.InodeType
+		rootInodeLock *dlm.RWLockStruct
+	)
+
+	rootInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(inode.RootDirInodeNumber, nil)
+	if nil != err {
+		err = fmt.Errorf("unable to lock /: %v", err)
+		return
+	}
+	defer rootInodeLock.Unlock()
+
+	dirInodeNumber, err = vS.inodeVolumeHandle.Lookup(inode.RootDirInodeNumber, dirName)
+	if nil == err {
+		inodeType, err = vS.inodeVolumeHandle.GetType(dirInodeNumber)
+		if (nil != err) || (inode.DirType != inodeType) {
+			err = fmt.Errorf("/%s is not a directory", dirName)
+		}
+		return
+	}
+
+	if !blunder.Is(err, blunder.NotFoundError) {
+		err = fmt.Errorf("unable to lookup /%s: %v", dirName, err)
+		return
+	}
+
+	dirInodeNumber, err = vS.inodeVolumeHandle.CreateDir(inode.InodeMode(0700), inode.InodeRootUserID, inode.InodeGroupID(0))
+	if nil != err {
+		err = fmt.Errorf("unable to create /%s: %v", dirName, err)
+		return
+	}
+
+	err = vS.inodeVolumeHandle.Link(inode.RootDirInodeNumber, dirName, dirInodeNumber, false)
+	if nil != err {
+		_ = vS.inodeVolumeHandle.Destroy(dirInodeNumber)
+		err = fmt.Errorf("unable to link /%s: %v", dirName, err)
+		return
+	}
+
+	logger.Infof("Created /%s for volume %s", dirName, vS.volumeName)
+
+	return
+}
+
+// recycleBinCanonicalPath returns the canonicalized form of path for recording in the RecycleBin or
+// "" should an Inode removed from path not be placed in the RecycleBin.
+func (vS *volumeStruct) recycleBinCanonicalPath(path string) (recycleBinPath string) {
+	var (
+		err       error
+		pathSplit []string
+	)
+
+	if !vS.recycleBinEnabled() || ("" == path) {
+		recycleBinPath = ""
+		return
+	}
+
+	pathSplit, err = canonicalizePath(path)
+	if (nil != err) || (0 == len(pathSplit)) || (recycleBinDirName == pathSplit[0]) {
+		recycleBinPath = ""
+		return
+	}
+
+	recycleBinPath = "/" + strings.Join(pathSplit, "/")
+
+	return
+}
+
+// recycleBinEntryPath returns the path to basename in dirInodeNumber for recording in the RecycleBin
+// or "" should an Inode removed from there not be placed in the RecycleBin. Callers must not hold any
+// Inode locks.
+func (vS *volumeStruct) recycleBinEntryPath(dirInodeNumber inode.InodeNumber, basename string) (recycleBinPath string) {
+	if !vS.recycleBinEnabled() || (vS.recycleBinDirInodeNumber == dirInodeNumber) {
+		recycleBinPath = ""
+		return
+	}
+
+	recycleBinPath = vS.recycleBinCanonicalPath(vS.changeEventEntryPath(dirInodeNumber, basename))
+
+	return
+}
+
+// recycle places inodeNumber, just removed from recycleBinPath and otherwise about to be destroyed, in
+// the RecycleBin. If recycleBinPath is "" or this fails, false is returned and the caller should
+// proceed to destroy inodeNumber. Callers hold an exclusive lock on inodeNumber but must not hold one
+// on /<recycleBinDirName>/.
+func (vS *volumeStruct) recycle(inodeNumber inode.InodeNumber, recycleBinPath string) (recycled bool) {
+	var (
+		err                 error
+		recycleBinInodeLock *dlm.RWLockStruct
+	)
+
+	if "" == recycleBinPath {
+		recycled = false
+		return
+	}
+
+	recycleBinInodeLock, err = vS.inodeVolumeHandle.GetWriteLock(vS.recycleBinDirInodeNumber, nil)
+	if nil != err {
+		logger.ErrorfWithError(err, "RecycleBin of volume %s unable to accept inode 0x%016X (\"%s\")", vS.volumeName, inodeNumber, recycleBinPath)
+		recycled = false
+		return
+	}
+	defer recycleBinInodeLock.Unlock()
+
+	err = vS.inodeVolumeHandle.PutStream(inodeNumber, recycleBinPathStreamName, []byte(recycleBinPath))
+	if nil == err {
+		err = vS.inodeVolumeHandle.PutStream(inodeNumber, recycleBinTimeStreamName, []byte(time.Now().UTC().Format(time.RFC3339Nano)))
+	}
+	if nil == err {
+		err = vS.inodeVolumeHandle.Link(vS.recycleBinDirInodeNumber, recycleBinEntryName(inodeNumber), inodeNumber, false)
+	}
+	if nil != err {
+		logger.ErrorfWithError(err, "RecycleBin of volume %s unable to accept inode 0x%016X (\"%s\")", vS.volumeName, inodeNumber, recycleBinPath)
+		recycled = false
+		return
+	}
+
+	globals.RecycleBinInodesRecycled.Add(1)
+
+	recycled = true
+	return
+}
+
+func recycleBinEntryName(inodeNumber inode.InodeNumber) (name string) {
+	name = fmt.Sprintf("%016X", uint64(inodeNumber))
+	return
+}
+
+// recycleBinFetchEntry returns the RecycleBinEntry describing the RecycleBin entry name referencing
+// inodeNumber. Callers hold at least a shared lock on both /<recycleBinDirName>/ and inodeNumber.
+func (vS *volumeStruct) recycleBinFetchEntry(name string, inodeNumber inode.InodeNumber) (recycleBinEntry RecycleBinEntry, err error) {
+	var (
+		deletionTimeAsBuf []byte
+		metadata          *inode.MetadataStruct
+		originalPathAsBuf []byte
+	)
+
+	metadata, err = vS.inodeVolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	recycleBinEntry = RecycleBinEntry{
+		Name:        name,
+		InodeNumber: inodeNumber,
+		InodeType:   metadata.InodeType,
+		Size:        metadata.Size,
+		UserID:      metadata.UserID,
+		GroupID:     metadata.GroupID,
+	}
+
+	originalPathAsBuf, err = vS.inodeVolumeHandle.GetStream(inodeNumber, recycleBinPathStreamName)
+	if nil == err {
+		recycleBinEntry.OriginalPath = string(originalPathAsBuf)
+	}
+
+	deletionTimeAsBuf, err = vS.inodeVolumeHandle.GetStream(inodeNumber, recycleBinTimeStreamName)
+	if nil == err {
+		recycleBinEntry.DeletionTime, err = time.Parse(time.RFC3339Nano, string(deletionTimeAsBuf))
+		if nil != err {
+			recycleBinEntry.DeletionTime = time.Time{}
+		}
+	}
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) RecycleBinList(prevName string, maxEntries uint64) (recycleBinEntries []RecycleBinEntry, moreEntries bool, err error) {
+	var (
+		dirEntry            inode.DirEntry
+		dirEntrySlice       []inode.DirEntry
+		entryInodeLock      *dlm.RWLockStruct
+		readDirMaxEntries   uint64
+		recycleBinEntry     RecycleBinEntry
+		recycleBinInodeLock *dlm.RWLockStruct
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.RecycleBinListUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.RecycleBinListErrors.Add(1)
+		}
+	}()
+
+	if !vS.recycleBinEnabled() {
+		err = blunder.NewError(blunder.NotSupportedError, "RecycleBin not enabled for volume %s", vS.volumeName)
+		return
+	}
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	recycleBinInodeLock, err = vS.inodeVolumeHandle.GetReadLock(vS.recycleBinDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	defer recycleBinInodeLock.Unlock()
+
+	if (0 == maxEntries) || (recycleBinReadDirMaxEntries < maxEntries) {
+		readDirMaxEntries = recycleBinReadDirMaxEntries
+	} else {
+		readDirMaxEntries = maxEntries
+	}
+
+	if "" == prevName {
+		dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(vS.recycleBinDirInodeNumber, readDirMaxEntries, 0)
+	} else {
+		dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(vS.recycleBinDirInodeNumber, readDirMaxEntries, 0, prevName)
+	}
+	if nil != err {
+		return
+	}
+
+	recycleBinEntries = make([]RecycleBinEntry, 0, len(dirEntrySlice))
+
+	for _, dirEntry = range dirEntrySlice {
+		if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+			continue
+		}
+
+		entryInodeLock, err = vS.inodeVolumeHandle.GetReadLock(dirEntry.InodeNumber, nil)
+		if nil != err {
+			return
+		}
+
+		recycleBinEntry, err = vS.recycleBinFetchEntry(dirEntry.Basename, dirEntry.InodeNumber)
+
+		_ = entryInodeLock.Unlock()
+
+		if nil != err {
+			return
+		}
+
+		recycleBinEntries = append(recycleBinEntries, recycleBinEntry)
+	}
+
+	return
+}
+
+func (vS *volumeStruct) RecycleBinRestore(name string, path string) (restoredPath string, err error) {
+	var (
+		dirInodeNumber        inode.InodeNumber
+		entryInodeNumber      inode.InodeNumber
+		heldLocks             *heldLocksStruct
+		originalPathAsBuf     []byte
+		pathSplit             []string
+		retryRequired         bool
+		tryLockBackoffContext *tryLockBackoffContextStruct
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.RecycleBinRestoreUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.RecycleBinRestoreErrors.Add(1)
+		}
+	}()
+
+	if !vS.recycleBinEnabled() {
+		err = blunder.NewError(blunder.NotSupportedError, "RecycleBin not enabled for volume %s", vS.volumeName)
+		return
+	}
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	changeEvents := vS.newChangeEventPendingList()
+	defer vS.postChangeEvents(changeEvents)
+
+	// Retry until done or failure (starting with ZERO backoff)
+
+	tryLockBackoffContext = &tryLockBackoffContextStruct{}
+
+Restart:
+
+	// Perform backoff and update for each restart (starting with ZERO backoff of course)
+
+	tryLockBackoffContext.backoff()
+
+	// Construct fresh heldLocks for this restart
+
+	heldLocks = newHeldLocks()
+
+	retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), vS.recycleBinDirInodeNumber)
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	entryInodeNumber, err = vS.inodeVolumeHandle.Lookup(vS.recycleBinDirInodeNumber, name)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), entryInodeNumber)
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	// Determine where to restore the entry
+
+	if "" == path {
+		originalPathAsBuf, err = vS.inodeVolumeHandle.GetStream(entryInodeNumber, recycleBinPathStreamName)
+		if nil != err {
+			heldLocks.free()
+			err = blunder.NewError(blunder.InvalidArgError, "RecycleBin entry %s has no original path", name)
+			return
+		}
+		restoredPath = string(originalPathAsBuf)
+	} else {
+		restoredPath = path
+	}
+
+	pathSplit, err = canonicalizePath(restoredPath)
+	if (nil != err) || (0 == len(pathSplit)) || (recycleBinDirName == pathSplit[0]) {
+		heldLocks.free()
+		err = blunder.NewError(blunder.InvalidArgError, "RecycleBin entry %s cannot be restored to \"%s\"", name, restoredPath)
+		return
+	}
+
+	restoredPath = "/" + strings.Join(pathSplit, "/")
+
+	_, dirInodeNumber, _, _, retryRequired, err =
+		vS.resolvePath(
+			inode.RootDirInodeNumber,
+			"/"+strings.Join(pathSplit[:len(pathSplit)-1], "/"),
+			heldLocks,
+			resolvePathFollowDirSymlinks|
+				resolvePathDirEntryInodeMustBeDirectory|
+				resolvePathRequireExclusiveLockOnDirEntryInode)
+
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	// Now move the entry back into place (failing if something else is already there)
+
+	_, err = vS.inodeVolumeHandle.Move(vS.recycleBinDirInodeNumber, name, dirInodeNumber, pathSplit[len(pathSplit)-1], inode.MoveFlagNoReplace)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	_ = vS.inodeVolumeHandle.DeleteStream(entryInodeNumber, recycleBinPathStreamName)
+	_ = vS.inodeVolumeHandle.DeleteStream(entryInodeNumber, recycleBinTimeStreamName)
+
+	changeEvents.add(changeEventPendingStruct{changeEventType: ChangeEventCreate, inodeNumber: entryInodeNumber, dirInodeNumber: dirInodeNumber, basename: pathSplit[len(pathSplit)-1], path: restoredPath})
+
+	heldLocks.free()
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) RecycleBinPurge(name string) (err error) {
+	startTime := time.Now()
+	defer func() {
+		globals.RecycleBinPurgeUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.RecycleBinPurgeErrors.Add(1)
+		}
+	}()
+
+	if !vS.recycleBinEnabled() {
+		err = blunder.NewError(blunder.NotSupportedError, "RecycleBin not enabled for volume %s", vS.volumeName)
+		return
+	}
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	err = vS.recycleBinPurgeEntry(name, nil)
+
+	return
+}
+
+// recycleBinPurgeEntry destroys the RecycleBin entry name. If olderThan is non-nil, the entry is
+// only purged if it was placed in the RecycleBin before *olderThan. Callers hold a shared lock on
+// vS.jobRWMutex.
+func (vS *volumeStruct) recycleBinPurgeEntry(name string, olderThan *time.Time) (err error) {
+	var (
+		entryInodeNumber      inode.InodeNumber
+		heldLocks             *heldLocksStruct
+		metadata              *inode.MetadataStruct
+		numDirEntries         uint64
+		recycleBinEntry       RecycleBinEntry
+		retryRequired         bool
+		toDestroyInodeNumber  inode.InodeNumber
+		tryLockBackoffContext *tryLockBackoffContextStruct
+	)
+
+	// Retry until done or failure (starting with ZERO backoff)
+
+	tryLockBackoffContext = &tryLockBackoffContextStruct{}
+
+Restart:
+
+	// Perform backoff and update for each restart (starting with ZERO backoff of course)
+
+	tryLockBackoffContext.backoff()
+
+	// Construct fresh heldLocks for this restart
+
+	heldLocks = newHeldLocks()
+
+	retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), vS.recycleBinDirInodeNumber)
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	entryInodeNumber, err = vS.inodeVolumeHandle.Lookup(vS.recycleBinDirInodeNumber, name)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	retryRequired = heldLocks.attemptExclusiveLock(vS.inodeVolumeHandle, dlm.GenerateCallerID(), entryInodeNumber)
+	if retryRequired {
+		heldLocks.free()
+		goto Restart
+	}
+
+	if nil != olderThan {
+		recycleBinEntry, err = vS.recycleBinFetchEntry(name, entryInodeNumber)
+		if nil != err {
+			heldLocks.free()
+			return
+		}
+		if !recycleBinEntry.DeletionTime.Before(*olderThan) {
+			heldLocks.free()
+			return
+		}
+	}
+
+	metadata, err = vS.inodeVolumeHandle.GetMetadata(entryInodeNumber)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	if inode.DirType == metadata.InodeType {
+		numDirEntries, err = vS.inodeVolumeHandle.NumDirEntries(entryInodeNumber)
+		if nil != err {
+			heldLocks.free()
+			return
+		}
+
+		if 2 != numDirEntries {
+			heldLocks.free()
+			err = blunder.NewError(blunder.NotEmptyError, "RecycleBin entry %s not empty", name)
+			return
+		}
+	}
+
+	toDestroyInodeNumber, err = vS.inodeVolumeHandle.Unlink(vS.recycleBinDirInodeNumber, name, false)
+	if nil != err {
+		heldLocks.free()
+		return
+	}
+
+	if inode.InodeNumber(0) != toDestroyInodeNumber {
+		vS.untrackInFlightFileInodeData(entryInodeNumber, false)
+		err = vS.inodeVolumeHandle.Destroy(toDestroyInodeNumber)
+		if nil != err {
+			logger.ErrorfWithError(err, "RecycleBin of volume %s failed to Destroy inode 0x%016X", vS.volumeName, toDestroyInodeNumber)
+		}
+	}
+
+	globals.RecycleBinInodesPurged.Add(1)
+
+	heldLocks.free()
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) startRecycleBinPurgeDaemon() {
+	if !vS.recycleBinEnabled() {
+		vS.recycleBinStopChan = nil
+		return
+	}
+
+	vS.recycleBinStopChan = make(chan struct{})
+	vS.recycleBinWG.Add(1)
+
+	go vS.recycleBinPurgeDaemon()
+}
+
+func (vS *volumeStruct) stopRecycleBinPurgeDaemon() {
+	if nil == vS.recycleBinStopChan {
+		return
+	}
+
+	close(vS.recycleBinStopChan)
+	vS.recycleBinWG.Wait()
+
+	vS.recycleBinStopChan = nil
+}
+
+func (vS *volumeStruct) recycleBinPurgeDaemon() {
+	defer vS.recycleBinWG.Done()
+
+	for {
+		select {
+		case <-vS.recycleBinStopChan:
+			return
+		case <-time.After(vS.recycleBinPurgeInterval):
+			vS.recycleBinPurgeExpired()
+		}
+	}
+}
+
+// recycleBinPurgeExpired purges all RecycleBin entries older than RecycleBinRetention.
+func (vS *volumeStruct) recycleBinPurgeExpired() {
+	var (
+		err               error
+		moreEntries       bool
+		olderThan         time.Time
+		prevName          string
+		recycleBinEntries []RecycleBinEntry
+		recycleBinEntry   RecycleBinEntry
+	)
+
+	olderThan = time.Now().Add(-vS.recycleBinRetention)
+
+	prevName = ""
+	moreEntries = true
+
+	for moreEntries {
+		recycleBinEntries, moreEntries, err = vS.RecycleBinList(prevName, recycleBinReadDirMaxEntries)
+		if nil != err {
+			logger.ErrorfWithError(err, "RecycleBin of volume %s unable to list entries", vS.volumeName)
+			return
+		}
+
+		if 0 == len(recycleBinEntries) {
+			return
+		}
+
+		for _, recycleBinEntry = range recycleBinEntries {
+			select {
+			case <-vS.recycleBinStopChan:
+				return
+			default:
+			}
+
+			if recycleBinEntry.DeletionTime.Before(olderThan) {
+				vS.jobRWMutex.RLock()
+				err = vS.recycleBinPurgeEntry(recycleBinEntry.Name, &olderThan)
+				vS.jobRWMutex.RUnlock()
+				if nil != err {
+					logger.WarnfWithError(err, "RecycleBin of volume %s unable to purge entry %s", vS.volumeName, recycleBinEntry.Name)
+				}
+			}
+		}
+
+		prevName = recycleBinEntries[len(recycleBinEntries)-1].Name
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func testRecycleBinList(t *testing.T) (recycleBinEntries []RecycleBinEntry) {
+	recycleBinEntries, moreEntries, err := testVolumeStruct.RecycleBinList("", 0)
+	if nil != err {
+		t.Fatalf("RecycleBinList() failed: %v", err)
+	}
+	if moreEntries {
+		t.Fatalf("RecycleBinList() unexpectedly returned moreEntries == true")
+	}
+	return
+}
+
+func TestRecycleBin(t *testing.T) {
+	testSetup(t, false)
+
+	_, _, err := testVolumeStruct.RecycleBinList("", 0)
+	if !blunder.Is(err, blunder.NotSupportedError) {
+		t.Fatalf("RecycleBinList() with RecycleBin disabled should have failed with NotSupportedError - got: %v", err)
+	}
+
+	testVolumeStruct.recycleBinRetention = time.Hour
+	testVolumeStruct.establishRecycleBin()
+	if !testVolumeStruct.recycleBinEnabled() {
+		t.Fatalf("establishRecycleBin() failed")
+	}
+
+	recycleBinDirInodeNumber, err := testVolumeStruct.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, recycleBinDirName)
+	if nil != err {
+		t.Fatalf("Lookup() of /%s failed: %v", recycleBinDirName, err)
+	}
+	if testVolumeStruct.recycleBinDirInodeNumber != recycleBinDirInodeNumber {
+		t.Fatalf("Lookup() of /%s returned unexpected InodeNumber", recycleBinDirName)
+	}
+
+	dirInodeNumber, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TrashDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+
+	// Unlink() places a file in the RecycleBin from which it may be restored
+
+	fileInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	fileData := []byte{0x00, 0x01, 0x02, 0x03}
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, fileData, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+
+	recycleBinEntries := testRecycleBinList(t)
+	if (1 != len(recycleBinEntries)) || (fileInodeNumber != recycleBinEntries[0].InodeNumber) || ("/TrashDir/File" != recycleBinEntries[0].OriginalPath) {
+		t.Fatalf("RecycleBinList() after Unlink() returned unexpected entries: %+v", recycleBinEntries)
+	}
+	if (inode.FileType != recycleBinEntries[0].InodeType) || (uint64(len(fileData)) != recycleBinEntries[0].Size) || recycleBinEntries[0].DeletionTime.IsZero() {
+		t.Fatalf("RecycleBinList() after Unlink() returned unexpected entry: %+v", recycleBinEntries[0])
+	}
+
+	restoredPath, err := testVolumeStruct.RecycleBinRestore(recycleBinEntries[0].Name, "")
+	if nil != err {
+		t.Fatalf("RecycleBinRestore() failed: %v", err)
+	}
+	if "/TrashDir/File" != restoredPath {
+		t.Fatalf("RecycleBinRestore() returned unexpected restoredPath: \"%s\"", restoredPath)
+	}
+
+	restoredInodeNumber, err := testVolumeStruct.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File")
+	if (nil != err) || (fileInodeNumber != restoredInodeNumber) {
+		t.Fatalf("Lookup() after RecycleBinRestore() returned unexpected result (err: %v)", err)
+	}
+	readData, err := testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, uint64(len(fileData)), nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if !bytes.Equal(fileData, readData) {
+		t.Fatalf("Read() after RecycleBinRestore() returned unexpected data")
+	}
+	_, err = testVolumeStruct.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, recycleBinPathStreamName)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("GetXAttr() after RecycleBinRestore() should have failed with StreamNotFound - got: %v", err)
+	}
+
+	if 0 != len(testRecycleBinList(t)) {
+		t.Fatalf("RecycleBinList() after RecycleBinRestore() should have been empty")
+	}
+
+	// Rmdir() places a directory in the RecycleBin that cannot be restored over an existing entry
+
+	subDirInodeNumber, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Sub", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	err = testVolumeStruct.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Sub")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+
+	recycleBinEntries = testRecycleBinList(t)
+	if (1 != len(recycleBinEntries)) || (subDirInodeNumber != recycleBinEntries[0].InodeNumber) || (inode.DirType != recycleBinEntries[0].InodeType) {
+		t.Fatalf("RecycleBinList() after Rmdir() returned unexpected entries: %+v", recycleBinEntries)
+	}
+
+	_, err = testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Sub", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	_, err = testVolumeStruct.RecycleBinRestore(recycleBinEntries[0].Name, "")
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("RecycleBinRestore() over an existing entry should have failed with FileExistsError - got: %v", err)
+	}
+	_, err = testVolumeStruct.RecycleBinRestore(recycleBinEntries[0].Name, "/"+recycleBinDirName+"/Elsewhere")
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("RecycleBinRestore() into the RecycleBin should have failed with InvalidArgError - got: %v", err)
+	}
+
+	err = testVolumeStruct.RecycleBinPurge(recycleBinEntries[0].Name)
+	if nil != err {
+		t.Fatalf("RecycleBinPurge() failed: %v", err)
+	}
+	err = testVolumeStruct.RecycleBinPurge(recycleBinEntries[0].Name)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("RecycleBinPurge() of a purged entry should have failed with NotFoundError - got: %v", err)
+	}
+
+	if 0 != len(testRecycleBinList(t)) {
+		t.Fatalf("RecycleBinList() after RecycleBinPurge() should have been empty")
+	}
+
+	// Rename() over an existing file places the replaced file in the RecycleBin
+
+	_, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Src", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	dstInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Dst", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Src", dirInodeNumber, "Dst", inode.MoveFlagsNone)
+	if nil != err {
+		t.Fatalf("Rename() failed: %v", err)
+	}
+
+	recycleBinEntries = testRecycleBinList(t)
+	if (1 != len(recycleBinEntries)) || (dstInodeNumber != recycleBinEntries[0].InodeNumber) || ("/TrashDir/Dst" != recycleBinEntries[0].OriginalPath) {
+		t.Fatalf("RecycleBinList() after Rename() returned unexpected entries: %+v", recycleBinEntries)
+	}
+
+	restoredPath, err = testVolumeStruct.RecycleBinRestore(recycleBinEntries[0].Name, "/TrashDir/Dst.restored")
+	if nil != err {
+		t.Fatalf("RecycleBinRestore() to an alternate path failed: %v", err)
+	}
+	if "/TrashDir/Dst.restored" != restoredPath {
+		t.Fatalf("RecycleBinRestore() returned unexpected restoredPath: \"%s\"", restoredPath)
+	}
+	restoredInodeNumber, err = testVolumeStruct.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Dst.restored")
+	if (nil != err) || (dstInodeNumber != restoredInodeNumber) {
+		t.Fatalf("Lookup() after RecycleBinRestore() to an alternate path returned unexpected result (err: %v)", err)
+	}
+
+	// Entries older than RecycleBinRetention are purged
+
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Dst.restored")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+
+	testVolumeStruct.recycleBinPurgeExpired()
+
+	if 1 != len(testRecycleBinList(t)) {
+		t.Fatalf("recycleBinPurgeExpired() should not have purged an unexpired entry")
+	}
+
+	testVolumeStruct.recycleBinRetention = time.Nanosecond
+
+	testVolumeStruct.recycleBinPurgeExpired()
+
+	if 0 != len(testRecycleBinList(t)) {
+		t.Fatalf("recycleBinPurgeExpired() should have purged an expired entry")
+	}
+
+	testVolumeStruct.recycleBinRetention = time.Duration(0)
+
+	testTeardown(t)
+}
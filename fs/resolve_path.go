@@ -143,6 +143,15 @@ func (heldLocks *heldLocksStruct) unlock(inodeNumber inode.InodeNumber) {
 	logger.Fatalf("Attempt to unlock a non-held Lock on inodeNumber 0x%016X", inodeNumber)
 }
 
+func (heldLocks *heldLocksStruct) holds(inodeNumber inode.InodeNumber) (held bool) {
+	_, held = heldLocks.exclusive[inodeNumber]
+	if !held {
+		_, held = heldLocks.shared[inodeNumber]
+	}
+
+	return
+}
+
 func (heldLocks *heldLocksStruct) free() {
 	var (
 		err      error
@@ -675,6 +684,13 @@ RestartAfterFollowingSymlink:
 					}
 				}
 
+				// The WORM state of dirInodeNumber must permit adding an entry
+
+				err = vS.wormCheck(dirInodeNumber, wormOpAddEntry)
+				if nil != err {
+					return
+				}
+
 				// Create missing {Dir|File}Inode (cannot implicitly create a SymlinkInode)
 
 				if (pathSplitPartIndex < (len(pathSplit) - 1)) || resolvePathOptionsCheck(options, resolvePathDirEntryInodeMustBeDirectory) {
@@ -720,7 +736,10 @@ RestartAfterFollowingSymlink:
 
 				// Now insert created {Dir|File}Inode
 
-				internalErr = inodeVolumeHandle.Link(dirInodeNumber, pathSplitPart, dirEntryInodeNumber, false)
+				internalErr = vS.inheritStreams(dirInodeNumber, dirEntryInodeNumber, dirEntryInodeType)
+				if nil == internalErr {
+					internalErr = inodeVolumeHandle.Link(dirInodeNumber, pathSplitPart, dirEntryInodeNumber, false)
+				}
 				if nil != internalErr {
 					err = blunder.NewError(blunder.PermDeniedError, "resolvePath(): failed to Link created {Dir|File}Inode into path %s: %v", path, internalErr)
 					internalErr = inodeVolumeHandle.Destroy(dirEntryInodeNumber)
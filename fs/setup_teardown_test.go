@@ -67,6 +67,10 @@ func testSetup(t *testing.T, starvationMode bool) {
 		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.ContainerNamePrefix=Replicated3Way_",
 		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.ContainersPerPeer=10",
 		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.MaxObjectsPerContainer=1000000",
+		"PhysicalContainerLayout:PhysicalContainerLayoutCold.ContainerStoragePolicy=bronze",
+		"PhysicalContainerLayout:PhysicalContainerLayoutCold.ContainerNamePrefix=Cold_",
+		"PhysicalContainerLayout:PhysicalContainerLayoutCold.ContainersPerPeer=10",
+		"PhysicalContainerLayout:PhysicalContainerLayoutCold.MaxObjectsPerContainer=1000000",
 		"Peer:Peer0.PublicIPAddr=127.0.0.1",
 		"Peer:Peer0.PrivateIPAddr=127.0.0.1",
 		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
@@ -80,6 +84,7 @@ func testSetup(t *testing.T, starvationMode bool) {
 		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
 		"Volume:TestVolume.CheckpointInterval=10s",
 		"Volume:TestVolume.DefaultPhysicalContainerLayout=PhysicalContainerLayoutReplicated3Way",
+		"Volume:TestVolume.PhysicalContainerLayoutList=PhysicalContainerLayoutCold",
 		"Volume:TestVolume.MaxFlushSize=10485760",
 		"Volume:TestVolume.MaxFlushTime=10s",
 		"Volume:TestVolume.FileDefragmentChunkSize=10485760",
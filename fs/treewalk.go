@@ -1148,7 +1148,7 @@ func (vVS *validateVolumeStruct) validateVolume() {
 
 	vVS.jobLogInfo("Completed checkpoint after FSCK work")
 
-	// Validate headhunter checkpoint container contents
+	// Validate headhunter checkpoint container contents (including objects retained checkpoints depend upon)
 
 	headhunterLayoutReport, discrepencies, err = vVS.headhunterVolumeHandle.FetchLayoutReport(headhunter.MergedBPlusTree, true)
 	if nil != err {
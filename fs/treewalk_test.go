@@ -0,0 +1,105 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NVIDIA/proxyfs/inode"
+	"github.com/NVIDIA/proxyfs/transitions"
+)
+
+func TestValidateVolumePreservesRetainedCheckpoints(t *testing.T) {
+	testSetup(t, false)
+
+	// Remount TestVolume retaining superseded checkpoints
+
+	err := transitions.Down(testConfMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() failed: %v", err)
+	}
+	err = testConfMap.UpdateFromString("Volume:TestVolume.CheckpointRetentionCount=4")
+	if nil != err {
+		t.Fatalf("testConfMap.UpdateFromString() failed: %v", err)
+	}
+	err = transitions.Up(testConfMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() failed: %v", err)
+	}
+	testVolumeHandle, err := FetchVolumeHandleByVolumeName("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandleByVolumeName() failed: %v", err)
+	}
+	testVolumeStruct = testVolumeHandle.(*volumeStruct)
+
+	// Capture a file in a checkpoint and then supersede it with one lacking the file
+
+	fileInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Retained", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	fileData := []byte("retained checkpoint contents")
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, fileData, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeStruct.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+	err = testVolumeStruct.headhunterVolumeHandle.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() [file present] failed: %v", err)
+	}
+
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Retained")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+	err = testVolumeStruct.headhunterVolumeHandle.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() [file removed] failed: %v", err)
+	}
+
+	retainedCheckpointList := testVolumeStruct.headhunterVolumeHandle.RetainedCheckpointList()
+	if 0 == len(retainedCheckpointList) {
+		t.Fatalf("RetainedCheckpointList() unexpectedly empty")
+	}
+	retainedCheckpointID := retainedCheckpointList[len(retainedCheckpointList)-1].ID
+
+	// Validate the volume...which must leave the objects of retained checkpoints intact
+
+	validateVolumeHandle := ValidateVolume("TestVolume")
+	validateVolumeHandle.Wait()
+	if 0 != len(validateVolumeHandle.Error()) {
+		t.Fatalf("ValidateVolume() reported errors: %v", validateVolumeHandle.Error())
+	}
+
+	// Inspect the retained checkpoint that captured the file
+
+	err = testVolumeStruct.RetainedCheckpointInspect(retainedCheckpointID)
+	if nil != err {
+		t.Fatalf("RetainedCheckpointInspect(%d) failed: %v", retainedCheckpointID, err)
+	}
+
+	inspectedFileInodeNumber, err := testVolumeStruct.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Retained")
+	if nil != err {
+		t.Fatalf("Lookup() in retained checkpoint failed: %v", err)
+	}
+	readData, err := testVolumeStruct.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inspectedFileInodeNumber, 0, uint64(len(fileData)), nil)
+	if nil != err {
+		t.Fatalf("Read() in retained checkpoint failed: %v", err)
+	}
+	if !bytes.Equal(fileData, readData) {
+		t.Fatalf("Read() in retained checkpoint returned unexpected data")
+	}
+
+	err = testVolumeStruct.RetainedCheckpointInspect(0)
+	if nil != err {
+		t.Fatalf("RetainedCheckpointInspect(0) failed: %v", err)
+	}
+
+	testTeardown(t)
+}
@@ -0,0 +1,374 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+// An Inode's WORM (write once, read many) state is held in three Streams (see inode.ImmutableStreamName,
+// inode.AppendOnlyStreamName, and inode.RetainUntilStreamName) that may be set via SetXAttr() or
+// SetWORMState() by the Inode's owner (or root). Until its RetainUntil time has passed, an Inode is
+// treated as Immutable...and its RetainUntil time may be extended but never shortened.
+//
+// The rules enforced by wormCheck() are:
+//
+//   Immutable  - contents, attributes, and (for a DirInode) entries may not be changed, nor may it be removed
+//   AppendOnly - contents may only be extended, attributes may not be changed, it may not be removed, and
+//                (for a DirInode) entries may be added but not removed (nor renamed)
+//   Retained   - as for Immutable except that (for a DirInode) entries may be added
+//
+// Note that the WORM Streams themselves may still be set by the owner. As with CAP_LINUX_IMMUTABLE,
+// however, only root may clear (or remove) a set Immutable or AppendOnly flag returning an Inode to
+// a mutable state...and even then only once its RetainUntil time has passed.
+
+type wormOpType uint8
+
+const (
+	wormOpModify      wormOpType = iota // change contents or attributes of an Inode
+	wormOpAppend                        // extend the contents of a FileInode
+	wormOpRemove                        // unlink (or replace) an Inode
+	wormOpAddEntry                      // add an entry to a DirInode
+	wormOpRemoveEntry                   // remove an entry from a DirInode
+)
+
+// wormCheck returns a NotPermError if the WORM state of inodeNumber prohibits wormOp.
+// The caller is expected to hold a lock on inodeNumber.
+func (vS *volumeStruct) wormCheck(inodeNumber inode.InodeNumber, wormOp wormOpType) (err error) {
+	var (
+		denied    bool
+		retained  bool
+		wormState inode.WORMStateStruct
+	)
+
+	wormState, err = vS.inodeVolumeHandle.GetWORMState(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	retained = wormState.Retained(time.Now())
+
+	switch wormOp {
+	case wormOpModify, wormOpRemove, wormOpRemoveEntry:
+		denied = wormState.Immutable || wormState.AppendOnly || retained
+	case wormOpAppend:
+		denied = wormState.Immutable || retained
+	case wormOpAddEntry:
+		denied = wormState.Immutable
+	}
+
+	if denied {
+		err = fmt.Errorf("inode %d volume '%s' WORM state (%+v) prohibits the requested operation", inodeNumber, vS.volumeName, wormState)
+		err = blunder.AddError(err, blunder.NotPermError)
+	}
+
+	return
+}
+
+// wormCheckUnlink is the wormCheck() applied to removing inodeNumber from dirInodeNumber.
+func (vS *volumeStruct) wormCheckUnlink(dirInodeNumber inode.InodeNumber, inodeNumber inode.InodeNumber) (err error) {
+	err = vS.wormCheck(dirInodeNumber, wormOpRemoveEntry)
+	if nil == err {
+		err = vS.wormCheck(inodeNumber, wormOpRemove)
+	}
+	return
+}
+
+// wormCheckWrite is a wormCheck() of a write starting at offset that is treated as an
+// append if it starts at (or beyond) the current end of the file.
+func (vS *volumeStruct) wormCheckWrite(inodeNumber inode.InodeNumber, offset uint64) (err error) {
+	var (
+		metadata *inode.MetadataStruct
+	)
+
+	metadata, err = vS.inodeVolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if offset >= metadata.Size {
+		err = vS.wormCheck(inodeNumber, wormOpAppend)
+	} else {
+		err = vS.wormCheck(inodeNumber, wormOpModify)
+	}
+
+	return
+}
+
+// wroteMinFileOffset returns the lowest of the fileOffset's passed to Wrote() such that
+// a wormCheckWrite() of it covers all of them.
+func wroteMinFileOffset(fileOffset []uint64) (minFileOffset uint64) {
+	minFileOffset = ^uint64(0)
+
+	for _, offset := range fileOffset {
+		if offset < minFileOffset {
+			minFileOffset = offset
+		}
+	}
+
+	return
+}
+
+// isWORMStreamName returns whether or not streamName is one of the WORM Streams.
+func isWORMStreamName(streamName string) bool {
+	switch streamName {
+	case inode.ImmutableStreamName, inode.AppendOnlyStreamName, inode.RetainUntilStreamName:
+		return true
+	default:
+		return false
+	}
+}
+
+// wormCheckClear returns a NotPermError if a non-root userID would clear a set Immutable
+// or AppendOnly flag of inodeNumber by leaving them as immutable and appendOnly.
+func (vS *volumeStruct) wormCheckClear(userID inode.InodeUserID, inodeNumber inode.InodeNumber, immutable bool, appendOnly bool) (err error) {
+	var (
+		wormState inode.WORMStateStruct
+	)
+
+	if inode.InodeRootUserID == userID {
+		return
+	}
+
+	wormState, err = vS.inodeVolumeHandle.GetWORMState(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if (wormState.Immutable && !immutable) || (wormState.AppendOnly && !appendOnly) {
+		err = fmt.Errorf("inode %d volume '%s' WORM flags (%+v) may only be cleared by root", inodeNumber, vS.volumeName, wormState)
+		err = blunder.AddError(err, blunder.NotPermError)
+	}
+
+	return
+}
+
+// wormCheckXAttr is the wormCheck() applied to setting streamName to value (or removing it
+// if value == nil). The WORM Streams themselves may only be changed by the owner (or root)
+// with only root able to clear a set flag...subject to the inode package's refusal to
+// shorten (or remove) an unexpired RetainUntil time.
+func (vS *volumeStruct) wormCheckXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, value []byte) (err error) {
+	var (
+		wormState inode.WORMStateStruct
+	)
+
+	if !isWORMStreamName(streamName) {
+		err = vS.wormCheck(inodeNumber, wormOpModify)
+		return
+	}
+
+	if !vS.inodeVolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.P_OK,
+		inode.NoOverride) {
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+		return
+	}
+
+	on := (nil != value) && (inode.WORMStreamValueOn == string(value))
+
+	// Only the flag named by streamName may be cleared
+
+	switch streamName {
+	case inode.ImmutableStreamName:
+		wormState.Immutable = on
+		wormState.AppendOnly = true
+	case inode.AppendOnlyStreamName:
+		wormState.Immutable = true
+		wormState.AppendOnly = on
+	default:
+		return // RetainUntil is guarded by the inode package
+	}
+
+	err = vS.wormCheckClear(userID, inodeNumber, wormState.Immutable, wormState.AppendOnly)
+
+	return
+}
+
+// wormCheckSnapShotRestore returns a NotPermError if the WORM state of the live Inode inodeNumber
+// (should it exist) prohibits replacing it with its version in a SnapShot.
+func (vS *volumeStruct) wormCheckSnapShotRestore(inodeNumber inode.InodeNumber) (err error) {
+	_, err = vS.inodeVolumeHandle.GetType(inodeNumber)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = nil
+		}
+		return
+	}
+
+	err = vS.wormCheck(inodeNumber, wormOpModify)
+
+	return
+}
+
+// wormCheckRevert returns a NotPermError if any Inode in the live view is currently Retained as
+// reverting the entire live view (to a SnapShot or retained checkpoint) would discard or alter it.
+// As this walks the entire namespace, the caller is expected to hold vS.jobRWMutex exclusively.
+func (vS *volumeStruct) wormCheckRevert() (err error) {
+	var (
+		dirEntry       inode.DirEntry
+		dirEntrySlice  []inode.DirEntry
+		dirInodeNumber inode.InodeNumber
+		dirInodeList   []inode.InodeNumber
+		inodeType      inode.InodeType
+		moreEntries    bool
+		now            time.Time
+		prevReturned   string
+		wormState      inode.WORMStateStruct
+	)
+
+	now = time.Now()
+
+	dirInodeList = []inode.InodeNumber{inode.RootDirInodeNumber}
+
+	for 0 < len(dirInodeList) {
+		dirInodeNumber = dirInodeList[0]
+		dirInodeList = dirInodeList[1:]
+
+		prevReturned = ""
+		moreEntries = true
+
+		for moreEntries {
+			if "" == prevReturned {
+				dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(dirInodeNumber, snapShotDiffReadDirMaxEntries, 0)
+			} else {
+				dirEntrySlice, moreEntries, err = vS.inodeVolumeHandle.ReadDir(dirInodeNumber, snapShotDiffReadDirMaxEntries, 0, prevReturned)
+			}
+			if nil != err {
+				return
+			}
+
+			for _, dirEntry = range dirEntrySlice {
+				prevReturned = dirEntry.Basename
+
+				if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+					continue
+				}
+				if (inode.RootDirInodeNumber == dirInodeNumber) && (inode.SnapShotDirName == dirEntry.Basename) {
+					continue
+				}
+
+				wormState, err = vS.inodeVolumeHandle.GetWORMState(dirEntry.InodeNumber)
+				if nil != err {
+					return
+				}
+				if wormState.Retained(now) {
+					err = fmt.Errorf("inode %d volume '%s' is retained until %v", dirEntry.InodeNumber, vS.volumeName, wormState.RetainUntil)
+					err = blunder.AddError(err, blunder.NotPermError)
+					return
+				}
+
+				inodeType, err = vS.inodeVolumeHandle.GetType(dirEntry.InodeNumber)
+				if nil != err {
+					return
+				}
+				if inode.DirType == inodeType {
+					dirInodeList = append(dirInodeList, dirEntry.InodeNumber)
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func (vS *volumeStruct) GetWORMState(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (wormState inode.WORMStateStruct, err error) {
+	startTime := time.Now()
+	defer func() {
+		globals.GetWORMStateUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.GetWORMStateErrors.Add(1)
+		}
+	}()
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	inodeLock, err := vS.inodeVolumeHandle.InitInodeLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if err != nil {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !vS.inodeVolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK,
+		inode.NoOverride) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+
+	wormState, err = vS.inodeVolumeHandle.GetWORMState(inodeNumber)
+
+	return
+}
+
+func (vS *volumeStruct) SetWORMState(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, wormState inode.WORMStateStruct) (err error) {
+	startTime := time.Now()
+	defer func() {
+		globals.SetWORMStateUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.SetWORMStateErrors.Add(1)
+		}
+	}()
+
+	vS.jobRWMutex.RLock()
+	defer vS.jobRWMutex.RUnlock()
+
+	inodeLock, err := vS.inodeVolumeHandle.InitInodeLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+	err = inodeLock.WriteLock()
+	if err != nil {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !vS.inodeVolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK,
+		inode.NoOverride) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !vS.inodeVolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.P_OK,
+		inode.NoOverride) {
+		err = blunder.NewError(blunder.NotPermError, "EPERM")
+		return
+	}
+	err = vS.wormCheckClear(userID, inodeNumber, wormState.Immutable, wormState.AppendOnly)
+	if nil != err {
+		return
+	}
+
+	// Apply RetainUntil first so that an attempt to shorten it leaves the flags untouched
+
+	if !wormState.RetainUntil.IsZero() {
+		err = vS.inodeVolumeHandle.PutStream(inodeNumber, inode.RetainUntilStreamName, []byte(wormState.RetainUntil.UTC().Format(time.RFC3339Nano)))
+		if nil != err {
+			return
+		}
+	}
+
+	err = vS.inodeVolumeHandle.PutStream(inodeNumber, inode.ImmutableStreamName, wormStreamValue(wormState.Immutable))
+	if nil != err {
+		return
+	}
+
+	err = vS.inodeVolumeHandle.PutStream(inodeNumber, inode.AppendOnlyStreamName, wormStreamValue(wormState.AppendOnly))
+
+	return
+}
+
+func wormStreamValue(on bool) (value []byte) {
+	if on {
+		value = []byte(inode.WORMStreamValueOn)
+	} else {
+		value = []byte(inode.WORMStreamValueOff)
+	}
+	return
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func testWORMCreate(t *testing.T, dirInodeNumber inode.InodeNumber, basename string, contents string) (fileInodeNumber inode.InodeNumber) {
+	fileInodeNumber, err := testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename, inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(,,,,\"%s\",) failed: %v", basename, err)
+	}
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, []byte(contents), nil)
+	if nil != err {
+		t.Fatalf("Write() to \"%s\" failed: %v", basename, err)
+	}
+	return
+}
+
+func testWORMExpectNotPerm(t *testing.T, err error, what string) {
+	if !blunder.Is(err, blunder.NotPermError) {
+		t.Fatalf("%s should have failed with NotPermError: %v", what, err)
+	}
+}
+
+func TestWORM(t *testing.T) {
+	testSetup(t, false)
+
+	// An Immutable FileInode may be neither modified nor removed
+
+	immutableInodeNumber := testWORMCreate(t, inode.RootDirInodeNumber, "Immutable", "immutable")
+
+	err := testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableInodeNumber, inode.WORMStateStruct{Immutable: true})
+	if nil != err {
+		t.Fatalf("SetWORMState(,,,,{Immutable: true}) failed: %v", err)
+	}
+
+	wormState, err := testVolumeStruct.GetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableInodeNumber)
+	if (nil != err) || !wormState.Immutable || wormState.AppendOnly || !wormState.RetainUntil.IsZero() {
+		t.Fatalf("GetWORMState() returned unexpected result %+v (err: %v)", wormState, err)
+	}
+
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableInodeNumber, 0, []byte("x"), nil)
+	testWORMExpectNotPerm(t, err, "Write() to Immutable FileInode")
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableInodeNumber, uint64(len("immutable")), []byte("x"), nil)
+	testWORMExpectNotPerm(t, err, "Write() appending to Immutable FileInode")
+	err = testVolumeStruct.Resize(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableInodeNumber, 0)
+	testWORMExpectNotPerm(t, err, "Resize() of Immutable FileInode")
+	err = testVolumeStruct.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableInodeNumber, Stat{StatMode: uint64(0400)})
+	testWORMExpectNotPerm(t, err, "Setstat() of Immutable FileInode")
+	err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableInodeNumber, "user.color", []byte("blue"), SetXAttrCreateOrReplace)
+	testWORMExpectNotPerm(t, err, "SetXAttr() of Immutable FileInode")
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Immutable")
+	testWORMExpectNotPerm(t, err, "Unlink() of Immutable FileInode")
+	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Immutable", inode.RootDirInodeNumber, "Renamed", inode.MoveFlagsNone)
+	testWORMExpectNotPerm(t, err, "Rename() of Immutable FileInode")
+
+	otherInodeNumber := testWORMCreate(t, inode.RootDirInodeNumber, "Other", "other")
+	err = testVolumeStruct.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Other", inode.RootDirInodeNumber, "Immutable", inode.MoveFlagsNone)
+	testWORMExpectNotPerm(t, err, "Rename() over Immutable FileInode")
+	err = testVolumeStruct.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Linked", immutableInodeNumber)
+	testWORMExpectNotPerm(t, err, "Link() to Immutable FileInode")
+
+	// Only the owner (or root) may change the WORM state
+
+	err = testVolumeStruct.SetXAttr(inode.InodeUserID(123), inode.InodeGroupID(0), nil, otherInodeNumber, inode.ImmutableStreamName, []byte(inode.WORMStreamValueOn), SetXAttrCreateOrReplace)
+	if !blunder.Is(err, blunder.NotPermError) && !blunder.Is(err, blunder.PermDeniedError) {
+		t.Fatalf("SetXAttr(,,,,inode.ImmutableStreamName,,) by non-owner should have failed: %v", err)
+	}
+
+	// The owner may set...but only root may clear...the Immutable and AppendOnly flags
+
+	ownedInodeNumber := testWORMCreate(t, inode.RootDirInodeNumber, "Owned", "owned")
+	err = testVolumeStruct.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ownedInodeNumber, Stat{StatUserID: 123})
+	if nil != err {
+		t.Fatalf("Setstat(,,,,{StatUserID: 123}) failed: %v", err)
+	}
+	err = testVolumeStruct.SetXAttr(inode.InodeUserID(123), inode.InodeGroupID(0), nil, ownedInodeNumber, inode.ImmutableStreamName, []byte(inode.WORMStreamValueOn), SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr(,,,,inode.ImmutableStreamName,\"on\",) by owner failed: %v", err)
+	}
+	err = testVolumeStruct.SetXAttr(inode.InodeUserID(123), inode.InodeGroupID(0), nil, ownedInodeNumber, inode.AppendOnlyStreamName, []byte(inode.WORMStreamValueOff), SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr(,,,,inode.AppendOnlyStreamName,\"off\",) of unset flag by owner failed: %v", err)
+	}
+	err = testVolumeStruct.SetXAttr(inode.InodeUserID(123), inode.InodeGroupID(0), nil, ownedInodeNumber, inode.ImmutableStreamName, []byte(inode.WORMStreamValueOff), SetXAttrCreateOrReplace)
+	testWORMExpectNotPerm(t, err, "SetXAttr(,,,,inode.ImmutableStreamName,\"off\",) by owner")
+	err = testVolumeStruct.RemoveXAttr(inode.InodeUserID(123), inode.InodeGroupID(0), nil, ownedInodeNumber, inode.ImmutableStreamName)
+	testWORMExpectNotPerm(t, err, "RemoveXAttr(,,,,inode.ImmutableStreamName) by owner")
+	err = testVolumeStruct.SetWORMState(inode.InodeUserID(123), inode.InodeGroupID(0), nil, ownedInodeNumber, inode.WORMStateStruct{AppendOnly: true})
+	testWORMExpectNotPerm(t, err, "SetWORMState(,,,,{AppendOnly: true}) of Immutable FileInode by owner")
+	err = testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ownedInodeNumber, inode.WORMStateStruct{})
+	if nil != err {
+		t.Fatalf("SetWORMState(,,,,{}) by root failed: %v", err)
+	}
+
+	// Clearing Immutable (absent a RetainUntil) restores mutability
+
+	err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableInodeNumber, inode.ImmutableStreamName, []byte(inode.WORMStreamValueOff), SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr(,,,,inode.ImmutableStreamName,\"off\",) failed: %v", err)
+	}
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Immutable")
+	if nil != err {
+		t.Fatalf("Unlink() of formerly Immutable FileInode failed: %v", err)
+	}
+
+	// An AppendOnly FileInode may only be extended
+
+	appendOnlyInodeNumber := testWORMCreate(t, inode.RootDirInodeNumber, "AppendOnly", "log")
+
+	err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, appendOnlyInodeNumber, inode.AppendOnlyStreamName, []byte(inode.WORMStreamValueOn), SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr(,,,,inode.AppendOnlyStreamName,\"on\",) failed: %v", err)
+	}
+	err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, appendOnlyInodeNumber, inode.AppendOnlyStreamName, []byte("maybe"), SetXAttrCreateOrReplace)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("SetXAttr(,,,,inode.AppendOnlyStreamName,\"maybe\",) should have failed with InvalidArgError: %v", err)
+	}
+
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, appendOnlyInodeNumber, uint64(len("log")), []byte("+more"), nil)
+	if nil != err {
+		t.Fatalf("Write() appending to AppendOnly FileInode failed: %v", err)
+	}
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, appendOnlyInodeNumber, 0, []byte("x"), nil)
+	testWORMExpectNotPerm(t, err, "Write() overwriting AppendOnly FileInode")
+	err = testVolumeStruct.Resize(inode.InodeRootUserID, inode.InodeGroupID(0), nil, appendOnlyInodeNumber, 0)
+	testWORMExpectNotPerm(t, err, "Resize() of AppendOnly FileInode")
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "AppendOnly")
+	testWORMExpectNotPerm(t, err, "Unlink() of AppendOnly FileInode")
+
+	// An AppendOnly DirInode accepts new entries but none may be removed
+
+	appendOnlyDirInodeNumber, err := testVolumeStruct.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "AppendOnlyDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	err = testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, appendOnlyDirInodeNumber, inode.WORMStateStruct{AppendOnly: true})
+	if nil != err {
+		t.Fatalf("SetWORMState(,,,,{AppendOnly: true}) of DirInode failed: %v", err)
+	}
+	_ = testWORMCreate(t, appendOnlyDirInodeNumber, "Entry", "entry")
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, appendOnlyDirInodeNumber, "Entry")
+	testWORMExpectNotPerm(t, err, "Unlink() from AppendOnly DirInode")
+
+	// An Immutable DirInode accepts no new entries...including those implicitly created by the middleware
+
+	err = testVolumeStruct.MiddlewarePutContainer("ImmutableContainer", []byte(""), []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() failed: %v", err)
+	}
+	immutableDirInodeNumber, err := testVolumeStruct.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "ImmutableContainer")
+	if nil != err {
+		t.Fatalf("Lookup() of container failed: %v", err)
+	}
+	err = testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableDirInodeNumber, inode.WORMStateStruct{Immutable: true})
+	if nil != err {
+		t.Fatalf("SetWORMState(,,,,{Immutable: true}) of DirInode failed: %v", err)
+	}
+	_, err = testVolumeStruct.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, immutableDirInodeNumber, "Entry", inode.PosixModePerm)
+	testWORMExpectNotPerm(t, err, "Create() in Immutable DirInode")
+	_, _, _, _, err = testVolumeStruct.MiddlewarePutComplete("ImmutableContainer", "Object", nil, nil, []byte("metadata"))
+	testWORMExpectNotPerm(t, err, "MiddlewarePutComplete() in Immutable DirInode")
+	_, _, _, _, err = testVolumeStruct.MiddlewareMkdir("ImmutableContainer", "Dir/SubDir", []byte("metadata"))
+	testWORMExpectNotPerm(t, err, "MiddlewareMkdir() in Immutable DirInode")
+
+	// A RetainUntil in the future prohibits modification and may be extended but not shortened
+
+	retainedInodeNumber := testWORMCreate(t, inode.RootDirInodeNumber, "Retained", "retained")
+
+	retainUntil := time.Now().Add(time.Hour)
+
+	err = testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, retainedInodeNumber, inode.WORMStateStruct{RetainUntil: retainUntil})
+	if nil != err {
+		t.Fatalf("SetWORMState(,,,,{RetainUntil: retainUntil}) failed: %v", err)
+	}
+
+	_, err = testVolumeStruct.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, retainedInodeNumber, 0, []byte("x"), nil)
+	testWORMExpectNotPerm(t, err, "Write() to retained FileInode")
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Retained")
+	testWORMExpectNotPerm(t, err, "Unlink() of retained FileInode")
+
+	err = testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, retainedInodeNumber, inode.WORMStateStruct{RetainUntil: retainUntil.Add(-time.Minute)})
+	testWORMExpectNotPerm(t, err, "SetWORMState() shortening RetainUntil")
+	err = testVolumeStruct.RemoveXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, retainedInodeNumber, inode.RetainUntilStreamName)
+	testWORMExpectNotPerm(t, err, "RemoveXAttr(,,,,inode.RetainUntilStreamName) before RetainUntil")
+
+	err = testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, retainedInodeNumber, inode.WORMStateStruct{RetainUntil: retainUntil.Add(time.Hour)})
+	if nil != err {
+		t.Fatalf("SetWORMState() extending RetainUntil failed: %v", err)
+	}
+
+	wormState, err = testVolumeStruct.GetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, retainedInodeNumber)
+	if (nil != err) || !wormState.RetainUntil.Equal(retainUntil.Add(time.Hour)) || !wormState.Retained(time.Now()) {
+		t.Fatalf("GetWORMState() after extending RetainUntil returned unexpected result %+v (err: %v)", wormState, err)
+	}
+
+	// A retained FileInode may not be discarded by reverting (or restoring) the live view
+
+	snapShotID, err := testVolumeStruct.inodeVolumeHandle.SnapShotCreate("WORM")
+	if nil != err {
+		t.Fatalf("SnapShotCreate() failed: %v", err)
+	}
+	err = testVolumeStruct.SnapShotRevert(snapShotID)
+	testWORMExpectNotPerm(t, err, "SnapShotRevert() with a retained FileInode")
+	err = testVolumeStruct.SnapShotRestore(snapShotID, "/Retained")
+	testWORMExpectNotPerm(t, err, "SnapShotRestore() of a retained FileInode")
+	err = testVolumeStruct.inodeVolumeHandle.SnapShotDelete(snapShotID)
+	if nil != err {
+		t.Fatalf("SnapShotDelete() failed: %v", err)
+	}
+
+	// A RetainUntil already passed imposes nothing
+
+	expiredInodeNumber := testWORMCreate(t, inode.RootDirInodeNumber, "Expired", "expired")
+
+	err = testVolumeStruct.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, expiredInodeNumber, inode.RetainUntilStreamName, []byte(time.Now().Add(-time.Hour).Format(time.RFC3339Nano)), SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr(,,,,inode.RetainUntilStreamName,,) failed: %v", err)
+	}
+	err = testVolumeStruct.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "Expired")
+	if nil != err {
+		t.Fatalf("Unlink() of FileInode with expired RetainUntil failed: %v", err)
+	}
+
+	// Middleware PUTs and DELETEs are subject to the same checks
+
+	err = testVolumeStruct.MiddlewarePutContainer("WORMContainer", []byte(""), []byte(""))
+	if nil != err {
+		t.Fatalf("MiddlewarePutContainer() failed: %v", err)
+	}
+	_, _, objectInodeNumber, _, err := testVolumeStruct.MiddlewarePutComplete("WORMContainer", "Object", nil, nil, []byte("first"))
+	if nil != err {
+		t.Fatalf("MiddlewarePutComplete() failed: %v", err)
+	}
+	err = testVolumeStruct.SetWORMState(inode.InodeRootUserID, inode.InodeGroupID(0), nil, objectInodeNumber, inode.WORMStateStruct{Immutable: true})
+	if nil != err {
+		t.Fatalf("SetWORMState(,,,,{Immutable: true}) of object failed: %v", err)
+	}
+	_, _, _, _, err = testVolumeStruct.MiddlewarePutComplete("WORMContainer", "Object", nil, nil, []byte("second"))
+	testWORMExpectNotPerm(t, err, "MiddlewarePutComplete() over Immutable object")
+	err = testVolumeStruct.MiddlewarePost("WORMContainer", "Object", []byte("second"), []byte("first"))
+	testWORMExpectNotPerm(t, err, "MiddlewarePost() to Immutable object")
+	err = testVolumeStruct.MiddlewareDelete("WORMContainer", "Object", 0)
+	testWORMExpectNotPerm(t, err, "MiddlewareDelete() of Immutable object")
+
+	testTeardown(t)
+}
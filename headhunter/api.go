@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/NVIDIA/sortedmap"
+
+	"github.com/NVIDIA/proxyfs/conf"
 )
 
 type BPlusTreeType uint32
@@ -36,6 +38,45 @@ type SnapShotStruct struct {
 	Name string
 }
 
+// RetainedCheckpointStruct describes a superseded checkpoint retained (per CheckpointRetentionCount) such
+// that the volume may be inspected at, or reverted to, that point in time.
+type RetainedCheckpointStruct struct {
+	ID   uint64    // objectNumber of the retained checkpoint's CheckpointObjectTrailerV3Struct
+	Time time.Time // when the retained checkpoint was taken (zero if unknown)
+}
+
+type InodeRecDiffType uint8
+
+const (
+	InodeRecDiffCreated  InodeRecDiffType = iota // InodeRec only present in "to" view
+	InodeRecDiffModified                         // InodeRec present in both views but different
+	InodeRecDiffDeleted                          // InodeRec only present in "from" view
+)
+
+// InodeRecDiffStruct is returned by FetchInodeRecDiff() for each InodeRec that differs
+// between two views. The InodeNumber is the nonce (i.e. without any SnapShotID encoding).
+type InodeRecDiffStruct struct {
+	InodeNumber uint64
+	DiffType    InodeRecDiffType
+}
+
+// DedupFingerprintStruct describes a chunk of a LogSegment eligible to be referenced by
+// subsequent writes of identical data.
+type DedupFingerprintStruct struct {
+	Fingerprint      []byte // at least 8 bytes (e.g. a sha256 of the chunk)
+	LogSegmentOffset uint64
+	Length           uint64
+}
+
+// DedupStatsStruct is returned by FetchDedupStats().
+type DedupStatsStruct struct {
+	ChunkedBytes    uint64 // total bytes written subject to deduplication
+	DedupedBytes    uint64 // ...of which were found to already be stored
+	Fingerprints    uint64 // number of chunks eligible to be referenced
+	LogSegments     uint64 // number of LogSegments tracked by reference count
+	RebuildRequired bool   // if true, LogSegment reference counts must be rebuilt
+}
+
 type VolumeEventListener interface {
 	CheckpointCompleted()
 }
@@ -45,6 +86,7 @@ type VolumeHandle interface {
 	RegisterForEvents(listener VolumeEventListener)
 	UnregisterForEvents(listener VolumeEventListener)
 	FetchAccountAndCheckpointContainerNames() (accountName string, checkpointContainerName string)
+	FetchObjectAccountName(objectNumber uint64) (accountName string)
 	FetchNonce() (nonce uint64)
 	GetInodeRec(inodeNumber uint64) (value []byte, ok bool, err error)
 	PutInodeRec(inodeNumber uint64, value []byte) (err error)
@@ -56,15 +98,36 @@ type VolumeHandle interface {
 	PutLogSegmentRec(logSegmentNumber uint64, value []byte) (err error)
 	DeleteLogSegmentRec(logSegmentNumber uint64) (err error)
 	IndexedLogSegmentNumber(index uint64) (logSegmentNumber uint64, ok bool, err error)
+	ReferenceDedupChunk(fingerprint []byte) (logSegmentNumber uint64, logSegmentOffset uint64, length uint64, ok bool, err error)
+	PutDedupFingerprints(logSegmentNumber uint64, fingerprints []DedupFingerprintStruct) (err error)
+	ReferenceLogSegmentRec(logSegmentNumber uint64) (err error)
+	UnreferenceLogSegmentRec(logSegmentNumber uint64) (err error)
+	FetchLogSegmentRefCount(logSegmentNumber uint64) (refCount uint64, err error)
+	RecordDedupBytes(chunkedBytes uint64, dedupedBytes uint64)
+	FetchDedupStats() (dedupStats DedupStatsStruct)
+	RebuildDedupRefCounts(refCounts map[uint64]uint64) (err error)
 	GetBPlusTreeObject(objectNumber uint64) (value []byte, err error)
 	PutBPlusTreeObject(objectNumber uint64, value []byte) (err error)
 	DeleteBPlusTreeObject(objectNumber uint64) (err error)
 	IndexedBPlusTreeObjectNumber(index uint64) (objectNumber uint64, ok bool, err error)
+	RegisterBPlusTreeCache(treeName string, evictLowLimit uint64, evictHighLimit uint64) (bPlusTreeCache BPlusTreeCache)
 	DoCheckpoint() (err error)
+	CheckpointInProgress() (inProgress bool)
 	FetchLayoutReport(treeType BPlusTreeType, validate bool) (layoutReport sortedmap.LayoutReport, discrepencies uint64, err error)
 	DefragmentMetadata(treeType BPlusTreeType, thisStartPercentage float64, thisStopPercentage float64) (err error)
 	SnapShotCreateByInodeLayer(name string) (id uint64, err error)
 	SnapShotDeleteByInodeLayer(id uint64) (err error)
+	SnapShotRevertByInodeLayer(id uint64) (err error)
+	SnapShotCloneByInodeLayer(id uint64, cloneAccountName string, cloneCheckpointContainerName string, cloneCheckpointContainerStoragePolicy string) (err error)
+	RestoreLogSegmentRecs(snapShotID uint64, logSegmentNumbers []uint64) (err error)
+	ReplicateSnapShots() (err error)
+	RequestReplication()
+	IsReplica() (isReplica bool)
+	PromoteReplica() (err error)
+	RetainedCheckpointList() (list []RetainedCheckpointStruct)
+	RetainedCheckpointInspectByInodeLayer(id uint64) (err error)
+	RetainedCheckpointRevertByInodeLayer(id uint64) (err error)
+	InspectedRetainedCheckpoint() (id uint64)
 	SnapShotCount() (snapShotCount uint64)
 	SnapShotLookupByName(name string) (snapShot SnapShotStruct, ok bool)
 	SnapShotListByID(reversed bool) (list []SnapShotStruct)
@@ -73,6 +136,7 @@ type VolumeHandle interface {
 	SnapShotU64Decode(snapShotU64 uint64) (snapShotIDType SnapShotIDType, snapShotID uint64, nonce uint64)
 	SnapShotIDAndNonceEncode(snapShotID uint64, nonce uint64) (snapShotU64 uint64)
 	SnapShotTypeDotSnapShotAndNonceEncode(nonce uint64) (snapShotU64 uint64)
+	FetchInodeRecDiff(fromSnapShotID uint64, toSnapShotID uint64, lastInodeNumber uint64, maxEntries uint64) (diffList []InodeRecDiffStruct, moreEntries bool, err error)
 }
 
 // FetchVolumeHandle is used to fetch a VolumeHandle to use when operating on a given volume's database
@@ -89,6 +153,53 @@ func FetchVolumeHandle(volumeName string) (volumeHandle VolumeHandle, err error)
 	return
 }
 
+// FetchCloneOrigin reports the volume (identified by its accountName and checkpointContainerName)
+// from whose SnapShot the volume stored in accountName/checkpointContainerName was cloned. If the
+// volume is not a clone, originAccountName will be "".
+func FetchCloneOrigin(accountName string, checkpointContainerName string) (originAccountName string, originCheckpointContainerName string, snapShotID uint64, err error) {
+	originAccountName, originCheckpointContainerName, _, snapShotID, err = fetchCloneOrigin(accountName, checkpointContainerName)
+	return
+}
+
+// FetchClones returns the accountNames of all volumes cloned from SnapShots of the volume stored
+// in accountName/checkpointContainerName. While any such clone exists, the volume must not be
+// reformatted and the SnapShots the clones were created from may not be deleted.
+func FetchClones(accountName string, checkpointContainerName string) (cloneAccountNames []string, err error) {
+	var (
+		clonePin  *clonePinStruct
+		clonePins []*clonePinStruct
+	)
+
+	clonePins, err = fetchClonePins(accountName, checkpointContainerName)
+	if nil != err {
+		return
+	}
+
+	cloneAccountNames = make([]string, 0, len(clonePins))
+
+	for _, clonePin = range clonePins {
+		cloneAccountNames = append(cloneAccountNames, clonePin.cloneAccountName)
+	}
+
+	return
+}
+
+// ReleaseClone removes the record of the clone stored in accountName/checkpointContainerName from
+// the volume it was cloned from. It must be called before the clone is reformatted or destroyed
+// so that the origin volume is once again able to delete the SnapShot the clone was created from.
+func ReleaseClone(accountName string, checkpointContainerName string) (err error) {
+	err = releaseClone(accountName, checkpointContainerName)
+	return
+}
+
+// FetchBPlusTreeCacheLimits fetches the optional FSGlobals.<prefix>EvictLowLimit & EvictHighLimit
+// to be passed to VolumeHandle.RegisterBPlusTreeCache(). If neither is configured, zero limits are
+// returned such that the B+Tree type's caches are sized from the byte budget.
+func FetchBPlusTreeCacheLimits(confMap conf.ConfMap, prefix string) (evictLowLimit uint64, evictHighLimit uint64, err error) {
+	evictLowLimit, evictHighLimit, err = fetchBPlusTreeCacheLimits(confMap, prefix)
+	return
+}
+
 // DisableObjectDeletions prevents objects from being deleted until EnableObjectDeletions() is called
 func DisableObjectDeletions() {
 	globals.backgroundObjectDeleteRWMutex.Lock()
@@ -4,16 +4,21 @@
 package headhunter
 
 import (
+	"bytes"
 	"container/list"
 	"fmt"
 	"math/big"
+	"sync/atomic"
 	"time"
 
+	"github.com/NVIDIA/cstruct"
 	"github.com/NVIDIA/sortedmap"
 
+	"github.com/NVIDIA/proxyfs/blunder"
 	"github.com/NVIDIA/proxyfs/evtlog"
 	"github.com/NVIDIA/proxyfs/logger"
 	"github.com/NVIDIA/proxyfs/swiftclient"
+	"github.com/NVIDIA/proxyfs/utils"
 )
 
 func (volume *volumeStruct) RegisterForEvents(listener VolumeEventListener) {
@@ -60,6 +65,17 @@ func (volume *volumeStruct) FetchAccountAndCheckpointContainerNames() (accountNa
 	return
 }
 
+// FetchObjectAccountName returns the Account in which the object numbered objectNumber resides. For a
+// volume cloned from a SnapShot of another volume, this is that volume's Account for shared objects.
+func (volume *volumeStruct) FetchObjectAccountName(objectNumber uint64) (accountName string) {
+	if objectNumber < volume.cloneOriginNonce {
+		accountName = volume.cloneOriginAccountName
+	} else {
+		accountName = volume.accountName
+	}
+	return
+}
+
 func (volume *volumeStruct) fetchNonceWhileLocked() (nonce uint64) {
 	var (
 		checkpointContainerHeaders map[string][]string
@@ -483,11 +499,6 @@ func (volume *volumeStruct) PutLogSegmentRec(logSegmentNumber uint64, value []by
 }
 
 func (volume *volumeStruct) DeleteLogSegmentRec(logSegmentNumber uint64) (err error) {
-	var (
-		containerNameAsValue sortedmap.Value
-		ok                   bool
-	)
-
 	startTime := time.Now()
 	defer func() {
 		globals.DeleteLogSegmentRecUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
@@ -497,7 +508,17 @@ func (volume *volumeStruct) DeleteLogSegmentRec(logSegmentNumber uint64) (err er
 	}()
 
 	volume.Lock()
-	defer volume.Unlock()
+	err = volume.deleteLogSegmentRecWhileLocked(logSegmentNumber)
+	volume.Unlock()
+
+	return // err as returned by deleteLogSegmentRecWhileLocked() is sufficient
+}
+
+func (volume *volumeStruct) deleteLogSegmentRecWhileLocked(logSegmentNumber uint64) (err error) {
+	var (
+		containerNameAsValue sortedmap.Value
+		ok                   bool
+	)
 
 	volume.checkpointTriggeringEvents++
 
@@ -729,6 +750,11 @@ func (volume *volumeStruct) DoCheckpoint() (err error) {
 	return
 }
 
+func (volume *volumeStruct) CheckpointInProgress() (inProgress bool) {
+	inProgress = (0 != atomic.LoadUint32(&volume.checkpointInProgress))
+	return
+}
+
 func (volume *volumeStruct) fetchLayoutReport(treeType BPlusTreeType, validate bool) (layoutReport sortedmap.LayoutReport, discrepencies uint64, err error) {
 	var (
 		measuredLayoutReport sortedmap.LayoutReport
@@ -926,6 +952,17 @@ func (volume *volumeStruct) FetchLayoutReport(treeType BPlusTreeType, validate b
 				layoutReport[objectNumber] = perTreeObjectBytes
 			}
 		}
+
+		// Finally, add in the Checkpoint Container objects retained checkpoints still depend upon
+
+		for objectNumber, checkpointObjectBytes = range volume.fetchRetainedCheckpointLayoutReportWhileLocked() {
+			objectBytes, ok = layoutReport[objectNumber]
+			if ok {
+				layoutReport[objectNumber] = objectBytes + checkpointObjectBytes
+			} else {
+				layoutReport[objectNumber] = checkpointObjectBytes
+			}
+		}
 	} else {
 		layoutReport, discrepencies, err = volume.fetchLayoutReport(treeType, validate)
 	}
@@ -1166,7 +1203,7 @@ func (volume *volumeStruct) SnapShotCreateByInodeLayer(name string) (id uint64,
 				volumeView.volume.maxInodesPerMetadataNode,
 				sortedmap.CompareUint64,
 				volumeView.inodeRecWrapper,
-				globals.inodeRecCache)
+				volume.inodeRecCache.cache)
 	} else {
 		volumeView.inodeRecWrapper.bPlusTree, err =
 			sortedmap.OldBPlusTree(
@@ -1175,7 +1212,7 @@ func (volume *volumeStruct) SnapShotCreateByInodeLayer(name string) (id uint64,
 				inodeRecBPlusTreeRootObjectLength,
 				sortedmap.CompareUint64,
 				volumeView.inodeRecWrapper,
-				globals.inodeRecCache)
+				volume.inodeRecCache.cache)
 		if nil != err {
 			logger.Fatalf("Logic error - sortedmap.OldBPlusTree(<InodeRecBPlusTree>) failed with error: %v", err)
 		}
@@ -1196,7 +1233,7 @@ func (volume *volumeStruct) SnapShotCreateByInodeLayer(name string) (id uint64,
 				volumeView.volume.maxLogSegmentsPerMetadataNode,
 				sortedmap.CompareUint64,
 				volumeView.logSegmentRecWrapper,
-				globals.logSegmentRecCache)
+				volume.logSegmentRecCache.cache)
 	} else {
 		volumeView.logSegmentRecWrapper.bPlusTree, err =
 			sortedmap.OldBPlusTree(
@@ -1205,7 +1242,7 @@ func (volume *volumeStruct) SnapShotCreateByInodeLayer(name string) (id uint64,
 				logSegmentRecBPlusTreeRootObjectLength,
 				sortedmap.CompareUint64,
 				volumeView.logSegmentRecWrapper,
-				globals.logSegmentRecCache)
+				volume.logSegmentRecCache.cache)
 		if nil != err {
 			logger.Fatalf("Logic error - sortedmap.OldBPlusTree(<LogSegmentRecBPlusTree>) failed with error: %v", err)
 		}
@@ -1226,7 +1263,7 @@ func (volume *volumeStruct) SnapShotCreateByInodeLayer(name string) (id uint64,
 				volumeView.volume.maxDirFileNodesPerMetadataNode,
 				sortedmap.CompareUint64,
 				volumeView.bPlusTreeObjectWrapper,
-				globals.bPlusTreeObjectCache)
+				volume.bPlusTreeObjectCache.cache)
 	} else {
 		volumeView.bPlusTreeObjectWrapper.bPlusTree, err =
 			sortedmap.OldBPlusTree(
@@ -1235,7 +1272,7 @@ func (volume *volumeStruct) SnapShotCreateByInodeLayer(name string) (id uint64,
 				bPlusTreeObjectBPlusTreeRootObjectLength,
 				sortedmap.CompareUint64,
 				volumeView.bPlusTreeObjectWrapper,
-				globals.bPlusTreeObjectCache)
+				volume.bPlusTreeObjectCache.cache)
 		if nil != err {
 			logger.Fatalf("Logic error - sortedmap.OldBPlusTree(<BPlusTreeObjectBPlusTree>) failed with error: %v", err)
 		}
@@ -1251,7 +1288,7 @@ func (volume *volumeStruct) SnapShotCreateByInodeLayer(name string) (id uint64,
 			volumeView.volume.maxCreatedDeletedObjectsPerMetadataNode,
 			sortedmap.CompareUint64,
 			volumeView.volume.liveView.createdObjectsWrapper,
-			globals.createdDeletedObjectsCache)
+			volume.createdDeletedObjectsCache.cache)
 
 	volumeView.deletedObjectsWrapper = &bPlusTreeWrapperStruct{
 		volumeView:       volumeView,
@@ -1263,7 +1300,7 @@ func (volume *volumeStruct) SnapShotCreateByInodeLayer(name string) (id uint64,
 			volumeView.volume.maxCreatedDeletedObjectsPerMetadataNode,
 			sortedmap.CompareUint64,
 			volumeView.volume.liveView.deletedObjectsWrapper,
-			globals.createdDeletedObjectsCache)
+			volume.createdDeletedObjectsCache.cache)
 
 	ok, err = volume.viewTreeByNonce.Put(snapShotNonce, volumeView)
 	if nil != err {
@@ -1314,6 +1351,8 @@ func (volume *volumeStruct) SnapShotCreateByInodeLayer(name string) (id uint64,
 
 func (volume *volumeStruct) SnapShotDeleteByInodeLayer(id uint64) (err error) {
 	var (
+		clonePin                       *clonePinStruct
+		clonePins                      []*clonePinStruct
 		deletedVolumeView              *volumeViewStruct
 		deletedVolumeViewIndex         int
 		found                          bool
@@ -1335,6 +1374,34 @@ func (volume *volumeStruct) SnapShotDeleteByInodeLayer(id uint64) (err error) {
 
 	volume.Lock()
 
+	clonePins, err = fetchClonePins(volume.accountName, volume.checkpointContainerName)
+	if nil != err {
+		volume.Unlock()
+		return
+	}
+	for _, clonePin = range clonePins {
+		if clonePin.snapShotID == id {
+			volume.Unlock()
+			err = fmt.Errorf("SnapShot ID %v of volume %v is the origin of clone %s/%s", id, volume.volumeName, clonePin.cloneAccountName, clonePin.cloneCheckpointContainerName)
+			return
+		}
+	}
+
+	value, ok, err = volume.viewTreeByID.GetByKey(id)
+	if (nil == err) && ok {
+		deletedVolumeView = value.(*volumeViewStruct)
+		if (deletedVolumeView.nonce == volume.replicatedSnapShotNonce) || (deletedVolumeView.nonce == volume.replicatingSnapShotNonce) {
+			volume.Unlock()
+			err = fmt.Errorf("SnapShot ID %v of volume %v is needed for replication to Account %s", id, volume.volumeName, volume.replicaAccountName)
+			return
+		}
+		if 0 != volume.diffingSnapShotIDs[id] {
+			volume.Unlock()
+			err = fmt.Errorf("SnapShot ID %v of volume %v is being diffed", id, volume.volumeName)
+			return
+		}
+	}
+
 	volume.checkpointTriggeringEvents++
 
 	value, ok, err = volume.viewTreeByID.GetByKey(id)
@@ -1574,6 +1641,601 @@ func (volume *volumeStruct) SnapShotDeleteByInodeLayer(id uint64) (err error) {
 	return
 }
 
+// SnapShotRevertByInodeLayer reverts the live view to the state captured by the SnapShot identified
+// by id. As the live view would otherwise no longer descend from them, only the most recent SnapShot
+// may be reverted to (i.e. newer SnapShots must first be deleted). Objects created since the SnapShot
+// are scheduled for deletion and objects deleted since the SnapShot are once again referenced by the
+// live view. A checkpoint is taken both before and after the revert as the Replay Log cannot express
+// it. Package inode must have flushed and discarded all of its cached state prior to calling this.
+func (volume *volumeStruct) SnapShotRevertByInodeLayer(id uint64) (err error) {
+	var (
+		containerNameAsByteSlice []byte
+		index                    int
+		key                      sortedmap.Key
+		ok                       bool
+		revertedVolumeView       *volumeViewStruct
+		value                    sortedmap.Value
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.SnapShotRevertByInodeLayerUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.SnapShotRevertByInodeLayerErrors.Add(1)
+		}
+	}()
+
+	if liveSnapShotID == id {
+		err = fmt.Errorf("SnapShot ID %v does not identify a SnapShot", id)
+		return
+	}
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	revertedVolumeView, err = volume.findVolumeViewBySnapShotIDWhileLocked(id)
+	if nil != err {
+		return
+	}
+	if revertedVolumeView != volume.priorView {
+		err = fmt.Errorf("SnapShot ID %v is not the most recent SnapShot of volume \"%v\"", id, volume.volumeName)
+		return
+	}
+
+	// Ensure all live view B+Tree modifications are persisted and liveView deletedObjects is drained
+
+	volume.checkpointTriggeringEvents++
+
+	evtlog.Record(evtlog.FormatHeadhunterCheckpointStart, volume.volumeName)
+	err = volume.putCheckpoint()
+	if nil != err {
+		evtlog.Record(evtlog.FormatHeadhunterCheckpointEndFailure, volume.volumeName, err.Error())
+		logger.FatalfWithError(err, "Shutting down to prevent subsequent checkpoints from corrupting Swift")
+	}
+	evtlog.Record(evtlog.FormatHeadhunterCheckpointEndSuccess, volume.volumeName)
+
+	volume.checkpointTriggeringEvents++
+
+	volume.revertBPlusTreeWhileLocked(volume.liveView.inodeRecWrapper, revertedVolumeView.inodeRecWrapper, volume.maxInodesPerMetadataNode, volume.inodeRecCache.cache)
+	volume.revertBPlusTreeWhileLocked(volume.liveView.logSegmentRecWrapper, revertedVolumeView.logSegmentRecWrapper, volume.maxLogSegmentsPerMetadataNode, volume.logSegmentRecCache.cache)
+	volume.revertBPlusTreeWhileLocked(volume.liveView.bPlusTreeObjectWrapper, revertedVolumeView.bPlusTreeObjectWrapper, volume.maxDirFileNodesPerMetadataNode, volume.bPlusTreeObjectCache.cache)
+
+	// The dedup B+Tree is not preserved by SnapShots... so its reference counts must be rebuilt
+
+	if volume.dedupInUseWhileLocked() {
+		volume.dedupRebuildRequired = true
+	}
+
+	// LogSegments created since revertedVolumeView are no longer referenced by anything
+	// (Checkpoint Objects are disposed of via bPlusTreeLayout tracking in putCheckpoint())
+
+	index = 0
+
+	for {
+		key, value, ok, err = revertedVolumeView.createdObjectsWrapper.bPlusTree.GetByIndex(index)
+		if nil != err {
+			logger.Fatalf("Logic error - revertedVolumeView.createdObjectsWrapper.bPlusTree.GetByIndex(%v) failed with error: %v", index, err)
+		}
+		if !ok {
+			break
+		}
+
+		containerNameAsByteSlice = value.([]byte)
+
+		if volume.checkpointContainerName == string(containerNameAsByteSlice[:]) {
+			index++
+			continue
+		}
+
+		ok, err = revertedVolumeView.createdObjectsWrapper.bPlusTree.DeleteByIndex(index)
+		if nil != err {
+			logger.Fatalf("Logic error - revertedVolumeView.createdObjectsWrapper.bPlusTree.DeleteByIndex(%v) failed with error: %v", index, err)
+		}
+		if !ok {
+			logger.Fatalf("Logic error - revertedVolumeView.createdObjectsWrapper.bPlusTree.DeleteByIndex(%v) returned ok == false", index)
+		}
+		ok, err = volume.liveView.deletedObjectsWrapper.bPlusTree.Put(key, value)
+		if nil != err {
+			logger.Fatalf("Logic error - liveView.deletedObjectsWrapper.bPlusTree.Put() failed with error: %v", err)
+		}
+		if !ok {
+			logger.Fatalf("Logic error - liveView.deletedObjectsWrapper.bPlusTree.Put() returned ok == false")
+		}
+	}
+
+	// Objects deleted since revertedVolumeView that are once again referenced must no longer be deleted
+
+	index = 0
+
+	for {
+		key, value, ok, err = revertedVolumeView.deletedObjectsWrapper.bPlusTree.GetByIndex(index)
+		if nil != err {
+			logger.Fatalf("Logic error - revertedVolumeView.deletedObjectsWrapper.bPlusTree.GetByIndex(%v) failed with error: %v", index, err)
+		}
+		if !ok {
+			break
+		}
+
+		containerNameAsByteSlice = value.([]byte)
+
+		if (volume.checkpointContainerName == string(containerNameAsByteSlice[:])) && !volume.liveViewReferencesCheckpointObjectWhileLocked(key.(uint64)) {
+			index++
+			continue
+		}
+
+		ok, err = revertedVolumeView.deletedObjectsWrapper.bPlusTree.DeleteByIndex(index)
+		if nil != err {
+			logger.Fatalf("Logic error - revertedVolumeView.deletedObjectsWrapper.bPlusTree.DeleteByIndex(%v) failed with error: %v", index, err)
+		}
+		if !ok {
+			logger.Fatalf("Logic error - revertedVolumeView.deletedObjectsWrapper.bPlusTree.DeleteByIndex(%v) returned ok == false", index)
+		}
+	}
+
+	evtlog.Record(evtlog.FormatHeadhunterCheckpointStart, volume.volumeName)
+	err = volume.putCheckpoint()
+	if nil != err {
+		evtlog.Record(evtlog.FormatHeadhunterCheckpointEndFailure, volume.volumeName, err.Error())
+		logger.FatalfWithError(err, "Shutting down to prevent subsequent checkpoints from corrupting Swift")
+	}
+	evtlog.Record(evtlog.FormatHeadhunterCheckpointEndSuccess, volume.volumeName)
+
+	return
+}
+
+// revertBPlusTreeWhileLocked replaces liveWrapper's B+Tree with one sharing the nodes of snapShotWrapper's
+// B+Tree. Objects no longer referenced are left in bPlusTreeLayout with zero bytes used such that the next
+// putCheckpoint() will dispose of them.
+func (volume *volumeStruct) revertBPlusTreeWhileLocked(liveWrapper *bPlusTreeWrapperStruct, snapShotWrapper *bPlusTreeWrapperStruct, maxKeysPerNode uint64, cache sortedmap.BPlusTreeCache) {
+	var (
+		err               error
+		revertedBPlusTree sortedmap.BPlusTree
+		rootObjectLength  uint64
+		rootObjectNumber  uint64
+		rootObjectOffset  uint64
+	)
+
+	rootObjectNumber, rootObjectOffset, rootObjectLength = snapShotWrapper.bPlusTree.FetchLocation()
+
+	revertedBPlusTree, err = volume.loadBPlusTreeWhileLocked(liveWrapper, rootObjectNumber, rootObjectOffset, rootObjectLength, maxKeysPerNode, cache)
+	if nil != err {
+		logger.Fatalf("Logic error - sortedmap.OldBPlusTree() for volume %v failed with error: %v", volume.volumeName, err)
+	}
+
+	volume.installRevertedBPlusTreeWhileLocked(liveWrapper, revertedBPlusTree)
+}
+
+// loadBPlusTreeWhileLocked returns a B+Tree (using bPlusTreeWrapper's callbacks) rooted at the specified
+// location... or an empty B+Tree if rootObjectNumber is zero.
+func (volume *volumeStruct) loadBPlusTreeWhileLocked(bPlusTreeWrapper *bPlusTreeWrapperStruct, rootObjectNumber uint64, rootObjectOffset uint64, rootObjectLength uint64, maxKeysPerNode uint64, cache sortedmap.BPlusTreeCache) (bPlusTree sortedmap.BPlusTree, err error) {
+	if 0 == rootObjectNumber {
+		bPlusTree = sortedmap.NewBPlusTree(maxKeysPerNode, sortedmap.CompareUint64, bPlusTreeWrapper, cache)
+		err = nil
+	} else {
+		bPlusTree, err = sortedmap.OldBPlusTree(rootObjectNumber, rootObjectOffset, rootObjectLength, sortedmap.CompareUint64, bPlusTreeWrapper, cache)
+	}
+
+	return
+}
+
+// installRevertedBPlusTreeWhileLocked replaces liveWrapper's B+Tree with revertedBPlusTree, updating
+// bPlusTreeLayout as described for revertBPlusTreeWhileLocked().
+func (volume *volumeStruct) installRevertedBPlusTreeWhileLocked(liveWrapper *bPlusTreeWrapperStruct, revertedBPlusTree sortedmap.BPlusTree) {
+	var (
+		err          error
+		layoutReport sortedmap.LayoutReport
+		objectNumber uint64
+		ok           bool
+		oldBPlusTree sortedmap.BPlusTree
+	)
+
+	oldBPlusTree = liveWrapper.bPlusTree
+
+	liveWrapper.bPlusTree = revertedBPlusTree
+
+	layoutReport, err = liveWrapper.bPlusTree.FetchLayoutReport()
+	if nil != err {
+		logger.Fatalf("Logic error - FetchLayoutReport() for volume %v failed with error: %v", volume.volumeName, err)
+	}
+
+	for objectNumber = range liveWrapper.bPlusTreeTracker.bPlusTreeLayout {
+		_, ok = layoutReport[objectNumber]
+		if !ok {
+			layoutReport[objectNumber] = 0
+		}
+	}
+
+	liveWrapper.bPlusTreeTracker.bPlusTreeLayout = layoutReport
+
+	err = oldBPlusTree.Prune()
+	if nil != err {
+		logger.Fatalf("Logic error - oldBPlusTree.Prune() for volume %v failed with error: %v", volume.volumeName, err)
+	}
+}
+
+// liveViewReferencesCheckpointObjectWhileLocked reports whether any of the live view's
+// B+Trees has nodes in the Checkpoint Object identified by objectNumber.
+func (volume *volumeStruct) liveViewReferencesCheckpointObjectWhileLocked(objectNumber uint64) (referenced bool) {
+	var (
+		bPlusTreeWrapper *bPlusTreeWrapperStruct
+	)
+
+	for _, bPlusTreeWrapper = range []*bPlusTreeWrapperStruct{
+		volume.liveView.inodeRecWrapper,
+		volume.liveView.logSegmentRecWrapper,
+		volume.liveView.bPlusTreeObjectWrapper,
+		volume.liveView.dedupWrapper,
+		volume.liveView.createdObjectsWrapper,
+		volume.liveView.deletedObjectsWrapper,
+	} {
+		if 0 < bPlusTreeWrapper.bPlusTreeTracker.bPlusTreeLayout[objectNumber] {
+			referenced = true
+			return
+		}
+	}
+
+	referenced = false
+	return
+}
+
+// RestoreLogSegmentRecs ensures that each of logSegmentNumbers, as recorded in the view of the SnapShot
+// identified by snapShotID, is present in the live view. This enables package inode to once again
+// reference LogSegments deleted from the live view (but preserved by the SnapShot) without copying them.
+// As the Replay Log cannot express this, a checkpoint is taken if any LogSegmentRecs were restored.
+func (volume *volumeStruct) RestoreLogSegmentRecs(snapShotID uint64, logSegmentNumbers []uint64) (err error) {
+	var (
+		logSegmentNumber     uint64
+		ok                   bool
+		restoreList          []uint64
+		restoreValues        map[uint64]sortedmap.Value
+		snapShotVolumeView   *volumeViewStruct
+		value                sortedmap.Value
+		volumeView           *volumeViewStruct
+		volumeViewAsValue    sortedmap.Value
+		volumeViewCount      int
+		volumeViewIndex      int
+		volumeViewHadSegment bool
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.RestoreLogSegmentRecsUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.RestoreLogSegmentRecsErrors.Add(1)
+		}
+	}()
+
+	if liveSnapShotID == snapShotID {
+		err = fmt.Errorf("SnapShot ID %v does not identify a SnapShot", snapShotID)
+		return
+	}
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	snapShotVolumeView, err = volume.findVolumeViewBySnapShotIDWhileLocked(snapShotID)
+	if nil != err {
+		return
+	}
+
+	// First pass ensures all logSegmentNumbers can be restored before modifying anything
+
+	restoreList = make([]uint64, 0, len(logSegmentNumbers))
+	restoreValues = make(map[uint64]sortedmap.Value)
+
+	for _, logSegmentNumber = range logSegmentNumbers {
+		_, ok = restoreValues[logSegmentNumber]
+		if ok {
+			continue
+		}
+
+		_, ok, err = volume.liveView.logSegmentRecWrapper.bPlusTree.GetByKey(logSegmentNumber)
+		if nil != err {
+			return
+		}
+		if ok {
+			continue
+		}
+
+		value, ok, err = snapShotVolumeView.logSegmentRecWrapper.bPlusTree.GetByKey(logSegmentNumber)
+		if nil != err {
+			return
+		}
+		if !ok {
+			err = fmt.Errorf("logSegmentNumber 0x%016X not found in SnapShot ID %v of volume \"%v\"", logSegmentNumber, snapShotID, volume.volumeName)
+			return
+		}
+
+		restoreList = append(restoreList, logSegmentNumber)
+		restoreValues[logSegmentNumber] = value
+	}
+
+	if 0 == len(restoreList) {
+		return
+	}
+
+	volume.checkpointTriggeringEvents++
+
+	volumeViewCount, err = volume.viewTreeByNonce.Len()
+	if nil != err {
+		logger.Fatalf("Logic error - viewTreeByNonce.Len() failed with error: %v", err)
+	}
+
+	for _, logSegmentNumber = range restoreList {
+		// Having been deleted from the live view after snapShotVolumeView was created, the LogSegment
+		// is scheduled for deletion by the deletedObjects of precisely one volumeView no older than
+		// snapShotVolumeView... which must no longer do so
+
+		volumeViewHadSegment = false
+
+		for volumeViewIndex = volumeViewCount - 1; (0 <= volumeViewIndex) && !volumeViewHadSegment; volumeViewIndex-- {
+			_, volumeViewAsValue, ok, err = volume.viewTreeByNonce.GetByIndex(volumeViewIndex)
+			if nil != err {
+				logger.Fatalf("Logic error - viewTreeByNonce.GetByIndex(%v) failed with error: %v", volumeViewIndex, err)
+			}
+			if !ok {
+				logger.Fatalf("Logic error - viewTreeByNonce.GetByIndex(%v) returned ok == false", volumeViewIndex)
+			}
+			volumeView, ok = volumeViewAsValue.(*volumeViewStruct)
+			if !ok {
+				logger.Fatalf("Logic error - viewTreeByNonce.GetByIndex(%v) returned something other than a volumeView", volumeViewIndex)
+			}
+
+			volumeViewHadSegment, err = volumeView.deletedObjectsWrapper.bPlusTree.DeleteByKey(logSegmentNumber)
+			if nil != err {
+				logger.Fatalf("Logic error - volumeView.deletedObjectsWrapper.bPlusTree.DeleteByKey(0x%016X) failed with error: %v", logSegmentNumber, err)
+			}
+
+			if volumeView == snapShotVolumeView {
+				break
+			}
+		}
+
+		if !volumeViewHadSegment {
+			logger.Fatalf("Logic error - logSegmentNumber 0x%016X of SnapShot ID %v not found in any deletedObjects of volume %v", logSegmentNumber, snapShotID, volume.volumeName)
+		}
+
+		ok, err = volume.liveView.logSegmentRecWrapper.bPlusTree.Put(logSegmentNumber, restoreValues[logSegmentNumber])
+		if nil != err {
+			logger.Fatalf("Logic error - liveView.logSegmentRecWrapper.bPlusTree.Put() failed with error: %v", err)
+		}
+		if !ok {
+			logger.Fatalf("Logic error - liveView.logSegmentRecWrapper.bPlusTree.Put() returned ok == false")
+		}
+	}
+
+	evtlog.Record(evtlog.FormatHeadhunterCheckpointStart, volume.volumeName)
+	err = volume.putCheckpoint()
+	if nil != err {
+		evtlog.Record(evtlog.FormatHeadhunterCheckpointEndFailure, volume.volumeName, err.Error())
+		logger.FatalfWithError(err, "Shutting down to prevent subsequent checkpoints from corrupting Swift")
+	}
+	evtlog.Record(evtlog.FormatHeadhunterCheckpointEndSuccess, volume.volumeName)
+
+	return
+}
+
+// SnapShotCloneByInodeLayer creates a new volume, residing in cloneAccountName/cloneCheckpointContainerName,
+// whose initial state is that of the SnapShot identified by id. Rather than being copied, the B+Tree nodes
+// and LogSegments of the SnapShot are shared with the clone. As they remain in this volume's Account, they
+// are never deleted by the clone. To prevent their deletion here, the clone is recorded in this volume's
+// checkpoint container (see FetchClones()) and the SnapShot may not be deleted until the clone is released
+// (see ReleaseClone()).
+func (volume *volumeStruct) SnapShotCloneByInodeLayer(id uint64, cloneAccountName string, cloneCheckpointContainerName string, cloneCheckpointContainerStoragePolicy string) (err error) {
+	var (
+		accountHeaders             map[string][]string
+		checkpointContainerHeaders map[string][]string
+		checkpointTrailerBuf       []byte
+		chunkedPutContext          swiftclient.ChunkedPutContext
+		cloneNonce                 uint64
+		clonedVolumeView           *volumeViewStruct
+		ok                         bool
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.SnapShotCloneByInodeLayerUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.SnapShotCloneByInodeLayerErrors.Add(1)
+		}
+	}()
+
+	if liveSnapShotID == id {
+		err = fmt.Errorf("SnapShot ID %v does not identify a SnapShot", id)
+		return
+	}
+	if cloneAccountName == volume.accountName {
+		err = fmt.Errorf("Clone of volume %v must reside in a different Account", volume.volumeName)
+		return
+	}
+
+	// A clone's objects numbered below its cloneOriginNonce reside in its origin's Account... which a
+	// clone of it would have no way to locate
+
+	if "" != volume.cloneOriginAccountName {
+		err = fmt.Errorf("Volume %v is a clone (of %s/%s) so cannot itself be cloned", volume.volumeName, volume.cloneOriginAccountName, volume.cloneOriginCheckpointContainerName)
+		return
+	}
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	clonedVolumeView, err = volume.findVolumeViewBySnapShotIDWhileLocked(id)
+	if nil != err {
+		return
+	}
+
+	checkpointContainerHeaders, err = swiftclient.ContainerHead(cloneAccountName, cloneCheckpointContainerName)
+	if nil == err {
+		_, ok = checkpointContainerHeaders[CheckpointHeaderName]
+		if ok {
+			err = fmt.Errorf("%s/%s already contains a volume", cloneAccountName, cloneCheckpointContainerName)
+			return
+		}
+	}
+
+	// All objects referenced by clonedVolumeView were numbered before cloneNonce
+
+	cloneNonce = volume.fetchNonceWhileLocked()
+
+	// Compose a checkpoint trailer referencing the B+Tree roots of clonedVolumeView
+
+	checkpointTrailerBuf, err = volume.composeVolumeViewCheckpointTrailerWhileLocked(clonedVolumeView)
+	if nil != err {
+		return
+	}
+
+	// Create clone's checkpoint container and write its initial checkpoint trailer (named cloneNonce)
+
+	checkpointContainerHeaders = make(map[string][]string)
+
+	checkpointContainerHeaders[StoragePolicyHeaderName] = []string{cloneCheckpointContainerStoragePolicy}
+
+	err = swiftclient.ContainerPut(cloneAccountName, cloneCheckpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	chunkedPutContext, err = swiftclient.ObjectFetchChunkedPutContext(cloneAccountName, cloneCheckpointContainerName, utils.Uint64ToHexStr(cloneNonce), "")
+	if nil != err {
+		return
+	}
+
+	err = chunkedPutContext.SendChunk(checkpointTrailerBuf)
+	if nil != err {
+		return
+	}
+
+	err = chunkedPutContext.Close()
+	if nil != err {
+		return
+	}
+
+	// Record the clone here before making it visible so that the SnapShot cannot be deleted out from under it
+
+	checkpointContainerHeaders = make(map[string][]string)
+
+	checkpointContainerHeaders[ClonePinHeaderNamePrefix+utils.Uint64ToHexStr(cloneNonce)] = []string{
+		fmt.Sprintf("%s %s %016X", cloneAccountName, cloneCheckpointContainerName, id),
+	}
+
+	err = swiftclient.ContainerPost(volume.accountName, volume.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	// Finally, POST the clone's checkpointHeader (reserving nonces up to cloneNonce) and mark its Account as bi-modal
+
+	checkpointContainerHeaders = make(map[string][]string)
+
+	checkpointContainerHeaders[CheckpointHeaderName] = []string{
+		fmt.Sprintf("%016X %016X %016X %016X", CheckpointVersion3, cloneNonce, uint64(len(checkpointTrailerBuf)), cloneNonce+1),
+	}
+	checkpointContainerHeaders[CloneOriginHeaderName] = []string{
+		fmt.Sprintf("%s %s %016X %016X", volume.accountName, volume.checkpointContainerName, cloneNonce, id),
+	}
+
+	err = swiftclient.ContainerPost(cloneAccountName, cloneCheckpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	accountHeaders = make(map[string][]string)
+
+	accountHeaders[AccountHeaderName] = []string{AccountHeaderValue}
+
+	err = swiftclient.AccountPost(cloneAccountName, accountHeaders)
+
+	return // err set as appropriate
+}
+
+// composeVolumeViewCheckpointTrailerWhileLocked returns a checkpoint trailer (and trailing
+// B+Tree layouts) that, once written, makes volumeView the live view of a volume.
+func (volume *volumeStruct) composeVolumeViewCheckpointTrailerWhileLocked(volumeView *volumeViewStruct) (checkpointTrailerBuf []byte, err error) {
+	var (
+		checkpointObjectTrailer *CheckpointObjectTrailerV3Struct
+		dedupTrailerBuf         []byte
+		treeLayoutBuf           []byte
+	)
+
+	checkpointObjectTrailer = &CheckpointObjectTrailerV3Struct{
+		CreatedObjectsBPlusTreeLayoutNumElements: 0,
+		DeletedObjectsBPlusTreeLayoutNumElements: 0,
+		SnapShotIDNumBits:                        uint64(volume.snapShotIDNumBits),
+		SnapShotListNumElements:                  0,
+		SnapShotListTotalSize:                    0,
+	}
+
+	checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectLength = volumeView.inodeRecWrapper.bPlusTree.FetchLocation()
+	checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectLength = volumeView.logSegmentRecWrapper.bPlusTree.FetchLocation()
+	checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectNumber,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectOffset,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectLength = volumeView.bPlusTreeObjectWrapper.bPlusTree.FetchLocation()
+
+	treeLayoutBuf = make([]byte, 0)
+
+	checkpointObjectTrailer.InodeRecBPlusTreeLayoutNumElements, treeLayoutBuf, err = appendBPlusTreeLayout(treeLayoutBuf, volumeView.inodeRecWrapper.bPlusTree)
+	if nil != err {
+		return
+	}
+	checkpointObjectTrailer.LogSegmentRecBPlusTreeLayoutNumElements, treeLayoutBuf, err = appendBPlusTreeLayout(treeLayoutBuf, volumeView.logSegmentRecWrapper.bPlusTree)
+	if nil != err {
+		return
+	}
+	checkpointObjectTrailer.BPlusTreeObjectBPlusTreeLayoutNumElements, treeLayoutBuf, err = appendBPlusTreeLayout(treeLayoutBuf, volumeView.bPlusTreeObjectWrapper.bPlusTree)
+	if nil != err {
+		return
+	}
+
+	checkpointTrailerBuf, err = cstruct.Pack(checkpointObjectTrailer, LittleEndian)
+	if nil != err {
+		return
+	}
+
+	checkpointTrailerBuf = append(checkpointTrailerBuf, treeLayoutBuf...)
+
+	dedupTrailerBuf, err = volume.composeCloneDedupTrailerWhileLocked()
+	if nil != err {
+		return
+	}
+
+	checkpointTrailerBuf = append(checkpointTrailerBuf, dedupTrailerBuf...)
+
+	return
+}
+
+// appendBPlusTreeLayout appends the serialized LayoutReport of bPlusTree to treeLayoutBuf.
+func appendBPlusTreeLayout(treeLayoutBuf []byte, bPlusTree sortedmap.BPlusTree) (numElements uint64, newTreeLayoutBuf []byte, err error) {
+	var (
+		elementOfBPlusTreeLayout    ElementOfBPlusTreeLayoutStruct
+		elementOfBPlusTreeLayoutBuf []byte
+		layoutReport                sortedmap.LayoutReport
+	)
+
+	layoutReport, err = bPlusTree.FetchLayoutReport()
+	if nil != err {
+		return
+	}
+
+	newTreeLayoutBuf = treeLayoutBuf
+
+	for elementOfBPlusTreeLayout.ObjectNumber, elementOfBPlusTreeLayout.ObjectBytes = range layoutReport {
+		elementOfBPlusTreeLayoutBuf, err = cstruct.Pack(&elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+		newTreeLayoutBuf = append(newTreeLayoutBuf, elementOfBPlusTreeLayoutBuf...)
+	}
+
+	numElements = uint64(len(layoutReport))
+
+	return
+}
+
 func (volume *volumeStruct) SnapShotCount() (snapShotCount uint64) {
 	var (
 		err error
@@ -1769,3 +2431,215 @@ func (volume *volumeStruct) SnapShotTypeDotSnapShotAndNonceEncode(nonce uint64)
 
 	return
 }
+
+// findVolumeViewBySnapShotIDWhileLocked returns the volumeView for the specified snapShotID
+// (where liveSnapShotID selects the liveView).
+func (volume *volumeStruct) findVolumeViewBySnapShotIDWhileLocked(snapShotID uint64) (volumeView *volumeViewStruct, err error) {
+	var (
+		ok    bool
+		value sortedmap.Value
+	)
+
+	if liveSnapShotID == snapShotID {
+		volumeView = volume.liveView
+		err = nil
+		return
+	}
+
+	value, ok, err = volume.viewTreeByID.GetByKey(snapShotID)
+	if nil != err {
+		return
+	}
+	if !ok {
+		err = blunder.NewError(blunder.NotFoundError, "SnapShot ID %v not found in volume \"%v\"", snapShotID, volume.volumeName)
+		return
+	}
+
+	volumeView, ok = value.(*volumeViewStruct)
+	if !ok {
+		logger.Fatalf("Logic error - volume %v has non-*volumeViewStruct value in viewTreeByID", volume.volumeName)
+	}
+
+	err = nil
+	return
+}
+
+// FetchInodeRecDiff compares the InodeRecs of two views (fromSnapShotID and toSnapShotID, either of
+// which may be zero to select the live view) and returns up to maxEntries InodeRecs that differ
+// beyond lastInodeNumber. To fetch the next batch, pass the last returned InodeNumber as the
+// lastInodeNumber of the subsequent call.
+//
+// Since the InodeRec B+Trees of a SnapShot and its successor share unmodified nodes, only InodeRecs
+// that were created, deleted, or rewritten (including those whose payload B+Tree moved) will differ.
+//
+// As comparing the InodeRecs may fault in many B+Tree nodes, the volume lock is only held while
+// comparing each inodeRecDiffBatchSize InodeRecs. In between, the SnapShots are pinned such that
+// they cannot be deleted.
+func (volume *volumeStruct) FetchInodeRecDiff(fromSnapShotID uint64, toSnapShotID uint64, lastInodeNumber uint64, maxEntries uint64) (diffList []InodeRecDiffStruct, moreEntries bool, err error) {
+	var (
+		done bool
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.FetchInodeRecDiffUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		globals.FetchInodeRecDiffEntries.Add(uint64(len(diffList)))
+		if err != nil {
+			globals.FetchInodeRecDiffErrors.Add(1)
+		}
+	}()
+
+	diffList = make([]InodeRecDiffStruct, 0)
+	moreEntries = false
+
+	if uint64(0xFFFFFFFFFFFFFFFF) == lastInodeNumber {
+		err = nil
+		return
+	}
+
+	volume.Lock()
+
+	_, err = volume.findVolumeViewBySnapShotIDWhileLocked(fromSnapShotID)
+	if nil != err {
+		volume.Unlock()
+		return
+	}
+	_, err = volume.findVolumeViewBySnapShotIDWhileLocked(toSnapShotID)
+	if nil != err {
+		volume.Unlock()
+		return
+	}
+
+	volume.diffingSnapShotIDs[fromSnapShotID]++
+	volume.diffingSnapShotIDs[toSnapShotID]++
+
+	volume.Unlock()
+
+	defer func() {
+		volume.Lock()
+		volume.unpinDiffingSnapShotIDWhileLocked(fromSnapShotID)
+		volume.unpinDiffingSnapShotIDWhileLocked(toSnapShotID)
+		volume.Unlock()
+	}()
+
+	for !done {
+		volume.Lock()
+		diffList, lastInodeNumber, moreEntries, done, err = volume.fetchInodeRecDiffBatchWhileLocked(fromSnapShotID, toSnapShotID, lastInodeNumber, maxEntries, diffList)
+		volume.Unlock()
+		if nil != err {
+			return
+		}
+	}
+
+	err = nil
+	return
+}
+
+// unpinDiffingSnapShotIDWhileLocked reverses the pinning of snapShotID by FetchInodeRecDiff().
+func (volume *volumeStruct) unpinDiffingSnapShotIDWhileLocked(snapShotID uint64) {
+	volume.diffingSnapShotIDs[snapShotID]--
+	if 0 == volume.diffingSnapShotIDs[snapShotID] {
+		delete(volume.diffingSnapShotIDs, snapShotID)
+	}
+}
+
+// fetchInodeRecDiffBatchWhileLocked compares up to inodeRecDiffBatchSize InodeRecs beyond lastInodeNumber
+// on behalf of FetchInodeRecDiff() appending those that differ to diffList. The views are looked up anew
+// as the live view (and its InodeRec B+Tree) may have changed since the prior batch. Upon return,
+// newLastInodeNumber is the last InodeNumber compared and done indicates the comparison is complete
+// (either because maxEntries were found or no InodeRecs remain).
+func (volume *volumeStruct) fetchInodeRecDiffBatchWhileLocked(fromSnapShotID uint64, toSnapShotID uint64, lastInodeNumber uint64, maxEntries uint64, diffList []InodeRecDiffStruct) (newDiffList []InodeRecDiffStruct, newLastInodeNumber uint64, moreEntries bool, done bool, err error) {
+	var (
+		batchIndex     int
+		fromIndex      int
+		fromKey        sortedmap.Key
+		fromOK         bool
+		fromValue      sortedmap.Value
+		fromVolumeView *volumeViewStruct
+		toIndex        int
+		toKey          sortedmap.Key
+		toOK           bool
+		toValue        sortedmap.Value
+		toVolumeView   *volumeViewStruct
+	)
+
+	newDiffList = diffList
+	newLastInodeNumber = lastInodeNumber
+	moreEntries = false
+	done = true
+
+	fromVolumeView, err = volume.findVolumeViewBySnapShotIDWhileLocked(fromSnapShotID)
+	if nil != err {
+		return
+	}
+	toVolumeView, err = volume.findVolumeViewBySnapShotIDWhileLocked(toSnapShotID)
+	if nil != err {
+		return
+	}
+
+	fromIndex, _, err = fromVolumeView.inodeRecWrapper.bPlusTree.BisectRight(lastInodeNumber + 1)
+	if nil != err {
+		return
+	}
+	toIndex, _, err = toVolumeView.inodeRecWrapper.bPlusTree.BisectRight(lastInodeNumber + 1)
+	if nil != err {
+		return
+	}
+
+	fromKey, fromValue, fromOK, err = fromVolumeView.inodeRecWrapper.bPlusTree.GetByIndex(fromIndex)
+	if nil != err {
+		return
+	}
+	toKey, toValue, toOK, err = toVolumeView.inodeRecWrapper.bPlusTree.GetByIndex(toIndex)
+	if nil != err {
+		return
+	}
+
+	for batchIndex = 0; fromOK || toOK; batchIndex++ {
+		if (0 != maxEntries) && (uint64(len(newDiffList)) == maxEntries) {
+			moreEntries = true
+			return
+		}
+		if (inodeRecDiffBatchSize == batchIndex) && (uint64(0xFFFFFFFFFFFFFFFF) != newLastInodeNumber) {
+			done = false
+			return
+		}
+
+		switch {
+		case fromOK && (!toOK || (fromKey.(uint64) < toKey.(uint64))):
+			newLastInodeNumber = fromKey.(uint64)
+			newDiffList = append(newDiffList, InodeRecDiffStruct{InodeNumber: newLastInodeNumber, DiffType: InodeRecDiffDeleted})
+			fromIndex++
+			fromKey, fromValue, fromOK, err = fromVolumeView.inodeRecWrapper.bPlusTree.GetByIndex(fromIndex)
+			if nil != err {
+				return
+			}
+		case toOK && (!fromOK || (toKey.(uint64) < fromKey.(uint64))):
+			newLastInodeNumber = toKey.(uint64)
+			newDiffList = append(newDiffList, InodeRecDiffStruct{InodeNumber: newLastInodeNumber, DiffType: InodeRecDiffCreated})
+			toIndex++
+			toKey, toValue, toOK, err = toVolumeView.inodeRecWrapper.bPlusTree.GetByIndex(toIndex)
+			if nil != err {
+				return
+			}
+		default: // fromKey == toKey
+			newLastInodeNumber = toKey.(uint64)
+			if !bytes.Equal(fromValue.([]byte), toValue.([]byte)) {
+				newDiffList = append(newDiffList, InodeRecDiffStruct{InodeNumber: newLastInodeNumber, DiffType: InodeRecDiffModified})
+			}
+			fromIndex++
+			fromKey, fromValue, fromOK, err = fromVolumeView.inodeRecWrapper.bPlusTree.GetByIndex(fromIndex)
+			if nil != err {
+				return
+			}
+			toIndex++
+			toKey, toValue, toOK, err = toVolumeView.inodeRecWrapper.bPlusTree.GetByIndex(toIndex)
+			if nil != err {
+				return
+			}
+		}
+	}
+
+	err = nil
+	return
+}
@@ -0,0 +1,434 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/NVIDIA/sortedmap"
+
+	"github.com/NVIDIA/proxyfs/bucketstats"
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/logger"
+	"github.com/NVIDIA/proxyfs/platform"
+)
+
+// Each served volume caches the nodes of each type of its B+Trees in a sortedmap.BPlusTreeCache of
+// its own. As a sortedmap.BPlusTreeCache is limited by a count of nodes, the limits of each are
+// periodically recomputed:
+//
+//   For a B+Tree type with configured EvictLowLimit & EvictHighLimit, those limits are divided
+//   among the served volumes in proportion to their BPlusTreeCacheWeight.
+//
+//   For a B+Tree type lacking configured limits, a byte budget is used instead. Unless
+//   FSGlobals.BPlusTreeCacheQuotaFraction is explicitly zero, the budget is that fraction (by
+//   default 0.10) of memory (as is done for the ReadCache). Half of the budget is divided among
+//   the volumes in proportion to their BPlusTreeCacheWeight. The other half follows demand: it is
+//   divided in proportion to BPlusTreeCacheWeight times the recent cache misses of each volume (or
+//   by BPlusTreeCacheWeight alone while no volume is missing). A volume's share is divided evenly
+//   among its (budgeted) B+Tree types and converted to a count of nodes using the average size of
+//   nodes observed. Should the budget be zero, default limits are divided as if configured.
+//
+// Note that the budget is only approximate: node sizes are averages of those serialized (rather
+// than of their in-memory representation) and each volume's B+Tree types are still cached (and
+// evicted) independently...the budget merely sets each of their limits.
+//
+// As sortedmap does not count evictions, these are estimated as the number of nodes faulted in
+// since the prior rebalance beyond the growth in the number of nodes cached. Recent cache misses
+// decay by half at each rebalance so that a volume's demand share follows its current workload.
+
+const (
+	bPlusTreeCacheDefaultEvictLowLimit     = uint64(10000) // Used for a B+Tree type lacking configured limits
+	bPlusTreeCacheDefaultEvictHighLimit    = uint64(10010) //   (weighting it within a volume's share of the budget)
+	bPlusTreeCacheDefaultNodeSize          = uint64(4096)  // Assumed until a node has been read or written
+	bPlusTreeCacheDefaultQuotaFraction     = float64(0.10)
+	bPlusTreeCacheDefaultRebalanceInterval = 10 * time.Second
+	bPlusTreeCacheDefaultWeight            = uint64(100)
+	bPlusTreeCacheDemandFraction           = float64(0.5) // Portion of budget divided by recent cache misses
+	bPlusTreeCacheRecentMissesDecay        = float64(0.5) // Applied to recentMisses at each rebalance
+	bPlusTreeCacheStatsPkgName             = "proxyfs.headhunter.bplustreecache"
+)
+
+// BPlusTreeCache is returned by VolumeHandle.RegisterBPlusTreeCache(). Cache() should be passed to
+// sortedmap.{New|Old}BPlusTree() for each B+Tree of this type. The size of each node read or written
+// should be reported via NodeSizeObserved() so that a share of memory may be expressed in nodes.
+// Registering with an evictHighLimit of zero (i.e. no limits configured) sizes the cache from the
+// byte budget.
+type BPlusTreeCache interface {
+	Cache() (bPlusTreeCache sortedmap.BPlusTreeCache)
+	NodeSizeObserved(nodeSize uint64)
+}
+
+type bPlusTreeCacheStruct struct {
+	volume           *volumeStruct
+	treeName         string
+	statsGroupName   string // == volume.volumeName + "." + treeName
+	evictLowLimit    uint64 //  configured limits for this type of B+Tree (else defaults)
+	evictHighLimit   uint64
+	limitsConfigured bool //  if false, the cache is sized from the byte budget (if non-zero)
+	cache            sortedmap.BPlusTreeCache
+	nodeSizeTotal    uint64 //  accessed atomically
+	nodeSizeCount    uint64 //  accessed atomically
+	priorCacheHits   uint64 //  as of last rebalance
+	priorCacheMisses uint64
+	priorCachedNodes uint64
+	recentMisses     float64 // decayed sum of cache misses as of last rebalance
+
+	statsPriorCacheHits   uint64 // as of last report to package stats
+	statsPriorCacheMisses uint64
+
+	CacheHits      bucketstats.Total
+	CacheMisses    bucketstats.Total
+	CacheEvictions bucketstats.Total // estimated
+}
+
+func (bPlusTreeCache *bPlusTreeCacheStruct) Cache() (cache sortedmap.BPlusTreeCache) {
+	cache = bPlusTreeCache.cache
+	return
+}
+
+func (bPlusTreeCache *bPlusTreeCacheStruct) NodeSizeObserved(nodeSize uint64) {
+	atomic.AddUint64(&bPlusTreeCache.nodeSizeTotal, nodeSize)
+	atomic.AddUint64(&bPlusTreeCache.nodeSizeCount, 1)
+}
+
+func (bPlusTreeCache *bPlusTreeCacheStruct) averageNodeSize() (nodeSize uint64) {
+	var (
+		nodeSizeCount uint64
+	)
+
+	nodeSizeCount = atomic.LoadUint64(&bPlusTreeCache.nodeSizeCount)
+
+	if 0 == nodeSizeCount {
+		nodeSize = bPlusTreeCacheDefaultNodeSize
+	} else {
+		nodeSize = atomic.LoadUint64(&bPlusTreeCache.nodeSizeTotal) / nodeSizeCount
+		if 0 == nodeSize {
+			nodeSize = 1
+		}
+	}
+
+	return
+}
+
+// observeNodeSize attributes a node read or written to the bPlusTreeCacheStruct of its B+Tree type.
+func (bPlusTreeWrapper *bPlusTreeWrapperStruct) observeNodeSize(nodeSize uint64) {
+	var (
+		bPlusTreeCache *bPlusTreeCacheStruct
+		volumeView     *volumeViewStruct
+	)
+
+	volumeView = bPlusTreeWrapper.volumeView
+
+	switch bPlusTreeWrapper {
+	case volumeView.inodeRecWrapper:
+		bPlusTreeCache = volumeView.volume.inodeRecCache
+	case volumeView.logSegmentRecWrapper, volumeView.dedupWrapper:
+		bPlusTreeCache = volumeView.volume.logSegmentRecCache
+	case volumeView.bPlusTreeObjectWrapper:
+		bPlusTreeCache = volumeView.volume.bPlusTreeObjectCache
+	case volumeView.createdObjectsWrapper, volumeView.deletedObjectsWrapper:
+		bPlusTreeCache = volumeView.volume.createdDeletedObjectsCache
+	default:
+		return
+	}
+
+	if nil != bPlusTreeCache {
+		bPlusTreeCache.NodeSizeObserved(nodeSize)
+	}
+}
+
+// fetchBPlusTreeCacheLimits fetches the optional FSGlobals.<prefix>EvictLowLimit & EvictHighLimit.
+// Zero limits are returned if neither is configured.
+func fetchBPlusTreeCacheLimits(confMap conf.ConfMap, prefix string) (evictLowLimit uint64, evictHighLimit uint64, err error) {
+	var (
+		evictHighLimitErr error
+		evictLowLimitErr  error
+	)
+
+	evictLowLimit, evictLowLimitErr = confMap.FetchOptionValueUint64("FSGlobals", prefix+"EvictLowLimit")
+	evictHighLimit, evictHighLimitErr = confMap.FetchOptionValueUint64("FSGlobals", prefix+"EvictHighLimit")
+
+	if (nil != evictLowLimitErr) && (nil != evictHighLimitErr) {
+		evictLowLimit = 0
+		evictHighLimit = 0
+		err = nil
+		return
+	}
+
+	if nil != evictLowLimitErr {
+		err = evictLowLimitErr
+		return
+	}
+	if nil != evictHighLimitErr {
+		err = evictHighLimitErr
+		return
+	}
+
+	if (0 == evictHighLimit) || (evictLowLimit > evictHighLimit) {
+		err = fmt.Errorf("FSGlobals.%sEvictLowLimit (%v) & FSGlobals.%sEvictHighLimit (%v) must satisfy 0 <= EvictLowLimit <= EvictHighLimit > 0", prefix, evictLowLimit, prefix, evictHighLimit)
+		return
+	}
+
+	return
+}
+
+func bPlusTreeCacheUp(confMap conf.ConfMap) (err error) {
+	var (
+		bPlusTreeCacheQuotaFraction float64
+	)
+
+	bPlusTreeCacheQuotaFraction, err = confMap.FetchOptionValueFloat64("FSGlobals", "BPlusTreeCacheQuotaFraction")
+	if nil != err {
+		bPlusTreeCacheQuotaFraction = bPlusTreeCacheDefaultQuotaFraction // Default to 10% of memory if not present
+	}
+	if (0 > bPlusTreeCacheQuotaFraction) || (1 < bPlusTreeCacheQuotaFraction) {
+		err = fmt.Errorf("FSGlobals.BPlusTreeCacheQuotaFraction (%v) must be between 0 and 1", bPlusTreeCacheQuotaFraction)
+		return
+	}
+
+	globals.bPlusTreeCacheQuotaBytes = uint64(float64(platform.MemSize()) * bPlusTreeCacheQuotaFraction / platform.GoHeapAllocationMultiplier)
+
+	globals.bPlusTreeCacheRebalanceInterval, err = confMap.FetchOptionValueDuration("FSGlobals", "BPlusTreeCacheRebalanceInterval")
+	if nil != err {
+		globals.bPlusTreeCacheRebalanceInterval = bPlusTreeCacheDefaultRebalanceInterval // Default to 10s if not present
+	}
+	if time.Duration(0) == globals.bPlusTreeCacheRebalanceInterval {
+		err = fmt.Errorf("FSGlobals.BPlusTreeCacheRebalanceInterval must be non-zero")
+		return
+	}
+
+	if 0 != globals.bPlusTreeCacheQuotaBytes {
+		logger.Infof("Adopting B+Tree Cache Parameters: BPlusTreeCacheQuotaFraction(%v) of memSize(0x%016X) totals 0x%016X",
+			bPlusTreeCacheQuotaFraction, platform.MemSize(), globals.bPlusTreeCacheQuotaBytes)
+	}
+
+	globals.bPlusTreeCacheMap = make(map[string]*bPlusTreeCacheStruct)
+	globals.bPlusTreeCacheRebalancerStopChan = make(chan struct{})
+	globals.bPlusTreeCacheRebalancerDoneChan = make(chan struct{})
+
+	go bPlusTreeCacheRebalancer()
+
+	err = nil
+	return
+}
+
+func bPlusTreeCacheDown() {
+	close(globals.bPlusTreeCacheRebalancerStopChan)
+	<-globals.bPlusTreeCacheRebalancerDoneChan
+
+	globals.bPlusTreeCacheMap = nil
+}
+
+func bPlusTreeCacheRebalancer() {
+	for {
+		select {
+		case <-globals.bPlusTreeCacheRebalancerStopChan:
+			close(globals.bPlusTreeCacheRebalancerDoneChan)
+			return
+		case <-time.After(globals.bPlusTreeCacheRebalanceInterval):
+			globals.bPlusTreeCacheMutex.Lock()
+			bPlusTreeCacheRebalanceWhileLocked()
+			globals.bPlusTreeCacheMutex.Unlock()
+		}
+	}
+}
+
+func (volume *volumeStruct) RegisterBPlusTreeCache(treeName string, evictLowLimit uint64, evictHighLimit uint64) (bPlusTreeCache BPlusTreeCache) {
+	bPlusTreeCache = volume.registerBPlusTreeCache(treeName, evictLowLimit, evictHighLimit)
+	return
+}
+
+func (volume *volumeStruct) registerBPlusTreeCache(treeName string, evictLowLimit uint64, evictHighLimit uint64) (bPlusTreeCache *bPlusTreeCacheStruct) {
+	var (
+		limitsConfigured bool
+		ok               bool
+		statsGroupName   string
+	)
+
+	statsGroupName = volume.volumeName + "." + treeName
+
+	limitsConfigured = (0 != evictHighLimit)
+	if !limitsConfigured {
+		evictLowLimit = bPlusTreeCacheDefaultEvictLowLimit
+		evictHighLimit = bPlusTreeCacheDefaultEvictHighLimit
+	}
+
+	globals.bPlusTreeCacheMutex.Lock()
+
+	bPlusTreeCache, ok = globals.bPlusTreeCacheMap[statsGroupName]
+	if ok {
+		globals.bPlusTreeCacheMutex.Unlock()
+		return
+	}
+
+	bPlusTreeCache = &bPlusTreeCacheStruct{
+		volume:           volume,
+		treeName:         treeName,
+		statsGroupName:   statsGroupName,
+		evictLowLimit:    evictLowLimit,
+		evictHighLimit:   evictHighLimit,
+		limitsConfigured: limitsConfigured,
+		cache:            sortedmap.NewBPlusTreeCache(evictLowLimit, evictHighLimit),
+		nodeSizeTotal:    0,
+		nodeSizeCount:    0,
+		priorCacheHits:   0,
+		priorCacheMisses: 0,
+		priorCachedNodes: 0,
+		recentMisses:     0,
+
+		statsPriorCacheHits:   0,
+		statsPriorCacheMisses: 0,
+	}
+
+	globals.bPlusTreeCacheMap[statsGroupName] = bPlusTreeCache
+
+	bucketstats.Register(bPlusTreeCacheStatsPkgName, statsGroupName, bPlusTreeCache)
+
+	bPlusTreeCacheRebalanceWhileLocked()
+
+	globals.bPlusTreeCacheMutex.Unlock()
+
+	return
+}
+
+func (volume *volumeStruct) unregisterBPlusTreeCaches() {
+	var (
+		bPlusTreeCache *bPlusTreeCacheStruct
+		statsGroupName string
+	)
+
+	globals.bPlusTreeCacheMutex.Lock()
+
+	for statsGroupName, bPlusTreeCache = range globals.bPlusTreeCacheMap {
+		if bPlusTreeCache.volume == volume {
+			bucketstats.UnRegister(bPlusTreeCacheStatsPkgName, statsGroupName)
+			delete(globals.bPlusTreeCacheMap, statsGroupName)
+		}
+	}
+
+	bPlusTreeCacheRebalanceWhileLocked()
+
+	globals.bPlusTreeCacheMutex.Unlock()
+}
+
+func bPlusTreeCacheRebalanceWhileLocked() {
+	var (
+		bPlusTreeCache        *bPlusTreeCacheStruct
+		bPlusTreeCacheStats   *sortedmap.BPlusTreeCacheStats
+		budgeted              map[*bPlusTreeCacheStruct]bool
+		cachedNodes           uint64
+		cacheHitsDelta        uint64
+		cacheMissesDelta      uint64
+		evictHighLimit        uint64
+		evictLowLimit         uint64
+		ok                    bool
+		recentMissesByVolume  map[*volumeStruct]float64
+		treeWeightSumByVolume map[*volumeStruct]float64
+		volume                *volumeStruct
+		volumeDemandSum       float64
+		volumeShare           float64
+		volumeShareByVolume   map[*volumeStruct]float64
+		volumeWeightSum       float64
+		volumeWeightSumByTree map[string]float64
+	)
+
+	// Update stats
+
+	for _, bPlusTreeCache = range globals.bPlusTreeCacheMap {
+		bPlusTreeCacheStats = bPlusTreeCache.cache.Stats()
+
+		cacheHitsDelta = bPlusTreeCacheStats.CacheHits - bPlusTreeCache.priorCacheHits
+		cacheMissesDelta = bPlusTreeCacheStats.CacheMisses - bPlusTreeCache.priorCacheMisses
+		cachedNodes = bPlusTreeCacheStats.CleanLRUItems + bPlusTreeCacheStats.DirtyLRUItems
+
+		bPlusTreeCache.CacheHits.Add(cacheHitsDelta)
+		bPlusTreeCache.CacheMisses.Add(cacheMissesDelta)
+		if (bPlusTreeCache.priorCachedNodes + cacheMissesDelta) > cachedNodes {
+			bPlusTreeCache.CacheEvictions.Add(bPlusTreeCache.priorCachedNodes + cacheMissesDelta - cachedNodes)
+		}
+
+		bPlusTreeCache.priorCacheHits = bPlusTreeCacheStats.CacheHits
+		bPlusTreeCache.priorCacheMisses = bPlusTreeCacheStats.CacheMisses
+		bPlusTreeCache.priorCachedNodes = cachedNodes
+
+		bPlusTreeCache.recentMisses = (bPlusTreeCache.recentMisses * bPlusTreeCacheRecentMissesDecay) + float64(cacheMissesDelta)
+	}
+
+	// Compute sums of weights (note there is at most one bPlusTreeCacheStruct per volume per treeName)...
+	// only B+Tree types lacking configured limits share the budget
+
+	budgeted = make(map[*bPlusTreeCacheStruct]bool)
+	recentMissesByVolume = make(map[*volumeStruct]float64)
+	treeWeightSumByVolume = make(map[*volumeStruct]float64)
+	volumeWeightSumByTree = make(map[string]float64)
+
+	volumeWeightSum = 0
+
+	for _, bPlusTreeCache = range globals.bPlusTreeCacheMap {
+		volume = bPlusTreeCache.volume
+
+		volumeWeightSumByTree[bPlusTreeCache.treeName] += float64(volume.bPlusTreeCacheWeight)
+
+		if bPlusTreeCache.limitsConfigured || (0 == globals.bPlusTreeCacheQuotaBytes) {
+			continue
+		}
+
+		budgeted[bPlusTreeCache] = true
+
+		_, ok = treeWeightSumByVolume[volume]
+		if !ok {
+			volumeWeightSum += float64(volume.bPlusTreeCacheWeight)
+		}
+		treeWeightSumByVolume[volume] += float64(bPlusTreeCache.evictHighLimit) * float64(bPlusTreeCache.averageNodeSize())
+
+		recentMissesByVolume[volume] += bPlusTreeCache.recentMisses
+	}
+
+	// Compute each volume's share of the budget (i.e. its weighted share plus its demand share)
+
+	volumeDemandSum = 0
+
+	for volume = range treeWeightSumByVolume {
+		volumeDemandSum += float64(volume.bPlusTreeCacheWeight) * recentMissesByVolume[volume]
+	}
+
+	volumeShareByVolume = make(map[*volumeStruct]float64)
+
+	for volume = range treeWeightSumByVolume {
+		if 0 == volumeDemandSum {
+			volumeShare = float64(volume.bPlusTreeCacheWeight) / volumeWeightSum
+		} else {
+			volumeShare = (1 - bPlusTreeCacheDemandFraction) * float64(volume.bPlusTreeCacheWeight) / volumeWeightSum
+			volumeShare += bPlusTreeCacheDemandFraction * float64(volume.bPlusTreeCacheWeight) * recentMissesByVolume[volume] / volumeDemandSum
+		}
+
+		volumeShareByVolume[volume] = volumeShare
+	}
+
+	// Apply each sortedmap.BPlusTreeCache's share of the budget
+
+	for _, bPlusTreeCache = range globals.bPlusTreeCacheMap {
+		volume = bPlusTreeCache.volume
+
+		if !budgeted[bPlusTreeCache] {
+			evictHighLimit = uint64(float64(bPlusTreeCache.evictHighLimit) * float64(volume.bPlusTreeCacheWeight) / volumeWeightSumByTree[bPlusTreeCache.treeName])
+		} else {
+			evictHighLimit = uint64(float64(globals.bPlusTreeCacheQuotaBytes) * volumeShareByVolume[volume] * (float64(bPlusTreeCache.evictHighLimit) / treeWeightSumByVolume[volume]))
+		}
+		if 0 == evictHighLimit {
+			evictHighLimit = 1
+		}
+
+		if bPlusTreeCache.evictLowLimit < bPlusTreeCache.evictHighLimit {
+			evictLowLimit = uint64(float64(evictHighLimit) * float64(bPlusTreeCache.evictLowLimit) / float64(bPlusTreeCache.evictHighLimit))
+		} else {
+			evictLowLimit = evictHighLimit
+		}
+
+		bPlusTreeCache.cache.UpdateLimits(evictLowLimit, evictHighLimit)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/proxyfs/conf"
+)
+
+func TestBPlusTreeCacheRebalance(t *testing.T) {
+	var (
+		bPlusTreeCacheA1 *bPlusTreeCacheStruct
+		bPlusTreeCacheA2 *bPlusTreeCacheStruct
+		bPlusTreeCacheA3 *bPlusTreeCacheStruct
+		bPlusTreeCacheA4 *bPlusTreeCacheStruct
+		bPlusTreeCacheB1 *bPlusTreeCacheStruct
+		bPlusTreeCacheB3 *bPlusTreeCacheStruct
+		volumeA          *volumeStruct
+		volumeB          *volumeStruct
+	)
+
+	globals.bPlusTreeCacheMap = make(map[string]*bPlusTreeCacheStruct)
+	globals.bPlusTreeCacheQuotaBytes = 0
+
+	defer func() {
+		globals.bPlusTreeCacheMap = nil
+	}()
+
+	volumeA = &volumeStruct{volumeName: "TestVolumeA", bPlusTreeCacheWeight: 300}
+	volumeB = &volumeStruct{volumeName: "TestVolumeB", bPlusTreeCacheWeight: 100}
+
+	// With only volumeA registered, it should receive the entire configured limits
+
+	bPlusTreeCacheA1 = volumeA.registerBPlusTreeCache("Tree1", 900, 1000)
+
+	if (900 != bPlusTreeCacheA1.cache.Stats().EvictLowLimit) || (1000 != bPlusTreeCacheA1.cache.Stats().EvictHighLimit) {
+		t.Fatalf("Lone bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA1.cache.Stats())
+	}
+
+	if bPlusTreeCacheA1 != volumeA.registerBPlusTreeCache("Tree1", 900, 1000) {
+		t.Fatalf("Re-registering a bPlusTreeCache should have returned the existing one")
+	}
+
+	// With volumeB registered, "Tree1"'s limits should be split 3:1 while "Tree2" remains volumeA's alone
+
+	bPlusTreeCacheA2 = volumeA.registerBPlusTreeCache("Tree2", 50, 100)
+	bPlusTreeCacheB1 = volumeB.registerBPlusTreeCache("Tree1", 900, 1000)
+
+	if (675 != bPlusTreeCacheA1.cache.Stats().EvictLowLimit) || (750 != bPlusTreeCacheA1.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeA's Tree1 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA1.cache.Stats())
+	}
+	if (50 != bPlusTreeCacheA2.cache.Stats().EvictLowLimit) || (100 != bPlusTreeCacheA2.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeA's Tree2 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA2.cache.Stats())
+	}
+	if (225 != bPlusTreeCacheB1.cache.Stats().EvictLowLimit) || (250 != bPlusTreeCacheB1.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeB's Tree1 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheB1.cache.Stats())
+	}
+
+	// Switch to a budget of 4400KiB... B+Tree types with configured limits should be unaffected
+
+	globals.bPlusTreeCacheQuotaBytes = 4400 * 1024
+
+	bPlusTreeCacheA1.NodeSizeObserved(512)
+	bPlusTreeCacheB1.NodeSizeObserved(512)
+
+	globals.bPlusTreeCacheMutex.Lock()
+	bPlusTreeCacheRebalanceWhileLocked()
+	globals.bPlusTreeCacheMutex.Unlock()
+
+	if (675 != bPlusTreeCacheA1.cache.Stats().EvictLowLimit) || (750 != bPlusTreeCacheA1.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeA's Tree1 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA1.cache.Stats())
+	}
+	if (50 != bPlusTreeCacheA2.cache.Stats().EvictLowLimit) || (100 != bPlusTreeCacheA2.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeA's Tree2 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA2.cache.Stats())
+	}
+
+	// B+Tree types lacking configured limits share the budget... volumeA's 3300KiB is split evenly
+	// between its two such B+Tree types while volumeB's 1100KiB goes to its lone one
+
+	bPlusTreeCacheA3 = volumeA.registerBPlusTreeCache("Tree3", 0, 0)
+	bPlusTreeCacheA4 = volumeA.registerBPlusTreeCache("Tree4", 0, 0)
+	bPlusTreeCacheB3 = volumeB.registerBPlusTreeCache("Tree3", 0, 0)
+
+	bPlusTreeCacheA3.NodeSizeObserved(1024)
+	bPlusTreeCacheA4.NodeSizeObserved(1000)
+	bPlusTreeCacheA4.NodeSizeObserved(1048)
+	bPlusTreeCacheB3.NodeSizeObserved(512)
+
+	globals.bPlusTreeCacheMutex.Lock()
+	bPlusTreeCacheRebalanceWhileLocked()
+	globals.bPlusTreeCacheMutex.Unlock()
+
+	if (1648 != bPlusTreeCacheA3.cache.Stats().EvictLowLimit) || (1650 != bPlusTreeCacheA3.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeA's Tree3 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA3.cache.Stats())
+	}
+	if (1648 != bPlusTreeCacheA4.cache.Stats().EvictLowLimit) || (1650 != bPlusTreeCacheA4.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeA's Tree4 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA4.cache.Stats())
+	}
+	if (2197 != bPlusTreeCacheB3.cache.Stats().EvictLowLimit) || (2200 != bPlusTreeCacheB3.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeB's Tree3 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheB3.cache.Stats())
+	}
+	if (675 != bPlusTreeCacheA1.cache.Stats().EvictLowLimit) || (750 != bPlusTreeCacheA1.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeA's Tree1 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA1.cache.Stats())
+	}
+
+	// Recent misses only on volumeB should shift the demand half of the budget to it... volumeA
+	// is left with 3/4 of the other half (1650KiB) and volumeB gets the remaining 2750KiB
+
+	bPlusTreeCacheB3.recentMisses = 1000
+
+	globals.bPlusTreeCacheMutex.Lock()
+	bPlusTreeCacheRebalanceWhileLocked()
+	globals.bPlusTreeCacheMutex.Unlock()
+
+	if (824 != bPlusTreeCacheA3.cache.Stats().EvictLowLimit) || (825 != bPlusTreeCacheA3.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeA's Tree3 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA3.cache.Stats())
+	}
+	if (824 != bPlusTreeCacheA4.cache.Stats().EvictLowLimit) || (825 != bPlusTreeCacheA4.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeA's Tree4 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheA4.cache.Stats())
+	}
+	if (5494 != bPlusTreeCacheB3.cache.Stats().EvictLowLimit) || (5500 != bPlusTreeCacheB3.cache.Stats().EvictHighLimit) {
+		t.Fatalf("volumeB's Tree3 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheB3.cache.Stats())
+	}
+
+	bPlusTreeCacheB3.recentMisses = 0
+
+	// Unregistering volumeA should leave volumeB with the entire budget (and configured limits)
+
+	volumeA.unregisterBPlusTreeCaches()
+
+	if 2 != len(globals.bPlusTreeCacheMap) {
+		t.Fatalf("Unregistering volumeA's bPlusTreeCaches left %d bPlusTreeCaches (expected 2)", len(globals.bPlusTreeCacheMap))
+	}
+	if 8800 != bPlusTreeCacheB3.cache.Stats().EvictHighLimit {
+		t.Fatalf("volumeB's Tree3 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheB3.cache.Stats())
+	}
+	if 1000 != bPlusTreeCacheB1.cache.Stats().EvictHighLimit {
+		t.Fatalf("volumeB's Tree1 bPlusTreeCache got unexpected limits: %#v", bPlusTreeCacheB1.cache.Stats())
+	}
+
+	volumeB.unregisterBPlusTreeCaches()
+
+	globals.bPlusTreeCacheQuotaBytes = 0
+}
+
+func TestFetchBPlusTreeCacheLimits(t *testing.T) {
+	confMap, err := conf.MakeConfMapFromStrings([]string{
+		"FSGlobals.ConfiguredCacheEvictLowLimit=900",
+		"FSGlobals.ConfiguredCacheEvictHighLimit=1000",
+		"FSGlobals.PartialCacheEvictHighLimit=1000",
+		"FSGlobals.InvertedCacheEvictLowLimit=1000",
+		"FSGlobals.InvertedCacheEvictHighLimit=900",
+	})
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings() failed: %v", err)
+	}
+
+	evictLowLimit, evictHighLimit, err := fetchBPlusTreeCacheLimits(confMap, "ConfiguredCache")
+	if (nil != err) || (900 != evictLowLimit) || (1000 != evictHighLimit) {
+		t.Fatalf("fetchBPlusTreeCacheLimits() of configured limits returned %v, %v, %v", evictLowLimit, evictHighLimit, err)
+	}
+	evictLowLimit, evictHighLimit, err = fetchBPlusTreeCacheLimits(confMap, "AbsentCache")
+	if (nil != err) || (0 != evictLowLimit) || (0 != evictHighLimit) {
+		t.Fatalf("fetchBPlusTreeCacheLimits() of absent limits returned %v, %v, %v", evictLowLimit, evictHighLimit, err)
+	}
+	_, _, err = fetchBPlusTreeCacheLimits(confMap, "PartialCache")
+	if nil == err {
+		t.Fatalf("fetchBPlusTreeCacheLimits() lacking EvictLowLimit should have failed")
+	}
+	_, _, err = fetchBPlusTreeCacheLimits(confMap, "InvertedCache")
+	if nil == err {
+		t.Fatalf("fetchBPlusTreeCacheLimits() with EvictLowLimit > EvictHighLimit should have failed")
+	}
+}
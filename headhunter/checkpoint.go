@@ -9,11 +9,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash/crc64"
-	"io"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -24,7 +23,6 @@ import (
 
 	"github.com/NVIDIA/proxyfs/evtlog"
 	"github.com/NVIDIA/proxyfs/logger"
-	"github.com/NVIDIA/proxyfs/platform"
 	"github.com/NVIDIA/proxyfs/stats"
 	"github.com/NVIDIA/proxyfs/swiftclient"
 	"github.com/NVIDIA/proxyfs/utils"
@@ -82,6 +80,48 @@ type CheckpointObjectTrailerV3Struct struct {
 	// SnapShotList                   serialized as [SnapShotListNumElements                  ]ElementOfSnapShotListStruct
 }
 
+// CheckpointObjectTrailerDedupStruct optionally follows the SnapShotList of a CheckpointObjectTrailerV3Struct.
+// It is only present once deduplication has been applied to the volume.
+type CheckpointObjectTrailerDedupStruct struct {
+	DedupBPlusTreeObjectNumber      uint64 // if != 0, objectNumber-named Object in <accountName>.<checkpointContainerName> where root of dedup B+Tree
+	DedupBPlusTreeObjectOffset      uint64 // ...and offset into the Object where root starts
+	DedupBPlusTreeObjectLength      uint64 // ...and length if that root node
+	DedupBPlusTreeLayoutNumElements uint64 // elements immediately follow CheckpointObjectTrailerDedupStruct
+	DedupChunkedBytes               uint64 // total bytes written subject to deduplication
+	DedupDedupedBytes               uint64 // ...of which were found to already be stored
+	DedupFingerprints               uint64 // number of Fingerprint records in dedup B+Tree
+	DedupLogSegments                uint64 // number of LogSegment  records in dedup B+Tree
+	DedupRebuildRequired            uint64 // if != 0, LogSegment reference counts must be rebuilt before LogSegments may be deleted
+	// DedupBPlusTreeLayout serialized as [DedupBPlusTreeLayoutNumElements]ElementOfBPlusTreeLayoutStruct
+}
+
+// CheckpointObjectTrailerRetentionStruct optionally follows the CheckpointObjectTrailerDedupStruct (and the
+// DedupBPlusTreeLayout) of a CheckpointObjectTrailerV3Struct. It is only present if superseded checkpoints
+// are being retained (in which case a zero-filled CheckpointObjectTrailerDedupStruct precedes it should
+// deduplication never have been applied to the volume).
+type CheckpointObjectTrailerRetentionStruct struct {
+	CheckpointTimeStamp               uint64 // time (in nanoseconds since the Unix epoch) this checkpoint was taken
+	RetainedCheckpointListNumElements uint64 // elements immediately follow CheckpointObjectTrailerRetentionStruct
+	// RetainedCheckpointList serialized as [RetainedCheckpointListNumElements]ElementOfRetainedCheckpointListStruct
+}
+
+// ElementOfRetainedCheckpointListStruct describes a superseded checkpoint (oldest first) whose objects
+// are not to be deleted until it is no longer retained.
+type ElementOfRetainedCheckpointListStruct struct {
+	CheckpointObjectTrailerStructObjectNumber uint64 // checkpointHeader of the retained checkpoint
+	CheckpointObjectTrailerStructObjectLength uint64 // ...
+	ReservedToNonce                           uint64 // ...
+	CheckpointTimeStamp                       uint64 // time (in nanoseconds since the Unix epoch) the retained checkpoint was taken
+	DeferredObjectDeleteListNumElements       uint64 // elements immediately follow ElementOfRetainedCheckpointListStruct
+	// DeferredObjectDeleteList serialized as [DeferredObjectDeleteListNumElements]ElementOfDeferredObjectDeleteListStruct
+}
+
+type ElementOfDeferredObjectDeleteListStruct struct {
+	ObjectNumber        uint64
+	ContainerNameLength uint64
+	// ContainerName serialized as [ContainerNameLength]byte
+}
+
 type ElementOfBPlusTreeLayoutStruct struct {
 	ObjectNumber uint64
 	ObjectBytes  uint64
@@ -215,8 +255,8 @@ func (volume *volumeStruct) recordTransaction(transactionType uint64, keys inter
 		logger.Fatalf("headhunter.recordTransaction(transactionType==%v,,) invalid", transactionType)
 	}
 
-	// TODO: Eventually just remove this (once replayLogFile is mandatory)
-	if "" == volume.replayLogFileName {
+	// TODO: Eventually just remove this (once replayLog is mandatory)
+	if nil == volume.replayLog {
 		// Replay Log is disabled... simply return
 		return
 	}
@@ -487,31 +527,15 @@ func (volume *volumeStruct) recordTransaction(transactionType uint64, keys inter
 	_ = copy(replayLogWriteBuffer, packedUint64)
 
 	// Finally, write out replayLogWriteBuffer
+	//
+	// If this is the first call to recordTransaction() since upVolume() called getCheckpoint(),
+	// the transaction will follow the last transaction replayed following the loading of the
+	// checkpoint. If instead a successful putCheckpoint() has removed the Replay Log, a fresh
+	// Replay Log will now be started.
 
-	if nil == volume.replayLogFile {
-		// Replay Log not currently open
-		//
-		// Either upVolume()'s call to getCheckpoint() found that a clean downVolume() was possible
-		// or a successful putCheckpoint() has removed the Replay Log. In either case, a fresh
-		// Replay Log will now be created.
-
-		volume.replayLogFile, err = platform.OpenFileSync(volume.replayLogFileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
-		if nil != err {
-			logger.FatalfWithError(err, "platform.OpenFileSync(%v,os.O_CREATE|os.O_EXCL|os.O_WRONLY,) failed", volume.replayLogFileName)
-		}
-	} else {
-		// Replay Log is currently open
-		//
-		// If this is the first call to recordTransaction() since upVolume() called getCheckpoint(),
-		// volume.replayLogFile will be positioned for writing just after the last transaction replayed
-		// following the loading of the checkpoint. If this is not the first call to recordTransaction()
-		// since the last putCheckpoint(), volume.replayLogFile will be positioned for writing just
-		// after the prior transaction.
-	}
-
-	_, err = volume.replayLogFile.Write(replayLogWriteBuffer)
+	err = volume.replayLog.append(replayLogWriteBuffer)
 	if nil != err {
-		logger.Fatalf("os.Write() unexpectedly returned error: %v", err)
+		logger.Fatalf("Replay Log append for Volume %s unexpectedly returned error: %v", volume.volumeName, err)
 	}
 
 	return
@@ -773,6 +797,131 @@ func (volume *volumeStruct) fetchCheckpointContainerHeaderAsynchronously(checkpo
 	wg.Done()
 }
 
+// fetchCloneOrigin parses the CloneOriginHeaderName Header (if any) of the checkpoint container of
+// the volume residing in accountName. If the volume is not a clone, originAccountName will be "".
+func fetchCloneOrigin(accountName string, checkpointContainerName string) (originAccountName string, originCheckpointContainerName string, cloneNonce uint64, snapShotID uint64, err error) {
+	var (
+		checkpointContainerHeaders map[string][]string
+		cloneOriginHeaderValues    []string
+		cloneOriginHeaderSplit     []string
+		ok                         bool
+	)
+
+	checkpointContainerHeaders, err = swiftclient.ContainerHead(accountName, checkpointContainerName)
+	if nil != err {
+		return
+	}
+
+	cloneOriginHeaderValues, ok = checkpointContainerHeaders[CloneOriginHeaderName]
+	if !ok || (0 == len(cloneOriginHeaderValues)) || ("" == cloneOriginHeaderValues[0]) {
+		originAccountName = ""
+		originCheckpointContainerName = ""
+		cloneNonce = 0
+		snapShotID = 0
+		return
+	}
+
+	cloneOriginHeaderSplit = strings.Split(cloneOriginHeaderValues[0], " ")
+	if 4 != len(cloneOriginHeaderSplit) {
+		err = fmt.Errorf("%s/%s Header %s should have four fields (had %d)", accountName, checkpointContainerName, CloneOriginHeaderName, len(cloneOriginHeaderSplit))
+		return
+	}
+
+	originAccountName = cloneOriginHeaderSplit[0]
+	originCheckpointContainerName = cloneOriginHeaderSplit[1]
+
+	cloneNonce, err = strconv.ParseUint(cloneOriginHeaderSplit[2], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("%s/%s Header %s contained unparseable nonce (%s)", accountName, checkpointContainerName, CloneOriginHeaderName, cloneOriginHeaderSplit[2])
+		return
+	}
+	snapShotID, err = strconv.ParseUint(cloneOriginHeaderSplit[3], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("%s/%s Header %s contained unparseable SnapShotID (%s)", accountName, checkpointContainerName, CloneOriginHeaderName, cloneOriginHeaderSplit[3])
+		return
+	}
+
+	return
+}
+
+type clonePinStruct struct {
+	headerName                   string // ClonePinHeaderNamePrefix followed by the clone's initial checkpoint objectNumber
+	cloneAccountName             string
+	cloneCheckpointContainerName string
+	snapShotID                   uint64 // SnapShot from which the clone was created
+}
+
+// fetchClonePins returns the clones recorded in the checkpoint container of the volume residing in accountName.
+func fetchClonePins(accountName string, checkpointContainerName string) (clonePins []*clonePinStruct, err error) {
+	var (
+		checkpointContainerHeaders map[string][]string
+		clonePin                   *clonePinStruct
+		clonePinHeaderSplit        []string
+		headerName                 string
+		headerValues               []string
+	)
+
+	checkpointContainerHeaders, err = swiftclient.ContainerHead(accountName, checkpointContainerName)
+	if nil != err {
+		return
+	}
+
+	clonePins = make([]*clonePinStruct, 0)
+
+	for headerName, headerValues = range checkpointContainerHeaders {
+		if !strings.HasPrefix(headerName, ClonePinHeaderNamePrefix) || (0 == len(headerValues)) || ("" == headerValues[0]) {
+			continue
+		}
+
+		clonePinHeaderSplit = strings.Split(headerValues[0], " ")
+		if 3 != len(clonePinHeaderSplit) {
+			err = fmt.Errorf("%s/%s Header %s should have three fields (had %d)", accountName, checkpointContainerName, headerName, len(clonePinHeaderSplit))
+			return
+		}
+
+		clonePin = &clonePinStruct{
+			headerName:                   headerName,
+			cloneAccountName:             clonePinHeaderSplit[0],
+			cloneCheckpointContainerName: clonePinHeaderSplit[1],
+		}
+
+		clonePin.snapShotID, err = strconv.ParseUint(clonePinHeaderSplit[2], 16, 64)
+		if nil != err {
+			err = fmt.Errorf("%s/%s Header %s contained unparseable SnapShotID (%s)", accountName, checkpointContainerName, headerName, clonePinHeaderSplit[2])
+			return
+		}
+
+		clonePins = append(clonePins, clonePin)
+	}
+
+	return
+}
+
+// releaseClone removes the record of the clone residing in accountName from its origin volume's checkpoint container.
+func releaseClone(accountName string, checkpointContainerName string) (err error) {
+	var (
+		checkpointContainerHeaders    map[string][]string
+		cloneNonce                    uint64
+		originAccountName             string
+		originCheckpointContainerName string
+	)
+
+	originAccountName, originCheckpointContainerName, cloneNonce, _, err = fetchCloneOrigin(accountName, checkpointContainerName)
+	if (nil != err) || ("" == originAccountName) {
+		return
+	}
+
+	// Note: Swift removes metadata POST'd with an empty value
+
+	checkpointContainerHeaders = make(map[string][]string)
+
+	checkpointContainerHeaders[ClonePinHeaderNamePrefix+utils.Uint64ToHexStr(cloneNonce)] = []string{""}
+
+	err = swiftclient.ContainerPost(originAccountName, originCheckpointContainerName, checkpointContainerHeaders)
+
+	return // err set as appropriate
+}
+
 func (volume *volumeStruct) fetchCheckpointLayoutReport() (layoutReport sortedmap.LayoutReport, err error) {
 	var (
 		checkpointHeader *CheckpointHeaderStruct
@@ -822,7 +971,6 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 		computedCRC64                                      uint64
 		containerNameAsValue                               sortedmap.Value
 		createdObjectsWrapperBPlusTreeTracker              *bPlusTreeTrackerStruct
-		defaultReplayLogReadBuffer                         []byte
 		deletedObjectsWrapperBPlusTreeTracker              *bPlusTreeTrackerStruct
 		elementOfBPlusTreeLayout                           ElementOfBPlusTreeLayoutStruct
 		expectedCheckpointObjectTrailerSize                uint64
@@ -835,11 +983,13 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 		numInodes                                          uint64
 		objectNumber                                       uint64
 		ok                                                 bool
+		replayLog                                          []byte
 		replayLogReadBuffer                                []byte
 		replayLogReadBufferPosition                        uint64
-		replayLogPosition                                  int64
-		replayLogSize                                      int64
+		replayLogPosition                                  uint64
+		replayLogSize                                      uint64
 		replayLogTransactionFixedPart                      replayLogTransactionFixedPartStruct
+		replayLogTransactionSize                           uint64
 		snapShotBPlusTreeObjectBPlusTreeObjectLengthStruct uint64Struct
 		snapShotBPlusTreeObjectBPlusTreeObjectNumberStruct uint64Struct
 		snapShotBPlusTreeObjectBPlusTreeObjectOffsetStruct uint64Struct
@@ -995,8 +1145,29 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 		}
 	}
 
+	volume.cloneOriginAccountName, volume.cloneOriginCheckpointContainerName, volume.cloneOriginNonce, _, err = fetchCloneOrigin(volume.accountName, volume.checkpointContainerName)
+	if nil != err {
+		return
+	}
+
+	volume.replicaOfAccountName, _, _, _, err = fetchReplicaOf(volume.accountName, volume.checkpointContainerName)
+	if nil != err {
+		return
+	}
+	if "" == volume.replicaOfAccountName {
+		atomic.StoreUint32(&volume.isReplica, 0)
+	} else {
+		atomic.StoreUint32(&volume.isReplica, 1)
+	}
+
 	volume.liveView = &volumeViewStruct{volume: volume}
 
+	volume.checkpointTime = time.Time{}
+	volume.retainedCheckpointList = make([]*retainedCheckpointStruct, 0)
+	volume.inspectedRetainedCheckpoint = nil
+	atomic.StoreUint64(&volume.inspectedRetainedCheckpointID, 0)
+	volume.inspectedLiveView = nil
+
 	if CheckpointVersion3 == volume.checkpointHeader.CheckpointVersion {
 		if 0 == volume.checkpointHeader.CheckpointObjectTrailerStructObjectNumber {
 			// Initialize based on zero-filled CheckpointObjectTrailerV3Struct
@@ -1033,7 +1204,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 					volume.maxInodesPerMetadataNode,
 					sortedmap.CompareUint64,
 					volume.liveView.inodeRecWrapper,
-					globals.inodeRecCache)
+					volume.inodeRecCache.cache)
 
 			logSegmentRecWrapperBPlusTreeTracker = &bPlusTreeTrackerStruct{bPlusTreeLayout: make(sortedmap.LayoutReport)}
 
@@ -1047,7 +1218,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 					volume.maxLogSegmentsPerMetadataNode,
 					sortedmap.CompareUint64,
 					volume.liveView.logSegmentRecWrapper,
-					globals.logSegmentRecCache)
+					volume.logSegmentRecCache.cache)
 
 			bPlusTreeObjectWrapperBPlusTreeTracker = &bPlusTreeTrackerStruct{bPlusTreeLayout: make(sortedmap.LayoutReport)}
 
@@ -1061,7 +1232,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 					volume.maxDirFileNodesPerMetadataNode,
 					sortedmap.CompareUint64,
 					volume.liveView.bPlusTreeObjectWrapper,
-					globals.bPlusTreeObjectCache)
+					volume.bPlusTreeObjectCache.cache)
 
 			createdObjectsWrapperBPlusTreeTracker = &bPlusTreeTrackerStruct{bPlusTreeLayout: make(sortedmap.LayoutReport)}
 
@@ -1075,7 +1246,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 					volume.maxCreatedDeletedObjectsPerMetadataNode,
 					sortedmap.CompareUint64,
 					volume.liveView.createdObjectsWrapper,
-					globals.createdDeletedObjectsCache)
+					volume.createdDeletedObjectsCache.cache)
 
 			deletedObjectsWrapperBPlusTreeTracker = &bPlusTreeTrackerStruct{bPlusTreeLayout: make(sortedmap.LayoutReport)}
 
@@ -1089,7 +1260,9 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 					volume.maxCreatedDeletedObjectsPerMetadataNode,
 					sortedmap.CompareUint64,
 					volume.liveView.deletedObjectsWrapper,
-					globals.createdDeletedObjectsCache)
+					volume.createdDeletedObjectsCache.cache)
+
+			volume.newDedupWrapperWhileLocked()
 
 			// Compute SnapShotID shortcuts
 
@@ -1149,7 +1322,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 						volume.maxInodesPerMetadataNode,
 						sortedmap.CompareUint64,
 						volume.liveView.inodeRecWrapper,
-						globals.inodeRecCache)
+						volume.inodeRecCache.cache)
 			} else {
 				volume.liveView.inodeRecWrapper.bPlusTree, err =
 					sortedmap.OldBPlusTree(
@@ -1158,7 +1331,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 						checkpointObjectTrailerV3.InodeRecBPlusTreeObjectLength,
 						sortedmap.CompareUint64,
 						volume.liveView.inodeRecWrapper,
-						globals.inodeRecCache)
+						volume.inodeRecCache.cache)
 				if nil != err {
 					return
 				}
@@ -1177,7 +1350,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 						volume.maxLogSegmentsPerMetadataNode,
 						sortedmap.CompareUint64,
 						volume.liveView.logSegmentRecWrapper,
-						globals.logSegmentRecCache)
+						volume.logSegmentRecCache.cache)
 			} else {
 				volume.liveView.logSegmentRecWrapper.bPlusTree, err =
 					sortedmap.OldBPlusTree(
@@ -1186,7 +1359,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 						checkpointObjectTrailerV3.LogSegmentRecBPlusTreeObjectLength,
 						sortedmap.CompareUint64,
 						volume.liveView.logSegmentRecWrapper,
-						globals.logSegmentRecCache)
+						volume.logSegmentRecCache.cache)
 				if nil != err {
 					return
 				}
@@ -1205,7 +1378,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 						volume.maxDirFileNodesPerMetadataNode,
 						sortedmap.CompareUint64,
 						volume.liveView.bPlusTreeObjectWrapper,
-						globals.bPlusTreeObjectCache)
+						volume.bPlusTreeObjectCache.cache)
 			} else {
 				volume.liveView.bPlusTreeObjectWrapper.bPlusTree, err =
 					sortedmap.OldBPlusTree(
@@ -1214,7 +1387,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 						checkpointObjectTrailerV3.BPlusTreeObjectBPlusTreeObjectLength,
 						sortedmap.CompareUint64,
 						volume.liveView.bPlusTreeObjectWrapper,
-						globals.bPlusTreeObjectCache)
+						volume.bPlusTreeObjectCache.cache)
 				if nil != err {
 					return
 				}
@@ -1234,7 +1407,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 					volume.maxCreatedDeletedObjectsPerMetadataNode,
 					sortedmap.CompareUint64,
 					volume.liveView.createdObjectsWrapper,
-					globals.createdDeletedObjectsCache)
+					volume.createdDeletedObjectsCache.cache)
 
 			deletedObjectsWrapperBPlusTreeTracker = &bPlusTreeTrackerStruct{bPlusTreeLayout: make(sortedmap.LayoutReport)}
 
@@ -1248,9 +1421,11 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 					volume.maxCreatedDeletedObjectsPerMetadataNode,
 					sortedmap.CompareUint64,
 					volume.liveView.deletedObjectsWrapper,
-					globals.createdDeletedObjectsCache)
+					volume.createdDeletedObjectsCache.cache)
 
-			// Validate size of checkpointObjectTrailerBuf
+			volume.newDedupWrapperWhileLocked()
+
+			// Validate size of checkpointObjectTrailerBuf (any CheckpointObjectTrailerDedupStruct follows)
 
 			expectedCheckpointObjectTrailerSize = checkpointObjectTrailerV3.InodeRecBPlusTreeLayoutNumElements
 			expectedCheckpointObjectTrailerSize += checkpointObjectTrailerV3.LogSegmentRecBPlusTreeLayoutNumElements
@@ -1260,7 +1435,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 			expectedCheckpointObjectTrailerSize *= globals.ElementOfBPlusTreeLayoutStructSize
 			expectedCheckpointObjectTrailerSize += checkpointObjectTrailerV3.SnapShotListTotalSize
 
-			if uint64(len(checkpointObjectTrailerBuf)) != expectedCheckpointObjectTrailerSize {
+			if uint64(len(checkpointObjectTrailerBuf)) < expectedCheckpointObjectTrailerSize {
 				err = fmt.Errorf("checkpointObjectTrailer for volume %v does not match required size", volume.volumeName)
 				return
 			}
@@ -1486,7 +1661,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							volume.maxInodesPerMetadataNode,
 							sortedmap.CompareUint64,
 							volumeView.inodeRecWrapper,
-							globals.inodeRecCache)
+							volume.inodeRecCache.cache)
 				} else {
 					volumeView.inodeRecWrapper.bPlusTree, err =
 						sortedmap.OldBPlusTree(
@@ -1495,7 +1670,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							snapShotInodeRecBPlusTreeObjectLengthStruct.U64,
 							sortedmap.CompareUint64,
 							volumeView.inodeRecWrapper,
-							globals.inodeRecCache)
+							volume.inodeRecCache.cache)
 					if nil != err {
 						return
 					}
@@ -1548,7 +1723,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							volume.maxLogSegmentsPerMetadataNode,
 							sortedmap.CompareUint64,
 							volumeView.logSegmentRecWrapper,
-							globals.logSegmentRecCache)
+							volume.logSegmentRecCache.cache)
 				} else {
 					volumeView.logSegmentRecWrapper.bPlusTree, err =
 						sortedmap.OldBPlusTree(
@@ -1557,7 +1732,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							snapShotLogSegmentRecBPlusTreeObjectLengthStruct.U64,
 							sortedmap.CompareUint64,
 							volumeView.logSegmentRecWrapper,
-							globals.logSegmentRecCache)
+							volume.logSegmentRecCache.cache)
 					if nil != err {
 						return
 					}
@@ -1610,7 +1785,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							volume.maxDirFileNodesPerMetadataNode,
 							sortedmap.CompareUint64,
 							volumeView.bPlusTreeObjectWrapper,
-							globals.logSegmentRecCache)
+							volume.logSegmentRecCache.cache)
 				} else {
 					volumeView.bPlusTreeObjectWrapper.bPlusTree, err =
 						sortedmap.OldBPlusTree(
@@ -1619,7 +1794,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							snapShotBPlusTreeObjectBPlusTreeObjectLengthStruct.U64,
 							sortedmap.CompareUint64,
 							volumeView.bPlusTreeObjectWrapper,
-							globals.logSegmentRecCache)
+							volume.logSegmentRecCache.cache)
 					if nil != err {
 						return
 					}
@@ -1672,7 +1847,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							volume.maxCreatedDeletedObjectsPerMetadataNode,
 							sortedmap.CompareUint64,
 							volumeView.createdObjectsWrapper,
-							globals.createdDeletedObjectsCache)
+							volume.createdDeletedObjectsCache.cache)
 				} else {
 					volumeView.createdObjectsWrapper.bPlusTree, err =
 						sortedmap.OldBPlusTree(
@@ -1681,7 +1856,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							snapShotCreatedObjectsBPlusTreeObjectLengthStruct.U64,
 							sortedmap.CompareUint64,
 							volumeView.createdObjectsWrapper,
-							globals.createdDeletedObjectsCache)
+							volume.createdDeletedObjectsCache.cache)
 					if nil != err {
 						return
 					}
@@ -1734,7 +1909,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							volume.maxCreatedDeletedObjectsPerMetadataNode,
 							sortedmap.CompareUint64,
 							volumeView.deletedObjectsWrapper,
-							globals.createdDeletedObjectsCache)
+							volume.createdDeletedObjectsCache.cache)
 				} else {
 					volumeView.deletedObjectsWrapper.bPlusTree, err =
 						sortedmap.OldBPlusTree(
@@ -1743,7 +1918,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 							snapShotDeletedObjectsBPlusTreeObjectLengthStruct.U64,
 							sortedmap.CompareUint64,
 							volumeView.deletedObjectsWrapper,
-							globals.createdDeletedObjectsCache)
+							volume.createdDeletedObjectsCache.cache)
 					if nil != err {
 						return
 					}
@@ -1785,6 +1960,20 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 				}
 			}
 
+			// Load liveView.dedupWrapper B+Tree (if present)
+
+			checkpointObjectTrailerBuf, err = volume.unpackDedupTrailerWhileLocked(checkpointObjectTrailerBuf)
+			if nil != err {
+				return
+			}
+
+			// Load RetainedCheckpointList (if present)
+
+			checkpointObjectTrailerBuf, err = volume.unpackRetentionTrailerWhileLocked(checkpointObjectTrailerBuf)
+			if nil != err {
+				return
+			}
+
 			// Validate checkpointObjectTrailerBuf was entirely consumed
 
 			if 0 != len(checkpointObjectTrailerBuf) {
@@ -1821,73 +2010,58 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 
 	// Check for the need to process a Replay Log
 
-	if "" == volume.replayLogFileName {
+	if nil == volume.replayLog {
 		// Replay Log is disabled... simply return now
 		err = nil
 		return
 	}
 
-	volume.replayLogFile, err = platform.OpenFileSync(volume.replayLogFileName, os.O_RDWR, 0600)
-	if nil != err {
-		if os.IsNotExist(err) {
-			// No Replay Log found... simply return now
-			err = nil
-			return
-		}
-		logger.FatalfWithError(err, "platform.OpenFileSync(%v,os.O_RDWR,) failed", volume.replayLogFileName)
-	}
-
-	// Compute current end of Replay Log and round it down to replayLogWriteBufferAlignment multiple if necessary
+	// Fetch the Replay Log (possibly left behind by a different peer) and round its size
+	// down to replayLogWriteBufferAlignment multiple if necessary
 
-	replayLogSize, err = volume.replayLogFile.Seek(0, 2)
+	replayLog, err = volume.replayLog.fetch()
 	if nil != err {
 		return
 	}
-	replayLogSize = int64(uintptr(replayLogSize) & ^(replayLogWriteBufferAlignment - 1))
 
-	// Seek back to start of Replay Log
-
-	_, err = volume.replayLogFile.Seek(0, 0)
+	replayLogSize = uint64(uintptr(len(replayLog)) & ^(replayLogWriteBufferAlignment - 1))
 	replayLogPosition = 0
 
-	defaultReplayLogReadBuffer = constructReplayLogWriteBuffer(globals.replayLogTransactionFixedPartStructSize)
-
 	for replayLogPosition < replayLogSize {
-		// Read next Transaction Header from Replay Log
+		// Fetch next Transaction Header from Replay Log
 
-		_, err = io.ReadFull(volume.replayLogFile, defaultReplayLogReadBuffer)
-		if nil != err {
+		if (replayLogPosition + globals.replayLogTransactionFixedPartStructSize) > replayLogSize {
+			// Partially written Transaction - so exit as if Replay Log ended here
+
+			logger.Infof("Reply Log for Volume %s hit truncated Transaction Header", volume.volumeName)
+
+			err = volume.replayLog.truncate(replayLogPosition)
 			return
 		}
 
-		_, err = cstruct.Unpack(defaultReplayLogReadBuffer, &replayLogTransactionFixedPart, LittleEndian)
+		_, err = cstruct.Unpack(replayLog[replayLogPosition:replayLogPosition+globals.replayLogTransactionFixedPartStructSize], &replayLogTransactionFixedPart, LittleEndian)
 		if nil != err {
 			// Logic error - we should never fail cstruct.Unpack() call
 
 			logger.Fatalf("Reply Log for Volume %s hit unexpected cstruct.Unpack() failure: %v", volume.volumeName, err)
 		}
 
-		// Ensure entire Transaction is in replayLogReadBuffer and we are positioned correctly
+		// Ensure entire Transaction is present in replayLogReadBuffer
 
 		bytesNeeded = globals.uint64Size + globals.uint64Size + replayLogTransactionFixedPart.BytesFollowing
+		replayLogTransactionSize = uint64((uintptr(bytesNeeded) + replayLogWriteBufferAlignment - 1) & ^(replayLogWriteBufferAlignment - 1))
 
-		if bytesNeeded <= uint64(len(defaultReplayLogReadBuffer)) {
-			// We've already read the entire Transaction
-
-			replayLogReadBuffer = defaultReplayLogReadBuffer
-		} else {
-			// Back up and read entire Transaction into fresh replayLogReadBuffer
-
-			_, err = volume.replayLogFile.Seek(replayLogPosition, 0)
+		if (replayLogPosition + replayLogTransactionSize) > replayLogSize {
+			// Partially written Transaction - so exit as if Replay Log ended here
 
-			replayLogReadBuffer = constructReplayLogWriteBuffer(bytesNeeded)
+			logger.Infof("Reply Log for Volume %s hit truncated Transaction", volume.volumeName)
 
-			_, err = io.ReadFull(volume.replayLogFile, replayLogReadBuffer)
-			if nil != err {
-				return
-			}
+			err = volume.replayLog.truncate(replayLogPosition)
+			return
 		}
 
+		replayLogReadBuffer = replayLog[replayLogPosition : replayLogPosition+replayLogTransactionSize]
+
 		// Validate ECMA CRC-64 of Transaction
 
 		computedCRC64 = crc64.Checksum(replayLogReadBuffer[globals.uint64Size:bytesNeeded], globals.crc64ECMATable)
@@ -1896,11 +2070,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 
 			logger.Infof("Reply Log for Volume %s hit unexpected cstruct.Unpack() failure: %v", volume.volumeName, err)
 
-			_, err = volume.replayLogFile.Seek(replayLogPosition, 0)
-			if nil != err {
-				return
-			}
-			err = volume.replayLogFile.Truncate(replayLogPosition)
+			err = volume.replayLog.truncate(replayLogPosition)
 			return
 		}
 
@@ -1908,6 +2078,12 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 
 		volume.checkpointTriggeringEvents++
 
+		// Updates to the dedup B+Tree are not logged... so its reference counts may now be stale
+
+		if volume.dedupInUseWhileLocked() {
+			volume.dedupRebuildRequired = true
+		}
+
 		replayLogReadBufferPosition = globals.replayLogTransactionFixedPartStructSize
 
 		switch replayLogTransactionFixedPart.TransactionType {
@@ -2078,17 +2254,13 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 
 			logger.Infof("Reply Log for Volume %s hit unexpected replayLogTransactionFixedPart.TransactionType == %v", volume.volumeName, replayLogTransactionFixedPart.TransactionType)
 
-			_, err = volume.replayLogFile.Seek(replayLogPosition, 0)
-			if nil != err {
-				return
-			}
-			err = volume.replayLogFile.Truncate(replayLogPosition)
+			err = volume.replayLog.truncate(replayLogPosition)
 			return
 		}
 
-		// Finally, make replayLogPosition match where we actually are in volume.replayLogFile
+		// Finally, advance replayLogPosition to the next Transaction
 
-		replayLogPosition += int64(len(replayLogReadBuffer))
+		replayLogPosition += replayLogTransactionSize
 	}
 
 	err = nil
@@ -2120,6 +2292,16 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		globals.PutCheckpointBytes.Add(chunkedPutBytes)
 	}()
 
+	if "" != volume.replicaOfAccountName {
+		// Replicas are only modified by the volume they replicate
+		return
+	}
+
+	if nil != volume.inspectedRetainedCheckpoint {
+		// The live view has been set aside (unmodified) while a retained checkpoint is inspected
+		return
+	}
+
 	var (
 		bytesUsedCumulative                                uint64
 		bytesUsedThisBPlusTree                             uint64
@@ -2129,10 +2311,15 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		checkpointObjectTrailer                            *CheckpointObjectTrailerV3Struct
 		checkpointObjectTrailerBeginningOffset             uint64
 		checkpointObjectTrailerEndingOffset                uint64
+		checkpointTime                                     time.Time
 		checkpointTrailerBuf                               []byte
 		combinedBPlusTreeLayout                            sortedmap.LayoutReport
 		containerNameAsByteSlice                           []byte
 		containerNameAsValue                               sortedmap.Value
+		dedupBPlusTreeObjectLength                         uint64
+		dedupBPlusTreeObjectNumber                         uint64
+		dedupBPlusTreeObjectOffset                         uint64
+		dedupTrailerBuf                                    []byte
 		delayedObjectDeleteList                            []delayedObjectDeleteStruct
 		elementOfBPlusTreeLayout                           ElementOfBPlusTreeLayoutStruct
 		elementOfBPlusTreeLayoutBuf                        []byte
@@ -2143,6 +2330,9 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		ok                                                 bool
 		postponedCreatedObjectNumber                       uint64
 		postponedCreatedObjectsFound                       bool
+		priorCheckpointHeader                              CheckpointHeaderStruct
+		priorCheckpointTime                                time.Time
+		retentionTrailerBuf                                []byte
 		snapShotBPlusTreeObjectBPlusTreeObjectLengthBuf    []byte
 		snapShotBPlusTreeObjectBPlusTreeObjectLengthStruct uint64Struct
 		snapShotBPlusTreeObjectBPlusTreeObjectNumberBuf    []byte
@@ -2247,6 +2437,16 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 	globals.PutCheckpointbPlusTreeObjectBytes.Add(volume.liveView.bPlusTreeObjectWrapper.totalPutBytes)
 	chunkedPutBytes += volume.liveView.bPlusTreeObjectWrapper.totalPutBytes
 
+	if volume.dedupInUseWhileLocked() {
+		volume.liveView.dedupWrapper.ClearCounters()
+
+		dedupBPlusTreeObjectNumber, dedupBPlusTreeObjectOffset, dedupBPlusTreeObjectLength, err = volume.liveView.dedupWrapper.bPlusTree.Flush(false)
+		if nil != err {
+			return
+		}
+		chunkedPutBytes += volume.liveView.dedupWrapper.totalPutBytes
+	}
+
 	startTime2 = time.Now()
 	volumeViewCount, err = volume.viewTreeByNonce.Len()
 	if nil != err {
@@ -2342,6 +2542,10 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 	if nil != err {
 		return
 	}
+	err = volume.liveView.dedupWrapper.bPlusTree.Prune()
+	if nil != err {
+		return
+	}
 	globals.PutCheckpointSnapshotFlushUsec.Add(uint64(time.Since(startTime2) / time.Microsecond))
 
 	startTime2 = time.Now()
@@ -2568,6 +2772,27 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		return
 	}
 
+	dedupTrailerBuf, err = volume.composeDedupTrailerWhileLocked(dedupBPlusTreeObjectNumber, dedupBPlusTreeObjectOffset, dedupBPlusTreeObjectLength)
+	if nil != err {
+		return
+	}
+
+	checkpointTime = time.Now()
+
+	retentionTrailerBuf, err = volume.composeRetentionTrailerWhileLocked(checkpointTime)
+	if nil != err {
+		return
+	}
+
+	if (0 < len(retentionTrailerBuf)) && (0 == len(dedupTrailerBuf)) {
+		// A CheckpointObjectTrailerRetentionStruct may only follow a CheckpointObjectTrailerDedupStruct
+
+		dedupTrailerBuf, err = cstruct.Pack(&CheckpointObjectTrailerDedupStruct{}, LittleEndian)
+		if nil != err {
+			return
+		}
+	}
+
 	err = volume.openCheckpointChunkedPutContextIfNecessary()
 	if nil != err {
 		return
@@ -2601,6 +2826,22 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 	globals.PutCheckpointSnapshotListBytes.Add(uint64(len(snapShotListBuf)))
 	chunkedPutBytes += uint64(len(snapShotListBuf))
 
+	if 0 < len(dedupTrailerBuf) {
+		err = volume.sendChunkToCheckpointChunkedPutContext(dedupTrailerBuf)
+		if nil != err {
+			return
+		}
+	}
+	chunkedPutBytes += uint64(len(dedupTrailerBuf))
+
+	if 0 < len(retentionTrailerBuf) {
+		err = volume.sendChunkToCheckpointChunkedPutContext(retentionTrailerBuf)
+		if nil != err {
+			return
+		}
+	}
+	chunkedPutBytes += uint64(len(retentionTrailerBuf))
+
 	checkpointObjectTrailerEndingOffset, err = volume.bytesPutToCheckpointChunkedPutContext()
 	if nil != err {
 		return
@@ -2624,6 +2865,8 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 
 	// Now update checkpointHeader atomically indicating checkpoint is complete
 
+	priorCheckpointHeader = *volume.checkpointHeader
+
 	volume.checkpointHeader.CheckpointVersion = CheckpointVersion3
 
 	volume.checkpointHeader.CheckpointObjectTrailerStructObjectNumber = volume.checkpointChunkedPutContextObjectNumber
@@ -2660,26 +2903,19 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 
 	volume.checkpointTriggeringEvents = 0
 
+	priorCheckpointTime = volume.checkpointTime
+	volume.checkpointTime = checkpointTime
+
 	stats.IncrementOperations(&stats.CompletedCheckpoints)
 
 	startTime2 = time.Now()
-	// Remove replayLogFile if necessary
+	// Remove Replay Log if necessary
 
-	if nil != volume.replayLogFile {
-		err = volume.replayLogFile.Close()
+	if nil != volume.replayLog {
+		err = volume.replayLog.remove()
 		if nil != err {
 			return
 		}
-		volume.replayLogFile = nil
-	}
-
-	if "" != volume.replayLogFileName {
-		err = os.Remove(volume.replayLogFileName)
-		if nil != err {
-			if !os.IsNotExist(err) {
-				return
-			}
-		}
 	}
 
 	// Now continue computing what checkpoint objects may be deleted
@@ -2708,6 +2944,15 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 			combinedBPlusTreeLayout[objectNumber] = bytesUsedThisBPlusTree
 		}
 	}
+	for objectNumber, bytesUsedThisBPlusTree = range volume.liveView.dedupWrapper.bPlusTreeTracker.bPlusTreeLayout {
+		bytesUsedCumulative, ok = combinedBPlusTreeLayout[objectNumber]
+		if ok {
+			combinedBPlusTreeLayout[objectNumber] = bytesUsedCumulative + bytesUsedThisBPlusTree
+		} else {
+			combinedBPlusTreeLayout[objectNumber] = bytesUsedThisBPlusTree
+		}
+	}
+
 	for objectNumber, bytesUsedThisBPlusTree = range volume.liveView.createdObjectsWrapper.bPlusTreeTracker.bPlusTreeLayout {
 		bytesUsedCumulative, ok = combinedBPlusTreeLayout[objectNumber]
 		if ok {
@@ -2737,6 +2982,7 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 			delete(volume.liveView.inodeRecWrapper.bPlusTreeTracker.bPlusTreeLayout, objectNumber)
 			delete(volume.liveView.logSegmentRecWrapper.bPlusTreeTracker.bPlusTreeLayout, objectNumber)
 			delete(volume.liveView.bPlusTreeObjectWrapper.bPlusTreeTracker.bPlusTreeLayout, objectNumber)
+			delete(volume.liveView.dedupWrapper.bPlusTreeTracker.bPlusTreeLayout, objectNumber)
 			delete(volume.liveView.createdObjectsWrapper.bPlusTreeTracker.bPlusTreeLayout, objectNumber)
 			delete(volume.liveView.deletedObjectsWrapper.bPlusTreeTracker.bPlusTreeLayout, objectNumber)
 
@@ -2792,6 +3038,10 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		}
 	}
 
+	// Objects still referenced by retained checkpoints must not yet be deleted
+
+	delayedObjectDeleteList = volume.retainCheckpointWhileLocked(priorCheckpointHeader, priorCheckpointTime, delayedObjectDeleteList)
+
 	if 0 < len(delayedObjectDeleteList) {
 		volume.backgroundObjectDeleteWG.Add(1)
 		go volume.performDelayedObjectDeletes(delayedObjectDeleteList)
@@ -2825,6 +3075,10 @@ RetryCheckForObjectDeleteEnabled:
 	globals.backgroundObjectDeleteRWMutex.RUnlock()
 
 	for _, delayedObjectDelete = range delayedObjectDeleteList {
+		if delayedObjectDelete.objectNumber < volume.cloneOriginNonce {
+			// Object is shared with (and only deleted by) the volume this volume was cloned from
+			continue
+		}
 		delayedObjectDeleteName = utils.Uint64ToHexStr(delayedObjectDelete.objectNumber)
 		if globals.metadataRecycleBin && (delayedObjectDelete.containerName == volume.checkpointContainerName) {
 			err = swiftclient.ObjectPost(
@@ -2975,6 +3229,8 @@ func (volume *volumeStruct) checkpointDaemon() {
 		// measure the time required to get the volume lock for the checkpoint
 		startTime2 := startTime
 
+		atomic.StoreUint32(&volume.checkpointInProgress, 1)
+
 		volume.Lock()
 		globals.DaemonPerCheckpointLockWaitUsec.Add(uint64(time.Since(startTime2) / time.Microsecond))
 
@@ -3035,6 +3291,8 @@ func (volume *volumeStruct) checkpointDaemon() {
 		volume.Unlock()
 		globals.DaemonPerCheckpointLockedUsec.Add(uint64(time.Since(startTime2) / time.Microsecond))
 
+		atomic.StoreUint32(&volume.checkpointInProgress, 0)
+
 		// Measure time spent updating statistics (including lock wait
 		// time), time spent waking waiters and calling back listeners
 		// (note that one listener also updates statistics).
@@ -3052,63 +3310,61 @@ func (volume *volumeStruct) checkpointDaemon() {
 			checkpointListener.CheckpointCompleted()
 		}
 
-		// Update Global B+Tree Cache stats now
+		// Update Global B+Tree Cache stats now with this volume's contribution
 
-		inodeRecCacheStats = globals.inodeRecCache.Stats()
-		logSegmentRecCacheStats = globals.logSegmentRecCache.Stats()
-		bPlusTreeObjectCacheStats = globals.bPlusTreeObjectCache.Stats()
-		createdDeletedObjectsCacheStats = globals.createdDeletedObjectsCache.Stats()
+		inodeRecCacheStats = volume.inodeRecCache.cache.Stats()
+		logSegmentRecCacheStats = volume.logSegmentRecCache.cache.Stats()
+		bPlusTreeObjectCacheStats = volume.bPlusTreeObjectCache.cache.Stats()
+		createdDeletedObjectsCacheStats = volume.createdDeletedObjectsCache.cache.Stats()
 
-		globals.Lock()
-		inodeRecCacheHitsDelta = inodeRecCacheStats.CacheHits - globals.inodeRecCachePriorCacheHits
-		inodeRecCacheMissesDelta = inodeRecCacheStats.CacheMisses - globals.inodeRecCachePriorCacheMisses
+		inodeRecCacheHitsDelta = inodeRecCacheStats.CacheHits - volume.inodeRecCache.statsPriorCacheHits
+		inodeRecCacheMissesDelta = inodeRecCacheStats.CacheMisses - volume.inodeRecCache.statsPriorCacheMisses
 
-		logSegmentRecCacheHitsDelta = logSegmentRecCacheStats.CacheHits - globals.logSegmentRecCachePriorCacheHits
-		logSegmentRecCacheMissesDelta = logSegmentRecCacheStats.CacheMisses - globals.logSegmentRecCachePriorCacheMisses
+		logSegmentRecCacheHitsDelta = logSegmentRecCacheStats.CacheHits - volume.logSegmentRecCache.statsPriorCacheHits
+		logSegmentRecCacheMissesDelta = logSegmentRecCacheStats.CacheMisses - volume.logSegmentRecCache.statsPriorCacheMisses
 
-		bPlusTreeObjectCacheHitsDelta = bPlusTreeObjectCacheStats.CacheHits - globals.bPlusTreeObjectCachePriorCacheHits
-		bPlusTreeObjectCacheMissesDelta = bPlusTreeObjectCacheStats.CacheMisses - globals.bPlusTreeObjectCachePriorCacheMisses
+		bPlusTreeObjectCacheHitsDelta = bPlusTreeObjectCacheStats.CacheHits - volume.bPlusTreeObjectCache.statsPriorCacheHits
+		bPlusTreeObjectCacheMissesDelta = bPlusTreeObjectCacheStats.CacheMisses - volume.bPlusTreeObjectCache.statsPriorCacheMisses
 
-		createdDeletedObjectsCacheHitsDelta = createdDeletedObjectsCacheStats.CacheHits - globals.createdDeletedObjectsCachePriorCacheHits
-		createdDeletedObjectsCacheMissesDelta = createdDeletedObjectsCacheStats.CacheMisses - globals.createdDeletedObjectsCachePriorCacheMisses
+		createdDeletedObjectsCacheHitsDelta = createdDeletedObjectsCacheStats.CacheHits - volume.createdDeletedObjectsCache.statsPriorCacheHits
+		createdDeletedObjectsCacheMissesDelta = createdDeletedObjectsCacheStats.CacheMisses - volume.createdDeletedObjectsCache.statsPriorCacheMisses
 
 		if 0 != inodeRecCacheHitsDelta {
 			stats.IncrementOperationsBy(&stats.InodeRecCacheHits, inodeRecCacheHitsDelta)
-			globals.inodeRecCachePriorCacheHits = inodeRecCacheStats.CacheHits
+			volume.inodeRecCache.statsPriorCacheHits = inodeRecCacheStats.CacheHits
 		}
 		if 0 != inodeRecCacheMissesDelta {
 			stats.IncrementOperationsBy(&stats.InodeRecCacheMisses, inodeRecCacheMissesDelta)
-			globals.inodeRecCachePriorCacheMisses = inodeRecCacheStats.CacheMisses
+			volume.inodeRecCache.statsPriorCacheMisses = inodeRecCacheStats.CacheMisses
 		}
 
 		if 0 != logSegmentRecCacheHitsDelta {
 			stats.IncrementOperationsBy(&stats.LogSegmentRecCacheHits, logSegmentRecCacheHitsDelta)
-			globals.logSegmentRecCachePriorCacheHits = logSegmentRecCacheStats.CacheHits
+			volume.logSegmentRecCache.statsPriorCacheHits = logSegmentRecCacheStats.CacheHits
 		}
 		if 0 != logSegmentRecCacheMissesDelta {
 			stats.IncrementOperationsBy(&stats.LogSegmentRecCacheMisses, logSegmentRecCacheMissesDelta)
-			globals.logSegmentRecCachePriorCacheMisses = logSegmentRecCacheStats.CacheMisses
+			volume.logSegmentRecCache.statsPriorCacheMisses = logSegmentRecCacheStats.CacheMisses
 		}
 
 		if 0 != bPlusTreeObjectCacheHitsDelta {
 			stats.IncrementOperationsBy(&stats.BPlusTreeObjectCacheHits, bPlusTreeObjectCacheHitsDelta)
-			globals.bPlusTreeObjectCachePriorCacheHits = bPlusTreeObjectCacheStats.CacheHits
+			volume.bPlusTreeObjectCache.statsPriorCacheHits = bPlusTreeObjectCacheStats.CacheHits
 		}
 		if 0 != bPlusTreeObjectCacheMissesDelta {
 			stats.IncrementOperationsBy(&stats.BPlusTreeObjectCacheMisses, bPlusTreeObjectCacheMissesDelta)
-			globals.bPlusTreeObjectCachePriorCacheMisses = bPlusTreeObjectCacheStats.CacheMisses
+			volume.bPlusTreeObjectCache.statsPriorCacheMisses = bPlusTreeObjectCacheStats.CacheMisses
 		}
 
 		if 0 != createdDeletedObjectsCacheHitsDelta {
 			stats.IncrementOperationsBy(&stats.CreatedDeletedObjectsCacheHits, createdDeletedObjectsCacheHitsDelta)
-			globals.createdDeletedObjectsCachePriorCacheHits = createdDeletedObjectsCacheStats.CacheHits
+			volume.createdDeletedObjectsCache.statsPriorCacheHits = createdDeletedObjectsCacheStats.CacheHits
 		}
 		if 0 != createdDeletedObjectsCacheMissesDelta {
 			stats.IncrementOperationsBy(&stats.CreatedDeletedObjectsCacheMisses, createdDeletedObjectsCacheMissesDelta)
-			globals.createdDeletedObjectsCachePriorCacheMisses = createdDeletedObjectsCacheStats.CacheMisses
+			volume.createdDeletedObjectsCache.statsPriorCacheMisses = createdDeletedObjectsCacheStats.CacheMisses
 		}
 
-		globals.Unlock()
 		globals.DaemonPerCheckpointStatsUpdateUsec.Add(uint64(time.Since(startTime2) / time.Microsecond))
 		globals.DaemonPerCheckpointUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
 
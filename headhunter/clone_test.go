@@ -0,0 +1,273 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/ramswift"
+	"github.com/NVIDIA/proxyfs/swiftclient"
+	"github.com/NVIDIA/proxyfs/transitions"
+)
+
+func TestHeadHunterClone(t *testing.T) {
+	var (
+		cloneAccountNames      []string
+		cloneOfCloneSnapShotID uint64
+		cloneSnapShotID        uint64
+		cloneVolume            VolumeHandle
+		confMap                conf.ConfMap
+		confStrings            []string
+		doneChan               chan bool
+		err                    error
+		key                    uint64
+		ok                     bool
+		originAccountName      string
+		signalHandlerIsArmedWG sync.WaitGroup
+		value                  []byte
+		volume                 VolumeHandle
+	)
+
+	confStrings = []string{
+		"Logging.LogFilePath=/dev/null",
+		"Stats.IPAddr=localhost",
+		"Stats.UDPPort=52184",
+		"Stats.BufferLength=100",
+		"Stats.MaxLatency=1s",
+		"SwiftClient.NoAuthIPAddr=127.0.0.1",
+		"SwiftClient.NoAuthTCPPort=9999",
+		"SwiftClient.Timeout=10s",
+		"SwiftClient.RetryLimit=0",
+		"SwiftClient.RetryLimitObject=0",
+		"SwiftClient.RetryDelay=1s",
+		"SwiftClient.RetryDelayObject=1s",
+		"SwiftClient.RetryExpBackoff=1.2",
+		"SwiftClient.RetryExpBackoffObject=2.0",
+		"SwiftClient.ChunkedConnectionPoolSize=64",
+		"SwiftClient.NonChunkedConnectionPoolSize=32",
+		"Cluster.WhoAmI=Peer0",
+		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
+		"Volume:TestVolume.PrimaryPeer=Peer0",
+		"Volume:TestVolume.AccountName=TestAccount",
+		"Volume:TestVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:TestVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:TestVolume.MaxFlushSize=10000000",
+		"Volume:TestVolume.NonceValuesToReserve=100",
+		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
+		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"Volume:CloneVolume.PrimaryPeer=Peer0",
+		"Volume:CloneVolume.AccountName=CloneAccount",
+		"Volume:CloneVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:CloneVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:CloneVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:CloneVolume.MaxFlushSize=10000000",
+		"Volume:CloneVolume.NonceValuesToReserve=100",
+		"Volume:CloneVolume.MaxInodesPerMetadataNode=32",
+		"Volume:CloneVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:CloneVolume.MaxDirFileNodesPerMetadataNode=16",
+		"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume",
+		"VolumeGroup:TestVolumeGroup.VirtualIPAddr=",
+		"VolumeGroup:TestVolumeGroup.PrimaryPeer=Peer0",
+		"FSGlobals.VolumeGroupList=TestVolumeGroup",
+		"FSGlobals.CheckpointHeaderConsensusAttempts=5",
+		"FSGlobals.MountRetryLimit=6",
+		"FSGlobals.MountRetryDelay=1s",
+		"FSGlobals.MountRetryExpBackoff=2",
+		"FSGlobals.LogCheckpointHeaderPosts=true",
+		"FSGlobals.TryLockBackoffMin=10ms",
+		"FSGlobals.TryLockBackoffMax=50ms",
+		"FSGlobals.TryLockSerializationThreshhold=5",
+		"FSGlobals.SymlinkMax=32",
+		"FSGlobals.CoalesceElementChunkSize=16",
+		"FSGlobals.InodeRecCacheEvictLowLimit=10000",
+		"FSGlobals.InodeRecCacheEvictHighLimit=10010",
+		"FSGlobals.LogSegmentRecCacheEvictLowLimit=10000",
+		"FSGlobals.LogSegmentRecCacheEvictHighLimit=10010",
+		"FSGlobals.BPlusTreeObjectCacheEvictLowLimit=10000",
+		"FSGlobals.BPlusTreeObjectCacheEvictHighLimit=10010",
+		"FSGlobals.EtcdEnabled=false",
+		"RamSwiftInfo.MaxAccountNameLength=256",
+		"RamSwiftInfo.MaxContainerNameLength=256",
+		"RamSwiftInfo.MaxObjectNameLength=1024",
+		"RamSwiftInfo.AccountListingLimit=10000",
+		"RamSwiftInfo.ContainerListingLimit=10000",
+	}
+
+	// Launch a ramswift instance
+
+	signalHandlerIsArmedWG.Add(1)
+	doneChan = make(chan bool, 1) // Must be buffered to avoid race
+
+	go ramswift.Daemon("/dev/null", confStrings, &signalHandlerIsArmedWG, doneChan, unix.SIGTERM)
+
+	signalHandlerIsArmedWG.Wait()
+
+	confMap, err = conf.MakeConfMapFromStrings(confStrings)
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings(confStrings) returned error: %v", err)
+	}
+
+	// Schedule a Format of TestVolume on first Up()
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=true")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=true\") returned error: %v", err)
+	}
+
+	// Up packages (TestVolume will be formatted)
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 1] returned error: %v", err)
+	}
+
+	// Unset AutoFormat for all subsequent uses of ConfMap
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=false")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=false\") returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 1] returned error: %v", err)
+	}
+
+	// Clone a SnapShot of TestVolume as CloneVolume
+
+	key = 5678
+
+	inodeRecPutGet(t, volume, key, []byte("SnapShot"))
+
+	cloneSnapShotID, err = volume.SnapShotCreateByInodeLayer("CloneMe")
+	if nil != err {
+		t.Fatalf("SnapShotCreateByInodeLayer() returned error: %v", err)
+	}
+
+	inodeRecPutGet(t, volume, key, []byte("TestVolume"))
+
+	err = swiftclient.AccountPut("CloneAccount", make(map[string][]string))
+	if nil != err {
+		t.Fatalf("swiftclient.AccountPut(\"CloneAccount\") returned error: %v", err)
+	}
+
+	err = volume.SnapShotCloneByInodeLayer(cloneSnapShotID, "CloneAccount", ".__checkpoint__", "gold")
+	if nil != err {
+		t.Fatalf("SnapShotCloneByInodeLayer() returned error: %v", err)
+	}
+
+	err = volume.SnapShotCloneByInodeLayer(cloneSnapShotID, "CloneAccount", ".__checkpoint__", "gold")
+	if nil == err {
+		t.Fatalf("SnapShotCloneByInodeLayer() onto existing volume should have failed")
+	}
+
+	cloneAccountNames, err = FetchClones("TestAccount", ".__checkpoint__")
+	if nil != err {
+		t.Fatalf("FetchClones() returned error: %v", err)
+	}
+	if (1 != len(cloneAccountNames)) || ("CloneAccount" != cloneAccountNames[0]) {
+		t.Fatalf("FetchClones() returned unexpected cloneAccountNames: %v", cloneAccountNames)
+	}
+
+	err = volume.SnapShotDeleteByInodeLayer(cloneSnapShotID)
+	if nil == err {
+		t.Fatalf("SnapShotDeleteByInodeLayer() of cloned SnapShot should have failed")
+	}
+
+	// Serve CloneVolume alongside TestVolume and verify each sees its own version
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 1] returned error: %v", err)
+	}
+
+	err = confMap.UpdateFromString("VolumeGroup:TestVolumeGroup.VolumeList=TestVolume,CloneVolume")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume,CloneVolume\") returned error: %v", err)
+	}
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 2] returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 2] returned error: %v", err)
+	}
+	cloneVolume, err = FetchVolumeHandle("CloneVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"CloneVolume\") returned error: %v", err)
+	}
+
+	value, ok, err = cloneVolume.GetInodeRec(key)
+	if (nil != err) || !ok || ("SnapShot" != string(value)) {
+		t.Fatalf("cloneVolume.GetInodeRec() returned unexpected value (%s), ok (%v), or err (%v)", value, ok, err)
+	}
+
+	inodeRecPutGet(t, cloneVolume, key, []byte("CloneVolume"))
+
+	err = cloneVolume.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("cloneVolume.DoCheckpoint() returned error: %v", err)
+	}
+
+	value, ok, err = volume.GetInodeRec(key)
+	if (nil != err) || !ok || ("TestVolume" != string(value)) {
+		t.Fatalf("volume.GetInodeRec() returned unexpected value (%s), ok (%v), or err (%v)", value, ok, err)
+	}
+
+	originAccountName, _, _, err = FetchCloneOrigin("CloneAccount", ".__checkpoint__")
+	if (nil != err) || ("TestAccount" != originAccountName) {
+		t.Fatalf("FetchCloneOrigin() returned unexpected originAccountName (%s) or err (%v)", originAccountName, err)
+	}
+
+	// A clone may not itself be cloned
+
+	cloneOfCloneSnapShotID, err = cloneVolume.SnapShotCreateByInodeLayer("CloneMeToo")
+	if nil != err {
+		t.Fatalf("cloneVolume.SnapShotCreateByInodeLayer() returned error: %v", err)
+	}
+
+	err = cloneVolume.SnapShotCloneByInodeLayer(cloneOfCloneSnapShotID, "CloneOfCloneAccount", ".__checkpoint__", "gold")
+	if nil == err {
+		t.Fatalf("cloneVolume.SnapShotCloneByInodeLayer() should have failed")
+	}
+
+	err = cloneVolume.SnapShotDeleteByInodeLayer(cloneOfCloneSnapShotID)
+	if nil != err {
+		t.Fatalf("cloneVolume.SnapShotDeleteByInodeLayer() returned error: %v", err)
+	}
+
+	// Release CloneVolume so that the SnapShot may be deleted
+
+	err = ReleaseClone("CloneAccount", ".__checkpoint__")
+	if nil != err {
+		t.Fatalf("ReleaseClone() returned error: %v", err)
+	}
+
+	err = volume.SnapShotDeleteByInodeLayer(cloneSnapShotID)
+	if nil != err {
+		t.Fatalf("SnapShotDeleteByInodeLayer() of released SnapShot returned error: %v", err)
+	}
+
+	// Shutdown packages
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 2] returned error: %v", err)
+	}
+
+	// Send ourself a SIGTERM to terminate ramswift.Daemon()
+
+	unix.Kill(unix.Getpid(), unix.SIGTERM)
+
+	_ = <-doneChan
+}
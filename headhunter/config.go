@@ -5,9 +5,10 @@ package headhunter
 
 import (
 	"container/list"
+	"crypto/tls"
 	"fmt"
 	"hash/crc64"
-	"os"
+	"io/ioutil"
 	"sync"
 	"time"
 
@@ -34,6 +35,10 @@ const (
 	liveSnapShotID = uint64(0)
 )
 
+const (
+	inodeRecDiffBatchSize = 256 // InodeRecs compared by FetchInodeRecDiff() per acquisition of the volume lock
+)
+
 const (
 	AccountHeaderName           = "X-ProxyFS-BiModal"
 	AccountHeaderNameTranslated = "X-Account-Sysmeta-Proxyfs-Bimodal"
@@ -42,6 +47,15 @@ const (
 	StoragePolicyHeaderName     = "X-Storage-Policy"
 )
 
+const (
+	CloneOriginHeaderName    = "X-Container-Meta-Cloned-From"
+	ClonePinHeaderNamePrefix = "X-Container-Meta-Clone-"
+)
+
+const (
+	ReplicaOfHeaderName = "X-Container-Meta-Replica-Of"
+)
+
 const (
 	MetadataRecycleBinHeaderName  = "X-Object-Meta-Recycle-Bin"
 	MetadataRecycleBinHeaderValue = "true"
@@ -54,7 +68,7 @@ type bPlusTreeTrackerStruct struct {
 type bPlusTreeWrapperStruct struct {
 	volumeView       *volumeViewStruct
 	bPlusTree        sortedmap.BPlusTree
-	bPlusTreeTracker *bPlusTreeTrackerStruct // For inodeRecWrapper, logSegmentRecWrapper, & bPlusTreeObjectWrapper:
+	bPlusTreeTracker *bPlusTreeTrackerStruct // For inodeRecWrapper, logSegmentRecWrapper, bPlusTreeObjectWrapper, & dedupWrapper:
 	//                                            only valid for liveView... nil otherwise
 	//                                          For createdObjectsWrapper & deletedObjectsWrapper:
 	//                                            all volumeView's share the corresponding one created for liveView
@@ -73,6 +87,7 @@ type volumeViewStruct struct {
 	inodeRecWrapper        *bPlusTreeWrapperStruct
 	logSegmentRecWrapper   *bPlusTreeWrapperStruct
 	bPlusTreeObjectWrapper *bPlusTreeWrapperStruct
+	dedupWrapper           *bPlusTreeWrapperStruct // only valid for liveView... nil otherwise
 	createdObjectsWrapper  *bPlusTreeWrapperStruct // if volumeView is     the liveView, should be empty
 	//                                                if volumeView is not the liveView, tracks objects created between this and the next volumeView
 	deletedObjectsWrapper *bPlusTreeWrapperStruct //  if volumeView is     the liveView, tracks objects to be deleted at next checkpoint
@@ -133,10 +148,20 @@ type volumeStruct struct {
 	checkpointEtcdKeyName                   string
 	checkpointContainerName                 string
 	checkpointContainerStoragePolicy        string
+	cloneOriginAccountName                  string            //             if != "", volume was cloned from a SnapShot of another volume
+	cloneOriginCheckpointContainerName      string            //             ...whose checkpoint container is in cloneOriginAccountName
+	cloneOriginNonce                        uint64            //             objects numbered below this reside in cloneOriginAccountName
+	replicaAccountName                      string            //             if != "", SnapShots are replicated to this Account
+	replicaOfAccountName                    string            //             if != "", volume is a (read-only) replica of a volume in this Account
+	isReplica                               uint32            //             accessed atomically; != 0 while replicaOfAccountName != ""
+	diffingSnapShotIDs                      map[uint64]uint64 //             count of FetchInodeRecDiff() calls in progress keyed by SnapShotID
+	replicationMutex                        trackedlock.Mutex //  serializes ReplicateSnapShots()
+	replicationRequestChan                  chan struct{}     //      nil if replicaAccountName == ""
+	replicationDaemonWG                     sync.WaitGroup
+	replicatedSnapShotNonce                 uint64 //             nonce of the SnapShot last replicated (0 if none)
+	replicatingSnapShotNonce                uint64 //             nonce of the SnapShot being replicated (0 if none)
 	checkpointInterval                      time.Duration
-	replayLogFileName                       string   //           if != "", use replay log to reduce RPO to zero
-	replayLogFile                           *os.File //           opened on first Put or Delete after checkpoint
-	//                                                            closed/deleted on successful checkpoint
+	replayLog                               replayLogBackend //   if != nil, use replay log to reduce RPO to zero
 	volumeGroup                             *volumeGroupStruct
 	served                                  bool
 	defaultReplayLogWriteBuffer             []byte //             used for O_DIRECT writes to replay log
@@ -151,8 +176,15 @@ type volumeStruct struct {
 	maxNonce                                uint64
 	nextNonce                               uint64
 	checkpointRequestChan                   chan *checkpointRequestStruct
+	checkpointInProgress                    uint32 //             accessed atomically; != 0 while checkpointDaemon() is performing a checkpoint
 	checkpointHeader                        *CheckpointHeaderStruct
 	checkpointHeaderEtcdRevision            int64
+	checkpointTime                          time.Time                   // when checkpointHeader's checkpoint was taken (zero if unknown)
+	checkpointRetentionCount                uint64                      // if == 0, superseded checkpoints are not retained
+	retainedCheckpointList                  []*retainedCheckpointStruct // oldest first
+	inspectedRetainedCheckpoint             *retainedCheckpointStruct   // if != nil, liveView is a (read-only) view of this retained checkpoint
+	inspectedRetainedCheckpointID           uint64                      // accessed atomically; ID of inspectedRetainedCheckpoint (0 if nil)
+	inspectedLiveView                       *volumeViewStruct           // ...and this is the liveView restored once inspection ends
 	liveView                                *volumeViewStruct
 	priorView                               *volumeViewStruct
 	postponePriorViewCreatedObjectsPuts     bool
@@ -162,6 +194,16 @@ type volumeStruct struct {
 	viewTreeByTime                          sortedmap.LLRBTree // key == volumeViewStruct.Time;  value == *volumeViewStruct
 	viewTreeByName                          sortedmap.LLRBTree // key == volumeViewStruct.Name;  value == *volumeViewStruct
 	availableSnapShotIDList                 *list.List
+	dedupChunkedBytes                       uint64 //             total bytes written subject to deduplication
+	dedupDedupedBytes                       uint64 //             ...of which were found to already be stored
+	dedupFingerprints                       uint64 //             number of Fingerprint records in dedup B+Tree
+	dedupLogSegments                        uint64 //             number of LogSegment  records in dedup B+Tree
+	dedupRebuildRequired                    bool   //             if true, LogSegment reference counts must be rebuilt
+	bPlusTreeCacheWeight                    uint64 //             share of process-wide B+Tree cache relative to other volumes
+	inodeRecCache                           *bPlusTreeCacheStruct
+	logSegmentRecCache                      *bPlusTreeCacheStruct //  also used for dedupWrapper
+	bPlusTreeObjectCache                    *bPlusTreeCacheStruct
+	createdDeletedObjectsCache              *bPlusTreeCacheStruct
 	backgroundObjectDeleteWG                sync.WaitGroup
 }
 
@@ -178,18 +220,21 @@ type globalsStruct struct {
 	ElementOfBPlusTreeLayoutStructSize      uint64
 	replayLogTransactionFixedPartStructSize uint64
 
-	inodeRecCache                              sortedmap.BPlusTreeCache
-	inodeRecCachePriorCacheHits                uint64
-	inodeRecCachePriorCacheMisses              uint64
-	logSegmentRecCache                         sortedmap.BPlusTreeCache
-	logSegmentRecCachePriorCacheHits           uint64
-	logSegmentRecCachePriorCacheMisses         uint64
-	bPlusTreeObjectCache                       sortedmap.BPlusTreeCache
-	bPlusTreeObjectCachePriorCacheHits         uint64
-	bPlusTreeObjectCachePriorCacheMisses       uint64
-	createdDeletedObjectsCache                 sortedmap.BPlusTreeCache
-	createdDeletedObjectsCachePriorCacheHits   uint64
-	createdDeletedObjectsCachePriorCacheMisses uint64
+	inodeRecCacheEvictLowLimit               uint64
+	inodeRecCacheEvictHighLimit              uint64
+	logSegmentRecCacheEvictLowLimit          uint64
+	logSegmentRecCacheEvictHighLimit         uint64
+	bPlusTreeObjectCacheEvictLowLimit        uint64
+	bPlusTreeObjectCacheEvictHighLimit       uint64
+	createdDeletedObjectsCacheEvictLowLimit  uint64
+	createdDeletedObjectsCacheEvictHighLimit uint64
+
+	bPlusTreeCacheMutex              trackedlock.Mutex
+	bPlusTreeCacheQuotaBytes         uint64 // if == 0, divide up the EvictLimit's above instead
+	bPlusTreeCacheRebalanceInterval  time.Duration
+	bPlusTreeCacheMap                map[string]*bPlusTreeCacheStruct // key == bPlusTreeCacheStruct.statsGroupName
+	bPlusTreeCacheRebalancerStopChan chan struct{}
+	bPlusTreeCacheRebalancerDoneChan chan struct{}
 
 	checkpointHeaderConsensusAttempts uint16
 	mountRetryLimit                   uint16
@@ -210,6 +255,11 @@ type globalsStruct struct {
 	etcdClient *etcd.Client
 	etcdKV     etcd.KV
 
+	replayLogPeerPort        uint16               // if != 0, Replay Logs of other peers' volumes are kept here
+	replayLogPeerCertificate tls.Certificate      // presented both as replay log peer server and client
+	replayLogPeerCAPEM       []byte               // CA that must have signed every replay log peer's certificate
+	replayLogPeerServer      *ReplayLogPeerServer // nil if replayLogPeerPort == 0
+
 	volumeGroupMap map[string]*volumeGroupStruct // key == volumeGroupStruct.name
 	volumeMap      map[string]*volumeStruct      // key == volumeStruct.volumeName
 
@@ -268,6 +318,12 @@ type globalsStruct struct {
 	FetchLayoutReportUsec                     bucketstats.BucketLog2Round
 	SnapShotCreateByInodeLayerUsec            bucketstats.BucketLog2Round
 	SnapShotDeleteByInodeLayerUsec            bucketstats.BucketLog2Round
+	SnapShotRevertByInodeLayerUsec            bucketstats.BucketLog2Round
+	RetainedCheckpointInspectUsec             bucketstats.BucketLog2Round
+	RetainedCheckpointRevertUsec              bucketstats.BucketLog2Round
+	SnapShotCloneByInodeLayerUsec             bucketstats.BucketLog2Round
+	ReplicateSnapShotsUsec                    bucketstats.BucketLog2Round
+	RestoreLogSegmentRecsUsec                 bucketstats.BucketLog2Round
 	SnapShotCountUsec                         bucketstats.BucketLog2Round
 	SnapShotLookupByNameUsec                  bucketstats.BucketLog2Round
 	SnapShotListByIDUsec                      bucketstats.BucketLog2Round
@@ -276,6 +332,13 @@ type globalsStruct struct {
 	SnapShotU64DecodeUsec                     bucketstats.BucketLog2Round
 	SnapShotIDAndNonceEncodeUsec              bucketstats.BucketLog2Round
 	SnapShotTypeDotSnapShotAndNonceEncodeUsec bucketstats.BucketLog2Round
+	FetchInodeRecDiffUsec                     bucketstats.BucketLog2Round
+	FetchInodeRecDiffEntries                  bucketstats.BucketLog2Round
+	ReferenceDedupChunkUsec                   bucketstats.BucketLog2Round
+	PutDedupFingerprintsUsec                  bucketstats.BucketLog2Round
+	ReferenceLogSegmentRecUsec                bucketstats.BucketLog2Round
+	UnreferenceLogSegmentRecUsec              bucketstats.BucketLog2Round
+	RebuildDedupRefCountsUsec                 bucketstats.BucketLog2Round
 
 	GetInodeRecErrors                  bucketstats.Total
 	PutInodeRecErrors                  bucketstats.Total
@@ -296,8 +359,20 @@ type globalsStruct struct {
 	FetchLayoutReportErrors            bucketstats.Total
 	SnapShotCreateByInodeLayerErrors   bucketstats.Total
 	SnapShotDeleteByInodeLayerErrors   bucketstats.Total
+	SnapShotRevertByInodeLayerErrors   bucketstats.Total
+	RetainedCheckpointInspectErrors    bucketstats.Total
+	RetainedCheckpointRevertErrors     bucketstats.Total
+	SnapShotCloneByInodeLayerErrors    bucketstats.Total
+	ReplicateSnapShotsErrors           bucketstats.Total
+	RestoreLogSegmentRecsErrors        bucketstats.Total
 	SnapShotCountErrors                bucketstats.Total
 	SnapShotLookupByNameErrors         bucketstats.Total
+	FetchInodeRecDiffErrors            bucketstats.Total
+	ReferenceDedupChunkErrors          bucketstats.Total
+	PutDedupFingerprintsErrors         bucketstats.Total
+	ReferenceLogSegmentRecErrors       bucketstats.Total
+	UnreferenceLogSegmentRecErrors     bucketstats.Total
+	RebuildDedupRefCountsErrors        bucketstats.Total
 }
 
 var globals globalsStruct
@@ -308,21 +383,19 @@ func init() {
 
 func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 	var (
-		bPlusTreeObjectCacheEvictHighLimit       uint64
-		bPlusTreeObjectCacheEvictLowLimit        uint64
-		createdDeletedObjectsCacheEvictHighLimit uint64
-		createdDeletedObjectsCacheEvictLowLimit  uint64
 		dummyElementOfBPlusTreeLayoutStruct      ElementOfBPlusTreeLayoutStruct
 		dummyReplayLogTransactionFixedPartStruct replayLogTransactionFixedPartStruct
 		dummyUint64                              uint64
-		inodeRecCacheEvictHighLimit              uint64
-		inodeRecCacheEvictLowLimit               uint64
-		logSegmentRecCacheEvictHighLimit         uint64
-		logSegmentRecCacheEvictLowLimit          uint64
 		mountRetryDelay                          time.Duration
 		mountRetryExpBackoff                     float64
 		mountRetryIndex                          uint16
 		nextMountRetryDelay                      time.Duration
+		replayLogPeerCAFilePath                  string
+		replayLogPeerCertFilePath                string
+		replayLogPeerDirectory                   string
+		replayLogPeerKeyFilePath                 string
+		replayLogPeerPublicIPAddr                string
+		whoAmI                                   string
 	)
 
 	bucketstats.Register("proxyfs.headhunter", "", &globals)
@@ -351,64 +424,37 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 	globals.volumeGroupMap = make(map[string]*volumeGroupStruct)
 	globals.volumeMap = make(map[string]*volumeStruct)
 
-	// Initialize B+Tree caches
+	// Record B+Tree cache limits and start rebalancing them across volumes
 
-	inodeRecCacheEvictLowLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "InodeRecCacheEvictLowLimit")
+	globals.inodeRecCacheEvictLowLimit, globals.inodeRecCacheEvictHighLimit, err = fetchBPlusTreeCacheLimits(confMap, "InodeRecCache")
 	if nil != err {
 		return
 	}
-	inodeRecCacheEvictHighLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "InodeRecCacheEvictHighLimit")
-	if nil != err {
-		return
-	}
-
-	globals.inodeRecCache = sortedmap.NewBPlusTreeCache(inodeRecCacheEvictLowLimit, inodeRecCacheEvictHighLimit)
-
-	globals.inodeRecCachePriorCacheHits = 0
-	globals.inodeRecCachePriorCacheMisses = 0
 
-	logSegmentRecCacheEvictLowLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "LogSegmentRecCacheEvictLowLimit")
+	globals.logSegmentRecCacheEvictLowLimit, globals.logSegmentRecCacheEvictHighLimit, err = fetchBPlusTreeCacheLimits(confMap, "LogSegmentRecCache")
 	if nil != err {
 		return
 	}
-	logSegmentRecCacheEvictHighLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "LogSegmentRecCacheEvictHighLimit")
+
+	globals.bPlusTreeObjectCacheEvictLowLimit, globals.bPlusTreeObjectCacheEvictHighLimit, err = fetchBPlusTreeCacheLimits(confMap, "BPlusTreeObjectCache")
 	if nil != err {
 		return
 	}
 
-	globals.logSegmentRecCache = sortedmap.NewBPlusTreeCache(logSegmentRecCacheEvictLowLimit, logSegmentRecCacheEvictHighLimit)
-
-	globals.logSegmentRecCachePriorCacheHits = 0
-	globals.logSegmentRecCachePriorCacheMisses = 0
-
-	bPlusTreeObjectCacheEvictLowLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "BPlusTreeObjectCacheEvictLowLimit")
+	globals.createdDeletedObjectsCacheEvictLowLimit, globals.createdDeletedObjectsCacheEvictHighLimit, err = fetchBPlusTreeCacheLimits(confMap, "CreatedDeletedObjectsCache")
 	if nil != err {
 		return
 	}
-	bPlusTreeObjectCacheEvictHighLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "BPlusTreeObjectCacheEvictHighLimit")
-	if nil != err {
-		return
+	if 0 == globals.createdDeletedObjectsCacheEvictHighLimit {
+		globals.createdDeletedObjectsCacheEvictLowLimit = globals.logSegmentRecCacheEvictLowLimit // TODO: Eventually just return
+		globals.createdDeletedObjectsCacheEvictHighLimit = globals.logSegmentRecCacheEvictHighLimit
 	}
 
-	globals.bPlusTreeObjectCache = sortedmap.NewBPlusTreeCache(bPlusTreeObjectCacheEvictLowLimit, bPlusTreeObjectCacheEvictHighLimit)
-
-	globals.bPlusTreeObjectCachePriorCacheHits = 0
-	globals.bPlusTreeObjectCachePriorCacheMisses = 0
-
-	createdDeletedObjectsCacheEvictLowLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "CreatedDeletedObjectsCacheEvictLowLimit")
+	err = bPlusTreeCacheUp(confMap)
 	if nil != err {
-		createdDeletedObjectsCacheEvictLowLimit = logSegmentRecCacheEvictLowLimit // TODO: Eventually just return
-	}
-	createdDeletedObjectsCacheEvictHighLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "CreatedDeletedObjectsCacheEvictHighLimit")
-	if nil != err {
-		createdDeletedObjectsCacheEvictHighLimit = logSegmentRecCacheEvictHighLimit // TODO: Eventually just return
+		return
 	}
 
-	globals.createdDeletedObjectsCache = sortedmap.NewBPlusTreeCache(createdDeletedObjectsCacheEvictLowLimit, createdDeletedObjectsCacheEvictHighLimit)
-
-	globals.createdDeletedObjectsCachePriorCacheHits = 0
-	globals.createdDeletedObjectsCachePriorCacheMisses = 0
-
 	// Record mount retry parameters and compute retry delays
 
 	globals.checkpointHeaderConsensusAttempts, err = confMap.FetchOptionValueUint16("FSGlobals", "CheckpointHeaderConsensusAttempts")
@@ -491,6 +537,59 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 		globals.etcdKV = etcd.NewKV(globals.etcdClient)
 	}
 
+	// Start replay log peer server if requested
+
+	globals.replayLogPeerPort, err = confMap.FetchOptionValueUint16("FSGlobals", "ReplayLogPeerPort")
+	if nil != err {
+		globals.replayLogPeerPort = 0 // Default to not keeping Replay Logs for other peers if not present
+	}
+
+	if 0 != globals.replayLogPeerPort {
+		replayLogPeerDirectory, err = confMap.FetchOptionValueString("FSGlobals", "ReplayLogPeerDirectory")
+		if nil != err {
+			return
+		}
+
+		// Replay log peers must mutually authenticate via certificates signed by a common CA
+
+		replayLogPeerCertFilePath, err = confMap.FetchOptionValueString("FSGlobals", "ReplayLogPeerCertFilePath")
+		if nil != err {
+			return
+		}
+		replayLogPeerKeyFilePath, err = confMap.FetchOptionValueString("FSGlobals", "ReplayLogPeerKeyFilePath")
+		if nil != err {
+			return
+		}
+		replayLogPeerCAFilePath, err = confMap.FetchOptionValueString("FSGlobals", "ReplayLogPeerCAFilePath")
+		if nil != err {
+			return
+		}
+		globals.replayLogPeerCertificate, err = tls.LoadX509KeyPair(replayLogPeerCertFilePath, replayLogPeerKeyFilePath)
+		if nil != err {
+			err = fmt.Errorf("failed to load [FSGlobals]ReplayLogPeer{Cert|Key}FilePath [\"%s\",\"%s\"]: %v", replayLogPeerCertFilePath, replayLogPeerKeyFilePath, err)
+			return
+		}
+		globals.replayLogPeerCAPEM, err = ioutil.ReadFile(replayLogPeerCAFilePath)
+		if nil != err {
+			err = fmt.Errorf("failed to load [FSGlobals]ReplayLogPeerCAFilePath [\"%s\"]: %v", replayLogPeerCAFilePath, err)
+			return
+		}
+
+		whoAmI, err = confMap.FetchOptionValueString("Cluster", "WhoAmI")
+		if nil != err {
+			return
+		}
+		replayLogPeerPublicIPAddr, err = confMap.FetchOptionValueString("Peer:"+whoAmI, "PublicIPAddr")
+		if nil != err {
+			return
+		}
+
+		err = replayLogPeerServerUp(replayLogPeerPublicIPAddr, globals.replayLogPeerPort, replayLogPeerDirectory)
+		if nil != err {
+			return
+		}
+	}
+
 	// Record MetadataRecycleBin setting
 
 	globals.metadataRecycleBin, err = confMap.FetchOptionValueBool("FSGlobals", "MetadataRecycleBin")
@@ -700,6 +799,9 @@ func (dummy *globalsStruct) SignaledFinish(confMap conf.ConfMap) (err error) {
 }
 
 func (dummy *globalsStruct) Down(confMap conf.ConfMap) (err error) {
+	replayLogPeerServerDown()
+	bPlusTreeCacheDown()
+
 	if globals.etcdEnabled {
 		globals.etcdKV = nil
 
@@ -726,16 +828,23 @@ func (dummy *globalsStruct) Down(confMap conf.ConfMap) (err error) {
 
 func (volume *volumeStruct) up(confMap conf.ConfMap) (err error) {
 	var (
-		autoFormat            bool
-		autoFormatStringSlice []string
-		mountRetryIndex       uint16
-		volumeSectionName     string
+		autoFormat                bool
+		autoFormatStringSlice     []string
+		mountRetryIndex           uint16
+		replayLogBackendName      string
+		replayLogEtcdKeyName      string
+		replayLogFileName         string
+		replayLogLocal            *replayLogFileStruct
+		replayLogPeerName         string
+		replayLogPeerPublicIPAddr string
+		volumeSectionName         string
 	)
 
 	volumeSectionName = "Volume:" + volume.volumeName
 
 	volume.checkpointChunkedPutContext = nil
 	volume.eventListeners = make(map[VolumeEventListener]struct{})
+	volume.diffingSnapShotIDs = make(map[uint64]uint64)
 	volume.checkpointRequestChan = make(chan *checkpointRequestStruct, 1)
 	volume.postponePriorViewCreatedObjectsPuts = false
 	volume.postponedPriorViewCreatedObjectsPuts = make(map[uint64]struct{})
@@ -797,13 +906,79 @@ func (volume *volumeStruct) up(confMap conf.ConfMap) (err error) {
 		return
 	}
 
-	volume.replayLogFileName, err = confMap.FetchOptionValueString(volumeSectionName, "ReplayLogFileName")
-	if nil == err {
+	volume.checkpointRetentionCount, err = confMap.FetchOptionValueUint64(volumeSectionName, "CheckpointRetentionCount")
+	if nil != err {
+		volume.checkpointRetentionCount = 0 // Default to not retaining superseded checkpoints if not present
+	}
+
+	volume.replicaAccountName, err = confMap.FetchOptionValueString(volumeSectionName, "ReplicaAccountName")
+	if nil != err {
+		volume.replicaAccountName = "" // Default to no replication if not present
+	}
+	if volume.replicaAccountName == volume.accountName {
+		err = fmt.Errorf("[%v]ReplicaAccountName must differ from AccountName", volumeSectionName)
+		return
+	}
+
+	replayLogBackendName, err = confMap.FetchOptionValueString(volumeSectionName, "ReplayLogBackend")
+	if nil != err {
+		replayLogBackendName = replayLogBackendFile // Default to a local file if not present
+	}
+
+	replayLogFileName, err = confMap.FetchOptionValueString(volumeSectionName, "ReplayLogFileName")
+	if nil != err {
+		replayLogFileName = "" // Default to no local file if not present
+	}
+
+	switch replayLogBackendName {
+	case replayLogBackendFile:
+		if "" == replayLogFileName {
+			// Disable Replay Log
+			volume.replayLog = nil
+		} else {
+			volume.replayLog = &replayLogFileStruct{fileName: replayLogFileName}
+		}
+	case replayLogBackendEtcd:
+		if !globals.etcdEnabled {
+			err = fmt.Errorf("[%v]ReplayLogBackend == \"%s\" requires [FSGlobals]EtcdEnabled", volumeSectionName, replayLogBackendEtcd)
+			return
+		}
+		replayLogEtcdKeyName, err = confMap.FetchOptionValueString(volumeSectionName, "ReplayLogEtcdKeyName")
+		if nil != err {
+			return
+		}
+		volume.replayLog = &replayLogEtcdStruct{keyName: replayLogEtcdKeyName}
+	case replayLogBackendPeer:
+		if 0 == globals.replayLogPeerPort {
+			err = fmt.Errorf("[%v]ReplayLogBackend == \"%s\" requires [FSGlobals]ReplayLogPeerPort", volumeSectionName, replayLogBackendPeer)
+			return
+		}
+		replayLogPeerName, err = confMap.FetchOptionValueString(volumeSectionName, "ReplayLogPeer")
+		if nil != err {
+			return
+		}
+		replayLogPeerPublicIPAddr, err = confMap.FetchOptionValueString("Peer:"+replayLogPeerName, "PublicIPAddr")
+		if nil != err {
+			return
+		}
+		if "" == replayLogFileName {
+			replayLogLocal = nil
+		} else {
+			replayLogLocal = &replayLogFileStruct{fileName: replayLogFileName}
+		}
+		volume.replayLog, err = newReplayLogPeer(volume, replayLogPeerName, replayLogPeerPublicIPAddr, replayLogLocal)
+		if nil != err {
+			volume.replayLog = nil
+			return
+		}
+	default:
+		err = fmt.Errorf("[%v]ReplayLogBackend must be one of \"%s\", \"%s\", or \"%s\"", volumeSectionName, replayLogBackendFile, replayLogBackendEtcd, replayLogBackendPeer)
+		return
+	}
+
+	if nil != volume.replayLog {
 		// Provision aligned buffer used to write to Replay Log
 		volume.defaultReplayLogWriteBuffer = constructReplayLogWriteBuffer(replayLogWriteBufferDefaultSize)
-	} else {
-		// Disable Replay Log
-		volume.replayLogFileName = ""
 	}
 
 	volume.snapShotIDNumBits, err = confMap.FetchOptionValueUint16(volumeSectionName, "SnapShotIDNumBits")
@@ -819,6 +994,20 @@ func (volume *volumeStruct) up(confMap conf.ConfMap) (err error) {
 		return
 	}
 
+	volume.bPlusTreeCacheWeight, err = confMap.FetchOptionValueUint64(volumeSectionName, "BPlusTreeCacheWeight")
+	if nil != err {
+		volume.bPlusTreeCacheWeight = bPlusTreeCacheDefaultWeight // Default to 100 if not present
+	}
+	if 0 == volume.bPlusTreeCacheWeight {
+		err = fmt.Errorf("[%v]BPlusTreeCacheWeight must be non-zero", volumeSectionName)
+		return
+	}
+
+	volume.inodeRecCache = volume.registerBPlusTreeCache("InodeRec", globals.inodeRecCacheEvictLowLimit, globals.inodeRecCacheEvictHighLimit)
+	volume.logSegmentRecCache = volume.registerBPlusTreeCache("LogSegmentRec", globals.logSegmentRecCacheEvictLowLimit, globals.logSegmentRecCacheEvictHighLimit)
+	volume.bPlusTreeObjectCache = volume.registerBPlusTreeCache("BPlusTreeObject", globals.bPlusTreeObjectCacheEvictLowLimit, globals.bPlusTreeObjectCacheEvictHighLimit)
+	volume.createdDeletedObjectsCache = volume.registerBPlusTreeCache("CreatedDeletedObjects", globals.createdDeletedObjectsCacheEvictLowLimit, globals.createdDeletedObjectsCacheEvictHighLimit)
+
 	autoFormatStringSlice, err = confMap.FetchOptionValueStringSlice(volumeSectionName, "AutoFormat")
 	if nil == err {
 		if 1 != len(autoFormatStringSlice) {
@@ -858,6 +1047,14 @@ func (volume *volumeStruct) up(confMap conf.ConfMap) (err error) {
 
 	go volume.checkpointDaemon()
 
+	if "" == volume.replicaAccountName {
+		volume.replicationRequestChan = nil
+	} else {
+		volume.replicationRequestChan = make(chan struct{}, 1)
+		volume.replicationDaemonWG.Add(1)
+		go volume.replicationDaemon()
+	}
+
 	err = nil
 	return
 }
@@ -867,6 +1064,12 @@ func (volume *volumeStruct) down(confMap conf.ConfMap) (err error) {
 		checkpointRequest checkpointRequestStruct
 	)
 
+	if nil != volume.replicationRequestChan {
+		close(volume.replicationRequestChan)
+		volume.replicationDaemonWG.Wait()
+		volume.replicationRequestChan = nil
+	}
+
 	checkpointRequest.exitOnCompletion = true
 	checkpointRequest.waitGroup.Add(1)
 
@@ -878,5 +1081,11 @@ func (volume *volumeStruct) down(confMap conf.ConfMap) (err error) {
 
 	volume.backgroundObjectDeleteWG.Wait()
 
+	if nil != volume.replayLog {
+		volume.replayLog.close()
+	}
+
+	volume.unregisterBPlusTreeCaches()
+
 	return
 }
@@ -0,0 +1,738 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/NVIDIA/cstruct"
+	"github.com/NVIDIA/sortedmap"
+
+	"github.com/NVIDIA/proxyfs/logger"
+	"github.com/NVIDIA/proxyfs/utils"
+)
+
+// The dedup B+Tree is maintained only for the live view and holds two kinds of records:
+//
+//   Fingerprint records (key has dedupFingerprintKeyFlag set):
+//     key   == dedupFingerprintKeyFlag | (first 8 bytes of Fingerprint)
+//     value == LogSegmentNumber, LogSegmentOffset, Length (each a uint64) followed by the full Fingerprint
+//
+//   LogSegment records (key has dedupFingerprintKeyFlag clear):
+//     key   == LogSegmentNumber
+//     value == RefCount (a uint64) followed by the (uint64) keys of the Fingerprint records referencing it
+//
+// RefCount is the number of FileInodes in the live view whose LogSegmentMap references the LogSegment.
+// LogSegments lacking a LogSegment record are implicitly referenced by a single FileInode. As a
+// truncated Fingerprint may collide, a Fingerprint record only ever describes the first chunk that
+// hashed to its key... subsequent (differing) chunks simply go undeduplicated.
+
+const (
+	dedupFingerprintKeyFlag = uint64(0x8000000000000000)
+)
+
+const (
+	dedupFingerprintRecFixedSize = 3 * 8 // LogSegmentNumber, LogSegmentOffset, Length
+	dedupLogSegmentRecFixedSize  = 8     // RefCount
+)
+
+func dedupFingerprintKey(fingerprint []byte) (key uint64, err error) {
+	if 8 > len(fingerprint) {
+		err = fmt.Errorf("Dedup fingerprint must be at least 8 bytes (was %v)", len(fingerprint))
+		return
+	}
+
+	key = dedupFingerprintKeyFlag | binary.BigEndian.Uint64(fingerprint[:8])
+
+	err = nil
+	return
+}
+
+func packDedupFingerprintRec(logSegmentNumber uint64, logSegmentOffset uint64, length uint64, fingerprint []byte) (value []byte) {
+	value = make([]byte, 0, dedupFingerprintRecFixedSize+len(fingerprint))
+	value = append(value, utils.Uint64ToByteSlice(logSegmentNumber)...)
+	value = append(value, utils.Uint64ToByteSlice(logSegmentOffset)...)
+	value = append(value, utils.Uint64ToByteSlice(length)...)
+	value = append(value, fingerprint...)
+	return
+}
+
+func unpackDedupFingerprintRec(value []byte) (logSegmentNumber uint64, logSegmentOffset uint64, length uint64, fingerprint []byte, err error) {
+	if dedupFingerprintRecFixedSize > len(value) {
+		err = fmt.Errorf("Dedup fingerprint record too short (%v bytes)", len(value))
+		return
+	}
+
+	logSegmentNumber, _ = utils.ByteSliceToUint64(value[0:8])
+	logSegmentOffset, _ = utils.ByteSliceToUint64(value[8:16])
+	length, _ = utils.ByteSliceToUint64(value[16:24])
+	fingerprint = value[dedupFingerprintRecFixedSize:]
+
+	err = nil
+	return
+}
+
+func packDedupLogSegmentRec(refCount uint64, fingerprintKeys []uint64) (value []byte) {
+	var (
+		fingerprintKey uint64
+	)
+
+	value = make([]byte, 0, dedupLogSegmentRecFixedSize+8*len(fingerprintKeys))
+	value = append(value, utils.Uint64ToByteSlice(refCount)...)
+
+	for _, fingerprintKey = range fingerprintKeys {
+		value = append(value, utils.Uint64ToByteSlice(fingerprintKey)...)
+	}
+
+	return
+}
+
+func unpackDedupLogSegmentRec(value []byte) (refCount uint64, fingerprintKeys []uint64, err error) {
+	var (
+		fingerprintKey uint64
+		position       int
+	)
+
+	if (dedupLogSegmentRecFixedSize > len(value)) || (0 != ((len(value) - dedupLogSegmentRecFixedSize) % 8)) {
+		err = fmt.Errorf("Dedup LogSegment record malformed (%v bytes)", len(value))
+		return
+	}
+
+	refCount, _ = utils.ByteSliceToUint64(value[0:8])
+
+	fingerprintKeys = make([]uint64, 0, (len(value)-dedupLogSegmentRecFixedSize)/8)
+
+	for position = dedupLogSegmentRecFixedSize; position < len(value); position += 8 {
+		fingerprintKey, _ = utils.ByteSliceToUint64(value[position:(position + 8)])
+		fingerprintKeys = append(fingerprintKeys, fingerprintKey)
+	}
+
+	err = nil
+	return
+}
+
+// newDedupWrapperWhileLocked sets up an empty dedup B+Tree for the liveView.
+func (volume *volumeStruct) newDedupWrapperWhileLocked() {
+	volume.liveView.dedupWrapper = &bPlusTreeWrapperStruct{
+		volumeView:       volume.liveView,
+		bPlusTreeTracker: &bPlusTreeTrackerStruct{bPlusTreeLayout: make(sortedmap.LayoutReport)},
+	}
+
+	volume.liveView.dedupWrapper.bPlusTree =
+		sortedmap.NewBPlusTree(
+			volume.maxLogSegmentsPerMetadataNode,
+			sortedmap.CompareUint64,
+			volume.liveView.dedupWrapper,
+			volume.logSegmentRecCache.cache)
+
+	volume.dedupChunkedBytes = 0
+	volume.dedupDedupedBytes = 0
+	volume.dedupFingerprints = 0
+	volume.dedupLogSegments = 0
+	volume.dedupRebuildRequired = false
+}
+
+// unpackDedupTrailerWhileLocked consumes the (optional) CheckpointObjectTrailerDedupStruct (and
+// the layout of the dedup B+Tree following it) from the remainder of a checkpointObjectTrailer.
+func (volume *volumeStruct) unpackDedupTrailerWhileLocked(checkpointObjectTrailerBuf []byte) (remainingBuf []byte, err error) {
+	var (
+		bytesConsumed            uint64
+		dedupTrailer             CheckpointObjectTrailerDedupStruct
+		elementOfBPlusTreeLayout ElementOfBPlusTreeLayoutStruct
+		layoutReportIndex        uint64
+	)
+
+	remainingBuf = checkpointObjectTrailerBuf
+
+	if 0 == len(remainingBuf) {
+		err = nil
+		return
+	}
+
+	bytesConsumed, err = cstruct.Unpack(remainingBuf, &dedupTrailer, LittleEndian)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse volume %v's checkpointObjectTrailer's dedup trailer: %v", volume.volumeName, err)
+		return
+	}
+	remainingBuf = remainingBuf[bytesConsumed:]
+
+	if uint64(len(remainingBuf)) < (dedupTrailer.DedupBPlusTreeLayoutNumElements * globals.ElementOfBPlusTreeLayoutStructSize) {
+		err = fmt.Errorf("Cannot parse volume %v's checkpointObjectTrailer's dedup B+Tree layout", volume.volumeName)
+		return
+	}
+
+	for layoutReportIndex = 0; layoutReportIndex < dedupTrailer.DedupBPlusTreeLayoutNumElements; layoutReportIndex++ {
+		bytesConsumed, err = cstruct.Unpack(remainingBuf, &elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+		remainingBuf = remainingBuf[bytesConsumed:]
+
+		volume.liveView.dedupWrapper.bPlusTreeTracker.bPlusTreeLayout[elementOfBPlusTreeLayout.ObjectNumber] = elementOfBPlusTreeLayout.ObjectBytes
+	}
+
+	if 0 != dedupTrailer.DedupBPlusTreeObjectNumber {
+		volume.liveView.dedupWrapper.bPlusTree, err =
+			sortedmap.OldBPlusTree(
+				dedupTrailer.DedupBPlusTreeObjectNumber,
+				dedupTrailer.DedupBPlusTreeObjectOffset,
+				dedupTrailer.DedupBPlusTreeObjectLength,
+				sortedmap.CompareUint64,
+				volume.liveView.dedupWrapper,
+				volume.logSegmentRecCache.cache)
+		if nil != err {
+			return
+		}
+	}
+
+	volume.dedupChunkedBytes = dedupTrailer.DedupChunkedBytes
+	volume.dedupDedupedBytes = dedupTrailer.DedupDedupedBytes
+	volume.dedupFingerprints = dedupTrailer.DedupFingerprints
+	volume.dedupLogSegments = dedupTrailer.DedupLogSegments
+	volume.dedupRebuildRequired = (0 != dedupTrailer.DedupRebuildRequired)
+
+	err = nil
+	return
+}
+
+// dedupInUseWhileLocked returns whether or not deduplication has ever been applied to the volume.
+// If not, no CheckpointObjectTrailerDedupStruct need be appended to the checkpointObjectTrailer
+// (retaining compatibility with prior checkpoint formats).
+func (volume *volumeStruct) dedupInUseWhileLocked() (inUse bool) {
+	inUse = (0 != volume.dedupChunkedBytes) || (0 != volume.dedupLogSegments) || volume.dedupRebuildRequired
+	return
+}
+
+// composeDedupTrailerWhileLocked returns the CheckpointObjectTrailerDedupStruct (and the layout of
+// the dedup B+Tree following it) to be appended to the checkpointObjectTrailer. If deduplication
+// has never been applied to the volume, dedupTrailerBuf will be empty.
+func (volume *volumeStruct) composeDedupTrailerWhileLocked(rootObjectNumber uint64, rootObjectOffset uint64, rootObjectLength uint64) (dedupTrailerBuf []byte, err error) {
+	var (
+		dedupTrailer                CheckpointObjectTrailerDedupStruct
+		elementOfBPlusTreeLayout    ElementOfBPlusTreeLayoutStruct
+		elementOfBPlusTreeLayoutBuf []byte
+	)
+
+	if !volume.dedupInUseWhileLocked() {
+		dedupTrailerBuf = make([]byte, 0)
+		err = nil
+		return
+	}
+
+	dedupTrailer.DedupBPlusTreeObjectNumber = rootObjectNumber
+	dedupTrailer.DedupBPlusTreeObjectOffset = rootObjectOffset
+	dedupTrailer.DedupBPlusTreeObjectLength = rootObjectLength
+	dedupTrailer.DedupBPlusTreeLayoutNumElements = uint64(len(volume.liveView.dedupWrapper.bPlusTreeTracker.bPlusTreeLayout))
+	dedupTrailer.DedupChunkedBytes = volume.dedupChunkedBytes
+	dedupTrailer.DedupDedupedBytes = volume.dedupDedupedBytes
+	dedupTrailer.DedupFingerprints = volume.dedupFingerprints
+	dedupTrailer.DedupLogSegments = volume.dedupLogSegments
+	if volume.dedupRebuildRequired {
+		dedupTrailer.DedupRebuildRequired = 1
+	} else {
+		dedupTrailer.DedupRebuildRequired = 0
+	}
+
+	dedupTrailerBuf, err = cstruct.Pack(&dedupTrailer, LittleEndian)
+	if nil != err {
+		return
+	}
+
+	for elementOfBPlusTreeLayout.ObjectNumber, elementOfBPlusTreeLayout.ObjectBytes = range volume.liveView.dedupWrapper.bPlusTreeTracker.bPlusTreeLayout {
+		elementOfBPlusTreeLayoutBuf, err = cstruct.Pack(&elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+		dedupTrailerBuf = append(dedupTrailerBuf, elementOfBPlusTreeLayoutBuf...)
+	}
+
+	err = nil
+	return
+}
+
+// composeCloneDedupTrailerWhileLocked returns the CheckpointObjectTrailerDedupStruct to append to the
+// checkpoint trailer of a volume (clone or replica) whose live view will be a SnapShot of this volume.
+// As the dedup B+Tree only describes the live view, it is not shared. Instead, if deduplication has
+// been applied to this volume, the other volume is marked as requiring its reference counts to be
+// rebuilt before it may safely delete LogSegments.
+func (volume *volumeStruct) composeCloneDedupTrailerWhileLocked() (dedupTrailerBuf []byte, err error) {
+	var (
+		dedupTrailer CheckpointObjectTrailerDedupStruct
+	)
+
+	if !volume.dedupInUseWhileLocked() {
+		dedupTrailerBuf = make([]byte, 0)
+		err = nil
+		return
+	}
+
+	dedupTrailer.DedupChunkedBytes = volume.dedupChunkedBytes
+	dedupTrailer.DedupDedupedBytes = volume.dedupDedupedBytes
+	dedupTrailer.DedupRebuildRequired = 1
+
+	dedupTrailerBuf, err = cstruct.Pack(&dedupTrailer, LittleEndian)
+
+	return // err set as appropriate
+}
+
+// fetchDedupLogSegmentRecWhileLocked returns the RefCount and Fingerprint record keys of the LogSegment
+// record for logSegmentNumber. If there is no such record, ok will be false.
+func (volume *volumeStruct) fetchDedupLogSegmentRecWhileLocked(logSegmentNumber uint64) (refCount uint64, fingerprintKeys []uint64, ok bool, err error) {
+	var (
+		value sortedmap.Value
+	)
+
+	value, ok, err = volume.liveView.dedupWrapper.bPlusTree.GetByKey(logSegmentNumber)
+	if (nil != err) || !ok {
+		return
+	}
+
+	refCount, fingerprintKeys, err = unpackDedupLogSegmentRec(value.([]byte))
+
+	return // err set as appropriate
+}
+
+func (volume *volumeStruct) putDedupLogSegmentRecWhileLocked(logSegmentNumber uint64, refCount uint64, fingerprintKeys []uint64) (err error) {
+	var (
+		ok    bool
+		value []byte
+	)
+
+	value = packDedupLogSegmentRec(refCount, fingerprintKeys)
+
+	ok, err = volume.liveView.dedupWrapper.bPlusTree.PatchByKey(logSegmentNumber, value)
+	if nil != err {
+		return
+	}
+	if !ok {
+		_, err = volume.liveView.dedupWrapper.bPlusTree.Put(logSegmentNumber, value)
+		if nil != err {
+			return
+		}
+		volume.dedupLogSegments++
+	}
+
+	volume.checkpointTriggeringEvents++
+
+	return
+}
+
+// deleteDedupLogSegmentRecWhileLocked removes the LogSegment record for logSegmentNumber along with
+// all of the Fingerprint records that reference it.
+func (volume *volumeStruct) deleteDedupLogSegmentRecWhileLocked(logSegmentNumber uint64, fingerprintKeys []uint64) (err error) {
+	var (
+		fingerprintKey uint64
+		ok             bool
+	)
+
+	for _, fingerprintKey = range fingerprintKeys {
+		ok, err = volume.liveView.dedupWrapper.bPlusTree.DeleteByKey(fingerprintKey)
+		if nil != err {
+			return
+		}
+		if ok {
+			volume.dedupFingerprints--
+		}
+	}
+
+	ok, err = volume.liveView.dedupWrapper.bPlusTree.DeleteByKey(logSegmentNumber)
+	if nil != err {
+		return
+	}
+	if ok {
+		volume.dedupLogSegments--
+	}
+
+	volume.checkpointTriggeringEvents++
+
+	return
+}
+
+func (volume *volumeStruct) ReferenceDedupChunk(fingerprint []byte) (logSegmentNumber uint64, logSegmentOffset uint64, length uint64, ok bool, err error) {
+	var (
+		fingerprintKey    uint64
+		fingerprintKeys   []uint64
+		recordFingerprint []byte
+		refCount          uint64
+		value             sortedmap.Value
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.ReferenceDedupChunkUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.ReferenceDedupChunkErrors.Add(1)
+		}
+	}()
+
+	fingerprintKey, err = dedupFingerprintKey(fingerprint)
+	if nil != err {
+		return
+	}
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	value, ok, err = volume.liveView.dedupWrapper.bPlusTree.GetByKey(fingerprintKey)
+	if (nil != err) || !ok {
+		return
+	}
+
+	logSegmentNumber, logSegmentOffset, length, recordFingerprint, err = unpackDedupFingerprintRec(value.([]byte))
+	if nil != err {
+		return
+	}
+
+	if !bytes.Equal(fingerprint, recordFingerprint) {
+		// Truncated Fingerprint collision... so treat as a miss
+
+		ok = false
+		return
+	}
+
+	_, ok, err = volume.liveView.logSegmentRecWrapper.bPlusTree.GetByKey(logSegmentNumber)
+	if (nil != err) || !ok {
+		// LogSegment no longer present (e.g. deleted since the dedup B+Tree was last checkpointed)
+
+		return
+	}
+
+	refCount, fingerprintKeys, ok, err = volume.fetchDedupLogSegmentRecWhileLocked(logSegmentNumber)
+	if (nil != err) || !ok {
+		return
+	}
+
+	err = volume.putDedupLogSegmentRecWhileLocked(logSegmentNumber, refCount+1, fingerprintKeys)
+	if nil != err {
+		return
+	}
+
+	ok = true
+	return
+}
+
+func (volume *volumeStruct) PutDedupFingerprints(logSegmentNumber uint64, fingerprints []DedupFingerprintStruct) (err error) {
+	var (
+		dedupFingerprint DedupFingerprintStruct
+		fingerprintKey   uint64
+		fingerprintKeys  []uint64
+		ok               bool
+		refCount         uint64
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.PutDedupFingerprintsUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.PutDedupFingerprintsErrors.Add(1)
+		}
+	}()
+
+	if 0 != (logSegmentNumber & dedupFingerprintKeyFlag) {
+		err = fmt.Errorf("LogSegmentNumber 0x%016X not supported by dedup B+Tree", logSegmentNumber)
+		return
+	}
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	// Only a LogSegment still present in the live view may be referenced by subsequent chunks
+
+	_, ok, err = volume.liveView.logSegmentRecWrapper.bPlusTree.GetByKey(logSegmentNumber)
+	if (nil != err) || !ok {
+		return
+	}
+
+	refCount, fingerprintKeys, ok, err = volume.fetchDedupLogSegmentRecWhileLocked(logSegmentNumber)
+	if nil != err {
+		return
+	}
+	if !ok {
+		refCount = 1
+		fingerprintKeys = make([]uint64, 0, len(fingerprints))
+	}
+
+	for _, dedupFingerprint = range fingerprints {
+		fingerprintKey, err = dedupFingerprintKey(dedupFingerprint.Fingerprint)
+		if nil != err {
+			return
+		}
+
+		ok, err = volume.liveView.dedupWrapper.bPlusTree.Put(fingerprintKey, packDedupFingerprintRec(logSegmentNumber, dedupFingerprint.LogSegmentOffset, dedupFingerprint.Length, dedupFingerprint.Fingerprint))
+		if nil != err {
+			return
+		}
+		if ok {
+			fingerprintKeys = append(fingerprintKeys, fingerprintKey)
+			volume.dedupFingerprints++
+		}
+	}
+
+	err = volume.putDedupLogSegmentRecWhileLocked(logSegmentNumber, refCount, fingerprintKeys)
+
+	return // err set as appropriate
+}
+
+// ReferenceLogSegmentRec records that one more FileInode references the LogSegment identified by
+// logSegmentNumber (e.g. a FileInode restored from a SnapShot referencing a LogSegment still in use
+// by another FileInode). A LogSegment lacking a LogSegment record in the dedup B+Tree is presumed to
+// have been referenced by just the one FileInode until now.
+func (volume *volumeStruct) ReferenceLogSegmentRec(logSegmentNumber uint64) (err error) {
+	var (
+		fingerprintKeys []uint64
+		ok              bool
+		refCount        uint64
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.ReferenceLogSegmentRecUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.ReferenceLogSegmentRecErrors.Add(1)
+		}
+	}()
+
+	if 0 != (logSegmentNumber & dedupFingerprintKeyFlag) {
+		err = fmt.Errorf("LogSegmentNumber 0x%016X not supported by dedup B+Tree", logSegmentNumber)
+		return
+	}
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	_, ok, err = volume.liveView.logSegmentRecWrapper.bPlusTree.GetByKey(logSegmentNumber)
+	if nil != err {
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("Missing logSegmentNumber (0x%016X) in volume %v LogSegmentRec B+Tree", logSegmentNumber, volume.volumeName)
+		return
+	}
+
+	refCount, fingerprintKeys, ok, err = volume.fetchDedupLogSegmentRecWhileLocked(logSegmentNumber)
+	if nil != err {
+		return
+	}
+	if !ok {
+		refCount = 1
+		fingerprintKeys = []uint64{}
+	}
+
+	err = volume.putDedupLogSegmentRecWhileLocked(logSegmentNumber, refCount+1, fingerprintKeys)
+
+	return // err set as appropriate
+}
+
+func (volume *volumeStruct) UnreferenceLogSegmentRec(logSegmentNumber uint64) (err error) {
+	var (
+		fingerprintKeys []uint64
+		ok              bool
+		refCount        uint64
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.UnreferenceLogSegmentRecUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.UnreferenceLogSegmentRecErrors.Add(1)
+		}
+	}()
+
+	volume.Lock()
+
+	refCount, fingerprintKeys, ok, err = volume.fetchDedupLogSegmentRecWhileLocked(logSegmentNumber)
+	if nil != err {
+		volume.Unlock()
+		return
+	}
+
+	if ok {
+		if 1 < refCount {
+			err = volume.putDedupLogSegmentRecWhileLocked(logSegmentNumber, refCount-1, fingerprintKeys)
+			volume.Unlock()
+			return
+		}
+
+		err = volume.deleteDedupLogSegmentRecWhileLocked(logSegmentNumber, fingerprintKeys)
+		if nil != err {
+			volume.Unlock()
+			return
+		}
+	} else if volume.dedupRebuildRequired {
+		// Until reference counts are rebuilt, a LogSegment lacking a LogSegment record
+		// may yet be referenced by another FileInode... so it cannot be deleted
+
+		volume.Unlock()
+		logger.Warnf("Volume %v awaiting dedup reference count rebuild... not deleting LogSegment 0x%016X", volume.volumeName, logSegmentNumber)
+		err = nil
+		return
+	}
+
+	volume.Unlock()
+
+	err = volume.DeleteLogSegmentRec(logSegmentNumber)
+
+	return // err set as appropriate
+}
+
+// FetchLogSegmentRefCount returns the number of FileInodes referencing the LogSegment identified by
+// logSegmentNumber. A LogSegment lacking a LogSegment record in the dedup B+Tree reports a refCount
+// of one. Note that, while FetchDedupStats() reports RebuildRequired, refCount may be stale.
+func (volume *volumeStruct) FetchLogSegmentRefCount(logSegmentNumber uint64) (refCount uint64, err error) {
+	var (
+		ok bool
+	)
+
+	if 0 != (logSegmentNumber & dedupFingerprintKeyFlag) {
+		// Such a LogSegment could never have been tracked by the dedup B+Tree
+
+		refCount = 1
+		err = nil
+		return
+	}
+
+	volume.Lock()
+	refCount, _, ok, err = volume.fetchDedupLogSegmentRecWhileLocked(logSegmentNumber)
+	volume.Unlock()
+
+	if (nil == err) && !ok {
+		refCount = 1
+	}
+
+	return // err set as appropriate
+}
+
+func (volume *volumeStruct) RecordDedupBytes(chunkedBytes uint64, dedupedBytes uint64) {
+	volume.Lock()
+	volume.dedupChunkedBytes += chunkedBytes
+	volume.dedupDedupedBytes += dedupedBytes
+	volume.checkpointTriggeringEvents++
+	volume.Unlock()
+}
+
+func (volume *volumeStruct) FetchDedupStats() (dedupStats DedupStatsStruct) {
+	volume.Lock()
+	dedupStats = DedupStatsStruct{
+		ChunkedBytes:    volume.dedupChunkedBytes,
+		DedupedBytes:    volume.dedupDedupedBytes,
+		Fingerprints:    volume.dedupFingerprints,
+		LogSegments:     volume.dedupLogSegments,
+		RebuildRequired: volume.dedupRebuildRequired,
+	}
+	volume.Unlock()
+	return
+}
+
+func (volume *volumeStruct) RebuildDedupRefCounts(refCounts map[uint64]uint64) (err error) {
+	var (
+		fingerprintKeys  []uint64
+		index            int
+		key              sortedmap.Key
+		logSegmentNumber uint64
+		ok               bool
+		refCount         uint64
+		trackedRefCounts map[uint64]struct{}
+		value            sortedmap.Value
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.RebuildDedupRefCountsUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.RebuildDedupRefCountsErrors.Add(1)
+		}
+	}()
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	// First, update (or remove) existing LogSegment records
+
+	trackedRefCounts = make(map[uint64]struct{})
+
+	index = 0
+
+	for {
+		key, value, ok, err = volume.liveView.dedupWrapper.bPlusTree.GetByIndex(index)
+		if nil != err {
+			return
+		}
+		if !ok {
+			break
+		}
+
+		logSegmentNumber = key.(uint64)
+
+		if 0 != (logSegmentNumber & dedupFingerprintKeyFlag) {
+			// LogSegment records sort before all Fingerprint records
+
+			break
+		}
+
+		_, fingerprintKeys, err = unpackDedupLogSegmentRec(value.([]byte))
+		if nil != err {
+			return
+		}
+
+		refCount = refCounts[logSegmentNumber]
+
+		if 0 == refCount {
+			// No longer referenced by any FileInode (e.g. following a revert)... so the LogSegment
+			// itself (if still present in the live view) may be deleted as well
+
+			err = volume.deleteDedupLogSegmentRecWhileLocked(logSegmentNumber, fingerprintKeys)
+			if nil != err {
+				return
+			}
+
+			_, ok, err = volume.liveView.logSegmentRecWrapper.bPlusTree.GetByKey(logSegmentNumber)
+			if nil != err {
+				return
+			}
+			if ok {
+				err = volume.deleteLogSegmentRecWhileLocked(logSegmentNumber)
+				if nil != err {
+					return
+				}
+			}
+
+			continue // index now identifies the next record
+		}
+
+		err = volume.putDedupLogSegmentRecWhileLocked(logSegmentNumber, refCount, fingerprintKeys)
+		if nil != err {
+			return
+		}
+
+		trackedRefCounts[logSegmentNumber] = struct{}{}
+
+		index++
+	}
+
+	// Next, track any other LogSegments referenced by multiple FileInodes
+
+	for logSegmentNumber, refCount = range refCounts {
+		if 1 >= refCount {
+			continue
+		}
+		_, ok = trackedRefCounts[logSegmentNumber]
+		if ok {
+			continue
+		}
+
+		err = volume.putDedupLogSegmentRecWhileLocked(logSegmentNumber, refCount, []uint64{})
+		if nil != err {
+			return
+		}
+	}
+
+	volume.dedupRebuildRequired = false
+	volume.checkpointTriggeringEvents++
+
+	err = nil
+	return
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/ramswift"
+	"github.com/NVIDIA/proxyfs/swiftclient"
+	"github.com/NVIDIA/proxyfs/transitions"
+)
+
+const testDedupLogSegmentNumber = uint64(4321)
+
+var testDedupFingerprint = []byte("0123456789ABCDEF0123456789ABCDEF")
+
+func dedupFingerprintsPutReference(t *testing.T, volume VolumeHandle) {
+	logsegmentRecPutGet(t, volume, testDedupLogSegmentNumber, []byte("DedupContainer"))
+
+	err := volume.PutDedupFingerprints(testDedupLogSegmentNumber, []DedupFingerprintStruct{{Fingerprint: testDedupFingerprint, LogSegmentOffset: 16, Length: 4096}})
+	if nil != err {
+		t.Fatalf("PutDedupFingerprints() failed: %v", err)
+	}
+
+	logSegmentNumber, logSegmentOffset, length, ok, err := volume.ReferenceDedupChunk(testDedupFingerprint)
+	if (nil != err) || !ok || (testDedupLogSegmentNumber != logSegmentNumber) || (16 != logSegmentOffset) || (4096 != length) {
+		t.Fatalf("ReferenceDedupChunk() returned unexpected logSegmentNumber (0x%016X), logSegmentOffset (%v), length (%v), ok (%v), or err (%v)", logSegmentNumber, logSegmentOffset, length, ok, err)
+	}
+
+	_, _, _, ok, err = volume.ReferenceDedupChunk([]byte("0123456789ABCDEF0123456789ABCDEX"))
+	if (nil != err) || ok {
+		t.Fatalf("ReferenceDedupChunk() of differing fingerprint returned unexpected ok (%v) or err (%v)", ok, err)
+	}
+
+	volume.RecordDedupBytes(8192, 4096)
+}
+
+func dedupFingerprintsVerifyUnreference(t *testing.T, volume VolumeHandle) {
+	dedupStats := volume.FetchDedupStats()
+	if (8192 != dedupStats.ChunkedBytes) || (4096 != dedupStats.DedupedBytes) || (1 != dedupStats.Fingerprints) || (1 != dedupStats.LogSegments) || dedupStats.RebuildRequired {
+		t.Fatalf("FetchDedupStats() returned unexpected %#v", dedupStats)
+	}
+
+	refCount, err := volume.FetchLogSegmentRefCount(testDedupLogSegmentNumber)
+	if (nil != err) || (2 != refCount) {
+		t.Fatalf("FetchLogSegmentRefCount() returned unexpected refCount (%v) or err (%v)", refCount, err)
+	}
+
+	// Two references were taken... so only the second UnreferenceLogSegmentRec() should delete it
+
+	err = volume.UnreferenceLogSegmentRec(testDedupLogSegmentNumber)
+	if nil != err {
+		t.Fatalf("UnreferenceLogSegmentRec() [case 1] failed: %v", err)
+	}
+	_, err = volume.GetLogSegmentRec(testDedupLogSegmentNumber)
+	if nil != err {
+		t.Fatalf("UnreferenceLogSegmentRec() [case 1] should not have deleted LogSegmentRec")
+	}
+
+	err = volume.UnreferenceLogSegmentRec(testDedupLogSegmentNumber)
+	if nil != err {
+		t.Fatalf("UnreferenceLogSegmentRec() [case 2] failed: %v", err)
+	}
+	_, err = volume.GetLogSegmentRec(testDedupLogSegmentNumber)
+	if nil == err {
+		t.Fatalf("UnreferenceLogSegmentRec() [case 2] should have deleted LogSegmentRec")
+	}
+
+	_, _, _, ok, err := volume.ReferenceDedupChunk(testDedupFingerprint)
+	if (nil != err) || ok {
+		t.Fatalf("ReferenceDedupChunk() after LogSegment deletion returned unexpected ok (%v) or err (%v)", ok, err)
+	}
+
+	dedupStats = volume.FetchDedupStats()
+	if (0 != dedupStats.Fingerprints) || (0 != dedupStats.LogSegments) {
+		t.Fatalf("FetchDedupStats() returned unexpected %#v", dedupStats)
+	}
+
+	// A LogSegment lacking a dedup LogSegment record may become shared via ReferenceLogSegmentRec()
+
+	logsegmentRecPutGet(t, volume, testDedupLogSegmentNumber+1, []byte("DedupContainer"))
+
+	refCount, err = volume.FetchLogSegmentRefCount(testDedupLogSegmentNumber + 1)
+	if (nil != err) || (1 != refCount) {
+		t.Fatalf("FetchLogSegmentRefCount() [case 1] returned unexpected refCount (%v) or err (%v)", refCount, err)
+	}
+
+	err = volume.ReferenceLogSegmentRec(testDedupLogSegmentNumber + 1)
+	if nil != err {
+		t.Fatalf("ReferenceLogSegmentRec() failed: %v", err)
+	}
+
+	refCount, err = volume.FetchLogSegmentRefCount(testDedupLogSegmentNumber + 1)
+	if (nil != err) || (2 != refCount) {
+		t.Fatalf("FetchLogSegmentRefCount() [case 2] returned unexpected refCount (%v) or err (%v)", refCount, err)
+	}
+
+	err = volume.ReferenceLogSegmentRec(testDedupLogSegmentNumber + 2)
+	if nil == err {
+		t.Fatalf("ReferenceLogSegmentRec() of missing LogSegment should have failed")
+	}
+
+	// Once found to be referenced by no FileInode, a rebuild should delete the LogSegment itself
+
+	err = volume.RebuildDedupRefCounts(map[uint64]uint64{})
+	if nil != err {
+		t.Fatalf("RebuildDedupRefCounts() failed: %v", err)
+	}
+	_, err = volume.GetLogSegmentRec(testDedupLogSegmentNumber + 1)
+	if nil == err {
+		t.Fatalf("RebuildDedupRefCounts() should have deleted LogSegmentRec")
+	}
+
+	dedupStats = volume.FetchDedupStats()
+	if (0 != dedupStats.Fingerprints) || (0 != dedupStats.LogSegments) || dedupStats.RebuildRequired {
+		t.Fatalf("FetchDedupStats() returned unexpected %#v", dedupStats)
+	}
+}
+
+func TestHeadHunterDedup(t *testing.T) {
+	var (
+		cloneSnapShotID        uint64
+		cloneVolume            VolumeHandle
+		confMap                conf.ConfMap
+		confStrings            []string
+		doneChan               chan bool
+		err                    error
+		signalHandlerIsArmedWG sync.WaitGroup
+		volume                 VolumeHandle
+	)
+
+	confStrings = []string{
+		"Logging.LogFilePath=/dev/null",
+		"Stats.IPAddr=localhost",
+		"Stats.UDPPort=52184",
+		"Stats.BufferLength=100",
+		"Stats.MaxLatency=1s",
+		"SwiftClient.NoAuthIPAddr=127.0.0.1",
+		"SwiftClient.NoAuthTCPPort=9999",
+		"SwiftClient.Timeout=10s",
+		"SwiftClient.RetryLimit=0",
+		"SwiftClient.RetryLimitObject=0",
+		"SwiftClient.RetryDelay=1s",
+		"SwiftClient.RetryDelayObject=1s",
+		"SwiftClient.RetryExpBackoff=1.2",
+		"SwiftClient.RetryExpBackoffObject=2.0",
+		"SwiftClient.ChunkedConnectionPoolSize=64",
+		"SwiftClient.NonChunkedConnectionPoolSize=32",
+		"Cluster.WhoAmI=Peer0",
+		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
+		"Volume:TestVolume.PrimaryPeer=Peer0",
+		"Volume:TestVolume.AccountName=TestAccount",
+		"Volume:TestVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:TestVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:TestVolume.MaxFlushSize=10000000",
+		"Volume:TestVolume.NonceValuesToReserve=100",
+		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
+		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"Volume:CloneVolume.PrimaryPeer=Peer0",
+		"Volume:CloneVolume.AccountName=CloneAccount",
+		"Volume:CloneVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:CloneVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:CloneVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:CloneVolume.MaxFlushSize=10000000",
+		"Volume:CloneVolume.NonceValuesToReserve=100",
+		"Volume:CloneVolume.MaxInodesPerMetadataNode=32",
+		"Volume:CloneVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:CloneVolume.MaxDirFileNodesPerMetadataNode=16",
+		"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume",
+		"VolumeGroup:TestVolumeGroup.VirtualIPAddr=",
+		"VolumeGroup:TestVolumeGroup.PrimaryPeer=Peer0",
+		"FSGlobals.VolumeGroupList=TestVolumeGroup",
+		"FSGlobals.CheckpointHeaderConsensusAttempts=5",
+		"FSGlobals.MountRetryLimit=6",
+		"FSGlobals.MountRetryDelay=1s",
+		"FSGlobals.MountRetryExpBackoff=2",
+		"FSGlobals.LogCheckpointHeaderPosts=true",
+		"FSGlobals.TryLockBackoffMin=10ms",
+		"FSGlobals.TryLockBackoffMax=50ms",
+		"FSGlobals.TryLockSerializationThreshhold=5",
+		"FSGlobals.SymlinkMax=32",
+		"FSGlobals.CoalesceElementChunkSize=16",
+		"FSGlobals.InodeRecCacheEvictLowLimit=10000",
+		"FSGlobals.InodeRecCacheEvictHighLimit=10010",
+		"FSGlobals.LogSegmentRecCacheEvictLowLimit=10000",
+		"FSGlobals.LogSegmentRecCacheEvictHighLimit=10010",
+		"FSGlobals.BPlusTreeObjectCacheEvictLowLimit=10000",
+		"FSGlobals.BPlusTreeObjectCacheEvictHighLimit=10010",
+		"FSGlobals.EtcdEnabled=false",
+		"RamSwiftInfo.MaxAccountNameLength=256",
+		"RamSwiftInfo.MaxContainerNameLength=256",
+		"RamSwiftInfo.MaxObjectNameLength=1024",
+		"RamSwiftInfo.AccountListingLimit=10000",
+		"RamSwiftInfo.ContainerListingLimit=10000",
+	}
+
+	// Launch a ramswift instance
+
+	signalHandlerIsArmedWG.Add(1)
+	doneChan = make(chan bool, 1) // Must be buffered to avoid race
+
+	go ramswift.Daemon("/dev/null", confStrings, &signalHandlerIsArmedWG, doneChan, unix.SIGTERM)
+
+	signalHandlerIsArmedWG.Wait()
+
+	confMap, err = conf.MakeConfMapFromStrings(confStrings)
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings(confStrings) returned error: %v", err)
+	}
+
+	// Schedule a Format of TestVolume on first Up()
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=true")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=true\") returned error: %v", err)
+	}
+
+	// Up packages (TestVolume will be formatted)
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 1] returned error: %v", err)
+	}
+
+	// Unset AutoFormat for all subsequent uses of ConfMap
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=false")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=false\") returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 1] returned error: %v", err)
+	}
+
+	dedupFingerprintsPutReference(t, volume)
+
+	// Clone a SnapShot of TestVolume (including its dedup B+Tree) as CloneVolume
+
+	cloneSnapShotID, err = volume.SnapShotCreateByInodeLayer("CloneMe")
+	if nil != err {
+		t.Fatalf("SnapShotCreateByInodeLayer() returned error: %v", err)
+	}
+
+	err = swiftclient.AccountPut("CloneAccount", make(map[string][]string))
+	if nil != err {
+		t.Fatalf("swiftclient.AccountPut(\"CloneAccount\") returned error: %v", err)
+	}
+
+	err = volume.SnapShotCloneByInodeLayer(cloneSnapShotID, "CloneAccount", ".__checkpoint__", "gold")
+	if nil != err {
+		t.Fatalf("SnapShotCloneByInodeLayer() returned error: %v", err)
+	}
+
+	// Serve CloneVolume alongside TestVolume
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 1] returned error: %v", err)
+	}
+
+	err = confMap.UpdateFromString("VolumeGroup:TestVolumeGroup.VolumeList=TestVolume,CloneVolume")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume,CloneVolume\") returned error: %v", err)
+	}
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 2] returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 2] returned error: %v", err)
+	}
+	cloneVolume, err = FetchVolumeHandle("CloneVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"CloneVolume\") returned error: %v", err)
+	}
+
+	// Dedup B+Tree should have persisted for TestVolume while CloneVolume must rebuild its reference counts
+
+	dedupFingerprintsVerifyUnreference(t, volume)
+
+	if !cloneVolume.FetchDedupStats().RebuildRequired {
+		t.Fatalf("cloneVolume.FetchDedupStats() should have reported RebuildRequired")
+	}
+
+	err = cloneVolume.RebuildDedupRefCounts(map[uint64]uint64{})
+	if (nil != err) || cloneVolume.FetchDedupStats().RebuildRequired {
+		t.Fatalf("cloneVolume.RebuildDedupRefCounts() failed: %v", err)
+	}
+
+	// Release CloneVolume so that the SnapShot may be deleted
+
+	err = ReleaseClone("CloneAccount", ".__checkpoint__")
+	if nil != err {
+		t.Fatalf("ReleaseClone() returned error: %v", err)
+	}
+
+	err = volume.SnapShotDeleteByInodeLayer(cloneSnapShotID)
+	if nil != err {
+		t.Fatalf("SnapShotDeleteByInodeLayer() of released SnapShot returned error: %v", err)
+	}
+
+	// Shutdown packages
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 2] returned error: %v", err)
+	}
+
+	// Send ourself a SIGTERM to terminate ramswift.Daemon()
+
+	unix.Kill(unix.Getpid(), unix.SIGTERM)
+
+	_ = <-doneChan
+}
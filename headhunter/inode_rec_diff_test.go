@@ -0,0 +1,248 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/ramswift"
+	"github.com/NVIDIA/proxyfs/transitions"
+)
+
+func TestHeadHunterInodeRecDiff(t *testing.T) {
+	var (
+		confMap                conf.ConfMap
+		confStrings            []string
+		diffList               []InodeRecDiffStruct
+		doneChan               chan bool
+		err                    error
+		expectedDiffList       []InodeRecDiffStruct
+		key                    uint64
+		lastInodeNumber        uint64
+		moreEntries            bool
+		pagedDiffList          []InodeRecDiffStruct
+		signalHandlerIsArmedWG sync.WaitGroup
+		snapShotID             uint64
+		volume                 VolumeHandle
+	)
+
+	confStrings = []string{
+		"Logging.LogFilePath=/dev/null",
+		"Stats.IPAddr=localhost",
+		"Stats.UDPPort=52184",
+		"Stats.BufferLength=100",
+		"Stats.MaxLatency=1s",
+		"SwiftClient.NoAuthIPAddr=127.0.0.1",
+		"SwiftClient.NoAuthTCPPort=9999",
+		"SwiftClient.Timeout=10s",
+		"SwiftClient.RetryLimit=0",
+		"SwiftClient.RetryLimitObject=0",
+		"SwiftClient.RetryDelay=1s",
+		"SwiftClient.RetryDelayObject=1s",
+		"SwiftClient.RetryExpBackoff=1.2",
+		"SwiftClient.RetryExpBackoffObject=2.0",
+		"SwiftClient.ChunkedConnectionPoolSize=64",
+		"SwiftClient.NonChunkedConnectionPoolSize=32",
+		"Cluster.WhoAmI=Peer0",
+		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
+		"Volume:TestVolume.AccountName=TestAccount",
+		"Volume:TestVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:TestVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:TestVolume.MaxFlushSize=10000000",
+		"Volume:TestVolume.NonceValuesToReserve=100",
+		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
+		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume",
+		"VolumeGroup:TestVolumeGroup.VirtualIPAddr=",
+		"VolumeGroup:TestVolumeGroup.PrimaryPeer=Peer0",
+		"FSGlobals.VolumeGroupList=TestVolumeGroup",
+		"FSGlobals.CheckpointHeaderConsensusAttempts=5",
+		"FSGlobals.MountRetryLimit=6",
+		"FSGlobals.MountRetryDelay=1s",
+		"FSGlobals.MountRetryExpBackoff=2",
+		"FSGlobals.LogCheckpointHeaderPosts=true",
+		"FSGlobals.TryLockBackoffMin=10ms",
+		"FSGlobals.TryLockBackoffMax=50ms",
+		"FSGlobals.TryLockSerializationThreshhold=5",
+		"FSGlobals.SymlinkMax=32",
+		"FSGlobals.CoalesceElementChunkSize=16",
+		"FSGlobals.InodeRecCacheEvictLowLimit=10000",
+		"FSGlobals.InodeRecCacheEvictHighLimit=10010",
+		"FSGlobals.LogSegmentRecCacheEvictLowLimit=10000",
+		"FSGlobals.LogSegmentRecCacheEvictHighLimit=10010",
+		"FSGlobals.BPlusTreeObjectCacheEvictLowLimit=10000",
+		"FSGlobals.BPlusTreeObjectCacheEvictHighLimit=10010",
+		"FSGlobals.EtcdEnabled=false",
+		"RamSwiftInfo.MaxAccountNameLength=256",
+		"RamSwiftInfo.MaxContainerNameLength=256",
+		"RamSwiftInfo.MaxObjectNameLength=1024",
+		"RamSwiftInfo.AccountListingLimit=10000",
+		"RamSwiftInfo.ContainerListingLimit=10000",
+	}
+
+	// Launch a ramswift instance
+
+	signalHandlerIsArmedWG.Add(1)
+	doneChan = make(chan bool, 1) // Must be buffered to avoid race
+
+	go ramswift.Daemon("/dev/null", confStrings, &signalHandlerIsArmedWG, doneChan, unix.SIGTERM)
+
+	signalHandlerIsArmedWG.Wait()
+
+	confMap, err = conf.MakeConfMapFromStrings(confStrings)
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings(confStrings) returned error: %v", err)
+	}
+
+	// Schedule a Format of TestVolume on first Up()
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=true")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=true\") returned error: %v", err)
+	}
+
+	// Up packages (TestVolume will be formatted)
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 1] returned error: %v", err)
+	}
+
+	// Unset AutoFormat for all subsequent uses of ConfMap
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=false")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=false\") returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") returned error: %v", err)
+	}
+
+	// Compare enough InodeRecs to span several batches (each holding the volume lock)
+
+	for key = 1000; key < 1000+3*inodeRecDiffBatchSize; key++ {
+		err = volume.PutInodeRec(key, []byte("A"))
+		if nil != err {
+			t.Fatalf("PutInodeRec(%d) returned error: %v", key, err)
+		}
+	}
+
+	snapShotID, err = volume.SnapShotCreateByInodeLayer("InodeRecDiff")
+	if nil != err {
+		t.Fatalf("SnapShotCreateByInodeLayer(\"InodeRecDiff\") returned error: %v", err)
+	}
+
+	expectedDiffList = make([]InodeRecDiffStruct, 0)
+
+	for key = 1000; key < 1000+3*inodeRecDiffBatchSize; key++ {
+		switch key % 3 {
+		case 0:
+			err = volume.PutInodeRec(key, []byte("B"))
+			if nil != err {
+				t.Fatalf("PutInodeRec(%d) returned error: %v", key, err)
+			}
+			expectedDiffList = append(expectedDiffList, InodeRecDiffStruct{InodeNumber: key, DiffType: InodeRecDiffModified})
+		case 1:
+			err = volume.DeleteInodeRec(key)
+			if nil != err {
+				t.Fatalf("DeleteInodeRec(%d) returned error: %v", key, err)
+			}
+			expectedDiffList = append(expectedDiffList, InodeRecDiffStruct{InodeNumber: key, DiffType: InodeRecDiffDeleted})
+		}
+	}
+	for ; key < 1000+4*inodeRecDiffBatchSize; key++ {
+		err = volume.PutInodeRec(key, []byte("C"))
+		if nil != err {
+			t.Fatalf("PutInodeRec(%d) returned error: %v", key, err)
+		}
+		expectedDiffList = append(expectedDiffList, InodeRecDiffStruct{InodeNumber: key, DiffType: InodeRecDiffCreated})
+	}
+
+	diffList, moreEntries, err = volume.FetchInodeRecDiff(snapShotID, 0, 0, 0)
+	if nil != err {
+		t.Fatalf("FetchInodeRecDiff() [unbounded] returned error: %v", err)
+	}
+	if moreEntries {
+		t.Fatalf("FetchInodeRecDiff() [unbounded] returned moreEntries == true")
+	}
+	inodeRecDiffListCompare(t, diffList, expectedDiffList)
+
+	// Fetching a page at a time should return the same list
+
+	pagedDiffList = make([]InodeRecDiffStruct, 0)
+	lastInodeNumber = 0
+	moreEntries = true
+
+	for moreEntries {
+		diffList, moreEntries, err = volume.FetchInodeRecDiff(snapShotID, 0, lastInodeNumber, 100)
+		if nil != err {
+			t.Fatalf("FetchInodeRecDiff() [paged] returned error: %v", err)
+		}
+		if 100 < len(diffList) {
+			t.Fatalf("FetchInodeRecDiff() [paged] returned %d entries (maxEntries == 100)", len(diffList))
+		}
+		if 0 < len(diffList) {
+			lastInodeNumber = diffList[len(diffList)-1].InodeNumber
+		}
+		pagedDiffList = append(pagedDiffList, diffList...)
+	}
+	inodeRecDiffListCompare(t, pagedDiffList, expectedDiffList)
+
+	// A SnapShot being diffed may not be deleted
+
+	volume.(*volumeStruct).Lock()
+	volume.(*volumeStruct).diffingSnapShotIDs[snapShotID]++
+	volume.(*volumeStruct).Unlock()
+
+	err = volume.SnapShotDeleteByInodeLayer(snapShotID)
+	if nil == err {
+		t.Fatalf("SnapShotDeleteByInodeLayer() of a SnapShot being diffed should have failed")
+	}
+
+	volume.(*volumeStruct).Lock()
+	volume.(*volumeStruct).unpinDiffingSnapShotIDWhileLocked(snapShotID)
+	volume.(*volumeStruct).Unlock()
+
+	err = volume.SnapShotDeleteByInodeLayer(snapShotID)
+	if nil != err {
+		t.Fatalf("SnapShotDeleteByInodeLayer() returned error: %v", err)
+	}
+
+	// Shutdown packages
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() returned error: %v", err)
+	}
+
+	// Send ourself a SIGTERM to terminate ramswift.Daemon()
+
+	unix.Kill(unix.Getpid(), unix.SIGTERM)
+
+	_ = <-doneChan
+}
+
+func inodeRecDiffListCompare(t *testing.T, diffList []InodeRecDiffStruct, expectedDiffList []InodeRecDiffStruct) {
+	var (
+		diffListIndex int
+	)
+
+	if len(expectedDiffList) != len(diffList) {
+		t.Fatalf("FetchInodeRecDiff() returned %d entries (expected %d)", len(diffList), len(expectedDiffList))
+	}
+
+	for diffListIndex = range diffList {
+		if expectedDiffList[diffListIndex] != diffList[diffListIndex] {
+			t.Fatalf("FetchInodeRecDiff() returned %+v at index %d (expected %+v)", diffList[diffListIndex], diffListIndex, expectedDiffList[diffListIndex])
+		}
+	}
+}
@@ -0,0 +1,737 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	etcd "go.etcd.io/etcd/clientv3"
+
+	"github.com/NVIDIA/cstruct"
+
+	"github.com/NVIDIA/proxyfs/logger"
+	"github.com/NVIDIA/proxyfs/platform"
+	"github.com/NVIDIA/proxyfs/retryrpc"
+	"github.com/NVIDIA/proxyfs/trackedlock"
+)
+
+// A Replay Log holds every transaction recorded by recordTransaction() since the last
+// successful checkpoint so that getCheckpoint() may bring the volume fully up to date.
+// Where the Replay Log is kept is determined by [Volume:<name>]ReplayLogBackend:
+//
+//   file - a local file at ReplayLogFileName written with O_DIRECT|O_SYNC (the default)
+//   etcd - one key per transaction named ReplayLogEtcdKeyName:<sequence number>
+//   peer - sent via retryrpc to the replay log peer server of ReplayLogPeer and,
+//          if ReplayLogFileName is specified, also kept in that local file
+//
+// Only the latter two survive the loss of the peer serving the volume. Replay log peers
+// mutually authenticate via TLS certificates signed by [FSGlobals]ReplayLogPeerCAFilePath
+// and only hold Replay Logs for configured volumes.
+//
+// A failed local file write remains fatal. A replay log peer that cannot be reached is
+// not: a warning is logged and the peer is skipped until the next successful checkpoint
+// manages to remove the (now incomplete) Replay Log it holds. Each transaction records the
+// checkpoint it follows, so a Replay Log left behind by a since-superseded checkpoint is
+// never replayed.
+
+const (
+	replayLogBackendEtcd = "etcd"
+	replayLogBackendFile = "file"
+	replayLogBackendPeer = "peer"
+)
+
+const (
+	replayLogPeerDeadlineIO      = 60 * time.Second
+	replayLogPeerKeepAlivePeriod = 60 * time.Second
+	replayLogPeerLongTrim        = 10 * time.Minute
+	replayLogPeerShortTrim       = 100 * time.Millisecond
+)
+
+type replayLogBackend interface {
+	append(transaction []byte) (err error)     // len(transaction) is a multiple of replayLogWriteBufferAlignment
+	fetch() (replayLog []byte, err error)      // replayLog is empty if no Replay Log was found
+	truncate(replayLogSize uint64) (err error) // discards everything following the first replayLogSize bytes
+	remove() (err error)                       // discards the entire Replay Log following a successful checkpoint
+	close()
+}
+
+type replayLogFileStruct struct {
+	fileName string
+	file     *os.File // opened on first append() after checkpoint or by fetch()
+}
+
+type replayLogEtcdTransactionStruct struct {
+	key    string
+	offset uint64
+}
+
+type replayLogEtcdStruct struct {
+	keyName         string
+	transactionList []replayLogEtcdTransactionStruct
+	replayLogSize   uint64
+	nextSequence    uint64
+}
+
+type replayLogPeerStruct struct {
+	volume         *volumeStruct
+	peerName       string
+	local          *replayLogFileStruct // nil if no local copy is kept
+	retryRPCClient *retryrpc.Client
+	peerLost       bool // if true, the peer's copy is incomplete until the next successful remove()
+}
+
+// ReplayLogPeerServer is registered with retryrpc to hold the Replay Logs of volumes served by
+// other peers whose ReplayLogPeer names this peer.
+type ReplayLogPeerServer struct {
+	trackedlock.Mutex
+	directory      string
+	retryRPCServer *retryrpc.Server
+}
+
+// ReplayLogAppendRequest is the request object for RpcReplayLogAppend.
+type ReplayLogAppendRequest struct {
+	VolumeName  string
+	Transaction []byte
+}
+
+// ReplayLogFetchRequest is the request object for RpcReplayLogFetch.
+type ReplayLogFetchRequest struct {
+	VolumeName string
+}
+
+// ReplayLogFetchReply is the reply object for RpcReplayLogFetch.
+type ReplayLogFetchReply struct {
+	ReplayLog []byte
+}
+
+// ReplayLogTruncateRequest is the request object for RpcReplayLogTruncate.
+type ReplayLogTruncateRequest struct {
+	VolumeName    string
+	ReplayLogSize uint64
+}
+
+// ReplayLogRemoveRequest is the request object for RpcReplayLogRemove.
+type ReplayLogRemoveRequest struct {
+	VolumeName string
+}
+
+// ReplayLogReply is the reply object for those replay log peer server RPCs returning nothing.
+type ReplayLogReply struct{}
+
+func (replayLogFile *replayLogFileStruct) append(transaction []byte) (err error) {
+	if nil == replayLogFile.file {
+		// Either fetch() found no Replay Log or a successful checkpoint has removed it
+
+		replayLogFile.file, err = platform.OpenFileSync(replayLogFile.fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if nil != err {
+			return
+		}
+	}
+
+	_, err = replayLogFile.file.Write(transaction)
+
+	return
+}
+
+func (replayLogFile *replayLogFileStruct) fetch() (replayLog []byte, err error) {
+	var (
+		replayLogSize int64
+	)
+
+	replayLogFile.close()
+
+	replayLogFile.file, err = platform.OpenFileSync(replayLogFile.fileName, os.O_RDWR, 0600)
+	if nil != err {
+		replayLogFile.file = nil
+		if os.IsNotExist(err) {
+			replayLog = make([]byte, 0)
+			err = nil
+		}
+		return
+	}
+
+	// Compute current end of Replay Log and round it down to replayLogWriteBufferAlignment multiple if necessary
+
+	replayLogSize, err = replayLogFile.file.Seek(0, 2)
+	if nil != err {
+		return
+	}
+	replayLogSize = int64(uintptr(replayLogSize) & ^(replayLogWriteBufferAlignment - 1))
+
+	// Read it all leaving replayLogFile.file positioned for the next append()
+
+	_, err = replayLogFile.file.Seek(0, 0)
+	if nil != err {
+		return
+	}
+
+	replayLog = constructReplayLogWriteBuffer(uint64(replayLogSize))
+
+	_, err = io.ReadFull(replayLogFile.file, replayLog)
+
+	return
+}
+
+func (replayLogFile *replayLogFileStruct) truncate(replayLogSize uint64) (err error) {
+	if nil == replayLogFile.file {
+		err = nil
+		return
+	}
+
+	_, err = replayLogFile.file.Seek(int64(replayLogSize), 0)
+	if nil != err {
+		return
+	}
+
+	err = replayLogFile.file.Truncate(int64(replayLogSize))
+
+	return
+}
+
+func (replayLogFile *replayLogFileStruct) remove() (err error) {
+	if nil != replayLogFile.file {
+		err = replayLogFile.file.Close()
+		if nil != err {
+			return
+		}
+		replayLogFile.file = nil
+	}
+
+	err = os.Remove(replayLogFile.fileName)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+
+	return
+}
+
+func (replayLogFile *replayLogFileStruct) close() {
+	if nil != replayLogFile.file {
+		_ = replayLogFile.file.Close()
+		replayLogFile.file = nil
+	}
+}
+
+func (replayLogEtcd *replayLogEtcdStruct) transactionKey(sequence uint64) (key string) {
+	key = fmt.Sprintf("%s:%016X", replayLogEtcd.keyName, sequence)
+	return
+}
+
+func (replayLogEtcd *replayLogEtcdStruct) append(transaction []byte) (err error) {
+	var (
+		cancel context.CancelFunc
+		ctx    context.Context
+		key    string
+	)
+
+	key = replayLogEtcd.transactionKey(replayLogEtcd.nextSequence)
+
+	ctx, cancel = context.WithTimeout(context.Background(), globals.etcdOpTimeout)
+	_, err = globals.etcdKV.Put(ctx, key, string(transaction))
+	cancel()
+	if nil != err {
+		return
+	}
+
+	replayLogEtcd.transactionList = append(replayLogEtcd.transactionList, replayLogEtcdTransactionStruct{key: key, offset: replayLogEtcd.replayLogSize})
+	replayLogEtcd.replayLogSize += uint64(len(transaction))
+	replayLogEtcd.nextSequence++
+
+	return
+}
+
+func (replayLogEtcd *replayLogEtcdStruct) fetch() (replayLog []byte, err error) {
+	var (
+		cancel      context.CancelFunc
+		ctx         context.Context
+		getResponse *etcd.GetResponse
+		keyPrefix   string
+		sequence    uint64
+	)
+
+	keyPrefix = replayLogEtcd.keyName + ":"
+
+	ctx, cancel = context.WithTimeout(context.Background(), globals.etcdOpTimeout)
+	getResponse, err = globals.etcdKV.Get(ctx, keyPrefix, etcd.WithPrefix(), etcd.WithSort(etcd.SortByKey, etcd.SortAscend))
+	cancel()
+	if nil != err {
+		return
+	}
+
+	replayLog = make([]byte, 0)
+
+	replayLogEtcd.transactionList = make([]replayLogEtcdTransactionStruct, 0, len(getResponse.Kvs))
+	replayLogEtcd.replayLogSize = 0
+	replayLogEtcd.nextSequence = 0
+
+	for _, kv := range getResponse.Kvs {
+		sequence, err = strconv.ParseUint(strings.TrimPrefix(string(kv.Key), keyPrefix), 16, 64)
+		if nil != err {
+			err = fmt.Errorf("Replay Log key %s malformed: %v", string(kv.Key), err)
+			return
+		}
+
+		replayLogEtcd.transactionList = append(replayLogEtcd.transactionList, replayLogEtcdTransactionStruct{key: string(kv.Key), offset: replayLogEtcd.replayLogSize})
+		replayLogEtcd.replayLogSize += uint64(len(kv.Value))
+		replayLogEtcd.nextSequence = sequence + 1
+
+		replayLog = append(replayLog, kv.Value...)
+	}
+
+	return
+}
+
+func (replayLogEtcd *replayLogEtcdStruct) truncate(replayLogSize uint64) (err error) {
+	var (
+		cancel           context.CancelFunc
+		ctx              context.Context
+		transactionIndex int
+	)
+
+	for transactionIndex = 0; transactionIndex < len(replayLogEtcd.transactionList); transactionIndex++ {
+		if replayLogEtcd.transactionList[transactionIndex].offset >= replayLogSize {
+			break
+		}
+	}
+
+	if transactionIndex == len(replayLogEtcd.transactionList) {
+		err = nil
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), globals.etcdOpTimeout)
+	_, err = globals.etcdKV.Delete(ctx, replayLogEtcd.transactionList[transactionIndex].key, etcd.WithRange(etcd.GetPrefixRangeEnd(replayLogEtcd.keyName+":")))
+	cancel()
+	if nil != err {
+		return
+	}
+
+	replayLogEtcd.replayLogSize = replayLogEtcd.transactionList[transactionIndex].offset
+	replayLogEtcd.transactionList = replayLogEtcd.transactionList[:transactionIndex]
+
+	return
+}
+
+func (replayLogEtcd *replayLogEtcdStruct) remove() (err error) {
+	var (
+		cancel context.CancelFunc
+		ctx    context.Context
+	)
+
+	ctx, cancel = context.WithTimeout(context.Background(), globals.etcdOpTimeout)
+	_, err = globals.etcdKV.Delete(ctx, replayLogEtcd.keyName+":", etcd.WithPrefix())
+	cancel()
+	if nil != err {
+		return
+	}
+
+	// Sequence numbers continue to ascend such that keys are never reused
+
+	replayLogEtcd.transactionList = make([]replayLogEtcdTransactionStruct, 0)
+	replayLogEtcd.replayLogSize = 0
+
+	return
+}
+
+func (replayLogEtcd *replayLogEtcdStruct) close() {}
+
+func (replayLogPeer *replayLogPeerStruct) Interrupt(payload []byte) {}
+
+func (replayLogPeer *replayLogPeerStruct) append(transaction []byte) (err error) {
+	if nil != replayLogPeer.local {
+		err = replayLogPeer.local.append(transaction)
+		if nil != err {
+			return
+		}
+	}
+
+	if !replayLogPeer.peerLost {
+		err = replayLogPeer.retryRPCClient.Send("RpcReplayLogAppend", &ReplayLogAppendRequest{VolumeName: replayLogPeer.volume.volumeName, Transaction: transaction}, &ReplayLogReply{})
+		if nil != err {
+			replayLogPeer.lost("RpcReplayLogAppend", err)
+		}
+	}
+
+	err = nil
+	return
+}
+
+// lost records that the peer's copy of the Replay Log can no longer be trusted to be complete.
+func (replayLogPeer *replayLogPeerStruct) lost(rpcName string, rpcErr error) {
+	if nil == replayLogPeer.local {
+		logger.Warnf("Replay Log for Volume %s lost (%s to peer %s failed: %v)...RPO reverts to last checkpoint until next checkpoint", replayLogPeer.volume.volumeName, rpcName, replayLogPeer.peerName, rpcErr)
+	} else {
+		logger.Warnf("Replay Log for Volume %s kept only locally (%s to peer %s failed: %v) until next checkpoint", replayLogPeer.volume.volumeName, rpcName, replayLogPeer.peerName, rpcErr)
+	}
+
+	replayLogPeer.peerLost = true
+}
+
+// fetch returns the longer of the Replay Log held by the peer and the local copy (if any)
+// after bringing the other one into agreement with it. Either is disregarded if it was left
+// behind by a since-superseded checkpoint. The two will otherwise only differ if we failed
+// over from a different peer (leaving no local copy), the peer was unreachable, or we
+// crashed between updates. If the peer cannot be reached, the local copy (if any) is used.
+func (replayLogPeer *replayLogPeerStruct) fetch() (replayLog []byte, err error) {
+	var (
+		localIsStale   bool
+		localReplayLog []byte
+		peerIsStale    bool
+		reply          *ReplayLogFetchReply
+	)
+
+	replayLogPeer.peerLost = false
+
+	reply = &ReplayLogFetchReply{}
+
+	err = replayLogPeer.retryRPCClient.Send("RpcReplayLogFetch", &ReplayLogFetchRequest{VolumeName: replayLogPeer.volume.volumeName}, reply)
+	if nil != err {
+		if nil == replayLogPeer.local {
+			return
+		}
+		replayLogPeer.lost("RpcReplayLogFetch", err)
+		reply.ReplayLog = nil
+	}
+
+	replayLog = reply.ReplayLog
+	if nil == replayLog {
+		replayLog = make([]byte, 0)
+	}
+
+	peerIsStale = (0 < len(replayLog)) && !replayLogPeer.volume.replayLogIsCurrent(replayLog)
+	if peerIsStale {
+		logger.Infof("Replay Log for Volume %s on peer %s precedes last checkpoint...discarding it", replayLogPeer.volume.volumeName, replayLogPeer.peerName)
+		replayLog = make([]byte, 0)
+	}
+
+	if nil != replayLogPeer.local {
+		localReplayLog, err = replayLogPeer.local.fetch()
+		if nil != err {
+			return
+		}
+
+		localIsStale = (0 < len(localReplayLog)) && !replayLogPeer.volume.replayLogIsCurrent(localReplayLog)
+		if localIsStale {
+			logger.Infof("Replay Log for Volume %s local copy precedes last checkpoint...discarding it", replayLogPeer.volume.volumeName)
+			localReplayLog = make([]byte, 0)
+		}
+
+		if len(localReplayLog) > len(replayLog) {
+			if !replayLogPeer.peerLost {
+				logger.Warnf("Replay Log for Volume %s on peer %s is behind local copy (%v < %v bytes)", replayLogPeer.volume.volumeName, replayLogPeer.peerName, len(replayLog), len(localReplayLog))
+
+				err = replayLogPeer.retryRPCClient.Send("RpcReplayLogRemove", &ReplayLogRemoveRequest{VolumeName: replayLogPeer.volume.volumeName}, &ReplayLogReply{})
+				if nil == err {
+					err = replayLogPeer.retryRPCClient.Send("RpcReplayLogAppend", &ReplayLogAppendRequest{VolumeName: replayLogPeer.volume.volumeName, Transaction: localReplayLog}, &ReplayLogReply{})
+					if nil != err {
+						replayLogPeer.lost("RpcReplayLogAppend", err)
+					}
+				} else {
+					replayLogPeer.lost("RpcReplayLogRemove", err)
+				}
+			}
+
+			replayLog = localReplayLog
+		} else if len(localReplayLog) < len(replayLog) {
+			logger.Warnf("Replay Log for Volume %s local copy is behind peer %s (%v < %v bytes)", replayLogPeer.volume.volumeName, replayLogPeer.peerName, len(localReplayLog), len(replayLog))
+
+			err = replayLogPeer.local.remove()
+			if nil != err {
+				return
+			}
+
+			// O_DIRECT requires an aligned buffer
+
+			localReplayLog = constructReplayLogWriteBuffer(uint64(len(replayLog)))
+			_ = copy(localReplayLog, replayLog)
+
+			err = replayLogPeer.local.append(localReplayLog)
+			if nil != err {
+				return
+			}
+		} else if localIsStale {
+			err = replayLogPeer.local.remove()
+			if nil != err {
+				return
+			}
+		}
+	}
+
+	if peerIsStale && (0 == len(replayLog)) && !replayLogPeer.peerLost {
+		err = replayLogPeer.retryRPCClient.Send("RpcReplayLogRemove", &ReplayLogRemoveRequest{VolumeName: replayLogPeer.volume.volumeName}, &ReplayLogReply{})
+		if nil != err {
+			replayLogPeer.lost("RpcReplayLogRemove", err)
+		}
+	}
+
+	err = nil
+	return
+}
+
+func (replayLogPeer *replayLogPeerStruct) truncate(replayLogSize uint64) (err error) {
+	if nil != replayLogPeer.local {
+		err = replayLogPeer.local.truncate(replayLogSize)
+		if nil != err {
+			return
+		}
+	}
+
+	if !replayLogPeer.peerLost {
+		err = replayLogPeer.retryRPCClient.Send("RpcReplayLogTruncate", &ReplayLogTruncateRequest{VolumeName: replayLogPeer.volume.volumeName, ReplayLogSize: replayLogSize}, &ReplayLogReply{})
+		if nil != err {
+			replayLogPeer.lost("RpcReplayLogTruncate", err)
+		}
+	}
+
+	err = nil
+	return
+}
+
+func (replayLogPeer *replayLogPeerStruct) remove() (err error) {
+	if nil != replayLogPeer.local {
+		err = replayLogPeer.local.remove()
+		if nil != err {
+			return
+		}
+	}
+
+	// Even if the peer was lost, a successful removal makes its (empty) copy complete again
+
+	err = replayLogPeer.retryRPCClient.Send("RpcReplayLogRemove", &ReplayLogRemoveRequest{VolumeName: replayLogPeer.volume.volumeName}, &ReplayLogReply{})
+	if nil == err {
+		if replayLogPeer.peerLost {
+			logger.Infof("Replay Log for Volume %s once again sent to peer %s", replayLogPeer.volume.volumeName, replayLogPeer.peerName)
+			replayLogPeer.peerLost = false
+		}
+	} else {
+		replayLogPeer.lost("RpcReplayLogRemove", err)
+	}
+
+	err = nil
+	return
+}
+
+func (replayLogPeer *replayLogPeerStruct) close() {
+	if nil != replayLogPeer.local {
+		replayLogPeer.local.close()
+	}
+
+	replayLogPeer.retryRPCClient.Close()
+}
+
+// replayLogIsCurrent returns whether or not replayLog begins with a transaction recorded
+// following the checkpoint from which the volume was just loaded.
+func (volume *volumeStruct) replayLogIsCurrent(replayLog []byte) (isCurrent bool) {
+	var (
+		err                           error
+		replayLogTransactionFixedPart replayLogTransactionFixedPartStruct
+	)
+
+	if uint64(len(replayLog)) < globals.replayLogTransactionFixedPartStructSize {
+		isCurrent = false
+		return
+	}
+
+	_, err = cstruct.Unpack(replayLog[:globals.replayLogTransactionFixedPartStructSize], &replayLogTransactionFixedPart, LittleEndian)
+	if nil != err {
+		logger.Fatalf("Replay Log for Volume %s hit unexpected cstruct.Unpack() failure: %v", volume.volumeName, err)
+	}
+
+	isCurrent = (replayLogTransactionFixedPart.LastCheckpointObjectTrailerStructObjectNumber == volume.checkpointHeader.CheckpointObjectTrailerStructObjectNumber)
+
+	return
+}
+
+// replayLogFileName returns the path of the Replay Log held for volumeName. As volumeName is
+// supplied by the (authenticated) client, it must name a configured volume and, hence, is not
+// able to escape server.directory.
+func (server *ReplayLogPeerServer) replayLogFileName(volumeName string) (fileName string, err error) {
+	var (
+		ok bool
+	)
+
+	if ("" == volumeName) || ("." == volumeName) || (".." == volumeName) || strings.ContainsAny(volumeName, "/\\\x00") {
+		err = fmt.Errorf("Replay Log VolumeName \"%s\" invalid", volumeName)
+		return
+	}
+
+	globals.Lock()
+	_, ok = globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("Replay Log VolumeName \"%s\" not a configured volume", volumeName)
+		return
+	}
+
+	fileName = filepath.Join(server.directory, volumeName+".rlog")
+	err = nil
+
+	return
+}
+
+// RpcReplayLogAppend appends a transaction to the Replay Log held for the volume.
+func (server *ReplayLogPeerServer) RpcReplayLogAppend(request *ReplayLogAppendRequest, reply *ReplayLogReply) (err error) {
+	var (
+		file     *os.File
+		fileName string
+	)
+
+	fileName, err = server.replayLogFileName(request.VolumeName)
+	if nil != err {
+		return
+	}
+
+	server.Lock()
+	defer server.Unlock()
+
+	file, err = os.OpenFile(fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY|os.O_SYNC, 0600)
+	if nil != err {
+		return
+	}
+
+	_, err = file.Write(request.Transaction)
+	if nil != err {
+		_ = file.Close()
+		return
+	}
+
+	err = file.Close()
+
+	return
+}
+
+// RpcReplayLogFetch returns the Replay Log held for the volume (empty if none).
+func (server *ReplayLogPeerServer) RpcReplayLogFetch(request *ReplayLogFetchRequest, reply *ReplayLogFetchReply) (err error) {
+	var (
+		fileName string
+	)
+
+	fileName, err = server.replayLogFileName(request.VolumeName)
+	if nil != err {
+		return
+	}
+
+	server.Lock()
+	defer server.Unlock()
+
+	reply.ReplayLog, err = ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		reply.ReplayLog = make([]byte, 0)
+		err = nil
+	}
+
+	return
+}
+
+// RpcReplayLogTruncate discards the portion of the Replay Log held for the volume following ReplayLogSize bytes.
+func (server *ReplayLogPeerServer) RpcReplayLogTruncate(request *ReplayLogTruncateRequest, reply *ReplayLogReply) (err error) {
+	var (
+		fileName string
+	)
+
+	fileName, err = server.replayLogFileName(request.VolumeName)
+	if nil != err {
+		return
+	}
+
+	server.Lock()
+	defer server.Unlock()
+
+	err = os.Truncate(fileName, int64(request.ReplayLogSize))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+
+	return
+}
+
+// RpcReplayLogRemove discards the entire Replay Log held for the volume.
+func (server *ReplayLogPeerServer) RpcReplayLogRemove(request *ReplayLogRemoveRequest, reply *ReplayLogReply) (err error) {
+	var (
+		fileName string
+	)
+
+	fileName, err = server.replayLogFileName(request.VolumeName)
+	if nil != err {
+		return
+	}
+
+	server.Lock()
+	defer server.Unlock()
+
+	err = os.Remove(fileName)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+
+	return
+}
+
+func replayLogPeerServerUp(publicIPAddr string, port uint16, directory string) (err error) {
+	globals.replayLogPeerServer = &ReplayLogPeerServer{directory: directory}
+
+	globals.replayLogPeerServer.retryRPCServer = retryrpc.NewServer(&retryrpc.ServerConfig{
+		LongTrim:          replayLogPeerLongTrim,
+		ShortTrim:         replayLogPeerShortTrim,
+		DNSOrIPAddr:       publicIPAddr,
+		Port:              int(port),
+		DeadlineIO:        replayLogPeerDeadlineIO,
+		KeepAlivePeriod:   replayLogPeerKeepAlivePeriod,
+		TLSCertificate:    globals.replayLogPeerCertificate,
+		ClientCAx509PEM:   globals.replayLogPeerCAPEM,
+		RequireClientCert: true,
+	})
+
+	err = globals.replayLogPeerServer.retryRPCServer.Register(globals.replayLogPeerServer)
+	if nil != err {
+		return
+	}
+
+	err = globals.replayLogPeerServer.retryRPCServer.Start()
+	if nil != err {
+		return
+	}
+
+	globals.replayLogPeerServer.retryRPCServer.Run()
+
+	return
+}
+
+func replayLogPeerServerDown() {
+	if nil != globals.replayLogPeerServer {
+		globals.replayLogPeerServer.retryRPCServer.Close()
+		globals.replayLogPeerServer = nil
+	}
+}
+
+func newReplayLogPeer(volume *volumeStruct, peerName string, publicIPAddr string, local *replayLogFileStruct) (replayLogPeer *replayLogPeerStruct, err error) {
+	replayLogPeer = &replayLogPeerStruct{
+		volume:   volume,
+		peerName: peerName,
+		local:    local,
+		peerLost: false,
+	}
+
+	replayLogPeer.retryRPCClient, err = retryrpc.NewClient(&retryrpc.ClientConfig{
+		DNSOrIPAddr:                publicIPAddr,
+		Port:                       int(globals.replayLogPeerPort),
+		RootCAx509CertificatePEM:   globals.replayLogPeerCAPEM,
+		TLSCertificate:             globals.replayLogPeerCertificate,
+		Callbacks:                  replayLogPeer,
+		DeadlineIO:                 replayLogPeerDeadlineIO,
+		KeepAlivePeriod:            replayLogPeerKeepAlivePeriod,
+		FailOnConnectionRetryLimit: true,
+	})
+
+	return
+}
@@ -0,0 +1,376 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/icert/icertpkg"
+	"github.com/NVIDIA/proxyfs/ramswift"
+	"github.com/NVIDIA/proxyfs/transitions"
+)
+
+func TestHeadHunterReplayLogPeer(t *testing.T) {
+	var (
+		caCertPEMBlock         []byte
+		certDir                string
+		confMap                conf.ConfMap
+		confStrings            []string
+		doneChan               chan bool
+		err                    error
+		key                    uint64
+		localReplayLog         []byte
+		localReplayLogDir      string
+		localReplayLogFileName string
+		ok                     bool
+		peerReplayLog          []byte
+		peerReplayLogDir       string
+		peerReplayLogFileName  string
+		rootCAs                *x509.CertPool
+		signalHandlerIsArmedWG sync.WaitGroup
+		tlsConn                *tls.Conn
+		value                  []byte
+		volume                 VolumeHandle
+	)
+
+	peerReplayLogDir, err = ioutil.TempDir("", "TestVolume_Replay_Log_Peer_")
+	if nil != err {
+		t.Fatalf("ioutil.TempDir() returned error: %v", err)
+	}
+	defer os.RemoveAll(peerReplayLogDir)
+
+	localReplayLogDir, err = ioutil.TempDir("", "TestVolume_Replay_Log_Local_")
+	if nil != err {
+		t.Fatalf("ioutil.TempDir() returned error: %v", err)
+	}
+	defer os.RemoveAll(localReplayLogDir)
+
+	certDir, err = ioutil.TempDir("", "TestVolume_Replay_Log_Certs_")
+	if nil != err {
+		t.Fatalf("ioutil.TempDir() returned error: %v", err)
+	}
+	defer os.RemoveAll(certDir)
+
+	caCertPEMBlock, _, err = icertpkg.GenCACert(
+		icertpkg.GenerateKeyAlgorithmEd25519,
+		pkix.Name{Organization: []string{"Test Organization CA"}},
+		time.Hour,
+		filepath.Join(certDir, "ca.crt"),
+		filepath.Join(certDir, "ca.key"))
+	if nil != err {
+		t.Fatalf("icertpkg.GenCACert() returned error: %v", err)
+	}
+
+	_, _, err = icertpkg.GenEndpointCert(
+		icertpkg.GenerateKeyAlgorithmEd25519,
+		pkix.Name{CommonName: "Peer0", Organization: []string{"Test Organization Endpoint"}},
+		[]string{},
+		[]net.IP{net.ParseIP("127.0.0.1")},
+		time.Hour,
+		filepath.Join(certDir, "ca.crt"),
+		filepath.Join(certDir, "ca.key"),
+		filepath.Join(certDir, "peer.crt"),
+		filepath.Join(certDir, "peer.key"))
+	if nil != err {
+		t.Fatalf("icertpkg.GenEndpointCert() returned error: %v", err)
+	}
+
+	peerReplayLogFileName = filepath.Join(peerReplayLogDir, "TestVolume.rlog")
+	localReplayLogFileName = filepath.Join(localReplayLogDir, "TestVolume.rlog")
+
+	confStrings = []string{
+		"Logging.LogFilePath=/dev/null",
+		"Stats.IPAddr=localhost",
+		"Stats.UDPPort=52184",
+		"Stats.BufferLength=100",
+		"Stats.MaxLatency=1s",
+		"SwiftClient.NoAuthIPAddr=127.0.0.1",
+		"SwiftClient.NoAuthTCPPort=9999",
+		"SwiftClient.Timeout=10s",
+		"SwiftClient.RetryLimit=0",
+		"SwiftClient.RetryLimitObject=0",
+		"SwiftClient.RetryDelay=1s",
+		"SwiftClient.RetryDelayObject=1s",
+		"SwiftClient.RetryExpBackoff=1.2",
+		"SwiftClient.RetryExpBackoffObject=2.0",
+		"SwiftClient.ChunkedConnectionPoolSize=64",
+		"SwiftClient.NonChunkedConnectionPoolSize=32",
+		"Cluster.WhoAmI=Peer0",
+		"Peer:Peer0.PublicIPAddr=127.0.0.1",
+		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
+		"Volume:TestVolume.AccountName=TestAccount",
+		"Volume:TestVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:TestVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:TestVolume.MaxFlushSize=10000000",
+		"Volume:TestVolume.NonceValuesToReserve=100",
+		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
+		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"Volume:TestVolume.ReplayLogBackend=peer",
+		"Volume:TestVolume.ReplayLogPeer=Peer0",
+		"Volume:TestVolume.ReplayLogFileName=" + localReplayLogFileName,
+		"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume",
+		"VolumeGroup:TestVolumeGroup.VirtualIPAddr=",
+		"VolumeGroup:TestVolumeGroup.PrimaryPeer=Peer0",
+		"FSGlobals.VolumeGroupList=TestVolumeGroup",
+		"FSGlobals.CheckpointHeaderConsensusAttempts=5",
+		"FSGlobals.MountRetryLimit=6",
+		"FSGlobals.MountRetryDelay=1s",
+		"FSGlobals.MountRetryExpBackoff=2",
+		"FSGlobals.LogCheckpointHeaderPosts=true",
+		"FSGlobals.TryLockBackoffMin=10ms",
+		"FSGlobals.TryLockBackoffMax=50ms",
+		"FSGlobals.TryLockSerializationThreshhold=5",
+		"FSGlobals.SymlinkMax=32",
+		"FSGlobals.CoalesceElementChunkSize=16",
+		"FSGlobals.InodeRecCacheEvictLowLimit=10000",
+		"FSGlobals.InodeRecCacheEvictHighLimit=10010",
+		"FSGlobals.LogSegmentRecCacheEvictLowLimit=10000",
+		"FSGlobals.LogSegmentRecCacheEvictHighLimit=10010",
+		"FSGlobals.BPlusTreeObjectCacheEvictLowLimit=10000",
+		"FSGlobals.BPlusTreeObjectCacheEvictHighLimit=10010",
+		"FSGlobals.EtcdEnabled=false",
+		"FSGlobals.ReplayLogPeerPort=24460",
+		"FSGlobals.ReplayLogPeerDirectory=" + peerReplayLogDir,
+		"FSGlobals.ReplayLogPeerCertFilePath=" + filepath.Join(certDir, "peer.crt"),
+		"FSGlobals.ReplayLogPeerKeyFilePath=" + filepath.Join(certDir, "peer.key"),
+		"FSGlobals.ReplayLogPeerCAFilePath=" + filepath.Join(certDir, "ca.crt"),
+		"RamSwiftInfo.MaxAccountNameLength=256",
+		"RamSwiftInfo.MaxContainerNameLength=256",
+		"RamSwiftInfo.MaxObjectNameLength=1024",
+		"RamSwiftInfo.AccountListingLimit=10000",
+		"RamSwiftInfo.ContainerListingLimit=10000",
+	}
+
+	// Launch a ramswift instance
+
+	signalHandlerIsArmedWG.Add(1)
+	doneChan = make(chan bool, 1) // Must be buffered to avoid race
+
+	go ramswift.Daemon("/dev/null", confStrings, &signalHandlerIsArmedWG, doneChan, unix.SIGTERM)
+
+	signalHandlerIsArmedWG.Wait()
+
+	confMap, err = conf.MakeConfMapFromStrings(confStrings)
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings(confStrings) returned error: %v", err)
+	}
+
+	// Schedule a Format of TestVolume on first Up()
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=true")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=true\") returned error: %v", err)
+	}
+
+	// Up packages (TestVolume will be formatted)
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 1] returned error: %v", err)
+	}
+
+	// Unset AutoFormat for all subsequent uses of ConfMap
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=false")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=false\") returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 1] returned error: %v", err)
+	}
+
+	// Record a transaction and verify it was written both locally and to the peer
+
+	key = volume.FetchNonce()
+
+	err = volume.PutInodeRec(key, []byte("A"))
+	if nil != err {
+		t.Fatalf("PutInodeRec() returned error: %v", err)
+	}
+
+	peerReplayLog, err = ioutil.ReadFile(peerReplayLogFileName)
+	if nil != err {
+		t.Fatalf("ioutil.ReadFile(peerReplayLogFileName) returned error: %v", err)
+	}
+	if (0 == len(peerReplayLog)) || (0 != (uintptr(len(peerReplayLog)) & (replayLogWriteBufferAlignment - 1))) {
+		t.Fatalf("Replay Log on peer has unexpected length: %v", len(peerReplayLog))
+	}
+
+	localReplayLog, err = ioutil.ReadFile(localReplayLogFileName)
+	if nil != err {
+		t.Fatalf("ioutil.ReadFile(localReplayLogFileName) returned error: %v", err)
+	}
+	if !bytes.Equal(localReplayLog, peerReplayLog) {
+		t.Fatalf("Replay Log on peer differs from local copy")
+	}
+
+	// Verify the replay log peer server only holds Replay Logs of configured volumes
+
+	err = globals.replayLogPeerServer.RpcReplayLogFetch(&ReplayLogFetchRequest{VolumeName: "../TestVolume"}, &ReplayLogFetchReply{})
+	if nil == err {
+		t.Fatalf("RpcReplayLogFetch() of VolumeName \"../TestVolume\" should have failed")
+	}
+	err = globals.replayLogPeerServer.RpcReplayLogRemove(&ReplayLogRemoveRequest{VolumeName: "NoSuchVolume"}, &ReplayLogReply{})
+	if nil == err {
+		t.Fatalf("RpcReplayLogRemove() of VolumeName \"NoSuchVolume\" should have failed")
+	}
+
+	// Verify the replay log peer server refuses clients not presenting a certificate
+
+	rootCAs = x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caCertPEMBlock) {
+		t.Fatalf("rootCAs.AppendCertsFromPEM() returned !ok")
+	}
+
+	tlsConn, err = tls.Dial("tcp", "127.0.0.1:24460", &tls.Config{RootCAs: rootCAs})
+	if nil == err {
+		_ = tlsConn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		_, err = tlsConn.Read(make([]byte, 1))
+		_ = tlsConn.Close()
+	}
+	if nil == err {
+		t.Fatalf("Replay log peer server should have refused a client not presenting a certificate")
+	}
+
+	// Simulate a crash prior to checkpointing the PutInodeRec() by skipping the checkpoint at Down()
+
+	volume.(*volumeStruct).checkpointTriggeringEvents = 0
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 1] returned error: %v", err)
+	}
+
+	// Simulate a failover where the local copy of the Replay Log was lost
+	// and the peer's copy ends with a partially written Transaction
+
+	err = os.Remove(localReplayLogFileName)
+	if nil != err {
+		t.Fatalf("os.Remove(localReplayLogFileName) returned error: %v", err)
+	}
+
+	err = ioutil.WriteFile(peerReplayLogFileName, append(peerReplayLog, make([]byte, replayLogWriteBufferAlignment)...), 0600)
+	if nil != err {
+		t.Fatalf("ioutil.WriteFile(peerReplayLogFileName,,) returned error: %v", err)
+	}
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 2] returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 2] returned error: %v", err)
+	}
+
+	value, ok, err = volume.GetInodeRec(key)
+	if (nil != err) || !ok || ("A" != string(value)) {
+		t.Fatalf("GetInodeRec(%d) after replay returned unexpected value (%s), ok (%v), or err (%v)", key, value, ok, err)
+	}
+
+	// Verify the corrupt tail was truncated and the local copy was restored from the peer
+
+	localReplayLog, err = ioutil.ReadFile(localReplayLogFileName)
+	if nil != err {
+		t.Fatalf("ioutil.ReadFile(localReplayLogFileName) after replay returned error: %v", err)
+	}
+	if !bytes.Equal(localReplayLog, peerReplayLog) {
+		t.Fatalf("Local Replay Log after replay differs from that originally written")
+	}
+
+	localReplayLog, err = ioutil.ReadFile(peerReplayLogFileName)
+	if nil != err {
+		t.Fatalf("ioutil.ReadFile(peerReplayLogFileName) after replay returned error: %v", err)
+	}
+	if !bytes.Equal(localReplayLog, peerReplayLog) {
+		t.Fatalf("Replay Log on peer after replay was not truncated")
+	}
+
+	// Undo the transaction and verify the checkpoint taken at Down() removes both copies
+
+	err = volume.DeleteInodeRec(key)
+	if nil != err {
+		t.Fatalf("DeleteInodeRec() returned error: %v", err)
+	}
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 2] returned error: %v", err)
+	}
+
+	_, err = os.Stat(peerReplayLogFileName)
+	if !os.IsNotExist(err) {
+		t.Fatalf("Replay Log on peer should have been removed by checkpoint (err: %v)", err)
+	}
+	_, err = os.Stat(localReplayLogFileName)
+	if !os.IsNotExist(err) {
+		t.Fatalf("Local Replay Log should have been removed by checkpoint (err: %v)", err)
+	}
+
+	// Simulate both copies having been left behind by a since-superseded checkpoint
+
+	err = ioutil.WriteFile(peerReplayLogFileName, peerReplayLog, 0600)
+	if nil != err {
+		t.Fatalf("ioutil.WriteFile(peerReplayLogFileName,,) returned error: %v", err)
+	}
+	err = ioutil.WriteFile(localReplayLogFileName, peerReplayLog, 0600)
+	if nil != err {
+		t.Fatalf("ioutil.WriteFile(localReplayLogFileName,,) returned error: %v", err)
+	}
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 3] returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 3] returned error: %v", err)
+	}
+
+	_, ok, _ = volume.GetInodeRec(key)
+	if ok {
+		t.Fatalf("GetInodeRec(%d) should not have found stale Replay Log transaction", key)
+	}
+
+	_, err = os.Stat(peerReplayLogFileName)
+	if !os.IsNotExist(err) {
+		t.Fatalf("Stale Replay Log on peer should have been removed (err: %v)", err)
+	}
+	_, err = os.Stat(localReplayLogFileName)
+	if !os.IsNotExist(err) {
+		t.Fatalf("Stale local Replay Log should have been removed (err: %v)", err)
+	}
+
+	// Shutdown packages
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 3] returned error: %v", err)
+	}
+
+	// Send ourself a SIGTERM to terminate ramswift.Daemon()
+
+	unix.Kill(unix.Getpid(), unix.SIGTERM)
+
+	_ = <-doneChan
+}
@@ -0,0 +1,612 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/logger"
+	"github.com/NVIDIA/proxyfs/swiftclient"
+	"github.com/NVIDIA/proxyfs/utils"
+	"github.com/NVIDIA/sortedmap"
+)
+
+// Replication ships each newest SnapShot of a volume to the same-named containers of a second
+// Account (the replica). Only objects created since the previously replicated SnapShot are copied
+// (as recorded in the createdObjects of the intervening volumeViews) and objects deleted since
+// then (as recorded in their deletedObjects) are removed from the replica. A replica is marked
+// by ReplicaOfHeaderName on its checkpoint container that also durably records the nonce of the
+// last SnapShot replicated. A replica may be served read-only until promoted.
+
+const (
+	replicationCopyChunkSize = uint64(16 * 1024 * 1024)
+)
+
+type replicationObjectStruct struct {
+	containerName string
+	objectNumber  uint64
+}
+
+type replicationCopyContextStruct struct{}
+
+func (replicationCopyContext *replicationCopyContextStruct) BytesRemaining(bytesRemaining uint64) (chunkSize uint64) {
+	if bytesRemaining > replicationCopyChunkSize {
+		chunkSize = replicationCopyChunkSize
+	} else {
+		chunkSize = bytesRemaining
+	}
+	return
+}
+
+// fetchReplicaOf parses the ReplicaOfHeaderName Header (if any) of the checkpoint container of the
+// volume residing in accountName. If the volume is not a replica, replicaOfAccountName will be "".
+// If the checkpoint container does not (yet) exist, err will indicate http.StatusNotFound.
+func fetchReplicaOf(accountName string, checkpointContainerName string) (replicaOfAccountName string, replicaOfCheckpointContainerName string, replicatedSnapShotNonce uint64, checkpointObjectNumber uint64, err error) {
+	var (
+		checkpointContainerHeaders map[string][]string
+		checkpointHeaderSplit      []string
+		checkpointHeaderValues     []string
+		ok                         bool
+		replicaOfHeaderSplit       []string
+		replicaOfHeaderValues      []string
+	)
+
+	checkpointContainerHeaders, err = swiftclient.ContainerHead(accountName, checkpointContainerName)
+	if nil != err {
+		return
+	}
+
+	checkpointHeaderValues, ok = checkpointContainerHeaders[CheckpointHeaderName]
+	if ok && (0 < len(checkpointHeaderValues)) && ("" != checkpointHeaderValues[0]) {
+		checkpointHeaderSplit = strings.Split(checkpointHeaderValues[0], " ")
+		if 4 != len(checkpointHeaderSplit) {
+			err = fmt.Errorf("%s/%s Header %s should have four fields (had %d)", accountName, checkpointContainerName, CheckpointHeaderName, len(checkpointHeaderSplit))
+			return
+		}
+		checkpointObjectNumber, err = strconv.ParseUint(checkpointHeaderSplit[1], 16, 64)
+		if nil != err {
+			err = fmt.Errorf("%s/%s Header %s contained unparseable objectNumber (%s)", accountName, checkpointContainerName, CheckpointHeaderName, checkpointHeaderSplit[1])
+			return
+		}
+	} else {
+		checkpointObjectNumber = 0
+	}
+
+	replicaOfHeaderValues, ok = checkpointContainerHeaders[ReplicaOfHeaderName]
+	if !ok || (0 == len(replicaOfHeaderValues)) || ("" == replicaOfHeaderValues[0]) {
+		replicaOfAccountName = ""
+		replicaOfCheckpointContainerName = ""
+		replicatedSnapShotNonce = 0
+		return
+	}
+
+	replicaOfHeaderSplit = strings.Split(replicaOfHeaderValues[0], " ")
+	if 3 != len(replicaOfHeaderSplit) {
+		err = fmt.Errorf("%s/%s Header %s should have three fields (had %d)", accountName, checkpointContainerName, ReplicaOfHeaderName, len(replicaOfHeaderSplit))
+		return
+	}
+
+	replicaOfAccountName = replicaOfHeaderSplit[0]
+	replicaOfCheckpointContainerName = replicaOfHeaderSplit[1]
+
+	replicatedSnapShotNonce, err = strconv.ParseUint(replicaOfHeaderSplit[2], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("%s/%s Header %s contained unparseable nonce (%s)", accountName, checkpointContainerName, ReplicaOfHeaderName, replicaOfHeaderSplit[2])
+		return
+	}
+
+	return
+}
+
+// appendReplicationObjects appends the objects recorded in bPlusTree (keyed by objectNumber with
+// containerName values) to replicationObjects.
+func appendReplicationObjects(replicationObjects []replicationObjectStruct, bPlusTree sortedmap.BPlusTree) (newReplicationObjects []replicationObjectStruct, err error) {
+	var (
+		containerNameAsValue sortedmap.Value
+		index                int
+		numObjects           int
+		objectNumberAsKey    sortedmap.Key
+		ok                   bool
+	)
+
+	newReplicationObjects = replicationObjects
+
+	numObjects, err = bPlusTree.Len()
+	if nil != err {
+		return
+	}
+
+	for index = 0; index < numObjects; index++ {
+		objectNumberAsKey, containerNameAsValue, ok, err = bPlusTree.GetByIndex(index)
+		if nil != err {
+			return
+		}
+		if !ok {
+			err = fmt.Errorf("Logic error - bPlusTree.GetByIndex(%d) returned ok == false", index)
+			return
+		}
+
+		newReplicationObjects = append(newReplicationObjects, replicationObjectStruct{
+			containerName: string(containerNameAsValue.([]byte)),
+			objectNumber:  objectNumberAsKey.(uint64),
+		})
+	}
+
+	return
+}
+
+// appendReplicationLayoutObjects appends the checkpoint container objects holding the nodes of bPlusTree to replicationObjects.
+func (volume *volumeStruct) appendReplicationLayoutObjects(replicationObjects []replicationObjectStruct, bPlusTree sortedmap.BPlusTree) (newReplicationObjects []replicationObjectStruct, err error) {
+	var (
+		layoutReport sortedmap.LayoutReport
+		objectNumber uint64
+	)
+
+	newReplicationObjects = replicationObjects
+
+	layoutReport, err = bPlusTree.FetchLayoutReport()
+	if nil != err {
+		return
+	}
+
+	for objectNumber = range layoutReport {
+		newReplicationObjects = append(newReplicationObjects, replicationObjectStruct{
+			containerName: volume.checkpointContainerName,
+			objectNumber:  objectNumber,
+		})
+	}
+
+	return
+}
+
+// composeReplicationWhileLocked computes the objects that must be copied to (and deleted from) a
+// replica currently holding the SnapShot with nonce baseNonce (0 if none) in order for it to hold
+// replicatedVolumeView. If the base SnapShot no longer exists, a full copy is computed (and fullCopy
+// is returned true) leaving the caller to remove whatever the replica held that is not copied.
+func (volume *volumeStruct) composeReplicationWhileLocked(baseNonce uint64, replicatedVolumeView *volumeViewStruct) (copyList []replicationObjectStruct, deleteList []replicationObjectStruct, fullCopy bool, err error) {
+	var (
+		baseIndex       int
+		found           bool
+		index           int
+		ok              bool
+		replicatedIndex int
+		value           sortedmap.Value
+		volumeView      *volumeViewStruct
+	)
+
+	copyList = make([]replicationObjectStruct, 0)
+	deleteList = make([]replicationObjectStruct, 0)
+
+	if 0 != baseNonce {
+		baseIndex, found, err = volume.viewTreeByNonce.BisectLeft(baseNonce)
+		if nil != err {
+			return
+		}
+	} else {
+		found = false
+	}
+
+	if !found {
+		// Full copy of every object referenced by replicatedVolumeView
+
+		fullCopy = true
+
+		copyList, err = volume.appendReplicationLayoutObjects(copyList, replicatedVolumeView.inodeRecWrapper.bPlusTree)
+		if nil != err {
+			return
+		}
+		copyList, err = volume.appendReplicationLayoutObjects(copyList, replicatedVolumeView.logSegmentRecWrapper.bPlusTree)
+		if nil != err {
+			return
+		}
+		copyList, err = volume.appendReplicationLayoutObjects(copyList, replicatedVolumeView.bPlusTreeObjectWrapper.bPlusTree)
+		if nil != err {
+			return
+		}
+		copyList, err = appendReplicationObjects(copyList, replicatedVolumeView.logSegmentRecWrapper.bPlusTree)
+
+		return // err set as appropriate
+	}
+
+	replicatedIndex, found, err = volume.viewTreeByNonce.BisectLeft(replicatedVolumeView.nonce)
+	if nil != err {
+		return
+	}
+	if !found {
+		err = fmt.Errorf("Logic error - viewTreeByNonce.BisectLeft() returned found == false")
+		return
+	}
+
+	for index = baseIndex; index < replicatedIndex; index++ {
+		_, value, ok, err = volume.viewTreeByNonce.GetByIndex(index)
+		if nil != err {
+			return
+		}
+		if !ok {
+			err = fmt.Errorf("Logic error - viewTreeByNonce.GetByIndex(%d) returned ok == false", index)
+			return
+		}
+		volumeView, ok = value.(*volumeViewStruct)
+		if !ok {
+			err = fmt.Errorf("Logic error - viewTreeByNonce.GetByIndex(%d) returned something other than a volumeView", index)
+			return
+		}
+
+		copyList, err = appendReplicationObjects(copyList, volumeView.createdObjectsWrapper.bPlusTree)
+		if nil != err {
+			return
+		}
+		deleteList, err = appendReplicationObjects(deleteList, volumeView.deletedObjectsWrapper.bPlusTree)
+		if nil != err {
+			return
+		}
+	}
+
+	return
+}
+
+// ReplicateSnapShots brings the replica of this volume up to date with its most recent SnapShot.
+func (volume *volumeStruct) ReplicateSnapShots() (err error) {
+	var (
+		accountHeaders                   map[string][]string
+		baseNonce                        uint64
+		checkpointContainerHeaders       map[string][]string
+		checkpointTrailerBuf             []byte
+		chunkedPutContext                swiftclient.ChunkedPutContext
+		containerHeaders                 map[string][]string
+		containerName                    string
+		copyList                         []replicationObjectStruct
+		deleteList                       []replicationObjectStruct
+		deleteSet                        map[uint64]struct{}
+		fullCopy                         bool
+		ok                               bool
+		replicaCheckpointObjectNumber    uint64
+		replicaContainerSet              map[string]struct{}
+		replicaOfAccountName             string
+		replicaOfCheckpointContainerName string
+		replicatedVolumeView             *volumeViewStruct
+		replicationObject                replicationObjectStruct
+		trailerObjectNumber              uint64
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.ReplicateSnapShotsUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.ReplicateSnapShotsErrors.Add(1)
+		}
+	}()
+
+	if "" == volume.replicaAccountName {
+		err = fmt.Errorf("Volume %v is not configured for replication", volume.volumeName)
+		return
+	}
+
+	volume.replicationMutex.Lock()
+	defer volume.replicationMutex.Unlock()
+
+	// Determine which SnapShot (if any) the replica currently holds
+
+	replicaOfAccountName, replicaOfCheckpointContainerName, baseNonce, replicaCheckpointObjectNumber, err = fetchReplicaOf(volume.replicaAccountName, volume.checkpointContainerName)
+	if nil == err {
+		if 0 != replicaCheckpointObjectNumber {
+			if (replicaOfAccountName != volume.accountName) || (replicaOfCheckpointContainerName != volume.checkpointContainerName) {
+				err = fmt.Errorf("%s/%s is not a replica of volume %v (promoted?)", volume.replicaAccountName, volume.checkpointContainerName, volume.volumeName)
+				return
+			}
+		}
+	} else {
+		if http.StatusNotFound != blunder.HTTPCode(err) {
+			return
+		}
+		baseNonce = 0
+		replicaCheckpointObjectNumber = 0
+	}
+
+	volume.Lock()
+
+	volume.replicatedSnapShotNonce = baseNonce
+
+	replicatedVolumeView = volume.priorView
+
+	if (nil == replicatedVolumeView) || (replicatedVolumeView.nonce == baseNonce) {
+		// Nothing (new) to replicate
+		volume.Unlock()
+		err = nil
+		return
+	}
+
+	copyList, deleteList, fullCopy, err = volume.composeReplicationWhileLocked(baseNonce, replicatedVolumeView)
+	if nil != err {
+		volume.Unlock()
+		return
+	}
+
+	checkpointTrailerBuf, err = volume.composeVolumeViewCheckpointTrailerWhileLocked(replicatedVolumeView)
+	if nil != err {
+		volume.Unlock()
+		return
+	}
+
+	// All objects referenced by replicatedVolumeView were numbered before trailerObjectNumber
+
+	trailerObjectNumber = volume.fetchNonceWhileLocked()
+
+	// Prevent deletion of replicatedVolumeView while its objects are being copied
+
+	volume.replicatingSnapShotNonce = replicatedVolumeView.nonce
+
+	volume.Unlock()
+
+	defer func() {
+		volume.Lock()
+		volume.replicatingSnapShotNonce = 0
+		if nil == err {
+			volume.replicatedSnapShotNonce = replicatedVolumeView.nonce
+		}
+		volume.Unlock()
+	}()
+
+	// Objects both created and deleted since baseNonce need not be copied
+
+	deleteSet = make(map[uint64]struct{})
+
+	for _, replicationObject = range deleteList {
+		deleteSet[replicationObject.objectNumber] = struct{}{}
+	}
+
+	// Ensure the replica's containers exist (using the same Storage Policy as their source)
+
+	replicaContainerSet = make(map[string]struct{})
+
+	checkpointContainerHeaders = make(map[string][]string)
+
+	checkpointContainerHeaders[StoragePolicyHeaderName] = []string{volume.checkpointContainerStoragePolicy}
+
+	err = swiftclient.ContainerPut(volume.replicaAccountName, volume.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	replicaContainerSet[volume.checkpointContainerName] = struct{}{}
+
+	for _, replicationObject = range copyList {
+		_, ok = deleteSet[replicationObject.objectNumber]
+		if ok {
+			continue
+		}
+
+		containerName = replicationObject.containerName
+
+		_, ok = replicaContainerSet[containerName]
+		if !ok {
+			containerHeaders, err = swiftclient.ContainerHead(volume.accountName, containerName)
+			if nil != err {
+				return
+			}
+			checkpointContainerHeaders = make(map[string][]string)
+			if nil != containerHeaders[StoragePolicyHeaderName] {
+				checkpointContainerHeaders[StoragePolicyHeaderName] = containerHeaders[StoragePolicyHeaderName]
+			}
+			err = swiftclient.ContainerPut(volume.replicaAccountName, containerName, checkpointContainerHeaders)
+			if nil != err {
+				return
+			}
+			replicaContainerSet[containerName] = struct{}{}
+		}
+
+		err = swiftclient.ObjectCopy(
+			volume.accountName, containerName, utils.Uint64ToHexStr(replicationObject.objectNumber),
+			volume.replicaAccountName, containerName, utils.Uint64ToHexStr(replicationObject.objectNumber),
+			&replicationCopyContextStruct{})
+		if nil != err {
+			if http.StatusNotFound != blunder.HTTPCode(err) {
+				return
+			}
+			// Object was since deleted... so it was not referenced by replicatedVolumeView
+		}
+	}
+
+	// Write the replica's checkpoint trailer (named trailerObjectNumber)
+
+	chunkedPutContext, err = swiftclient.ObjectFetchChunkedPutContext(volume.replicaAccountName, volume.checkpointContainerName, utils.Uint64ToHexStr(trailerObjectNumber), "")
+	if nil != err {
+		return
+	}
+
+	err = chunkedPutContext.SendChunk(checkpointTrailerBuf)
+	if nil != err {
+		return
+	}
+
+	err = chunkedPutContext.Close()
+	if nil != err {
+		return
+	}
+
+	// Make replicatedVolumeView visible in the replica (also durably recording replication progress)
+
+	checkpointContainerHeaders = make(map[string][]string)
+
+	checkpointContainerHeaders[CheckpointHeaderName] = []string{
+		fmt.Sprintf("%016X %016X %016X %016X", CheckpointVersion3, trailerObjectNumber, uint64(len(checkpointTrailerBuf)), trailerObjectNumber+1),
+	}
+	checkpointContainerHeaders[ReplicaOfHeaderName] = []string{
+		fmt.Sprintf("%s %s %016X", volume.accountName, volume.checkpointContainerName, replicatedVolumeView.nonce),
+	}
+
+	err = swiftclient.ContainerPost(volume.replicaAccountName, volume.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	accountHeaders = make(map[string][]string)
+
+	accountHeaders[AccountHeaderName] = []string{AccountHeaderValue}
+
+	err = swiftclient.AccountPost(volume.replicaAccountName, accountHeaders)
+	if nil != err {
+		return
+	}
+
+	// Finally, remove the replica's prior checkpoint trailer and objects no longer referenced
+
+	if 0 != replicaCheckpointObjectNumber {
+		deleteList = append(deleteList, replicationObjectStruct{
+			containerName: volume.checkpointContainerName,
+			objectNumber:  replicaCheckpointObjectNumber,
+		})
+	}
+
+	for _, replicationObject = range deleteList {
+		err = swiftclient.ObjectDelete(volume.replicaAccountName, replicationObject.containerName, utils.Uint64ToHexStr(replicationObject.objectNumber), swiftclient.SkipRetry)
+		if (nil != err) && (http.StatusNotFound != blunder.HTTPCode(err)) {
+			logger.WarnfWithError(err, "Replica of volume %v failed to delete %s/%s/%016X", volume.volumeName, volume.replicaAccountName, replicationObject.containerName, replicationObject.objectNumber)
+		}
+	}
+
+	// A full copy over an existing replica says nothing about what the replica previously held
+
+	if fullCopy && (0 != replicaCheckpointObjectNumber) {
+		err = volume.removeStaleReplicaObjects(copyList, trailerObjectNumber)
+		if nil != err {
+			logger.WarnfWithError(err, "Replica of volume %v failed to remove stale objects", volume.volumeName)
+		}
+	}
+
+	err = nil
+	return
+}
+
+// removeStaleReplicaObjects removes from every container of the replica each object named by an
+// object number that is neither in keepList nor the replica's checkpoint trailer (trailerObjectNumber).
+// Objects not named by an object number are left alone.
+func (volume *volumeStruct) removeStaleReplicaObjects(keepList []replicationObjectStruct, trailerObjectNumber uint64) (err error) {
+	var (
+		containerList     []string
+		containerName     string
+		keepSet           map[replicationObjectStruct]struct{}
+		objectList        []string
+		objectName        string
+		objectNumber      uint64
+		ok                bool
+		replicationObject replicationObjectStruct
+	)
+
+	keepSet = make(map[replicationObjectStruct]struct{})
+
+	for _, replicationObject = range keepList {
+		keepSet[replicationObject] = struct{}{}
+	}
+
+	keepSet[replicationObjectStruct{containerName: volume.checkpointContainerName, objectNumber: trailerObjectNumber}] = struct{}{}
+
+	_, containerList, err = swiftclient.AccountGet(volume.replicaAccountName)
+	if nil != err {
+		return
+	}
+
+	for _, containerName = range containerList {
+		_, objectList, err = swiftclient.ContainerGet(volume.replicaAccountName, containerName)
+		if nil != err {
+			return
+		}
+
+		for _, objectName = range objectList {
+			if 16 != len(objectName) {
+				continue
+			}
+			objectNumber, err = strconv.ParseUint(objectName, 16, 64)
+			if nil != err {
+				continue
+			}
+
+			_, ok = keepSet[replicationObjectStruct{containerName: containerName, objectNumber: objectNumber}]
+			if ok {
+				continue
+			}
+
+			err = swiftclient.ObjectDelete(volume.replicaAccountName, containerName, objectName, swiftclient.SkipRetry)
+			if (nil != err) && (http.StatusNotFound != blunder.HTTPCode(err)) {
+				logger.WarnfWithError(err, "Replica of volume %v failed to delete %s/%s/%s", volume.volumeName, volume.replicaAccountName, containerName, objectName)
+			}
+		}
+	}
+
+	err = nil
+	return
+}
+
+// RequestReplication asynchronously triggers a call to ReplicateSnapShots() if the volume is so configured.
+func (volume *volumeStruct) RequestReplication() {
+	if nil == volume.replicationRequestChan {
+		return
+	}
+
+	select {
+	case volume.replicationRequestChan <- struct{}{}:
+		// Request queued
+	default:
+		// A request is already pending... which will pick up the latest SnapShot
+	}
+}
+
+func (volume *volumeStruct) replicationDaemon() {
+	var (
+		err error
+	)
+
+	for range volume.replicationRequestChan {
+		err = volume.ReplicateSnapShots()
+		if nil != err {
+			logger.WarnfWithError(err, "Replication of volume %v to Account %v failed", volume.volumeName, volume.replicaAccountName)
+		}
+	}
+
+	volume.replicationDaemonWG.Done()
+}
+
+// IsReplica returns true if the volume is a (not yet promoted) replica of another volume.
+func (volume *volumeStruct) IsReplica() (isReplica bool) {
+	isReplica = (0 != atomic.LoadUint32(&volume.isReplica))
+	return
+}
+
+// PromoteReplica converts a replica into an ordinary (writable) volume. Subsequent replication
+// attempts from the original volume will fail rather than overwrite the promoted volume.
+func (volume *volumeStruct) PromoteReplica() (err error) {
+	var (
+		checkpointContainerHeaders map[string][]string
+	)
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	if "" == volume.replicaOfAccountName {
+		err = fmt.Errorf("Volume %v is not a replica", volume.volumeName)
+		return
+	}
+
+	// Note: Swift removes metadata POST'd with an empty value
+
+	checkpointContainerHeaders = make(map[string][]string)
+
+	checkpointContainerHeaders[ReplicaOfHeaderName] = []string{""}
+
+	err = swiftclient.ContainerPost(volume.accountName, volume.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	volume.replicaOfAccountName = ""
+	atomic.StoreUint32(&volume.isReplica, 0)
+
+	return
+}
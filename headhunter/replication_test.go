@@ -0,0 +1,547 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/ramswift"
+	"github.com/NVIDIA/proxyfs/swiftclient"
+	"github.com/NVIDIA/proxyfs/transitions"
+	"github.com/NVIDIA/proxyfs/utils"
+)
+
+func logSegmentObjectPut(t *testing.T, volume VolumeHandle, logSegmentNumber uint64) {
+	chunkedPutContext, err := swiftclient.ObjectFetchChunkedPutContext("TestAccount", "ReplicaTestContainer", utils.Uint64ToHexStr(logSegmentNumber), "")
+	if nil != err {
+		t.Fatalf("ObjectFetchChunkedPutContext() of LogSegment 0x%016X failed: %v", logSegmentNumber, err)
+	}
+	err = chunkedPutContext.SendChunk([]byte("LogSegment"))
+	if nil != err {
+		t.Fatalf("SendChunk() of LogSegment 0x%016X failed: %v", logSegmentNumber, err)
+	}
+	err = chunkedPutContext.Close()
+	if nil != err {
+		t.Fatalf("Close() of LogSegment 0x%016X failed: %v", logSegmentNumber, err)
+	}
+
+	err = volume.PutLogSegmentRec(logSegmentNumber, []byte("ReplicaTestContainer"))
+	if nil != err {
+		t.Fatalf("PutLogSegmentRec() of LogSegment 0x%016X failed: %v", logSegmentNumber, err)
+	}
+}
+
+func replicaObjectPut(t *testing.T, containerName string, objectName string) {
+	var (
+		chunkedPutContext swiftclient.ChunkedPutContext
+		err               error
+	)
+
+	chunkedPutContext, err = swiftclient.ObjectFetchChunkedPutContext("TestReplicaAccount", containerName, objectName, "")
+	if nil != err {
+		t.Fatalf("ObjectFetchChunkedPutContext(\"TestReplicaAccount\",\"%s\",\"%s\",) returned error: %v", containerName, objectName, err)
+	}
+	err = chunkedPutContext.SendChunk([]byte("stale"))
+	if nil != err {
+		t.Fatalf("SendChunk() returned error: %v", err)
+	}
+	err = chunkedPutContext.Close()
+	if nil != err {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+}
+
+func TestHeadHunterReplication(t *testing.T) {
+	var (
+		confMap                conf.ConfMap
+		confStrings            []string
+		doneChan               chan bool
+		err                    error
+		signalHandlerIsArmedWG sync.WaitGroup
+		snapShotID             uint64
+		volume                 VolumeHandle
+	)
+
+	confStrings = []string{
+		"Logging.LogFilePath=/dev/null",
+		"Stats.IPAddr=localhost",
+		"Stats.UDPPort=52184",
+		"Stats.BufferLength=100",
+		"Stats.MaxLatency=1s",
+		"SwiftClient.NoAuthIPAddr=127.0.0.1",
+		"SwiftClient.NoAuthTCPPort=9999",
+		"SwiftClient.Timeout=10s",
+		"SwiftClient.RetryLimit=0",
+		"SwiftClient.RetryLimitObject=0",
+		"SwiftClient.RetryDelay=1s",
+		"SwiftClient.RetryDelayObject=1s",
+		"SwiftClient.RetryExpBackoff=1.2",
+		"SwiftClient.RetryExpBackoffObject=2.0",
+		"SwiftClient.ChunkedConnectionPoolSize=64",
+		"SwiftClient.NonChunkedConnectionPoolSize=32",
+		"Cluster.WhoAmI=Peer0",
+		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
+		"Volume:TestVolume.AccountName=TestAccount",
+		"Volume:TestVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:TestVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:TestVolume.ReplicaAccountName=TestReplicaAccount",
+		"Volume:TestVolume.MaxFlushSize=10000000",
+		"Volume:TestVolume.NonceValuesToReserve=100",
+		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
+		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume",
+		"VolumeGroup:TestVolumeGroup.VirtualIPAddr=",
+		"VolumeGroup:TestVolumeGroup.PrimaryPeer=Peer0",
+		"FSGlobals.VolumeGroupList=TestVolumeGroup",
+		"FSGlobals.CheckpointHeaderConsensusAttempts=5",
+		"FSGlobals.MountRetryLimit=6",
+		"FSGlobals.MountRetryDelay=1s",
+		"FSGlobals.MountRetryExpBackoff=2",
+		"FSGlobals.LogCheckpointHeaderPosts=true",
+		"FSGlobals.TryLockBackoffMin=10ms",
+		"FSGlobals.TryLockBackoffMax=50ms",
+		"FSGlobals.TryLockSerializationThreshhold=5",
+		"FSGlobals.SymlinkMax=32",
+		"FSGlobals.CoalesceElementChunkSize=16",
+		"FSGlobals.InodeRecCacheEvictLowLimit=10000",
+		"FSGlobals.InodeRecCacheEvictHighLimit=10010",
+		"FSGlobals.LogSegmentRecCacheEvictLowLimit=10000",
+		"FSGlobals.LogSegmentRecCacheEvictHighLimit=10010",
+		"FSGlobals.BPlusTreeObjectCacheEvictLowLimit=10000",
+		"FSGlobals.BPlusTreeObjectCacheEvictHighLimit=10010",
+		"FSGlobals.EtcdEnabled=false",
+		"RamSwiftInfo.MaxAccountNameLength=256",
+		"RamSwiftInfo.MaxContainerNameLength=256",
+		"RamSwiftInfo.MaxObjectNameLength=1024",
+		"RamSwiftInfo.AccountListingLimit=10000",
+		"RamSwiftInfo.ContainerListingLimit=10000",
+	}
+
+	// Launch a ramswift instance
+
+	signalHandlerIsArmedWG.Add(1)
+	doneChan = make(chan bool, 1) // Must be buffered to avoid race
+
+	go ramswift.Daemon("/dev/null", confStrings, &signalHandlerIsArmedWG, doneChan, unix.SIGTERM)
+
+	signalHandlerIsArmedWG.Wait()
+
+	confMap, err = conf.MakeConfMapFromStrings(confStrings)
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings(confStrings) returned error: %v", err)
+	}
+
+	// Schedule a Format of TestVolume on first Up()
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=true")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=true\") returned error: %v", err)
+	}
+
+	// Up packages (TestVolume will be formatted)
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 1] returned error: %v", err)
+	}
+
+	// Unset AutoFormat for all subsequent uses of ConfMap
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=false")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=false\") returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 1] returned error: %v", err)
+	}
+
+	err = swiftclient.AccountPut("TestReplicaAccount", make(map[string][]string))
+	if nil != err {
+		t.Fatalf("AccountPut(\"TestReplicaAccount\") returned error: %v", err)
+	}
+
+	// Replicate a first SnapShot
+
+	inodeRecPutGet(t, volume, 1234, []byte("A"))
+
+	snapShotID, err = volume.SnapShotCreateByInodeLayer("SnapShot1")
+	if nil != err {
+		t.Fatalf("SnapShotCreateByInodeLayer(\"SnapShot1\") returned error: %v", err)
+	}
+
+	err = volume.ReplicateSnapShots()
+	if nil != err {
+		t.Fatalf("ReplicateSnapShots() [case 1] returned error: %v", err)
+	}
+
+	if volume.IsReplica() {
+		t.Fatalf("IsReplica() of the replicated volume should have returned false")
+	}
+
+	// Leave an object in the replica that a full re-copy must remove (and one it must not)
+
+	replicaObjectPut(t, ".__checkpoint__", "00000000DEADBEEF")
+	replicaObjectPut(t, ".__checkpoint__", "NotAnObjectNumber")
+
+	// Following a remount (that forgets which SnapShot was replicated), delete the replicated
+	// SnapShot such that replicating the next one requires a full re-copy
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 1] returned error: %v", err)
+	}
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 2] returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 2] returned error: %v", err)
+	}
+
+	err = volume.SnapShotDeleteByInodeLayer(snapShotID)
+	if nil != err {
+		t.Fatalf("SnapShotDeleteByInodeLayer(%d) returned error: %v", snapShotID, err)
+	}
+
+	inodeRecPutGet(t, volume, 1234, []byte("B"))
+
+	_, err = volume.SnapShotCreateByInodeLayer("SnapShot2")
+	if nil != err {
+		t.Fatalf("SnapShotCreateByInodeLayer(\"SnapShot2\") returned error: %v", err)
+	}
+
+	err = volume.ReplicateSnapShots()
+	if nil != err {
+		t.Fatalf("ReplicateSnapShots() [case 2] returned error: %v", err)
+	}
+
+	_, err = swiftclient.ObjectHead("TestReplicaAccount", ".__checkpoint__", "00000000DEADBEEF")
+	if http.StatusNotFound != blunder.HTTPCode(err) {
+		t.Fatalf("ObjectHead() of a stale replica object should have failed with NotFound (err: %v)", err)
+	}
+
+	_, err = swiftclient.ObjectHead("TestReplicaAccount", ".__checkpoint__", "NotAnObjectNumber")
+	if nil != err {
+		t.Fatalf("ObjectHead() of a replica object not named by an object number returned error: %v", err)
+	}
+
+	// Shutdown packages
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 2] returned error: %v", err)
+	}
+
+	// Send ourself a SIGTERM to terminate ramswift.Daemon()
+
+	unix.Kill(unix.Getpid(), unix.SIGTERM)
+
+	_ = <-doneChan
+}
+
+func TestHeadHunterReplicaVolume(t *testing.T) {
+	var (
+		confMap                conf.ConfMap
+		confStrings            []string
+		doneChan               chan bool
+		err                    error
+		key                    uint64
+		ok                     bool
+		replicaVolume          VolumeHandle
+		replicatedSnapShotID   uint64
+		signalHandlerIsArmedWG sync.WaitGroup
+		value                  []byte
+		volume                 VolumeHandle
+	)
+
+	confStrings = []string{
+		"Logging.LogFilePath=/dev/null",
+		"Stats.IPAddr=localhost",
+		"Stats.UDPPort=52184",
+		"Stats.BufferLength=100",
+		"Stats.MaxLatency=1s",
+		"SwiftClient.NoAuthIPAddr=127.0.0.1",
+		"SwiftClient.NoAuthTCPPort=9999",
+		"SwiftClient.Timeout=10s",
+		"SwiftClient.RetryLimit=0",
+		"SwiftClient.RetryLimitObject=0",
+		"SwiftClient.RetryDelay=1s",
+		"SwiftClient.RetryDelayObject=1s",
+		"SwiftClient.RetryExpBackoff=1.2",
+		"SwiftClient.RetryExpBackoffObject=2.0",
+		"SwiftClient.ChunkedConnectionPoolSize=64",
+		"SwiftClient.NonChunkedConnectionPoolSize=32",
+		"Cluster.WhoAmI=Peer0",
+		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
+		"Volume:TestVolume.AccountName=TestAccount",
+		"Volume:TestVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:TestVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:TestVolume.MaxFlushSize=10000000",
+		"Volume:TestVolume.NonceValuesToReserve=100",
+		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
+		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"Volume:ReplicaVolume.AccountName=ReplicaAccount",
+		"Volume:ReplicaVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:ReplicaVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:ReplicaVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:ReplicaVolume.MaxFlushSize=10000000",
+		"Volume:ReplicaVolume.NonceValuesToReserve=100",
+		"Volume:ReplicaVolume.MaxInodesPerMetadataNode=32",
+		"Volume:ReplicaVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:ReplicaVolume.MaxDirFileNodesPerMetadataNode=16",
+		"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume",
+		"VolumeGroup:TestVolumeGroup.VirtualIPAddr=",
+		"VolumeGroup:TestVolumeGroup.PrimaryPeer=Peer0",
+		"FSGlobals.VolumeGroupList=TestVolumeGroup",
+		"FSGlobals.CheckpointHeaderConsensusAttempts=5",
+		"FSGlobals.MountRetryLimit=6",
+		"FSGlobals.MountRetryDelay=1s",
+		"FSGlobals.MountRetryExpBackoff=2",
+		"FSGlobals.LogCheckpointHeaderPosts=true",
+		"FSGlobals.TryLockBackoffMin=10ms",
+		"FSGlobals.TryLockBackoffMax=50ms",
+		"FSGlobals.TryLockSerializationThreshhold=5",
+		"FSGlobals.SymlinkMax=32",
+		"FSGlobals.CoalesceElementChunkSize=16",
+		"FSGlobals.InodeRecCacheEvictLowLimit=10000",
+		"FSGlobals.InodeRecCacheEvictHighLimit=10010",
+		"FSGlobals.LogSegmentRecCacheEvictLowLimit=10000",
+		"FSGlobals.LogSegmentRecCacheEvictHighLimit=10010",
+		"FSGlobals.BPlusTreeObjectCacheEvictLowLimit=10000",
+		"FSGlobals.BPlusTreeObjectCacheEvictHighLimit=10010",
+		"FSGlobals.EtcdEnabled=false",
+		"RamSwiftInfo.MaxAccountNameLength=256",
+		"RamSwiftInfo.MaxContainerNameLength=256",
+		"RamSwiftInfo.MaxObjectNameLength=1024",
+		"RamSwiftInfo.AccountListingLimit=10000",
+		"RamSwiftInfo.ContainerListingLimit=10000",
+	}
+
+	// Launch a ramswift instance
+
+	signalHandlerIsArmedWG.Add(1)
+	doneChan = make(chan bool, 1) // Must be buffered to avoid race
+
+	go ramswift.Daemon("/dev/null", confStrings, &signalHandlerIsArmedWG, doneChan, unix.SIGTERM)
+
+	signalHandlerIsArmedWG.Wait()
+
+	confMap, err = conf.MakeConfMapFromStrings(confStrings)
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings(confStrings) returned error: %v", err)
+	}
+
+	// Schedule a Format of TestVolume on first Up()
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=true")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=true\") returned error: %v", err)
+	}
+
+	// Up packages (TestVolume will be formatted)
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 1] returned error: %v", err)
+	}
+
+	// Unset AutoFormat for all subsequent uses of ConfMap
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=false")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=false\") returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 1] returned error: %v", err)
+	}
+
+	key = 5678
+
+	// Replicate SnapShots of TestVolume to ReplicaAccount
+
+	err = swiftclient.AccountPut("ReplicaAccount", make(map[string][]string))
+	if nil != err {
+		t.Fatalf("swiftclient.AccountPut(\"ReplicaAccount\") returned error: %v", err)
+	}
+
+	err = swiftclient.ContainerPut("TestAccount", "ReplicaTestContainer", make(map[string][]string))
+	if nil != err {
+		t.Fatalf("swiftclient.ContainerPut(\"ReplicaTestContainer\") returned error: %v", err)
+	}
+
+	logSegmentObjectPut(t, volume, 0x1111)
+
+	inodeRecPutGet(t, volume, key, []byte("FirstReplica"))
+
+	_, err = volume.SnapShotCreateByInodeLayer("FirstReplica")
+	if nil != err {
+		t.Fatalf("SnapShotCreateByInodeLayer(\"FirstReplica\") returned error: %v", err)
+	}
+
+	err = volume.ReplicateSnapShots()
+	if nil == err {
+		t.Fatalf("ReplicateSnapShots() of volume not configured for replication should have failed")
+	}
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 1] returned error: %v", err)
+	}
+
+	err = confMap.UpdateFromString("Volume:TestVolume.ReplicaAccountName=ReplicaAccount")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.ReplicaAccountName=ReplicaAccount\") returned error: %v", err)
+	}
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 2] returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 2] returned error: %v", err)
+	}
+
+	err = volume.ReplicateSnapShots()
+	if nil != err {
+		t.Fatalf("ReplicateSnapShots() [full] returned error: %v", err)
+	}
+
+	_, err = swiftclient.ObjectContentLength("ReplicaAccount", "ReplicaTestContainer", utils.Uint64ToHexStr(0x1111))
+	if nil != err {
+		t.Fatalf("Replicated LogSegment 0x1111 missing from ReplicaAccount: %v", err)
+	}
+
+	err = volume.DeleteLogSegmentRec(0x1111)
+	if nil != err {
+		t.Fatalf("DeleteLogSegmentRec(0x1111) returned error: %v", err)
+	}
+
+	logSegmentObjectPut(t, volume, 0x2222)
+
+	inodeRecPutGet(t, volume, key, []byte("SecondReplica"))
+
+	replicatedSnapShotID, err = volume.SnapShotCreateByInodeLayer("SecondReplica")
+	if nil != err {
+		t.Fatalf("SnapShotCreateByInodeLayer(\"SecondReplica\") returned error: %v", err)
+	}
+
+	inodeRecPutGet(t, volume, key, []byte("NotReplicated"))
+
+	err = volume.ReplicateSnapShots()
+	if nil != err {
+		t.Fatalf("ReplicateSnapShots() [incremental] returned error: %v", err)
+	}
+
+	_, err = swiftclient.ObjectContentLength("ReplicaAccount", "ReplicaTestContainer", utils.Uint64ToHexStr(0x2222))
+	if nil != err {
+		t.Fatalf("Replicated LogSegment 0x2222 missing from ReplicaAccount: %v", err)
+	}
+	_, err = swiftclient.ObjectContentLength("ReplicaAccount", "ReplicaTestContainer", utils.Uint64ToHexStr(0x1111))
+	if nil == err {
+		t.Fatalf("Deleted LogSegment 0x1111 should have been removed from ReplicaAccount")
+	}
+
+	err = volume.SnapShotDeleteByInodeLayer(replicatedSnapShotID)
+	if nil == err {
+		t.Fatalf("SnapShotDeleteByInodeLayer() of replicated SnapShot should have failed")
+	}
+
+	// Serve ReplicaVolume (read-only) alongside TestVolume, then promote it
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 2] returned error: %v", err)
+	}
+
+	err = confMap.UpdateFromString("VolumeGroup:TestVolumeGroup.VolumeList=TestVolume,ReplicaVolume")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume,ReplicaVolume\") returned error: %v", err)
+	}
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 3] returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 3] returned error: %v", err)
+	}
+	replicaVolume, err = FetchVolumeHandle("ReplicaVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"ReplicaVolume\") returned error: %v", err)
+	}
+
+	if !replicaVolume.IsReplica() {
+		t.Fatalf("replicaVolume.IsReplica() should have returned true")
+	}
+
+	value, ok, err = replicaVolume.GetInodeRec(key)
+	if (nil != err) || !ok || ("SecondReplica" != string(value)) {
+		t.Fatalf("replicaVolume.GetInodeRec() returned unexpected value (%s), ok (%v), or err (%v)", value, ok, err)
+	}
+
+	_, err = replicaVolume.GetLogSegmentRec(0x2222)
+	if nil != err {
+		t.Fatalf("replicaVolume.GetLogSegmentRec(0x2222) returned error: %v", err)
+	}
+
+	err = replicaVolume.PromoteReplica()
+	if nil != err {
+		t.Fatalf("replicaVolume.PromoteReplica() returned error: %v", err)
+	}
+
+	if replicaVolume.IsReplica() {
+		t.Fatalf("replicaVolume.IsReplica() should have returned false once promoted")
+	}
+
+	inodeRecPutGet(t, replicaVolume, key, []byte("ReplicaVolume"))
+
+	err = replicaVolume.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("replicaVolume.DoCheckpoint() returned error: %v", err)
+	}
+
+	_, err = volume.SnapShotCreateByInodeLayer("AfterPromotion")
+	if nil != err {
+		t.Fatalf("SnapShotCreateByInodeLayer(\"AfterPromotion\") returned error: %v", err)
+	}
+
+	err = volume.ReplicateSnapShots()
+	if nil == err {
+		t.Fatalf("ReplicateSnapShots() to promoted replica should have failed")
+	}
+
+	// Shutdown packages
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 3] returned error: %v", err)
+	}
+
+	// Send ourself a SIGTERM to terminate ramswift.Daemon()
+
+	unix.Kill(unix.Getpid(), unix.SIGTERM)
+
+	_ = <-doneChan
+}
@@ -0,0 +1,716 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/NVIDIA/cstruct"
+	"github.com/NVIDIA/sortedmap"
+
+	"github.com/NVIDIA/proxyfs/evtlog"
+	"github.com/NVIDIA/proxyfs/logger"
+	"github.com/NVIDIA/proxyfs/swiftclient"
+	"github.com/NVIDIA/proxyfs/utils"
+)
+
+// If CheckpointRetentionCount is non-zero, the most recent CheckpointRetentionCount superseded checkpoints
+// are retained. The objects a checkpoint finds to be no longer referenced (that would otherwise be passed to
+// performDelayedObjectDeletes()) are instead recorded with the checkpoint being superseded. Only once that
+// checkpoint ages out of the retention window are they actually deleted. As objects are never resurrected
+// by subsequent checkpoints, a retained checkpoint remains intact so long as it and every newer retained
+// checkpoint have yet to age out. The RetainedCheckpointList is persisted in the CheckpointObjectTrailer.
+
+type retainedCheckpointStruct struct {
+	checkpointHeader         CheckpointHeaderStruct
+	checkpointTime           time.Time
+	deferredObjectDeleteList []delayedObjectDeleteStruct // objects no longer referenced by the checkpoint that superseded this one
+}
+
+// retentionTimeToTimeStamp encodes t (in nanoseconds since the Unix epoch) with zero indicating unknown.
+func retentionTimeToTimeStamp(t time.Time) (timeStamp uint64) {
+	if t.IsZero() {
+		timeStamp = 0
+	} else {
+		timeStamp = uint64(t.UnixNano())
+	}
+	return
+}
+
+func retentionTimeStampToTime(timeStamp uint64) (t time.Time) {
+	if 0 == timeStamp {
+		t = time.Time{}
+	} else {
+		t = time.Unix(0, int64(timeStamp))
+	}
+	return
+}
+
+// unpackRetentionTrailerWhileLocked consumes the (optional) CheckpointObjectTrailerRetentionStruct (and the
+// RetainedCheckpointList following it) from the remainder of a checkpointObjectTrailer.
+func (volume *volumeStruct) unpackRetentionTrailerWhileLocked(checkpointObjectTrailerBuf []byte) (remainingBuf []byte, err error) {
+	var (
+		bytesConsumed                   uint64
+		containerNameLength             uint64
+		deferredObjectDeleteListIndex   uint64
+		elementOfDeferredObjectDelete   ElementOfDeferredObjectDeleteListStruct
+		elementOfRetainedCheckpointList ElementOfRetainedCheckpointListStruct
+		retainedCheckpoint              *retainedCheckpointStruct
+		retainedCheckpointListIndex     uint64
+		retentionTrailer                CheckpointObjectTrailerRetentionStruct
+	)
+
+	remainingBuf = checkpointObjectTrailerBuf
+
+	if 0 == len(remainingBuf) {
+		err = nil
+		return
+	}
+
+	bytesConsumed, err = cstruct.Unpack(remainingBuf, &retentionTrailer, LittleEndian)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse volume %v's checkpointObjectTrailer's retention trailer: %v", volume.volumeName, err)
+		return
+	}
+	remainingBuf = remainingBuf[bytesConsumed:]
+
+	volume.checkpointTime = retentionTimeStampToTime(retentionTrailer.CheckpointTimeStamp)
+
+	for retainedCheckpointListIndex = 0; retainedCheckpointListIndex < retentionTrailer.RetainedCheckpointListNumElements; retainedCheckpointListIndex++ {
+		bytesConsumed, err = cstruct.Unpack(remainingBuf, &elementOfRetainedCheckpointList, LittleEndian)
+		if nil != err {
+			err = fmt.Errorf("Cannot parse volume %v's checkpointObjectTrailer's RetainedCheckpointList: %v", volume.volumeName, err)
+			return
+		}
+		remainingBuf = remainingBuf[bytesConsumed:]
+
+		retainedCheckpoint = &retainedCheckpointStruct{
+			checkpointHeader: CheckpointHeaderStruct{
+				CheckpointVersion:                         CheckpointVersion3,
+				CheckpointObjectTrailerStructObjectNumber: elementOfRetainedCheckpointList.CheckpointObjectTrailerStructObjectNumber,
+				CheckpointObjectTrailerStructObjectLength: elementOfRetainedCheckpointList.CheckpointObjectTrailerStructObjectLength,
+				ReservedToNonce:                           elementOfRetainedCheckpointList.ReservedToNonce,
+			},
+			checkpointTime:           retentionTimeStampToTime(elementOfRetainedCheckpointList.CheckpointTimeStamp),
+			deferredObjectDeleteList: make([]delayedObjectDeleteStruct, 0, elementOfRetainedCheckpointList.DeferredObjectDeleteListNumElements),
+		}
+
+		for deferredObjectDeleteListIndex = 0; deferredObjectDeleteListIndex < elementOfRetainedCheckpointList.DeferredObjectDeleteListNumElements; deferredObjectDeleteListIndex++ {
+			bytesConsumed, err = cstruct.Unpack(remainingBuf, &elementOfDeferredObjectDelete, LittleEndian)
+			if nil != err {
+				err = fmt.Errorf("Cannot parse volume %v's checkpointObjectTrailer's DeferredObjectDeleteList: %v", volume.volumeName, err)
+				return
+			}
+			remainingBuf = remainingBuf[bytesConsumed:]
+
+			containerNameLength = elementOfDeferredObjectDelete.ContainerNameLength
+			if uint64(len(remainingBuf)) < containerNameLength {
+				err = fmt.Errorf("Cannot parse volume %v's checkpointObjectTrailer's DeferredObjectDeleteList ContainerName", volume.volumeName)
+				return
+			}
+
+			retainedCheckpoint.deferredObjectDeleteList = append(retainedCheckpoint.deferredObjectDeleteList, delayedObjectDeleteStruct{
+				containerName: string(remainingBuf[:containerNameLength]),
+				objectNumber:  elementOfDeferredObjectDelete.ObjectNumber,
+			})
+			remainingBuf = remainingBuf[containerNameLength:]
+		}
+
+		volume.retainedCheckpointList = append(volume.retainedCheckpointList, retainedCheckpoint)
+	}
+
+	err = nil
+	return
+}
+
+// composeRetentionTrailerWhileLocked returns the CheckpointObjectTrailerRetentionStruct (and the
+// RetainedCheckpointList following it) to be appended to the checkpointObjectTrailer of the checkpoint
+// being taken at checkpointTime. The RetainedCheckpointList is that which will result once the current
+// checkpoint has been superseded. If superseded checkpoints are not being retained, retentionTrailerBuf
+// will be empty.
+func (volume *volumeStruct) composeRetentionTrailerWhileLocked(checkpointTime time.Time) (retentionTrailerBuf []byte, err error) {
+	var (
+		delayedObjectDelete             delayedObjectDeleteStruct
+		elementOfDeferredObjectDelete   ElementOfDeferredObjectDeleteListStruct
+		elementOfDeferredObjectBuf      []byte
+		elementOfRetainedCheckpointBuf  []byte
+		elementOfRetainedCheckpointList ElementOfRetainedCheckpointListStruct
+		retainedCheckpoint              *retainedCheckpointStruct
+		retainedCheckpointList          []*retainedCheckpointStruct
+		retentionTrailer                CheckpointObjectTrailerRetentionStruct
+	)
+
+	if 0 == volume.checkpointRetentionCount {
+		retentionTrailerBuf = make([]byte, 0)
+		err = nil
+		return
+	}
+
+	retainedCheckpointList = volume.retainedCheckpointList[:len(volume.retainedCheckpointList):len(volume.retainedCheckpointList)]
+
+	if 0 != volume.checkpointHeader.CheckpointObjectTrailerStructObjectNumber {
+		retainedCheckpointList = append(retainedCheckpointList, &retainedCheckpointStruct{
+			checkpointHeader:         *volume.checkpointHeader,
+			checkpointTime:           volume.checkpointTime,
+			deferredObjectDeleteList: volume.previewDelayedObjectDeleteListWhileLocked(),
+		})
+	}
+
+	if uint64(len(retainedCheckpointList)) > volume.checkpointRetentionCount {
+		retainedCheckpointList = retainedCheckpointList[uint64(len(retainedCheckpointList))-volume.checkpointRetentionCount:]
+	}
+
+	retentionTrailer.CheckpointTimeStamp = retentionTimeToTimeStamp(checkpointTime)
+	retentionTrailer.RetainedCheckpointListNumElements = uint64(len(retainedCheckpointList))
+
+	retentionTrailerBuf, err = cstruct.Pack(&retentionTrailer, LittleEndian)
+	if nil != err {
+		return
+	}
+
+	for _, retainedCheckpoint = range retainedCheckpointList {
+		elementOfRetainedCheckpointList.CheckpointObjectTrailerStructObjectNumber = retainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectNumber
+		elementOfRetainedCheckpointList.CheckpointObjectTrailerStructObjectLength = retainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectLength
+		elementOfRetainedCheckpointList.ReservedToNonce = retainedCheckpoint.checkpointHeader.ReservedToNonce
+		elementOfRetainedCheckpointList.CheckpointTimeStamp = retentionTimeToTimeStamp(retainedCheckpoint.checkpointTime)
+		elementOfRetainedCheckpointList.DeferredObjectDeleteListNumElements = uint64(len(retainedCheckpoint.deferredObjectDeleteList))
+
+		elementOfRetainedCheckpointBuf, err = cstruct.Pack(&elementOfRetainedCheckpointList, LittleEndian)
+		if nil != err {
+			return
+		}
+		retentionTrailerBuf = append(retentionTrailerBuf, elementOfRetainedCheckpointBuf...)
+
+		for _, delayedObjectDelete = range retainedCheckpoint.deferredObjectDeleteList {
+			elementOfDeferredObjectDelete.ObjectNumber = delayedObjectDelete.objectNumber
+			elementOfDeferredObjectDelete.ContainerNameLength = uint64(len(delayedObjectDelete.containerName))
+
+			elementOfDeferredObjectBuf, err = cstruct.Pack(&elementOfDeferredObjectDelete, LittleEndian)
+			if nil != err {
+				return
+			}
+			retentionTrailerBuf = append(retentionTrailerBuf, elementOfDeferredObjectBuf...)
+			retentionTrailerBuf = append(retentionTrailerBuf, delayedObjectDelete.containerName...)
+		}
+	}
+
+	err = nil
+	return
+}
+
+// previewDelayedObjectDeleteListWhileLocked returns (without disposing of any of them) the objects
+// putCheckpoint() will find to be no longer referenced once the checkpoint being taken is committed.
+// It mirrors the computation of delayedObjectDeleteList in putCheckpoint() such that the
+// RetainedCheckpointList persisted by that very checkpoint is complete.
+func (volume *volumeStruct) previewDelayedObjectDeleteListWhileLocked() (delayedObjectDeleteList []delayedObjectDeleteStruct) {
+	var (
+		bPlusTreeWrapper          *bPlusTreeWrapperStruct
+		bytesUsed                 uint64
+		combinedBPlusTreeLayout   sortedmap.LayoutReport
+		containerNameAsValue      sortedmap.Value
+		err                       error
+		index                     int
+		objectNumber              uint64
+		objectNumberAsKey         sortedmap.Key
+		ok                        bool
+		logSegmentObjectsToDelete int
+	)
+
+	combinedBPlusTreeLayout = make(sortedmap.LayoutReport)
+
+	if 0 != volume.checkpointHeader.CheckpointObjectTrailerStructObjectNumber {
+		combinedBPlusTreeLayout[volume.checkpointHeader.CheckpointObjectTrailerStructObjectNumber] = 0
+	}
+
+	for _, bPlusTreeWrapper = range []*bPlusTreeWrapperStruct{
+		volume.liveView.inodeRecWrapper,
+		volume.liveView.logSegmentRecWrapper,
+		volume.liveView.bPlusTreeObjectWrapper,
+		volume.liveView.dedupWrapper,
+		volume.liveView.createdObjectsWrapper,
+		volume.liveView.deletedObjectsWrapper,
+	} {
+		for objectNumber, bytesUsed = range bPlusTreeWrapper.bPlusTreeTracker.bPlusTreeLayout {
+			combinedBPlusTreeLayout[objectNumber] += bytesUsed
+		}
+	}
+
+	logSegmentObjectsToDelete, err = volume.liveView.deletedObjectsWrapper.bPlusTree.Len()
+	if nil != err {
+		logger.Fatalf("Logic error - volume.liveView.deletedObjectsWrapper.bPlusTree.Len() failed: %v", err)
+	}
+
+	delayedObjectDeleteList = make([]delayedObjectDeleteStruct, 0, len(combinedBPlusTreeLayout)+logSegmentObjectsToDelete)
+
+	for objectNumber, bytesUsed = range combinedBPlusTreeLayout {
+		if 0 != bytesUsed {
+			continue
+		}
+		if nil != volume.priorView {
+			// Unless created since the most recent SnapShot, it remains referenced by that SnapShot
+
+			_, ok, err = volume.priorView.createdObjectsWrapper.bPlusTree.GetByKey(objectNumber)
+			if nil != err {
+				logger.Fatalf("Logic error - volume.priorView.createdObjectsWrapper.bPlusTree.GetByKey(objectNumber==0x%016X) failed: %v", objectNumber, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		delayedObjectDeleteList = append(delayedObjectDeleteList, delayedObjectDeleteStruct{containerName: volume.checkpointContainerName, objectNumber: objectNumber})
+	}
+
+	for index = 0; index < logSegmentObjectsToDelete; index++ {
+		objectNumberAsKey, containerNameAsValue, ok, err = volume.liveView.deletedObjectsWrapper.bPlusTree.GetByIndex(index)
+		if nil != err {
+			logger.Fatalf("Logic error - volume.liveView.deletedObjectsWrapper.bPlusTree.GetByIndex(%v) failed: %v", index, err)
+		}
+		if !ok {
+			logger.Fatalf("Logic error - volume.liveView.deletedObjectsWrapper.bPlusTree.GetByIndex(%v) returned !ok", index)
+		}
+
+		delayedObjectDeleteList = append(delayedObjectDeleteList, delayedObjectDeleteStruct{containerName: string(containerNameAsValue.([]byte)), objectNumber: objectNumberAsKey.(uint64)})
+	}
+
+	return
+}
+
+// retainCheckpointWhileLocked is called by putCheckpoint() once priorCheckpointHeader has been superseded.
+// If superseded checkpoints are being retained, deletion of the objects in delayedObjectDeleteList is
+// deferred until priorCheckpointHeader's checkpoint is no longer retained. Returned are the objects
+// that may be deleted now (including those deferred by checkpoints aging out of the retention window).
+func (volume *volumeStruct) retainCheckpointWhileLocked(priorCheckpointHeader CheckpointHeaderStruct, priorCheckpointTime time.Time, delayedObjectDeleteList []delayedObjectDeleteStruct) (objectDeleteList []delayedObjectDeleteStruct) {
+	if (0 < volume.checkpointRetentionCount) && (0 != priorCheckpointHeader.CheckpointObjectTrailerStructObjectNumber) {
+		volume.retainedCheckpointList = append(volume.retainedCheckpointList, &retainedCheckpointStruct{
+			checkpointHeader:         priorCheckpointHeader,
+			checkpointTime:           priorCheckpointTime,
+			deferredObjectDeleteList: delayedObjectDeleteList,
+		})
+		objectDeleteList = make([]delayedObjectDeleteStruct, 0)
+	} else {
+		objectDeleteList = delayedObjectDeleteList
+	}
+
+	for uint64(len(volume.retainedCheckpointList)) > volume.checkpointRetentionCount {
+		objectDeleteList = append(objectDeleteList, volume.retainedCheckpointList[0].deferredObjectDeleteList...)
+		volume.retainedCheckpointList = volume.retainedCheckpointList[1:]
+	}
+
+	return
+}
+
+// fetchRetainedCheckpointLayoutReportWhileLocked returns the Checkpoint Container objects no longer
+// referenced by the live view but upon which retained checkpoints depend (i.e. their checkpointObjectTrailers
+// and the B+Tree nodes they alone reference). As only the checkpointObjectTrailers' sizes are known, the
+// remaining objects are reported as holding zero bytes.
+func (volume *volumeStruct) fetchRetainedCheckpointLayoutReportWhileLocked() (layoutReport sortedmap.LayoutReport) {
+	var (
+		delayedObjectDelete delayedObjectDeleteStruct
+		ok                  bool
+		retainedCheckpoint  *retainedCheckpointStruct
+	)
+
+	layoutReport = make(sortedmap.LayoutReport)
+
+	for _, retainedCheckpoint = range volume.retainedCheckpointList {
+		for _, delayedObjectDelete = range retainedCheckpoint.deferredObjectDeleteList {
+			if volume.checkpointContainerName == delayedObjectDelete.containerName {
+				_, ok = layoutReport[delayedObjectDelete.objectNumber]
+				if !ok {
+					layoutReport[delayedObjectDelete.objectNumber] = 0
+				}
+			}
+		}
+
+		layoutReport[retainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectNumber] = retainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectLength
+	}
+
+	return
+}
+
+// RetainedCheckpointList returns the retained checkpoints (oldest first).
+func (volume *volumeStruct) RetainedCheckpointList() (list []RetainedCheckpointStruct) {
+	var (
+		retainedCheckpoint *retainedCheckpointStruct
+	)
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	list = make([]RetainedCheckpointStruct, 0, len(volume.retainedCheckpointList))
+
+	for _, retainedCheckpoint = range volume.retainedCheckpointList {
+		list = append(list, RetainedCheckpointStruct{
+			ID:   retainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectNumber,
+			Time: retainedCheckpoint.checkpointTime,
+		})
+	}
+
+	return
+}
+
+// InspectedRetainedCheckpoint returns the ID of the retained checkpoint currently presented
+// (read-only) in place of the live view... or zero if the live view is being presented.
+func (volume *volumeStruct) InspectedRetainedCheckpoint() (id uint64) {
+	id = atomic.LoadUint64(&volume.inspectedRetainedCheckpointID)
+	return
+}
+
+func (volume *volumeStruct) findRetainedCheckpointWhileLocked(id uint64) (index int, err error) {
+	for index = range volume.retainedCheckpointList {
+		if id == volume.retainedCheckpointList[index].checkpointHeader.CheckpointObjectTrailerStructObjectNumber {
+			err = nil
+			return
+		}
+	}
+
+	err = fmt.Errorf("Retained checkpoint ID %v not found in volume \"%v\"", id, volume.volumeName)
+	return
+}
+
+func (volume *volumeStruct) fetchRetainedCheckpointTrailerWhileLocked(retainedCheckpoint *retainedCheckpointStruct) (checkpointObjectTrailer *CheckpointObjectTrailerV3Struct, err error) {
+	var (
+		checkpointObjectTrailerBuf []byte
+	)
+
+	checkpointObjectTrailerBuf, err =
+		swiftclient.ObjectTail(
+			volume.accountName,
+			volume.checkpointContainerName,
+			utils.Uint64ToHexStr(retainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectNumber),
+			retainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectLength)
+	if nil != err {
+		return
+	}
+
+	checkpointObjectTrailer = &CheckpointObjectTrailerV3Struct{}
+
+	_, err = cstruct.Unpack(checkpointObjectTrailerBuf, checkpointObjectTrailer, LittleEndian)
+
+	return
+}
+
+// putCheckpointOrShutdownWhileLocked is used by operations that cannot be expressed in the Replay Log.
+func (volume *volumeStruct) putCheckpointOrShutdownWhileLocked() {
+	var (
+		err error
+	)
+
+	volume.checkpointTriggeringEvents++
+
+	evtlog.Record(evtlog.FormatHeadhunterCheckpointStart, volume.volumeName)
+	err = volume.putCheckpoint()
+	if nil != err {
+		evtlog.Record(evtlog.FormatHeadhunterCheckpointEndFailure, volume.volumeName, err.Error())
+		logger.FatalfWithError(err, "Shutting down to prevent subsequent checkpoints from corrupting Swift")
+	}
+	evtlog.Record(evtlog.FormatHeadhunterCheckpointEndSuccess, volume.volumeName)
+}
+
+// RetainedCheckpointInspectByInodeLayer presents (read-only) the state captured by the retained checkpoint
+// identified by id in place of the live view. The live view is first checkpointed and is restored by
+// passing an id of zero. While a retained checkpoint is being inspected, no checkpoints are taken. Package
+// inode must have flushed and discarded all of its cached state prior to calling this.
+func (volume *volumeStruct) RetainedCheckpointInspectByInodeLayer(id uint64) (err error) {
+	var (
+		checkpointObjectTrailer *CheckpointObjectTrailerV3Struct
+		index                   int
+		inspectedView           *volumeViewStruct
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.RetainedCheckpointInspectUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.RetainedCheckpointInspectErrors.Add(1)
+		}
+	}()
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	if 0 == id {
+		if nil == volume.inspectedRetainedCheckpoint {
+			err = fmt.Errorf("Volume \"%v\" is not inspecting a retained checkpoint", volume.volumeName)
+			return
+		}
+
+		volume.pruneBPlusTreeWhileLocked(volume.liveView.inodeRecWrapper.bPlusTree)
+		volume.pruneBPlusTreeWhileLocked(volume.liveView.logSegmentRecWrapper.bPlusTree)
+		volume.pruneBPlusTreeWhileLocked(volume.liveView.bPlusTreeObjectWrapper.bPlusTree)
+
+		volume.liveView = volume.inspectedLiveView
+		volume.inspectedLiveView = nil
+		volume.inspectedRetainedCheckpoint = nil
+		atomic.StoreUint64(&volume.inspectedRetainedCheckpointID, 0)
+
+		err = nil
+		return
+	}
+
+	if nil != volume.inspectedRetainedCheckpoint {
+		err = fmt.Errorf("Volume \"%v\" is already inspecting retained checkpoint ID %v", volume.volumeName, volume.inspectedRetainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectNumber)
+		return
+	}
+
+	// Ensure all live view B+Tree modifications are persisted before it is set aside
+	// (noting this may cause the oldest retained checkpoint to age out)
+
+	volume.putCheckpointOrShutdownWhileLocked()
+
+	index, err = volume.findRetainedCheckpointWhileLocked(id)
+	if nil != err {
+		return
+	}
+
+	checkpointObjectTrailer, err = volume.fetchRetainedCheckpointTrailerWhileLocked(volume.retainedCheckpointList[index])
+	if nil != err {
+		return
+	}
+
+	// Only the B+Trees describing the file system are taken from the retained checkpoint
+
+	inspectedView = &volumeViewStruct{
+		volume:                volume,
+		dedupWrapper:          volume.liveView.dedupWrapper,
+		createdObjectsWrapper: volume.liveView.createdObjectsWrapper,
+		deletedObjectsWrapper: volume.liveView.deletedObjectsWrapper,
+	}
+
+	inspectedView.inodeRecWrapper, err = volume.newRetainedBPlusTreeWrapperWhileLocked(
+		inspectedView,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectLength,
+		volume.maxInodesPerMetadataNode,
+		volume.inodeRecCache.cache)
+	if nil != err {
+		return
+	}
+
+	inspectedView.logSegmentRecWrapper, err = volume.newRetainedBPlusTreeWrapperWhileLocked(
+		inspectedView,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectLength,
+		volume.maxLogSegmentsPerMetadataNode,
+		volume.logSegmentRecCache.cache)
+	if nil != err {
+		volume.pruneBPlusTreeWhileLocked(inspectedView.inodeRecWrapper.bPlusTree)
+		return
+	}
+
+	inspectedView.bPlusTreeObjectWrapper, err = volume.newRetainedBPlusTreeWrapperWhileLocked(
+		inspectedView,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectNumber,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectOffset,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectLength,
+		volume.maxDirFileNodesPerMetadataNode,
+		volume.bPlusTreeObjectCache.cache)
+	if nil != err {
+		volume.pruneBPlusTreeWhileLocked(inspectedView.inodeRecWrapper.bPlusTree)
+		volume.pruneBPlusTreeWhileLocked(inspectedView.logSegmentRecWrapper.bPlusTree)
+		return
+	}
+
+	volume.inspectedLiveView = volume.liveView
+	volume.liveView = inspectedView
+	volume.inspectedRetainedCheckpoint = volume.retainedCheckpointList[index]
+	atomic.StoreUint64(&volume.inspectedRetainedCheckpointID, volume.inspectedRetainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectNumber)
+
+	err = nil
+	return
+}
+
+// newRetainedBPlusTreeWrapperWhileLocked returns a bPlusTreeWrapperStruct for volumeView whose B+Tree
+// is rooted at the specified location in a retained checkpoint.
+func (volume *volumeStruct) newRetainedBPlusTreeWrapperWhileLocked(volumeView *volumeViewStruct, rootObjectNumber uint64, rootObjectOffset uint64, rootObjectLength uint64, maxKeysPerNode uint64, cache sortedmap.BPlusTreeCache) (bPlusTreeWrapper *bPlusTreeWrapperStruct, err error) {
+	bPlusTreeWrapper = &bPlusTreeWrapperStruct{
+		volumeView:       volumeView,
+		bPlusTreeTracker: &bPlusTreeTrackerStruct{bPlusTreeLayout: make(sortedmap.LayoutReport)},
+	}
+
+	bPlusTreeWrapper.bPlusTree, err = volume.loadBPlusTreeWhileLocked(bPlusTreeWrapper, rootObjectNumber, rootObjectOffset, rootObjectLength, maxKeysPerNode, cache)
+
+	return
+}
+
+func (volume *volumeStruct) pruneBPlusTreeWhileLocked(bPlusTree sortedmap.BPlusTree) {
+	var (
+		err error
+	)
+
+	err = bPlusTree.Prune()
+	if nil != err {
+		logger.Fatalf("Logic error - bPlusTree.Prune() for volume %v failed with error: %v", volume.volumeName, err)
+	}
+}
+
+// RetainedCheckpointRevertByInodeLayer makes the state captured by the retained checkpoint identified by id
+// the new live view. As SnapShots track objects relative to the live view, none may exist. Objects no longer
+// referenced by the reverted live view are retained (as part of the checkpoint taken just prior to the
+// revert) subject to the usual retention window, such that the revert itself may be reverted. A checkpoint
+// is taken both before and after the revert as the Replay Log cannot express it. Package inode must have
+// flushed and discarded all of its cached state prior to calling this.
+func (volume *volumeStruct) RetainedCheckpointRevertByInodeLayer(id uint64) (err error) {
+	var (
+		checkpointObjectTrailer          *CheckpointObjectTrailerV3Struct
+		containerNameAsValue             sortedmap.Value
+		delayedObjectDelete              delayedObjectDeleteStruct
+		deferredObjectDeleteList         []delayedObjectDeleteStruct
+		index                            int
+		logSegmentNumberAsKey            sortedmap.Key
+		logSegmentRecIndex               int
+		ok                               bool
+		referenced                       bool
+		retainedCheckpoint               *retainedCheckpointStruct
+		retainedInodeRecBPlusTree        sortedmap.BPlusTree
+		retainedLogSegmentRecBPlusTree   sortedmap.BPlusTree
+		retainedBPlusTreeObjectBPlusTree sortedmap.BPlusTree
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.RetainedCheckpointRevertUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if err != nil {
+			globals.RetainedCheckpointRevertErrors.Add(1)
+		}
+	}()
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	if nil != volume.inspectedRetainedCheckpoint {
+		err = fmt.Errorf("Volume \"%v\" is inspecting retained checkpoint ID %v", volume.volumeName, volume.inspectedRetainedCheckpoint.checkpointHeader.CheckpointObjectTrailerStructObjectNumber)
+		return
+	}
+	if nil != volume.priorView {
+		err = fmt.Errorf("Volume \"%v\" has SnapShots that must first be deleted", volume.volumeName)
+		return
+	}
+
+	// Ensure all live view B+Tree modifications are persisted and liveView deletedObjects is drained
+	// (noting this may cause the oldest retained checkpoint to age out)
+
+	volume.putCheckpointOrShutdownWhileLocked()
+
+	index, err = volume.findRetainedCheckpointWhileLocked(id)
+	if nil != err {
+		return
+	}
+
+	checkpointObjectTrailer, err = volume.fetchRetainedCheckpointTrailerWhileLocked(volume.retainedCheckpointList[index])
+	if nil != err {
+		return
+	}
+
+	// Load all of the retained checkpoint's B+Trees before modifying anything
+
+	retainedInodeRecBPlusTree, err = volume.loadBPlusTreeWhileLocked(
+		volume.liveView.inodeRecWrapper,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectLength,
+		volume.maxInodesPerMetadataNode,
+		volume.inodeRecCache.cache)
+	if nil != err {
+		return
+	}
+
+	retainedLogSegmentRecBPlusTree, err = volume.loadBPlusTreeWhileLocked(
+		volume.liveView.logSegmentRecWrapper,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectLength,
+		volume.maxLogSegmentsPerMetadataNode,
+		volume.logSegmentRecCache.cache)
+	if nil != err {
+		volume.pruneBPlusTreeWhileLocked(retainedInodeRecBPlusTree)
+		return
+	}
+
+	retainedBPlusTreeObjectBPlusTree, err = volume.loadBPlusTreeWhileLocked(
+		volume.liveView.bPlusTreeObjectWrapper,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectNumber,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectOffset,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectLength,
+		volume.maxDirFileNodesPerMetadataNode,
+		volume.bPlusTreeObjectCache.cache)
+	if nil != err {
+		volume.pruneBPlusTreeWhileLocked(retainedInodeRecBPlusTree)
+		volume.pruneBPlusTreeWhileLocked(retainedLogSegmentRecBPlusTree)
+		return
+	}
+
+	volume.checkpointTriggeringEvents++
+
+	// LogSegments referenced by the live view but not the retained checkpoint are no longer referenced
+	// (Checkpoint Objects are disposed of via bPlusTreeLayout tracking in putCheckpoint())
+
+	for logSegmentRecIndex = 0; ; logSegmentRecIndex++ {
+		logSegmentNumberAsKey, containerNameAsValue, ok, err = volume.liveView.logSegmentRecWrapper.bPlusTree.GetByIndex(logSegmentRecIndex)
+		if nil != err {
+			logger.Fatalf("Logic error - liveView.logSegmentRecWrapper.bPlusTree.GetByIndex(%v) failed with error: %v", logSegmentRecIndex, err)
+		}
+		if !ok {
+			break
+		}
+
+		_, ok, err = retainedLogSegmentRecBPlusTree.GetByKey(logSegmentNumberAsKey)
+		if nil != err {
+			logger.Fatalf("Logic error - retainedLogSegmentRecBPlusTree.GetByKey() failed with error: %v", err)
+		}
+		if ok {
+			continue
+		}
+
+		ok, err = volume.liveView.deletedObjectsWrapper.bPlusTree.Put(logSegmentNumberAsKey, containerNameAsValue)
+		if nil != err {
+			logger.Fatalf("Logic error - liveView.deletedObjectsWrapper.bPlusTree.Put() failed with error: %v", err)
+		}
+		if !ok {
+			logger.Fatalf("Logic error - liveView.deletedObjectsWrapper.bPlusTree.Put() returned ok == false")
+		}
+	}
+
+	volume.installRevertedBPlusTreeWhileLocked(volume.liveView.inodeRecWrapper, retainedInodeRecBPlusTree)
+	volume.installRevertedBPlusTreeWhileLocked(volume.liveView.logSegmentRecWrapper, retainedLogSegmentRecBPlusTree)
+	volume.installRevertedBPlusTreeWhileLocked(volume.liveView.bPlusTreeObjectWrapper, retainedBPlusTreeObjectBPlusTree)
+
+	// The dedup B+Tree is not restored... so its reference counts must be rebuilt
+
+	if volume.dedupInUseWhileLocked() {
+		volume.dedupRebuildRequired = true
+	}
+
+	// Objects deferred by the reverted to (and any newer) retained checkpoint that are once again
+	// referenced must no longer be deleted once those checkpoints age out
+
+	for _, retainedCheckpoint = range volume.retainedCheckpointList[index:] {
+		deferredObjectDeleteList = make([]delayedObjectDeleteStruct, 0, len(retainedCheckpoint.deferredObjectDeleteList))
+
+		for _, delayedObjectDelete = range retainedCheckpoint.deferredObjectDeleteList {
+			if volume.checkpointContainerName == delayedObjectDelete.containerName {
+				referenced = volume.liveViewReferencesCheckpointObjectWhileLocked(delayedObjectDelete.objectNumber)
+			} else {
+				_, referenced, err = volume.liveView.logSegmentRecWrapper.bPlusTree.GetByKey(delayedObjectDelete.objectNumber)
+				if nil != err {
+					logger.Fatalf("Logic error - liveView.logSegmentRecWrapper.bPlusTree.GetByKey() failed with error: %v", err)
+				}
+			}
+			if !referenced {
+				deferredObjectDeleteList = append(deferredObjectDeleteList, delayedObjectDelete)
+			}
+		}
+
+		retainedCheckpoint.deferredObjectDeleteList = deferredObjectDeleteList
+	}
+
+	volume.putCheckpointOrShutdownWhileLocked()
+
+	err = nil
+	return
+}
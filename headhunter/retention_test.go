@@ -0,0 +1,289 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package headhunter
+
+import (
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/ramswift"
+	"github.com/NVIDIA/proxyfs/transitions"
+)
+
+func retainedCheckpointInodeRecGet(t *testing.T, volume VolumeHandle, key uint64, expectedValue string) {
+	var (
+		err   error
+		ok    bool
+		value []byte
+	)
+
+	value, ok, err = volume.GetInodeRec(key)
+	if (nil != err) || !ok || (expectedValue != string(value)) {
+		t.Fatalf("GetInodeRec(%d) returned unexpected value (%s), ok (%v), or err (%v) [expected %s]", key, value, ok, err, expectedValue)
+	}
+}
+
+func TestHeadHunterRetainedCheckpoints(t *testing.T) {
+	var (
+		confMap                           conf.ConfMap
+		confStrings                       []string
+		doneChan                          chan bool
+		err                               error
+		expectedRetainedCheckpointListLen int
+		expectedValueMap                  map[uint64]string
+		inspectedID                       uint64
+		key                               uint64
+		retainedCheckpoint                RetainedCheckpointStruct
+		retainedCheckpointList            []RetainedCheckpointStruct
+		signalHandlerIsArmedWG            sync.WaitGroup
+		value                             string
+		valueIndex                        int
+		valueList                         []string
+		volume                            VolumeHandle
+	)
+
+	confStrings = []string{
+		"Logging.LogFilePath=/dev/null",
+		"Stats.IPAddr=localhost",
+		"Stats.UDPPort=52184",
+		"Stats.BufferLength=100",
+		"Stats.MaxLatency=1s",
+		"SwiftClient.NoAuthIPAddr=127.0.0.1",
+		"SwiftClient.NoAuthTCPPort=9999",
+		"SwiftClient.Timeout=10s",
+		"SwiftClient.RetryLimit=0",
+		"SwiftClient.RetryLimitObject=0",
+		"SwiftClient.RetryDelay=1s",
+		"SwiftClient.RetryDelayObject=1s",
+		"SwiftClient.RetryExpBackoff=1.2",
+		"SwiftClient.RetryExpBackoffObject=2.0",
+		"SwiftClient.ChunkedConnectionPoolSize=64",
+		"SwiftClient.NonChunkedConnectionPoolSize=32",
+		"Cluster.WhoAmI=Peer0",
+		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
+		"Volume:TestVolume.AccountName=TestAccount",
+		"Volume:TestVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:TestVolume.CheckpointInterval=10h", // We never want a time-based checkpoint
+		"Volume:TestVolume.CheckpointRetentionCount=3",
+		"Volume:TestVolume.MaxFlushSize=10000000",
+		"Volume:TestVolume.NonceValuesToReserve=100",
+		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
+		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume",
+		"VolumeGroup:TestVolumeGroup.VirtualIPAddr=",
+		"VolumeGroup:TestVolumeGroup.PrimaryPeer=Peer0",
+		"FSGlobals.VolumeGroupList=TestVolumeGroup",
+		"FSGlobals.CheckpointHeaderConsensusAttempts=5",
+		"FSGlobals.MountRetryLimit=6",
+		"FSGlobals.MountRetryDelay=1s",
+		"FSGlobals.MountRetryExpBackoff=2",
+		"FSGlobals.LogCheckpointHeaderPosts=true",
+		"FSGlobals.TryLockBackoffMin=10ms",
+		"FSGlobals.TryLockBackoffMax=50ms",
+		"FSGlobals.TryLockSerializationThreshhold=5",
+		"FSGlobals.SymlinkMax=32",
+		"FSGlobals.CoalesceElementChunkSize=16",
+		"FSGlobals.InodeRecCacheEvictLowLimit=10000",
+		"FSGlobals.InodeRecCacheEvictHighLimit=10010",
+		"FSGlobals.LogSegmentRecCacheEvictLowLimit=10000",
+		"FSGlobals.LogSegmentRecCacheEvictHighLimit=10010",
+		"FSGlobals.BPlusTreeObjectCacheEvictLowLimit=10000",
+		"FSGlobals.BPlusTreeObjectCacheEvictHighLimit=10010",
+		"FSGlobals.EtcdEnabled=false",
+		"RamSwiftInfo.MaxAccountNameLength=256",
+		"RamSwiftInfo.MaxContainerNameLength=256",
+		"RamSwiftInfo.MaxObjectNameLength=1024",
+		"RamSwiftInfo.AccountListingLimit=10000",
+		"RamSwiftInfo.ContainerListingLimit=10000",
+	}
+
+	// Launch a ramswift instance
+
+	signalHandlerIsArmedWG.Add(1)
+	doneChan = make(chan bool, 1) // Must be buffered to avoid race
+
+	go ramswift.Daemon("/dev/null", confStrings, &signalHandlerIsArmedWG, doneChan, unix.SIGTERM)
+
+	signalHandlerIsArmedWG.Wait()
+
+	confMap, err = conf.MakeConfMapFromStrings(confStrings)
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings(confStrings) returned error: %v", err)
+	}
+
+	// Schedule a Format of TestVolume on first Up()
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=true")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=true\") returned error: %v", err)
+	}
+
+	// Up packages (TestVolume will be formatted)
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 1] returned error: %v", err)
+	}
+
+	// Unset AutoFormat for all subsequent uses of ConfMap
+
+	err = confMap.UpdateFromString("Volume:TestVolume.AutoFormat=false")
+	if nil != err {
+		t.Fatalf("conf.UpdateFromString(\"Volume:TestVolume.AutoFormat=false\") returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 1] returned error: %v", err)
+	}
+
+	// Checkpoint a sequence of values, noting which retained checkpoint captured each one
+
+	key = 1234
+	valueList = []string{"A", "B", "C", "D", "E"}
+	expectedValueMap = make(map[uint64]string)
+
+	for valueIndex, value = range valueList {
+		inodeRecPutGet(t, volume, key, []byte(value))
+
+		err = volume.DoCheckpoint()
+		if nil != err {
+			t.Fatalf("DoCheckpoint() returned error: %v", err)
+		}
+
+		retainedCheckpointList = volume.RetainedCheckpointList()
+
+		// Note that the checkpoint of a freshly formatted volume (with no trailer) is not retained
+
+		expectedRetainedCheckpointListLen = valueIndex
+		if 3 < expectedRetainedCheckpointListLen {
+			expectedRetainedCheckpointListLen = 3
+		}
+		if expectedRetainedCheckpointListLen != len(retainedCheckpointList) {
+			t.Fatalf("RetainedCheckpointList() returned %d elements (expected %d)", len(retainedCheckpointList), expectedRetainedCheckpointListLen)
+		}
+
+		for _, retainedCheckpoint = range retainedCheckpointList {
+			if retainedCheckpoint.Time.IsZero() {
+				t.Fatalf("RetainedCheckpointList() returned an element with a zero Time")
+			}
+		}
+
+		if 0 < valueIndex {
+			expectedValueMap[retainedCheckpointList[len(retainedCheckpointList)-1].ID] = valueList[valueIndex-1]
+		}
+	}
+
+	// Verify the RetainedCheckpointList survives a remount
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 1] returned error: %v", err)
+	}
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Up() [case 2] returned error: %v", err)
+	}
+
+	volume, err = FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") [case 2] returned error: %v", err)
+	}
+
+	retainedCheckpointList = volume.RetainedCheckpointList()
+	if 3 != len(retainedCheckpointList) {
+		t.Fatalf("RetainedCheckpointList() after remount returned %d elements (expected 3)", len(retainedCheckpointList))
+	}
+
+	retainedCheckpointInodeRecGet(t, volume, key, "E")
+
+	// Inspect the retained checkpoint that captured "D", then resume the live view
+
+	inspectedID = 0
+	for _, retainedCheckpoint = range retainedCheckpointList {
+		if "D" == expectedValueMap[retainedCheckpoint.ID] {
+			inspectedID = retainedCheckpoint.ID
+		}
+	}
+	if 0 == inspectedID {
+		t.Fatalf("RetainedCheckpointList() after remount no longer contains the checkpoint that captured \"D\"")
+	}
+
+	err = volume.RetainedCheckpointInspectByInodeLayer(0)
+	if nil == err {
+		t.Fatalf("RetainedCheckpointInspectByInodeLayer(0) while not inspecting should have failed")
+	}
+
+	err = volume.RetainedCheckpointInspectByInodeLayer(inspectedID)
+	if nil != err {
+		t.Fatalf("RetainedCheckpointInspectByInodeLayer(%d) returned error: %v", inspectedID, err)
+	}
+
+	if inspectedID != volume.InspectedRetainedCheckpoint() {
+		t.Fatalf("InspectedRetainedCheckpoint() returned %d (expected %d)", volume.InspectedRetainedCheckpoint(), inspectedID)
+	}
+
+	retainedCheckpointInodeRecGet(t, volume, key, "D")
+
+	err = volume.RetainedCheckpointRevertByInodeLayer(inspectedID)
+	if nil == err {
+		t.Fatalf("RetainedCheckpointRevertByInodeLayer() while inspecting should have failed")
+	}
+
+	err = volume.RetainedCheckpointInspectByInodeLayer(0)
+	if nil != err {
+		t.Fatalf("RetainedCheckpointInspectByInodeLayer(0) returned error: %v", err)
+	}
+
+	if 0 != volume.InspectedRetainedCheckpoint() {
+		t.Fatalf("InspectedRetainedCheckpoint() returned %d after resuming (expected 0)", volume.InspectedRetainedCheckpoint())
+	}
+
+	retainedCheckpointInodeRecGet(t, volume, key, "E")
+
+	// Revert to the retained checkpoint that captured "D", then revert that revert
+
+	err = volume.RetainedCheckpointRevertByInodeLayer(inspectedID)
+	if nil != err {
+		t.Fatalf("RetainedCheckpointRevertByInodeLayer(%d) returned error: %v", inspectedID, err)
+	}
+
+	retainedCheckpointInodeRecGet(t, volume, key, "D")
+
+	retainedCheckpointList = volume.RetainedCheckpointList()
+	if 0 == len(retainedCheckpointList) {
+		t.Fatalf("RetainedCheckpointList() after revert returned no elements")
+	}
+
+	err = volume.RetainedCheckpointRevertByInodeLayer(retainedCheckpointList[len(retainedCheckpointList)-1].ID)
+	if nil != err {
+		t.Fatalf("RetainedCheckpointRevertByInodeLayer() of the pre-revert checkpoint returned error: %v", err)
+	}
+
+	retainedCheckpointInodeRecGet(t, volume, key, "E")
+
+	err = volume.RetainedCheckpointRevertByInodeLayer(1)
+	if nil == err {
+		t.Fatalf("RetainedCheckpointRevertByInodeLayer(1) should have failed")
+	}
+
+	// Shutdown packages
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		t.Fatalf("transitions.Down() [case 2] returned error: %v", err)
+	}
+
+	// Send ourself a SIGTERM to terminate ramswift.Daemon()
+
+	unix.Kill(unix.Getpid(), unix.SIGTERM)
+
+	_ = <-doneChan
+}
@@ -64,13 +64,29 @@ func (bPlusTreeWrapper *bPlusTreeWrapperStruct) GetNode(objectNumber uint64, obj
 	stats.IncrementOperations(&stats.HeadhunterBPlusTreeNodeFaults)
 	evtlog.Record(evtlog.FormatHeadhunterBPlusTreeNodeFault, bPlusTreeWrapper.volumeView.volume.volumeName, objectNumber, objectOffset, objectLength)
 
-	nodeByteSlice, err =
-		swiftclient.ObjectGet(
-			bPlusTreeWrapper.volumeView.volume.accountName,
-			bPlusTreeWrapper.volumeView.volume.checkpointContainerName,
-			utils.Uint64ToHexStr(objectNumber),
-			objectOffset,
-			objectLength)
+	if objectNumber < bPlusTreeWrapper.volumeView.volume.cloneOriginNonce {
+		// Node is shared with the volume this volume was cloned from
+
+		nodeByteSlice, err =
+			swiftclient.ObjectGet(
+				bPlusTreeWrapper.volumeView.volume.cloneOriginAccountName,
+				bPlusTreeWrapper.volumeView.volume.cloneOriginCheckpointContainerName,
+				utils.Uint64ToHexStr(objectNumber),
+				objectOffset,
+				objectLength)
+	} else {
+		nodeByteSlice, err =
+			swiftclient.ObjectGet(
+				bPlusTreeWrapper.volumeView.volume.accountName,
+				bPlusTreeWrapper.volumeView.volume.checkpointContainerName,
+				utils.Uint64ToHexStr(objectNumber),
+				objectOffset,
+				objectLength)
+	}
+
+	if nil == err {
+		bPlusTreeWrapper.observeNodeSize(uint64(len(nodeByteSlice)))
+	}
 
 	return
 }
@@ -85,6 +101,8 @@ func (bPlusTreeWrapper *bPlusTreeWrapperStruct) PutNode(nodeByteSlice []byte) (o
 	bPlusTreeWrapper.totalPutNodes++
 	bPlusTreeWrapper.totalPutBytes += uint64(len(nodeByteSlice))
 
+	bPlusTreeWrapper.observeNodeSize(uint64(len(nodeByteSlice)))
+
 	err = bPlusTreeWrapper.volumeView.volume.openCheckpointChunkedPutContextIfNecessary()
 	if nil != err {
 		return
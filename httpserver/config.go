@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -49,6 +50,14 @@ const (
 	limitJobType
 )
 
+const snapShotDiffDefaultMaxEntries = uint64(1024)
+
+const (
+	changeEventsMaxEventsPerFetch = uint64(1024)
+	changeEventsKeepAliveInterval = 15 * time.Second
+	changeEventsMaxStreamDuration = 5 * time.Minute
+)
+
 type jobStruct struct {
 	id        uint64
 	volume    *volumeStruct
@@ -69,29 +78,34 @@ type JobStatusJSONPackedStruct struct {
 
 type volumeStruct struct {
 	trackedlock.Mutex
-	name                       string
-	fsVolumeHandle             fs.VolumeHandle
-	inodeVolumeHandle          inode.VolumeHandle
-	headhunterVolumeHandle     headhunter.VolumeHandle
-	fsckActiveJob              *jobStruct
-	fsckJobs                   sortedmap.LLRBTree // Key == jobStruct.id, Value == *jobStruct
-	scrubActiveJob             *jobStruct
-	scrubJobs                  sortedmap.LLRBTree // Key == jobStruct.id, Value == *jobStruct
-	activeDefragInodeNumberSet map[inode.InodeNumber]struct{}
+	name                              string
+	accountName                       string
+	fsVolumeHandle                    fs.VolumeHandle
+	inodeVolumeHandle                 inode.VolumeHandle
+	headhunterVolumeHandle            headhunter.VolumeHandle
+	fsckActiveJob                     *jobStruct
+	fsckJobs                          sortedmap.LLRBTree // Key == jobStruct.id, Value == *jobStruct
+	scrubActiveJob                    *jobStruct
+	scrubJobs                         sortedmap.LLRBTree // Key == jobStruct.id, Value == *jobStruct
+	activeDefragInodeNumberSet        map[inode.InodeNumber]struct{}
+	activeLayoutMigrateInodeNumberSet map[inode.InodeNumber]struct{}
 }
 
 type globalsStruct struct {
 	trackedlock.Mutex
-	active            bool
-	jobHistoryMaxSize uint32
-	whoAmI            string
-	ipAddr            string
-	tcpPort           uint16
-	ipAddrTCPPort     string
-	netListener       net.Listener
-	wg                sync.WaitGroup
-	confMap           conf.ConfMap
-	volumeLLRB        sortedmap.LLRBTree // Key == volumeStruct.name, Value == *volumeStruct
+	active                      bool
+	jobHistoryMaxSize           uint32
+	fsAPIEnabled                bool
+	fsAPIAuthTokenValidationURL string // must be != "" if fsAPIEnabled
+	fsAPIPutTempFileNonce       uint64 // atomically incremented to name the temporary file of each full fs API PUT
+	whoAmI                      string
+	ipAddr                      string
+	tcpPort                     uint16
+	ipAddrTCPPort               string
+	netListener                 net.Listener
+	wg                          sync.WaitGroup
+	confMap                     conf.ConfMap
+	volumeLLRB                  sortedmap.LLRBTree // Key == volumeStruct.name, Value == *volumeStruct
 }
 
 var globals globalsStruct
@@ -113,6 +127,22 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 		globals.jobHistoryMaxSize = 5
 	}
 
+	globals.fsAPIEnabled, err = confMap.FetchOptionValueBool("HTTPServer", "FSAPIEnabled")
+	if nil != err {
+		globals.fsAPIEnabled = false // Default to not exposing the fs API if not present
+	}
+
+	globals.fsAPIAuthTokenValidationURL, err = confMap.FetchOptionValueString("HTTPServer", "FSAPIAuthTokenValidationURL")
+	if nil != err {
+		globals.fsAPIAuthTokenValidationURL = "" // Default to no means to authenticate fs API requests if not present
+	}
+	globals.fsAPIAuthTokenValidationURL = strings.TrimRight(globals.fsAPIAuthTokenValidationURL, "/")
+
+	if globals.fsAPIEnabled && ("" == globals.fsAPIAuthTokenValidationURL) {
+		err = fmt.Errorf("HTTPServer.FSAPIEnabled requires HTTPServer.FSAPIAuthTokenValidationURL")
+		return
+	}
+
 	globals.whoAmI, err = confMap.FetchOptionValueString("Cluster", "WhoAmI")
 	if nil != err {
 		err = fmt.Errorf("confMap.FetchOptionValueString(\"Cluster\", \"WhoAmI\") failed: %v", err)
@@ -174,12 +204,18 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 	)
 
 	volume = &volumeStruct{
-		name:                       volumeName,
-		fsckActiveJob:              nil,
-		fsckJobs:                   sortedmap.NewLLRBTree(sortedmap.CompareUint64, nil),
-		scrubActiveJob:             nil,
-		scrubJobs:                  sortedmap.NewLLRBTree(sortedmap.CompareUint64, nil),
-		activeDefragInodeNumberSet: make(map[inode.InodeNumber]struct{}),
+		name:                              volumeName,
+		fsckActiveJob:                     nil,
+		fsckJobs:                          sortedmap.NewLLRBTree(sortedmap.CompareUint64, nil),
+		scrubActiveJob:                    nil,
+		scrubJobs:                         sortedmap.NewLLRBTree(sortedmap.CompareUint64, nil),
+		activeDefragInodeNumberSet:        make(map[inode.InodeNumber]struct{}),
+		activeLayoutMigrateInodeNumberSet: make(map[inode.InodeNumber]struct{}),
+	}
+
+	volume.accountName, err = confMap.FetchOptionValueString("Volume:"+volumeName, "AccountName")
+	if nil != err {
+		return
 	}
 
 	volume.fsVolumeHandle, err = fs.FetchVolumeHandleByVolumeName(volume.name)
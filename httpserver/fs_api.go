@@ -0,0 +1,1226 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/NVIDIA/sortedmap"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/fs"
+	"github.com/NVIDIA/proxyfs/inode"
+	"github.com/NVIDIA/proxyfs/logger"
+)
+
+// The fs API presents each served volume via a path-based REST/JSON interface:
+//
+//   GET    /fs                                      the OpenAPI document describing the fs API
+//   GET    /fs/<volume-name>/<path>                 file contents (honoring Range), directory
+//                                                   listing (paged via marker & limit), or
+//                                                   symlink target
+//   GET    /fs/<volume-name>/<path>?op=stat         stat (as JSON)
+//   GET    /fs/<volume-name>/<path>?op=xattr        list of extended attribute names (as JSON)
+//   GET    /fs/<volume-name>/<path>?op=xattr&name=  value of extended attribute
+//   GET    /fs/<volume-name>?op=snapshots           list of SnapShots (as JSON)
+//   PUT    /fs/<volume-name>/<path>                 write file contents (creating the file if
+//                                                   necessary) either entirely or, if
+//                                                   Content-Range is supplied, in part
+//   PUT    /fs/<volume-name>/<path>?op=xattr&name=  set extended attribute
+//   POST   /fs/<volume-name>/<path>?op=mkdir        create directory
+//   POST   /fs/<volume-name>/<path>?op=symlink&target=
+//                                                   create symlink
+//   POST   /fs/<volume-name>/<path>?op=rename&destination=
+//                                                   rename to destination (a path in the volume)
+//   DELETE /fs/<volume-name>/<path>                 unlink file or symlink, or remove directory
+//   DELETE /fs/<volume-name>/<path>?op=xattr&name=  remove extended attribute
+//
+// Any SnapShot's view of the volume is reachable via <path> "/.snapshot/<snapshot-name>/...".
+//
+// The fs API must be enabled via [HTTPServer]FSAPIEnabled which, in turn, requires that
+// [HTTPServer]FSAPIAuthTokenValidationURL be set. Each request must supply an X-Auth-Token that
+// is validated via a HEAD of <FSAPIAuthTokenValidationURL>/v1/<volume's Account>. A successful
+// response must identify the caller via X-ProxyFS-UserID and X-ProxyFS-GroupID (and, optionally,
+// X-ProxyFS-OtherGroupIDs as a comma-separated list) as whom each operation is performed. Unless
+// it also includes "X-ProxyFS-Access: read-write", only GET requests are permitted. Failures are
+// reported as a JSON-encoded fsAPIErrorStruct carrying the blunder errno.
+
+const (
+	fsAPIPathPrefix = "/fs/"
+
+	fsAPIAuthTokenValidationTimeout = 10 * time.Second
+
+	fsAPIReaddirDefaultLimit = uint64(1024)
+	fsAPIReaddirMaxLimit     = uint64(16384)
+
+	fsAPIReadChunkSize = uint64(1024 * 1024)
+	fsAPIMaxWriteSize  = int64(64 * 1024 * 1024)
+
+	fsAPIDefaultFileMode = inode.InodeMode(0644)
+	fsAPIDefaultDirMode  = inode.InodeMode(0755)
+
+	fsAPIPutTempFilePrefix = ".fsapi-put-"
+
+	fsAPIUserIDHeader        = "X-ProxyFS-UserID"
+	fsAPIGroupIDHeader       = "X-ProxyFS-GroupID"
+	fsAPIOtherGroupIDsHeader = "X-ProxyFS-OtherGroupIDs"
+	fsAPIAccessHeader        = "X-ProxyFS-Access"
+	fsAPIAccessReadWrite     = "read-write"
+)
+
+type fsAPIErrorStruct struct {
+	Error string
+	Errno int
+}
+
+type fsAPIStatStruct struct {
+	InodeNumber uint64
+	Type        string
+	Mode        uint32
+	UserID      uint32
+	GroupID     uint32
+	Size        uint64
+	NumLinks    uint64
+	NumWrites   uint64
+	CTimeNs     uint64
+	CRTimeNs    uint64
+	MTimeNs     uint64
+	ATimeNs     uint64
+}
+
+type fsAPIDirEntryStruct struct {
+	Name        string
+	InodeNumber uint64
+	Type        string
+}
+
+type fsAPIReaddirStruct struct {
+	Entries     []fsAPIDirEntryStruct
+	MoreEntries bool
+	NextMarker  string // if MoreEntries, pass as query parameter "marker" to fetch the next page
+}
+
+type fsAPISymlinkStruct struct {
+	Target string
+}
+
+type fsAPIRequestStruct struct {
+	volume        *volumeStruct
+	path          string // relative to the root of the volume ("" for the root itself)
+	op            string
+	queryValues   url.Values
+	userID        inode.InodeUserID // the remaining fields are supplied by fsAPIAuthenticate()
+	groupID       inode.InodeGroupID
+	otherGroupIDs []inode.InodeGroupID
+	writeAllowed  bool
+}
+
+func doFSAPI(responseWriter http.ResponseWriter, request *http.Request) {
+	var (
+		err           error
+		fsAPIRequest  *fsAPIRequestStruct
+		ok            bool
+		pathSplit     []string
+		volumeAsValue sortedmap.Value
+	)
+
+	if !globals.fsAPIEnabled {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if (http.MethodGet == request.Method) && (("/fs" == request.URL.Path) || (fsAPIPathPrefix == request.URL.Path)) {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+		_, _ = responseWriter.Write([]byte(fsAPIOpenAPIDocument))
+		return
+	}
+
+	pathSplit = strings.SplitN(strings.TrimPrefix(request.URL.Path, fsAPIPathPrefix), "/", 2)
+
+	volumeAsValue, ok, err = globals.volumeLLRB.GetByKey(pathSplit[0])
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+	if !ok {
+		fsAPIRespondWithError(responseWriter, blunder.NewError(blunder.NotFoundError, "volume \"%s\" not served", pathSplit[0]))
+		return
+	}
+
+	fsAPIRequest = &fsAPIRequestStruct{
+		volume:      volumeAsValue.(*volumeStruct),
+		queryValues: request.URL.Query(),
+	}
+
+	if 2 == len(pathSplit) {
+		fsAPIRequest.path = strings.TrimPrefix(path.Clean("/"+pathSplit[1]), "/")
+	}
+
+	fsAPIRequest.op = fsAPIRequest.queryValues.Get("op")
+
+	// Unlike the administrative requests, fs API requests may take a while, so don't block the rest
+
+	globals.Unlock()
+	defer globals.Lock()
+
+	err = fsAPIAuthenticate(fsAPIRequest, request.Header.Get("X-Auth-Token"))
+	if nil != err {
+		fsAPIRespondWithError(responseWriter, err)
+		return
+	}
+
+	if (http.MethodGet != request.Method) && !fsAPIRequest.writeAllowed {
+		fsAPIRespondWithError(responseWriter, blunder.NewError(blunder.PermDeniedError, "X-Auth-Token only grants read access to volume \"%s\"", fsAPIRequest.volume.name))
+		return
+	}
+
+	switch request.Method {
+	case http.MethodDelete:
+		switch fsAPIRequest.op {
+		case "":
+			err = doFSAPIDeleteOfPath(responseWriter, fsAPIRequest)
+		case "xattr":
+			err = doFSAPIDeleteOfXAttr(responseWriter, fsAPIRequest)
+		default:
+			err = fsAPIUnknownOp(fsAPIRequest)
+		}
+	case http.MethodGet:
+		switch fsAPIRequest.op {
+		case "":
+			err = doFSAPIGetOfPath(responseWriter, request, fsAPIRequest)
+		case "snapshots":
+			err = doFSAPIGetOfSnapShots(responseWriter, fsAPIRequest)
+		case "stat":
+			err = doFSAPIGetOfStat(responseWriter, fsAPIRequest)
+		case "xattr":
+			err = doFSAPIGetOfXAttr(responseWriter, fsAPIRequest)
+		default:
+			err = fsAPIUnknownOp(fsAPIRequest)
+		}
+	case http.MethodPost:
+		switch fsAPIRequest.op {
+		case "mkdir":
+			err = doFSAPIPostOfMkdir(responseWriter, fsAPIRequest)
+		case "rename":
+			err = doFSAPIPostOfRename(responseWriter, fsAPIRequest)
+		case "symlink":
+			err = doFSAPIPostOfSymlink(responseWriter, fsAPIRequest)
+		default:
+			err = fsAPIUnknownOp(fsAPIRequest)
+		}
+	case http.MethodPut:
+		switch fsAPIRequest.op {
+		case "":
+			err = doFSAPIPutOfPath(responseWriter, request, fsAPIRequest)
+		case "xattr":
+			err = doFSAPIPutOfXAttr(responseWriter, request, fsAPIRequest)
+		default:
+			err = fsAPIUnknownOp(fsAPIRequest)
+		}
+	default:
+		responseWriter.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if nil != err {
+		fsAPIRespondWithError(responseWriter, err)
+	}
+}
+
+func fsAPIUnknownOp(fsAPIRequest *fsAPIRequestStruct) (err error) {
+	err = blunder.NewError(blunder.InvalidArgError, "op \"%s\" not supported for this method", fsAPIRequest.op)
+	return
+}
+
+// fsAPIAuthenticate asks the token validation service (via [HTTPServer]FSAPIAuthTokenValidationURL)
+// whether authToken grants access to the volume's Account and, if so, records in fsAPIRequest the
+// identity (and access) it maps to.
+func fsAPIAuthenticate(fsAPIRequest *fsAPIRequestStruct, authToken string) (err error) {
+	var (
+		httpClient          *http.Client
+		httpRequest         *http.Request
+		httpResponse        *http.Response
+		idAsString          string
+		idAsUint64          uint64
+		otherGroupIDsString string
+		volume              *volumeStruct
+	)
+
+	volume = fsAPIRequest.volume
+
+	if "" == globals.fsAPIAuthTokenValidationURL {
+		err = blunder.NewError(blunder.PermDeniedError, "no [HTTPServer]FSAPIAuthTokenValidationURL with which to validate requests for volume \"%s\"", volume.name)
+		return
+	}
+
+	if "" == authToken {
+		err = blunder.NewError(blunder.PermDeniedError, "no X-Auth-Token supplied for volume \"%s\"", volume.name)
+		return
+	}
+
+	httpRequest, err = http.NewRequest(http.MethodHead, globals.fsAPIAuthTokenValidationURL+"/v1/"+volume.accountName, nil)
+	if nil != err {
+		err = blunder.AddError(err, blunder.PermDeniedError)
+		return
+	}
+
+	httpRequest.Header.Add("X-Auth-Token", authToken)
+
+	httpClient = &http.Client{Timeout: fsAPIAuthTokenValidationTimeout}
+
+	httpResponse, err = httpClient.Do(httpRequest)
+	if nil != err {
+		err = blunder.NewError(blunder.PermDeniedError, "X-Auth-Token validation for volume \"%s\" failed: %v", volume.name, err)
+		return
+	}
+
+	_ = httpResponse.Body.Close()
+
+	if (http.StatusOK > httpResponse.StatusCode) || (http.StatusMultipleChoices <= httpResponse.StatusCode) {
+		err = blunder.NewError(blunder.PermDeniedError, "X-Auth-Token rejected for volume \"%s\" (Status: %s)", volume.name, httpResponse.Status)
+		return
+	}
+
+	idAsString = httpResponse.Header.Get(fsAPIUserIDHeader)
+	idAsUint64, err = strconv.ParseUint(idAsString, 10, 32)
+	if nil != err {
+		err = blunder.NewError(blunder.PermDeniedError, "X-Auth-Token validation for volume \"%s\" returned invalid %s \"%s\"", volume.name, fsAPIUserIDHeader, idAsString)
+		return
+	}
+	fsAPIRequest.userID = inode.InodeUserID(idAsUint64)
+
+	idAsString = httpResponse.Header.Get(fsAPIGroupIDHeader)
+	idAsUint64, err = strconv.ParseUint(idAsString, 10, 32)
+	if nil != err {
+		err = blunder.NewError(blunder.PermDeniedError, "X-Auth-Token validation for volume \"%s\" returned invalid %s \"%s\"", volume.name, fsAPIGroupIDHeader, idAsString)
+		return
+	}
+	fsAPIRequest.groupID = inode.InodeGroupID(idAsUint64)
+
+	fsAPIRequest.otherGroupIDs = nil
+
+	otherGroupIDsString = httpResponse.Header.Get(fsAPIOtherGroupIDsHeader)
+	if "" != otherGroupIDsString {
+		for _, idAsString = range strings.Split(otherGroupIDsString, ",") {
+			idAsUint64, err = strconv.ParseUint(strings.TrimSpace(idAsString), 10, 32)
+			if nil != err {
+				err = blunder.NewError(blunder.PermDeniedError, "X-Auth-Token validation for volume \"%s\" returned invalid %s \"%s\"", volume.name, fsAPIOtherGroupIDsHeader, otherGroupIDsString)
+				return
+			}
+			fsAPIRequest.otherGroupIDs = append(fsAPIRequest.otherGroupIDs, inode.InodeGroupID(idAsUint64))
+		}
+	}
+
+	fsAPIRequest.writeAllowed = (fsAPIAccessReadWrite == httpResponse.Header.Get(fsAPIAccessHeader))
+
+	err = nil
+	return
+}
+
+// fsAPIStatusCode selects the HTTP Status best describing a failure of package fs.
+func fsAPIStatusCode(err error) (statusCode int) {
+	switch {
+	case blunder.Is(err, blunder.NotFoundError), blunder.Is(err, blunder.NoDataError):
+		statusCode = http.StatusNotFound
+	case blunder.Is(err, blunder.FileExistsError), blunder.Is(err, blunder.NotEmptyError), blunder.Is(err, blunder.TooManyLinksError):
+		statusCode = http.StatusConflict
+	case blunder.Is(err, blunder.PermDeniedError), blunder.Is(err, blunder.NotPermError), blunder.Is(err, blunder.ReadOnlyError):
+		statusCode = http.StatusForbidden
+	case blunder.Is(err, blunder.InvalidArgError), blunder.Is(err, blunder.NotDirError), blunder.Is(err, blunder.IsDirError), blunder.Is(err, blunder.NameTooLongError), blunder.Is(err, blunder.TooManySymlinksError):
+		statusCode = http.StatusBadRequest
+	case blunder.Is(err, blunder.FileTooLargeError), blunder.Is(err, blunder.TooBigError):
+		statusCode = http.StatusRequestEntityTooLarge
+	case blunder.Is(err, blunder.OutOfRangeError):
+		statusCode = http.StatusRequestedRangeNotSatisfiable
+	case blunder.Is(err, blunder.NoSpaceError):
+		statusCode = http.StatusInsufficientStorage
+	case blunder.Is(err, blunder.NotSupportedError), blunder.Is(err, blunder.NotImplementedError):
+		statusCode = http.StatusNotImplemented
+	default:
+		statusCode = http.StatusInternalServerError
+	}
+
+	return
+}
+
+func fsAPIRespondWithError(responseWriter http.ResponseWriter, err error) {
+	var (
+		errorJSONPacked []byte
+		marshalErr      error
+	)
+
+	errorJSONPacked, marshalErr = json.Marshal(&fsAPIErrorStruct{Error: err.Error(), Errno: blunder.Errno(err)})
+	if nil != marshalErr {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(fsAPIStatusCode(err))
+	_, _ = responseWriter.Write(errorJSONPacked)
+	_, _ = responseWriter.Write([]byte("\n"))
+}
+
+func fsAPIRespondWithJSON(responseWriter http.ResponseWriter, statusCode int, value interface{}) (err error) {
+	var (
+		valueJSON       bytes.Buffer
+		valueJSONPacked []byte
+	)
+
+	valueJSONPacked, err = json.Marshal(value)
+	if nil != err {
+		return
+	}
+
+	json.Indent(&valueJSON, valueJSONPacked, "", "\t")
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(statusCode)
+	_, _ = responseWriter.Write(valueJSON.Bytes())
+	_, _ = responseWriter.Write([]byte("\n"))
+
+	return
+}
+
+func fsAPIInodeTypeString(inodeType inode.InodeType) (inodeTypeString string) {
+	switch inodeType {
+	case inode.DirType:
+		inodeTypeString = "dir"
+	case inode.FileType:
+		inodeTypeString = "file"
+	case inode.SymlinkType:
+		inodeTypeString = "symlink"
+	default:
+		inodeTypeString = "unknown"
+	}
+
+	return
+}
+
+// fsAPIParseMode parses the optional (octal) "mode" query parameter.
+func fsAPIParseMode(fsAPIRequest *fsAPIRequestStruct, defaultMode inode.InodeMode) (mode inode.InodeMode, err error) {
+	var (
+		modeAsString string
+		modeAsUint64 uint64
+	)
+
+	modeAsString = fsAPIRequest.queryValues.Get("mode")
+	if "" == modeAsString {
+		mode = defaultMode
+		err = nil
+		return
+	}
+
+	modeAsUint64, err = strconv.ParseUint(modeAsString, 8, 32)
+	if (nil != err) || (0 != (inode.InodeMode(modeAsUint64) & ^inode.PosixModePerm)) {
+		err = blunder.NewError(blunder.InvalidArgError, "mode \"%s\" invalid", modeAsString)
+		return
+	}
+
+	mode = inode.InodeMode(modeAsUint64)
+
+	return
+}
+
+func (fsAPIRequest *fsAPIRequestStruct) lookup() (inodeNumber inode.InodeNumber, err error) {
+	if "" == fsAPIRequest.path {
+		inodeNumber = inode.RootDirInodeNumber
+		err = nil
+		return
+	}
+
+	inodeNumber, err = fsAPIRequest.volume.fsVolumeHandle.LookupPath(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, fsAPIRequest.path)
+
+	return
+}
+
+// lookupParent resolves the directory containing fsAPIRequest.path (or, if supplied, otherPath).
+func (fsAPIRequest *fsAPIRequestStruct) lookupParent(otherPath ...string) (dirInodeNumber inode.InodeNumber, basename string, err error) {
+	var (
+		dirPath    string
+		targetPath string
+	)
+
+	if 0 == len(otherPath) {
+		targetPath = fsAPIRequest.path
+	} else {
+		targetPath = strings.TrimPrefix(path.Clean("/"+otherPath[0]), "/")
+	}
+
+	if "" == targetPath {
+		err = blunder.NewError(blunder.InvalidArgError, "operation not permitted on the root of volume \"%s\"", fsAPIRequest.volume.name)
+		return
+	}
+
+	dirPath, basename = path.Split(targetPath)
+	dirPath = strings.TrimSuffix(dirPath, "/")
+
+	if "" == dirPath {
+		dirInodeNumber = inode.RootDirInodeNumber
+		err = nil
+		return
+	}
+
+	dirInodeNumber, err = fsAPIRequest.volume.fsVolumeHandle.LookupPath(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirPath)
+
+	return
+}
+
+func doFSAPIGetOfStat(responseWriter http.ResponseWriter, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		inodeNumber inode.InodeNumber
+		stat        fs.Stat
+	)
+
+	inodeNumber, err = fsAPIRequest.lookup()
+	if nil != err {
+		return
+	}
+
+	stat, err = fsAPIRequest.volume.fsVolumeHandle.Getstat(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, inodeNumber)
+	if nil != err {
+		return
+	}
+
+	err = fsAPIRespondWithJSON(responseWriter, http.StatusOK, &fsAPIStatStruct{
+		InodeNumber: stat[fs.StatINum],
+		Type:        fsAPIInodeTypeString(inode.InodeType(stat[fs.StatFType])),
+		Mode:        uint32(stat[fs.StatMode]),
+		UserID:      uint32(stat[fs.StatUserID]),
+		GroupID:     uint32(stat[fs.StatGroupID]),
+		Size:        stat[fs.StatSize],
+		NumLinks:    stat[fs.StatNLink],
+		NumWrites:   stat[fs.StatNumWrites],
+		CTimeNs:     stat[fs.StatCTime],
+		CRTimeNs:    stat[fs.StatCRTime],
+		MTimeNs:     stat[fs.StatMTime],
+		ATimeNs:     stat[fs.StatATime],
+	})
+
+	return
+}
+
+func doFSAPIGetOfSnapShots(responseWriter http.ResponseWriter, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	if "" != fsAPIRequest.path {
+		err = blunder.NewError(blunder.InvalidArgError, "op \"snapshots\" only supported on the root of volume \"%s\"", fsAPIRequest.volume.name)
+		return
+	}
+
+	err = fsAPIRespondWithJSON(responseWriter, http.StatusOK, fsAPIRequest.volume.headhunterVolumeHandle.SnapShotListByTime(false))
+
+	return
+}
+
+func doFSAPIGetOfPath(responseWriter http.ResponseWriter, request *http.Request, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		inodeNumber inode.InodeNumber
+		inodeType   inode.InodeType
+		target      string
+	)
+
+	inodeNumber, err = fsAPIRequest.lookup()
+	if nil != err {
+		return
+	}
+
+	inodeType, err = fsAPIRequest.volume.fsVolumeHandle.GetType(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, inodeNumber)
+	if nil != err {
+		return
+	}
+
+	switch inodeType {
+	case inode.DirType:
+		err = doFSAPIReaddir(responseWriter, fsAPIRequest, inodeNumber)
+	case inode.FileType:
+		err = doFSAPIRead(responseWriter, request, fsAPIRequest, inodeNumber)
+	case inode.SymlinkType:
+		target, err = fsAPIRequest.volume.fsVolumeHandle.Readsymlink(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, inodeNumber)
+		if nil == err {
+			err = fsAPIRespondWithJSON(responseWriter, http.StatusOK, &fsAPISymlinkStruct{Target: target})
+		}
+	default:
+		err = fmt.Errorf("unexpected InodeType %v for InodeNumber %016X", inodeType, inodeNumber)
+	}
+
+	return
+}
+
+// doFSAPIReaddir reports (as JSON) a page of the directory's entries (excluding "." and "..").
+// Query parameter "marker" continues a prior page after the named entry and "limit" bounds the
+// number of entries returned (default fsAPIReaddirDefaultLimit).
+func doFSAPIReaddir(responseWriter http.ResponseWriter, fsAPIRequest *fsAPIRequestStruct, dirInodeNumber inode.InodeNumber) (err error) {
+	var (
+		dirEntry      inode.DirEntry
+		dirEntries    []inode.DirEntry
+		limit         uint64
+		limitAsString string
+		marker        string
+		moreEntries   bool
+		readdir       *fsAPIReaddirStruct
+	)
+
+	limitAsString = fsAPIRequest.queryValues.Get("limit")
+	if "" == limitAsString {
+		limit = fsAPIReaddirDefaultLimit
+	} else {
+		limit, err = strconv.ParseUint(limitAsString, 10, 64)
+		if (nil != err) || (0 == limit) || (fsAPIReaddirMaxLimit < limit) {
+			err = blunder.NewError(blunder.InvalidArgError, "limit \"%s\" must be between 1 and %d", limitAsString, fsAPIReaddirMaxLimit)
+			return
+		}
+	}
+
+	marker = fsAPIRequest.queryValues.Get("marker")
+
+	readdir = &fsAPIReaddirStruct{
+		Entries: make([]fsAPIDirEntryStruct, 0, limit),
+	}
+
+	moreEntries = true
+
+	for moreEntries && (uint64(len(readdir.Entries)) < limit) {
+		if "" == marker {
+			dirEntries, _, moreEntries, err = fsAPIRequest.volume.fsVolumeHandle.Readdir(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, limit-uint64(len(readdir.Entries)))
+		} else {
+			dirEntries, _, moreEntries, err = fsAPIRequest.volume.fsVolumeHandle.Readdir(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, limit-uint64(len(readdir.Entries)), marker)
+		}
+		if nil != err {
+			return
+		}
+
+		if 0 == len(dirEntries) {
+			moreEntries = false
+			break
+		}
+
+		for _, dirEntry = range dirEntries {
+			if ("." != dirEntry.Basename) && (".." != dirEntry.Basename) {
+				readdir.Entries = append(readdir.Entries, fsAPIDirEntryStruct{
+					Name:        dirEntry.Basename,
+					InodeNumber: uint64(dirEntry.InodeNumber),
+					Type:        fsAPIInodeTypeString(dirEntry.Type),
+				})
+			}
+		}
+
+		marker = dirEntries[len(dirEntries)-1].Basename
+	}
+
+	readdir.MoreEntries = moreEntries
+	if moreEntries {
+		readdir.NextMarker = marker
+	}
+
+	err = fsAPIRespondWithJSON(responseWriter, http.StatusOK, readdir)
+
+	return
+}
+
+// parseFSAPIRange interprets a single "bytes=" Range header against a file of fileSize bytes.
+// An unsatisfiable range is reported as a blunder.OutOfRangeError.
+func parseFSAPIRange(rangeHeader string, fileSize uint64) (offset uint64, length uint64, err error) {
+	var (
+		firstByte       uint64
+		lastByte        uint64
+		rangeSpec       string
+		rangeSpecSplit  []string
+		suffixLength    uint64
+		validRangeUnits bool
+	)
+
+	rangeSpec = strings.TrimPrefix(strings.TrimSpace(rangeHeader), "bytes=")
+	validRangeUnits = (rangeSpec != strings.TrimSpace(rangeHeader))
+
+	rangeSpecSplit = strings.Split(rangeSpec, "-")
+	if !validRangeUnits || (2 != len(rangeSpecSplit)) || strings.Contains(rangeSpec, ",") {
+		err = blunder.NewError(blunder.InvalidArgError, "Range \"%s\" must be of the form bytes=<first>-[<last>] or bytes=-<suffix-length>", rangeHeader)
+		return
+	}
+
+	if "" == rangeSpecSplit[0] {
+		suffixLength, err = strconv.ParseUint(rangeSpecSplit[1], 10, 64)
+		if nil != err {
+			err = blunder.NewError(blunder.InvalidArgError, "Range \"%s\" has invalid suffix-length", rangeHeader)
+			return
+		}
+		if (0 == suffixLength) || (0 == fileSize) {
+			err = blunder.NewError(blunder.OutOfRangeError, "Range \"%s\" not satisfiable for size %d", rangeHeader, fileSize)
+			return
+		}
+		if suffixLength > fileSize {
+			suffixLength = fileSize
+		}
+		offset = fileSize - suffixLength
+		length = suffixLength
+		return
+	}
+
+	firstByte, err = strconv.ParseUint(rangeSpecSplit[0], 10, 64)
+	if nil != err {
+		err = blunder.NewError(blunder.InvalidArgError, "Range \"%s\" has invalid first-byte-pos", rangeHeader)
+		return
+	}
+
+	if "" == rangeSpecSplit[1] {
+		lastByte = math.MaxUint64
+	} else {
+		lastByte, err = strconv.ParseUint(rangeSpecSplit[1], 10, 64)
+		if (nil != err) || (lastByte < firstByte) {
+			err = blunder.NewError(blunder.InvalidArgError, "Range \"%s\" has invalid last-byte-pos", rangeHeader)
+			return
+		}
+	}
+
+	if firstByte >= fileSize {
+		err = blunder.NewError(blunder.OutOfRangeError, "Range \"%s\" not satisfiable for size %d", rangeHeader, fileSize)
+		return
+	}
+
+	if lastByte >= fileSize {
+		lastByte = fileSize - 1
+	}
+
+	offset = firstByte
+	length = lastByte - firstByte + 1
+
+	return
+}
+
+// parseFSAPIContentRange interprets a "bytes <first>-<last>/<complete-length>" (or "/*")
+// Content-Range header on a write.
+func parseFSAPIContentRange(contentRangeHeader string) (offset uint64, length uint64, err error) {
+	var (
+		firstByte        uint64
+		lastByte         uint64
+		rangeSpec        string
+		rangeSpecSplit   []string
+		validRangeFormat bool
+	)
+
+	rangeSpec = strings.TrimPrefix(strings.TrimSpace(contentRangeHeader), "bytes ")
+	validRangeFormat = (rangeSpec != strings.TrimSpace(contentRangeHeader))
+
+	if validRangeFormat {
+		rangeSpecSplit = strings.Split(strings.SplitN(rangeSpec, "/", 2)[0], "-")
+		validRangeFormat = (2 == len(rangeSpecSplit))
+	}
+
+	if validRangeFormat {
+		firstByte, err = strconv.ParseUint(rangeSpecSplit[0], 10, 64)
+		if nil == err {
+			lastByte, err = strconv.ParseUint(rangeSpecSplit[1], 10, 64)
+		}
+		validRangeFormat = (nil == err) && (firstByte <= lastByte)
+	}
+
+	if !validRangeFormat {
+		err = blunder.NewError(blunder.InvalidArgError, "Content-Range \"%s\" must be of the form bytes <first>-<last>/<complete-length>", contentRangeHeader)
+		return
+	}
+
+	offset = firstByte
+	length = lastByte - firstByte + 1
+	err = nil
+
+	return
+}
+
+func doFSAPIRead(responseWriter http.ResponseWriter, request *http.Request, fsAPIRequest *fsAPIRequestStruct, fileInodeNumber inode.InodeNumber) (err error) {
+	var (
+		buf         []byte
+		chunkLength uint64
+		fileSize    uint64
+		length      uint64
+		offset      uint64
+		rangeHeader string
+		stat        fs.Stat
+	)
+
+	stat, err = fsAPIRequest.volume.fsVolumeHandle.Getstat(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	fileSize = stat[fs.StatSize]
+
+	rangeHeader = request.Header.Get("Range")
+
+	if "" == rangeHeader {
+		offset = 0
+		length = fileSize
+	} else {
+		offset, length, err = parseFSAPIRange(rangeHeader, fileSize)
+		if nil != err {
+			if blunder.Is(err, blunder.OutOfRangeError) {
+				responseWriter.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fileSize))
+			}
+			return
+		}
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/octet-stream")
+	responseWriter.Header().Set("Accept-Ranges", "bytes")
+	responseWriter.Header().Set("Content-Length", strconv.FormatUint(length, 10))
+
+	if "" == rangeHeader {
+		responseWriter.WriteHeader(http.StatusOK)
+	} else {
+		responseWriter.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, fileSize))
+		responseWriter.WriteHeader(http.StatusPartialContent)
+	}
+
+	// Having committed to a response, subsequent failures can only truncate it
+
+	for 0 < length {
+		chunkLength = length
+		if fsAPIReadChunkSize < chunkLength {
+			chunkLength = fsAPIReadChunkSize
+		}
+
+		buf, err = fsAPIRequest.volume.fsVolumeHandle.Read(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, fileInodeNumber, offset, chunkLength, nil)
+		if (nil != err) || (0 == len(buf)) {
+			break
+		}
+
+		_, err = responseWriter.Write(buf)
+		if nil != err {
+			break
+		}
+
+		offset += uint64(len(buf))
+		length -= uint64(len(buf))
+	}
+
+	err = nil
+	return
+}
+
+// doFSAPIPutOfPath writes the request body to the file (creating it if necessary). Given a
+// Content-Range, the body is written in place. Otherwise, the body is written to a temporary
+// file that then atomically replaces the file (retaining its mode unless "mode" is supplied,
+// as well as its ownership and xattrs) so that readers never observe a partially replaced
+// file. A file with other links (which would not follow the replacement) or whose WORM state
+// prohibits its replacement may only be written in place.
+func doFSAPIPutOfPath(responseWriter http.ResponseWriter, request *http.Request, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		basename           string
+		body               []byte
+		contentRangeHeader string
+		created            bool
+		dirInodeNumber     inode.InodeNumber
+		fileInodeNumber    inode.InodeNumber
+		fileMode           inode.InodeMode
+		fsVolumeHandle     fs.VolumeHandle
+		inodeType          inode.InodeType
+		length             uint64
+		offset             uint64
+		stat               fs.Stat
+		tempBasename       string
+		tempInodeNumber    inode.InodeNumber
+		wormState          inode.WORMStateStruct
+	)
+
+	fsVolumeHandle = fsAPIRequest.volume.fsVolumeHandle
+
+	body, err = ioutil.ReadAll(http.MaxBytesReader(responseWriter, request.Body, fsAPIMaxWriteSize))
+	if nil != err {
+		err = blunder.NewError(blunder.TooBigError, "request body could not be read (limit %d bytes): %v", fsAPIMaxWriteSize, err)
+		return
+	}
+
+	contentRangeHeader = request.Header.Get("Content-Range")
+	if "" != contentRangeHeader {
+		offset, length, err = parseFSAPIContentRange(contentRangeHeader)
+		if nil != err {
+			return
+		}
+		if uint64(len(body)) != length {
+			err = blunder.NewError(blunder.InvalidArgError, "Content-Range \"%s\" does not match request body length %d", contentRangeHeader, len(body))
+			return
+		}
+	}
+
+	dirInodeNumber, basename, err = fsAPIRequest.lookupParent()
+	if nil != err {
+		return
+	}
+
+	fileMode, err = fsAPIParseMode(fsAPIRequest, fsAPIDefaultFileMode)
+	if nil != err {
+		return
+	}
+
+	fileInodeNumber, err = fsVolumeHandle.Lookup(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, basename)
+	if nil == err {
+		inodeType, err = fsVolumeHandle.GetType(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, fileInodeNumber)
+		if nil != err {
+			return
+		}
+		if inode.FileType != inodeType {
+			err = blunder.NewError(blunder.NotFileError, "\"%s\" is not a file", fsAPIRequest.path)
+			return
+		}
+
+		stat, err = fsVolumeHandle.Getstat(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, fileInodeNumber)
+		if nil != err {
+			return
+		}
+
+		if "" == fsAPIRequest.queryValues.Get("mode") {
+			fileMode = inode.InodeMode(stat[fs.StatMode]) & inode.PosixModePerm
+		}
+	} else {
+		if !blunder.Is(err, blunder.NotFoundError) {
+			return
+		}
+
+		created = true
+	}
+
+	if "" != contentRangeHeader {
+		if created {
+			fileInodeNumber, err = fsVolumeHandle.Create(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, basename, fileMode)
+			if nil != err {
+				return
+			}
+		}
+
+		err = fsAPIWriteAndFlush(fsAPIRequest, fileInodeNumber, offset, body)
+		if nil != err {
+			return
+		}
+	} else {
+		if !created {
+			// Other links would continue to reference the replaced file
+
+			if 1 < stat[fs.StatNLink] {
+				err = blunder.NewError(blunder.TooManyLinksError, "\"%s\" has %d links so cannot be replaced (supply a Content-Range to write it in place)", fsAPIRequest.path, stat[fs.StatNLink])
+				return
+			}
+
+			// Avoid leaving behind a temporary file that inherited WORM state prohibiting its removal
+
+			wormState, err = fsVolumeHandle.GetWORMState(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, fileInodeNumber)
+			if nil != err {
+				return
+			}
+			if wormState.Immutable || wormState.AppendOnly || wormState.Retained(time.Now()) {
+				err = blunder.NewError(blunder.NotPermError, "\"%s\" WORM state prohibits its replacement", fsAPIRequest.path)
+				return
+			}
+		}
+
+		tempBasename = fmt.Sprintf("%s%016X", fsAPIPutTempFilePrefix, atomic.AddUint64(&globals.fsAPIPutTempFileNonce, 1))
+
+		tempInodeNumber, err = fsVolumeHandle.Create(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, tempBasename, fileMode)
+		if nil != err {
+			return
+		}
+
+		err = fsAPIWriteAndFlush(fsAPIRequest, tempInodeNumber, 0, body)
+		if (nil == err) && !created {
+			err = fsAPIInheritAttributes(fsVolumeHandle, fileInodeNumber, stat, tempInodeNumber)
+		}
+		if nil == err {
+			err = fsVolumeHandle.Rename(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, tempBasename, dirInodeNumber, basename, inode.MoveFlagsNone)
+		}
+		if nil != err {
+			_ = fsVolumeHandle.Unlink(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, tempBasename)
+			return
+		}
+	}
+
+	if created {
+		responseWriter.WriteHeader(http.StatusCreated)
+	} else {
+		responseWriter.WriteHeader(http.StatusNoContent)
+	}
+
+	return
+}
+
+// fsAPIInheritAttributes applies the ownership and xattrs of the file being replaced to its
+// replacement. As the requester need not be permitted to change ownership (nor to set every
+// xattr), this is performed as root.
+func fsAPIInheritAttributes(fsVolumeHandle fs.VolumeHandle, fileInodeNumber inode.InodeNumber, stat fs.Stat, tempInodeNumber inode.InodeNumber) (err error) {
+	var (
+		streamName  string
+		streamNames []string
+		streamValue []byte
+	)
+
+	streamNames, err = fsVolumeHandle.ListXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		return
+	}
+
+	for _, streamName = range streamNames {
+		streamValue, err = fsVolumeHandle.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, streamName)
+		if nil != err {
+			return
+		}
+
+		err = fsVolumeHandle.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, tempInodeNumber, streamName, streamValue, fs.SetXAttrCreateOrReplace)
+		if nil != err {
+			return
+		}
+	}
+
+	err = fsVolumeHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, tempInodeNumber, fs.Stat{fs.StatUserID: stat[fs.StatUserID], fs.StatGroupID: stat[fs.StatGroupID]})
+
+	return // err as returned by Setstat() is sufficient
+}
+
+func fsAPIWriteAndFlush(fsAPIRequest *fsAPIRequestStruct, fileInodeNumber inode.InodeNumber, offset uint64, buf []byte) (err error) {
+	if 0 < len(buf) {
+		_, err = fsAPIRequest.volume.fsVolumeHandle.Write(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, fileInodeNumber, offset, buf, nil)
+		if nil != err {
+			return
+		}
+	}
+
+	err = fsAPIRequest.volume.fsVolumeHandle.Flush(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, fileInodeNumber)
+
+	return // err as returned by Flush() is sufficient
+}
+
+func doFSAPIPostOfMkdir(responseWriter http.ResponseWriter, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		basename       string
+		dirInodeNumber inode.InodeNumber
+		dirMode        inode.InodeMode
+	)
+
+	dirMode, err = fsAPIParseMode(fsAPIRequest, fsAPIDefaultDirMode)
+	if nil != err {
+		return
+	}
+
+	dirInodeNumber, basename, err = fsAPIRequest.lookupParent()
+	if nil != err {
+		return
+	}
+
+	_, err = fsAPIRequest.volume.fsVolumeHandle.Mkdir(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, basename, dirMode)
+	if nil != err {
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusCreated)
+
+	return
+}
+
+func doFSAPIPostOfSymlink(responseWriter http.ResponseWriter, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		basename       string
+		dirInodeNumber inode.InodeNumber
+		target         string
+	)
+
+	target = fsAPIRequest.queryValues.Get("target")
+	if "" == target {
+		err = blunder.NewError(blunder.InvalidArgError, "op \"symlink\" requires query parameter \"target\"")
+		return
+	}
+
+	dirInodeNumber, basename, err = fsAPIRequest.lookupParent()
+	if nil != err {
+		return
+	}
+
+	_, err = fsAPIRequest.volume.fsVolumeHandle.Symlink(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, basename, target)
+	if nil != err {
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusCreated)
+
+	return
+}
+
+func doFSAPIPostOfRename(responseWriter http.ResponseWriter, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		destination       string
+		dstBasename       string
+		dstDirInodeNumber inode.InodeNumber
+		srcBasename       string
+		srcDirInodeNumber inode.InodeNumber
+	)
+
+	destination = fsAPIRequest.queryValues.Get("destination")
+	if "" == destination {
+		err = blunder.NewError(blunder.InvalidArgError, "op \"rename\" requires query parameter \"destination\"")
+		return
+	}
+
+	srcDirInodeNumber, srcBasename, err = fsAPIRequest.lookupParent()
+	if nil != err {
+		return
+	}
+
+	dstDirInodeNumber, dstBasename, err = fsAPIRequest.lookupParent(destination)
+	if nil != err {
+		return
+	}
+
+	err = fsAPIRequest.volume.fsVolumeHandle.Rename(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, inode.MoveFlagsNone)
+	if nil != err {
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+
+	return
+}
+
+func doFSAPIDeleteOfPath(responseWriter http.ResponseWriter, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		basename       string
+		dirInodeNumber inode.InodeNumber
+		inodeNumber    inode.InodeNumber
+		inodeType      inode.InodeType
+	)
+
+	dirInodeNumber, basename, err = fsAPIRequest.lookupParent()
+	if nil != err {
+		return
+	}
+
+	inodeNumber, err = fsAPIRequest.volume.fsVolumeHandle.Lookup(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, basename)
+	if nil != err {
+		return
+	}
+
+	inodeType, err = fsAPIRequest.volume.fsVolumeHandle.GetType(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if inode.DirType == inodeType {
+		err = fsAPIRequest.volume.fsVolumeHandle.Rmdir(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, basename)
+	} else {
+		err = fsAPIRequest.volume.fsVolumeHandle.Unlink(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, dirInodeNumber, basename)
+	}
+	if nil != err {
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+
+	return
+}
+
+func doFSAPIGetOfXAttr(responseWriter http.ResponseWriter, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		inodeNumber inode.InodeNumber
+		name        string
+		streamNames []string
+		value       []byte
+	)
+
+	inodeNumber, err = fsAPIRequest.lookup()
+	if nil != err {
+		return
+	}
+
+	name = fsAPIRequest.queryValues.Get("name")
+
+	if "" == name {
+		streamNames, err = fsAPIRequest.volume.fsVolumeHandle.ListXAttr(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, inodeNumber)
+		if nil != err {
+			return
+		}
+		if nil == streamNames {
+			streamNames = make([]string, 0)
+		}
+
+		err = fsAPIRespondWithJSON(responseWriter, http.StatusOK, streamNames)
+
+		return
+	}
+
+	value, err = fsAPIRequest.volume.fsVolumeHandle.GetXAttr(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, inodeNumber, name)
+	if nil != err {
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/octet-stream")
+	responseWriter.WriteHeader(http.StatusOK)
+	_, _ = responseWriter.Write(value)
+
+	return
+}
+
+func doFSAPIPutOfXAttr(responseWriter http.ResponseWriter, request *http.Request, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		inodeNumber inode.InodeNumber
+		name        string
+		value       []byte
+	)
+
+	name = fsAPIRequest.queryValues.Get("name")
+	if "" == name {
+		err = blunder.NewError(blunder.InvalidArgError, "op \"xattr\" requires query parameter \"name\"")
+		return
+	}
+
+	value, err = ioutil.ReadAll(http.MaxBytesReader(responseWriter, request.Body, fsAPIMaxWriteSize))
+	if nil != err {
+		err = blunder.NewError(blunder.TooBigError, "request body could not be read (limit %d bytes): %v", fsAPIMaxWriteSize, err)
+		return
+	}
+
+	inodeNumber, err = fsAPIRequest.lookup()
+	if nil != err {
+		return
+	}
+
+	err = fsAPIRequest.volume.fsVolumeHandle.SetXAttr(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, inodeNumber, name, value, fs.SetXAttrCreateOrReplace)
+	if nil != err {
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+
+	return
+}
+
+func doFSAPIDeleteOfXAttr(responseWriter http.ResponseWriter, fsAPIRequest *fsAPIRequestStruct) (err error) {
+	var (
+		inodeNumber inode.InodeNumber
+		name        string
+	)
+
+	name = fsAPIRequest.queryValues.Get("name")
+	if "" == name {
+		err = blunder.NewError(blunder.InvalidArgError, "op \"xattr\" requires query parameter \"name\"")
+		return
+	}
+
+	inodeNumber, err = fsAPIRequest.lookup()
+	if nil != err {
+		return
+	}
+
+	err = fsAPIRequest.volume.fsVolumeHandle.RemoveXAttr(fsAPIRequest.userID, fsAPIRequest.groupID, fsAPIRequest.otherGroupIDs, inodeNumber, name)
+	if nil != err {
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+
+	return
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package httpserver
+
+// fsAPIOpenAPIDocument is returned by GET /fs and must be kept in sync with fs_api.go
+const fsAPIOpenAPIDocument string = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "ProxyFS fs API",
+    "description": "Path-based access to each volume served by this ProxyFS node. The {path} parameter may contain '/' and is relative to the root of the volume. Each SnapShot's view is reachable via {path} .snapshot/{snapshot-name}/...",
+    "version": "1"
+  },
+  "components": {
+    "securitySchemes": {
+      "authToken": {
+        "type": "apiKey",
+        "in": "header",
+        "name": "X-Auth-Token",
+        "description": "Required. Validated via [HTTPServer]FSAPIAuthTokenValidationURL which maps it to the identity (X-ProxyFS-UserID, X-ProxyFS-GroupID, X-ProxyFS-OtherGroupIDs) as whom each operation is performed. Only GET is permitted unless the validation also returns X-ProxyFS-Access: read-write."
+      }
+    },
+    "parameters": {
+      "volume": {"name": "volume", "in": "path", "required": true, "schema": {"type": "string"}},
+      "path": {"name": "path", "in": "path", "required": true, "schema": {"type": "string"}},
+      "mode": {"name": "mode", "in": "query", "required": false, "schema": {"type": "string", "pattern": "^[0-7]{1,4}$"}, "description": "Permission bits (in octal) of a newly created file or directory"}
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "properties": {
+          "Error": {"type": "string"},
+          "Errno": {"type": "integer", "description": "blunder errno (a Linux errno value)"}
+        }
+      },
+      "Stat": {
+        "type": "object",
+        "properties": {
+          "InodeNumber": {"type": "integer"},
+          "Type": {"type": "string", "enum": ["dir", "file", "symlink", "unknown"]},
+          "Mode": {"type": "integer"},
+          "UserID": {"type": "integer"},
+          "GroupID": {"type": "integer"},
+          "Size": {"type": "integer"},
+          "NumLinks": {"type": "integer"},
+          "NumWrites": {"type": "integer"},
+          "CTimeNs": {"type": "integer"},
+          "CRTimeNs": {"type": "integer"},
+          "MTimeNs": {"type": "integer"},
+          "ATimeNs": {"type": "integer"}
+        }
+      },
+      "Readdir": {
+        "type": "object",
+        "properties": {
+          "Entries": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "Name": {"type": "string"},
+                "InodeNumber": {"type": "integer"},
+                "Type": {"type": "string", "enum": ["dir", "file", "symlink", "unknown"]}
+              }
+            }
+          },
+          "MoreEntries": {"type": "boolean"},
+          "NextMarker": {"type": "string"}
+        }
+      },
+      "Symlink": {
+        "type": "object",
+        "properties": {
+          "Target": {"type": "string"}
+        }
+      },
+      "SnapShot": {
+        "type": "object",
+        "properties": {
+          "ID": {"type": "integer"},
+          "Time": {"type": "string", "format": "date-time"},
+          "Name": {"type": "string"}
+        }
+      }
+    },
+    "responses": {
+      "Error": {
+        "description": "Failure (HTTP Status derived from Errno)",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      }
+    }
+  },
+  "security": [{"authToken": []}, {}],
+  "paths": {
+    "/fs/{volume}": {
+      "parameters": [{"$ref": "#/components/parameters/volume"}],
+      "get": {
+        "summary": "List the root directory, or (with op=snapshots) the volume's SnapShots",
+        "parameters": [
+          {"name": "op", "in": "query", "required": false, "schema": {"type": "string", "enum": ["snapshots", "stat", "xattr"]}},
+          {"name": "marker", "in": "query", "required": false, "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 1, "maximum": 16384, "default": 1024}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Readdir, or array of SnapShot if op=snapshots",
+            "content": {"application/json": {"schema": {"oneOf": [{"$ref": "#/components/schemas/Readdir"}, {"type": "array", "items": {"$ref": "#/components/schemas/SnapShot"}}]}}}
+          },
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/fs/{volume}/{path}": {
+      "parameters": [
+        {"$ref": "#/components/parameters/volume"},
+        {"$ref": "#/components/parameters/path"}
+      ],
+      "get": {
+        "summary": "Read a file (honoring Range), list a directory (paged via marker and limit), read a symlink, stat (op=stat), or list/get extended attributes (op=xattr)",
+        "parameters": [
+          {"name": "op", "in": "query", "required": false, "schema": {"type": "string", "enum": ["stat", "xattr"]}},
+          {"name": "name", "in": "query", "required": false, "schema": {"type": "string"}, "description": "With op=xattr, the extended attribute to fetch (else names are listed)"},
+          {"name": "marker", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Directory entry name after which listing resumes"},
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 1, "maximum": 16384, "default": 1024}},
+          {"name": "Range", "in": "header", "required": false, "schema": {"type": "string"}, "description": "Single range of the form bytes=first-[last] or bytes=-suffix"}
+        ],
+        "responses": {
+          "200": {
+            "description": "File contents, extended attribute value, or JSON description",
+            "content": {
+              "application/octet-stream": {"schema": {"type": "string", "format": "binary"}},
+              "application/json": {"schema": {"oneOf": [
+                {"$ref": "#/components/schemas/Stat"},
+                {"$ref": "#/components/schemas/Readdir"},
+                {"$ref": "#/components/schemas/Symlink"},
+                {"type": "array", "items": {"type": "string"}}
+              ]}}
+            }
+          },
+          "206": {
+            "description": "Requested Range of file contents",
+            "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}
+          },
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "summary": "Write a file (creating it if necessary) or, with op=xattr, set an extended attribute",
+        "parameters": [
+          {"name": "op", "in": "query", "required": false, "schema": {"type": "string", "enum": ["xattr"]}},
+          {"name": "name", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Required with op=xattr"},
+          {"$ref": "#/components/parameters/mode"},
+          {"name": "Content-Range", "in": "header", "required": false, "schema": {"type": "string"}, "description": "bytes first-last/complete-length (or /*) to write in place, else the body atomically replaces the file"}
+        ],
+        "requestBody": {"content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}},
+        "responses": {
+          "201": {"description": "File created"},
+          "204": {"description": "File or extended attribute written"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Create a directory (op=mkdir) or symlink (op=symlink), or rename (op=rename)",
+        "parameters": [
+          {"name": "op", "in": "query", "required": true, "schema": {"type": "string", "enum": ["mkdir", "symlink", "rename"]}},
+          {"name": "target", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Required with op=symlink"},
+          {"name": "destination", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Required with op=rename, a path relative to the root of the volume"},
+          {"$ref": "#/components/parameters/mode"}
+        ],
+        "responses": {
+          "201": {"description": "Directory or symlink created"},
+          "204": {"description": "Renamed"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Unlink a file or symlink, remove an (empty) directory, or (op=xattr) remove an extended attribute",
+        "parameters": [
+          {"name": "op", "in": "query", "required": false, "schema": {"type": "string", "enum": ["xattr"]}},
+          {"name": "name", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Required with op=xattr"}
+        ],
+        "responses": {
+          "204": {"description": "Removed"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  }
+}
+`
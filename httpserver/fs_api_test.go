@@ -0,0 +1,415 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/fs"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func TestFSAPIRange(t *testing.T) {
+	var (
+		err    error
+		length uint64
+		offset uint64
+	)
+
+	testRange := func(rangeHeader string, fileSize uint64, expectedOffset uint64, expectedLength uint64) {
+		offset, length, err = parseFSAPIRange(rangeHeader, fileSize)
+		if nil != err {
+			t.Fatalf("parseFSAPIRange(\"%s\", %d) failed: %v", rangeHeader, fileSize, err)
+		}
+		if (expectedOffset != offset) || (expectedLength != length) {
+			t.Fatalf("parseFSAPIRange(\"%s\", %d) returned (%d, %d)... expected (%d, %d)", rangeHeader, fileSize, offset, length, expectedOffset, expectedLength)
+		}
+	}
+
+	testRangeFailure := func(rangeHeader string, fileSize uint64, expectedError blunder.FsError) {
+		_, _, err = parseFSAPIRange(rangeHeader, fileSize)
+		if !blunder.Is(err, expectedError) {
+			t.Fatalf("parseFSAPIRange(\"%s\", %d) returned err %v... expected %v", rangeHeader, fileSize, err, expectedError)
+		}
+	}
+
+	testRange("bytes=0-99", 1000, 0, 100)
+	testRange("bytes=900-", 1000, 900, 100)
+	testRange("bytes=900-2000", 1000, 900, 100)
+	testRange("bytes=-100", 1000, 900, 100)
+	testRange("bytes=-2000", 1000, 0, 1000)
+
+	testRangeFailure("bytes=1000-", 1000, blunder.OutOfRangeError)
+	testRangeFailure("bytes=0-", 0, blunder.OutOfRangeError)
+	testRangeFailure("bytes=-0", 1000, blunder.OutOfRangeError)
+	testRangeFailure("bytes=10-5", 1000, blunder.InvalidArgError)
+	testRangeFailure("bytes=0-1,5-6", 1000, blunder.InvalidArgError)
+	testRangeFailure("0-99", 1000, blunder.InvalidArgError)
+
+	offset, length, err = parseFSAPIContentRange("bytes 100-199/*")
+	if (nil != err) || (100 != offset) || (100 != length) {
+		t.Fatalf("parseFSAPIContentRange(\"bytes 100-199/*\") returned (%d, %d, %v)", offset, length, err)
+	}
+	offset, length, err = parseFSAPIContentRange("bytes 0-0/1")
+	if (nil != err) || (0 != offset) || (1 != length) {
+		t.Fatalf("parseFSAPIContentRange(\"bytes 0-0/1\") returned (%d, %d, %v)", offset, length, err)
+	}
+	_, _, err = parseFSAPIContentRange("bytes 199-100/*")
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("parseFSAPIContentRange(\"bytes 199-100/*\") should have failed with InvalidArgError: %v", err)
+	}
+}
+
+func TestFSAPIErrors(t *testing.T) {
+	var (
+		err            error
+		errorJSON      []byte
+		errorStruct    fsAPIErrorStruct
+		openAPIMapping map[string]interface{}
+	)
+
+	testStatusCode := func(fsError blunder.FsError, expectedStatusCode int) {
+		err = blunder.NewError(fsError, "test")
+		if expectedStatusCode != fsAPIStatusCode(err) {
+			t.Fatalf("fsAPIStatusCode(%v) returned %d... expected %d", fsError, fsAPIStatusCode(err), expectedStatusCode)
+		}
+	}
+
+	testStatusCode(blunder.NotFoundError, http.StatusNotFound)
+	testStatusCode(blunder.NoDataError, http.StatusNotFound)
+	testStatusCode(blunder.FileExistsError, http.StatusConflict)
+	testStatusCode(blunder.NotEmptyError, http.StatusConflict)
+	testStatusCode(blunder.PermDeniedError, http.StatusForbidden)
+	testStatusCode(blunder.IsDirError, http.StatusBadRequest)
+	testStatusCode(blunder.OutOfRangeError, http.StatusRequestedRangeNotSatisfiable)
+	testStatusCode(blunder.IOError, http.StatusInternalServerError)
+
+	if http.StatusInternalServerError != fsAPIStatusCode(fmt.Errorf("no errno")) {
+		t.Fatalf("fsAPIStatusCode() of error lacking errno should have returned StatusInternalServerError")
+	}
+
+	err = blunder.NewError(blunder.NotEmptyError, "directory not empty")
+	errorJSON, err = json.Marshal(&fsAPIErrorStruct{Error: err.Error(), Errno: blunder.Errno(err)})
+	if nil != err {
+		t.Fatalf("json.Marshal(&fsAPIErrorStruct{}) failed: %v", err)
+	}
+	err = json.Unmarshal(errorJSON, &errorStruct)
+	if (nil != err) || (int(blunder.NotEmptyError) != errorStruct.Errno) {
+		t.Fatalf("fsAPIErrorStruct round trip returned (%#v, %v)", errorStruct, err)
+	}
+
+	err = json.Unmarshal([]byte(fsAPIOpenAPIDocument), &openAPIMapping)
+	if nil != err {
+		t.Fatalf("fsAPIOpenAPIDocument is not valid JSON: %v", err)
+	}
+	if _, ok := openAPIMapping["paths"]; !ok {
+		t.Fatalf("fsAPIOpenAPIDocument lacks \"paths\"")
+	}
+}
+
+// testFSAPITokenValidation mimics a token validation service granting "root-token" read-write
+// access as root, "user-token" read-write access as 1000:1000, and "reader-token" read-only
+// access as 1000:1000.
+func testFSAPITokenValidation(responseWriter http.ResponseWriter, request *http.Request) {
+	switch request.Header.Get("X-Auth-Token") {
+	case "root-token":
+		responseWriter.Header().Set(fsAPIUserIDHeader, "0")
+		responseWriter.Header().Set(fsAPIGroupIDHeader, "0")
+		responseWriter.Header().Set(fsAPIAccessHeader, fsAPIAccessReadWrite)
+	case "user-token":
+		responseWriter.Header().Set(fsAPIUserIDHeader, "1000")
+		responseWriter.Header().Set(fsAPIGroupIDHeader, "1000")
+		responseWriter.Header().Set(fsAPIOtherGroupIDsHeader, "1001,1002")
+		responseWriter.Header().Set(fsAPIAccessHeader, fsAPIAccessReadWrite)
+	case "reader-token":
+		responseWriter.Header().Set(fsAPIUserIDHeader, "1000")
+		responseWriter.Header().Set(fsAPIGroupIDHeader, "1000")
+	default:
+		responseWriter.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+func testFSAPIRequest(t *testing.T, method string, url string, authToken string, header map[string]string, body []byte) (statusCode int, responseBody []byte) {
+	var (
+		err            error
+		headerName     string
+		headerValue    string
+		request        *http.Request
+		responseWriter *httptest.ResponseRecorder
+	)
+
+	request = httptest.NewRequest(method, "http://pfs.com"+url, bytes.NewReader(body))
+	if "" != authToken {
+		request.Header.Set("X-Auth-Token", authToken)
+	}
+	for headerName, headerValue = range header {
+		request.Header.Set(headerName, headerValue)
+	}
+
+	responseWriter = httptest.NewRecorder()
+
+	httpRequestHandler{}.ServeHTTP(responseWriter, request)
+
+	statusCode = responseWriter.Code
+
+	responseBody, err = ioutil.ReadAll(responseWriter.Result().Body)
+	if nil != err {
+		t.Fatalf("ioutil.ReadAll() of %s %s response failed: %v", method, url, err)
+	}
+
+	return
+}
+
+func TestFSAPIHandlers(t *testing.T) {
+	var (
+		names        []string
+		readdir      fsAPIReaddirStruct
+		responseBody []byte
+		stat         fsAPIStatStruct
+		statusCode   int
+		url          string
+	)
+
+	testSetup(t)
+	defer testTeardown(t)
+
+	tokenValidationServer := httptest.NewServer(http.HandlerFunc(testFSAPITokenValidation))
+	defer tokenValidationServer.Close()
+
+	globals.Lock()
+	globals.fsAPIEnabled = true
+	globals.fsAPIAuthTokenValidationURL = tokenValidationServer.URL
+	globals.Unlock()
+
+	defer func() {
+		globals.Lock()
+		globals.fsAPIEnabled = false
+		globals.fsAPIAuthTokenValidationURL = ""
+		globals.Unlock()
+	}()
+
+	// Authentication & authorization
+
+	statusCode, _ = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume", "", nil, nil)
+	if http.StatusForbidden != statusCode {
+		t.Fatalf("GET lacking X-Auth-Token returned %d... expected %d", statusCode, http.StatusForbidden)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume", "bad-token", nil, nil)
+	if http.StatusForbidden != statusCode {
+		t.Fatalf("GET with rejected X-Auth-Token returned %d... expected %d", statusCode, http.StatusForbidden)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume", "reader-token", nil, nil)
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET with read-only X-Auth-Token returned %d... expected %d", statusCode, http.StatusOK)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodPut, "/fs/TestVolume/ReaderFile", "reader-token", nil, []byte("denied"))
+	if http.StatusForbidden != statusCode {
+		t.Fatalf("PUT with read-only X-Auth-Token returned %d... expected %d", statusCode, http.StatusForbidden)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume/ReaderFile", "root-token", nil, nil)
+	if http.StatusNotFound != statusCode {
+		t.Fatalf("PUT with read-only X-Auth-Token should not have created ReaderFile (GET returned %d)", statusCode)
+	}
+
+	// Operations are performed as the identity the X-Auth-Token maps to
+
+	statusCode, _ = testFSAPIRequest(t, http.MethodPost, "/fs/TestVolume/RootDir?op=mkdir&mode=755", "root-token", nil, nil)
+	if http.StatusCreated != statusCode {
+		t.Fatalf("POST op=mkdir of RootDir returned %d... expected %d", statusCode, http.StatusCreated)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodPut, "/fs/TestVolume/RootDir/UserFile", "user-token", nil, []byte("denied"))
+	if http.StatusForbidden != statusCode {
+		t.Fatalf("PUT by non-owner into mode 0755 RootDir returned %d... expected %d", statusCode, http.StatusForbidden)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodPost, "/fs/TestVolume/SharedDir?op=mkdir&mode=777", "root-token", nil, nil)
+	if http.StatusCreated != statusCode {
+		t.Fatalf("POST op=mkdir of SharedDir returned %d... expected %d", statusCode, http.StatusCreated)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodPut, "/fs/TestVolume/SharedDir/UserFile", "user-token", nil, []byte("allowed"))
+	if http.StatusCreated != statusCode {
+		t.Fatalf("PUT into mode 0777 SharedDir returned %d... expected %d", statusCode, http.StatusCreated)
+	}
+	statusCode, responseBody = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume/SharedDir/UserFile?op=stat", "reader-token", nil, nil)
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET op=stat of UserFile returned %d... expected %d", statusCode, http.StatusOK)
+	}
+	err := json.Unmarshal(responseBody, &stat)
+	if nil != err {
+		t.Fatalf("json.Unmarshal() of op=stat response failed: %v", err)
+	}
+	if (1000 != stat.UserID) || (1000 != stat.GroupID) {
+		t.Fatalf("UserFile created via user-token should be owned by 1000:1000... not %d:%d", stat.UserID, stat.GroupID)
+	}
+
+	// Write (full replacement is atomic while a Content-Range writes in place)
+
+	statusCode, _ = testFSAPIRequest(t, http.MethodPut, "/fs/TestVolume/File?mode=600", "root-token", nil, []byte("hello"))
+	if http.StatusCreated != statusCode {
+		t.Fatalf("PUT creating File returned %d... expected %d", statusCode, http.StatusCreated)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodPut, "/fs/TestVolume/File", "root-token", nil, []byte("goodbye"))
+	if http.StatusNoContent != statusCode {
+		t.Fatalf("PUT replacing File returned %d... expected %d", statusCode, http.StatusNoContent)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodPut, "/fs/TestVolume/File", "root-token", map[string]string{"Content-Range": "bytes 0-1/*"}, []byte("GO"))
+	if http.StatusNoContent != statusCode {
+		t.Fatalf("PUT with Content-Range to File returned %d... expected %d", statusCode, http.StatusNoContent)
+	}
+	statusCode, responseBody = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume/File", "root-token", nil, nil)
+	if (http.StatusOK != statusCode) || ("GOodbye" != string(responseBody)) {
+		t.Fatalf("GET of File returned (%d, \"%s\")... expected (%d, \"GOodbye\")", statusCode, string(responseBody), http.StatusOK)
+	}
+	statusCode, responseBody = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume/File", "root-token", map[string]string{"Range": "bytes=2-4"}, nil)
+	if (http.StatusPartialContent != statusCode) || ("odb" != string(responseBody)) {
+		t.Fatalf("GET of File with Range returned (%d, \"%s\")... expected (%d, \"odb\")", statusCode, string(responseBody), http.StatusPartialContent)
+	}
+	statusCode, responseBody = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume/File?op=stat", "root-token", nil, nil)
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET op=stat of File returned %d... expected %d", statusCode, http.StatusOK)
+	}
+	err = json.Unmarshal(responseBody, &stat)
+	if nil != err {
+		t.Fatalf("json.Unmarshal() of op=stat response failed: %v", err)
+	}
+	if 0600 != (stat.Mode & 0777) {
+		t.Fatalf("PUT replacing File should have retained mode 0600... not 0%o", stat.Mode&0777)
+	}
+
+	// Full replacement retains ownership and xattrs...but is refused for a file with other links
+
+	fsVolumeHandle, err := fs.FetchVolumeHandleByVolumeName("TestVolume")
+	if nil != err {
+		t.Fatalf("fs.FetchVolumeHandleByVolumeName() failed: %v", err)
+	}
+	userFileInodeNumber, err := fsVolumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, "SharedDir/UserFile")
+	if nil != err {
+		t.Fatalf("LookupPath() of UserFile failed: %v", err)
+	}
+	err = fsVolumeHandle.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, userFileInodeNumber, "user.test", []byte("xattr"), fs.SetXAttrCreateOrReplace)
+	if nil != err {
+		t.Fatalf("SetXAttr() of UserFile failed: %v", err)
+	}
+
+	statusCode, _ = testFSAPIRequest(t, http.MethodPut, "/fs/TestVolume/SharedDir/UserFile", "root-token", nil, []byte("replaced"))
+	if http.StatusNoContent != statusCode {
+		t.Fatalf("PUT replacing UserFile returned %d... expected %d", statusCode, http.StatusNoContent)
+	}
+	statusCode, responseBody = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume/SharedDir/UserFile?op=stat", "reader-token", nil, nil)
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET op=stat of UserFile returned %d... expected %d", statusCode, http.StatusOK)
+	}
+	err = json.Unmarshal(responseBody, &stat)
+	if nil != err {
+		t.Fatalf("json.Unmarshal() of op=stat response failed: %v", err)
+	}
+	if (1000 != stat.UserID) || (1000 != stat.GroupID) {
+		t.Fatalf("PUT replacing UserFile via root-token should have retained owner 1000:1000... not %d:%d", stat.UserID, stat.GroupID)
+	}
+	xattrValue, err := fsVolumeHandle.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(stat.InodeNumber), "user.test")
+	if (nil != err) || ("xattr" != string(xattrValue)) {
+		t.Fatalf("PUT replacing UserFile should have retained xattr \"user.test\" (err: %v)", err)
+	}
+
+	sharedDirInodeNumber, err := fsVolumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, "SharedDir")
+	if nil != err {
+		t.Fatalf("LookupPath() of SharedDir failed: %v", err)
+	}
+	err = fsVolumeHandle.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, sharedDirInodeNumber, "UserFileLink", inode.InodeNumber(stat.InodeNumber))
+	if nil != err {
+		t.Fatalf("Link() of UserFileLink failed: %v", err)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodPut, "/fs/TestVolume/SharedDir/UserFile", "root-token", nil, []byte("refused"))
+	if http.StatusConflict != statusCode {
+		t.Fatalf("PUT replacing UserFile with two links returned %d... expected %d", statusCode, http.StatusConflict)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodPut, "/fs/TestVolume/SharedDir/UserFile", "root-token", map[string]string{"Content-Range": "bytes 0-7/*"}, []byte("REPLACED"))
+	if http.StatusNoContent != statusCode {
+		t.Fatalf("PUT with Content-Range to UserFile with two links returned %d... expected %d", statusCode, http.StatusNoContent)
+	}
+	statusCode, responseBody = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume/SharedDir/UserFileLink", "root-token", nil, nil)
+	if (http.StatusOK != statusCode) || ("REPLACED" != string(responseBody)) {
+		t.Fatalf("GET of UserFileLink returned (%d, \"%s\")... expected (%d, \"REPLACED\")", statusCode, string(responseBody), http.StatusOK)
+	}
+	err = fsVolumeHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, sharedDirInodeNumber, "UserFileLink")
+	if nil != err {
+		t.Fatalf("Unlink() of UserFileLink failed: %v", err)
+	}
+
+	// Readdir paging (which must also reveal no leftover temporary files from the above PUTs)
+
+	statusCode, _ = testFSAPIRequest(t, http.MethodPost, "/fs/TestVolume/PagedDir?op=mkdir", "root-token", nil, nil)
+	if http.StatusCreated != statusCode {
+		t.Fatalf("POST op=mkdir of PagedDir returned %d... expected %d", statusCode, http.StatusCreated)
+	}
+	for i := 0; i < 5; i++ {
+		statusCode, _ = testFSAPIRequest(t, http.MethodPut, fmt.Sprintf("/fs/TestVolume/PagedDir/File%d", i), "root-token", nil, []byte("x"))
+		if http.StatusCreated != statusCode {
+			t.Fatalf("PUT of PagedDir/File%d returned %d... expected %d", i, statusCode, http.StatusCreated)
+		}
+	}
+
+	names = make([]string, 0)
+	url = "/fs/TestVolume/PagedDir?limit=2"
+
+	for {
+		statusCode, responseBody = testFSAPIRequest(t, http.MethodGet, url, "reader-token", nil, nil)
+		if http.StatusOK != statusCode {
+			t.Fatalf("GET %s returned %d... expected %d", url, statusCode, http.StatusOK)
+		}
+		readdir = fsAPIReaddirStruct{}
+		err = json.Unmarshal(responseBody, &readdir)
+		if nil != err {
+			t.Fatalf("json.Unmarshal() of readdir response failed: %v", err)
+		}
+		if 2 < len(readdir.Entries) {
+			t.Fatalf("GET %s returned %d entries... expected no more than 2", url, len(readdir.Entries))
+		}
+		for _, dirEntry := range readdir.Entries {
+			names = append(names, dirEntry.Name)
+		}
+		if !readdir.MoreEntries {
+			break
+		}
+		url = "/fs/TestVolume/PagedDir?limit=2&marker=" + readdir.NextMarker
+	}
+
+	if "File0,File1,File2,File3,File4" != strings.Join(names, ",") {
+		t.Fatalf("Paged readdir of PagedDir returned %v", names)
+	}
+
+	statusCode, responseBody = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume", "reader-token", nil, nil)
+	if (http.StatusOK != statusCode) || bytes.Contains(responseBody, []byte(fsAPIPutTempFilePrefix)) {
+		t.Fatalf("GET of root directory returned (%d, %s)", statusCode, string(responseBody))
+	}
+
+	// Rename
+
+	statusCode, _ = testFSAPIRequest(t, http.MethodPost, "/fs/TestVolume/PagedDir/File0?op=rename&destination=SharedDir/Renamed", "root-token", nil, nil)
+	if http.StatusNoContent != statusCode {
+		t.Fatalf("POST op=rename returned %d... expected %d", statusCode, http.StatusNoContent)
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume/PagedDir/File0", "root-token", nil, nil)
+	if http.StatusNotFound != statusCode {
+		t.Fatalf("GET of renamed source returned %d... expected %d", statusCode, http.StatusNotFound)
+	}
+	statusCode, responseBody = testFSAPIRequest(t, http.MethodGet, "/fs/TestVolume/SharedDir/Renamed", "root-token", nil, nil)
+	if (http.StatusOK != statusCode) || ("x" != string(responseBody)) {
+		t.Fatalf("GET of rename destination returned (%d, \"%s\")", statusCode, string(responseBody))
+	}
+	statusCode, _ = testFSAPIRequest(t, http.MethodPost, "/fs/TestVolume/PagedDir/File1?op=rename&destination=PagedDir/File2", "reader-token", nil, nil)
+	if http.StatusForbidden != statusCode {
+		t.Fatalf("POST op=rename with read-only X-Auth-Token returned %d... expected %d", statusCode, http.StatusForbidden)
+	}
+}
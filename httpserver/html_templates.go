@@ -593,6 +593,107 @@ const snapShotsBottomTemplate string = `        </tbody>
 </html>
 `
 
+// To use: fmt.Sprintf(snapShotDiffTopTemplate, proxyfsVersion, globals.ipAddrTCPPort, volumeName, fromSnapShotID, toSnapShotID, created, modified, deleted)
+const snapShotDiffTopTemplate string = `<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1, shrink-to-fit=no">
+    <link rel="stylesheet" href="/bootstrap.min.css">
+    <link rel="stylesheet" href="/styles.css">
+    <title>SnapShot Diff %[3]v - %[2]v</title>
+  </head>
+  <body>
+    <nav class="navbar navbar-expand-lg navbar-dark bg-dark fixed-top">
+      <a class="navbar-brand" href="#">%[2]v</a>
+      <button class="navbar-toggler" type="button" data-toggle="collapse" data-target="#navbarNavDropdown" aria-controls="navbarNavDropdown" aria-expanded="false" aria-label="Toggle navigation">
+        <span class="navbar-toggler-icon"></span>
+      </button>
+      <div class="collapse navbar-collapse" id="navbarNavDropdown">
+        <ul class="navbar-nav mr-auto">
+          <li class="nav-item">
+            <a class="nav-link" href="/">Home</a>
+          </li>
+          <li class="nav-item">
+            <a class="nav-link" href="/config">Config</a>
+          </li>
+          <li class="nav-item">
+            <a class="nav-link" href="/metrics">StatsD/Prometheus</a>
+          </li>
+          <li class="nav-item">
+            <a class="nav-link" href="/trigger">Triggers</a>
+          </li>
+          <li class="nav-item active">
+            <a class="nav-link" href="/volume">Volumes <span class="sr-only">(current)</span></a>
+          </li>
+        </ul>
+        <span class="navbar-text">Version %[1]v</span>
+      </div>
+    </nav>
+    <div class="container">
+      <nav aria-label="breadcrumb">
+        <ol class="breadcrumb">
+          <li class="breadcrumb-item"><a href="/">Home</a></li>
+          <li class="breadcrumb-item"><a href="/volume">Volumes</a></li>
+          <li class="breadcrumb-item"><a href="/volume/%[3]v/snapshot">SnapShots %[3]v</a></li>
+          <li class="breadcrumb-item active" aria-current="page">SnapShot Diff</li>
+        </ol>
+      </nav>
+      <h1 class="display-4">
+        SnapShot Diff
+        <small class="text-muted">%[3]v</small>
+      </h1>
+      <dl class="row">
+        <dt class="col-sm-2">From SnapShotID</dt>
+        <dd class="col-sm-10">%[4]v</dd>
+        <dt class="col-sm-2">To SnapShotID</dt>
+        <dd class="col-sm-10">%[5]v (0 == live)</dd>
+        <dt class="col-sm-2">Created</dt>
+        <dd class="col-sm-10">%[6]v</dd>
+        <dt class="col-sm-2">Modified</dt>
+        <dd class="col-sm-10">%[7]v</dd>
+        <dt class="col-sm-2">Deleted</dt>
+        <dd class="col-sm-10">%[8]v</dd>
+      </dl>
+      <table class="table table-sm table-striped table-hover">
+        <thead>
+          <tr>
+            <th scope="col" class="fit">InodeNumber</th>
+            <th scope="col" class="fit">Change</th>
+            <th scope="col" class="fit">Type</th>
+            <th scope="col">Paths</th>
+          </tr>
+        </thead>
+        <tbody>
+`
+
+// To use: fmt.Sprintf(snapShotDiffPerEntryTemplate, inodeNumber, diffType, inodeType, escapedPaths)
+const snapShotDiffPerEntryTemplate string = `          <tr>
+            <td class="fit"><pre class="no-margin">%016[1]X</pre></td>
+            <td class="fit">%[2]v</td>
+            <td class="fit">%[3]v</td>
+            <td>%[4]v</td>
+          </tr>
+`
+
+// To use: fmt.Sprintf(snapShotDiffBottomTemplate, nextLink)
+const snapShotDiffBottomTemplate string = `        </tbody>
+      </table>
+      <a id="next-button" class="btn btn-primary float-right" href="%[1]v">Next</a>
+      <br />
+    </div>
+    <script src="/jquery.min.js"></script>
+    <script src="/popper.min.js"></script>
+    <script src="/bootstrap.min.js"></script>
+    <script type="text/javascript">
+      if ("" == document.getElementById('next-button').getAttribute("href")) {
+        document.getElementById('next-button').classList.add("d-none");
+      }
+    </script>
+  </body>
+</html>
+`
+
 // To use: fmt.Sprintf(jobsTopTemplate, proxyfsVersion, globals.ipAddrTCPPort, volumeName, {"FSCK"|"SCRUB"})
 const jobsTopTemplate string = `<!doctype html>
 <html lang="en">
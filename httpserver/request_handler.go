@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"io/ioutil"
 	"math"
@@ -21,6 +22,7 @@ import (
 
 	"github.com/NVIDIA/sortedmap"
 
+	"github.com/NVIDIA/proxyfs/blunder"
 	"github.com/NVIDIA/proxyfs/bucketstats"
 	"github.com/NVIDIA/proxyfs/fs"
 	"github.com/NVIDIA/proxyfs/halter"
@@ -75,6 +77,8 @@ func (h httpRequestHandler) ServeHTTP(responseWriter http.ResponseWriter, reques
 
 func doDelete(responseWriter http.ResponseWriter, request *http.Request) {
 	switch {
+	case strings.HasPrefix(request.URL.Path, fsAPIPathPrefix):
+		doFSAPI(responseWriter, request)
 	case strings.HasPrefix(request.URL.Path, "/volume"):
 		doDeleteOfVolume(responseWriter, request)
 	default:
@@ -161,6 +165,8 @@ func doGet(responseWriter http.ResponseWriter, request *http.Request) {
 		_, _ = responseWriter.Write([]byte(bootstrapDotJSContent))
 	case "/config" == path:
 		doGetOfConfig(responseWriter, request)
+	case ("/fs" == path) || strings.HasPrefix(request.URL.Path, fsAPIPathPrefix):
+		doFSAPI(responseWriter, request)
 	case "/index.html" == path:
 		doGetOfIndexDotHTML(responseWriter, request)
 	case "/jquery.min.js" == path:
@@ -758,13 +764,19 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 		responseWriter.WriteHeader(http.StatusNotFound)
 		return
 	case 3:
+		// Form: /volume/<volume-name>/change-events
+		// Form: /volume/<volume-name>/checkpoint
+		// Form: /volume/<volume-name>/dedup-report
 		// Form: /volume/<volume-name>/extent-map
 		// Form: /volume/<volume-name>/fsck-job
+		// Form: /volume/<volume-name>/layout-migrate
 		// Form: /volume/<volume-name>/layout-report
 		// Form: /volume/<volume-name>/lease-report
 		// Form: /volume/<volume-name>/meta-defrag
+		// Form: /volume/<volume-name>/recycle-bin
 		// Form: /volume/<volume-name>/scrub-job
 		// Form: /volume/<volume-name>/snapshot
+		// Form: /volume/<volume-name>/snapshot-diff
 	case 4:
 		// Form: /volume/<volume-name>/defrag/<basename>
 		// Form: /volume/<volume-name>/extent-map/<basename>
@@ -772,12 +784,14 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 		// Form: /volume/<volume-name>/fetch-ondisk-metadata-object/<ObjectNumberAs16HexDigits>
 		// Form: /volume/<volume-name>/find-subdir-inodes/<DirInodeNumberAs16HexDigits>
 		// Form: /volume/<volume-name>/fsck-job/<job-id>
+		// Form: /volume/<volume-name>/layout-migrate/<dir>
 		// Form: /volume/<volume-name>/meta-defrag/<BPlusTreeType>
 		// Form: /volume/<volume-name>/scrub-job/<job-id>
 	default:
 		// Form: /volume/<volume-name>/defrag/<dir>/.../<basename>
 		// Form: /volume/<volume-name>/extent-map/<dir>/.../<basename>
 		// Form: /volume/<volume-name>/find-dir-inode/<dir>/.../<basename>
+		// Form: /volume/<volume-name>/layout-migrate/<dir>/.../<dir>
 	}
 
 	acceptHeader = request.Header.Get("Accept")
@@ -933,6 +947,15 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	requestState.formatResponseCompactly = formatResponseCompactly
 
 	switch pathSplit[3] {
+	case "change-events":
+		doGetOfChangeEvents(responseWriter, request, requestState)
+
+	case "checkpoint":
+		doGetOfCheckpoint(responseWriter, request, requestState)
+
+	case "dedup-report":
+		doDedupReport(responseWriter, request, requestState)
+
 	case "defrag":
 		doDefrag(responseWriter, request, requestState)
 
@@ -954,6 +977,9 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	case "fsck-job":
 		doJob(fsckJobType, responseWriter, request, requestState)
 
+	case "layout-migrate":
+		doLayoutMigrate(responseWriter, request, requestState)
+
 	case "layout-report":
 		doLayoutReport(responseWriter, request, requestState)
 
@@ -963,12 +989,18 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	case "meta-defrag":
 		doMetaDefrag(responseWriter, request, requestState)
 
+	case "recycle-bin":
+		doGetOfRecycleBin(responseWriter, request, requestState)
+
 	case "scrub-job":
 		doJob(scrubJobType, responseWriter, request, requestState)
 
 	case "snapshot":
 		doGetOfSnapShot(responseWriter, request, requestState)
 
+	case "snapshot-diff":
+		doGetOfSnapShotDiff(responseWriter, request, requestState)
+
 	default:
 		responseWriter.WriteHeader(http.StatusNotFound)
 		return
@@ -1678,6 +1710,70 @@ type layoutReportSetElementWithoutDiscrepenciesStruct struct {
 	LayoutReport []layoutReportElementLayoutReportElementStruct
 }
 
+func doLayoutMigrate(responseWriter http.ResponseWriter, request *http.Request, requestState *requestStateStruct) {
+	var (
+		alreadyInActiveLayoutMigrateInodeNumberSet bool
+		dirEntryInodeNumber                        inode.InodeNumber
+		dirInodeNumber                             inode.InodeNumber
+		err                                        error
+		errString                                  string
+		infoString                                 string
+		migrateLayoutHandle                        fs.JobHandle
+		pathPartIndex                              int
+	)
+
+	if 3 > requestState.numPathParts {
+		err = fmt.Errorf("doLayoutMigrate() not passed enough requestState.numPathParts (%d)", requestState.numPathParts)
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	dirEntryInodeNumber = inode.RootDirInodeNumber
+	pathPartIndex = 3
+
+	for ; pathPartIndex < requestState.numPathParts; pathPartIndex++ {
+		dirInodeNumber = dirEntryInodeNumber
+
+		dirEntryInodeNumber, err = requestState.volume.fsVolumeHandle.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, requestState.pathSplit[pathPartIndex+1])
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	_, alreadyInActiveLayoutMigrateInodeNumberSet = requestState.volume.activeLayoutMigrateInodeNumberSet[dirEntryInodeNumber]
+	if alreadyInActiveLayoutMigrateInodeNumberSet {
+		responseWriter.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	requestState.volume.activeLayoutMigrateInodeNumberSet[dirEntryInodeNumber] = struct{}{}
+
+	globals.Unlock()
+
+	migrateLayoutHandle = fs.MigrateLayout(requestState.volume.name, "/"+strings.Join(requestState.pathSplit[4:requestState.numPathParts+1], "/"))
+
+	migrateLayoutHandle.Wait()
+
+	responseWriter.Header().Set("Content-Type", "text/plain")
+
+	if 0 == len(migrateLayoutHandle.Error()) {
+		responseWriter.WriteHeader(http.StatusOK)
+	} else {
+		responseWriter.WriteHeader(http.StatusConflict)
+	}
+
+	for _, infoString = range migrateLayoutHandle.Info() {
+		_, _ = responseWriter.Write([]byte(infoString + "\n"))
+	}
+	for _, errString = range migrateLayoutHandle.Error() {
+		_, _ = responseWriter.Write([]byte(errString + "\n"))
+	}
+
+	globals.Lock()
+
+	delete(requestState.volume.activeLayoutMigrateInodeNumberSet, dirEntryInodeNumber)
+}
+
 func doLayoutReport(responseWriter http.ResponseWriter, request *http.Request, requestState *requestStateStruct) {
 	var (
 		discrepencyFormatClass              string
@@ -1816,6 +1912,66 @@ func doLeaseReport(responseWriter http.ResponseWriter, request *http.Request, re
 	}
 }
 
+// dedupReportStruct is returned by a GET of /volume/<volume-name>/dedup-report. Ratio is the
+// ratio of bytes written (subject to deduplication) to bytes actually stored (1.0 if none).
+type dedupReportStruct struct {
+	ChunkedBytes    uint64
+	DedupedBytes    uint64
+	StoredBytes     uint64
+	Ratio           float64
+	Fingerprints    uint64
+	LogSegments     uint64
+	RebuildRequired bool
+}
+
+func doDedupReport(responseWriter http.ResponseWriter, request *http.Request, requestState *requestStateStruct) {
+	var (
+		dedupReport           *dedupReportStruct
+		dedupReportJSON       bytes.Buffer
+		dedupReportJSONPacked []byte
+		dedupStats            headhunter.DedupStatsStruct
+		err                   error
+	)
+
+	if 3 != requestState.numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	dedupStats = requestState.volume.inodeVolumeHandle.FetchDedupStats()
+
+	dedupReport = &dedupReportStruct{
+		ChunkedBytes:    dedupStats.ChunkedBytes,
+		DedupedBytes:    dedupStats.DedupedBytes,
+		StoredBytes:     dedupStats.ChunkedBytes - dedupStats.DedupedBytes,
+		Ratio:           1.0,
+		Fingerprints:    dedupStats.Fingerprints,
+		LogSegments:     dedupStats.LogSegments,
+		RebuildRequired: dedupStats.RebuildRequired,
+	}
+
+	if 0 != dedupReport.StoredBytes {
+		dedupReport.Ratio = float64(dedupReport.ChunkedBytes) / float64(dedupReport.StoredBytes)
+	}
+
+	dedupReportJSONPacked, err = json.Marshal(dedupReport)
+	if nil != err {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	if requestState.formatResponseCompactly {
+		_, _ = responseWriter.Write(dedupReportJSONPacked)
+	} else {
+		json.Indent(&dedupReportJSON, dedupReportJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(dedupReportJSON.Bytes())
+		_, _ = responseWriter.Write([]byte("\n"))
+	}
+}
+
 func doGetOfSnapShot(responseWriter http.ResponseWriter, request *http.Request, requestState *requestStateStruct) {
 	var (
 		directionStringCanonicalized string
@@ -1893,10 +2049,385 @@ func doGetOfSnapShot(responseWriter http.ResponseWriter, request *http.Request,
 	}
 }
 
+type retainedCheckpointsStruct struct {
+	InspectedID         uint64 // zero if the live view is being presented
+	RetainedCheckpoints []headhunter.RetainedCheckpointStruct
+}
+
+// doGetOfCheckpoint reports (as JSON) the volume's retained checkpoints (oldest first) along with
+// the one (if any) currently being inspected in place of the live view.
+func doGetOfCheckpoint(responseWriter http.ResponseWriter, request *http.Request, requestState *requestStateStruct) {
+	var (
+		err                           error
+		retainedCheckpoints           retainedCheckpointsStruct
+		retainedCheckpointsJSON       bytes.Buffer
+		retainedCheckpointsJSONPacked []byte
+	)
+
+	if 3 != requestState.numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	retainedCheckpoints.InspectedID = requestState.volume.headhunterVolumeHandle.InspectedRetainedCheckpoint()
+	retainedCheckpoints.RetainedCheckpoints = requestState.volume.headhunterVolumeHandle.RetainedCheckpointList()
+
+	retainedCheckpointsJSONPacked, err = json.Marshal(retainedCheckpoints)
+	if nil != err {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	if requestState.formatResponseCompactly {
+		_, _ = responseWriter.Write(retainedCheckpointsJSONPacked)
+	} else {
+		json.Indent(&retainedCheckpointsJSON, retainedCheckpointsJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(retainedCheckpointsJSON.Bytes())
+		_, _ = responseWriter.Write([]byte("\n"))
+	}
+}
+
+// doGetOfRecycleBin reports the entries in the volume's RecycleBin (as JSON). The RecycleBin
+// must be enabled for the volume (via RecycleBinRetention).
+func doGetOfRecycleBin(responseWriter http.ResponseWriter, request *http.Request, requestState *requestStateStruct) {
+	var (
+		entries           []fs.RecycleBinEntry
+		entriesJSON       bytes.Buffer
+		entriesJSONPacked []byte
+		err               error
+		moreEntries       bool
+		pageOfEntries     []fs.RecycleBinEntry
+		prevName          string
+	)
+
+	entries = make([]fs.RecycleBinEntry, 0)
+
+	prevName = ""
+	moreEntries = true
+
+	for moreEntries {
+		pageOfEntries, moreEntries, err = requestState.volume.fsVolumeHandle.RecycleBinList(prevName, 0)
+		if nil != err {
+			if blunder.Is(err, blunder.NotSupportedError) {
+				responseWriter.WriteHeader(http.StatusNotImplemented)
+			} else {
+				responseWriter.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if 0 == len(pageOfEntries) {
+			break
+		}
+
+		entries = append(entries, pageOfEntries...)
+
+		prevName = pageOfEntries[len(pageOfEntries)-1].Name
+	}
+
+	entriesJSONPacked, err = json.Marshal(entries)
+	if nil != err {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	if requestState.formatResponseCompactly {
+		_, _ = responseWriter.Write(entriesJSONPacked)
+	} else {
+		json.Indent(&entriesJSON, entriesJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(entriesJSON.Bytes())
+		_, _ = responseWriter.Write([]byte("\n"))
+	}
+}
+
+type snapShotDiffStruct struct {
+	FromSnapShotID  uint64
+	ToSnapShotID    uint64
+	LastInodeNumber uint64
+	Created         uint64
+	Modified        uint64
+	Deleted         uint64
+	DiffEntries     []fs.SnapShotDiffEntry
+	MoreEntries     bool
+}
+
+// doGetOfChangeEvents streams the volume's change events as Server-Sent Events. Each event's
+// "id" is its "<epoch>:<sequence number>" such that a reconnecting client supplying it via the
+// Last-Event-ID header resumes immediately after it. Lacking that header, query parameter "since"
+// may be used (default 0 - the oldest event retained). An event named "lost" is sent should events
+// have been discarded before they could be streamed (including when the volume has been re-served). As the stream is long-lived, globals is unlocked while
+// streaming and the stream is ended after changeEventsMaxStreamDuration (clients will reconnect).
+func doGetOfChangeEvents(responseWriter http.ResponseWriter, request *http.Request, requestState *requestStateStruct) {
+	var (
+		changeEvent          fs.ChangeEvent
+		changeEventJSON      []byte
+		changeEvents         []fs.ChangeEvent
+		err                  error
+		eventsLost           bool
+		epoch                uint64
+		flusher              http.Flusher
+		fsVolumeHandle       fs.VolumeHandle
+		lastEpoch            uint64
+		lastSequenceNumber   uint64
+		latestSequenceNumber uint64
+		ok                   bool
+		queryValues          url.Values
+		streamDeadline       time.Time
+	)
+
+	if 3 != requestState.numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	flusher, ok = responseWriter.(http.Flusher)
+	if !ok {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	queryValues = request.URL.Query()
+
+	if "" != request.Header.Get("Last-Event-ID") {
+		lastEpoch, lastSequenceNumber, err = parseChangeEventID(request.Header.Get("Last-Event-ID"))
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else if "" != queryValues.Get("since") {
+		lastEpoch, lastSequenceNumber, err = parseChangeEventID(queryValues.Get("since"))
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	fsVolumeHandle = requestState.volume.fsVolumeHandle
+
+	// Verify change notification is enabled before committing to a stream
+
+	_, _, _, _, err = fsVolumeHandle.FetchChangeEvents(0, 0, 1, 0)
+	if nil != err {
+		if blunder.Is(err, blunder.NotSupportedError) {
+			responseWriter.WriteHeader(http.StatusNotImplemented)
+		} else {
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/event-stream")
+	responseWriter.Header().Set("Cache-Control", "no-cache")
+	responseWriter.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	globals.Unlock()
+	defer globals.Lock()
+
+	streamDeadline = time.Now().Add(changeEventsMaxStreamDuration)
+
+	for time.Now().Before(streamDeadline) {
+		select {
+		case <-request.Context().Done():
+			return
+		default:
+		}
+
+		changeEvents, epoch, latestSequenceNumber, eventsLost, err = fsVolumeHandle.FetchChangeEvents(lastEpoch, lastSequenceNumber, changeEventsMaxEventsPerFetch, changeEventsKeepAliveInterval)
+		if nil != err {
+			return
+		}
+
+		if eventsLost {
+			_, err = responseWriter.Write([]byte("event: lost\ndata: {}\n\n"))
+			if nil != err {
+				return
+			}
+		}
+
+		if 0 == len(changeEvents) {
+			_, err = responseWriter.Write([]byte(": keepalive\n\n"))
+			if nil != err {
+				return
+			}
+		} else {
+			for _, changeEvent = range changeEvents {
+				changeEventJSON, err = json.Marshal(changeEvent)
+				if nil != err {
+					return
+				}
+				_, err = responseWriter.Write([]byte(fmt.Sprintf("id: %d:%d\nevent: %s\ndata: %s\n\n", changeEvent.Epoch, changeEvent.SequenceNumber, changeEvent.Type.String(), changeEventJSON)))
+				if nil != err {
+					return
+				}
+			}
+		}
+
+		flusher.Flush()
+
+		lastEpoch = epoch
+		lastSequenceNumber = latestSequenceNumber
+	}
+}
+
+// parseChangeEventID parses a change event "id" of the form "<epoch>:<sequence number>". A lone
+// "<sequence number>" is accepted as well (with an epoch of zero).
+func parseChangeEventID(changeEventID string) (epoch uint64, sequenceNumber uint64, err error) {
+	var (
+		changeEventIDSplit []string
+	)
+
+	changeEventIDSplit = strings.Split(changeEventID, ":")
+
+	switch len(changeEventIDSplit) {
+	case 1:
+		epoch = 0
+		sequenceNumber, err = strconv.ParseUint(changeEventIDSplit[0], 10, 64)
+	case 2:
+		epoch, err = strconv.ParseUint(changeEventIDSplit[0], 10, 64)
+		if nil == err {
+			sequenceNumber, err = strconv.ParseUint(changeEventIDSplit[1], 10, 64)
+		}
+	default:
+		err = fmt.Errorf("malformed change event id \"%s\"", changeEventID)
+	}
+
+	return
+}
+
+// doGetOfSnapShotDiff reports a page of the Inodes that differ between two views of the volume.
+// Query parameter "from" (required) and "to" (default 0 - the live view) select the SnapShotIDs
+// to compare. Query parameter "marker" (InodeNumber as 16 Hex Digits) continues a prior report
+// after its last entry and "max" limits the number of entries (default snapShotDiffDefaultMaxEntries).
+func doGetOfSnapShotDiff(responseWriter http.ResponseWriter, request *http.Request, requestState *requestStateStruct) {
+	var (
+		diffEntry          fs.SnapShotDiffEntry
+		err                error
+		escapedPaths       []string
+		maxEntries         uint64
+		nextLink           string
+		path               string
+		queryValues        url.Values
+		snapShotDiff       snapShotDiffStruct
+		snapShotDiffJSON   bytes.Buffer
+		snapShotDiffPacked []byte
+	)
+
+	if 3 != requestState.numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	queryValues = request.URL.Query()
+
+	if "" == queryValues.Get("from") {
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	snapShotDiff.FromSnapShotID, err = strconv.ParseUint(queryValues.Get("from"), 10, 64)
+	if nil != err {
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if "" != queryValues.Get("to") {
+		snapShotDiff.ToSnapShotID, err = strconv.ParseUint(queryValues.Get("to"), 10, 64)
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	if "" != queryValues.Get("marker") {
+		snapShotDiff.LastInodeNumber, err = strconv.ParseUint(queryValues.Get("marker"), 16, 64)
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	if "" == queryValues.Get("max") {
+		maxEntries = snapShotDiffDefaultMaxEntries
+	} else {
+		maxEntries, err = strconv.ParseUint(queryValues.Get("max"), 10, 64)
+		if (nil != err) || (0 == maxEntries) {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	snapShotDiff.DiffEntries, snapShotDiff.MoreEntries, err = requestState.volume.fsVolumeHandle.SnapShotDiff(snapShotDiff.FromSnapShotID, snapShotDiff.ToSnapShotID, inode.InodeNumber(snapShotDiff.LastInodeNumber), maxEntries)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+
+	for _, diffEntry = range snapShotDiff.DiffEntries {
+		switch diffEntry.DiffType {
+		case inode.SnapShotDiffCreated:
+			snapShotDiff.Created++
+		case inode.SnapShotDiffModified:
+			snapShotDiff.Modified++
+		case inode.SnapShotDiffDeleted:
+			snapShotDiff.Deleted++
+		}
+	}
+
+	if requestState.formatResponseAsJSON {
+		snapShotDiffPacked, err = json.Marshal(snapShotDiff)
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		if requestState.formatResponseCompactly {
+			_, _ = responseWriter.Write(snapShotDiffPacked)
+		} else {
+			json.Indent(&snapShotDiffJSON, snapShotDiffPacked, "", "\t")
+			_, _ = responseWriter.Write(snapShotDiffJSON.Bytes())
+			_, _ = responseWriter.Write([]byte("\n"))
+		}
+	} else {
+		responseWriter.Header().Set("Content-Type", "text/html")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		_, _ = responseWriter.Write([]byte(fmt.Sprintf(snapShotDiffTopTemplate, version.ProxyFSVersion, globals.ipAddrTCPPort, requestState.volume.name, snapShotDiff.FromSnapShotID, snapShotDiff.ToSnapShotID, snapShotDiff.Created, snapShotDiff.Modified, snapShotDiff.Deleted)))
+
+		for _, diffEntry = range snapShotDiff.DiffEntries {
+			escapedPaths = make([]string, 0, len(diffEntry.Paths))
+			for _, path = range diffEntry.Paths {
+				escapedPaths = append(escapedPaths, html.EscapeString(path))
+			}
+			_, _ = responseWriter.Write([]byte(fmt.Sprintf(snapShotDiffPerEntryTemplate, uint64(diffEntry.InodeNumber), diffEntry.DiffType, diffEntry.Type, strings.Join(escapedPaths, "<br>"))))
+		}
+
+		if snapShotDiff.MoreEntries {
+			nextLink = fmt.Sprintf("/volume/%s/snapshot-diff?from=%v&to=%v&marker=%016X&max=%v", requestState.volume.name, snapShotDiff.FromSnapShotID, snapShotDiff.ToSnapShotID, uint64(snapShotDiff.DiffEntries[len(snapShotDiff.DiffEntries)-1].InodeNumber), maxEntries)
+		}
+
+		_, _ = responseWriter.Write([]byte(fmt.Sprintf(snapShotDiffBottomTemplate, nextLink)))
+	}
+}
+
 func doPost(responseWriter http.ResponseWriter, request *http.Request) {
 	switch {
 	case strings.HasPrefix(request.URL.Path, "/deletions"):
 		doPostOfDeletions(responseWriter, request)
+	case strings.HasPrefix(request.URL.Path, fsAPIPathPrefix):
+		doFSAPI(responseWriter, request)
 	case strings.HasPrefix(request.URL.Path, "/trigger"):
 		doPostOfTrigger(responseWriter, request)
 	case strings.HasPrefix(request.URL.Path, "/volume"):
@@ -2037,16 +2568,21 @@ func doPostOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	switch numPathParts {
 	case 3:
 		// Form: /volume/<volume-name>/add-dir-entry
+		// Form: /volume/<volume-name>/checkpoint
 		// Form: /volume/<volume-name>/find-file-inodes-matching-lengths
 		// Form: /volume/<volume-name>/fsck-job
 		// Form: /volume/<volume-name>/patch-dir-inode
 		// Form: /volume/<volume-name>/patch-file-inode
 		// Form: /volume/<volume-name>/patch-symlink-inode
+		// Form: /volume/<volume-name>/replication
 		// Form: /volume/<volume-name>/scrub-job
 		// Form: /volume/<volume-name>/snapshot
 	case 4:
+		// Form: /volume/<volume-name>/checkpoint/<checkpoint-id>
 		// Form: /volume/<volume-name>/fsck-job/<job-id>
+		// Form: /volume/<volume-name>/recycle-bin/<entry-name>
 		// Form: /volume/<volume-name>/scrub-job/<job-id>
+		// Form: /volume/<volume-name>/snapshot/<snapshot-id>
 	default:
 		responseWriter.WriteHeader(http.StatusNotFound)
 		return
@@ -2072,6 +2608,13 @@ func doPostOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 		}
 		doPostOfAddDirEntry(responseWriter, request, volume)
 		return
+	case "checkpoint":
+		if 3 == numPathParts {
+			doPostOfCheckpoint(responseWriter, request, volume)
+		} else {
+			doPostOfCheckpointID(responseWriter, request, volume, pathSplit[4])
+		}
+		return
 	case "fsck-job":
 		jobType = fsckJobType
 	case "find-file-inodes-matching-lengths":
@@ -2102,14 +2645,28 @@ func doPostOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 		}
 		doPostOfPatchSymlinkInode(responseWriter, request, volume)
 		return
-	case "scrub-job":
-		jobType = scrubJobType
-	case "snapshot":
+	case "replication":
 		if 3 != numPathParts {
 			responseWriter.WriteHeader(http.StatusNotFound)
 			return
 		}
-		doPostOfSnapShot(responseWriter, request, volume)
+		doPostOfReplication(responseWriter, request, volume)
+		return
+	case "recycle-bin":
+		if 4 != numPathParts {
+			responseWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+		doPostOfRecycleBinEntry(responseWriter, request, volume, pathSplit[4])
+		return
+	case "scrub-job":
+		jobType = scrubJobType
+	case "snapshot":
+		if 3 == numPathParts {
+			doPostOfSnapShot(responseWriter, request, volume)
+		} else {
+			doPostOfSnapShotID(responseWriter, request, volume, pathSplit[4])
+		}
 		return
 	default:
 		responseWriter.WriteHeader(http.StatusNotFound)
@@ -2295,6 +2852,182 @@ func doPostOfSnapShot(responseWriter http.ResponseWriter, request *http.Request,
 	}
 }
 
+// doPostOfReplication performs the action specified by form value "action" on a volume. An action
+// of "replicate" ships the volume's most recent SnapShot to its replica while an action of "promote"
+// converts a (read-only) replica into an ordinary volume.
+func doPostOfReplication(responseWriter http.ResponseWriter, request *http.Request, volume *volumeStruct) {
+	var (
+		err error
+	)
+
+	switch request.FormValue("action") {
+	case "replicate":
+		err = volume.headhunterVolumeHandle.ReplicateSnapShots()
+	case "promote":
+		err = volume.headhunterVolumeHandle.PromoteReplica()
+	default:
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if nil == err {
+		responseWriter.WriteHeader(http.StatusNoContent)
+	} else {
+		responseWriter.WriteHeader(http.StatusConflict)
+	}
+}
+
+// doPostOfSnapShotID performs the action specified by form value "action" on a SnapShot. An action
+// of "revert" reverts the entire volume to the (most recent) SnapShot while an action of "restore"
+// restores the file or directory subtree at form value "path" from the SnapShot.
+func doPostOfSnapShotID(responseWriter http.ResponseWriter, request *http.Request, volume *volumeStruct, snapShotIDAsString string) {
+	var (
+		cloneAccountName                      string
+		cloneCheckpointContainerName          string
+		cloneCheckpointContainerStoragePolicy string
+		cloneVolumeName                       string
+		cloneVolumeSectionName                string
+		err                                   error
+		path                                  string
+		snapShotID                            uint64
+	)
+
+	snapShotID, err = strconv.ParseUint(snapShotIDAsString, 10, 64)
+	if nil != err {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch request.FormValue("action") {
+	case "revert":
+		err = volume.fsVolumeHandle.SnapShotRevert(snapShotID)
+	case "restore":
+		path = request.FormValue("path")
+		if "" == path {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		err = volume.fsVolumeHandle.SnapShotRestore(snapShotID, path)
+	case "clone":
+		// The clone's volume must be described in the config but not yet formatted
+
+		cloneVolumeName = request.FormValue("volume")
+		if ("" == cloneVolumeName) || (volume.name == cloneVolumeName) {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		cloneVolumeSectionName = "Volume:" + cloneVolumeName
+		cloneAccountName, err = globals.confMap.FetchOptionValueString(cloneVolumeSectionName, "AccountName")
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		cloneCheckpointContainerName, err = globals.confMap.FetchOptionValueString(cloneVolumeSectionName, "CheckpointContainerName")
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		cloneCheckpointContainerStoragePolicy, err = globals.confMap.FetchOptionValueString(cloneVolumeSectionName, "CheckpointContainerStoragePolicy")
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		err = volume.inodeVolumeHandle.SnapShotClone(snapShotID, cloneAccountName, cloneCheckpointContainerName, cloneCheckpointContainerStoragePolicy)
+	default:
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case nil == err:
+		responseWriter.WriteHeader(http.StatusNoContent)
+	case blunder.Is(err, blunder.NotFoundError):
+		responseWriter.WriteHeader(http.StatusNotFound)
+	default:
+		responseWriter.WriteHeader(http.StatusConflict)
+	}
+}
+
+// doPostOfCheckpoint resumes presenting the live view (action=resume) once inspection of
+// a retained checkpoint is complete.
+func doPostOfCheckpoint(responseWriter http.ResponseWriter, request *http.Request, volume *volumeStruct) {
+	var (
+		err error
+	)
+
+	if "resume" != request.FormValue("action") {
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = volume.fsVolumeHandle.RetainedCheckpointInspect(0)
+	if nil == err {
+		responseWriter.WriteHeader(http.StatusNoContent)
+	} else {
+		responseWriter.WriteHeader(http.StatusConflict)
+	}
+}
+
+// doPostOfCheckpointID either presents (read-only) the identified retained checkpoint in place
+// of the live view (action=inspect) or makes it the new live view (action=revert).
+func doPostOfCheckpointID(responseWriter http.ResponseWriter, request *http.Request, volume *volumeStruct, checkpointIDAsString string) {
+	var (
+		checkpointID uint64
+		err          error
+	)
+
+	checkpointID, err = strconv.ParseUint(checkpointIDAsString, 10, 64)
+	if (nil != err) || (0 == checkpointID) {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch request.FormValue("action") {
+	case "inspect":
+		err = volume.fsVolumeHandle.RetainedCheckpointInspect(checkpointID)
+	case "revert":
+		err = volume.fsVolumeHandle.RetainedCheckpointRevert(checkpointID)
+	default:
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if nil == err {
+		responseWriter.WriteHeader(http.StatusNoContent)
+	} else {
+		responseWriter.WriteHeader(http.StatusConflict)
+	}
+}
+
+// doPostOfRecycleBinEntry either restores (action=restore, optionally to path) or purges
+// (action=purge) the named entry of the volume's RecycleBin.
+func doPostOfRecycleBinEntry(responseWriter http.ResponseWriter, request *http.Request, volume *volumeStruct, entryName string) {
+	var (
+		err error
+	)
+
+	switch request.FormValue("action") {
+	case "restore":
+		_, err = volume.fsVolumeHandle.RecycleBinRestore(entryName, request.FormValue("path"))
+	case "purge":
+		err = volume.fsVolumeHandle.RecycleBinPurge(entryName)
+	default:
+		responseWriter.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case nil == err:
+		responseWriter.WriteHeader(http.StatusNoContent)
+	case blunder.Is(err, blunder.NotSupportedError):
+		responseWriter.WriteHeader(http.StatusNotImplemented)
+	case blunder.Is(err, blunder.NotFoundError):
+		responseWriter.WriteHeader(http.StatusNotFound)
+	default:
+		responseWriter.WriteHeader(http.StatusConflict)
+	}
+}
+
 func doPostOfAddDirEntry(responseWriter http.ResponseWriter, request *http.Request, volume *volumeStruct) {
 	var (
 		dirEntryInodeNumberAsString                         string
@@ -2899,6 +3632,8 @@ func markJobsCompletedIfNoLongerActiveWhileLocked(volume *volumeStruct) {
 
 func doPut(responseWriter http.ResponseWriter, request *http.Request) {
 	switch {
+	case strings.HasPrefix(request.URL.Path, fsAPIPathPrefix):
+		doFSAPI(responseWriter, request)
 	case strings.HasPrefix(request.URL.Path, "/volume"):
 		doPutOfVolume(responseWriter, request)
 	default:
@@ -46,11 +46,43 @@ func testSetup(t *testing.T) {
 		"SwiftClient.RetryExpBackoffObject=2.0",
 		"SwiftClient.ChunkedConnectionPoolSize=256",
 		"SwiftClient.NonChunkedConnectionPoolSize=64",
+		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.ContainerStoragePolicy=silver",
+		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.ContainerNamePrefix=Replicated3Way_",
+		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.ContainersPerPeer=10",
+		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.MaxObjectsPerContainer=1000000",
 		"Peer:Peer0.PublicIPAddr=127.0.0.1",
 		"Peer:Peer0.PrivateIPAddr=127.0.0.1",
 		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
 		"Cluster.Peers=Peer0",
 		"Cluster.WhoAmI=Peer0",
+		"Volume:TestVolume.FSID=1",
+		"Volume:TestVolume.PrimaryPeer=Peer0",
+		"Volume:TestVolume.AccountName=AUTH_test",
+		"Volume:TestVolume.AutoFormat=true",
+		"Volume:TestVolume.FUSEMountPointName=/ProxyFSHTTPServerTestNonExistentDir/TestVolume", // parent missing, so no FUSE mount is attempted
+		"Volume:TestVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:TestVolume.CheckpointInterval=10s",
+		"Volume:TestVolume.DefaultPhysicalContainerLayout=PhysicalContainerLayoutReplicated3Way",
+		"Volume:TestVolume.MaxFlushSize=10485760",
+		"Volume:TestVolume.MaxFlushTime=10s",
+		"Volume:TestVolume.FileDefragmentChunkSize=10485760",
+		"Volume:TestVolume.FileDefragmentChunkDelay=10ms",
+		"Volume:TestVolume.NonceValuesToReserve=100",
+		"Volume:TestVolume.MaxEntriesPerDirNode=32",
+		"Volume:TestVolume.MaxExtentsPerFileNode=32",
+		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
+		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"Volume:TestVolume.MaxBytesInodeCache=100000",
+		"Volume:TestVolume.InodeCacheEvictInterval=1s",
+		"Volume:TestVolume.ActiveLeaseEvictLowLimit=5000",
+		"Volume:TestVolume.ActiveLeaseEvictHighLimit=5010",
+		"VolumeGroup:TestVolumeGroup.VolumeList=TestVolume",
+		"VolumeGroup:TestVolumeGroup.VirtualIPAddr=",
+		"VolumeGroup:TestVolumeGroup.PrimaryPeer=Peer0",
+		"VolumeGroup:TestVolumeGroup.ReadCacheLineSize=1000000",
+		"VolumeGroup:TestVolumeGroup.ReadCacheWeight=100",
 		"Cluster.PrivateClusterUDPPort=18123",
 		"Cluster.UDPPacketSendSize=1400",
 		"Cluster.UDPPacketRecvSize=1500",
@@ -61,7 +93,7 @@ func testSetup(t *testing.T) {
 		"Cluster.MaxRequestDuration=1s",
 		"Cluster.LivenessCheckerEnable=true",
 		"Cluster.LivenessCheckRedundancy=2",
-		"FSGlobals.VolumeGroupList=",
+		"FSGlobals.VolumeGroupList=TestVolumeGroup",
 		"FSGlobals.CheckpointHeaderConsensusAttempts=5",
 		"FSGlobals.MountRetryLimit=6",
 		"FSGlobals.MountRetryDelay=1s",
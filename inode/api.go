@@ -5,6 +5,7 @@
 package inode
 
 import (
+	"fmt"
 	"time"
 	"unsafe"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/NVIDIA/sortedmap"
 
 	"github.com/NVIDIA/proxyfs/dlm"
+	"github.com/NVIDIA/proxyfs/headhunter"
 	"github.com/NVIDIA/proxyfs/utils"
 )
 
@@ -64,6 +66,16 @@ const (
 	OwnerOverride
 )
 
+// MoveFlags modifies the behavior of Move(). The values match those of the Linux
+// renameat2() flags so that they may be passed through unmodified from FUSE.
+type MoveFlags uint32
+
+const (
+	MoveFlagsNone     MoveFlags = 0
+	MoveFlagNoReplace MoveFlags = 1 << 0 // RENAME_NOREPLACE: fail with EEXIST if dstBasename exists
+	MoveFlagExchange  MoveFlags = 1 << 1 // RENAME_EXCHANGE: atomically swap srcBasename and dstBasename
+)
+
 // The following line of code is a directive to go generate that tells it to create a
 // file called inodetype_string.go that implements the .String() method for InodeType.
 //go:generate stringer -type=InodeType
@@ -83,12 +95,43 @@ type MetadataStruct struct {
 	GroupID              InodeGroupID
 }
 
+// FragmentationReport excludes extents in LogSegments shared with other FileInodes (e.g. via dedup)
+// as DefragmentFile() leaves those alone.
 type FragmentationReport struct {
 	NumberOfFragments uint64 // used with BytesInFragments to compute average fragment size
 	BytesInFragments  uint64 // equivalent to size of file for FileInode that is not sparse
 	BytesTrapped      uint64 // unreferenced bytes trapped in referenced log segments
 }
 
+type SnapShotDiffType uint8
+
+const (
+	SnapShotDiffCreated  SnapShotDiffType = iota // Inode exists only in the "to" view
+	SnapShotDiffModified                         // Inode exists in both views but differs
+	SnapShotDiffDeleted                          // Inode exists only in the "from" view
+)
+
+func (diffType SnapShotDiffType) String() string {
+	switch diffType {
+	case SnapShotDiffCreated:
+		return "Created"
+	case SnapShotDiffModified:
+		return "Modified"
+	case SnapShotDiffDeleted:
+		return "Deleted"
+	default:
+		return fmt.Sprintf("SnapShotDiffType(%d)", uint8(diffType))
+	}
+}
+
+// SnapShotDiffEntry describes an Inode that differs between two views as reported by SnapShotDiff().
+type SnapShotDiffEntry struct {
+	InodeNumber                 // as known in the live view (i.e. without SnapShotID encoding)
+	ViewInodeNumber InodeNumber // as known in the "to" view (or the "from" view if DiffType == SnapShotDiffDeleted)
+	Type            InodeType   // as known in the same view as ViewInodeNumber
+	DiffType        SnapShotDiffType
+}
+
 type DirEntry struct {
 	InodeNumber
 	Basename        string
@@ -110,6 +153,9 @@ type ReadPlanStep struct {
 	ContainerName    string // If == "", Length specifies a zero-fill size
 	ObjectName       string // If == "", Length specifies a zero-fill size
 	ObjectPath       string // If == "", Length specifies a zero-fill size
+	Data             []byte // If != nil, step is satisfied by data inlined in the FileInode
+	CompressedLength uint64 // If != 0, [Offset:Offset+CompressedLength) is a (flate) compressed frame of which
+	FrameOffset      uint64 //   [FrameOffset:FrameOffset+Length) of the decompressed frame is called for
 }
 
 type ExtentMapEntryStruct struct {
@@ -118,6 +164,7 @@ type ExtentMapEntryStruct struct {
 	Length           uint64
 	ContainerName    string // While "read-as-zero" entries in ExtentMapShunkStruct
 	ObjectName       string //   are not present, {Container|Object}Name would be == ""
+	Data             []byte // If != nil, entry is [LogSegmentOffset:LogSegmentOffset+Length) of Data (e.g. inlined in the FileInode)
 }
 
 type ExtentMapChunkStruct struct {
@@ -135,6 +182,56 @@ const (
 	SnapShotDirName = ".snapshot"
 )
 
+// CompressionStreamName is the name of the Stream (i.e. xattr) whose value, either CompressionStreamValueOn
+// or CompressionStreamValueOff, overrides a volume's CompressionEnabled setting for a FileInode. Note that
+// package fs propagates this Stream from a DirInode to the {Dir|File}Inodes created within it.
+const (
+	CompressionStreamName     = "proxyfs.compression"
+	CompressionStreamValueOff = "off"
+	CompressionStreamValueOn  = "on"
+)
+
+// CaseInsensitiveStreamName is the name of the Stream (i.e. xattr) whose value, either CaseInsensitiveStreamValueOn
+// or CaseInsensitiveStreamValueOff, overrides a volume's CaseInsensitiveDirs setting for lookups in a DirInode.
+// As it is meaningless for other Inode types, package fs propagates it only to the DirInodes created within it.
+const (
+	CaseInsensitiveStreamName     = "proxyfs.caseinsensitive"
+	CaseInsensitiveStreamValueOff = "off"
+	CaseInsensitiveStreamValueOn  = "on"
+)
+
+// PhysicalContainerLayoutStreamName is the name of the Stream (i.e. xattr) whose value, the name of one of the
+// volume's PhysicalContainerLayoutList, overrides a volume's DefaultPhysicalContainerLayout for a FileInode.
+// Note that package fs propagates this Stream from a DirInode to the {Dir|File}Inodes created within it.
+const PhysicalContainerLayoutStreamName = "proxyfs.layout"
+
+// ImmutableStreamName and AppendOnlyStreamName are the names of the Streams (i.e. xattrs) whose value, either
+// WORMStreamValueOn or WORMStreamValueOff, marks an Inode as immutable or append-only. RetainUntilStreamName is
+// the name of the Stream whose value, an RFC3339 timestamp, is the time until which an Inode is retained (i.e.
+// treated as immutable). A retention time may be extended but never shortened (nor removed before it passes).
+// Note that these Streams are interpreted (and enforced) by package fs.
+const (
+	ImmutableStreamName   = "proxyfs.immutable"
+	AppendOnlyStreamName  = "proxyfs.appendonly"
+	RetainUntilStreamName = "proxyfs.retainuntil"
+	WORMStreamValueOff    = "off"
+	WORMStreamValueOn     = "on"
+)
+
+// WORMStateStruct is returned by GetWORMState() to describe an Inode's ImmutableStreamName,
+// AppendOnlyStreamName, and RetainUntilStreamName Streams.
+type WORMStateStruct struct {
+	Immutable   bool
+	AppendOnly  bool
+	RetainUntil time.Time // zero if the Inode has never been retained
+}
+
+// Retained returns whether or not, at time now, the Inode's retention has yet to pass.
+func (wormState *WORMStateStruct) Retained(now time.Time) (retained bool) {
+	retained = now.Before(wormState.RetainUntil)
+	return
+}
+
 type RWModeType uint8
 
 const (
@@ -181,6 +278,14 @@ type VolumeHandle interface {
 	GetFSID() (fsid uint64)
 	SnapShotCreate(name string) (id uint64, err error)
 	SnapShotDelete(id uint64) (err error)
+	SnapShotDiff(fromSnapShotID uint64, toSnapShotID uint64, lastInodeNumber InodeNumber, maxEntries uint64) (diffEntries []SnapShotDiffEntry, moreEntries bool, err error)
+	SnapShotRevert(id uint64) (err error)
+	RetainedCheckpointInspect(id uint64) (err error)
+	RetainedCheckpointRevert(id uint64) (err error)
+	SnapShotRestore(snapShotID uint64, inodeNumber InodeNumber) (err error)
+	SnapShotClone(id uint64, cloneAccountName string, cloneCheckpointContainerName string, cloneCheckpointContainerStoragePolicy string) (err error)
+	FetchDedupStats() (dedupStats headhunter.DedupStatsStruct)
+	RebuildDedupRefCountsIfNecessary()
 
 	// Wrapper methods around DLM locks.  Implemented in locker.go
 
@@ -219,12 +324,16 @@ type VolumeHandle interface {
 	Optimize(inodeNumber InodeNumber, maxDuration time.Duration) (err error)
 	Validate(inodeNumber InodeNumber, deeply bool) (err error)
 
+	// WORM Stream methods, implemented in worm.go
+
+	GetWORMState(inodeNumber InodeNumber) (wormState WORMStateStruct, err error)
+
 	// Directory Inode specific methods, implemented in dir.go
 
 	CreateDir(filePerm InodeMode, userID InodeUserID, groupID InodeGroupID) (dirInodeNumber InodeNumber, err error)
 	Link(dirInodeNumber InodeNumber, basename string, targetInodeNumber InodeNumber, insertOnly bool) (err error)
 	Unlink(dirInodeNumber InodeNumber, basename string, removeOnly bool) (toDestroyInodeNumber InodeNumber, err error)
-	Move(srcDirInodeNumber InodeNumber, srcBasename string, dstDirInodeNumber InodeNumber, dstBasename string) (toDestroyInodeNumber InodeNumber, err error)
+	Move(srcDirInodeNumber InodeNumber, srcBasename string, dstDirInodeNumber InodeNumber, dstBasename string, flags MoveFlags) (toDestroyInodeNumber InodeNumber, err error)
 	Lookup(dirInodeNumber InodeNumber, basename string) (targetInodeNumber InodeNumber, err error)
 	NumDirEntries(dirInodeNumber InodeNumber) (numEntries uint64, err error)
 	ReadDir(dirInodeNumber InodeNumber, maxEntries uint64, maxBufSize uint64, prevReturned ...interface{}) (dirEntrySlice []DirEntry, moreEntries bool, err error)
@@ -244,6 +353,7 @@ type VolumeHandle interface {
 	Flush(fileInodeNumber InodeNumber, andPurge bool) (err error)
 	Coalesce(destInodeNumber InodeNumber, metaDataName string, metaData []byte, elements []*CoalesceElement) (attrChangeTime time.Time, modificationTime time.Time, numWrites uint64, fileSize uint64, err error)
 	DefragmentFile(fileInodeNumber InodeNumber, startingFileOffset uint64, chunkSize uint64) (nextFileOffset uint64, eofReached bool, err error)
+	FileInPhysicalContainerLayout(fileInodeNumber InodeNumber) (inLayout bool, err error)
 
 	// Symlink Inode specific methods, implemented in symlink.go
 
@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/NVIDIA/proxyfs/blunder"
 	"github.com/NVIDIA/proxyfs/swiftclient"
 	"github.com/NVIDIA/proxyfs/utils"
 	"github.com/stretchr/testify/assert"
@@ -1343,7 +1344,7 @@ func TestAPI(t *testing.T) {
 		t.Fatalf("ReadDir(RootDirInodeNumber, 0, 0) returned unexpected dirEntrySlice[2]")
 	}
 
-	toDestroyInodeNumber, err = testVolumeHandle.Move(RootDirInodeNumber, "1stLocation", RootDirInodeNumber, "2ndLocation")
+	toDestroyInodeNumber, err = testVolumeHandle.Move(RootDirInodeNumber, "1stLocation", RootDirInodeNumber, "2ndLocation", MoveFlagsNone)
 	if nil != err {
 		t.Fatalf("Move(RootDirInodeNumber, \"1stLocation\", RootDirInodeNumber, \"2ndLocation\") failed: %v", err)
 	}
@@ -1381,7 +1382,7 @@ func TestAPI(t *testing.T) {
 	if file2Inode != toDestroyInodeNumber {
 		t.Fatalf("Unlink(RootDirInodeNumber, \"3rdLocation\", false) should have returned toDestroyInodeNumber == file2Inode")
 	}
-	toDestroyInodeNumber, err = testVolumeHandle.Move(RootDirInodeNumber, "2ndLocation", RootDirInodeNumber, "3rdLocation")
+	toDestroyInodeNumber, err = testVolumeHandle.Move(RootDirInodeNumber, "2ndLocation", RootDirInodeNumber, "3rdLocation", MoveFlagsNone)
 	if nil != err {
 		t.Fatalf("Move(RootDirInodeNumber, \"2ndLocation\", RootDirInodeNumber, \"3rdLocation\") failed: %v", err)
 	}
@@ -1537,7 +1538,7 @@ func TestAPI(t *testing.T) {
 		t.Fatalf("ReadDir(subDirInode, 0, 0) returned unexpected dirEntrySlice[1]")
 	}
 
-	toDestroyInodeNumber, err = testVolumeHandle.Move(RootDirInodeNumber, "3rdLocation", subDirInode, "4thLocation")
+	toDestroyInodeNumber, err = testVolumeHandle.Move(RootDirInodeNumber, "3rdLocation", subDirInode, "4thLocation", MoveFlagsNone)
 	if nil != err {
 		t.Fatalf("Move(RootDirInodeNumber, \"3rdLocation\", subDirInode, \"4thLocation\") failed: %v", err)
 	}
@@ -2087,7 +2088,7 @@ func TestAPI(t *testing.T) {
 
 	time.Sleep(positiveDurationToDelayOrSkew)
 
-	toDestroyInodeNumber, err = testVolumeHandle.Move(dirInode, "loc_1", dirInode, "loc_2")
+	toDestroyInodeNumber, err = testVolumeHandle.Move(dirInode, "loc_1", dirInode, "loc_2", MoveFlagsNone)
 	if nil != err {
 		t.Fatalf("Move(dirInode, \"loc_1\", dirInode, \"loc_2\") failed: %v", err)
 	}
@@ -2326,3 +2327,198 @@ func TestInodeDiscard(t *testing.T) {
 
 	testTeardown(t)
 }
+
+func TestMoveFlags(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") should have worked - got error: %v", err)
+	}
+
+	fileAInode, err := testVolumeHandle.CreateFile(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(RootDirInodeNumber, "fileA", fileAInode, false)
+	if nil != err {
+		t.Fatalf("Link(RootDirInodeNumber, \"fileA\", fileAInode, false) failed: %v", err)
+	}
+
+	fileBInode, err := testVolumeHandle.CreateFile(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(RootDirInodeNumber, "fileB", fileBInode, false)
+	if nil != err {
+		t.Fatalf("Link(RootDirInodeNumber, \"fileB\", fileBInode, false) failed: %v", err)
+	}
+
+	subDirInode, err := testVolumeHandle.CreateDir(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(RootDirInodeNumber, "subDir", subDirInode, false)
+	if nil != err {
+		t.Fatalf("Link(RootDirInodeNumber, \"subDir\", subDirInode, false) failed: %v", err)
+	}
+
+	// Unsupported & conflicting flags must be rejected
+
+	_, err = testVolumeHandle.Move(RootDirInodeNumber, "fileA", RootDirInodeNumber, "fileB", MoveFlagNoReplace|MoveFlagExchange)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("Move(,,,,MoveFlagNoReplace|MoveFlagExchange) should have failed with InvalidArgError: %v", err)
+	}
+	_, err = testVolumeHandle.Move(RootDirInodeNumber, "fileA", RootDirInodeNumber, "fileB", MoveFlags(1<<2))
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("Move(,,,,RENAME_WHITEOUT) should have failed with InvalidArgError: %v", err)
+	}
+
+	// MoveFlagNoReplace must fail if the target exists and succeed otherwise
+
+	_, err = testVolumeHandle.Move(RootDirInodeNumber, "fileA", RootDirInodeNumber, "fileB", MoveFlagNoReplace)
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("Move(RootDirInodeNumber, \"fileA\", RootDirInodeNumber, \"fileB\", MoveFlagNoReplace) should have failed with FileExistsError: %v", err)
+	}
+	lookupInode, err := testVolumeHandle.Lookup(RootDirInodeNumber, "fileB")
+	if (nil != err) || (fileBInode != lookupInode) {
+		t.Fatalf("Lookup(RootDirInodeNumber, \"fileB\") should have returned fileBInode after failed MoveFlagNoReplace")
+	}
+
+	toDestroyInodeNumber, err := testVolumeHandle.Move(RootDirInodeNumber, "fileA", RootDirInodeNumber, "fileC", MoveFlagNoReplace)
+	if nil != err {
+		t.Fatalf("Move(RootDirInodeNumber, \"fileA\", RootDirInodeNumber, \"fileC\", MoveFlagNoReplace) failed: %v", err)
+	}
+	if InodeNumber(0) != toDestroyInodeNumber {
+		t.Fatalf("Move(,,,,MoveFlagNoReplace) should have returned toDestroyInodeNumber == 0")
+	}
+
+	// MoveFlagExchange must fail if the target doesn't exist
+
+	_, err = testVolumeHandle.Move(RootDirInodeNumber, "fileC", RootDirInodeNumber, "fileD", MoveFlagExchange)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Move(RootDirInodeNumber, \"fileC\", RootDirInodeNumber, \"fileD\", MoveFlagExchange) should have failed with NotFoundError: %v", err)
+	}
+
+	// MoveFlagExchange of two files in the same directory
+
+	toDestroyInodeNumber, err = testVolumeHandle.Move(RootDirInodeNumber, "fileC", RootDirInodeNumber, "fileB", MoveFlagExchange)
+	if nil != err {
+		t.Fatalf("Move(RootDirInodeNumber, \"fileC\", RootDirInodeNumber, \"fileB\", MoveFlagExchange) failed: %v", err)
+	}
+	if InodeNumber(0) != toDestroyInodeNumber {
+		t.Fatalf("Move(,,,,MoveFlagExchange) should have returned toDestroyInodeNumber == 0")
+	}
+	lookupInode, err = testVolumeHandle.Lookup(RootDirInodeNumber, "fileB")
+	if (nil != err) || (fileAInode != lookupInode) {
+		t.Fatalf("Lookup(RootDirInodeNumber, \"fileB\") should have returned fileAInode after MoveFlagExchange")
+	}
+	lookupInode, err = testVolumeHandle.Lookup(RootDirInodeNumber, "fileC")
+	if (nil != err) || (fileBInode != lookupInode) {
+		t.Fatalf("Lookup(RootDirInodeNumber, \"fileC\") should have returned fileBInode after MoveFlagExchange")
+	}
+
+	// MoveFlagExchange of a directory and a file in different directories
+
+	rootDirLinkCountBefore, err := testVolumeHandle.GetLinkCount(RootDirInodeNumber)
+	if nil != err {
+		t.Fatalf("GetLinkCount(RootDirInodeNumber) failed: %v", err)
+	}
+
+	subSubDirInode, err := testVolumeHandle.CreateDir(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(subDirInode, "subSubDir", subSubDirInode, false)
+	if nil != err {
+		t.Fatalf("Link(subDirInode, \"subSubDir\", subSubDirInode, false) failed: %v", err)
+	}
+
+	_, err = testVolumeHandle.Move(subDirInode, "subSubDir", RootDirInodeNumber, "fileC", MoveFlagExchange)
+	if nil != err {
+		t.Fatalf("Move(subDirInode, \"subSubDir\", RootDirInodeNumber, \"fileC\", MoveFlagExchange) failed: %v", err)
+	}
+	lookupInode, err = testVolumeHandle.Lookup(RootDirInodeNumber, "fileC")
+	if (nil != err) || (subSubDirInode != lookupInode) {
+		t.Fatalf("Lookup(RootDirInodeNumber, \"fileC\") should have returned subSubDirInode after MoveFlagExchange")
+	}
+	lookupInode, err = testVolumeHandle.Lookup(subDirInode, "subSubDir")
+	if (nil != err) || (fileBInode != lookupInode) {
+		t.Fatalf("Lookup(subDirInode, \"subSubDir\") should have returned fileBInode after MoveFlagExchange")
+	}
+	lookupInode, err = testVolumeHandle.Lookup(subSubDirInode, "..")
+	if (nil != err) || (RootDirInodeNumber != lookupInode) {
+		t.Fatalf("Lookup(subSubDirInode, \"..\") should have returned RootDirInodeNumber after MoveFlagExchange")
+	}
+	rootDirLinkCountAfter, err := testVolumeHandle.GetLinkCount(RootDirInodeNumber)
+	if nil != err {
+		t.Fatalf("GetLinkCount(RootDirInodeNumber) failed: %v", err)
+	}
+	if (rootDirLinkCountBefore + 1) != rootDirLinkCountAfter {
+		t.Fatalf("RootDirInodeNumber LinkCount should have been incremented by MoveFlagExchange")
+	}
+	subDirLinkCount, err := testVolumeHandle.GetLinkCount(subDirInode)
+	if nil != err {
+		t.Fatalf("GetLinkCount(subDirInode) failed: %v", err)
+	}
+	if 2 != subDirLinkCount {
+		t.Fatalf("subDirInode LinkCount should have been returned to 2 by MoveFlagExchange")
+	}
+
+	testTeardown(t)
+}
+
+func TestFetchFragmentationReport(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") should have worked - got error: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	for _, length := range []int{1000, 500} {
+		err = testVolumeHandle.Write(fileInodeNumber, 0, make([]byte, length), nil)
+		if nil != err {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		err = testVolumeHandle.Flush(fileInodeNumber, false)
+		if nil != err {
+			t.Fatalf("Flush() failed: %v", err)
+		}
+	}
+
+	// The length of each closed LogSegment should be known without resorting to a HEAD
+
+	fileInode, err := volume.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	if 2 != len(fileInode.LogSegmentMap) {
+		t.Fatalf("Expected 2 LogSegments but found %v", len(fileInode.LogSegmentMap))
+	}
+	for logSegmentNumber := range fileInode.LogSegmentMap {
+		volume.logSegmentLengthCacheLock.Lock()
+		_, ok := volume.logSegmentLengthCache[logSegmentNumber]
+		volume.logSegmentLengthCacheLock.Unlock()
+		if !ok {
+			t.Fatalf("Length of LogSegment 0x%016X should have been recorded when it was closed", logSegmentNumber)
+		}
+	}
+
+	fragmentationReport, err := testVolumeHandle.FetchFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("FetchFragmentationReport() failed: %v", err)
+	}
+	if (2 != fragmentationReport.NumberOfFragments) || (1000 != fragmentationReport.BytesInFragments) || (500 != fragmentationReport.BytesTrapped) {
+		t.Fatalf("FetchFragmentationReport() returned unexpected %#v", fragmentationReport)
+	}
+
+	testTeardown(t)
+}
@@ -0,0 +1,332 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/NVIDIA/sortedmap"
+)
+
+// DirInodes for which case-insensitivity is enabled (either via the volume's CaseInsensitiveDirs
+// setting or, overriding that, the DirInode's CaseInsensitiveStreamName Stream) remain keyed on the
+// exact (i.e. case-preserved) basenames of their entries such that ReadDir() is unaffected. Lookups
+// that fail to find an exact match consult a secondary index, a B+Tree persisted alongside the
+// directory's own (see onDiskInodeV1Struct.CaseFoldObjectNumber), keyed by each case-folded basename
+// followed by "/" and the actual basename. As "/" never appears in a basename, the entries differing
+// only by case share a key prefix and are ordered by their actual basename.
+//
+// The index is built from the directory's B+Tree upon first need and, once built, kept current as
+// entries are added and removed. It is discarded should case-insensitivity be disabled for the
+// DirInode. Link() and Move() reject a basename colliding with an existing entry that differs only
+// by case.
+
+// caseFold returns the form of basename used to key the caseFoldIndex. Each rune is replaced with
+// the lowest rune of its unicode.SimpleFold orbit such that two basenames fold identically exactly
+// when strings.EqualFold() reports them equal. A basename that is not valid UTF-8 is left as is.
+func caseFold(basename string) (caseFoldedBasename string) {
+	if !utf8.ValidString(basename) {
+		caseFoldedBasename = basename
+		return
+	}
+
+	caseFoldedBasename = strings.Map(func(r rune) (foldedRune rune) {
+		foldedRune = r
+		for orbitRune := unicode.SimpleFold(r); orbitRune != r; orbitRune = unicode.SimpleFold(orbitRune) {
+			if orbitRune < foldedRune {
+				foldedRune = orbitRune
+			}
+		}
+		return
+	}, basename)
+
+	return
+}
+
+// caseFoldIndexKeyPrefix returns the prefix shared by the caseFoldIndex keys of every basename
+// differing from basename only by case.
+func caseFoldIndexKeyPrefix(basename string) (keyPrefix string) {
+	keyPrefix = caseFold(basename) + "/"
+	return
+}
+
+// caseInsensitiveDir returns whether or not lookups in dirInode should be case-insensitive.
+func (vS *volumeStruct) caseInsensitiveDir(dirInode *inMemoryInodeStruct) (caseInsensitive bool) {
+	var (
+		ok          bool
+		streamValue []byte
+	)
+
+	streamValue, ok = dirInode.StreamMap[CaseInsensitiveStreamName]
+	if ok {
+		switch string(streamValue) {
+		case CaseInsensitiveStreamValueOn:
+			caseInsensitive = true
+			return
+		case CaseInsensitiveStreamValueOff:
+			caseInsensitive = false
+			return
+		}
+	}
+
+	caseInsensitive = vS.caseInsensitiveDirs
+	return
+}
+
+// loadCaseFoldIndex attaches the persisted caseFoldIndex (if any) of a just fetched dirInode.
+func (vS *volumeStruct) loadCaseFoldIndex(dirInode *inMemoryInodeStruct) (err error) {
+	if 0 == dirInode.CaseFoldObjectNumber {
+		err = nil
+		return
+	}
+
+	dirInode.caseFoldIndex, err =
+		sortedmap.OldBPlusTree(
+			dirInode.CaseFoldObjectNumber,
+			onDiskInodeV1PayloadObjectOffset,
+			dirInode.CaseFoldObjectLength,
+			sortedmap.CompareString,
+			&caseFoldIndexCallbacks{dirInodeCallbacks{treeNodeLoadable{inode: dirInode}}},
+			vS.dirEntryCache.Cache())
+
+	return
+}
+
+// fetchCaseFoldIndex returns dirInode's caseFoldIndex, building it first if necessary. As lookups
+// hold only a shared lock on dirInode, the build is serialized by dirInode.caseFoldIndexMutex. A
+// just built caseFoldIndex is persisted by the next flush of dirInode.
+func (vS *volumeStruct) fetchCaseFoldIndex(dirInode *inMemoryInodeStruct) (caseFoldIndex sortedmap.BPlusTree) {
+	var (
+		basename      string
+		dirEntryIndex int
+		dirMapping    sortedmap.BPlusTree
+		dirMappingLen int
+		err           error
+		key           sortedmap.Key
+		ok            bool
+	)
+
+	dirInode.caseFoldIndexMutex.Lock()
+	defer dirInode.caseFoldIndexMutex.Unlock()
+
+	if nil != dirInode.caseFoldIndex {
+		caseFoldIndex = dirInode.caseFoldIndex
+		return
+	}
+
+	caseFoldIndex =
+		sortedmap.NewBPlusTree(
+			vS.maxEntriesPerDirNode,
+			sortedmap.CompareString,
+			&caseFoldIndexCallbacks{dirInodeCallbacks{treeNodeLoadable{inode: dirInode}}},
+			vS.dirEntryCache.Cache())
+
+	dirMapping = dirInode.payload.(sortedmap.BPlusTree)
+
+	dirMappingLen, err = dirMapping.Len()
+	if nil != err {
+		panic(err)
+	}
+
+	for dirEntryIndex = 0; dirEntryIndex < dirMappingLen; dirEntryIndex++ {
+		key, _, ok, err = dirMapping.GetByIndex(dirEntryIndex)
+		if nil != err {
+			panic(err)
+		}
+		if !ok {
+			err = fmt.Errorf("dirMapping.GetByIndex(%v) of dirInode 0x%016X should have returned ok == true", dirEntryIndex, dirInode.InodeNumber)
+			panic(err)
+		}
+
+		basename = key.(string)
+
+		_, err = caseFoldIndex.Put(caseFoldIndexKeyPrefix(basename)+basename, basename)
+		if nil != err {
+			panic(err)
+		}
+	}
+
+	dirInode.caseFoldIndex = caseFoldIndex
+
+	return
+}
+
+// caseFoldIndexAdded updates dirInode's caseFoldIndex (if built) to include basename.
+func caseFoldIndexAdded(dirInode *inMemoryInodeStruct, basename string) {
+	var (
+		err error
+	)
+
+	dirInode.caseFoldIndexMutex.Lock()
+	defer dirInode.caseFoldIndexMutex.Unlock()
+
+	if nil == dirInode.caseFoldIndex {
+		return
+	}
+
+	_, err = dirInode.caseFoldIndex.Put(caseFoldIndexKeyPrefix(basename)+basename, basename)
+	if nil != err {
+		panic(err)
+	}
+}
+
+// caseFoldIndexRemoved updates dirInode's caseFoldIndex (if built) to no longer include basename.
+// Any other entries differing from basename only by case remain indexed.
+func caseFoldIndexRemoved(dirInode *inMemoryInodeStruct, basename string) {
+	var (
+		err error
+	)
+
+	dirInode.caseFoldIndexMutex.Lock()
+	defer dirInode.caseFoldIndexMutex.Unlock()
+
+	if nil == dirInode.caseFoldIndex {
+		return
+	}
+
+	_, err = dirInode.caseFoldIndex.DeleteByKey(caseFoldIndexKeyPrefix(basename) + basename)
+	if nil != err {
+		panic(err)
+	}
+}
+
+// discardCaseFoldIndex discards dirInode's caseFoldIndex (if built), including any persisted nodes.
+// The caller is expected to flush dirInode.
+func discardCaseFoldIndex(dirInode *inMemoryInodeStruct) (err error) {
+	dirInode.caseFoldIndexMutex.Lock()
+	defer dirInode.caseFoldIndexMutex.Unlock()
+
+	if nil == dirInode.caseFoldIndex {
+		err = nil
+		return
+	}
+
+	err = dirInode.caseFoldIndex.Discard()
+	if nil != err {
+		return
+	}
+
+	dirInode.caseFoldIndex = nil
+	dirInode.CaseFoldObjectNumber = 0
+	dirInode.CaseFoldObjectLength = 0
+	dirInode.dirty = true
+
+	return
+}
+
+// flushCaseFoldIndex persists dirInode's caseFoldIndex (if built) as part of flushing dirInode.
+func flushCaseFoldIndex(dirInode *inMemoryInodeStruct) (err error) {
+	var (
+		caseFoldObjectLength uint64
+		caseFoldObjectNumber uint64
+		caseFoldObjectOffset uint64
+	)
+
+	dirInode.caseFoldIndexMutex.Lock()
+	defer dirInode.caseFoldIndexMutex.Unlock()
+
+	if nil == dirInode.caseFoldIndex {
+		err = nil
+		return
+	}
+
+	caseFoldObjectNumber, caseFoldObjectOffset, caseFoldObjectLength, err = dirInode.caseFoldIndex.Flush(false)
+	if nil != err {
+		return
+	}
+	if onDiskInodeV1PayloadObjectOffset != caseFoldObjectOffset {
+		err = fmt.Errorf("Logic Error: caseFoldIndex.Flush() should have returned caseFoldObjectOffset == %v", onDiskInodeV1PayloadObjectOffset)
+		return
+	}
+
+	err = dirInode.caseFoldIndex.Prune()
+	if nil != err {
+		return
+	}
+
+	if (caseFoldObjectNumber != dirInode.CaseFoldObjectNumber) || (caseFoldObjectLength != dirInode.CaseFoldObjectLength) {
+		// Note that a caseFoldIndex just built under a shared lock did not itself dirty dirInode
+
+		dirInode.CaseFoldObjectNumber = caseFoldObjectNumber
+		dirInode.CaseFoldObjectLength = caseFoldObjectLength
+		dirInode.dirty = true
+	}
+
+	return
+}
+
+// fetchCaseFoldIndexLayoutReport returns the sortedmap.LayoutReport of dirInode's caseFoldIndex (if built).
+func fetchCaseFoldIndexLayoutReport(dirInode *inMemoryInodeStruct) (layoutReport sortedmap.LayoutReport, err error) {
+	dirInode.caseFoldIndexMutex.Lock()
+	defer dirInode.caseFoldIndexMutex.Unlock()
+
+	if nil == dirInode.caseFoldIndex {
+		layoutReport = make(sortedmap.LayoutReport)
+		err = nil
+		return
+	}
+
+	layoutReport, err = dirInode.caseFoldIndex.FetchLayoutReport()
+
+	return
+}
+
+// resolveBasename returns the actual basename of the entry in dirInode matching basename. If dirInode
+// is case-insensitive and contains no exact match, an entry differing only by case is returned instead
+// (the first in sorted order should there be several). If no entry matches, basename is returned unchanged.
+func (vS *volumeStruct) resolveBasename(dirInode *inMemoryInodeStruct, basename string) (actualBasename string) {
+	var (
+		caseFoldIndex sortedmap.BPlusTree
+		err           error
+		index         int
+		key           sortedmap.Key
+		keyPrefix     string
+		ok            bool
+		value         sortedmap.Value
+	)
+
+	actualBasename = basename
+
+	if !vS.caseInsensitiveDir(dirInode) {
+		return
+	}
+
+	_, ok, err = dirInode.payload.(sortedmap.BPlusTree).GetByKey(basename)
+	if nil != err {
+		panic(err)
+	}
+	if ok {
+		return
+	}
+
+	caseFoldIndex = vS.fetchCaseFoldIndex(dirInode)
+
+	keyPrefix = caseFoldIndexKeyPrefix(basename)
+
+	index, _, err = caseFoldIndex.BisectRight(keyPrefix)
+	if nil != err {
+		panic(err)
+	}
+
+	key, value, ok, err = caseFoldIndex.GetByIndex(index)
+	if nil != err {
+		panic(err)
+	}
+	if ok && strings.HasPrefix(key.(string), keyPrefix) && strings.EqualFold(value.(string), basename) {
+		actualBasename = value.(string)
+	}
+
+	return
+}
+
+// caseCollision returns the basename of any entry in a case-insensitive dirInode differing from
+// basename only by case. An exact match (or a case-sensitive dirInode) is never a collision.
+func (vS *volumeStruct) caseCollision(dirInode *inMemoryInodeStruct, basename string) (collidingBasename string, collides bool) {
+	collidingBasename = vS.resolveBasename(dirInode, basename)
+	collides = (collidingBasename != basename)
+	return
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+)
+
+func TestCaseInsensitiveDir(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") should have worked - got error: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	dirInodeNumber, err := testVolumeHandle.CreateDir(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(RootDirInodeNumber, "CaseDir", dirInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Link() of CaseDir failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(dirInodeNumber, "MixedCase", fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Link() of MixedCase failed: %v", err)
+	}
+
+	// Lookups are case-sensitive by default
+
+	_, err = testVolumeHandle.Lookup(dirInodeNumber, "mixedcase")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup() of \"mixedcase\" in case-sensitive DirInode should have failed with NotFoundError - got: %v", err)
+	}
+
+	otherFileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(dirInodeNumber, "MIXEDCASE", otherFileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Link() of MIXEDCASE in case-sensitive DirInode failed: %v", err)
+	}
+	_, err = testVolumeHandle.Unlink(dirInodeNumber, "MIXEDCASE", false)
+	if nil != err {
+		t.Fatalf("Unlink() of MIXEDCASE failed: %v", err)
+	}
+
+	// Now enable case-insensitivity via the Stream
+
+	err = testVolumeHandle.PutStream(dirInodeNumber, CaseInsensitiveStreamName, []byte(CaseInsensitiveStreamValueOn))
+	if nil != err {
+		t.Fatalf("PutStream() failed: %v", err)
+	}
+
+	lookupInodeNumber, err := testVolumeHandle.Lookup(dirInodeNumber, "mixedcase")
+	if nil != err {
+		t.Fatalf("Lookup() of \"mixedcase\" in case-insensitive DirInode failed: %v", err)
+	}
+	if fileInodeNumber != lookupInodeNumber {
+		t.Fatalf("Lookup() of \"mixedcase\" returned unexpected InodeNumber")
+	}
+
+	otherFileInodeNumber, err = testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(dirInodeNumber, "MIXEDCASE", otherFileInodeNumber, false)
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("Link() of MIXEDCASE in case-insensitive DirInode should have failed with FileExistsError - got: %v", err)
+	}
+	err = testVolumeHandle.Link(dirInodeNumber, "Other", otherFileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Link() of Other failed: %v", err)
+	}
+
+	_, err = testVolumeHandle.Move(dirInodeNumber, "other", dirInodeNumber, "mixedCASE", 0)
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("Move() onto \"mixedCASE\" should have failed with FileExistsError - got: %v", err)
+	}
+
+	// A change of case of an entry's own basename is allowed
+
+	_, err = testVolumeHandle.Move(dirInodeNumber, "mixedcase", dirInodeNumber, "mixedCase", 0)
+	if nil != err {
+		t.Fatalf("Move() of \"mixedcase\" to \"mixedCase\" failed: %v", err)
+	}
+
+	dirEntries, _, err := testVolumeHandle.ReadDir(dirInodeNumber, 0, 0)
+	if nil != err {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	basenames := make(map[string]InodeNumber)
+	for _, dirEntry := range dirEntries {
+		basenames[dirEntry.Basename] = dirEntry.InodeNumber
+	}
+	if (4 != len(basenames)) || (fileInodeNumber != basenames["mixedCase"]) || (otherFileInodeNumber != basenames["Other"]) {
+		t.Fatalf("ReadDir() should have preserved case of basenames - got %v", basenames)
+	}
+
+	_, err = testVolumeHandle.Unlink(dirInodeNumber, "OTHER", false)
+	if nil != err {
+		t.Fatalf("Unlink() of \"OTHER\" failed: %v", err)
+	}
+	_, err = testVolumeHandle.Lookup(dirInodeNumber, "other")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup() of unlinked \"other\" should have failed with NotFoundError - got: %v", err)
+	}
+
+	// The volume-wide setting is overridden by the Stream
+
+	volume.caseInsensitiveDirs = true
+
+	err = testVolumeHandle.PutStream(dirInodeNumber, CaseInsensitiveStreamName, []byte(CaseInsensitiveStreamValueOff))
+	if nil != err {
+		t.Fatalf("PutStream() failed: %v", err)
+	}
+	_, err = testVolumeHandle.Lookup(dirInodeNumber, "MIXEDCASE")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup() of \"MIXEDCASE\" with Stream overriding volume setting should have failed with NotFoundError - got: %v", err)
+	}
+
+	err = testVolumeHandle.DeleteStream(dirInodeNumber, CaseInsensitiveStreamName)
+	if nil != err {
+		t.Fatalf("DeleteStream() failed: %v", err)
+	}
+	lookupInodeNumber, err = testVolumeHandle.Lookup(dirInodeNumber, "MIXEDCASE")
+	if nil != err {
+		t.Fatalf("Lookup() of \"MIXEDCASE\" with volume setting failed: %v", err)
+	}
+	if fileInodeNumber != lookupInodeNumber {
+		t.Fatalf("Lookup() of \"MIXEDCASE\" returned unexpected InodeNumber")
+	}
+
+	volume.caseInsensitiveDirs = false
+
+	testTeardown(t)
+}
+
+func TestCaseFoldIndex(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") should have worked - got error: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	dirInodeNumber, err := testVolumeHandle.CreateDir(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+	err = testVolumeHandle.Link(RootDirInodeNumber, "CaseFoldDir", dirInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Link() of CaseFoldDir failed: %v", err)
+	}
+
+	// While still case-sensitive, create entries differing only by case as well as one
+	// whose case-insensitive match requires Unicode (not simply ASCII) case folding...
+	// "\u212A" (KELVIN SIGN) folds with "K" & "k" yet is unchanged by strings.ToUpper()
+
+	fileInodeNumbers := make(map[string]InodeNumber)
+
+	for _, basename := range []string{"DUP", "Dup", "\u212Aelvin"} {
+		fileInodeNumbers[basename], err = testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+		if nil != err {
+			t.Fatalf("CreateFile() failed: %v", err)
+		}
+		err = testVolumeHandle.Link(dirInodeNumber, basename, fileInodeNumbers[basename], false)
+		if nil != err {
+			t.Fatalf("Link() of %q failed: %v", basename, err)
+		}
+	}
+
+	err = testVolumeHandle.PutStream(dirInodeNumber, CaseInsensitiveStreamName, []byte(CaseInsensitiveStreamValueOn))
+	if nil != err {
+		t.Fatalf("PutStream() failed: %v", err)
+	}
+
+	lookupInodeNumber, err := testVolumeHandle.Lookup(dirInodeNumber, "kelvin")
+	if nil != err {
+		t.Fatalf("Lookup() of \"kelvin\" failed: %v", err)
+	}
+	if fileInodeNumbers["\u212Aelvin"] != lookupInodeNumber {
+		t.Fatalf("Lookup() of \"kelvin\" returned unexpected InodeNumber")
+	}
+
+	lookupInodeNumber, err = testVolumeHandle.Lookup(dirInodeNumber, "dup")
+	if nil != err {
+		t.Fatalf("Lookup() of \"dup\" failed: %v", err)
+	}
+	if fileInodeNumbers["DUP"] != lookupInodeNumber {
+		t.Fatalf("Lookup() of \"dup\" should have found \"DUP\" (first in sorted order)")
+	}
+
+	// Removing one of the entries differing only by case leaves the other findable
+
+	_, err = testVolumeHandle.Unlink(dirInodeNumber, "DUP", false)
+	if nil != err {
+		t.Fatalf("Unlink() of \"DUP\" failed: %v", err)
+	}
+
+	dirInode, ok, err := volume.fetchInode(dirInodeNumber)
+	if (nil != err) || !ok {
+		t.Fatalf("fetchInode(dirInodeNumber) failed: %v", err)
+	}
+	if nil == dirInode.caseFoldIndex {
+		t.Fatalf("Unlink() should not have discarded the caseFoldIndex")
+	}
+
+	lookupInodeNumber, err = testVolumeHandle.Lookup(dirInodeNumber, "dup")
+	if nil != err {
+		t.Fatalf("Lookup() of \"dup\" after Unlink() of \"DUP\" failed: %v", err)
+	}
+	if fileInodeNumbers["Dup"] != lookupInodeNumber {
+		t.Fatalf("Lookup() of \"dup\" after Unlink() of \"DUP\" should have found \"Dup\"")
+	}
+
+	// The caseFoldIndex is persisted... so is present upon refetching the DirInode
+
+	if 0 == dirInode.CaseFoldObjectNumber {
+		t.Fatalf("caseFoldIndex should have been persisted by Unlink()")
+	}
+
+	ok, err = volume.inodeCacheDrop(dirInode)
+	if (nil != err) || !ok {
+		t.Fatalf("inodeCacheDrop(dirInode) failed: %v", err)
+	}
+
+	dirInode, ok, err = volume.fetchInode(dirInodeNumber)
+	if (nil != err) || !ok {
+		t.Fatalf("fetchInode(dirInodeNumber) failed: %v", err)
+	}
+	if nil == dirInode.caseFoldIndex {
+		t.Fatalf("Refetched dirInode should have loaded its persisted caseFoldIndex")
+	}
+
+	lookupInodeNumber, err = testVolumeHandle.Lookup(dirInodeNumber, "KELVIN")
+	if nil != err {
+		t.Fatalf("Lookup() of \"KELVIN\" in refetched dirInode failed: %v", err)
+	}
+	if fileInodeNumbers["\u212Aelvin"] != lookupInodeNumber {
+		t.Fatalf("Lookup() of \"KELVIN\" in refetched dirInode returned unexpected InodeNumber")
+	}
+
+	layoutReport, err := testVolumeHandle.FetchLayoutReport(dirInodeNumber)
+	if nil != err {
+		t.Fatalf("FetchLayoutReport(dirInodeNumber) failed: %v", err)
+	}
+	_, ok = layoutReport[dirInode.CaseFoldObjectNumber]
+	if !ok {
+		t.Fatalf("FetchLayoutReport(dirInodeNumber) should have included the caseFoldIndex root")
+	}
+
+	// Disabling case-insensitivity discards the caseFoldIndex
+
+	err = testVolumeHandle.PutStream(dirInodeNumber, CaseInsensitiveStreamName, []byte(CaseInsensitiveStreamValueOff))
+	if nil != err {
+		t.Fatalf("PutStream() failed: %v", err)
+	}
+	if (nil != dirInode.caseFoldIndex) || (0 != dirInode.CaseFoldObjectNumber) {
+		t.Fatalf("Disabling case-insensitivity should have discarded the caseFoldIndex")
+	}
+
+	testTeardown(t)
+}
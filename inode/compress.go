@@ -0,0 +1,254 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/logger"
+)
+
+// Write()'s of a FileInode for which compression is enabled (either via the volume's CompressionEnabled
+// setting or, overriding that, the FileInode's CompressionStreamName Stream) are split into frames of
+// at most compressionFrameSize bytes each compressed independently. Frames that compress well are
+// appended to the open LogSegment in their compressed form and recorded as extents noting both their
+// logical length and the physical length of the compressed frame. Reads decompress such frames
+// transparently. GetReadPlan() returns steps referencing each compressed frame (along with its
+// CompressedLength and the FrameOffset of the portion called for) such that its consumer (e.g. the
+// middleware) fetches and decompresses frames itself as needed rather than this package holding an
+// entire decompressed file in memory. As an ExtentMap cannot describe compressed frames,
+// FetchExtentMapChunk() returns data read (and decompressed) here in lieu of each (bounded) chunk's
+// compressed frames.
+//
+// Note that Write()'s that are deduplicated (see dedup.go) are not also compressed.
+
+const (
+	compressionFrameSize = uint64(64 * 1024)
+)
+
+// compressionEnabledForFileInode returns whether or not Write()'s to fileInode should be compressed.
+func (vS *volumeStruct) compressionEnabledForFileInode(fileInode *inMemoryInodeStruct) (compressionEnabled bool) {
+	var (
+		ok          bool
+		streamValue []byte
+	)
+
+	streamValue, ok = fileInode.StreamMap[CompressionStreamName]
+	if ok {
+		switch string(streamValue) {
+		case CompressionStreamValueOn:
+			compressionEnabled = true
+			return
+		case CompressionStreamValueOff:
+			compressionEnabled = false
+			return
+		}
+	}
+
+	compressionEnabled = vS.compressionEnabled
+	return
+}
+
+// compressFrame returns the compressed form of frame.
+func compressFrame(frame []byte) (compressedFrame []byte, err error) {
+	var (
+		compressedFrameBuffer bytes.Buffer
+		flateWriter           *flate.Writer
+	)
+
+	flateWriter, err = flate.NewWriter(&compressedFrameBuffer, flate.BestSpeed)
+	if nil != err {
+		return
+	}
+
+	_, err = flateWriter.Write(frame)
+	if nil != err {
+		return
+	}
+
+	err = flateWriter.Close()
+	if nil != err {
+		return
+	}
+
+	compressedFrame = compressedFrameBuffer.Bytes()
+
+	return
+}
+
+// decompressFrame returns the decompressed form of compressedFrame.
+func decompressFrame(compressedFrame []byte) (frame []byte, err error) {
+	var (
+		flateReader io.ReadCloser
+	)
+
+	startTime := time.Now()
+	defer func() {
+		globals.DecompressionUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+		if nil != err {
+			globals.DecompressionErrors.Add(1)
+		}
+	}()
+
+	flateReader = flate.NewReader(bytes.NewReader(compressedFrame))
+
+	frame, err = ioutil.ReadAll(flateReader)
+	if nil != err {
+		_ = flateReader.Close()
+		return
+	}
+
+	err = flateReader.Close()
+
+	return // err as returned by flateReader.Close() is sufficient
+}
+
+// compressedWrite records buf at offset in fileInode one compressed frame at a time. Frames that
+// do not compress are stored as is.
+func (vS *volumeStruct) compressedWrite(fileInode *inMemoryInodeStruct, offset uint64, buf []byte) (err error) {
+	var (
+		compressedFrame  []byte
+		frame            []byte
+		frameLength      uint64
+		logSegmentNumber uint64
+		logSegmentOffset uint64
+	)
+
+	for 0 < len(buf) {
+		frameLength = uint64(len(buf))
+		if frameLength > compressionFrameSize {
+			frameLength = compressionFrameSize
+		}
+
+		frame = buf[:frameLength]
+
+		compressedFrame, err = compressFrame(frame)
+		if nil != err {
+			return
+		}
+
+		globals.CompressionLogicalBytes.Add(frameLength)
+
+		if uint64(len(compressedFrame)) < frameLength {
+			logSegmentNumber, logSegmentOffset, err = vS.doSendChunk(fileInode, compressedFrame, nil)
+			if nil != err {
+				return
+			}
+
+			err = recordExtent(fileInode, &fileExtentStruct{
+				FileOffset:       offset,
+				Length:           frameLength,
+				LogSegmentNumber: logSegmentNumber,
+				LogSegmentOffset: logSegmentOffset,
+				CompressedLength: uint64(len(compressedFrame)),
+				FrameOffset:      0,
+			})
+			if nil != err {
+				return
+			}
+
+			globals.CompressionPhysicalBytes.Add(uint64(len(compressedFrame)))
+		} else {
+			// Not worth compressing
+
+			logSegmentNumber, logSegmentOffset, err = vS.doSendChunk(fileInode, frame, nil)
+			if nil != err {
+				return
+			}
+
+			err = recordWrite(fileInode, offset, frameLength, logSegmentNumber, logSegmentOffset)
+			if nil != err {
+				return
+			}
+
+			globals.CompressionPhysicalBytes.Add(frameLength)
+		}
+
+		offset += frameLength
+		buf = buf[frameLength:]
+	}
+
+	err = nil
+	return
+}
+
+// decompressReadPlanSteps returns readPlan with each step referencing a compressed frame replaced
+// by a step supplying (as Data) the portion of the decompressed frame called for. If readPlan
+// contains no such steps, it is returned unmodified.
+func (vS *volumeStruct) decompressReadPlanSteps(fileInode *inMemoryInodeStruct, readPlan []ReadPlanStep) (newReadPlan []ReadPlanStep, err error) {
+	var (
+		compressedFrame []byte
+		frame           []byte
+		frameStep       ReadPlanStep
+		lastFrameStep   ReadPlanStep
+		readPlanCopied  bool
+		step            ReadPlanStep
+		stepIndex       int
+	)
+
+	newReadPlan = readPlan
+	readPlanCopied = false
+
+	for stepIndex, step = range readPlan {
+		if 0 == step.CompressedLength {
+			continue
+		}
+
+		if !readPlanCopied {
+			// First compressed step found... so we must now construct a distinct newReadPlan
+
+			newReadPlan = make([]ReadPlanStep, len(readPlan))
+			copy(newReadPlan, readPlan)
+
+			readPlanCopied = true
+		}
+
+		frameStep = ReadPlanStep{
+			LogSegmentNumber: step.LogSegmentNumber,
+			Offset:           step.Offset,
+			Length:           step.CompressedLength,
+			AccountName:      step.AccountName,
+			ContainerName:    step.ContainerName,
+			ObjectName:       step.ObjectName,
+			ObjectPath:       step.ObjectPath,
+		}
+
+		if (nil == frame) || (frameStep.LogSegmentNumber != lastFrameStep.LogSegmentNumber) || (frameStep.Offset != lastFrameStep.Offset) {
+			compressedFrame, err = vS.doReadPlan(fileInode, []ReadPlanStep{frameStep}, frameStep.Length)
+			if nil != err {
+				return
+			}
+
+			frame, err = decompressFrame(compressedFrame)
+			if nil != err {
+				logger.ErrorfWithError(err, "Decompressing frame of LogSegment 0x%016X at offset 0x%016X failed", step.LogSegmentNumber, step.Offset)
+				err = blunder.AddError(err, blunder.SegReadError)
+				return
+			}
+
+			lastFrameStep = frameStep
+		}
+
+		if (step.FrameOffset + step.Length) > uint64(len(frame)) {
+			err = fmt.Errorf("Invalid range for decompressed frame of LogSegment 0x%016X at offset 0x%016X", step.LogSegmentNumber, step.Offset)
+			logger.ErrorWithError(err)
+			err = blunder.AddError(err, blunder.SegReadError)
+			return
+		}
+
+		newReadPlan[stepIndex] = ReadPlanStep{
+			Length: step.Length,
+			Data:   frame[step.FrameOffset:(step.FrameOffset + step.Length)],
+		}
+	}
+
+	err = nil
+	return
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NVIDIA/sortedmap"
+)
+
+func testCompressedExtents(t *testing.T, fileInode *inMemoryInodeStruct) (compressedExtents int) {
+	extents := fileInode.payload.(sortedmap.BPlusTree)
+
+	extentsLen, err := extents.Len()
+	if nil != err {
+		t.Fatalf("extents.Len() failed: %v", err)
+	}
+
+	for extentIndex := 0; extentIndex < extentsLen; extentIndex++ {
+		_, extentValue, _, err := extents.GetByIndex(extentIndex)
+		if nil != err {
+			t.Fatalf("extents.GetByIndex() failed: %v", err)
+		}
+		if 0 != extentValue.(*fileExtentStruct).CompressedLength {
+			compressedExtents++
+		}
+	}
+
+	return
+}
+
+func TestCompressedExtentPackUnpack(t *testing.T) {
+	testSetup(t, false)
+
+	callbacks := &fileInodeCallbacks{}
+
+	for _, fileExtent := range []*fileExtentStruct{
+		{FileOffset: 1, Length: 2, LogSegmentNumber: 3, LogSegmentOffset: 4},
+		{FileOffset: 5, Length: 6, LogSegmentNumber: 7, LogSegmentOffset: 8, CompressedLength: 9, FrameOffset: 10},
+	} {
+		packedValue, err := callbacks.PackValue(fileExtent)
+		if nil != err {
+			t.Fatalf("PackValue() failed: %v", err)
+		}
+
+		value, bytesConsumed, err := callbacks.UnpackValue(append(packedValue, 0xFF))
+		if nil != err {
+			t.Fatalf("UnpackValue() failed: %v", err)
+		}
+		if uint64(len(packedValue)) != bytesConsumed {
+			t.Fatalf("UnpackValue() consumed %v bytes but PackValue() produced %v", bytesConsumed, len(packedValue))
+		}
+		if *fileExtent != *value.(*fileExtentStruct) {
+			t.Fatalf("UnpackValue() returned %#v but expected %#v", value, fileExtent)
+		}
+	}
+
+	testTeardown(t)
+}
+
+func TestCompressedWrite(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") should have worked - got error: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	volume.compressionEnabled = true
+
+	ourBytes := bytes.Repeat([]byte("The quick brown fox jumps over the lazy dog. "), 5000)
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 0, ourBytes, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	fileInode, err := volume.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	if 0 == testCompressedExtents(t, fileInode) {
+		t.Fatalf("Write() should have produced compressed extents")
+	}
+
+	// Overwrite a range straddling a frame boundary with compression disabled via the Stream
+
+	err = testVolumeHandle.PutStream(fileInodeNumber, CompressionStreamName, []byte(CompressionStreamValueOff))
+	if nil != err {
+		t.Fatalf("PutStream() failed: %v", err)
+	}
+
+	overwriteOffset := compressionFrameSize - 100
+	overwriteBytes := bytes.Repeat([]byte{0xA5}, 200)
+
+	err = testVolumeHandle.Write(fileInodeNumber, overwriteOffset, overwriteBytes, nil)
+	if nil != err {
+		t.Fatalf("Write() of overwriteBytes failed: %v", err)
+	}
+
+	copy(ourBytes[overwriteOffset:], overwriteBytes)
+
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	readBuf, err := testVolumeHandle.Read(fileInodeNumber, 0, uint64(len(ourBytes)), nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if 0 != bytes.Compare(ourBytes, readBuf) {
+		t.Fatalf("Read() of compressed data returned unexpected data")
+	}
+
+	readBuf, err = testVolumeHandle.Read(fileInodeNumber, overwriteOffset-1000, 2000, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if 0 != bytes.Compare(ourBytes[overwriteOffset-1000:overwriteOffset+1000], readBuf) {
+		t.Fatalf("Read() of partial compressed frames returned unexpected data")
+	}
+
+	err = testVolumeHandle.Validate(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Validate() failed: %v", err)
+	}
+
+	// GetReadPlan() callers are handed references to compressed frames to decompress themselves
+
+	offset := uint64(0)
+	length := uint64(len(ourBytes))
+	readPlan, err := testVolumeHandle.GetReadPlan(fileInodeNumber, &offset, &length)
+	if nil != err {
+		t.Fatalf("GetReadPlan() failed: %v", err)
+	}
+	fileInode, err = volume.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	readPlanBytes := uint64(0)
+	compressedSteps := 0
+	for _, step := range readPlan {
+		if 0 != step.CompressedLength {
+			if nil != step.Data {
+				t.Fatalf("GetReadPlan() returned a compressed step with Data: %#v", step)
+			}
+			compressedFrame, err := volume.doReadPlan(fileInode, []ReadPlanStep{{LogSegmentNumber: step.LogSegmentNumber, Offset: step.Offset, Length: step.CompressedLength, AccountName: step.AccountName, ContainerName: step.ContainerName, ObjectName: step.ObjectName, ObjectPath: step.ObjectPath}}, step.CompressedLength)
+			if nil != err {
+				t.Fatalf("doReadPlan() of compressed frame failed: %v", err)
+			}
+			frame, err := decompressFrame(compressedFrame)
+			if nil != err {
+				t.Fatalf("decompressFrame() failed: %v", err)
+			}
+			if 0 != bytes.Compare(ourBytes[readPlanBytes:readPlanBytes+step.Length], frame[step.FrameOffset:step.FrameOffset+step.Length]) {
+				t.Fatalf("GetReadPlan() returned a compressed step referencing unexpected data at file offset 0x%016X", readPlanBytes)
+			}
+			compressedSteps++
+		}
+		readPlanBytes += step.Length
+	}
+	if uint64(len(ourBytes)) != readPlanBytes {
+		t.Fatalf("GetReadPlan() returned %v bytes but expected %v", readPlanBytes, len(ourBytes))
+	}
+	if 0 == compressedSteps {
+		t.Fatalf("GetReadPlan() returned no compressed steps")
+	}
+
+	// FetchExtentMapChunk() callers must likewise be handed decompressed data in lieu of compressed frames
+
+	fileInode, err = volume.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	compressedExtents := testCompressedExtents(t, fileInode)
+	if 0 == compressedExtents {
+		t.Fatalf("Write() with compression enabled should have produced compressed extents")
+	}
+
+	extentMapChunk, err := testVolumeHandle.FetchExtentMapChunk(fileInodeNumber, 0, 1000, 0)
+	if nil != err {
+		t.Fatalf("FetchExtentMapChunk() failed: %v", err)
+	}
+	dataExtents := 0
+	for _, extentMapEntry := range extentMapChunk.ExtentMapEntry {
+		if nil == extentMapEntry.Data {
+			continue
+		}
+		if 0 != bytes.Compare(ourBytes[extentMapEntry.FileOffset:extentMapEntry.FileOffset+extentMapEntry.Length], extentMapEntry.Data[extentMapEntry.LogSegmentOffset:extentMapEntry.LogSegmentOffset+extentMapEntry.Length]) {
+			t.Fatalf("FetchExtentMapChunk() returned unexpected Data for FileOffset 0x%016X", extentMapEntry.FileOffset)
+		}
+		dataExtents++
+	}
+	if compressedExtents != dataExtents {
+		t.Fatalf("FetchExtentMapChunk() returned %v Data extents but expected %v", dataExtents, compressedExtents)
+	}
+
+	// FetchExtentMapChunk() must not have rewritten the file
+
+	fileInode, err = volume.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	if compressedExtents != testCompressedExtents(t, fileInode) {
+		t.Fatalf("FetchExtentMapChunk() should not have modified compressed extents")
+	}
+
+	readBuf, err = testVolumeHandle.Read(fileInodeNumber, 0, uint64(len(ourBytes)), nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if 0 != bytes.Compare(ourBytes, readBuf) {
+		t.Fatalf("Read() of compressed data returned unexpected data")
+	}
+
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	volume.compressionEnabled = false
+
+	testTeardown(t)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/NVIDIA/sortedmap"
 
 	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/bucketstats"
 	"github.com/NVIDIA/proxyfs/conf"
 	"github.com/NVIDIA/proxyfs/headhunter"
 	"github.com/NVIDIA/proxyfs/logger"
@@ -32,6 +33,7 @@ type readCacheElementStruct struct {
 	next         *readCacheElementStruct // nil if MRU element of volumeGroupStruct.readCache
 	prev         *readCacheElementStruct // nil if LRU element of volumeGroupStruct.readCache
 	cacheLine    []byte
+	readAhead    bool // true if fetched by readAhead() and not yet hit by doReadPlan()
 }
 
 type volumeGroupStruct struct {
@@ -47,6 +49,9 @@ type volumeGroupStruct struct {
 	readCache               map[readCacheKeyStruct]*readCacheElementStruct
 	readCacheMRU            *readCacheElementStruct
 	readCacheLRU            *readCacheElementStruct
+	readAheadMaxCacheLines  uint64                          // if == 0, read-ahead is disabled
+	readAheadInFlight       map[readCacheKeyStruct]struct{} // cache lines being fetched by readAheadCacheLine()
+	readAheadWG             sync.WaitGroup                  // tracks readAheadCacheLine() goroutines
 }
 
 type physicalContainerLayoutStruct struct {
@@ -72,9 +77,20 @@ type volumeStruct struct {
 	accountName                    string
 	maxEntriesPerDirNode           uint64
 	maxExtentsPerFileNode          uint64
+	maxInlineFileSize              uint64 //                      if != 0, FileInodes no larger than this inline their data
+	dedupEnabled                   bool   //                      if true, Write()'s to FileInodes are deduplicated
+	compressionEnabled             bool   //                      if true, Write()'s to FileInodes are compressed (unless overridden)
+	caseInsensitiveDirs            bool   //                      if true, DirInode lookups are case-insensitive (unless overridden)
 	defaultPhysicalContainerLayout *physicalContainerLayoutStruct
+	physicalContainerLayoutMap     map[string]*physicalContainerLayoutStruct // key == physicalContainerLayoutStruct.name
 	maxFlushSize                   uint64
 	headhunterVolumeHandle         headhunter.VolumeHandle
+	dirEntryCache                  headhunter.BPlusTreeCache
+	dirEntryCachePriorHits         uint64
+	dirEntryCachePriorMisses       uint64
+	fileExtentMapCache             headhunter.BPlusTreeCache
+	fileExtentMapCachePriorHits    uint64
+	fileExtentMapCachePriorMisses  uint64
 	inodeCache                     sortedmap.LLRBTree //          key == InodeNumber; value == *inMemoryInodeStruct
 	inodeCacheStopChan             chan struct{}
 	inodeCacheWG                   sync.WaitGroup
@@ -85,6 +101,8 @@ type volumeStruct struct {
 	inodeCacheLRUTicker            *time.Ticker
 	inodeCacheLRUTickerInterval    time.Duration
 	snapShotPolicy                 *snapShotPolicyStruct
+	logSegmentLengthCacheLock      trackedlock.Mutex
+	logSegmentLengthCache          map[uint64]uint64 //           key == LogSegmentNumber; value == length of the (closed) LogSegment
 }
 
 const (
@@ -96,32 +114,44 @@ const (
 
 type globalsStruct struct {
 	trackedlock.Mutex
-	whoAmI                             string
-	myPrivateIPAddr                    string
-	dirEntryCache                      sortedmap.BPlusTreeCache
-	dirEntryCachePriorCacheHits        uint64
-	dirEntryCachePriorCacheMisses      uint64
-	fileExtentMapCache                 sortedmap.BPlusTreeCache
-	fileExtentMapCachePriorCacheHits   uint64
-	fileExtentMapCachePriorCacheMisses uint64
-	volumeGroupMap                     map[string]*volumeGroupStruct // key == volumeGroupStruct.name
-	volumeMap                          map[string]*volumeStruct      // key == volumeStruct.volumeName
-	accountMap                         map[string]*volumeStruct      // key == volumeStruct.accountName
-	fileExtentStructSize               uint64                        // pre-calculated size of cstruct-packed fileExtentStruct
-	supportedOnDiskInodeVersions       map[Version]struct{}          // key == on disk inode version
-	corruptionDetectedTrueBuf          []byte                        // holds serialized CorruptionDetected == true
-	corruptionDetectedFalseBuf         []byte                        // holds serialized CorruptionDetected == false
-	versionV1Buf                       []byte                        // holds serialized Version            == V1
-	inodeRecDefaultPreambleBuf         []byte                        // holds concatenated corruptionDetectedFalseBuf & versionV1Buf
-	inodeSize                          uint64                        // size of in-memory inode struct
-	openLogSegmentLRUHead              *inFlightLogSegmentStruct
-	openLogSegmentLRUTail              *inFlightLogSegmentStruct
-	openLogSegmentLRUItems             uint64
-	noWriteThresholdErrno              blunder.FsError // either blunder.NotPermError or blunder.ReadOnlyError or blunder.NoSpaceError
-	noWriteThresholdErrnoString        string          // either "EPERM" or "EROFS" or "ENOSPC"
-	readOnlyThresholdErrno             blunder.FsError // either blunder.NotPermError or blunder.ReadOnlyError or blunder.NoSpaceError
-	readOnlyThresholdErrnoString       string          // either "EPERM" or "EROFS" or "ENOSPC"
-	rwMode                             RWModeType      // One of RWMode{Normal|NoWrite|ReadOnly}
+	whoAmI                          string
+	myPrivateIPAddr                 string
+	dirEntryCacheEvictLowLimit      uint64
+	dirEntryCacheEvictHighLimit     uint64
+	fileExtentMapEvictLowLimit      uint64
+	fileExtentMapEvictHighLimit     uint64
+	volumeGroupMap                  map[string]*volumeGroupStruct // key == volumeGroupStruct.name
+	volumeMap                       map[string]*volumeStruct      // key == volumeStruct.volumeName
+	accountMap                      map[string]*volumeStruct      // key == volumeStruct.accountName
+	fileExtentStructSize            uint64                        // pre-calculated size of cstruct-packed onDiskFileExtentStruct
+	fileExtentCompressionStructSize uint64                        // pre-calculated size of cstruct-packed onDiskFileExtentCompressionStruct
+	supportedOnDiskInodeVersions    map[Version]struct{}          // key == on disk inode version
+	corruptionDetectedTrueBuf       []byte                        // holds serialized CorruptionDetected == true
+	corruptionDetectedFalseBuf      []byte                        // holds serialized CorruptionDetected == false
+	versionV1Buf                    []byte                        // holds serialized Version            == V1
+	versionV2Buf                    []byte                        // holds serialized Version            == V2
+	inodeRecDefaultPreambleBuf      []byte                        // holds concatenated corruptionDetectedFalseBuf & versionV1Buf
+	inodeRecInlineDataPreambleBuf   []byte                        // holds concatenated corruptionDetectedFalseBuf & versionV2Buf
+	inodeSize                       uint64                        // size of in-memory inode struct
+	openLogSegmentLRUHead           *inFlightLogSegmentStruct
+	openLogSegmentLRUTail           *inFlightLogSegmentStruct
+	openLogSegmentLRUItems          uint64
+	noWriteThresholdErrno           blunder.FsError // either blunder.NotPermError or blunder.ReadOnlyError or blunder.NoSpaceError
+	noWriteThresholdErrnoString     string          // either "EPERM" or "EROFS" or "ENOSPC"
+	readOnlyThresholdErrno          blunder.FsError // either blunder.NotPermError or blunder.ReadOnlyError or blunder.NoSpaceError
+	readOnlyThresholdErrnoString    string          // either "EPERM" or "EROFS" or "ENOSPC"
+	rwMode                          RWModeType      // One of RWMode{Normal|NoWrite|ReadOnly}
+
+	ReadAheadCacheLinesIssued bucketstats.Total
+	ReadAheadCacheLinesHit    bucketstats.Total
+	ReadAheadBytes            bucketstats.BucketLog2Round
+	ReadAheadUsec             bucketstats.BucketLog2Round
+	ReadAheadErrors           bucketstats.Total
+
+	CompressionLogicalBytes  bucketstats.Total // bytes presented to compressedWrite()
+	CompressionPhysicalBytes bucketstats.Total // bytes actually appended to LogSegments by compressedWrite()
+	DecompressionUsec        bucketstats.BucketLog2Round
+	DecompressionErrors      bucketstats.Total
 }
 
 var globals globalsStruct
@@ -132,19 +162,16 @@ func init() {
 
 func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 	var (
-		corruptionDetectedFalse     = CorruptionDetected(false)
-		corruptionDetectedTrue      = CorruptionDetected(true)
-		dirEntryCacheEvictHighLimit uint64
-		dirEntryCacheEvictLowLimit  uint64
-		fileExtentMapEvictHighLimit uint64
-		fileExtentMapEvictLowLimit  uint64
-		ok                          bool
-		peerName                    string
-		peerNames                   []string
-		peerPrivateIPAddr           string
-		peerPrivateIPAddrMap        map[string]string
-		tempInode                   inMemoryInodeStruct
-		versionV1                   = Version(V1)
+		corruptionDetectedFalse = CorruptionDetected(false)
+		corruptionDetectedTrue  = CorruptionDetected(true)
+		ok                      bool
+		peerName                string
+		peerNames               []string
+		peerPrivateIPAddr       string
+		peerPrivateIPAddrMap    map[string]string
+		tempInode               inMemoryInodeStruct
+		versionV1               = Version(V1)
+		versionV2               = Version(V2)
 	)
 
 	peerPrivateIPAddrMap = make(map[string]string)
@@ -173,34 +200,16 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 		return
 	}
 
-	dirEntryCacheEvictLowLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "DirEntryCacheEvictLowLimit")
+	globals.dirEntryCacheEvictLowLimit, globals.dirEntryCacheEvictHighLimit, err = headhunter.FetchBPlusTreeCacheLimits(confMap, "DirEntryCache")
 	if nil != err {
 		return
 	}
-	dirEntryCacheEvictHighLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "DirEntryCacheEvictHighLimit")
-	if nil != err {
-		return
-	}
-
-	globals.dirEntryCache = sortedmap.NewBPlusTreeCache(dirEntryCacheEvictLowLimit, dirEntryCacheEvictHighLimit)
 
-	globals.dirEntryCachePriorCacheHits = 0
-	globals.dirEntryCachePriorCacheMisses = 0
-
-	fileExtentMapEvictLowLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "FileExtentMapEvictLowLimit")
-	if nil != err {
-		return
-	}
-	fileExtentMapEvictHighLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "FileExtentMapEvictHighLimit")
+	globals.fileExtentMapEvictLowLimit, globals.fileExtentMapEvictHighLimit, err = headhunter.FetchBPlusTreeCacheLimits(confMap, "FileExtentMap")
 	if nil != err {
 		return
 	}
 
-	globals.fileExtentMapCache = sortedmap.NewBPlusTreeCache(fileExtentMapEvictLowLimit, fileExtentMapEvictHighLimit)
-
-	globals.fileExtentMapCachePriorCacheHits = 0
-	globals.fileExtentMapCachePriorCacheMisses = 0
-
 	globals.volumeGroupMap = make(map[string]*volumeGroupStruct)
 	globals.volumeMap = make(map[string]*volumeStruct)
 	globals.accountMap = make(map[string]*volumeStruct)
@@ -211,7 +220,11 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 	globals.openLogSegmentLRUTail = nil
 	globals.openLogSegmentLRUItems = 0
 
-	globals.fileExtentStructSize, _, err = cstruct.Examine(fileExtentStruct{})
+	globals.fileExtentStructSize, _, err = cstruct.Examine(onDiskFileExtentStruct{})
+	if nil != err {
+		return
+	}
+	globals.fileExtentCompressionStructSize, _, err = cstruct.Examine(onDiskFileExtentCompressionStruct{})
 	if nil != err {
 		return
 	}
@@ -219,6 +232,7 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 	globals.supportedOnDiskInodeVersions = make(map[Version]struct{})
 
 	globals.supportedOnDiskInodeVersions[V1] = struct{}{}
+	globals.supportedOnDiskInodeVersions[V2] = struct{}{}
 
 	globals.corruptionDetectedTrueBuf, err = cstruct.Pack(corruptionDetectedTrue, cstruct.LittleEndian)
 	if nil != err {
@@ -232,15 +246,25 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 	if nil != err {
 		return
 	}
+	globals.versionV2Buf, err = cstruct.Pack(versionV2, cstruct.LittleEndian)
+	if nil != err {
+		return
+	}
 
 	globals.inodeRecDefaultPreambleBuf = make([]byte, 0, len(globals.corruptionDetectedFalseBuf)+len(globals.versionV1Buf))
 	globals.inodeRecDefaultPreambleBuf = append(globals.inodeRecDefaultPreambleBuf, globals.corruptionDetectedFalseBuf...)
 	globals.inodeRecDefaultPreambleBuf = append(globals.inodeRecDefaultPreambleBuf, globals.versionV1Buf...)
 
+	globals.inodeRecInlineDataPreambleBuf = make([]byte, 0, len(globals.corruptionDetectedFalseBuf)+len(globals.versionV2Buf))
+	globals.inodeRecInlineDataPreambleBuf = append(globals.inodeRecInlineDataPreambleBuf, globals.corruptionDetectedFalseBuf...)
+	globals.inodeRecInlineDataPreambleBuf = append(globals.inodeRecInlineDataPreambleBuf, globals.versionV2Buf...)
+
 	swiftclient.SetStarvationCallbackFunc(chunkedPutConnectionPoolStarvationCallback)
 
 	globals.rwMode = RWModeNormal
 
+	bucketstats.Register("proxyfs.inode", "", &globals)
+
 	err = nil
 	return
 }
@@ -260,6 +284,7 @@ func (dummy *globalsStruct) VolumeGroupCreated(confMap conf.ConfMap, volumeGroup
 		readCache:          make(map[readCacheKeyStruct]*readCacheElementStruct),
 		readCacheMRU:       nil,
 		readCacheLRU:       nil,
+		readAheadInFlight:  make(map[readCacheKeyStruct]struct{}),
 	}
 
 	volumeGroupSectionName = "VolumeGroup:" + volumeGroupName
@@ -302,6 +327,11 @@ func (dummy *globalsStruct) VolumeGroupCreated(confMap conf.ConfMap, volumeGroup
 		volumeGroup.readCacheWeight = 1
 	}
 
+	volumeGroup.readAheadMaxCacheLines, err = confMap.FetchOptionValueUint64(volumeGroupSectionName, "ReadAheadMaxCacheLines")
+	if nil != err {
+		volumeGroup.readAheadMaxCacheLines = 8 // Default to reading ahead up to 8 cache lines if not present
+	}
+
 	globals.Lock()
 
 	_, ok = globals.volumeGroupMap[volumeGroupName]
@@ -347,7 +377,7 @@ func (dummy *globalsStruct) VolumeGroupMoved(confMap conf.ConfMap, volumeGroupNa
 		}
 	}
 
-	// Note that VirtualIPAddr, ReadCacheLineSize, & ReadCacheWeight are not reloaded
+	// Note that VirtualIPAddr, ReadCacheLineSize, ReadCacheWeight, & ReadAheadMaxCacheLines are not reloaded
 
 	volumeGroup.Unlock()
 	globals.Unlock()
@@ -385,6 +415,10 @@ func (dummy *globalsStruct) VolumeGroupDestroyed(confMap conf.ConfMap, volumeGro
 	volumeGroup.Unlock()
 	globals.Unlock()
 
+	// Wait for any read-ahead to complete
+
+	volumeGroup.readAheadWG.Wait()
+
 	err = nil
 	return
 }
@@ -396,7 +430,7 @@ func (dummy *globalsStruct) VolumeCreated(confMap conf.ConfMap, volumeName strin
 		volumeSectionName string
 	)
 
-	volume := &volumeStruct{volumeName: volumeName, served: false}
+	volume := &volumeStruct{volumeName: volumeName, served: false, logSegmentLengthCache: make(map[uint64]uint64)}
 
 	volumeSectionName = "Volume:" + volumeName
 
@@ -549,12 +583,14 @@ func (dummy *globalsStruct) VolumeDestroyed(confMap conf.ConfMap, volumeName str
 
 func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string) (err error) {
 	var (
-		defaultPhysicalContainerLayout            *physicalContainerLayoutStruct
-		defaultPhysicalContainerLayoutName        string
-		defaultPhysicalContainerLayoutSectionName string
-		ok                                        bool
-		volume                                    *volumeStruct
-		volumeSectionName                         string
+		defaultPhysicalContainerLayout     *physicalContainerLayoutStruct
+		defaultPhysicalContainerLayoutName string
+		ok                                 bool
+		physicalContainerLayout            *physicalContainerLayoutStruct
+		physicalContainerLayoutName        string
+		physicalContainerLayoutNameList    []string
+		volume                             *volumeStruct
+		volumeSectionName                  string
 	)
 
 	volumeSectionName = "Volume:" + volumeName
@@ -583,45 +619,59 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 		globals.Unlock()
 		return
 	}
-	defaultPhysicalContainerLayoutName, err = confMap.FetchOptionValueString(volumeSectionName, "DefaultPhysicalContainerLayout")
+	volume.maxInlineFileSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "MaxInlineFileSize")
 	if nil != err {
-		globals.Unlock()
-		return
+		volume.maxInlineFileSize = 0 // Default to never inlining FileInode data if not present
 	}
-
-	defaultPhysicalContainerLayout = &physicalContainerLayoutStruct{
-		name:                        defaultPhysicalContainerLayoutName,
-		containerNameSliceNextIndex: 0,
-		containerNameSliceLoopCount: 0,
+	volume.dedupEnabled, err = confMap.FetchOptionValueBool(volumeSectionName, "DedupEnabled")
+	if nil != err {
+		volume.dedupEnabled = false // Default to not deduplicating FileInode data if not present
 	}
-
-	defaultPhysicalContainerLayoutSectionName = "PhysicalContainerLayout:" + defaultPhysicalContainerLayoutName
-
-	defaultPhysicalContainerLayout.containerStoragePolicy, err = confMap.FetchOptionValueString(defaultPhysicalContainerLayoutSectionName, "ContainerStoragePolicy")
+	volume.compressionEnabled, err = confMap.FetchOptionValueBool(volumeSectionName, "CompressionEnabled")
 	if nil != err {
-		globals.Unlock()
-		return
+		volume.compressionEnabled = false // Default to not compressing FileInode data if not present
 	}
-	defaultPhysicalContainerLayout.containerNamePrefix, err = confMap.FetchOptionValueString(defaultPhysicalContainerLayoutSectionName, "ContainerNamePrefix")
+	volume.caseInsensitiveDirs, err = confMap.FetchOptionValueBool(volumeSectionName, "CaseInsensitiveDirs")
 	if nil != err {
-		globals.Unlock()
-		return
+		volume.caseInsensitiveDirs = false // Default to case-sensitive DirInodes if not present
 	}
-	defaultPhysicalContainerLayout.containersPerPeer, err = confMap.FetchOptionValueUint64(defaultPhysicalContainerLayoutSectionName, "ContainersPerPeer")
+	defaultPhysicalContainerLayoutName, err = confMap.FetchOptionValueString(volumeSectionName, "DefaultPhysicalContainerLayout")
 	if nil != err {
 		globals.Unlock()
 		return
 	}
-	defaultPhysicalContainerLayout.maxObjectsPerContainer, err = confMap.FetchOptionValueUint64(defaultPhysicalContainerLayoutSectionName, "MaxObjectsPerContainer")
+
+	defaultPhysicalContainerLayout, err = fetchPhysicalContainerLayout(confMap, defaultPhysicalContainerLayoutName)
 	if nil != err {
 		globals.Unlock()
 		return
 	}
 
-	defaultPhysicalContainerLayout.containerNameSlice = make([]string, defaultPhysicalContainerLayout.containersPerPeer)
-
 	volume.defaultPhysicalContainerLayout = defaultPhysicalContainerLayout
 
+	volume.physicalContainerLayoutMap = make(map[string]*physicalContainerLayoutStruct)
+	volume.physicalContainerLayoutMap[defaultPhysicalContainerLayoutName] = defaultPhysicalContainerLayout
+
+	physicalContainerLayoutNameList, err = confMap.FetchOptionValueStringSlice(volumeSectionName, "PhysicalContainerLayoutList")
+	if nil != err {
+		physicalContainerLayoutNameList = []string{} // Default to only DefaultPhysicalContainerLayout if not present
+	}
+
+	for _, physicalContainerLayoutName = range physicalContainerLayoutNameList {
+		_, ok = volume.physicalContainerLayoutMap[physicalContainerLayoutName]
+		if ok {
+			continue
+		}
+
+		physicalContainerLayout, err = fetchPhysicalContainerLayout(confMap, physicalContainerLayoutName)
+		if nil != err {
+			globals.Unlock()
+			return
+		}
+
+		volume.physicalContainerLayoutMap[physicalContainerLayoutName] = physicalContainerLayout
+	}
+
 	volume.maxFlushSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "MaxFlushSize")
 	if nil != err {
 		globals.Unlock()
@@ -634,6 +684,14 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 		return
 	}
 
+	volume.dirEntryCache = volume.headhunterVolumeHandle.RegisterBPlusTreeCache("DirEntry", globals.dirEntryCacheEvictLowLimit, globals.dirEntryCacheEvictHighLimit)
+	volume.fileExtentMapCache = volume.headhunterVolumeHandle.RegisterBPlusTreeCache("FileExtentMap", globals.fileExtentMapEvictLowLimit, globals.fileExtentMapEvictHighLimit)
+
+	volume.dirEntryCachePriorHits = 0
+	volume.dirEntryCachePriorMisses = 0
+	volume.fileExtentMapCachePriorHits = 0
+	volume.fileExtentMapCachePriorMisses = 0
+
 	volume.headhunterVolumeHandle.RegisterForEvents(volume)
 
 	volume.inodeCache = sortedmap.NewLLRBTree(compareInodeNumber, volume)
@@ -660,6 +718,7 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 
 	globals.Unlock()
 
+	err = nil
 	return
 }
 
@@ -810,6 +869,8 @@ func (dummy *globalsStruct) Down(confMap conf.ConfMap) (err error) {
 		return
 	}
 
+	bucketstats.UnRegister("proxyfs.inode", "")
+
 	err = nil
 	return
 }
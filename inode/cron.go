@@ -268,6 +268,7 @@ func (snapShotPolicy *snapShotPolicyStruct) daemon() {
 				logger.WarnWithError(err)
 			}
 			snapShotPolicy.prune()
+			snapShotPolicy.volume.headhunterVolumeHandle.RequestReplication()
 		}
 		nextTimePreviously = nextTime
 	}
@@ -0,0 +1,251 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"crypto/sha256"
+
+	"github.com/NVIDIA/proxyfs/headhunter"
+	"github.com/NVIDIA/proxyfs/logger"
+)
+
+// Write()'s of a FileInode in a volume with DedupEnabled are split into content-defined chunks
+// using a "gear" rolling hash. Chunk boundaries are declared where the low-order bits of the hash
+// are all zero such that an insertion or deletion only perturbs the chunks around it. Each chunk's
+// sha256 is looked up in the dedup B+Tree maintained by package headhunter. If found, the chunk is
+// recorded as an extent referencing the already stored copy. Otherwise, it is appended to the open
+// LogSegment as usual and, once that LogSegment has been successfully closed, its fingerprint is
+// made available to subsequent Write()'s.
+//
+// Note that data supplied via Wrote() (i.e. by the middleware) is never deduplicated.
+
+const (
+	dedupMinChunkSize = uint64(4 * 1024)
+	dedupMaxChunkSize = uint64(64 * 1024)
+	dedupBoundaryMask = uint64(16*1024 - 1) // Yields an average chunk size (beyond dedupMinChunkSize) of ~16KiB
+)
+
+var dedupGearTable [256]uint64
+
+func init() {
+	var (
+		gearIndex int
+		seed      uint64
+		z         uint64
+	)
+
+	// Populate dedupGearTable deterministically (via splitmix64) as chunk boundaries must never change
+
+	seed = 0x5DEECE66D
+
+	for gearIndex = range dedupGearTable {
+		seed += 0x9E3779B97F4A7C15
+		z = seed
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		dedupGearTable[gearIndex] = z ^ (z >> 31)
+	}
+}
+
+// dedupChunkLength returns the length of the content-defined chunk at the start of buf.
+func dedupChunkLength(buf []byte) (chunkLength uint64) {
+	var (
+		bufLen uint64
+		hash   uint64
+	)
+
+	bufLen = uint64(len(buf))
+
+	if bufLen <= dedupMinChunkSize {
+		chunkLength = bufLen
+		return
+	}
+
+	hash = 0
+
+	for chunkLength = dedupMinChunkSize; chunkLength < bufLen; chunkLength++ {
+		if dedupMaxChunkSize == chunkLength {
+			return
+		}
+
+		hash = (hash << 1) + dedupGearTable[buf[chunkLength]]
+
+		if 0 == (hash & dedupBoundaryMask) {
+			chunkLength++
+			return
+		}
+	}
+
+	return
+}
+
+// dedupWrite records buf at offset in fileInode one content-defined chunk at a time, referencing
+// already stored copies of chunks where possible.
+func (vS *volumeStruct) dedupWrite(fileInode *inMemoryInodeStruct, offset uint64, buf []byte) (err error) {
+	var (
+		alreadyReferenced bool
+		chunk             []byte
+		chunkLength       uint64
+		chunkedBytes      uint64
+		dedupedBytes      uint64
+		fingerprint       []byte
+		fingerprintArray  [sha256.Size]byte
+		length            uint64
+		logSegmentNumber  uint64
+		logSegmentOffset  uint64
+		ok                bool
+	)
+
+	chunkedBytes = uint64(len(buf))
+	dedupedBytes = 0
+
+	defer func() {
+		vS.headhunterVolumeHandle.RecordDedupBytes(chunkedBytes, dedupedBytes)
+	}()
+
+	for 0 < len(buf) {
+		chunkLength = dedupChunkLength(buf)
+		chunk = buf[:chunkLength]
+
+		if dedupMinChunkSize > chunkLength {
+			// Too small to be worth tracking
+
+			fingerprint = nil
+		} else {
+			fingerprintArray = sha256.Sum256(chunk)
+			fingerprint = make([]byte, sha256.Size) // Retained by doSendChunk() so must not share fingerprintArray
+			copy(fingerprint, fingerprintArray[:])
+
+			logSegmentNumber, logSegmentOffset, length, ok, err = vS.headhunterVolumeHandle.ReferenceDedupChunk(fingerprint)
+			if nil != err {
+				return
+			}
+
+			if ok && (length != chunkLength) {
+				// Not actually the same content... so simply drop the reference just obtained
+
+				err = vS.headhunterVolumeHandle.UnreferenceLogSegmentRec(logSegmentNumber)
+				if nil != err {
+					return
+				}
+
+				ok = false
+			}
+
+			if ok {
+				// fileInode need only hold a single reference to any LogSegment
+
+				_, alreadyReferenced = fileInode.LogSegmentMap[logSegmentNumber]
+				if alreadyReferenced {
+					err = vS.headhunterVolumeHandle.UnreferenceLogSegmentRec(logSegmentNumber)
+					if nil != err {
+						return
+					}
+				}
+
+				err = recordWrite(fileInode, offset, chunkLength, logSegmentNumber, logSegmentOffset)
+				if nil != err {
+					return
+				}
+
+				dedupedBytes += chunkLength
+
+				offset += chunkLength
+				buf = buf[chunkLength:]
+
+				continue
+			}
+		}
+
+		logSegmentNumber, logSegmentOffset, err = vS.doSendChunk(fileInode, chunk, fingerprint)
+		if nil != err {
+			return
+		}
+
+		err = recordWrite(fileInode, offset, chunkLength, logSegmentNumber, logSegmentOffset)
+		if nil != err {
+			return
+		}
+
+		offset += chunkLength
+		buf = buf[chunkLength:]
+	}
+
+	err = nil
+	return
+}
+
+// FetchDedupStats returns the deduplication statistics of the volume.
+func (vS *volumeStruct) FetchDedupStats() (dedupStats headhunter.DedupStatsStruct) {
+	dedupStats = vS.headhunterVolumeHandle.FetchDedupStats()
+	return
+}
+
+// rebuildDedupRefCounts recounts the number of live FileInodes referencing each LogSegment. This is
+// necessary whenever the dedup B+Tree may no longer reflect the live view (e.g. following a revert
+// or the replay of a Replay Log). Until completed, package headhunter will not delete LogSegments.
+func (vS *volumeStruct) rebuildDedupRefCounts() (err error) {
+	var (
+		inode            *inMemoryInodeStruct
+		inodeNumber      uint64
+		logSegmentNumber uint64
+		ok               bool
+		refCounts        map[uint64]uint64
+	)
+
+	refCounts = make(map[uint64]uint64)
+
+	inodeNumber = 0
+
+	for {
+		inodeNumber, ok, err = vS.headhunterVolumeHandle.NextInodeNumber(inodeNumber)
+		if nil != err {
+			return
+		}
+		if !ok {
+			break
+		}
+
+		inode, ok, err = vS.inodeCacheFetch(InodeNumber(inodeNumber))
+		if nil != err {
+			return
+		}
+		if !ok {
+			inode, ok, err = vS.fetchOnDiskInode(InodeNumber(inodeNumber))
+			if nil != err {
+				return
+			}
+		}
+		if !ok || (FileType != inode.InodeType) {
+			continue
+		}
+
+		for logSegmentNumber = range inode.LogSegmentMap {
+			refCounts[logSegmentNumber]++
+		}
+	}
+
+	err = vS.headhunterVolumeHandle.RebuildDedupRefCounts(refCounts)
+
+	return // err set as appropriate
+}
+
+// RebuildDedupRefCountsIfNecessary invokes rebuildDedupRefCounts() if package headhunter reports
+// that the volume's LogSegment reference counts may be stale. Replicas are read-only so are skipped.
+// As every FileInode is visited, callers should ensure that no other operations are underway on the
+// volume and, given the time this may take, avoid doing so synchronously when serving the volume.
+func (vS *volumeStruct) RebuildDedupRefCountsIfNecessary() {
+	var (
+		err error
+	)
+
+	if !vS.headhunterVolumeHandle.FetchDedupStats().RebuildRequired || vS.headhunterVolumeHandle.IsReplica() {
+		return
+	}
+
+	err = vS.rebuildDedupRefCounts()
+	if nil != err {
+		logger.WarnfWithError(err, "Volume %s unable to rebuild dedup reference counts... LogSegments will not be deleted", vS.volumeName)
+	}
+}
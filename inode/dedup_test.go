@@ -0,0 +1,324 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func testDedupChunkBoundaries(buf []byte) (boundaries map[string]struct{}) {
+	boundaries = make(map[string]struct{})
+
+	for 0 < len(buf) {
+		chunkLength := dedupChunkLength(buf)
+		boundaries[string(buf[:chunkLength])] = struct{}{}
+		buf = buf[chunkLength:]
+	}
+
+	return
+}
+
+func TestDedupChunkLength(t *testing.T) {
+	ourBytes := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(ourBytes)
+
+	buf := ourBytes
+	for 0 < len(buf) {
+		chunkLength := dedupChunkLength(buf)
+		if chunkLength > dedupMaxChunkSize {
+			t.Fatalf("dedupChunkLength() returned %v (exceeding dedupMaxChunkSize)", chunkLength)
+		}
+		if (chunkLength < dedupMinChunkSize) && (chunkLength != uint64(len(buf))) {
+			t.Fatalf("dedupChunkLength() returned %v (below dedupMinChunkSize) before end of buf", chunkLength)
+		}
+		buf = buf[chunkLength:]
+	}
+
+	if 0 != dedupChunkLength(ourBytes[:0]) {
+		t.Fatalf("dedupChunkLength() of empty buf should have returned 0")
+	}
+
+	zeroes := make([]byte, 4*dedupMaxChunkSize)
+	if dedupMaxChunkSize != dedupChunkLength(zeroes) {
+		t.Fatalf("dedupChunkLength() of zeroes should have returned dedupMaxChunkSize")
+	}
+
+	// Inserting a few bytes at the front should leave nearly all subsequent chunks unchanged
+
+	originalChunks := testDedupChunkBoundaries(ourBytes)
+	shiftedChunks := testDedupChunkBoundaries(append([]byte{1, 2, 3}, ourBytes...))
+
+	chunksInCommon := 0
+	for chunk := range shiftedChunks {
+		_, ok := originalChunks[chunk]
+		if ok {
+			chunksInCommon++
+		}
+	}
+
+	if chunksInCommon < (len(originalChunks) - 2) {
+		t.Fatalf("Inserting bytes should only have perturbed the first chunk (only %v of %v chunks in common)", chunksInCommon, len(originalChunks))
+	}
+}
+
+func TestDedupWrite(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") should have worked - got error: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	volume.dedupEnabled = true
+
+	ourBytes := make([]byte, 256*1024)
+	rand.New(rand.NewSource(2)).Read(ourBytes)
+
+	fileInodeNumberA, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumberA, 0, ourBytes, nil)
+	if nil != err {
+		t.Fatalf("Write() to fileInodeNumberA failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumberA, false)
+	if nil != err {
+		t.Fatalf("Flush() of fileInodeNumberA failed: %v", err)
+	}
+
+	dedupStatsBefore := testVolumeHandle.FetchDedupStats()
+
+	fileInodeNumberB, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumberB, 0, ourBytes, nil)
+	if nil != err {
+		t.Fatalf("Write() to fileInodeNumberB failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumberB, false)
+	if nil != err {
+		t.Fatalf("Flush() of fileInodeNumberB failed: %v", err)
+	}
+
+	dedupStatsAfter := testVolumeHandle.FetchDedupStats()
+
+	if uint64(len(ourBytes)) != (dedupStatsAfter.ChunkedBytes - dedupStatsBefore.ChunkedBytes) {
+		t.Fatalf("FetchDedupStats() reported unexpected ChunkedBytes")
+	}
+	if (dedupStatsAfter.DedupedBytes - dedupStatsBefore.DedupedBytes) < (uint64(len(ourBytes)) - dedupMinChunkSize) {
+		t.Fatalf("Write() of identical data should have been (nearly) entirely deduplicated")
+	}
+
+	fileInodeA, err := volume.fetchInodeType(fileInodeNumberA, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType(fileInodeNumberA) failed: %v", err)
+	}
+	fileInodeB, err := volume.fetchInodeType(fileInodeNumberB, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType(fileInodeNumberB) failed: %v", err)
+	}
+
+	sharedLogSegmentNumbers := make([]uint64, 0)
+	for logSegmentNumber := range fileInodeB.LogSegmentMap {
+		_, ok := fileInodeA.LogSegmentMap[logSegmentNumber]
+		if ok {
+			sharedLogSegmentNumbers = append(sharedLogSegmentNumbers, logSegmentNumber)
+		}
+	}
+	if 0 == len(sharedLogSegmentNumbers) {
+		t.Fatalf("fileInodeNumberB should have referenced LogSegments of fileInodeNumberA")
+	}
+
+	// DefragmentFile() must neither rewrite nor report extents in LogSegments shared with fileInodeNumberA
+
+	fragmentationReport, err := testVolumeHandle.FetchFragmentationReport(fileInodeNumberB)
+	if nil != err {
+		t.Fatalf("FetchFragmentationReport(fileInodeNumberB) failed: %v", err)
+	}
+	if fragmentationReport.BytesInFragments > dedupMaxChunkSize {
+		t.Fatalf("FetchFragmentationReport(fileInodeNumberB) should not have reported shared extents - got %#v", fragmentationReport)
+	}
+
+	_, eofReached, err := testVolumeHandle.DefragmentFile(fileInodeNumberB, 0, uint64(len(ourBytes)))
+	if (nil != err) || !eofReached {
+		t.Fatalf("DefragmentFile(fileInodeNumberB) returned unexpected eofReached (%v) or err (%v)", eofReached, err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumberB, false)
+	if nil != err {
+		t.Fatalf("Flush() of fileInodeNumberB failed: %v", err)
+	}
+
+	fileInodeB, err = volume.fetchInodeType(fileInodeNumberB, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType(fileInodeNumberB) failed: %v", err)
+	}
+	for _, logSegmentNumber := range sharedLogSegmentNumbers {
+		_, ok := fileInodeB.LogSegmentMap[logSegmentNumber]
+		if !ok {
+			t.Fatalf("DefragmentFile(fileInodeNumberB) should not have rewritten shared LogSegment 0x%016X", logSegmentNumber)
+		}
+	}
+
+	// Destroying fileInodeNumberA must leave its LogSegments intact for fileInodeNumberB
+
+	err = testVolumeHandle.Destroy(fileInodeNumberA)
+	if nil != err {
+		t.Fatalf("Destroy(fileInodeNumberA) failed: %v", err)
+	}
+
+	for _, logSegmentNumber := range sharedLogSegmentNumbers {
+		_, err = volume.headhunterVolumeHandle.GetLogSegmentRec(logSegmentNumber)
+		if nil != err {
+			t.Fatalf("LogSegment 0x%016X shared by fileInodeNumberB should not have been deleted", logSegmentNumber)
+		}
+	}
+
+	readBuf, err := testVolumeHandle.Read(fileInodeNumberB, 0, uint64(len(ourBytes)), nil)
+	if nil != err {
+		t.Fatalf("Read() of fileInodeNumberB failed: %v", err)
+	}
+	if 0 != bytes.Compare(ourBytes, readBuf) {
+		t.Fatalf("Read() of fileInodeNumberB returned unexpected data")
+	}
+
+	// Destroying fileInodeNumberB should finally delete them
+
+	err = testVolumeHandle.Destroy(fileInodeNumberB)
+	if nil != err {
+		t.Fatalf("Destroy(fileInodeNumberB) failed: %v", err)
+	}
+
+	for _, logSegmentNumber := range sharedLogSegmentNumbers {
+		_, err = volume.headhunterVolumeHandle.GetLogSegmentRec(logSegmentNumber)
+		if nil == err {
+			t.Fatalf("LogSegment 0x%016X should have been deleted", logSegmentNumber)
+		}
+	}
+
+	dedupStatsAfter = testVolumeHandle.FetchDedupStats()
+
+	if (0 != dedupStatsAfter.Fingerprints) || (0 != dedupStatsAfter.LogSegments) {
+		t.Fatalf("FetchDedupStats() should have reported no remaining Fingerprints nor LogSegments - got %#v", dedupStatsAfter)
+	}
+
+	volume.dedupEnabled = false
+
+	testTeardown(t)
+}
+
+func TestDedupSnapShotRestore(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") should have worked - got error: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	volume.dedupEnabled = true
+
+	ourBytes := make([]byte, 256*1024)
+	rand.New(rand.NewSource(3)).Read(ourBytes)
+	otherBytes := make([]byte, 256*1024)
+	rand.New(rand.NewSource(4)).Read(otherBytes)
+
+	fileInodeNumberA, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumberA, 0, ourBytes, nil)
+	if nil != err {
+		t.Fatalf("Write() to fileInodeNumberA failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumberA, false)
+	if nil != err {
+		t.Fatalf("Flush() of fileInodeNumberA failed: %v", err)
+	}
+
+	fileInodeA, err := volume.fetchInodeType(fileInodeNumberA, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType(fileInodeNumberA) failed: %v", err)
+	}
+	originalLogSegmentNumbers := make([]uint64, 0, len(fileInodeA.LogSegmentMap))
+	for logSegmentNumber := range fileInodeA.LogSegmentMap {
+		originalLogSegmentNumbers = append(originalLogSegmentNumbers, logSegmentNumber)
+	}
+
+	snapShotID, err := testVolumeHandle.SnapShotCreate("DedupSnapShotRestore")
+	if nil != err {
+		t.Fatalf("SnapShotCreate() failed: %v", err)
+	}
+
+	// fileInodeNumberB comes to share the LogSegments of fileInodeNumberA just before they are overwritten
+
+	fileInodeNumberB, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumberB, 0, ourBytes, nil)
+	if nil != err {
+		t.Fatalf("Write() to fileInodeNumberB failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumberB, false)
+	if nil != err {
+		t.Fatalf("Flush() of fileInodeNumberB failed: %v", err)
+	}
+
+	err = testVolumeHandle.Write(fileInodeNumberA, 0, otherBytes, nil)
+	if nil != err {
+		t.Fatalf("Write() to fileInodeNumberA failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumberA, false)
+	if nil != err {
+		t.Fatalf("Flush() of fileInodeNumberA failed: %v", err)
+	}
+
+	// Restoring fileInodeNumberA must share (rather than refuse to reference) LogSegments in use by fileInodeNumberB
+
+	err = testVolumeHandle.SnapShotRestore(snapShotID, fileInodeNumberA)
+	if nil != err {
+		t.Fatalf("SnapShotRestore() of fileInodeNumberA failed: %v", err)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumberB)
+	if nil != err {
+		t.Fatalf("Destroy(fileInodeNumberB) failed: %v", err)
+	}
+
+	for _, logSegmentNumber := range originalLogSegmentNumbers {
+		_, err = volume.headhunterVolumeHandle.GetLogSegmentRec(logSegmentNumber)
+		if nil != err {
+			t.Fatalf("LogSegment 0x%016X referenced by restored fileInodeNumberA should not have been deleted", logSegmentNumber)
+		}
+	}
+
+	readBuf, err := testVolumeHandle.Read(fileInodeNumberA, 0, uint64(len(ourBytes)), nil)
+	if nil != err {
+		t.Fatalf("Read() of fileInodeNumberA failed: %v", err)
+	}
+	if 0 != bytes.Compare(ourBytes, readBuf) {
+		t.Fatalf("Read() of fileInodeNumberA returned unexpected data")
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumberA)
+	if nil != err {
+		t.Fatalf("Destroy(fileInodeNumberA) failed: %v", err)
+	}
+
+	err = testVolumeHandle.SnapShotDelete(snapShotID)
+	if nil != err {
+		t.Fatalf("SnapShotDelete() failed: %v", err)
+	}
+
+	volume.dedupEnabled = false
+
+	testTeardown(t)
+}
@@ -45,7 +45,7 @@ func (vS *volumeStruct) createRootOrSubDir(filePerm InodeMode, userID InodeUserI
 			vS.maxEntriesPerDirNode,
 			sortedmap.CompareString,
 			&dirInodeCallbacks{treeNodeLoadable{inode: dirInode}},
-			globals.dirEntryCache)
+			vS.dirEntryCache.Cache())
 
 	ok, err := dirMapping.Put(".", dirInode.InodeNumber)
 	if (nil != err) || (!ok) {
@@ -93,7 +93,7 @@ func (vS *volumeStruct) createRootOrSubDir(filePerm InodeMode, userID InodeUserI
 }
 
 func (vS *volumeStruct) CreateDir(filePerm InodeMode, userID InodeUserID, groupID InodeGroupID) (dirInodeNumber InodeNumber, err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -125,6 +125,8 @@ func linkInMemory(dirInode *inMemoryInodeStruct, targetInode *inMemoryInodeStruc
 		return blunder.AddError(err, blunder.FileExistsError)
 	}
 
+	caseFoldIndexAdded(dirInode, basename)
+
 	updateTime := time.Now()
 
 	targetInode.LinkCount++
@@ -158,6 +160,8 @@ func linkInMemoryInsertOnly(dirInode *inMemoryInodeStruct, basename string, targ
 		return blunder.AddError(err, blunder.FileExistsError)
 	}
 
+	caseFoldIndexAdded(dirInode, basename)
+
 	updateTime := time.Now()
 
 	dirInode.AttrChangeTime = updateTime
@@ -177,7 +181,7 @@ func (vS *volumeStruct) Link(dirInodeNumber InodeNumber, basename string, target
 		targetInode    *inMemoryInodeStruct
 	)
 
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -205,6 +209,13 @@ func (vS *volumeStruct) Link(dirInodeNumber InodeNumber, basename string, target
 		return err
 	}
 
+	collidingBasename, collides := vS.caseCollision(dirInode, basename)
+	if collides {
+		err = fmt.Errorf("%s: failed to create link '%v' to inode %v in directory inode %v: entry '%v' differs only by case",
+			utils.GetFnName(), basename, targetInodeNumber, dirInodeNumber, collidingBasename)
+		return blunder.AddError(err, blunder.FileExistsError)
+	}
+
 	if insertOnly {
 		err = linkInMemoryInsertOnly(dirInode, basename, targetInodeNumber)
 		if err != nil {
@@ -273,6 +284,8 @@ func unlinkInMemory(dirInode *inMemoryInodeStruct, untargetInode *inMemoryInodeS
 		panic(err)
 	}
 
+	caseFoldIndexRemoved(dirInode, basename)
+
 	untargetInode.LinkCount--
 
 	if DirType == untargetInode.InodeType {
@@ -334,6 +347,8 @@ func unlinkInMemoryRemoveOnly(dirInode *inMemoryInodeStruct, basename string) {
 		panic(err)
 	}
 
+	caseFoldIndexRemoved(dirInode, basename)
+
 	updateTime = time.Now()
 
 	dirInode.AttrChangeTime = updateTime
@@ -350,7 +365,7 @@ func (vS *volumeStruct) Unlink(dirInodeNumber InodeNumber, basename string, remo
 		untargetInodeNumber InodeNumber
 	)
 
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -372,6 +387,8 @@ func (vS *volumeStruct) Unlink(dirInodeNumber InodeNumber, basename string, remo
 		return
 	}
 
+	basename = vS.resolveBasename(dirInode, basename)
+
 	untargetInodeNumber, err = vS.lookupByDirInodeNumber(dirInodeNumber, basename)
 	if nil != err {
 		err = blunder.AddError(err, blunder.NotFoundError)
@@ -424,12 +441,21 @@ func (vS *volumeStruct) Unlink(dirInodeNumber InodeNumber, basename string, remo
 	return
 }
 
-func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string, dstDirInodeNumber InodeNumber, dstBasename string) (toDestroyInodeNumber InodeNumber, err error) {
-	err = enforceRWMode(false)
+func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string, dstDirInodeNumber InodeNumber, dstBasename string, flags MoveFlags) (toDestroyInodeNumber InodeNumber, err error) {
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
 
+	if 0 != (flags & ^(MoveFlagNoReplace | MoveFlagExchange)) {
+		err = blunder.NewError(blunder.InvalidArgError, "Move() flags 0x%08X not supported", uint32(flags))
+		return
+	}
+	if (MoveFlagNoReplace | MoveFlagExchange) == flags {
+		err = blunder.NewError(blunder.InvalidArgError, "Move() flags MoveFlagNoReplace & MoveFlagExchange are mutually exclusive")
+		return
+	}
+
 	if (RootDirInodeNumber == srcDirInodeNumber) && (SnapShotDirName == srcBasename) {
 		err = blunder.NewError(blunder.InvalidArgError, "Move() from /%v not allowed", SnapShotDirName)
 		return
@@ -458,6 +484,8 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 	}
 	srcDirMapping := srcDirInode.payload.(sortedmap.BPlusTree)
 
+	srcBasename = vS.resolveBasename(srcDirInode, srcBasename)
+
 	var dstDirInode *inMemoryInodeStruct
 	var dstDirMapping sortedmap.BPlusTree
 	if srcDirInodeNumber == dstDirInodeNumber {
@@ -478,6 +506,18 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 		dstDirMapping = dstDirInode.payload.(sortedmap.BPlusTree)
 	}
 
+	if MoveFlagExchange == flags {
+		dstBasename = vS.resolveBasename(dstDirInode, dstBasename)
+	} else {
+		collidingBasename, collides := vS.caseCollision(dstDirInode, dstBasename)
+		if collides && ((srcDirInodeNumber != dstDirInodeNumber) || (collidingBasename != srcBasename)) {
+			// Only a change of case of srcBasename itself is allowed to collide
+			err = fmt.Errorf("%v: Target of Move() differs only by case from existing %v/%v", utils.GetFnName(), dstDirInodeNumber, collidingBasename)
+			err = blunder.AddError(err, blunder.FileExistsError)
+			return
+		}
+	}
+
 	srcInodeNumberAsValue, ok, err := srcDirMapping.GetByKey(srcBasename)
 	if nil != err {
 		panic(err)
@@ -539,6 +579,27 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 		dstInode = nil
 	}
 
+	if (MoveFlagNoReplace == flags) && (nil != dstInode) {
+		err = fmt.Errorf("%v: Target of Move() exists and MoveFlagNoReplace specified: %v/%v", utils.GetFnName(), dstDirInodeNumber, dstBasename)
+		err = blunder.AddError(err, blunder.FileExistsError)
+		return
+	}
+
+	if MoveFlagExchange == flags {
+		if nil == dstInode {
+			err = fmt.Errorf("%v: Target of Move() must exist when MoveFlagExchange specified: %v/%v", utils.GetFnName(), dstDirInodeNumber, dstBasename)
+			err = blunder.AddError(err, blunder.NotFoundError)
+			return
+		}
+
+		err = vS.exchangeWhileLocked(srcDirInode, srcBasename, srcInode, dstDirInode, dstBasename, dstInode)
+		if nil == err {
+			toDestroyInodeNumber = InodeNumber(0)
+			stats.IncrementOperations(&stats.DirRenameSuccessOps)
+		}
+		return
+	}
+
 	// I believe this is allowed so long at the dstInode is empty --craig
 	if (nil != dstInode) && (DirType == dstInode.InodeType) {
 		err = fmt.Errorf("%v: Target of Move() is an existing directory: %v/%v", utils.GetFnName(), dstDirInodeNumber, dstBasename)
@@ -614,6 +675,8 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 		panic(err)
 	}
 
+	caseFoldIndexRemoved(srcDirInode, srcBasename)
+
 	if nil == dstInode {
 		ok, err = dstDirMapping.Put(dstBasename, srcInodeNumber)
 		if nil != err {
@@ -625,6 +688,8 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 			logger.ErrorfWithError(err, "Move(): dstDirInode Put error")
 			panic(err)
 		}
+
+		caseFoldIndexAdded(dstDirInode, dstBasename)
 	} else {
 		dstInode.dirty = true
 		dstInode.AttrChangeTime = updateTime
@@ -666,6 +731,137 @@ func (vS *volumeStruct) Move(srcDirInodeNumber InodeNumber, srcBasename string,
 	return
 }
 
+// exchangeWhileLocked atomically swaps the directory entries srcBasename (in srcDirInode)
+// and dstBasename (in dstDirInode). Both srcInode and dstInode survive the exchange, so
+// no LinkCount of either drops and nothing needs to be destroyed. If the two entries live
+// in different directories, any directory among them gets its ".." entry (and the LinkCount
+// of its new & old parent) adjusted accordingly.
+//
+func (vS *volumeStruct) exchangeWhileLocked(srcDirInode *inMemoryInodeStruct, srcBasename string, srcInode *inMemoryInodeStruct, dstDirInode *inMemoryInodeStruct, dstBasename string, dstInode *inMemoryInodeStruct) (err error) {
+	var (
+		dstDirMapping sortedmap.BPlusTree
+		inodes        []*inMemoryInodeStruct
+		ok            bool
+		srcDirMapping sortedmap.BPlusTree
+		updateTime    time.Time
+	)
+
+	if srcInode.InodeNumber == dstInode.InodeNumber {
+		// Both DirEntries reference the same (hard linked) Inode... so the exchange is a no-op
+
+		err = nil
+		return
+	}
+
+	srcDirMapping = srcDirInode.payload.(sortedmap.BPlusTree)
+	dstDirMapping = dstDirInode.payload.(sortedmap.BPlusTree)
+
+	// Pre-flush any FileInodes so that no time-based (implicit) flushes will occur during this transaction
+
+	if FileType == srcInode.InodeType {
+		err = vS.flushInode(srcInode)
+		if nil != err {
+			logger.ErrorfWithError(err, "Move(): srcInode flush error")
+			panic(err)
+		}
+	}
+	if FileType == dstInode.InodeType {
+		err = vS.flushInode(dstInode)
+		if nil != err {
+			logger.ErrorfWithError(err, "Move(): dstInode flush error")
+			panic(err)
+		}
+	}
+
+	updateTime = time.Now()
+
+	inodes = make([]*inMemoryInodeStruct, 0, 4)
+
+	srcDirInode.dirty = true
+	srcDirInode.AttrChangeTime = updateTime
+	srcDirInode.ModificationTime = updateTime
+	inodes = append(inodes, srcDirInode)
+
+	if srcDirInode.InodeNumber != dstDirInode.InodeNumber {
+		dstDirInode.dirty = true
+		dstDirInode.AttrChangeTime = updateTime
+		dstDirInode.ModificationTime = updateTime
+		inodes = append(inodes, dstDirInode)
+
+		if DirType == srcInode.InodeType {
+			srcDirInode.LinkCount--
+			dstDirInode.LinkCount++
+
+			ok, err = srcInode.payload.(sortedmap.BPlusTree).PatchByKey("..", dstDirInode.InodeNumber)
+			if nil != err {
+				logger.ErrorfWithError(err, "Move(): srcInode PatchByKey error")
+				panic(err)
+			}
+			if !ok {
+				err = fmt.Errorf("Should have found \"..\" entry")
+				logger.ErrorfWithError(err, "Move(): srcInode PatchByKey error")
+				panic(err)
+			}
+		}
+
+		if DirType == dstInode.InodeType {
+			dstDirInode.LinkCount--
+			srcDirInode.LinkCount++
+
+			ok, err = dstInode.payload.(sortedmap.BPlusTree).PatchByKey("..", srcDirInode.InodeNumber)
+			if nil != err {
+				logger.ErrorfWithError(err, "Move(): dstInode PatchByKey error")
+				panic(err)
+			}
+			if !ok {
+				err = fmt.Errorf("Should have found \"..\" entry")
+				logger.ErrorfWithError(err, "Move(): dstInode PatchByKey error")
+				panic(err)
+			}
+		}
+	}
+
+	srcInode.dirty = true
+	srcInode.AttrChangeTime = updateTime
+	inodes = append(inodes, srcInode)
+
+	dstInode.dirty = true
+	dstInode.AttrChangeTime = updateTime
+	inodes = append(inodes, dstInode)
+
+	ok, err = srcDirMapping.PatchByKey(srcBasename, dstInode.InodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "Move(): srcDirInode PatchByKey error")
+		panic(err)
+	}
+	if !ok {
+		err = fmt.Errorf("Should have been able to PatchByKey \"%v\" entry", srcBasename)
+		logger.ErrorfWithError(err, "Move(): srcDirInode PatchByKey error")
+		panic(err)
+	}
+
+	ok, err = dstDirMapping.PatchByKey(dstBasename, srcInode.InodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "Move(): dstDirInode PatchByKey error")
+		panic(err)
+	}
+	if !ok {
+		err = fmt.Errorf("Should have been able to PatchByKey \"%v\" entry", dstBasename)
+		logger.ErrorfWithError(err, "Move(): dstDirInode PatchByKey error")
+		panic(err)
+	}
+
+	// Flush the multi-inode transaction
+
+	err = vS.flushInodes(inodes)
+	if nil != err {
+		logger.ErrorfWithError(err, "flushInodes(%v) error", inodes)
+		panic(err)
+	}
+
+	return
+}
+
 func (vS *volumeStruct) lookupByDirInode(dirInode *inMemoryInodeStruct, basename string) (targetInodeNumber InodeNumber, err error) {
 	var (
 		dirInodeSnapShotID uint64
@@ -676,6 +872,8 @@ func (vS *volumeStruct) lookupByDirInode(dirInode *inMemoryInodeStruct, basename
 
 	_, dirInodeSnapShotID, _ = vS.headhunterVolumeHandle.SnapShotU64Decode(uint64(dirInode.InodeNumber))
 
+	basename = vS.resolveBasename(dirInode, basename)
+
 	dirMapping = dirInode.payload.(sortedmap.BPlusTree)
 	value, ok, err = dirMapping.GetByKey(basename)
 	if nil != err {
@@ -764,6 +962,8 @@ func (vS *volumeStruct) lookupByDirInodeNumber(dirInodeNumber InodeNumber, basen
 		return
 	}
 
+	basename = vS.resolveBasename(dirInode, basename)
+
 	dirMapping = dirInode.payload.(sortedmap.BPlusTree)
 	value, ok, err = dirMapping.GetByKey(basename)
 	if nil != err {
@@ -1294,6 +1494,8 @@ func (vS *volumeStruct) AddDirEntry(dirInodeNumber InodeNumber, dirEntryName str
 		return
 	}
 
+	caseFoldIndexAdded(dirInode, dirEntryName)
+
 	if !skipDirLinkCountIncrementOnSubDirEntry && (DirType == dirEntryInode.InodeType) {
 		dirInode.onDiskInodeV1Struct.LinkCount++
 	}
@@ -1391,7 +1593,7 @@ func (vS *volumeStruct) ReplaceDirEntries(parentDirInodeNumber InodeNumber, pare
 		}
 	}
 
-	dirMapping = sortedmap.NewBPlusTree(vS.maxEntriesPerDirNode, sortedmap.CompareString, &dirInodeCallbacks{treeNodeLoadable{inode: dirInode}}, globals.dirEntryCache)
+	dirMapping = sortedmap.NewBPlusTree(vS.maxEntriesPerDirNode, sortedmap.CompareString, &dirInodeCallbacks{treeNodeLoadable{inode: dirInode}}, vS.dirEntryCache.Cache())
 
 	ok, err = dirMapping.Put(".", dirInodeNumber)
 	if (nil != err) || !ok {
@@ -1418,6 +1620,11 @@ func (vS *volumeStruct) ReplaceDirEntries(parentDirInodeNumber InodeNumber, pare
 		}
 	}
 
+	err = discardCaseFoldIndex(dirInode)
+	if nil != err {
+		return
+	}
+
 	dirInode.payload = dirMapping
 	dirInode.onDiskInodeV1Struct.LinkCount = dirLinkCount
 
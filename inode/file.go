@@ -21,11 +21,33 @@ type fileExtentStruct struct {
 	FileOffset       uint64
 	Length           uint64
 	LogSegmentNumber uint64
-	LogSegmentOffset uint64
+	LogSegmentOffset uint64 // If CompressedLength != 0, start of the compressed frame in the LogSegment
+	CompressedLength uint64 // If != 0, the extent is [FrameOffset:FrameOffset+Length) of the decompressed frame
+	FrameOffset      uint64 // If CompressedLength == 0, == 0
+}
+
+// sliceFileExtent returns the portion of fileExtent starting skipSize bytes into it.
+func sliceFileExtent(fileExtent *fileExtentStruct, skipSize uint64) (slicedFileExtent *fileExtentStruct) {
+	slicedFileExtent = &fileExtentStruct{
+		FileOffset:       fileExtent.FileOffset + skipSize,
+		Length:           fileExtent.Length - skipSize,
+		LogSegmentNumber: fileExtent.LogSegmentNumber,
+		LogSegmentOffset: fileExtent.LogSegmentOffset,
+		CompressedLength: fileExtent.CompressedLength,
+		FrameOffset:      fileExtent.FrameOffset,
+	}
+
+	if 0 == fileExtent.CompressedLength {
+		slicedFileExtent.LogSegmentOffset += skipSize
+	} else {
+		slicedFileExtent.FrameOffset += skipSize
+	}
+
+	return
 }
 
 func (vS *volumeStruct) CreateFile(filePerm InodeMode, userID InodeUserID, groupID InodeGroupID) (fileInodeNumber InodeNumber, err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -63,7 +85,7 @@ func (vS *volumeStruct) createFileInode(filePerm InodeMode, userID InodeUserID,
 			vS.maxExtentsPerFileNode,
 			sortedmap.CompareUint64,
 			&fileInodeCallbacks{treeNodeLoadable{inode: fileInode}},
-			globals.fileExtentMapCache)
+			vS.fileExtentMapCache.Cache())
 
 	fileInode.payload = extents
 
@@ -106,6 +128,14 @@ func fileLen(extents sortedmap.BPlusTree) uint64 {
 //
 // Doesn't flush anything.
 func setSizeInMemory(fileInode *inMemoryInodeStruct, size uint64) (err error) {
+	if size < uint64(len(fileInode.InlineData)) {
+		if 0 == size {
+			fileInode.InlineData = nil
+		} else {
+			fileInode.InlineData = fileInode.InlineData[:size]
+		}
+	}
+
 	extents := fileInode.payload.(sortedmap.BPlusTree)
 	extentIndex, found, err := extents.BisectLeft(size)
 	if nil != err {
@@ -200,6 +230,8 @@ func (vS *volumeStruct) Read(fileInodeNumber InodeNumber, offset uint64, length
 		return
 	}
 
+	vS.readAhead(fileInode, snapShotID, offset, uint64(len(buf)))
+
 	stats.IncrementOperationsAndBucketedBytes(stats.FileRead, uint64(len(buf)))
 
 	err = nil
@@ -279,6 +311,11 @@ func (vS *volumeStruct) getReadPlanHelper(snapShotID uint64, fileInode *inMemory
 		return
 	}
 
+	if 0 < len(fileInode.InlineData) {
+		readPlan = appendInlineDataReadPlanSteps(readPlan, fileInode.InlineData, offset, offset+readPlanBytes)
+		return
+	}
+
 	extents := fileInode.payload.(sortedmap.BPlusTree)
 
 	curOffset := offset
@@ -347,9 +384,13 @@ func (vS *volumeStruct) getReadPlanHelper(snapShotID uint64, fileInode *inMemory
 				LogSegmentNumber: vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(snapShotID, curExtent.LogSegmentNumber),
 				Offset:           curExtent.LogSegmentOffset + skipSize,
 				Length:           terminalOffset - curOffset,
-				AccountName:      vS.accountName,
 			}
-			step.ContainerName, step.ObjectName, step.ObjectPath, err = vS.getObjectLocationFromLogSegmentNumber(step.LogSegmentNumber)
+			if 0 != curExtent.CompressedLength {
+				step.Offset = curExtent.LogSegmentOffset
+				step.CompressedLength = curExtent.CompressedLength
+				step.FrameOffset = curExtent.FrameOffset + skipSize
+			}
+			step.AccountName, step.ContainerName, step.ObjectName, step.ObjectPath, err = vS.getObjectLocationFromLogSegmentNumber(step.LogSegmentNumber)
 			if nil != err {
 				return
 			}
@@ -362,9 +403,13 @@ func (vS *volumeStruct) getReadPlanHelper(snapShotID uint64, fileInode *inMemory
 				LogSegmentNumber: vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(snapShotID, curExtent.LogSegmentNumber),
 				Offset:           curExtent.LogSegmentOffset + skipSize,
 				Length:           curExtent.Length - skipSize,
-				AccountName:      vS.accountName,
 			}
-			step.ContainerName, step.ObjectName, step.ObjectPath, err = vS.getObjectLocationFromLogSegmentNumber(step.LogSegmentNumber)
+			if 0 != curExtent.CompressedLength {
+				step.Offset = curExtent.LogSegmentOffset
+				step.CompressedLength = curExtent.CompressedLength
+				step.FrameOffset = curExtent.FrameOffset + skipSize
+			}
+			step.AccountName, step.ContainerName, step.ObjectName, step.ObjectPath, err = vS.getObjectLocationFromLogSegmentNumber(step.LogSegmentNumber)
 			if nil != err {
 				return
 			}
@@ -395,9 +440,115 @@ func (vS *volumeStruct) getReadPlanHelper(snapShotID uint64, fileInode *inMemory
 	return
 }
 
+// appendInlineDataReadPlanSteps serves [offset:terminalOffset) of a FileInode whose data is inlined
+// (zero-filling beyond the end of inlineData).
+func appendInlineDataReadPlanSteps(readPlan []ReadPlanStep, inlineData []byte, offset uint64, terminalOffset uint64) (newReadPlan []ReadPlanStep) {
+	var (
+		inlineDataLength uint64
+		step             ReadPlanStep
+	)
+
+	newReadPlan = readPlan
+
+	inlineDataLength = uint64(len(inlineData))
+
+	if offset < inlineDataLength {
+		step = ReadPlanStep{Data: make([]byte, 0, inlineDataLength-offset)}
+		if terminalOffset < inlineDataLength {
+			step.Data = append(step.Data, inlineData[offset:terminalOffset]...)
+		} else {
+			step.Data = append(step.Data, inlineData[offset:]...)
+		}
+		step.Length = uint64(len(step.Data))
+		newReadPlan = append(newReadPlan, step)
+		offset += step.Length
+	}
+
+	if offset < terminalOffset {
+		step = ReadPlanStep{
+			LogSegmentNumber: 0,
+			Offset:           0,
+			Length:           terminalOffset - offset,
+			AccountName:      "",
+			ContainerName:    "",
+			ObjectName:       "",
+			ObjectPath:       "",
+		}
+		newReadPlan = append(newReadPlan, step)
+	}
+
+	return
+}
+
+// promoteInlineData moves the inlined data (if any) of fileInode to a LogSegment.
+func (vS *volumeStruct) promoteInlineData(fileInode *inMemoryInodeStruct) (err error) {
+	var (
+		inlineData       []byte
+		logSegmentNumber uint64
+		logSegmentOffset uint64
+	)
+
+	if 0 == len(fileInode.InlineData) {
+		return
+	}
+
+	inlineData = fileInode.InlineData
+
+	fileInode.dirty = true
+
+	logSegmentNumber, logSegmentOffset, err = vS.doSendChunk(fileInode, inlineData, nil)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	fileInode.InlineData = nil
+
+	err = recordWrite(fileInode, 0, uint64(len(inlineData)), logSegmentNumber, logSegmentOffset)
+
+	return // err as returned by recordWrite() is sufficient
+}
+
+// writeInlineData applies a Write() to a FileInode's inlined data if the file (having no extents)
+// would remain no larger than maxInlineFileSize. Otherwise, any inlined data is moved to a LogSegment.
+func (vS *volumeStruct) writeInlineData(fileInode *inMemoryInodeStruct, offset uint64, buf []byte) (inlined bool, err error) {
+	var (
+		endingOffset uint64
+		extentsLen   int
+	)
+
+	endingOffset = offset + uint64(len(buf))
+
+	extentsLen, err = fileInode.payload.(sortedmap.BPlusTree).Len()
+	if nil != err {
+		return
+	}
+
+	if (0 < extentsLen) || (endingOffset > vS.maxInlineFileSize) || (fileInode.Size > vS.maxInlineFileSize) {
+		inlined = false
+		err = vS.promoteInlineData(fileInode)
+		return
+	}
+
+	if endingOffset > uint64(len(fileInode.InlineData)) {
+		fileInode.InlineData = append(fileInode.InlineData, make([]byte, endingOffset-uint64(len(fileInode.InlineData)))...)
+	}
+
+	copy(fileInode.InlineData[offset:], buf)
+
+	if endingOffset > fileInode.Size {
+		fileInode.Size = endingOffset
+	}
+
+	inlined = true
+	return
+}
+
 func (vS *volumeStruct) FetchExtentMapChunk(fileInodeNumber InodeNumber, fileOffset uint64, maxEntriesFromFileOffset int64, maxEntriesBeforeFileOffset int64) (extentMapChunk *ExtentMapChunkStruct, err error) {
 	var (
+		accountName                 string
 		containerName               string
+		decompressedReadPlan        []ReadPlanStep
 		encodedLogSegmentNumber     uint64
 		extentMap                   sortedmap.BPlusTree
 		extentMapLen                int
@@ -410,6 +561,7 @@ func (vS *volumeStruct) FetchExtentMapChunk(fileInodeNumber InodeNumber, fileOff
 		fileExtentAsValue           sortedmap.Value
 		fileInode                   *inMemoryInodeStruct
 		objectName                  string
+		objectPath                  string
 		snapShotID                  uint64
 	)
 
@@ -429,6 +581,25 @@ func (vS *volumeStruct) FetchExtentMapChunk(fileInodeNumber InodeNumber, fileOff
 		return
 	}
 
+	// Inlined data has no LogSegment to reference... so supply it directly as the sole extent
+
+	if 0 < len(fileInode.InlineData) {
+		extentMapChunk = &ExtentMapChunkStruct{
+			FileOffsetRangeStart: 0,
+			FileOffsetRangeEnd:   fileInode.Size,
+			FileSize:             fileInode.Size,
+			ExtentMapEntry: []ExtentMapEntryStruct{
+				{
+					FileOffset:       0,
+					LogSegmentOffset: 0,
+					Length:           uint64(len(fileInode.InlineData)),
+					Data:             append([]byte(nil), fileInode.InlineData...),
+				},
+			},
+		}
+		return
+	}
+
 	// Ensure in-flight LogSegments are flushed
 
 	if fileInode.dirty {
@@ -531,11 +702,42 @@ func (vS *volumeStruct) FetchExtentMapChunk(fileInodeNumber InodeNumber, fileOff
 
 		encodedLogSegmentNumber = vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(snapShotID, fileExtent.LogSegmentNumber)
 
-		containerName, objectName, _, err = vS.getObjectLocationFromLogSegmentNumber(encodedLogSegmentNumber)
+		accountName, containerName, objectName, objectPath, err = vS.getObjectLocationFromLogSegmentNumber(encodedLogSegmentNumber)
 		if nil != err {
 			panic(err)
 		}
 
+		if 0 != fileExtent.CompressedLength {
+			// Compressed frames cannot be described by an ExtentMap either... so supply the decompressed data directly
+
+			decompressedReadPlan, err = vS.decompressReadPlanSteps(fileInode, []ReadPlanStep{
+				{
+					LogSegmentNumber: encodedLogSegmentNumber,
+					Offset:           fileExtent.LogSegmentOffset,
+					Length:           fileExtent.Length,
+					AccountName:      accountName,
+					ContainerName:    containerName,
+					ObjectName:       objectName,
+					ObjectPath:       objectPath,
+					CompressedLength: fileExtent.CompressedLength,
+					FrameOffset:      fileExtent.FrameOffset,
+				},
+			})
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+
+			extentMapChunk.ExtentMapEntry = append(extentMapChunk.ExtentMapEntry, ExtentMapEntryStruct{
+				FileOffset:       fileExtent.FileOffset,
+				LogSegmentOffset: 0,
+				Length:           fileExtent.Length,
+				Data:             decompressedReadPlan[0].Data,
+			})
+
+			continue
+		}
+
 		extentMapChunk.ExtentMapEntry = append(extentMapChunk.ExtentMapEntry, ExtentMapEntryStruct{
 			FileOffset:       fileExtent.FileOffset,
 			LogSegmentOffset: fileExtent.LogSegmentOffset,
@@ -591,6 +793,26 @@ func decrementLogSegmentMapFileData(fileInode *inMemoryInodeStruct, logSegmentNu
 // `recordWrite` is called by `Write` and `Wrote` to update the file inode
 // payload's record of the extents that compose the file.
 func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint64, logSegmentNumber uint64, logSegmentOffset uint64) (err error) {
+	err = recordExtent(fileInode, &fileExtentStruct{
+		FileOffset:       fileOffset,
+		Length:           length,
+		LogSegmentNumber: logSegmentNumber,
+		LogSegmentOffset: logSegmentOffset,
+	})
+
+	return // err as returned by recordExtent() is sufficient
+}
+
+// `recordExtent` is the general form of `recordWrite` also able to record
+// an extent whose data resides in a compressed frame.
+func recordExtent(fileInode *inMemoryInodeStruct, newExtent *fileExtentStruct) (err error) {
+	var (
+		fileOffset       = newExtent.FileOffset
+		length           = newExtent.Length
+		logSegmentNumber = newExtent.LogSegmentNumber
+		logSegmentOffset = newExtent.LogSegmentOffset
+	)
+
 	extents := fileInode.payload.(sortedmap.BPlusTree)
 
 	// First we need to eliminate extents or portions thereof that overlap the specified write
@@ -615,6 +837,7 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 			if (leftExtent.FileOffset + leftExtent.Length) > fileOffset {
 				// Yes, we need to split the preceeding extent
 				splitOutSize := (leftExtent.FileOffset + leftExtent.Length) - fileOffset
+				rightExtent := sliceFileExtent(leftExtent, leftExtent.Length-splitOutSize)
 				leftExtent.Length -= splitOutSize
 				ok, patchByIndexErr := extents.PatchByIndex(extentIndex, leftExtent)
 				if nil != patchByIndexErr {
@@ -624,12 +847,6 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 					unexpectedErr := fmt.Errorf("unexpected extents indexing problem")
 					panic(unexpectedErr)
 				}
-				rightExtent := &fileExtentStruct{
-					FileOffset:       fileOffset,
-					Length:           splitOutSize,
-					LogSegmentNumber: leftExtent.LogSegmentNumber,
-					LogSegmentOffset: leftExtent.LogSegmentOffset + leftExtent.Length,
-				}
 				ok, putErr := extents.Put(rightExtent.FileOffset, rightExtent)
 				if nil != putErr {
 					panic(putErr)
@@ -680,12 +897,7 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 				panic(unexpectedErr)
 			}
 			overlapSize := (fileOffset + length) - leftExtent.FileOffset
-			rightExtent := &fileExtentStruct{
-				FileOffset:       leftExtent.FileOffset + overlapSize,
-				Length:           leftExtent.Length - overlapSize,
-				LogSegmentNumber: leftExtent.LogSegmentNumber,
-				LogSegmentOffset: leftExtent.LogSegmentOffset + overlapSize,
-			}
+			rightExtent := sliceFileExtent(leftExtent, overlapSize)
 			ok, putErr := extents.Put(rightExtent.FileOffset, rightExtent)
 			if nil != putErr {
 				panic(putErr)
@@ -727,7 +939,7 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 		prevExtent = prevExtentValue.(*fileExtentStruct)
 	}
 
-	if (nil != prevExtent) && (prevExtent.LogSegmentNumber == logSegmentNumber) && ((prevExtent.FileOffset + prevExtent.Length) == fileOffset) && ((prevExtent.LogSegmentOffset + prevExtent.Length) == logSegmentOffset) {
+	if (nil != prevExtent) && (0 == prevExtent.CompressedLength) && (0 == newExtent.CompressedLength) && (prevExtent.LogSegmentNumber == logSegmentNumber) && ((prevExtent.FileOffset + prevExtent.Length) == fileOffset) && ((prevExtent.LogSegmentOffset + prevExtent.Length) == logSegmentOffset) {
 		// APPEND Case: We are able to simply lengthen prevExtent
 
 		prevExtent.Length += length
@@ -742,13 +954,6 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 	} else {
 		// Non-APPEND Case: We need to insert a new extent
 
-		newExtent := &fileExtentStruct{
-			FileOffset:       fileOffset,
-			Length:           length,
-			LogSegmentNumber: logSegmentNumber,
-			LogSegmentOffset: logSegmentOffset,
-		}
-
 		ok, putErr := extents.Put(newExtent.FileOffset, newExtent)
 		if nil != putErr {
 			panic(putErr)
@@ -769,7 +974,13 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 }
 
 func (vS *volumeStruct) Write(fileInodeNumber InodeNumber, offset uint64, buf []byte, profiler *utils.Profiler) (err error) {
-	err = enforceRWMode(true)
+	err = vS.write(fileInodeNumber, offset, buf, profiler, vS.dedupEnabled)
+	return // err as returned by write() is sufficient
+}
+
+// write implements Write() optionally deduplicating buf if allowDedup is true.
+func (vS *volumeStruct) write(fileInodeNumber InodeNumber, offset uint64, buf []byte, profiler *utils.Profiler, allowDedup bool) (err error) {
+	err = vS.enforceRWMode(true)
 	if nil != err {
 		return
 	}
@@ -793,19 +1004,44 @@ func (vS *volumeStruct) Write(fileInodeNumber InodeNumber, offset uint64, buf []
 
 	fileInode.dirty = true
 
-	logSegmentNumber, logSegmentOffset, err := vS.doSendChunk(fileInode, buf)
-	if nil != err {
-		logger.ErrorWithError(err)
-		return
-	}
-
 	length := uint64(len(buf))
 	startingSize := fileInode.Size
 
-	err = recordWrite(fileInode, offset, length, logSegmentNumber, logSegmentOffset)
-	if nil != err {
-		logger.ErrorWithError(err)
-		return
+	inlined := false
+
+	if 0 < vS.maxInlineFileSize {
+		inlined, err = vS.writeInlineData(fileInode, offset, buf)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+	}
+
+	if !inlined && allowDedup {
+		err = vS.dedupWrite(fileInode, offset, buf)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+	} else if !inlined && vS.compressionEnabledForFileInode(fileInode) {
+		err = vS.compressedWrite(fileInode, offset, buf)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+	} else if !inlined {
+		logSegmentNumber, logSegmentOffset, doSendChunkErr := vS.doSendChunk(fileInode, buf, nil)
+		if nil != doSendChunkErr {
+			err = doSendChunkErr
+			logger.ErrorWithError(err)
+			return
+		}
+
+		err = recordWrite(fileInode, offset, length, logSegmentNumber, logSegmentOffset)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
 	}
 
 	appendedBytes := fileInode.Size - startingSize
@@ -822,7 +1058,7 @@ func (vS *volumeStruct) Write(fileInodeNumber InodeNumber, offset uint64, buf []
 }
 
 func (vS *volumeStruct) Wrote(fileInodeNumber InodeNumber, containerName string, objectName string, fileOffset []uint64, objectOffset []uint64, length []uint64, wroteTime time.Time, patchOnly bool) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -864,7 +1100,13 @@ func (vS *volumeStruct) Wrote(fileInodeNumber InodeNumber, containerName string,
 
 	fileInode.dirty = true
 
-	if !patchOnly {
+	if patchOnly {
+		err = vS.promoteInlineData(fileInode)
+		if err != nil {
+			logger.ErrorWithError(err)
+			return
+		}
+	} else {
 		err = setSizeInMemory(fileInode, 0)
 		if err != nil {
 			logger.ErrorWithError(err)
@@ -908,7 +1150,7 @@ func (vS *volumeStruct) Wrote(fileInodeNumber InodeNumber, containerName string,
 }
 
 func (vS *volumeStruct) SetSize(fileInodeNumber InodeNumber, size uint64) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -947,7 +1189,7 @@ func (vS *volumeStruct) SetSize(fileInodeNumber InodeNumber, size uint64) (err e
 }
 
 func (vS *volumeStruct) Flush(fileInodeNumber InodeNumber, andPurge bool) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1052,13 +1294,16 @@ func (vS *volumeStruct) Coalesce(destInodeNumber InodeNumber, metaDataName strin
 		inodeList                          []*inMemoryInodeStruct
 		inodeMap                           map[InodeNumber]*inMemoryInodeStruct
 		localErr                           error
+		logSegmentNumber                   uint64
 		logSegmentReferencedBytes          uint64
+		logSegmentsHeld                    map[uint64]struct{}
+		logSegmentsToUnreference           []uint64
 		ok                                 bool
 		snapShotIDType                     headhunter.SnapShotIDType
 		toDestroyInodeNumber               InodeNumber
 	)
 
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1137,6 +1382,16 @@ func (vS *volumeStruct) Coalesce(destInodeNumber InodeNumber, metaDataName strin
 		}
 	}
 
+	// Inlined data cannot be appended as extents... so move it to LogSegments first
+
+	for _, elementInode = range inodeList {
+		err = vS.promoteInlineData(elementInode)
+		if nil != err {
+			err = blunder.NewError(blunder.InvalidArgError, "Coalesce() unable to promote inlined data of Inode 0x%016X: %v", elementInode.InodeNumber, err)
+			return
+		}
+	}
+
 	// Ensure all referenced FileInodes are pre-flushed
 
 	err = vS.flushInodes(inodeList)
@@ -1153,6 +1408,27 @@ func (vS *volumeStruct) Coalesce(destInodeNumber InodeNumber, metaDataName strin
 
 	destInodeOffsetBeforeElementAppend = fileLen(destInodeExtentMap)
 
+	// Deduplication may have led to LogSegments referenced by more than one of the FileInodes
+	// whose references are being combined... destInode need only hold a single reference
+
+	logSegmentsHeld = make(map[uint64]struct{})
+	logSegmentsToUnreference = make([]uint64, 0)
+
+	for logSegmentNumber = range destInode.LogSegmentMap {
+		logSegmentsHeld[logSegmentNumber] = struct{}{}
+	}
+
+	for _, element = range elements {
+		for logSegmentNumber = range inodeMap[element.ElementInodeNumber].LogSegmentMap {
+			_, ok = logSegmentsHeld[logSegmentNumber]
+			if ok {
+				logSegmentsToUnreference = append(logSegmentsToUnreference, logSegmentNumber)
+			} else {
+				logSegmentsHeld[logSegmentNumber] = struct{}{}
+			}
+		}
+	}
+
 	for _, element = range elements {
 		elementInode = inodeMap[element.ElementInodeNumber]
 		destInode.NumWrites++
@@ -1244,6 +1520,14 @@ func (vS *volumeStruct) Coalesce(destInodeNumber InodeNumber, metaDataName strin
 		return
 	}
 
+	for _, logSegmentNumber = range logSegmentsToUnreference {
+		err = vS.headhunterVolumeHandle.UnreferenceLogSegmentRec(logSegmentNumber)
+		if nil != err {
+			err = fmt.Errorf("Coalesce() doing UnreferenceLogSegmentRec(0x%016X) failed: %v", logSegmentNumber, err)
+			return
+		}
+	}
+
 	// Now we can Unlink and Destroy each element
 
 	for _, element = range elements {
@@ -1270,12 +1554,21 @@ func (vS *volumeStruct) Coalesce(destInodeNumber InodeNumber, metaDataName strin
 
 func (vS *volumeStruct) DefragmentFile(fileInodeNumber InodeNumber, startingFileOffset uint64, chunkSize uint64) (nextFileOffset uint64, eofReached bool, err error) {
 	var (
-		chunk           []byte
-		chunkSizeCapped uint64
-		fileInode       *inMemoryInodeStruct
+		chunkSizeCapped   uint64
+		extentAsValue     sortedmap.Value
+		extentIndex       int
+		extentLimit       uint64
+		extents           sortedmap.BPlusTree
+		fileExtent        *fileExtentStruct
+		fileInode         *inMemoryInodeStruct
+		fileOffset        uint64
+		ok                bool
+		preservedExtent   fileExtentStruct
+		preservedExtents  []fileExtentStruct
+		sharedLogSegments map[uint64]struct{}
 	)
 
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1302,14 +1595,129 @@ func (vS *volumeStruct) DefragmentFile(fileInodeNumber InodeNumber, startingFile
 
 	nextFileOffset = startingFileOffset + chunkSizeCapped
 
-	chunk, err = vS.Read(fileInodeNumber, startingFileOffset, chunkSizeCapped, nil)
+	// Extents referencing LogSegments shared with other FileInodes (e.g. via dedup) are left
+	// alone as rewriting them would only duplicate data the other FileInodes still reference
+
+	sharedLogSegments, err = vS.fetchSharedLogSegments(fileInode)
+	if nil != err {
+		return
+	}
+
+	preservedExtents = make([]fileExtentStruct, 0)
+
+	if 0 < len(sharedLogSegments) {
+		extents = fileInode.payload.(sortedmap.BPlusTree)
+
+		extentIndex, _, err = extents.BisectLeft(startingFileOffset)
+		if nil != err {
+			return
+		}
+		if 0 > extentIndex {
+			extentIndex = 0
+		}
+
+		for {
+			_, extentAsValue, ok, err = extents.GetByIndex(extentIndex)
+			if nil != err {
+				return
+			}
+			if !ok {
+				break
+			}
+
+			fileExtent = extentAsValue.(*fileExtentStruct)
+
+			if fileExtent.FileOffset >= nextFileOffset {
+				break
+			}
+
+			_, ok = sharedLogSegments[fileExtent.LogSegmentNumber]
+			if ok && ((fileExtent.FileOffset + fileExtent.Length) > startingFileOffset) {
+				preservedExtents = append(preservedExtents, *fileExtent) // copied as rewrites may modify the extent map
+			}
+
+			extentIndex++
+		}
+	}
+
+	// Rewrite each run of [startingFileOffset:nextFileOffset) not covered by a preserved extent
+
+	fileOffset = startingFileOffset
+
+	for _, preservedExtent = range preservedExtents {
+		if preservedExtent.FileOffset > fileOffset {
+			err = vS.defragmentFileRange(fileInodeNumber, fileOffset, preservedExtent.FileOffset-fileOffset)
+			if nil != err {
+				return
+			}
+		}
+
+		extentLimit = preservedExtent.FileOffset + preservedExtent.Length
+		if extentLimit > nextFileOffset {
+			extentLimit = nextFileOffset
+		}
+		if extentLimit > fileOffset {
+			fileOffset = extentLimit
+		}
+	}
+
+	if nextFileOffset > fileOffset {
+		err = vS.defragmentFileRange(fileInodeNumber, fileOffset, nextFileOffset-fileOffset)
+	}
+
+	return // err set as appropriate
+}
+
+// defragmentFileRange rewrites [fileOffset:fileOffset+length) of fileInodeNumber to a new LogSegment.
+func (vS *volumeStruct) defragmentFileRange(fileInodeNumber InodeNumber, fileOffset uint64, length uint64) (err error) {
+	var (
+		chunk []byte
+	)
+
+	chunk, err = vS.Read(fileInodeNumber, fileOffset, length, nil)
 	if nil != err {
 		return
 	}
 
-	err = vS.Write(fileInodeNumber, startingFileOffset, chunk, nil)
+	// Deduplication would simply reference the very LogSegments being defragmented
+
+	err = vS.write(fileInodeNumber, fileOffset, chunk, nil, false)
+
+	return // err as returned by write() is sufficient
+}
+
+// fetchSharedLogSegments returns those LogSegments referenced by fileInode that are also referenced
+// by other FileInodes (e.g. via dedup or SnapShotRestore()). Until stale reference counts have been
+// rebuilt, every LogSegment referenced by fileInode must be presumed shared.
+func (vS *volumeStruct) fetchSharedLogSegments(fileInode *inMemoryInodeStruct) (sharedLogSegments map[uint64]struct{}, err error) {
+	var (
+		logSegmentNumber uint64
+		rebuildRequired  bool
+		refCount         uint64
+	)
+
+	sharedLogSegments = make(map[uint64]struct{})
+
+	rebuildRequired = vS.headhunterVolumeHandle.FetchDedupStats().RebuildRequired
+
+	for logSegmentNumber = range fileInode.LogSegmentMap {
+		if rebuildRequired {
+			sharedLogSegments[logSegmentNumber] = struct{}{}
+			continue
+		}
+
+		refCount, err = vS.headhunterVolumeHandle.FetchLogSegmentRefCount(logSegmentNumber)
+		if nil != err {
+			return
+		}
+
+		if 1 < refCount {
+			sharedLogSegments[logSegmentNumber] = struct{}{}
+		}
+	}
 
-	return // err as returned by Write() is sufficient
+	err = nil
+	return
 }
 
 func (vS *volumeStruct) setLogSegmentContainer(logSegmentNumber uint64, containerName string) (err error) {
@@ -1327,7 +1735,7 @@ func (vS *volumeStruct) getLogSegmentContainer(logSegmentNumber uint64) (contain
 	return
 }
 
-func (vS *volumeStruct) getObjectLocationFromLogSegmentNumber(logSegmentNumber uint64) (containerName string, objectName string, objectPath string, err error) {
+func (vS *volumeStruct) getObjectLocationFromLogSegmentNumber(logSegmentNumber uint64) (accountName string, containerName string, objectName string, objectPath string, err error) {
 	var (
 		nonce uint64
 	)
@@ -1339,7 +1747,8 @@ func (vS *volumeStruct) getObjectLocationFromLogSegmentNumber(logSegmentNumber u
 
 	_, _, nonce = vS.headhunterVolumeHandle.SnapShotU64Decode(logSegmentNumber)
 
+	accountName = vS.headhunterVolumeHandle.FetchObjectAccountName(nonce)
 	objectName = fmt.Sprintf("%016X", nonce)
-	objectPath = fmt.Sprintf("/v1/%s/%s/%016X", vS.accountName, containerName, nonce)
+	objectPath = fmt.Sprintf("/v1/%s/%s/%016X", accountName, containerName, nonce)
 	return
 }
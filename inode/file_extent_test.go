@@ -638,3 +638,119 @@ func TestReadYourWrite(t *testing.T) {
 
 	testTeardown(t)
 }
+
+func TestInlineData(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") should have worked - got error: %v", err)
+	}
+
+	testVolumeHandle.(*volumeStruct).maxInlineFileSize = 16
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	err = testVolumeHandle.Write(fileInodeNumber, 4, []byte{1, 2, 3, 4}, nil)
+	if nil != err {
+		t.Fatalf("Write(fileInodeNumber, 4, []byte{1, 2, 3, 4}) failed: %v", err)
+	}
+
+	fileInode, err := (testVolumeHandle.(*volumeStruct)).fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	if 0 != bytes.Compare([]byte{0, 0, 0, 0, 1, 2, 3, 4}, fileInode.InlineData) {
+		t.Fatalf("Write() should have inlined data - got %v", fileInode.InlineData)
+	}
+
+	offset := uint64(2)
+	length := uint64(8)
+	readPlan, err := testVolumeHandle.GetReadPlan(fileInodeNumber, &offset, &length)
+	if nil != err {
+		t.Fatalf("GetReadPlan() failed: %v", err)
+	}
+	if (1 != len(readPlan)) || (0 != bytes.Compare([]byte{0, 0, 1, 2, 3, 4}, readPlan[0].Data)) || (6 != readPlan[0].Length) {
+		t.Fatalf("GetReadPlan() returned unexpected readPlan: %#v", readPlan)
+	}
+
+	err = testVolumeHandle.Flush(fileInodeNumber, true)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	readBuf, err := testVolumeHandle.Read(fileInodeNumber, 0, 16, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if 0 != bytes.Compare([]byte{0, 0, 0, 0, 1, 2, 3, 4}, readBuf) {
+		t.Fatalf("Read() of inlined data after Flush() returned %v", readBuf)
+	}
+
+	err = testVolumeHandle.SetSize(fileInodeNumber, 6)
+	if nil != err {
+		t.Fatalf("SetSize() failed: %v", err)
+	}
+
+	readBuf, err = testVolumeHandle.Read(fileInodeNumber, 0, 16, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if 0 != bytes.Compare([]byte{0, 0, 0, 0, 1, 2}, readBuf) {
+		t.Fatalf("Read() of inlined data after SetSize() returned %v", readBuf)
+	}
+
+	// FetchExtentMapChunk() should supply the inlined data without promoting it
+
+	extentMapChunk, err := testVolumeHandle.FetchExtentMapChunk(fileInodeNumber, 0, 1, 0)
+	if nil != err {
+		t.Fatalf("FetchExtentMapChunk() failed: %v", err)
+	}
+	if (0 != extentMapChunk.FileOffsetRangeStart) || (6 != extentMapChunk.FileOffsetRangeEnd) || (6 != extentMapChunk.FileSize) ||
+		(1 != len(extentMapChunk.ExtentMapEntry)) || (6 != extentMapChunk.ExtentMapEntry[0].Length) ||
+		(0 != bytes.Compare([]byte{0, 0, 0, 0, 1, 2}, extentMapChunk.ExtentMapEntry[0].Data)) {
+		t.Fatalf("FetchExtentMapChunk() of inlined data returned unexpected extentMapChunk: %#v", extentMapChunk)
+	}
+	fileInode, err = (testVolumeHandle.(*volumeStruct)).fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	if 0 != bytes.Compare([]byte{0, 0, 0, 0, 1, 2}, fileInode.InlineData) {
+		t.Fatalf("FetchExtentMapChunk() should not have promoted inlined data - got %v", fileInode.InlineData)
+	}
+
+	// Growing beyond maxInlineFileSize should move the inlined data to a LogSegment
+
+	err = testVolumeHandle.Write(fileInodeNumber, 14, []byte{5, 6, 7, 8}, nil)
+	if nil != err {
+		t.Fatalf("Write(fileInodeNumber, 14, []byte{5, 6, 7, 8}) failed: %v", err)
+	}
+
+	fileInode, err = (testVolumeHandle.(*volumeStruct)).fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType() failed: %v", err)
+	}
+	if nil != fileInode.InlineData {
+		t.Fatalf("Write() beyond maxInlineFileSize should have promoted inlined data")
+	}
+
+	readBuf, err = testVolumeHandle.Read(fileInodeNumber, 0, 18, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if 0 != bytes.Compare([]byte{0, 0, 0, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0, 0, 5, 6, 7, 8}, readBuf) {
+		t.Fatalf("Read() after promotion of inlined data returned %v", readBuf)
+	}
+
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	testVolumeHandle.(*volumeStruct).maxInlineFileSize = 0
+
+	testTeardown(t)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/headhunter"
 	"github.com/NVIDIA/proxyfs/logger"
 	"github.com/NVIDIA/proxyfs/stats"
 	"github.com/NVIDIA/proxyfs/swiftclient"
@@ -166,14 +167,28 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 	readCacheLineSize = volumeGroup.readCacheLineSize
 	readCacheKey.volumeName = vS.volumeName
 
+	readPlan, err = vS.decompressReadPlanSteps(fileInode, readPlan)
+	if nil != err {
+		return
+	}
+
 	if 1 == len(readPlan) {
-		// Possibly a trivial case (allowing for a potential zero-copy return)... three exist:
+		// Possibly a trivial case (allowing for a potential zero-copy return)... four exist:
+		//   Case 0: The lone step is satisfied by data inlined in the FileInode
 		//   Case 1: The lone step calls for a zero-filled []byte
 		//   Case 2: The lone step is satisfied by reading from an inFlightLogSegment
 		//   Case 3: The lone step is satisfied by landing completely within a single Read Cache Line
 
 		step = readPlan[0]
 
+		if nil != step.Data {
+			// Case 0: The lone step is satisfied by data inlined in the FileInode
+			buf = step.Data
+			stats.IncrementOperationsAndBucketedBytes(stats.FileRead, step.Length)
+			err = nil
+			return
+		}
+
 		if 0 == step.LogSegmentNumber {
 			// Case 1: The lone step calls for a zero-filled []byte
 			buf = make([]byte, step.Length)
@@ -218,6 +233,10 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 
 			if readCacheHit {
 				volumeGroup.touchReadCacheElementWhileLocked(readCacheElement)
+				if readCacheElement.readAhead {
+					readCacheElement.readAhead = false
+					globals.ReadAheadCacheLinesHit.Add(1)
+				}
 				cacheLine = readCacheElement.cacheLine
 				volumeGroup.Unlock()
 				stats.IncrementOperations(&stats.FileReadcacheHitOps)
@@ -264,7 +283,10 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 	buf = make([]byte, 0, readPlanBytes)
 
 	for stepIndex, step = range readPlan {
-		if 0 == step.LogSegmentNumber {
+		if nil != step.Data {
+			// The step is satisfied by data inlined in the FileInode
+			buf = append(buf, step.Data...)
+		} else if 0 == step.LogSegmentNumber {
 			// The step calls for a zero-filled []byte
 			buf = append(buf, make([]byte, step.Length)...)
 		} else {
@@ -310,6 +332,10 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 					readCacheElement, readCacheHit = volumeGroup.readCache[readCacheKey]
 					if readCacheHit {
 						volumeGroup.touchReadCacheElementWhileLocked(readCacheElement)
+						if readCacheElement.readAhead {
+							readCacheElement.readAhead = false
+							globals.ReadAheadCacheLinesHit.Add(1)
+						}
 						cacheLine = readCacheElement.cacheLine
 						volumeGroup.Unlock()
 						stats.IncrementOperations(&stats.FileReadcacheHitOps)
@@ -354,7 +380,9 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 	return
 }
 
-func (vS *volumeStruct) doSendChunk(fileInode *inMemoryInodeStruct, buf []byte) (logSegmentNumber uint64, logSegmentOffset uint64, err error) {
+// doSendChunk appends buf to the open LogSegment of fileInode (opening one if necessary). If fingerprint
+// is not nil, buf will be made available for deduplication once the LogSegment has been closed.
+func (vS *volumeStruct) doSendChunk(fileInode *inMemoryInodeStruct, buf []byte, fingerprint []byte) (logSegmentNumber uint64, logSegmentOffset uint64, err error) {
 	var (
 		inFlightLogSegment          *inFlightLogSegmentStruct
 		openLogSegmentContainerName string
@@ -371,7 +399,7 @@ func (vS *volumeStruct) doSendChunk(fileInode *inMemoryInodeStruct, buf []byte)
 
 		fileInode.Unlock()
 
-		openLogSegmentContainerName, openLogSegmentObjectNumber, err = fileInode.volume.provisionObject()
+		openLogSegmentContainerName, openLogSegmentObjectNumber, err = fileInode.volume.provisionObject(fileInode.volume.physicalContainerLayoutForFileInode(fileInode))
 		if nil != err {
 			logger.ErrorfWithError(err, "Provisioning LogSegment failed")
 			return
@@ -426,6 +454,14 @@ func (vS *volumeStruct) doSendChunk(fileInode *inMemoryInodeStruct, buf []byte)
 		return
 	}
 
+	if nil != fingerprint {
+		inFlightLogSegment.dedupFingerprints = append(inFlightLogSegment.dedupFingerprints, headhunter.DedupFingerprintStruct{
+			Fingerprint:      fingerprint,
+			LogSegmentOffset: logSegmentOffset,
+			Length:           uint64(len(buf)),
+		})
+	}
+
 	if (logSegmentOffset + uint64(len(buf))) >= fileInode.volume.maxFlushSize {
 		fileInode.Add(1)
 		go vS.inFlightLogSegmentFlusher(inFlightLogSegment, true)
@@ -499,7 +535,20 @@ func (vS *volumeStruct) inFlightLogSegmentFlusher(inFlightLogSegment *inFlightLo
 	// Terminate Chunked PUT while not holding fileInode.Lock
 
 	fileInode.Unlock()
+	logSegmentLength, logSegmentLengthErr := inFlightLogSegment.BytesPut()
 	err = inFlightLogSegment.Close()
+	if (nil == err) && (nil == logSegmentLengthErr) {
+		vS.recordLogSegmentLength(inFlightLogSegment.logSegmentNumber, logSegmentLength)
+	}
+	if (nil == err) && (0 < len(inFlightLogSegment.dedupFingerprints)) {
+		// Failure here merely means subsequent Write()'s won't be able to reference this LogSegment
+
+		dedupErr := vS.headhunterVolumeHandle.PutDedupFingerprints(inFlightLogSegment.logSegmentNumber, inFlightLogSegment.dedupFingerprints)
+		if nil != dedupErr {
+			logger.WarnfWithError(dedupErr, "Recording dedup fingerprints of LogSegment 0x%016X failed", inFlightLogSegment.logSegmentNumber)
+		}
+		inFlightLogSegment.dedupFingerprints = nil
+	}
 	fileInode.Lock()
 
 	// Finish up... recording error (if any) in the process
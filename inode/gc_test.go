@@ -59,7 +59,7 @@ func TestEmptySegmentDeletion(t *testing.T) {
 	segmentObjectLocations := make([]testObjectLocationStruct, 0, 5)
 	for segmentNumber := range ourInode.LogSegmentMap {
 		segmentNumbers = append(segmentNumbers, segmentNumber)
-		containerName, objectName, _, getObjectLocationErr := volume.getObjectLocationFromLogSegmentNumber(segmentNumber)
+		_, containerName, objectName, _, getObjectLocationErr := volume.getObjectLocationFromLogSegmentNumber(segmentNumber)
 		if nil != getObjectLocationErr {
 			t.Fatalf("expected to be able to get log segment 0x%016X", segmentNumber)
 		}
@@ -32,7 +32,8 @@ import (
 var int_inode_debug = logger.DbgInodeInternal
 
 const (
-	optimisticInodeFetchBytes = 2048
+	optimisticInodeFetchBytes       = 2048
+	logSegmentLengthCacheMaxEntries = uint64(65536)
 )
 
 type CorruptionDetected bool
@@ -40,27 +41,31 @@ type Version uint64
 
 const (
 	V1                               Version = iota + 1 // use type/struct onDiskInodeV1Struct
+	V2                                                  // use type/struct onDiskInodeV1Struct (FileInode data may be inlined)
 	onDiskInodeV1PayloadObjectOffset uint64  = 0
 )
 
 type onDiskInodeV1Struct struct { // Preceded "on disk" by CorruptionDetected then Version both in cstruct.LittleEndian form
 	InodeNumber
 	InodeType
-	LinkCount           uint64
-	Size                uint64
-	CreationTime        time.Time
-	ModificationTime    time.Time
-	AccessTime          time.Time
-	AttrChangeTime      time.Time
-	NumWrites           uint64
-	Mode                InodeMode
-	UserID              InodeUserID
-	GroupID             InodeGroupID
-	StreamMap           map[string][]byte
-	PayloadObjectNumber uint64            // DirInode:     B+Tree Root with Key == dir_entry_name, Value = InodeNumber
-	PayloadObjectLength uint64            // FileInode:    B+Tree Root with Key == fileOffset, Value = fileExtent
-	SymlinkTarget       string            // SymlinkInode: target path of symbolic link
-	LogSegmentMap       map[uint64]uint64 // FileInode:    Key == LogSegment#, Value = file user data byte count
+	LinkCount            uint64
+	Size                 uint64
+	CreationTime         time.Time
+	ModificationTime     time.Time
+	AccessTime           time.Time
+	AttrChangeTime       time.Time
+	NumWrites            uint64
+	Mode                 InodeMode
+	UserID               InodeUserID
+	GroupID              InodeGroupID
+	StreamMap            map[string][]byte
+	PayloadObjectNumber  uint64            // DirInode:     B+Tree Root with Key == dir_entry_name, Value = InodeNumber
+	PayloadObjectLength  uint64            // FileInode:    B+Tree Root with Key == fileOffset, Value = fileExtent
+	SymlinkTarget        string            // SymlinkInode: target path of symbolic link
+	LogSegmentMap        map[uint64]uint64 // FileInode:    Key == LogSegment#, Value = file user data byte count
+	InlineData           []byte            `json:",omitempty"` // FileInode: if len() != 0, file data (beyond which is zero-filled up to Size) in lieu of LogSegments
+	CaseFoldObjectNumber uint64            `json:",omitempty"` // DirInode: if != 0, B+Tree Root with Key == caseFold(dir_entry_name) + "/" + dir_entry_name, Value = dir_entry_name
+	CaseFoldObjectLength uint64            `json:",omitempty"` // DirInode: see CaseFoldObjectNumber
 }
 
 type inFlightLogSegmentStruct struct { //               Used as (by reference) Value for inMemoryInodeStruct.inFlightLogSegmentMap
@@ -72,6 +77,7 @@ type inFlightLogSegmentStruct struct { //               Used as (by reference) V
 	containerName             string
 	objectName                string
 	openLogSegmentListElement list.Element
+	dedupFingerprints         []headhunter.DedupFingerprintStruct // chunks to be made available for dedup once closed
 	swiftclient.ChunkedPutContext
 }
 
@@ -88,6 +94,11 @@ type inMemoryInodeStruct struct {
 	openLogSegment           *inFlightLogSegmentStruct            // FileInode only... also in inFlightLogSegmentMap
 	inFlightLogSegmentMap    map[uint64]*inFlightLogSegmentStruct // FileInode: key == logSegmentNumber
 	inFlightLogSegmentErrors map[uint64]error                     // FileInode: key == logSegmentNumber; value == err (if non nil)
+	readAheadNextOffset      uint64                               // FileInode: offset of a Read() continuing a sequential access pattern
+	readAheadWindow          uint64                               // FileInode: current read-ahead window size (0 if access not sequential)
+	readAheadEndOffset       uint64                               // FileInode: end of the range covered by the most recent readAhead()
+	caseFoldIndex            sortedmap.BPlusTree                  // DirInode: if != nil, B+Tree persisted via CaseFoldObjectNumber
+	caseFoldIndexMutex       sync.Mutex                           // DirInode: serializes access to caseFoldIndex (which may be built under a shared lock)
 	onDiskInodeV1Struct                                           // Real on-disk inode information embedded here
 }
 
@@ -163,7 +174,7 @@ func setRWMode(rwMode RWModeType) (err error) {
 	return
 }
 
-func enforceRWMode(enforceNoWriteMode bool) (err error) {
+func (vS *volumeStruct) enforceRWMode(enforceNoWriteMode bool) (err error) {
 	var (
 		rwModeCopy RWModeType
 	)
@@ -174,6 +185,10 @@ func enforceRWMode(enforceNoWriteMode bool) (err error) {
 		err = blunder.NewError(globals.readOnlyThresholdErrno, globals.readOnlyThresholdErrnoString)
 	} else if enforceNoWriteMode && (rwModeCopy == RWModeNoWrite) {
 		err = blunder.NewError(globals.noWriteThresholdErrno, globals.noWriteThresholdErrnoString)
+	} else if vS.headhunterVolumeHandle.IsReplica() {
+		err = blunder.NewError(blunder.ReadOnlyError, "volume %s is a replica", vS.volumeName)
+	} else if 0 != vS.headhunterVolumeHandle.InspectedRetainedCheckpoint() {
+		err = blunder.NewError(blunder.ReadOnlyError, "volume %s is inspecting a retained checkpoint", vS.volumeName)
 	} else {
 		err = nil
 	}
@@ -270,7 +285,8 @@ func (vS *volumeStruct) fetchOnDiskInode(inodeNumber InodeNumber) (inMemoryInode
 		err = blunder.AddError(err, blunder.CorruptInodeError)
 		return
 	}
-	if V1 != version {
+	_, ok = globals.supportedOnDiskInodeVersions[version]
+	if !ok {
 		err = fmt.Errorf("%s: inodeRec.Version for inode %d (%v) not supported", utils.GetFnName(), inodeNumber, version)
 		err = blunder.AddError(err, blunder.CorruptInodeError)
 		return
@@ -307,7 +323,7 @@ func (vS *volumeStruct) fetchOnDiskInode(inodeNumber InodeNumber) (inMemoryInode
 					vS.maxEntriesPerDirNode,
 					sortedmap.CompareString,
 					&dirInodeCallbacks{treeNodeLoadable{inode: inMemoryInode}},
-					globals.dirEntryCache)
+					vS.dirEntryCache.Cache())
 		} else {
 			inMemoryInode.payload, err =
 				sortedmap.OldBPlusTree(
@@ -316,13 +332,19 @@ func (vS *volumeStruct) fetchOnDiskInode(inodeNumber InodeNumber) (inMemoryInode
 					inMemoryInode.PayloadObjectLength,
 					sortedmap.CompareString,
 					&dirInodeCallbacks{treeNodeLoadable{inode: inMemoryInode}},
-					globals.dirEntryCache)
+					vS.dirEntryCache.Cache())
 			if nil != err {
 				err = fmt.Errorf("%s: sortedmap.OldBPlusTree(inodeRec.<body>.PayloadObjectNumber) for DirType inode %d failed: %v", utils.GetFnName(), inodeNumber, err)
 				err = blunder.AddError(err, blunder.CorruptInodeError)
 				return
 			}
 		}
+		err = vS.loadCaseFoldIndex(inMemoryInode)
+		if nil != err {
+			err = fmt.Errorf("%s: sortedmap.OldBPlusTree(inodeRec.<body>.CaseFoldObjectNumber) for DirType inode %d failed: %v", utils.GetFnName(), inodeNumber, err)
+			err = blunder.AddError(err, blunder.CorruptInodeError)
+			return
+		}
 	case FileType:
 		if 0 == inMemoryInode.PayloadObjectNumber {
 			inMemoryInode.payload =
@@ -330,7 +352,7 @@ func (vS *volumeStruct) fetchOnDiskInode(inodeNumber InodeNumber) (inMemoryInode
 					vS.maxExtentsPerFileNode,
 					sortedmap.CompareUint64,
 					&fileInodeCallbacks{treeNodeLoadable{inode: inMemoryInode}},
-					globals.fileExtentMapCache)
+					vS.fileExtentMapCache.Cache())
 		} else {
 			inMemoryInode.payload, err =
 				sortedmap.OldBPlusTree(
@@ -339,7 +361,7 @@ func (vS *volumeStruct) fetchOnDiskInode(inodeNumber InodeNumber) (inMemoryInode
 					inMemoryInode.PayloadObjectLength,
 					sortedmap.CompareUint64,
 					&fileInodeCallbacks{treeNodeLoadable{inode: inMemoryInode}},
-					globals.fileExtentMapCache)
+					vS.fileExtentMapCache.Cache())
 			if nil != err {
 				err = fmt.Errorf("%s: sortedmap.OldBPlusTree(inodeRec.<body>.PayloadObjectNumber) for FileType inode %d failed: %v", utils.GetFnName(), inodeNumber, err)
 				err = blunder.AddError(err, blunder.CorruptInodeError)
@@ -793,7 +815,7 @@ func (vS *volumeStruct) PatchInode(inodeNumber InodeNumber, inodeType InodeType,
 			vS.maxEntriesPerDirNode,
 			sortedmap.CompareString,
 			&dirInodeCallbacks{treeNodeLoadable{inode: inode}},
-			globals.dirEntryCache)
+			vS.dirEntryCache.Cache())
 
 		ok, err = payload.Put(".", inodeNumberDecodedAsInodeNumber)
 		if nil != err {
@@ -822,7 +844,7 @@ func (vS *volumeStruct) PatchInode(inodeNumber InodeNumber, inodeType InodeType,
 			vS.maxExtentsPerFileNode,
 			sortedmap.CompareUint64,
 			&fileInodeCallbacks{treeNodeLoadable{inode: inode}},
-			globals.fileExtentMapCache)
+			vS.fileExtentMapCache.Cache())
 
 		inode.payload = payload
 		inode.onDiskInodeV1Struct.SymlinkTarget = ""
@@ -889,6 +911,11 @@ func (inMemoryInode *inMemoryInodeStruct) convertToOnDiskInodeV1() (onDiskInodeV
 		onDiskInode.LogSegmentMap[logSegmentNumber] = logSegmentBytesUsed
 	}
 
+	if 0 < len(inMemoryInode.InlineData) {
+		onDiskInode.InlineData = make([]byte, len(inMemoryInode.InlineData))
+		copy(onDiskInode.InlineData, inMemoryInode.InlineData)
+	}
+
 	return &onDiskInode, nil
 }
 
@@ -991,6 +1018,15 @@ func (vS *volumeStruct) flushInodes(inodes []*inMemoryInodeStruct) (err error) {
 				evtlog.Record(evtlog.FormatFlushInodesDirOrFilePayloadObjectNumberUpdated, vS.volumeName, uint64(inode.InodeNumber), payloadObjectNumber)
 			}
 		}
+		if DirType == inode.InodeType {
+			err = flushCaseFoldIndex(inode)
+			if nil != err {
+				evtlog.Record(evtlog.FormatFlushInodesErrorOnInode, vS.volumeName, uint64(inode.InodeNumber), err.Error())
+				logger.ErrorWithError(err)
+				err = blunder.AddError(err, blunder.InodeFlushError)
+				return
+			}
+		}
 		if inode.dirty {
 			onDiskInodeV1, err = inode.convertToOnDiskInodeV1()
 			if nil != err {
@@ -1006,8 +1042,13 @@ func (vS *volumeStruct) flushInodes(inodes []*inMemoryInodeStruct) (err error) {
 				err = blunder.AddError(err, blunder.InodeFlushError)
 				return
 			}
-			dirtyInodeRecBytes = make([]byte, 0, len(globals.inodeRecDefaultPreambleBuf)+len(onDiskInodeV1Buf))
-			dirtyInodeRecBytes = append(dirtyInodeRecBytes, globals.inodeRecDefaultPreambleBuf...)
+			if 0 == len(onDiskInodeV1.InlineData) {
+				dirtyInodeRecBytes = make([]byte, 0, len(globals.inodeRecDefaultPreambleBuf)+len(onDiskInodeV1Buf))
+				dirtyInodeRecBytes = append(dirtyInodeRecBytes, globals.inodeRecDefaultPreambleBuf...)
+			} else {
+				dirtyInodeRecBytes = make([]byte, 0, len(globals.inodeRecInlineDataPreambleBuf)+len(onDiskInodeV1Buf))
+				dirtyInodeRecBytes = append(dirtyInodeRecBytes, globals.inodeRecInlineDataPreambleBuf...)
+			}
 			dirtyInodeRecBytes = append(dirtyInodeRecBytes, onDiskInodeV1Buf...)
 			dirtyInodeNumbers = append(dirtyInodeNumbers, uint64(inode.InodeNumber))
 			dirtyInodeRecs = append(dirtyInodeRecs, dirtyInodeRecBytes)
@@ -1031,7 +1072,7 @@ func (vS *volumeStruct) flushInodes(inodes []*inMemoryInodeStruct) (err error) {
 	// Now do phase one of garbage collection
 	if 0 < len(emptyLogSegments) {
 		for _, logSegmentNumber = range emptyLogSegments {
-			err = vS.headhunterVolumeHandle.DeleteLogSegmentRec(logSegmentNumber)
+			err = vS.headhunterVolumeHandle.UnreferenceLogSegmentRec(logSegmentNumber)
 			if nil != err {
 				logger.WarnfWithError(err, "couldn't delete garbage log segment")
 			}
@@ -1187,7 +1228,7 @@ func (vS *volumeStruct) provisionPhysicalContainer(physicalContainerLayout *phys
 
 		newContainerName := fmt.Sprintf("%s%s", physicalContainerLayout.containerNamePrefix, utils.Uint64ToHexStr(physicalContainerNameSuffix))
 
-		storagePolicyHeaderValues := []string{vS.defaultPhysicalContainerLayout.containerStoragePolicy}
+		storagePolicyHeaderValues := []string{physicalContainerLayout.containerStoragePolicy}
 		newContainerHeaders := make(map[string][]string)
 		newContainerHeaders["X-Storage-Policy"] = storagePolicyHeaderValues
 
@@ -1203,24 +1244,24 @@ func (vS *volumeStruct) provisionPhysicalContainer(physicalContainerLayout *phys
 	return
 }
 
-func (vS *volumeStruct) provisionObject() (containerName string, objectNumber uint64, err error) {
+func (vS *volumeStruct) provisionObject(physicalContainerLayout *physicalContainerLayoutStruct) (containerName string, objectNumber uint64, err error) {
 	objectNumber = vS.headhunterVolumeHandle.FetchNonce()
 
 	vS.Lock()
 
-	err = vS.provisionPhysicalContainer(vS.defaultPhysicalContainerLayout)
+	err = vS.provisionPhysicalContainer(physicalContainerLayout)
 	if nil != err {
 		vS.Unlock()
 		return
 	}
 
-	containerName = vS.defaultPhysicalContainerLayout.containerNameSlice[vS.defaultPhysicalContainerLayout.containerNameSliceNextIndex]
+	containerName = physicalContainerLayout.containerNameSlice[physicalContainerLayout.containerNameSliceNextIndex]
 
-	vS.defaultPhysicalContainerLayout.containerNameSliceNextIndex++
+	physicalContainerLayout.containerNameSliceNextIndex++
 
-	if vS.defaultPhysicalContainerLayout.containerNameSliceNextIndex == vS.defaultPhysicalContainerLayout.containersPerPeer {
-		vS.defaultPhysicalContainerLayout.containerNameSliceNextIndex = 0
-		vS.defaultPhysicalContainerLayout.containerNameSliceLoopCount++
+	if physicalContainerLayout.containerNameSliceNextIndex == physicalContainerLayout.containersPerPeer {
+		physicalContainerLayout.containerNameSliceNextIndex = 0
+		physicalContainerLayout.containerNameSliceLoopCount++
 	}
 
 	vS.Unlock()
@@ -1380,12 +1421,12 @@ func (vS *volumeStruct) Access(inodeNumber InodeNumber, userID InodeUserID, grou
 }
 
 func (vS *volumeStruct) ProvisionObject() (objectPath string, err error) {
-	err = enforceRWMode(true)
+	err = vS.enforceRWMode(true)
 	if nil != err {
 		return
 	}
 
-	containerName, objectNumber, err := vS.provisionObject()
+	containerName, objectNumber, err := vS.provisionObject(vS.defaultPhysicalContainerLayout)
 	if nil != err {
 		return
 	}
@@ -1402,7 +1443,7 @@ func (vS *volumeStruct) Purge(inodeNumber InodeNumber) (err error) {
 		ok    bool
 	)
 
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1431,7 +1472,7 @@ func (vS *volumeStruct) Purge(inodeNumber InodeNumber) (err error) {
 func (vS *volumeStruct) Destroy(inodeNumber InodeNumber) (err error) {
 	logger.Tracef("inode.Destroy(): volume '%s' inode %d", vS.volumeName, inodeNumber)
 
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1491,6 +1532,12 @@ func (vS *volumeStruct) Destroy(inodeNumber InodeNumber) (err error) {
 			return
 		}
 
+		err = discardCaseFoldIndex(ourInode)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+
 		stats.IncrementOperations(&stats.DirDestroyOps)
 
 	} else if FileType == ourInode.InodeType {
@@ -1506,7 +1553,7 @@ func (vS *volumeStruct) Destroy(inodeNumber InodeNumber) (err error) {
 		}
 
 		for logSegmentNumber := range ourInode.LogSegmentMap {
-			deleteSegmentErr := vS.headhunterVolumeHandle.DeleteLogSegmentRec(logSegmentNumber)
+			deleteSegmentErr := vS.headhunterVolumeHandle.UnreferenceLogSegmentRec(logSegmentNumber)
 			if nil != deleteSegmentErr {
 				logger.WarnfWithError(deleteSegmentErr, "couldn't delete destroy'd log segment")
 				return
@@ -1670,7 +1717,7 @@ func (vS *volumeStruct) GetLinkCount(inodeNumber InodeNumber) (linkCount uint64,
 
 // SetLinkCount is used to adjust the LinkCount property to match current reference count during FSCK TreeWalk.
 func (vS *volumeStruct) SetLinkCount(inodeNumber InodeNumber, linkCount uint64) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1710,7 +1757,7 @@ func (vS *volumeStruct) SetLinkCount(inodeNumber InodeNumber, linkCount uint64)
 }
 
 func (vS *volumeStruct) SetCreationTime(inodeNumber InodeNumber, CreationTime time.Time) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1750,7 +1797,7 @@ func (vS *volumeStruct) SetCreationTime(inodeNumber InodeNumber, CreationTime ti
 }
 
 func (vS *volumeStruct) SetModificationTime(inodeNumber InodeNumber, ModificationTime time.Time) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1791,7 +1838,7 @@ func (vS *volumeStruct) SetModificationTime(inodeNumber InodeNumber, Modificatio
 }
 
 func (vS *volumeStruct) SetAccessTime(inodeNumber InodeNumber, accessTime time.Time) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1861,7 +1908,7 @@ func determineMode(filePerm InodeMode, inodeType InodeType) (fileMode InodeMode,
 }
 
 func (vS *volumeStruct) SetPermMode(inodeNumber InodeNumber, filePerm InodeMode) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1910,7 +1957,7 @@ func (vS *volumeStruct) SetPermMode(inodeNumber InodeNumber, filePerm InodeMode)
 }
 
 func (vS *volumeStruct) SetOwnerUserID(inodeNumber InodeNumber, userID InodeUserID) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1953,7 +2000,7 @@ func (vS *volumeStruct) SetOwnerUserID(inodeNumber InodeNumber, userID InodeUser
 }
 
 func (vS *volumeStruct) SetOwnerUserIDGroupID(inodeNumber InodeNumber, userID InodeUserID, groupID InodeGroupID) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -1997,7 +2044,7 @@ func (vS *volumeStruct) SetOwnerUserIDGroupID(inodeNumber InodeNumber, userID In
 }
 
 func (vS *volumeStruct) SetOwnerGroupID(inodeNumber InodeNumber, groupID InodeGroupID) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -2079,7 +2126,7 @@ func (vS *volumeStruct) GetStream(inodeNumber InodeNumber, inodeStreamName strin
 }
 
 func (vS *volumeStruct) PutStream(inodeNumber InodeNumber, inodeStreamName string, buf []byte) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -2106,6 +2153,18 @@ func (vS *volumeStruct) PutStream(inodeNumber InodeNumber, inodeStreamName strin
 		return err
 	}
 
+	if PhysicalContainerLayoutStreamName == inodeStreamName {
+		err = vS.validatePhysicalContainerLayoutStream(buf)
+		if nil != err {
+			return err
+		}
+	}
+
+	err = vS.validateWORMStream(inode, inodeStreamName, buf)
+	if nil != err {
+		return err
+	}
+
 	inodeStreamBuf := make([]byte, len(buf))
 
 	copy(inodeStreamBuf, buf)
@@ -2113,6 +2172,14 @@ func (vS *volumeStruct) PutStream(inodeNumber InodeNumber, inodeStreamName strin
 	inode.dirty = true
 	inode.StreamMap[inodeStreamName] = inodeStreamBuf
 
+	if (CaseInsensitiveStreamName == inodeStreamName) && (DirType == inode.InodeType) && !vS.caseInsensitiveDir(inode) {
+		err = discardCaseFoldIndex(inode)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return err
+		}
+	}
+
 	updateTime := time.Now()
 	inode.AttrChangeTime = updateTime
 
@@ -2126,7 +2193,7 @@ func (vS *volumeStruct) PutStream(inodeNumber InodeNumber, inodeStreamName strin
 }
 
 func (vS *volumeStruct) DeleteStream(inodeNumber InodeNumber, inodeStreamName string) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -2153,9 +2220,22 @@ func (vS *volumeStruct) DeleteStream(inodeNumber InodeNumber, inodeStreamName st
 		return
 	}
 
+	err = vS.validateWORMStreamDeletion(inode, inodeStreamName)
+	if nil != err {
+		return
+	}
+
 	inode.dirty = true
 	delete(inode.StreamMap, inodeStreamName)
 
+	if (CaseInsensitiveStreamName == inodeStreamName) && (DirType == inode.InodeType) && !vS.caseInsensitiveDir(inode) {
+		err = discardCaseFoldIndex(inode)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return err
+		}
+	}
+
 	updateTime := time.Now()
 	inode.AttrChangeTime = updateTime
 
@@ -2197,18 +2277,177 @@ func (vS *volumeStruct) FetchLayoutReport(inodeNumber InodeNumber) (layoutReport
 		err = nil
 	} else {
 		layoutReport, err = inode.payload.(sortedmap.BPlusTree).FetchLayoutReport()
+		if (nil == err) && (DirType == inode.InodeType) {
+			// Include the nodes of the DirInode's caseFoldIndex (which are disjoint from those above)
+
+			caseFoldIndexLayoutReport, caseFoldErr := fetchCaseFoldIndexLayoutReport(inode)
+			if nil != caseFoldErr {
+				err = caseFoldErr
+				return
+			}
+			for objectNumber, objectBytes := range caseFoldIndexLayoutReport {
+				layoutReport[objectNumber] += objectBytes
+			}
+		}
 	}
 
 	return
 }
 
 func (vS *volumeStruct) FetchFragmentationReport(inodeNumber InodeNumber) (fragmentationReport FragmentationReport, err error) {
-	err = fmt.Errorf("FetchFragmentationReport not yet implemented")
+	var (
+		extentAsValue       sortedmap.Value
+		extentIndex         int
+		extents             sortedmap.BPlusTree
+		fileExtent          *fileExtentStruct
+		fileInode           *inMemoryInodeStruct
+		inFlightHit         bool
+		logSegmentBytesUsed uint64
+		logSegmentLength    uint64
+		logSegmentNumber    uint64
+		ok                  bool
+		sharedLogSegments   map[uint64]struct{}
+		snapShotIDType      headhunter.SnapShotIDType
+	)
+
+	snapShotIDType, _, _ = vS.headhunterVolumeHandle.SnapShotU64Decode(uint64(inodeNumber))
+	if headhunter.SnapShotIDTypeDotSnapShot == snapShotIDType {
+		err = nil
+		return
+	}
+
+	fileInode, ok, err = vS.fetchInode(inodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "%s: fetch of inode failed", utils.GetFnName())
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because it is unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	if FileType != fileInode.InodeType {
+		// Only FileInodes reference LogSegments and hence can be fragmented
+		err = nil
+		return
+	}
+
+	// LogSegments shared with other FileInodes (e.g. via dedup) won't be rewritten by DefragmentFile()
+	// so are not reported
+
+	sharedLogSegments, err = vS.fetchSharedLogSegments(fileInode)
+	if nil != err {
+		return
+	}
+
+	extents = fileInode.payload.(sortedmap.BPlusTree)
+
+	for extentIndex = 0; ; extentIndex++ {
+		_, extentAsValue, ok, err = extents.GetByIndex(extentIndex)
+		if nil != err {
+			return
+		}
+		if !ok {
+			break
+		}
+
+		fileExtent = extentAsValue.(*fileExtentStruct)
+
+		_, ok = sharedLogSegments[fileExtent.LogSegmentNumber]
+		if ok {
+			continue
+		}
+
+		fragmentationReport.NumberOfFragments++
+		fragmentationReport.BytesInFragments += fileExtent.Length
+	}
+
+	for logSegmentNumber, logSegmentBytesUsed = range fileInode.LogSegmentMap {
+		fileInode.Lock()
+		_, inFlightHit = fileInode.inFlightLogSegmentMap[logSegmentNumber]
+		fileInode.Unlock()
+
+		if inFlightHit {
+			// The ultimate size of an inFlightLogSegment is not yet known
+			continue
+		}
+
+		_, ok = sharedLogSegments[logSegmentNumber]
+		if ok {
+			// Bytes unreferenced by fileInode may yet be referenced by other FileInodes
+			continue
+		}
+
+		logSegmentLength, err = vS.fetchLogSegmentLength(logSegmentNumber)
+		if nil != err {
+			return
+		}
+
+		if logSegmentLength > logSegmentBytesUsed {
+			fragmentationReport.BytesTrapped += logSegmentLength - logSegmentBytesUsed
+		}
+	}
+
+	err = nil
+	return
+}
+
+// recordLogSegmentLength remembers the length of a closed (and hence immutable) LogSegment so that
+// FetchFragmentationReport() need not HEAD it. Once logSegmentLengthCacheMaxEntries are remembered,
+// an arbitrary one is forgotten to make room.
+func (vS *volumeStruct) recordLogSegmentLength(logSegmentNumber uint64, logSegmentLength uint64) {
+	vS.logSegmentLengthCacheLock.Lock()
+
+	if uint64(len(vS.logSegmentLengthCache)) >= logSegmentLengthCacheMaxEntries {
+		for evictLogSegmentNumber := range vS.logSegmentLengthCache {
+			delete(vS.logSegmentLengthCache, evictLogSegmentNumber)
+			break
+		}
+	}
+
+	vS.logSegmentLengthCache[logSegmentNumber] = logSegmentLength
+
+	vS.logSegmentLengthCacheLock.Unlock()
+}
+
+// fetchLogSegmentLength returns the length of a closed LogSegment, only resorting to a HEAD of
+// the LogSegment if it isn't already known.
+func (vS *volumeStruct) fetchLogSegmentLength(logSegmentNumber uint64) (logSegmentLength uint64, err error) {
+	var (
+		accountName   string
+		containerName string
+		objectName    string
+		ok            bool
+	)
+
+	vS.logSegmentLengthCacheLock.Lock()
+	logSegmentLength, ok = vS.logSegmentLengthCache[logSegmentNumber]
+	vS.logSegmentLengthCacheLock.Unlock()
+
+	if ok {
+		err = nil
+		return
+	}
+
+	accountName, containerName, objectName, _, err = vS.getObjectLocationFromLogSegmentNumber(logSegmentNumber)
+	if nil != err {
+		return
+	}
+
+	logSegmentLength, err = swiftclient.ObjectContentLength(accountName, containerName, objectName)
+	if nil != err {
+		return
+	}
+
+	vS.recordLogSegmentLength(logSegmentNumber, logSegmentLength)
+
 	return
 }
 
 func (vS *volumeStruct) Optimize(inodeNumber InodeNumber, maxDuration time.Duration) (err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -2286,6 +2525,9 @@ func validateFileExtents(snapShotID uint64, ourInode *inMemoryInodeStruct) (err
 			continue
 		}
 		stepEndOffset := planStep.Offset + planStep.Length
+		if 0 != planStep.CompressedLength {
+			stepEndOffset = planStep.Offset + planStep.CompressedLength
+		}
 		endOffset, ok := objectPathToEndOffset[planStep.ObjectPath]
 		if !ok || stepEndOffset > endOffset {
 			objectPathToEndOffset[planStep.ObjectPath] = stepEndOffset
@@ -23,6 +23,24 @@ import (
 // have a well-defined serialization/deserialization), which is why our unpack
 // methods have so many panic codepaths (that we expect to never run).
 
+// On disk, a fileExtentStruct is serialized as an onDiskFileExtentStruct. If the extent's data resides
+// in a compressed frame, onDiskFileExtentCompressedFlag is set in Length and an onDiskFileExtentCompressionStruct
+// immediately follows. Extents written prior to support for compression are thus still understood.
+
+const onDiskFileExtentCompressedFlag = uint64(1) << 63
+
+type onDiskFileExtentStruct struct {
+	FileOffset       uint64
+	Length           uint64
+	LogSegmentNumber uint64
+	LogSegmentOffset uint64
+}
+
+type onDiskFileExtentCompressionStruct struct {
+	CompressedLength uint64
+	FrameOffset      uint64
+}
+
 type treeNodeLoadable struct {
 	inode *inMemoryInodeStruct
 }
@@ -51,6 +69,10 @@ func (tnl *treeNodeLoadable) GetNode(objectNumber uint64, objectOffset uint64, o
 		return
 	}
 
+	if nil == err {
+		tnl.observeNodeSize(objectLength)
+	}
+
 	return
 }
 
@@ -61,9 +83,22 @@ func (tnl *treeNodeLoadable) PutNode(nodeByteSlice []byte) (objectNumber uint64,
 
 	err = tnl.inode.volume.headhunterVolumeHandle.PutBPlusTreeObject(objectNumber, nodeByteSlice)
 
+	if nil == err {
+		tnl.observeNodeSize(uint64(len(nodeByteSlice)))
+	}
+
 	return
 }
 
+func (tnl *treeNodeLoadable) observeNodeSize(nodeSize uint64) {
+	switch tnl.inode.InodeType {
+	case DirType:
+		tnl.inode.volume.dirEntryCache.NodeSizeObserved(nodeSize)
+	case FileType:
+		tnl.inode.volume.fileExtentMapCache.NodeSizeObserved(nodeSize)
+	}
+}
+
 func (tnl *treeNodeLoadable) DiscardNode(objectNumber uint64, objectOffset uint64, objectLength uint64) (err error) {
 	logger.Tracef("inode.Discardnode(): volume '%s' inode %d: "+
 		"root Object %016X  discarding Object %016X  len %d",
@@ -119,17 +154,44 @@ func (c *fileInodeCallbacks) PackKey(key sortedmap.Key) (packedKey []byte, err e
 }
 
 func (c *fileInodeCallbacks) PackValue(value sortedmap.Value) (packedValue []byte, err error) {
+	var (
+		onDiskFileExtent            onDiskFileExtentStruct
+		onDiskFileExtentCompression onDiskFileExtentCompressionStruct
+		packedCompression           []byte
+	)
+
 	fileExtent, ok := value.(*fileExtentStruct)
 	if !ok {
 		err = fmt.Errorf("PackValue() arg is not a *fileExtentStruct")
 		return
 	}
-	packedValue, err = cstruct.Pack(fileExtent, sortedmap.OnDiskByteOrder)
+	onDiskFileExtent = onDiskFileExtentStruct{
+		FileOffset:       fileExtent.FileOffset,
+		Length:           fileExtent.Length,
+		LogSegmentNumber: fileExtent.LogSegmentNumber,
+		LogSegmentOffset: fileExtent.LogSegmentOffset,
+	}
+	if 0 != fileExtent.CompressedLength {
+		onDiskFileExtent.Length |= onDiskFileExtentCompressedFlag
+	}
+	packedValue, err = cstruct.Pack(onDiskFileExtent, sortedmap.OnDiskByteOrder)
 	if nil != err {
 		return
 	}
 	if uint64(len(packedValue)) != globals.fileExtentStructSize {
 		err = fmt.Errorf("PackValue() should have produced len(packedValue) == %v", globals.fileExtentStructSize)
+		return
+	}
+	if 0 != fileExtent.CompressedLength {
+		onDiskFileExtentCompression = onDiskFileExtentCompressionStruct{
+			CompressedLength: fileExtent.CompressedLength,
+			FrameOffset:      fileExtent.FrameOffset,
+		}
+		packedCompression, err = cstruct.Pack(onDiskFileExtentCompression, sortedmap.OnDiskByteOrder)
+		if nil != err {
+			return
+		}
+		packedValue = append(packedValue, packedCompression...)
 	}
 	return
 }
@@ -145,17 +207,40 @@ func (c *fileInodeCallbacks) UnpackKey(payloadData []byte) (key sortedmap.Key, b
 }
 
 func (c *fileInodeCallbacks) UnpackValue(payloadData []byte) (value sortedmap.Value, bytesConsumed uint64, err error) {
+	var (
+		onDiskFileExtent            onDiskFileExtentStruct
+		onDiskFileExtentCompression onDiskFileExtentCompressionStruct
+	)
+
 	if uint64(len(payloadData)) < globals.fileExtentStructSize {
 		err = fmt.Errorf("UnpackValue() arg not big enough to encode fileExtentStruct")
 		return
 	}
-	valueAsFileExtentPtr := &fileExtentStruct{}
-	_, err = cstruct.Unpack(payloadData, valueAsFileExtentPtr, sortedmap.OnDiskByteOrder)
+	_, err = cstruct.Unpack(payloadData, &onDiskFileExtent, sortedmap.OnDiskByteOrder)
 	if nil != err {
 		return
 	}
-	value = valueAsFileExtentPtr
+	valueAsFileExtentPtr := &fileExtentStruct{
+		FileOffset:       onDiskFileExtent.FileOffset,
+		Length:           onDiskFileExtent.Length &^ onDiskFileExtentCompressedFlag,
+		LogSegmentNumber: onDiskFileExtent.LogSegmentNumber,
+		LogSegmentOffset: onDiskFileExtent.LogSegmentOffset,
+	}
 	bytesConsumed = globals.fileExtentStructSize
+	if 0 != (onDiskFileExtent.Length & onDiskFileExtentCompressedFlag) {
+		if uint64(len(payloadData)) < (globals.fileExtentStructSize + globals.fileExtentCompressionStructSize) {
+			err = fmt.Errorf("UnpackValue() arg not big enough to encode compressed fileExtentStruct")
+			return
+		}
+		_, err = cstruct.Unpack(payloadData[globals.fileExtentStructSize:], &onDiskFileExtentCompression, sortedmap.OnDiskByteOrder)
+		if nil != err {
+			return
+		}
+		valueAsFileExtentPtr.CompressedLength = onDiskFileExtentCompression.CompressedLength
+		valueAsFileExtentPtr.FrameOffset = onDiskFileExtentCompression.FrameOffset
+		bytesConsumed += globals.fileExtentCompressionStructSize
+	}
+	value = valueAsFileExtentPtr
 	err = nil
 	return
 }
@@ -232,3 +317,43 @@ func (c *dirInodeCallbacks) UnpackValue(payloadData []byte) (value sortedmap.Val
 	bytesConsumed = 8
 	return
 }
+
+// For DirType inodes, the caseFoldIndex tree-map is from caseFold(basename) + "/" + basename to basename.
+
+type caseFoldIndexCallbacks struct {
+	dirInodeCallbacks
+}
+
+func (c *caseFoldIndexCallbacks) DumpValue(value sortedmap.Value) (valueAsString string, err error) {
+	valueAsString, ok := value.(string)
+
+	if ok {
+		err = nil
+	} else {
+		err = fmt.Errorf("caseFoldIndexCallbacks.DumpValue() could not parse value as a string")
+	}
+
+	return
+}
+
+func (c *caseFoldIndexCallbacks) PackValue(value sortedmap.Value) (packedValue []byte, err error) {
+	basename, ok := value.(string)
+	if !ok {
+		err = fmt.Errorf("PackValue() arg not a string")
+		return
+	}
+	packedValue = []byte(basename)
+	packedValue = append(packedValue, 0) // null terminator
+	err = nil
+	return
+}
+
+func (c *caseFoldIndexCallbacks) UnpackValue(payloadData []byte) (value sortedmap.Value, bytesConsumed uint64, err error) {
+	basenameAndRemainderBytes := bytes.SplitN(payloadData, []byte{0}, 2)
+	basenameBytes := basenameAndRemainderBytes[0]
+	basename := string(basenameBytes)
+	value = basename
+	bytesConsumed = uint64(len(basenameBytes) + 1)
+	err = nil
+	return
+}
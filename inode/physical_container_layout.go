@@ -0,0 +1,157 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/logger"
+	"github.com/NVIDIA/proxyfs/utils"
+)
+
+// LogSegments of a FileInode are placed in the PhysicalContainers of the PhysicalContainerLayout named
+// by the FileInode's PhysicalContainerLayoutStreamName Stream or, lacking that, the volume's
+// DefaultPhysicalContainerLayout. Only those PhysicalContainerLayouts listed in the volume's
+// PhysicalContainerLayoutList (which always includes the DefaultPhysicalContainerLayout) may be named.
+
+// fetchPhysicalContainerLayout returns the PhysicalContainerLayout described by the
+// [PhysicalContainerLayout:<physicalContainerLayoutName>] section of confMap.
+func fetchPhysicalContainerLayout(confMap conf.ConfMap, physicalContainerLayoutName string) (physicalContainerLayout *physicalContainerLayoutStruct, err error) {
+	var (
+		physicalContainerLayoutSectionName string
+	)
+
+	physicalContainerLayout = &physicalContainerLayoutStruct{
+		name:                        physicalContainerLayoutName,
+		containerNameSliceNextIndex: 0,
+		containerNameSliceLoopCount: 0,
+	}
+
+	physicalContainerLayoutSectionName = "PhysicalContainerLayout:" + physicalContainerLayoutName
+
+	physicalContainerLayout.containerStoragePolicy, err = confMap.FetchOptionValueString(physicalContainerLayoutSectionName, "ContainerStoragePolicy")
+	if nil != err {
+		return
+	}
+	physicalContainerLayout.containerNamePrefix, err = confMap.FetchOptionValueString(physicalContainerLayoutSectionName, "ContainerNamePrefix")
+	if nil != err {
+		return
+	}
+	physicalContainerLayout.containersPerPeer, err = confMap.FetchOptionValueUint64(physicalContainerLayoutSectionName, "ContainersPerPeer")
+	if nil != err {
+		return
+	}
+	physicalContainerLayout.maxObjectsPerContainer, err = confMap.FetchOptionValueUint64(physicalContainerLayoutSectionName, "MaxObjectsPerContainer")
+	if nil != err {
+		return
+	}
+
+	physicalContainerLayout.containerNameSlice = make([]string, physicalContainerLayout.containersPerPeer)
+
+	err = nil
+	return
+}
+
+// physicalContainerLayoutForFileInode returns the PhysicalContainerLayout in which LogSegments of
+// fileInode should be placed.
+func (vS *volumeStruct) physicalContainerLayoutForFileInode(fileInode *inMemoryInodeStruct) (physicalContainerLayout *physicalContainerLayoutStruct) {
+	var (
+		ok          bool
+		streamValue []byte
+	)
+
+	streamValue, ok = fileInode.StreamMap[PhysicalContainerLayoutStreamName]
+	if ok {
+		physicalContainerLayout, ok = vS.physicalContainerLayoutMap[string(streamValue)]
+		if ok {
+			return
+		}
+
+		logger.Warnf("Inode 0x%016X names unknown PhysicalContainerLayout \"%s\"... using DefaultPhysicalContainerLayout", fileInode.InodeNumber, string(streamValue))
+	}
+
+	physicalContainerLayout = vS.defaultPhysicalContainerLayout
+	return
+}
+
+// validatePhysicalContainerLayoutStream returns an error if buf does not name a PhysicalContainerLayout
+// available to the volume.
+func (vS *volumeStruct) validatePhysicalContainerLayoutStream(buf []byte) (err error) {
+	var (
+		ok bool
+	)
+
+	_, ok = vS.physicalContainerLayoutMap[string(buf)]
+	if !ok {
+		err = fmt.Errorf("PhysicalContainerLayout \"%s\" not available to volume '%s'", string(buf), vS.volumeName)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	err = nil
+	return
+}
+
+// containsContainer returns whether or not containerName names a PhysicalContainer provisioned for
+// physicalContainerLayout (i.e. its ContainerNamePrefix followed by a 16 hex digit suffix). Merely
+// starting with ContainerNamePrefix is insufficient as one layout's prefix may begin another's.
+func (physicalContainerLayout *physicalContainerLayoutStruct) containsContainer(containerName string) (contains bool) {
+	var (
+		err    error
+		suffix string
+	)
+
+	if !strings.HasPrefix(containerName, physicalContainerLayout.containerNamePrefix) {
+		contains = false
+		return
+	}
+
+	suffix = containerName[len(physicalContainerLayout.containerNamePrefix):]
+
+	if len(utils.Uint64ToHexStr(0)) != len(suffix) {
+		contains = false
+		return
+	}
+
+	_, err = utils.HexStrToUint64(suffix)
+
+	contains = (nil == err)
+	return
+}
+
+func (vS *volumeStruct) FileInPhysicalContainerLayout(fileInodeNumber InodeNumber) (inLayout bool, err error) {
+	var (
+		containerName           string
+		fileInode               *inMemoryInodeStruct
+		logSegmentNumber        uint64
+		physicalContainerLayout *physicalContainerLayoutStruct
+	)
+
+	fileInode, err = vS.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		return
+	}
+
+	physicalContainerLayout = vS.physicalContainerLayoutForFileInode(fileInode)
+
+	for logSegmentNumber = range fileInode.LogSegmentMap {
+		containerName, err = vS.getLogSegmentContainer(logSegmentNumber)
+		if nil != err {
+			return
+		}
+
+		if !physicalContainerLayout.containsContainer(containerName) {
+			inLayout = false
+			err = nil
+			return
+		}
+	}
+
+	inLayout = true
+	err = nil
+	return
+}
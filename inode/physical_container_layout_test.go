@@ -0,0 +1,27 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"testing"
+)
+
+func TestPhysicalContainerLayoutContainsContainer(t *testing.T) {
+	physicalContainerLayout := &physicalContainerLayoutStruct{containerNamePrefix: "Cold_"}
+
+	for containerName, expectedContains := range map[string]bool{
+		"Cold_0000000000000001":         true,
+		"Cold_00000000DEADBEEF":         true,
+		"Cold_Archive_0000000000000001": false, // container of a layout whose ContainerNamePrefix begins with "Cold_"
+		"Cold_":                         false,
+		"Cold_0001":                     false,
+		"Cold_000000000000000G":         false,
+		"Cold_00000000000000001":        false,
+		"Hot_0000000000000001":          false,
+	} {
+		if expectedContains != physicalContainerLayout.containsContainer(containerName) {
+			t.Fatalf("containsContainer(\"%s\") should have returned %v", containerName, expectedContains)
+		}
+	}
+}
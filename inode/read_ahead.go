@@ -0,0 +1,212 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"time"
+
+	"github.com/NVIDIA/proxyfs/logger"
+	"github.com/NVIDIA/proxyfs/swiftclient"
+)
+
+// readAheadMaxWindowWhileLocked returns the largest read-ahead window (in bytes) permitted. As prefetched
+// cache lines compete with on-demand ones, the window is limited to a quarter of the ReadCache lines
+// allotted to the volumeGroup (by its ReadCacheWeight).
+func (volumeGroup *volumeGroupStruct) readAheadMaxWindowWhileLocked() (maxWindow uint64) {
+	var (
+		maxCacheLines uint64
+	)
+
+	maxCacheLines = volumeGroup.readCacheLineCount / 4
+	if maxCacheLines > volumeGroup.readAheadMaxCacheLines {
+		maxCacheLines = volumeGroup.readAheadMaxCacheLines
+	}
+
+	maxWindow = maxCacheLines * volumeGroup.readCacheLineSize
+
+	return
+}
+
+// readAhead is called following a successful Read() of [offset:offset+length) from fileInode. If this
+// continues a sequential access pattern, the read-ahead window is grown (up to the volumeGroup's limit)
+// and any cache lines in the window not already present in (or being fetched into) the ReadCache are
+// fetched in parallel in the background (limited in number to the volumeGroup's maximum window).
+func (vS *volumeStruct) readAhead(fileInode *inMemoryInodeStruct, snapShotID uint64, offset uint64, length uint64) {
+	var (
+		cacheLineTag      uint64
+		cacheLineTagFinal uint64
+		err               error
+		inFlightHit       bool
+		maxWindow         uint64
+		ok                bool
+		readAheadOffset   uint64
+		readAheadLength   uint64
+		readCacheKey      readCacheKeyStruct
+		readPlan          []ReadPlanStep
+		step              ReadPlanStep
+		volumeGroup       *volumeGroupStruct
+	)
+
+	if 0 == length {
+		return
+	}
+
+	volumeGroup = vS.volumeGroup
+
+	volumeGroup.Lock()
+	maxWindow = volumeGroup.readAheadMaxWindowWhileLocked()
+	volumeGroup.Unlock()
+
+	fileInode.Lock()
+
+	if (0 == maxWindow) || (offset != fileInode.readAheadNextOffset) {
+		// Either read-ahead is disabled or this Read() is not sequential... so reset the window
+
+		fileInode.readAheadNextOffset = offset + length
+		fileInode.readAheadWindow = 0
+		fileInode.readAheadEndOffset = 0
+
+		fileInode.Unlock()
+
+		return
+	}
+
+	fileInode.readAheadNextOffset = offset + length
+
+	if 0 == fileInode.readAheadWindow {
+		fileInode.readAheadWindow = volumeGroup.readCacheLineSize
+	} else {
+		fileInode.readAheadWindow *= 2
+	}
+	if fileInode.readAheadWindow > maxWindow {
+		fileInode.readAheadWindow = maxWindow
+	}
+
+	// Only that part of the window not covered by a prior readAhead() need be considered
+
+	readAheadOffset = fileInode.readAheadNextOffset
+	if readAheadOffset < fileInode.readAheadEndOffset {
+		readAheadOffset = fileInode.readAheadEndOffset
+	}
+
+	if (fileInode.readAheadNextOffset + fileInode.readAheadWindow) <= readAheadOffset {
+		fileInode.Unlock()
+		return
+	}
+
+	readAheadLength = fileInode.readAheadNextOffset + fileInode.readAheadWindow - readAheadOffset
+
+	fileInode.readAheadEndOffset = readAheadOffset + readAheadLength
+
+	fileInode.Unlock()
+
+	readPlan, _, err = vS.getReadPlanHelper(snapShotID, fileInode, &readAheadOffset, &readAheadLength)
+	if nil != err {
+		logger.WarnWithError(err)
+		return
+	}
+
+	readCacheKey.volumeName = vS.volumeName
+
+	for _, step = range readPlan {
+		if (nil != step.Data) || (0 == step.LogSegmentNumber) {
+			// Neither inlined data nor zero-fill steps need reading ahead
+			continue
+		}
+
+		fileInode.Lock()
+		_, inFlightHit = fileInode.inFlightLogSegmentMap[step.LogSegmentNumber]
+		fileInode.Unlock()
+
+		if inFlightHit {
+			// Data in an inFlightLogSegment is already in memory
+			continue
+		}
+
+		readCacheKey.logSegmentNumber = step.LogSegmentNumber
+
+		if 0 == step.CompressedLength {
+			cacheLineTagFinal = (step.Offset + step.Length - 1) / volumeGroup.readCacheLineSize
+		} else {
+			cacheLineTagFinal = (step.Offset + step.CompressedLength - 1) / volumeGroup.readCacheLineSize
+		}
+
+		for cacheLineTag = step.Offset / volumeGroup.readCacheLineSize; cacheLineTag <= cacheLineTagFinal; cacheLineTag++ {
+			readCacheKey.cacheLineTag = cacheLineTag
+
+			volumeGroup.Lock()
+
+			_, ok = volumeGroup.readCache[readCacheKey]
+			if !ok {
+				_, ok = volumeGroup.readAheadInFlight[readCacheKey]
+			}
+			if ok {
+				volumeGroup.Unlock()
+				continue
+			}
+
+			if uint64(len(volumeGroup.readAheadInFlight)) >= (maxWindow / volumeGroup.readCacheLineSize) {
+				// Too many cache lines are already being read ahead
+
+				volumeGroup.Unlock()
+				return
+			}
+
+			volumeGroup.readAheadInFlight[readCacheKey] = struct{}{}
+			volumeGroup.readAheadWG.Add(1)
+
+			volumeGroup.Unlock()
+
+			globals.ReadAheadCacheLinesIssued.Add(1)
+
+			go volumeGroup.readAheadCacheLine(readCacheKey, step.AccountName, step.ContainerName, step.ObjectName)
+		}
+	}
+}
+
+// readAheadCacheLine fetches the cache line identified by readCacheKey into the ReadCache.
+func (volumeGroup *volumeGroupStruct) readAheadCacheLine(readCacheKey readCacheKeyStruct, accountName string, containerName string, objectName string) {
+	var (
+		cacheLine        []byte
+		err              error
+		ok               bool
+		readCacheElement *readCacheElementStruct
+		startTime        time.Time
+	)
+
+	defer volumeGroup.readAheadWG.Done()
+
+	startTime = time.Now()
+
+	cacheLine, err = swiftclient.ObjectGet(accountName, containerName, objectName, readCacheKey.cacheLineTag*volumeGroup.readCacheLineSize, volumeGroup.readCacheLineSize)
+
+	globals.ReadAheadUsec.Add(uint64(time.Since(startTime) / time.Microsecond))
+
+	volumeGroup.Lock()
+
+	delete(volumeGroup.readAheadInFlight, readCacheKey)
+
+	if nil != err {
+		volumeGroup.Unlock()
+		globals.ReadAheadErrors.Add(1)
+		logger.WarnfWithError(err, "Reading ahead from LogSegment object failed")
+		return
+	}
+
+	_, ok = volumeGroup.readCache[readCacheKey]
+	if !ok {
+		readCacheElement = &readCacheElementStruct{
+			readCacheKey: readCacheKey,
+			next:         nil,
+			prev:         nil,
+			cacheLine:    cacheLine,
+			readAhead:    true,
+		}
+		volumeGroup.insertReadCacheElementWhileLocked(readCacheElement)
+	}
+
+	volumeGroup.Unlock()
+
+	globals.ReadAheadBytes.Add(uint64(len(cacheLine)))
+}
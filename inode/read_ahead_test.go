@@ -0,0 +1,81 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadAhead(t *testing.T) {
+	testSetup(t, false)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") should have worked - got error: %v", err)
+	}
+
+	volumeGroup := testVolumeHandle.(*volumeStruct).volumeGroup
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	fileSize := 4 * volumeGroup.readCacheLineSize
+	readSize := volumeGroup.readCacheLineSize / 4
+
+	ourBytes := make([]byte, fileSize)
+	for i := range ourBytes {
+		ourBytes[i] = byte(i)
+	}
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, ourBytes, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, true)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	issuedBefore := globals.ReadAheadCacheLinesIssued.TotalGet()
+	hitBefore := globals.ReadAheadCacheLinesHit.TotalGet()
+
+	// Read the first cache line sequentially... which should trigger read-ahead of subsequent ones
+
+	for offset := uint64(0); offset < volumeGroup.readCacheLineSize; offset += readSize {
+		readBuf, err := testVolumeHandle.Read(fileInodeNumber, offset, readSize, nil)
+		if nil != err {
+			t.Fatalf("Read(fileInodeNumber, 0x%X, 0x%X) failed: %v", offset, readSize, err)
+		}
+		if 0 != bytes.Compare(ourBytes[offset:offset+readSize], readBuf) {
+			t.Fatalf("Read(fileInodeNumber, 0x%X, 0x%X) returned unexpected data", offset, readSize)
+		}
+	}
+
+	volumeGroup.readAheadWG.Wait()
+
+	if globals.ReadAheadCacheLinesIssued.TotalGet() == issuedBefore {
+		t.Fatalf("Sequential Read()s should have issued read-ahead")
+	}
+
+	// Reading the next cache line should now hit a read-ahead cache line
+
+	readBuf, err := testVolumeHandle.Read(fileInodeNumber, volumeGroup.readCacheLineSize, readSize, nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if 0 != bytes.Compare(ourBytes[volumeGroup.readCacheLineSize:volumeGroup.readCacheLineSize+readSize], readBuf) {
+		t.Fatalf("Read() of read-ahead cache line returned unexpected data")
+	}
+
+	if globals.ReadAheadCacheLinesHit.TotalGet() == hitBefore {
+		t.Fatalf("Read() following read-ahead should have hit a read-ahead cache line")
+	}
+
+	volumeGroup.readAheadWG.Wait()
+
+	testTeardown(t)
+}
@@ -5,7 +5,10 @@ package inode
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/headhunter"
 	"github.com/NVIDIA/proxyfs/logger"
 	"github.com/NVIDIA/proxyfs/stats"
 	"github.com/NVIDIA/sortedmap"
@@ -17,7 +20,7 @@ func (vS *volumeStruct) GetFSID() (fsid uint64) {
 }
 
 func (vS *volumeStruct) SnapShotCreate(name string) (id uint64, err error) {
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -46,7 +49,7 @@ func (vS *volumeStruct) SnapShotDelete(id uint64) (err error) {
 		valueAsInodeStructPtr               *inMemoryInodeStruct
 	)
 
-	err = enforceRWMode(false)
+	err = vS.enforceRWMode(false)
 	if nil != err {
 		return
 	}
@@ -120,6 +123,466 @@ func (vS *volumeStruct) SnapShotDelete(id uint64) (err error) {
 	return
 }
 
+// SnapShotDiff reports up to maxEntries Inodes (beyond lastInodeNumber) that differ between
+// the fromSnapShotID and toSnapShotID views. A SnapShotID of zero selects the live view. To
+// continue an enumeration, pass the InodeNumber of the last returned SnapShotDiffEntry.
+func (vS *volumeStruct) SnapShotDiff(fromSnapShotID uint64, toSnapShotID uint64, lastInodeNumber InodeNumber, maxEntries uint64) (diffEntries []SnapShotDiffEntry, moreEntries bool, err error) {
+	var (
+		diffEntry     SnapShotDiffEntry
+		inodeRecDiff  headhunter.InodeRecDiffStruct
+		inodeRecDiffs []headhunter.InodeRecDiffStruct
+	)
+
+	inodeRecDiffs, moreEntries, err = vS.headhunterVolumeHandle.FetchInodeRecDiff(fromSnapShotID, toSnapShotID, uint64(lastInodeNumber), maxEntries)
+	if nil != err {
+		return
+	}
+
+	diffEntries = make([]SnapShotDiffEntry, 0, len(inodeRecDiffs))
+
+	for _, inodeRecDiff = range inodeRecDiffs {
+		diffEntry = SnapShotDiffEntry{
+			InodeNumber: InodeNumber(inodeRecDiff.InodeNumber),
+		}
+
+		switch inodeRecDiff.DiffType {
+		case headhunter.InodeRecDiffCreated:
+			diffEntry.DiffType = SnapShotDiffCreated
+			diffEntry.ViewInodeNumber = InodeNumber(vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(toSnapShotID, inodeRecDiff.InodeNumber))
+		case headhunter.InodeRecDiffModified:
+			diffEntry.DiffType = SnapShotDiffModified
+			diffEntry.ViewInodeNumber = InodeNumber(vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(toSnapShotID, inodeRecDiff.InodeNumber))
+		case headhunter.InodeRecDiffDeleted:
+			diffEntry.DiffType = SnapShotDiffDeleted
+			diffEntry.ViewInodeNumber = InodeNumber(vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(fromSnapShotID, inodeRecDiff.InodeNumber))
+		default:
+			logger.Fatalf("headhunter.FetchInodeRecDiff() returned unexpected DiffType: %v", inodeRecDiff.DiffType)
+		}
+
+		diffEntry.Type, err = vS.GetType(diffEntry.ViewInodeNumber)
+		if nil != err {
+			logger.ErrorfWithError(err, "SnapShotDiff() unable to GetType(0x%016X)", diffEntry.ViewInodeNumber)
+			return
+		}
+
+		diffEntries = append(diffEntries, diffEntry)
+	}
+
+	return
+}
+
+// SnapShotClone creates a new volume, residing in cloneAccountName/cloneCheckpointContainerName,
+// whose initial state is that of the SnapShot identified by id. The clone shares the SnapShot's
+// LogSegments and metadata rather than copying them. A volume that is itself a clone cannot be
+// cloned.
+func (vS *volumeStruct) SnapShotClone(id uint64, cloneAccountName string, cloneCheckpointContainerName string, cloneCheckpointContainerStoragePolicy string) (err error) {
+	err = vS.enforceRWMode(false)
+	if nil != err {
+		return
+	}
+
+	vS.Lock()
+	err = vS.headhunterVolumeHandle.SnapShotCloneByInodeLayer(id, cloneAccountName, cloneCheckpointContainerName, cloneCheckpointContainerStoragePolicy)
+	vS.Unlock()
+	return
+}
+
+// SnapShotRevert reverts the live view to the state captured by the SnapShot identified by id. Only
+// the most recent SnapShot may be reverted to. All dirty Inodes are first flushed and the entire
+// inodeCache is then discarded as it would otherwise describe the abandoned live view. Callers must
+// ensure that no other operations are underway on the volume.
+func (vS *volumeStruct) SnapShotRevert(id uint64) (err error) {
+	err = vS.enforceRWMode(false)
+	if nil != err {
+		return
+	}
+
+	err = vS.flushAndDiscardInodeCache()
+	if nil != err {
+		return
+	}
+
+	err = vS.headhunterVolumeHandle.SnapShotRevertByInodeLayer(id)
+	if nil != err {
+		err = blunder.AddError(err, blunder.InvalidArgError)
+	}
+
+	vS.Unlock()
+
+	if nil == err {
+		vS.RebuildDedupRefCountsIfNecessary()
+	}
+
+	return
+}
+
+// RetainedCheckpointInspect presents (read-only) the state captured by the retained checkpoint identified
+// by id in place of the live view. Passing an id of zero resumes presenting the live view. As with
+// SnapShotRevert(), the inodeCache is discarded and callers must ensure that no other operations are
+// underway on the volume.
+func (vS *volumeStruct) RetainedCheckpointInspect(id uint64) (err error) {
+	err = vS.flushAndDiscardInodeCache()
+	if nil != err {
+		return
+	}
+
+	err = vS.headhunterVolumeHandle.RetainedCheckpointInspectByInodeLayer(id)
+	if nil != err {
+		err = blunder.AddError(err, blunder.InvalidArgError)
+	}
+
+	vS.Unlock()
+
+	return
+}
+
+// RetainedCheckpointRevert makes the state captured by the retained checkpoint identified by id the
+// new live view. No SnapShots may exist. As with SnapShotRevert(), the inodeCache is discarded and
+// callers must ensure that no other operations are underway on the volume.
+func (vS *volumeStruct) RetainedCheckpointRevert(id uint64) (err error) {
+	err = vS.enforceRWMode(false)
+	if nil != err {
+		return
+	}
+
+	err = vS.flushAndDiscardInodeCache()
+	if nil != err {
+		return
+	}
+
+	err = vS.headhunterVolumeHandle.RetainedCheckpointRevertByInodeLayer(id)
+	if nil != err {
+		err = blunder.AddError(err, blunder.InvalidArgError)
+	}
+
+	vS.Unlock()
+
+	if nil == err {
+		vS.RebuildDedupRefCountsIfNecessary()
+	}
+
+	return
+}
+
+// flushAndDiscardInodeCache flushes all dirty Inodes and then discards the entire inodeCache (as it would
+// otherwise describe a live view about to be abandoned). Upon success, vS is returned locked.
+func (vS *volumeStruct) flushAndDiscardInodeCache() (err error) {
+	var (
+		dirtyInodes     []*inMemoryInodeStruct
+		inode           *inMemoryInodeStruct
+		inodeAsValue    sortedmap.Value
+		inodeCacheIndex int
+		inodeCacheLen   int
+		ok              bool
+	)
+
+	vS.Lock()
+
+	inodeCacheLen, err = vS.inodeCache.Len()
+	if nil != err {
+		vS.Unlock()
+		err = fmt.Errorf("Volume %v InodeCache Len() failed: %v", vS.volumeName, err)
+		logger.Error(err)
+		return
+	}
+
+	dirtyInodes = make([]*inMemoryInodeStruct, 0)
+
+	for inodeCacheIndex = 0; inodeCacheIndex < inodeCacheLen; inodeCacheIndex++ {
+		_, inodeAsValue, ok, err = vS.inodeCache.GetByIndex(inodeCacheIndex)
+		if nil != err {
+			vS.Unlock()
+			err = fmt.Errorf("Volume %v InodeCache GetByIndex() failed: %v", vS.volumeName, err)
+			logger.Error(err)
+			return
+		}
+		if !ok {
+			vS.Unlock()
+			err = fmt.Errorf("Volume %v InodeCache GetByIndex() returned !ok", vS.volumeName)
+			return
+		}
+
+		inode = inodeAsValue.(*inMemoryInodeStruct)
+
+		if inode.dirty {
+			dirtyInodes = append(dirtyInodes, inode)
+		}
+	}
+
+	vS.Unlock()
+
+	if 0 < len(dirtyInodes) {
+		err = vS.flushInodes(dirtyInodes)
+		if nil != err {
+			logger.ErrorfWithError(err, "flushAndDiscardInodeCache() call to flushInodes() failed")
+			return
+		}
+	}
+
+	vS.Lock()
+
+	for {
+		_, inodeAsValue, ok, err = vS.inodeCache.GetByIndex(0)
+		if nil != err {
+			vS.Unlock()
+			err = fmt.Errorf("Volume %v InodeCache GetByIndex() failed: %v", vS.volumeName, err)
+			logger.Error(err)
+			return
+		}
+		if !ok {
+			break
+		}
+
+		ok, err = vS.inodeCacheDropWhileLocked(inodeAsValue.(*inMemoryInodeStruct))
+		if nil != err {
+			vS.Unlock()
+			err = fmt.Errorf("Volume %v inodeCacheDropWhileLocked() failed: %v", vS.volumeName, err)
+			return
+		}
+		if !ok {
+			vS.Unlock()
+			err = fmt.Errorf("Volume %v inodeCacheDropWhileLocked() returned !ok", vS.volumeName)
+			return
+		}
+	}
+
+	err = nil
+	return
+}
+
+// SnapShotRestore restores the live Inode identified by inodeNumber to its state in the SnapShot
+// identified by snapShotID. If the live Inode no longer exists, it is recreated with the same
+// inodeNumber (and a LinkCount reflecting only its "." entry if it is a DirInode) for the caller to
+// link into place. The contents of a FileInode are restored by referencing the very same LogSegments
+// as the SnapShot's version of it (rather than copying them). The contents of a DirInode are left to
+// the caller to restore.
+func (vS *volumeStruct) SnapShotRestore(snapShotID uint64, inodeNumber InodeNumber) (err error) {
+	var (
+		dirMapping              sortedmap.BPlusTree
+		extentAsValue           sortedmap.Value
+		extentIndex             int
+		extents                 []*fileExtentStruct
+		fileExtent              *fileExtentStruct
+		liveInode               *inMemoryInodeStruct
+		logSegmentNumber        uint64
+		logSegmentNumbers       []uint64
+		logSegmentNumbersSeen   map[uint64]struct{}
+		ok                      bool
+		sharedLogSegmentNumbers []uint64
+		snapShotExtents         sortedmap.BPlusTree
+		snapShotIDType          headhunter.SnapShotIDType
+		snapShotInode           *inMemoryInodeStruct
+		snapShotInodeNumber     InodeNumber
+		streamName              string
+		streamValue             []byte
+	)
+
+	err = vS.enforceRWMode(false)
+	if nil != err {
+		return
+	}
+
+	snapShotIDType, _, _ = vS.headhunterVolumeHandle.SnapShotU64Decode(uint64(inodeNumber))
+	if headhunter.SnapShotIDTypeLive != snapShotIDType {
+		err = fmt.Errorf("SnapShotRestore() to non-LiveView inodeNumber not allowed")
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	snapShotInodeNumber = InodeNumber(vS.headhunterVolumeHandle.SnapShotIDAndNonceEncode(snapShotID, uint64(inodeNumber)))
+
+	snapShotIDType, _, _ = vS.headhunterVolumeHandle.SnapShotU64Decode(uint64(snapShotInodeNumber))
+	if headhunter.SnapShotIDTypeSnapShot != snapShotIDType {
+		err = fmt.Errorf("SnapShotRestore() requires a SnapShot (not SnapShotID %v)", snapShotID)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	snapShotInode, ok, err = vS.fetchOnDiskInode(snapShotInodeNumber)
+	if nil != err {
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("SnapShotRestore() unable to find inode %d in SnapShotID %v of volume '%s'", inodeNumber, snapShotID, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	liveInode, ok, err = vS.inodeCacheFetch(inodeNumber)
+	if nil != err {
+		return
+	}
+	if !ok {
+		liveInode, ok, err = vS.fetchOnDiskInode(inodeNumber)
+		if nil != err {
+			if !blunder.Is(err, blunder.NotFoundError) {
+				return
+			}
+			ok = false
+			err = nil
+		}
+		if !ok {
+			liveInode = vS.makeInMemoryInodeWithThisInodeNumber(snapShotInode.InodeType, snapShotInode.Mode, snapShotInode.UserID, snapShotInode.GroupID, inodeNumber, false)
+
+			switch liveInode.InodeType {
+			case DirType:
+				dirMapping =
+					sortedmap.NewBPlusTree(
+						vS.maxEntriesPerDirNode,
+						sortedmap.CompareString,
+						&dirInodeCallbacks{treeNodeLoadable{inode: liveInode}},
+						vS.dirEntryCache.Cache())
+
+				ok, err = dirMapping.Put(".", liveInode.InodeNumber)
+				if (nil != err) || (!ok) {
+					panic(err)
+				}
+
+				liveInode.LinkCount = 1
+				liveInode.payload = dirMapping
+			case FileType:
+				liveInode.payload =
+					sortedmap.NewBPlusTree(
+						vS.maxExtentsPerFileNode,
+						sortedmap.CompareUint64,
+						&fileInodeCallbacks{treeNodeLoadable{inode: liveInode}},
+						vS.fileExtentMapCache.Cache())
+			}
+		}
+
+		ok, err = vS.inodeCacheInsert(liveInode)
+		if nil != err {
+			return
+		}
+		if !ok {
+			err = fmt.Errorf("SnapShotRestore() inodeCacheInsert(liveInode) failed")
+			return
+		}
+	}
+
+	if liveInode.InodeType != snapShotInode.InodeType {
+		err = fmt.Errorf("SnapShotRestore() found inode %d volume '%s' type changed from %v to %v", inodeNumber, vS.volumeName, snapShotInode.InodeType, liveInode.InodeType)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	if FileType == liveInode.InodeType {
+		if liveInode.dirty {
+			err = flush(liveInode, false)
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+		}
+
+		// Assemble the SnapShot's extents and the LogSegments they reference
+
+		snapShotExtents = snapShotInode.payload.(sortedmap.BPlusTree)
+
+		extents = make([]*fileExtentStruct, 0)
+		logSegmentNumbers = make([]uint64, 0)
+		logSegmentNumbersSeen = make(map[uint64]struct{})
+		sharedLogSegmentNumbers = make([]uint64, 0)
+
+		for extentIndex = 0; ; extentIndex++ {
+			_, extentAsValue, ok, err = snapShotExtents.GetByIndex(extentIndex)
+			if nil != err {
+				return
+			}
+			if !ok {
+				break
+			}
+
+			fileExtent = extentAsValue.(*fileExtentStruct)
+			extents = append(extents, fileExtent)
+
+			_, ok = logSegmentNumbersSeen[fileExtent.LogSegmentNumber]
+			if ok {
+				continue
+			}
+			logSegmentNumbersSeen[fileExtent.LogSegmentNumber] = struct{}{}
+
+			// A LogSegment still referenced by another live FileInode (e.g. via dedup) becomes shared
+
+			_, ok = liveInode.LogSegmentMap[fileExtent.LogSegmentNumber]
+			if !ok {
+				_, err = vS.headhunterVolumeHandle.GetLogSegmentRec(fileExtent.LogSegmentNumber)
+				if nil == err {
+					sharedLogSegmentNumbers = append(sharedLogSegmentNumbers, fileExtent.LogSegmentNumber)
+					continue
+				}
+			}
+
+			logSegmentNumbers = append(logSegmentNumbers, fileExtent.LogSegmentNumber)
+		}
+
+		err = vS.headhunterVolumeHandle.RestoreLogSegmentRecs(snapShotID, logSegmentNumbers)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+
+		for _, logSegmentNumber = range sharedLogSegmentNumbers {
+			err = vS.headhunterVolumeHandle.ReferenceLogSegmentRec(logSegmentNumber)
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+		}
+
+		err = setSizeInMemory(liveInode, 0)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+
+		for _, fileExtent = range extents {
+			err = recordExtent(liveInode, sliceFileExtent(fileExtent, 0))
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+		}
+
+		liveInode.InlineData = append([]byte(nil), snapShotInode.InlineData...)
+
+		err = setSizeInMemory(liveInode, snapShotInode.Size)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+
+		liveInode.NumWrites++
+	}
+
+	if SymlinkType == liveInode.InodeType {
+		liveInode.SymlinkTarget = snapShotInode.SymlinkTarget
+	}
+
+	liveInode.Mode = snapShotInode.Mode
+	liveInode.UserID = snapShotInode.UserID
+	liveInode.GroupID = snapShotInode.GroupID
+	liveInode.CreationTime = snapShotInode.CreationTime
+	liveInode.ModificationTime = snapShotInode.ModificationTime
+	liveInode.AccessTime = snapShotInode.AccessTime
+	liveInode.AttrChangeTime = time.Now()
+
+	liveInode.StreamMap = make(map[string][]byte)
+	for streamName, streamValue = range snapShotInode.StreamMap {
+		liveInode.StreamMap[streamName] = append([]byte(nil), streamValue...)
+	}
+
+	liveInode.dirty = true
+
+	err = vS.flushInode(liveInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	return
+}
+
 func (vS *volumeStruct) CheckpointCompleted() {
 	var (
 		dirEntryCacheHitsDelta        uint64
@@ -130,34 +593,30 @@ func (vS *volumeStruct) CheckpointCompleted() {
 		fileExtentMapCacheStats       *sortedmap.BPlusTreeCacheStats
 	)
 
-	dirEntryCacheStats = globals.dirEntryCache.Stats()
-	fileExtentMapCacheStats = globals.fileExtentMapCache.Stats()
-
-	globals.Lock()
+	dirEntryCacheStats = vS.dirEntryCache.Cache().Stats()
+	fileExtentMapCacheStats = vS.fileExtentMapCache.Cache().Stats()
 
-	dirEntryCacheHitsDelta = dirEntryCacheStats.CacheHits - globals.dirEntryCachePriorCacheHits
-	dirEntryCacheMissesDelta = dirEntryCacheStats.CacheMisses - globals.dirEntryCachePriorCacheMisses
+	dirEntryCacheHitsDelta = dirEntryCacheStats.CacheHits - vS.dirEntryCachePriorHits
+	dirEntryCacheMissesDelta = dirEntryCacheStats.CacheMisses - vS.dirEntryCachePriorMisses
 
-	fileExtentMapCacheHitsDelta = fileExtentMapCacheStats.CacheHits - globals.fileExtentMapCachePriorCacheHits
-	fileExtentMapCacheMissesDelta = fileExtentMapCacheStats.CacheMisses - globals.fileExtentMapCachePriorCacheMisses
+	fileExtentMapCacheHitsDelta = fileExtentMapCacheStats.CacheHits - vS.fileExtentMapCachePriorHits
+	fileExtentMapCacheMissesDelta = fileExtentMapCacheStats.CacheMisses - vS.fileExtentMapCachePriorMisses
 
 	if 0 != dirEntryCacheHitsDelta {
 		stats.IncrementOperationsBy(&stats.DirEntryCacheHits, dirEntryCacheHitsDelta)
-		globals.dirEntryCachePriorCacheHits = dirEntryCacheStats.CacheHits
+		vS.dirEntryCachePriorHits = dirEntryCacheStats.CacheHits
 	}
 	if 0 != dirEntryCacheMissesDelta {
 		stats.IncrementOperationsBy(&stats.DirEntryCacheMisses, dirEntryCacheMissesDelta)
-		globals.dirEntryCachePriorCacheMisses = dirEntryCacheStats.CacheMisses
+		vS.dirEntryCachePriorMisses = dirEntryCacheStats.CacheMisses
 	}
 
 	if 0 != fileExtentMapCacheHitsDelta {
 		stats.IncrementOperationsBy(&stats.FileExtentMapCacheHits, fileExtentMapCacheHitsDelta)
-		globals.fileExtentMapCachePriorCacheHits = fileExtentMapCacheStats.CacheHits
+		vS.fileExtentMapCachePriorHits = fileExtentMapCacheStats.CacheHits
 	}
 	if 0 != fileExtentMapCacheMissesDelta {
 		stats.IncrementOperationsBy(&stats.FileExtentMapCacheMisses, fileExtentMapCacheMissesDelta)
-		globals.fileExtentMapCachePriorCacheMisses = fileExtentMapCacheStats.CacheMisses
+		vS.fileExtentMapCachePriorMisses = fileExtentMapCacheStats.CacheMisses
 	}
-
-	globals.Unlock()
 }
@@ -0,0 +1,149 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package inode
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/logger"
+	"github.com/NVIDIA/proxyfs/utils"
+)
+
+func parseRetainUntilStream(buf []byte) (retainUntil time.Time, err error) {
+	retainUntil, err = time.Parse(time.RFC3339Nano, strings.TrimSpace(string(buf)))
+	if nil != err {
+		err = fmt.Errorf("%s value \"%s\" is not an RFC3339 timestamp", RetainUntilStreamName, string(buf))
+		err = blunder.AddError(err, blunder.InvalidArgError)
+	}
+	return
+}
+
+func parseWORMFlagStream(inodeStreamName string, buf []byte) (on bool, err error) {
+	switch strings.TrimSpace(string(buf)) {
+	case WORMStreamValueOn:
+		on = true
+	case WORMStreamValueOff:
+		on = false
+	default:
+		err = fmt.Errorf("%s value \"%s\" must be either \"%s\" or \"%s\"", inodeStreamName, string(buf), WORMStreamValueOn, WORMStreamValueOff)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+	}
+	return
+}
+
+// validateWORMStream is called by PutStream() to validate the new value of one of the WORM Streams. Note
+// that a RetainUntilStreamName value earlier than the one it would replace is rejected.
+func (vS *volumeStruct) validateWORMStream(inode *inMemoryInodeStruct, inodeStreamName string, buf []byte) (err error) {
+	var (
+		newRetainUntil time.Time
+		oldBuf         []byte
+		oldRetainUntil time.Time
+		ok             bool
+	)
+
+	switch inodeStreamName {
+	case ImmutableStreamName, AppendOnlyStreamName:
+		_, err = parseWORMFlagStream(inodeStreamName, buf)
+	case RetainUntilStreamName:
+		newRetainUntil, err = parseRetainUntilStream(buf)
+		if nil != err {
+			return
+		}
+		oldBuf, ok = inode.StreamMap[RetainUntilStreamName]
+		if ok {
+			oldRetainUntil, err = parseRetainUntilStream(oldBuf)
+			if (nil == err) && newRetainUntil.Before(oldRetainUntil) {
+				err = fmt.Errorf("retention of inode %d volume '%s' may not be shortened from %s", inode.InodeNumber, vS.volumeName, string(oldBuf))
+				err = blunder.AddError(err, blunder.NotPermError)
+				return
+			}
+		}
+		err = nil
+	default:
+		err = nil
+	}
+
+	return
+}
+
+// validateWORMStreamDeletion is called by DeleteStream() to prevent removing a retention time
+// that has yet to pass.
+func (vS *volumeStruct) validateWORMStreamDeletion(inode *inMemoryInodeStruct, inodeStreamName string) (err error) {
+	var (
+		buf         []byte
+		ok          bool
+		retainUntil time.Time
+	)
+
+	if RetainUntilStreamName != inodeStreamName {
+		err = nil
+		return
+	}
+
+	buf, ok = inode.StreamMap[RetainUntilStreamName]
+	if !ok {
+		err = nil
+		return
+	}
+
+	retainUntil, err = parseRetainUntilStream(buf)
+	if (nil == err) && time.Now().Before(retainUntil) {
+		err = fmt.Errorf("retention of inode %d volume '%s' may not be removed before %s", inode.InodeNumber, vS.volumeName, string(buf))
+		err = blunder.AddError(err, blunder.NotPermError)
+		return
+	}
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) GetWORMState(inodeNumber InodeNumber) (wormState WORMStateStruct, err error) {
+	var (
+		buf   []byte
+		inode *inMemoryInodeStruct
+		ok    bool
+	)
+
+	inode, ok, err = vS.fetchInode(inodeNumber)
+	if nil != err {
+		// this indicates disk corruption or software error
+		// (err includes volume name and inode number)
+		logger.ErrorfWithError(err, "%s: fetch of inode failed", utils.GetFnName())
+		return
+	}
+	if !ok {
+		// disk corruption or client request for unallocated inode
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because it is unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		logger.InfoWithError(err)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	// Malformed values (which PutStream() would have rejected) are treated as absent
+
+	buf, ok = inode.StreamMap[ImmutableStreamName]
+	if ok {
+		wormState.Immutable, _ = parseWORMFlagStream(ImmutableStreamName, buf)
+	}
+
+	buf, ok = inode.StreamMap[AppendOnlyStreamName]
+	if ok {
+		wormState.AppendOnly, _ = parseWORMFlagStream(AppendOnlyStreamName, buf)
+	}
+
+	buf, ok = inode.StreamMap[RetainUntilStreamName]
+	if ok {
+		wormState.RetainUntil, err = parseRetainUntilStream(buf)
+		if nil != err {
+			wormState.RetainUntil = time.Time{}
+		}
+	}
+
+	err = nil
+	return
+}
@@ -150,6 +150,8 @@
 package jrpcfs
 
 import (
+	"encoding/json"
+
 	"github.com/NVIDIA/proxyfs/fs"
 	"github.com/NVIDIA/proxyfs/headhunter"
 	"github.com/NVIDIA/proxyfs/inode"
@@ -267,6 +269,18 @@ type GetStatPathRequest struct {
 	PathHandle
 }
 
+// GetWORMRequest is the request object for RpcGetWORM.
+type GetWORMRequest struct {
+	InodeHandle
+}
+
+// GetWORMReply is the reply object for RpcGetWORM.
+type GetWORMReply struct {
+	Immutable     bool
+	AppendOnly    bool
+	RetainUntilNs int64 // zero if no retention time has been set
+}
+
 type GetXAttrRequest struct {
 	InodeHandle
 	AttrName string
@@ -499,12 +513,16 @@ type RenamePathRequest struct {
 // when a replacement DirEntry reduces the prior DirEntry's Inode LinkCount to zero
 // is not performed... instead leaving it up to the client to do so.
 //
+// Flags may contain either RENAME_NOREPLACE or RENAME_EXCHANGE (see inode.MoveFlags)
+// as passed in a FUSE Rename2 request. Both are performed atomically by the server.
+//
 type MoveRequest struct {
 	MountID           MountIDAsString
 	SrcDirInodeNumber int64
 	SrcBasename       string
 	DstDirInodeNumber int64
 	DstBasename       string
+	Flags             uint32
 }
 
 // MoveReply is the reply object for RpcMove.
@@ -550,6 +568,14 @@ type SetTimePathRequest struct {
 	StatStruct
 }
 
+// SetWORMRequest is the request object for RpcSetWORM.
+type SetWORMRequest struct {
+	InodeHandle
+	Immutable     bool
+	AppendOnly    bool
+	RetainUntilNs int64 // if zero, any existing retention time is left unchanged
+}
+
 type SetXAttrRequest struct {
 	InodeHandle
 	AttrName  string
@@ -664,7 +690,8 @@ type DeleteReply struct {
 
 // DeleteReq is the request object for RpcDelete
 type DeleteReq struct {
-	VirtPath string
+	VirtPath  string
+	VersionID uint64 // if non-zero, only this version of the object is deleted
 }
 
 type HeadReply struct {
@@ -678,7 +705,8 @@ type HeadReply struct {
 }
 
 type HeadReq struct {
-	VirtPath string // virtual entity path, e.g. /v1/AUTH_acc/some-dir[/some-file]
+	VirtPath  string // virtual entity path, e.g. /v1/AUTH_acc/some-dir[/some-file]
+	VersionID uint64 // if non-zero, this version of the object is described
 }
 
 // GetContainerReply is the response object for RpcGetContainer
@@ -697,6 +725,7 @@ type GetContainerReq struct {
 	Prefix     string // only look at entries starting with this
 	MaxEntries uint64 // maximum number of entries to return
 	Delimiter  string // only match up to the first occurrence of delimiter (excluding prefix)
+	Versions   bool   // also list the prior versions of each object
 }
 
 // Response object for RpcGetAccount
@@ -738,6 +767,10 @@ type GetObjectReq struct {
 	// to convert the values. To obtain a read plan for the entire
 	// object, leave ReadEntsIn empty.
 	ReadEntsIn []fs.ReadRangeIn
+
+	// Version of the object to be read. To read the current
+	// version, leave VersionID zero.
+	VersionID uint64
 }
 
 // MiddlewarePostReply is the reply object for RpcPost
@@ -844,6 +877,71 @@ type CoalesceReply struct {
 	NumWrites        uint64
 }
 
+// MultipartInitiateReq is the request object for RpcMultipartInitiate
+type MultipartInitiateReq struct {
+	VirtPath string
+	Metadata []byte
+}
+
+// MultipartInitiateReply is the response object for RpcMultipartInitiate
+type MultipartInitiateReply struct {
+	UploadID string
+}
+
+// MultipartPutPartReq is the request object for RpcMultipartPutPart
+type MultipartPutPartReq struct {
+	VirtPath    string
+	UploadID    string
+	PartNumber  uint32
+	PhysPaths   []string
+	PhysLengths []uint64
+	ETag        string
+}
+
+// MultipartPutPartReply is the response object for RpcMultipartPutPart
+type MultipartPutPartReply struct {
+}
+
+// MultipartListPartsReq is the request object for RpcMultipartListParts
+type MultipartListPartsReq struct {
+	VirtPath         string
+	UploadID         string
+	PartNumberMarker uint32
+	MaxParts         uint64
+}
+
+// MultipartListPartsReply is the response object for RpcMultipartListParts
+type MultipartListPartsReply struct {
+	Parts     []fs.MultipartPart
+	MoreParts bool
+}
+
+// MultipartCompleteReq is the request object for RpcMultipartComplete
+type MultipartCompleteReq struct {
+	VirtPath string
+	UploadID string
+	Parts    []fs.MultipartPart
+	Metadata []byte // if nil, the Metadata passed to RpcMultipartInitiate is applied
+}
+
+// MultipartCompleteReply is the response object for RpcMultipartComplete
+type MultipartCompleteReply struct {
+	ModificationTime uint64
+	AttrChangeTime   uint64
+	InodeNumber      int64
+	NumWrites        uint64
+}
+
+// MultipartAbortReq is the request object for RpcMultipartAbort
+type MultipartAbortReq struct {
+	VirtPath string
+	UploadID string
+}
+
+// MultipartAbortReply is the response object for RpcMultipartAbort
+type MultipartAbortReply struct {
+}
+
 type ProvisionObjectRequest struct {
 	MountID MountIDAsString
 }
@@ -917,6 +1015,220 @@ type SnapShotLookupByNameReply struct {
 	SnapShot headhunter.SnapShotStruct
 }
 
+// SnapShotDiffRequest is the request object for RpcSnapShotDiff
+//
+// A SnapShotID of zero selects the live view. To continue a prior response
+// indicating MoreEntries, pass the InodeNumber of the last entry returned
+// as LastInodeNumber (zero to start at the beginning).
+type SnapShotDiffRequest struct {
+	MountID         MountIDAsString
+	FromSnapShotID  uint64
+	ToSnapShotID    uint64
+	LastInodeNumber uint64
+	MaxEntries      uint64
+}
+
+// SnapShotDiffReply is the reply object for RpcSnapShotDiff
+type SnapShotDiffReply struct {
+	DiffEntries []fs.SnapShotDiffEntry
+	MoreEntries bool
+}
+
+// SnapShotRevertRequest is the request object for RpcSnapShotRevert
+//
+// Only the most recent SnapShot may be reverted to.
+type SnapShotRevertRequest struct {
+	MountID    MountIDAsString
+	SnapShotID uint64
+}
+
+// SnapShotRevertReply is the reply object for RpcSnapShotRevert
+type SnapShotRevertReply struct{}
+
+// SnapShotRestoreRequest is the request object for RpcSnapShotRestore
+//
+// Path identifies the file, symlink, or directory subtree (within both the
+// SnapShot and the live view) to be restored.
+type SnapShotRestoreRequest struct {
+	MountID    MountIDAsString
+	SnapShotID uint64
+	Path       string
+}
+
+// SnapShotRestoreReply is the reply object for RpcSnapShotRestore
+type SnapShotRestoreReply struct{}
+
+// FetchChangeEventsRequest is the request object for RpcFetchChangeEvents
+//
+// As ChangeEvents report changes made by any mount of the volume (including the
+// full paths involved), RpcFetchChangeEvents is only permitted on mounts whose
+// requests are performed as root (see RecycleBinListRequest).
+//
+// Pass the Epoch and LatestSequenceNumber of the prior reply as LastEpoch and
+// LastSequenceNumber (both zero to start with the oldest event retained). A
+// MaxEvents of zero imposes no limit. If no events are available, the reply is
+// delayed for up to MaxWaitMilliseconds (capped at fs.ChangeEventsMaxWait).
+type FetchChangeEventsRequest struct {
+	MountID             MountIDAsString
+	LastEpoch           uint64
+	LastSequenceNumber  uint64
+	MaxEvents           uint64
+	MaxWaitMilliseconds uint64
+}
+
+// FetchChangeEventsReply is the reply object for RpcFetchChangeEvents
+//
+// EventsLost indicates that events following LastSequenceNumber were discarded
+// before they could be fetched (including when Epoch differs from LastEpoch as
+// the volume has since been re-served).
+type FetchChangeEventsReply struct {
+	ChangeEvents         []fs.ChangeEvent
+	Epoch                uint64
+	LatestSequenceNumber uint64
+	EventsLost           bool
+}
+
+// RecycleBinListRequest is the request object for RpcRecycleBinList
+//
+// As the recycle bin holds what was removed by any mount of the volume, the
+// RpcRecycleBin* requests are only permitted on mounts whose requests are
+// performed as root (i.e. neither squashed nor bound to a non-root identity).
+//
+// Pass the Name of the last entry of the prior reply as PrevName ("" to start
+// with the first entry). A MaxEntries of zero imposes no limit beyond that of
+// the server.
+type RecycleBinListRequest struct {
+	MountID    MountIDAsString
+	PrevName   string
+	MaxEntries uint64
+}
+
+// RecycleBinListReply is the reply object for RpcRecycleBinList
+type RecycleBinListReply struct {
+	Entries     []fs.RecycleBinEntry
+	MoreEntries bool
+}
+
+// RecycleBinRestoreRequest is the request object for RpcRecycleBinRestore
+//
+// If Path is "", the entry is restored to the path from which it was removed.
+type RecycleBinRestoreRequest struct {
+	MountID MountIDAsString
+	Name    string
+	Path    string
+}
+
+// RecycleBinRestoreReply is the reply object for RpcRecycleBinRestore
+type RecycleBinRestoreReply struct {
+	Path string
+}
+
+// RecycleBinPurgeRequest is the request object for RpcRecycleBinPurge
+type RecycleBinPurgeRequest struct {
+	MountID MountIDAsString
+	Name    string
+}
+
+// RecycleBinPurgeReply is the reply object for RpcRecycleBinPurge
+type RecycleBinPurgeReply struct{}
+
+// FindRequest is the request object for RpcFind
+//
+// The Inodes at or beneath Path satisfying Predicate are reported by a Find
+// job whose results are retrieved via RpcFindFetch.
+type FindRequest struct {
+	MountID   MountIDAsString
+	Path      string
+	Predicate fs.FindPredicate
+}
+
+// FindReply is the reply object for RpcFind
+type FindReply struct {
+	FindJobID uint64
+}
+
+// FindFetchRequest is the request object for RpcFindFetch
+//
+// Pass the NextMarker of the prior reply as Marker (zero to start at the
+// beginning). A MaxResults of zero imposes no limit. If no results are
+// available, the reply is delayed for up to MaxWaitMilliseconds.
+type FindFetchRequest struct {
+	MountID             MountIDAsString
+	FindJobID           uint64
+	Marker              uint64
+	MaxResults          uint64
+	MaxWaitMilliseconds uint64
+}
+
+// FindFetchReply is the reply object for RpcFindFetch
+//
+// Once Done is returned, the Find job (and its FindJobID) is no more. Any
+// errors encountered by the Find job are then returned in Errors.
+type FindFetchReply struct {
+	Results    []fs.FindResult
+	NextMarker uint64
+	Done       bool
+	Errors     []string
+}
+
+// FindCancelRequest is the request object for RpcFindCancel
+type FindCancelRequest struct {
+	MountID   MountIDAsString
+	FindJobID uint64
+}
+
+// FindCancelReply is the reply object for RpcFindCancel
+type FindCancelReply struct{}
+
+// CompoundStepRef directs RpcCompound to overwrite an integer field of a step's
+// request with an integer field of an earlier step's reply (e.g. to operate on the
+// InodeNumber returned by a prior RpcLookup).
+//
+// RequestField may name a field of an embedded struct (e.g. InodeHandle.InodeNumber
+// is simply "InodeNumber").
+type CompoundStepRef struct {
+	RequestField string
+	Step         int // Index in CompoundRequest.Steps... must precede the referencing step
+	ReplyField   string
+}
+
+// CompoundStepCheck directs RpcCompound to verify, before performing a step, that
+// an integer field of an earlier step's reply holds Value (e.g. that an RpcLookup
+// still finds the InodeNumber the client holds a lease on). A failed check fails
+// the RpcCompound with TryAgainError (EAGAIN).
+type CompoundStepCheck struct {
+	Step       int // Index in CompoundRequest.Steps... must precede the checking step
+	ReplyField string
+	Value      int64
+}
+
+// CompoundStep is one operation of a CompoundRequest
+//
+// Method is the name of a Server method (e.g. "RpcLookup") and Request is the
+// JSON encoding of its request object.
+type CompoundStep struct {
+	Method  string
+	Request json.RawMessage
+	Refs    []CompoundStepRef
+	Checks  []CompoundStepCheck
+}
+
+// CompoundRequest is the request object for RpcCompound
+//
+// Steps are performed in order. The first failing step fails the RpcCompound
+// with that step's error and no subsequent steps are performed. A CompoundRequest
+// with no Steps does nothing and may be used to probe for RpcCompound support.
+type CompoundRequest struct {
+	Steps []CompoundStep
+}
+
+// CompoundReply is the reply object for RpcCompound
+//
+// Replies[i] is the JSON encoding of the reply object of Steps[i].
+type CompoundReply struct {
+	Replies []json.RawMessage
+}
+
 // LeaseRequestType specifies the requested lease operation
 //
 type LeaseRequestType uint32
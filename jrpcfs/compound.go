@@ -0,0 +1,268 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package jrpcfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/logger"
+)
+
+// compoundMethodNames lists the Server methods that may be a CompoundStep.
+//
+// Each is of the form func (s *Server) RpcXXX(in *XXXRequest, reply *XXXReply) (err error)
+// and performs a single fs operation... mount, lease, and long-running methods are
+// deliberately excluded.
+var compoundMethodNames = []string{
+	"RpcAccess",
+	"RpcChmod",
+	"RpcChown",
+	"RpcCreate",
+	"RpcDestroy",
+	"RpcGetStat",
+	"RpcGetWORM",
+	"RpcGetXAttr",
+	"RpcLink",
+	"RpcListXAttr",
+	"RpcLookup",
+	"RpcLookupPlus",
+	"RpcMkdir",
+	"RpcMove",
+	"RpcReadSymlink",
+	"RpcRemoveXAttr",
+	"RpcRename",
+	"RpcResize",
+	"RpcRmdir",
+	"RpcSetTime",
+	"RpcSetWORM",
+	"RpcSetXAttr",
+	"RpcSymlink",
+	"RpcType",
+	"RpcUnlink",
+}
+
+var compoundMethodMap map[string]reflect.Method // key == Method name
+
+func init() {
+	var (
+		compoundMap map[string]reflect.Method
+		errorType   reflect.Type
+		method      reflect.Method
+		methodName  string
+		ok          bool
+		serverType  reflect.Type
+	)
+
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	serverType = reflect.TypeOf(&Server{})
+
+	compoundMap = make(map[string]reflect.Method)
+
+	for _, methodName = range compoundMethodNames {
+		method, ok = serverType.MethodByName(methodName)
+		if !ok {
+			panic(fmt.Sprintf("compoundMethodNames contains unknown method %s", methodName))
+		}
+		if (3 != method.Type.NumIn()) || (reflect.Ptr != method.Type.In(1).Kind()) || (reflect.Ptr != method.Type.In(2).Kind()) || (1 != method.Type.NumOut()) || (errorType != method.Type.Out(0)) {
+			panic(fmt.Sprintf("compoundMethodNames contains method %s of unexpected type %v", methodName, method.Type))
+		}
+		compoundMap[methodName] = method
+	}
+
+	compoundMethodMap = compoundMap
+}
+
+// RpcCompound performs each of in.Steps in order, stopping at the first failure.
+//
+// Note that, as each step enters the gate itself, RpcCompound must not.
+func (s *Server) RpcCompound(in *CompoundRequest, reply *CompoundReply) (err error) {
+	var (
+		method    reflect.Method
+		ok        bool
+		replies   []interface{}
+		request   reflect.Value
+		results   []reflect.Value
+		step      CompoundStep
+		stepIndex int
+		stepReply reflect.Value
+	)
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+
+	reply.Replies = make([]json.RawMessage, 0, len(in.Steps))
+	replies = make([]interface{}, 0, len(in.Steps))
+
+	for stepIndex, step = range in.Steps {
+		method, ok = compoundMethodMap[step.Method]
+		if !ok {
+			err = fmt.Errorf("RpcCompound() Steps[%d].Method \"%s\" not supported", stepIndex, step.Method)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			rpcEncodeError(&err)
+			return
+		}
+
+		request = reflect.New(method.Type.In(1).Elem())
+
+		err = json.Unmarshal(step.Request, request.Interface())
+		if nil != err {
+			err = fmt.Errorf("RpcCompound() Steps[%d].Request could not be decoded: %v", stepIndex, err)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			rpcEncodeError(&err)
+			return
+		}
+
+		err = CheckCompoundStep(stepIndex, step.Checks, replies)
+		if nil != err {
+			rpcEncodeError(&err)
+			return
+		}
+
+		err = ApplyCompoundStepRefs(stepIndex, step.Refs, request.Interface(), replies)
+		if nil != err {
+			rpcEncodeError(&err)
+			return
+		}
+
+		stepReply = reflect.New(method.Type.In(2).Elem())
+
+		results = method.Func.Call([]reflect.Value{reflect.ValueOf(s), request, stepReply})
+		if !results[0].IsNil() {
+			// The step's error has already been encoded for return by RPC
+			err = results[0].Interface().(error)
+			return
+		}
+
+		replies = append(replies, stepReply.Interface())
+
+		stepReplyJSON, marshalErr := json.Marshal(stepReply.Interface())
+		if nil != marshalErr {
+			logger.Fatalf("RpcCompound() Steps[%d] reply could not be encoded: %v", stepIndex, marshalErr)
+		}
+
+		reply.Replies = append(reply.Replies, stepReplyJSON)
+	}
+
+	err = nil
+	return
+}
+
+// CheckCompoundStep verifies the checks of Steps[stepIndex] against replies (the
+// pointers to the reply objects of the preceding steps).
+//
+// A check that does not hold returns a TryAgainError. This is exported so that a
+// client talking to a server without RpcCompound may perform the steps itself.
+func CheckCompoundStep(stepIndex int, checks []CompoundStepCheck, replies []interface{}) (err error) {
+	var (
+		check      CompoundStepCheck
+		replyField reflect.Value
+		replyValue int64
+	)
+
+	for _, check = range checks {
+		if (0 > check.Step) || (stepIndex <= check.Step) || (len(replies) <= check.Step) {
+			err = fmt.Errorf("RpcCompound() Steps[%d] checks Steps[%d] which does not precede it", stepIndex, check.Step)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+
+		replyField = reflect.ValueOf(replies[check.Step]).Elem().FieldByName(check.ReplyField)
+		if !replyField.IsValid() {
+			err = fmt.Errorf("RpcCompound() Steps[%d] checks unknown reply field \"%s\" of Steps[%d]", stepIndex, check.ReplyField, check.Step)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+
+		switch replyField.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			replyValue = replyField.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			replyValue = int64(replyField.Uint())
+		default:
+			err = fmt.Errorf("RpcCompound() Steps[%d] checks non-integer reply field \"%s\"", stepIndex, check.ReplyField)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+
+		if check.Value != replyValue {
+			err = fmt.Errorf("RpcCompound() Steps[%d] check of Steps[%d].%s failed: %d != %d", stepIndex, check.Step, check.ReplyField, replyValue, check.Value)
+			err = blunder.AddError(err, blunder.TryAgainError)
+			return
+		}
+	}
+
+	err = nil
+	return
+}
+
+// ApplyCompoundStepRefs overwrites the fields of request (a pointer to the request
+// object of Steps[stepIndex]) named by refs with those of replies (the pointers to
+// the reply objects of the preceding steps).
+//
+// This is exported so that a client talking to a server without RpcCompound may
+// perform the steps itself.
+func ApplyCompoundStepRefs(stepIndex int, refs []CompoundStepRef, request interface{}, replies []interface{}) (err error) {
+	var (
+		ref          CompoundStepRef
+		replyField   reflect.Value
+		requestField reflect.Value
+	)
+
+	for _, ref = range refs {
+		if (0 > ref.Step) || (stepIndex <= ref.Step) || (len(replies) <= ref.Step) {
+			err = fmt.Errorf("RpcCompound() Steps[%d] references Steps[%d] which does not precede it", stepIndex, ref.Step)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+
+		replyField = reflect.ValueOf(replies[ref.Step]).Elem().FieldByName(ref.ReplyField)
+		if !replyField.IsValid() {
+			err = fmt.Errorf("RpcCompound() Steps[%d] references unknown reply field \"%s\" of Steps[%d]", stepIndex, ref.ReplyField, ref.Step)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+
+		requestField = reflect.ValueOf(request).Elem().FieldByName(ref.RequestField)
+		if !requestField.IsValid() {
+			err = fmt.Errorf("RpcCompound() Steps[%d] references unknown request field \"%s\"", stepIndex, ref.RequestField)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+
+		switch requestField.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			switch replyField.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				requestField.SetInt(replyField.Int())
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				requestField.SetInt(int64(replyField.Uint()))
+			default:
+				err = fmt.Errorf("RpcCompound() Steps[%d] references non-integer reply field \"%s\"", stepIndex, ref.ReplyField)
+				err = blunder.AddError(err, blunder.InvalidArgError)
+				return
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			switch replyField.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				requestField.SetUint(uint64(replyField.Int()))
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				requestField.SetUint(replyField.Uint())
+			default:
+				err = fmt.Errorf("RpcCompound() Steps[%d] references non-integer reply field \"%s\"", stepIndex, ref.ReplyField)
+				err = blunder.AddError(err, blunder.InvalidArgError)
+				return
+			}
+		default:
+			err = fmt.Errorf("RpcCompound() Steps[%d] references non-integer request field \"%s\"", stepIndex, ref.RequestField)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+	}
+
+	err = nil
+	return
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package jrpcfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func TestRpcCompound(t *testing.T) {
+	var (
+		compoundReply             *CompoundReply
+		compoundRequest           *CompoundRequest
+		createReply               InodeReply
+		err                       error
+		getStatReply              StatStruct
+		jserver                   *Server
+		mkdirReply                InodeReply
+		mountByAccountNameReply   *MountByAccountNameReply
+		mountByAccountNameRequest *MountByAccountNameRequest
+		unmountReply              *Reply
+		unmountRequest            *UnmountRequest
+	)
+
+	jserver = NewServer()
+
+	mountByAccountNameRequest = &MountByAccountNameRequest{
+		AccountName: testAccountName,
+		AuthToken:   "",
+	}
+	mountByAccountNameReply = &MountByAccountNameReply{}
+
+	err = jserver.RpcMountByAccountName(testRpcLeaseLocalClientID, mountByAccountNameRequest, mountByAccountNameReply)
+	if nil != err {
+		t.Fatalf("jserver.RpcMountByAccountName() failed: %v", err)
+	}
+
+	marshal := func(request interface{}) (requestJSON json.RawMessage) {
+		requestJSON, err = json.Marshal(request)
+		if nil != err {
+			t.Fatalf("json.Marshal() failed: %v", err)
+		}
+		return
+	}
+
+	// Mkdir, then Create inside it, then GetStat the new file... all via Refs
+
+	compoundRequest = &CompoundRequest{
+		Steps: []CompoundStep{
+			{
+				Method: "RpcMkdir",
+				Request: marshal(&MkdirRequest{
+					InodeHandle: InodeHandle{
+						MountID:     mountByAccountNameReply.MountID,
+						InodeNumber: int64(inode.RootDirInodeNumber),
+					},
+					Basename: "TestRpcCompoundDir",
+					FileMode: 0755,
+				}),
+			},
+			{
+				Method: "RpcCreate",
+				Request: marshal(&CreateRequest{
+					InodeHandle: InodeHandle{
+						MountID: mountByAccountNameReply.MountID,
+					},
+					Basename: "TestRpcCompoundFile",
+					FileMode: 0644,
+				}),
+				Refs: []CompoundStepRef{{RequestField: "InodeNumber", Step: 0, ReplyField: "InodeNumber"}},
+			},
+			{
+				Method: "RpcGetStat",
+				Request: marshal(&GetStatRequest{
+					InodeHandle: InodeHandle{
+						MountID: mountByAccountNameReply.MountID,
+					},
+				}),
+				Refs: []CompoundStepRef{{RequestField: "InodeNumber", Step: 1, ReplyField: "InodeNumber"}},
+			},
+		},
+	}
+	compoundReply = &CompoundReply{}
+
+	err = jserver.RpcCompound(compoundRequest, compoundReply)
+	if nil != err {
+		t.Fatalf("jserver.RpcCompound() failed: %v", err)
+	}
+	if 3 != len(compoundReply.Replies) {
+		t.Fatalf("jserver.RpcCompound() returned %d Replies... expected 3", len(compoundReply.Replies))
+	}
+
+	err = json.Unmarshal(compoundReply.Replies[0], &mkdirReply)
+	if nil != err {
+		t.Fatalf("json.Unmarshal(Replies[0]) failed: %v", err)
+	}
+	err = json.Unmarshal(compoundReply.Replies[1], &createReply)
+	if nil != err {
+		t.Fatalf("json.Unmarshal(Replies[1]) failed: %v", err)
+	}
+	err = json.Unmarshal(compoundReply.Replies[2], &getStatReply)
+	if nil != err {
+		t.Fatalf("json.Unmarshal(Replies[2]) failed: %v", err)
+	}
+
+	if createReply.InodeNumber != getStatReply.StatInodeNumber {
+		t.Fatalf("RpcGetStat step returned StatInodeNumber %v... expected %v", getStatReply.StatInodeNumber, createReply.InodeNumber)
+	}
+	if mkdirReply.InodeNumber == createReply.InodeNumber {
+		t.Fatalf("RpcMkdir and RpcCreate steps returned the same InodeNumber")
+	}
+
+	// A failing step stops execution... the following Mkdir must not happen
+
+	compoundRequest = &CompoundRequest{
+		Steps: []CompoundStep{
+			{
+				Method: "RpcLookup",
+				Request: marshal(&LookupRequest{
+					InodeHandle: InodeHandle{
+						MountID:     mountByAccountNameReply.MountID,
+						InodeNumber: int64(inode.RootDirInodeNumber),
+					},
+					Basename: "TestRpcCompoundMissing",
+				}),
+			},
+			{
+				Method: "RpcMkdir",
+				Request: marshal(&MkdirRequest{
+					InodeHandle: InodeHandle{
+						MountID:     mountByAccountNameReply.MountID,
+						InodeNumber: int64(inode.RootDirInodeNumber),
+					},
+					Basename: "TestRpcCompoundNotCreated",
+					FileMode: 0755,
+				}),
+			},
+		},
+	}
+	compoundReply = &CompoundReply{}
+
+	err = jserver.RpcCompound(compoundRequest, compoundReply)
+	if nil == err {
+		t.Fatalf("jserver.RpcCompound() with failing RpcLookup step should have failed")
+	}
+	if 0 != len(compoundReply.Replies) {
+		t.Fatalf("jserver.RpcCompound() with failing first step returned %d Replies... expected 0", len(compoundReply.Replies))
+	}
+
+	err = jserver.RpcLookup(&LookupRequest{
+		InodeHandle: InodeHandle{
+			MountID:     mountByAccountNameReply.MountID,
+			InodeNumber: int64(inode.RootDirInodeNumber),
+		},
+		Basename: "TestRpcCompoundNotCreated",
+	}, &InodeReply{})
+	if nil == err {
+		t.Fatalf("RpcMkdir step following failed step should not have been performed")
+	}
+
+	// Lookup then Unlink with a Check of the looked up InodeNumber... a mismatch must
+	// fail with TryAgainError and leave the file in place
+
+	unlinkSteps := func(expectedInodeNumber int64) (steps []CompoundStep) {
+		steps = []CompoundStep{
+			{
+				Method: "RpcLookup",
+				Request: marshal(&LookupRequest{
+					InodeHandle: InodeHandle{
+						MountID:     mountByAccountNameReply.MountID,
+						InodeNumber: mkdirReply.InodeNumber,
+					},
+					Basename: "TestRpcCompoundFile",
+				}),
+			},
+			{
+				Method: "RpcUnlink",
+				Request: marshal(&UnlinkRequest{
+					InodeHandle: InodeHandle{
+						MountID:     mountByAccountNameReply.MountID,
+						InodeNumber: mkdirReply.InodeNumber,
+					},
+					Basename: "TestRpcCompoundFile",
+				}),
+				Checks: []CompoundStepCheck{{Step: 0, ReplyField: "InodeNumber", Value: expectedInodeNumber}},
+			},
+		}
+		return
+	}
+
+	err = jserver.RpcCompound(&CompoundRequest{Steps: unlinkSteps(mkdirReply.InodeNumber)}, &CompoundReply{})
+	if nil == err {
+		t.Fatalf("jserver.RpcCompound() with failing Check should have failed")
+	}
+	if fmt.Sprintf("errno: %d", blunder.TryAgainError) != err.Error() {
+		t.Fatalf("jserver.RpcCompound() with failing Check returned \"%v\"... expected TryAgainError", err)
+	}
+
+	err = jserver.RpcLookup(&LookupRequest{
+		InodeHandle: InodeHandle{
+			MountID:     mountByAccountNameReply.MountID,
+			InodeNumber: mkdirReply.InodeNumber,
+		},
+		Basename: "TestRpcCompoundFile",
+	}, &InodeReply{})
+	if nil != err {
+		t.Fatalf("RpcUnlink step following failed Check should not have been performed")
+	}
+
+	err = jserver.RpcCompound(&CompoundRequest{Steps: unlinkSteps(createReply.InodeNumber)}, &CompoundReply{})
+	if nil != err {
+		t.Fatalf("jserver.RpcCompound() with passing Check failed: %v", err)
+	}
+
+	err = jserver.RpcLookup(&LookupRequest{
+		InodeHandle: InodeHandle{
+			MountID:     mountByAccountNameReply.MountID,
+			InodeNumber: mkdirReply.InodeNumber,
+		},
+		Basename: "TestRpcCompoundFile",
+	}, &InodeReply{})
+	if nil == err {
+		t.Fatalf("RpcUnlink step following passing Check should have been performed")
+	}
+
+	// An empty CompoundRequest (i.e. a probe for RpcCompound support) succeeds
+
+	compoundReply = &CompoundReply{}
+
+	err = jserver.RpcCompound(&CompoundRequest{}, compoundReply)
+	if nil != err {
+		t.Fatalf("jserver.RpcCompound() with no Steps failed: %v", err)
+	}
+	if 0 != len(compoundReply.Replies) {
+		t.Fatalf("jserver.RpcCompound() with no Steps returned %d Replies... expected 0", len(compoundReply.Replies))
+	}
+
+	// Unsupported methods and forward Refs are rejected
+
+	compoundRequest = &CompoundRequest{
+		Steps: []CompoundStep{{Method: "RpcUnmount", Request: marshal(&UnmountRequest{MountID: mountByAccountNameReply.MountID})}},
+	}
+
+	err = jserver.RpcCompound(compoundRequest, &CompoundReply{})
+	if nil == err {
+		t.Fatalf("jserver.RpcCompound() with RpcUnmount step should have failed")
+	}
+
+	compoundRequest = &CompoundRequest{
+		Steps: []CompoundStep{
+			{
+				Method: "RpcGetStat",
+				Request: marshal(&GetStatRequest{
+					InodeHandle: InodeHandle{
+						MountID: mountByAccountNameReply.MountID,
+					},
+				}),
+				Refs: []CompoundStepRef{{RequestField: "InodeNumber", Step: 0, ReplyField: "InodeNumber"}},
+			},
+		},
+	}
+
+	err = jserver.RpcCompound(compoundRequest, &CompoundReply{})
+	if nil == err {
+		t.Fatalf("jserver.RpcCompound() with self-referencing step should have failed")
+	}
+
+	unmountRequest = &UnmountRequest{
+		MountID: mountByAccountNameReply.MountID,
+	}
+	unmountReply = &Reply{}
+
+	err = jserver.RpcUnmount(unmountRequest, unmountReply)
+	if nil != err {
+		t.Fatalf("jserver.RpcUnmount() failed: %v", err)
+	}
+}
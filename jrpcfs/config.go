@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -98,16 +99,32 @@ type mountStruct struct {
 	mountIDAsByteArray     MountIDAsByteArray
 	mountIDAsString        MountIDAsString
 	authToken              string
+	principal              string          // authenticated per volume.export.mountAuthentication ("" if mountAuthenticationNone)
+	boundIdentity          *identityStruct // if non-nil, replaces UserID/GroupID supplied in each request
 	retryRpcUniqueID       uint64
 	acceptingLeaseRequests bool                                      // also an indicator (when false) that mount is being unmounted
 	leaseRequestMap        map[inode.InodeNumber]*leaseRequestStruct // if     present, there is an ongoing Lease Request for this inode.InodeNumber
 	//                                                                  if not present, there is no ongoing Lease Request for this inode.InodeNumber
 }
 
+// findJobIdleTimeout is how long a Find job may go without an RpcFindFetch before being cancelled
+const findJobIdleTimeout = 10 * time.Minute
+
+// findJobReapInterval is how often Find jobs are checked against findJobIdleTimeout
+const findJobReapInterval = time.Minute
+
+type findJobStruct struct {
+	jobHandle       fs.FindJobHandle
+	mountIDAsString MountIDAsString // only this mount may fetch results from or cancel the Find job
+	lastUsed        time.Time       // updated upon each RpcFindFetch
+}
+
 type volumeStruct struct {
 	volumeName                      string
 	volumeHandle                    fs.VolumeHandle
+	export                          *exportStruct
 	acceptingMountsAndLeaseRequests bool
+	recallingAllLeases              bool // if true, Shared & Exclusive LeaseRequests are denied (see recallAllLeases())
 	delayedUnmountList              *list.List
 	mountMapByMountIDAsByteArray    map[MountIDAsByteArray]*mountStruct     // key == mountStruct.mountIDAsByteArray
 	mountMapByMountIDAsString       map[MountIDAsString]*mountStruct        // key == mountStruct.mountIDAsString
@@ -118,6 +135,7 @@ type volumeStruct struct {
 	activeLeaseEvictHighLimit       uint64                                  // trigger on inodeLease{Map|LRU}.Len() for evicting inodeLeaseStructs
 	leaseHandlerWG                  sync.WaitGroup                          // .Add(1) each inodeLease insertion into inodeLeaseMap
 	//                                                                         .Done() each inodeLease after it is removed from inodeLeaseMap
+	findJobMap map[uint64]*findJobStruct // key == find job ID returned by RpcFind
 }
 
 type globalsStruct struct {
@@ -127,32 +145,42 @@ type globalsStruct struct {
 	volumesLock sync.Mutex // protects mountMapByMountIDAsByteArray & mountMapByMountIDAsString
 	//                        as well as each volumeStruct/mountStruct map
 
-	whoAmI                  string
-	publicIPAddr            string
-	privateIPAddr           string
-	portString              string
-	fastPortString          string
-	retryRPCPort            uint16
-	retryRPCTTLCompleted    time.Duration
-	retryRPCAckTrim         time.Duration
-	retryRPCDeadlineIO      time.Duration
-	retryRPCKeepAlivePeriod time.Duration
-	retryRPCCertFilePath    string
-	retryRPCKeyFilePath     string
-	minLeaseDuration        time.Duration
-	leaseInterruptInterval  time.Duration
-	leaseInterruptLimit     uint32
-	dataPathLogging         bool
+	whoAmI                     string
+	publicIPAddr               string
+	privateIPAddr              string
+	portString                 string
+	fastPortString             string
+	retryRPCPort               uint16
+	retryRPCTTLCompleted       time.Duration
+	retryRPCAckTrim            time.Duration
+	retryRPCDeadlineIO         time.Duration
+	retryRPCKeepAlivePeriod    time.Duration
+	retryRPCCertFilePath       string
+	retryRPCKeyFilePath        string
+	retryRPCClientCAFilePath   string
+	authTokenValidationURL     string
+	authTokenValidationTimeout time.Duration
+	minLeaseDuration           time.Duration
+	leaseInterruptInterval     time.Duration
+	leaseInterruptLimit        uint32
+	dataPathLogging            bool
 
 	retryRPCCertPEM []byte
 	retryRPCKeyPEM  []byte
 
+	retryRPCClientCAPEM []byte
+
 	retryRPCCertificate tls.Certificate
 
 	volumeMap                    map[string]*volumeStruct            // key == volumeStruct.volumeName
 	mountMapByMountIDAsByteArray map[MountIDAsByteArray]*mountStruct // key == mountStruct.mountIDAsByteArray
 	mountMapByMountIDAsString    map[MountIDAsString]*mountStruct    // key == mountStruct.mountIDAsString
 
+	lastFindJobID uint64 // protected by volumesLock
+
+	findJobReaperStopChan chan struct{} // closed to stop findJobReaper()
+	findJobReaperWG       sync.WaitGroup
+
 	// RetryRPC server
 	retryrpcSvr *retryrpc.Server
 
@@ -251,6 +279,18 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 				logger.ErrorfWithError(err, "tls.LoadX509KeyPair(\"%s\", \"%s\") failed", globals.retryRPCCertFilePath, globals.retryRPCKeyFilePath)
 				return
 			}
+			globals.retryRPCClientCAFilePath, err = confMap.FetchOptionValueString("JSONRPCServer", "RetryRPCClientCAFilePath")
+			if (nil == err) && ("" != globals.retryRPCClientCAFilePath) {
+				globals.retryRPCClientCAPEM, err = ioutil.ReadFile(globals.retryRPCClientCAFilePath)
+				if nil != err {
+					logger.ErrorfWithError(err, "failed to load PEM-formatted [JSONRPCServer]RetryRPCClientCAFilePath [\"%s\"]", globals.retryRPCClientCAFilePath)
+					return
+				}
+			} else {
+				logger.Infof("failed to get JSONRPCServer.RetryRPCClientCAFilePath from config file - client certificates will not be requested")
+				globals.retryRPCClientCAFilePath = ""
+				globals.retryRPCClientCAPEM = nil
+			}
 		} else {
 			globals.retryRPCKeyFilePath, err = confMap.FetchOptionValueString("JSONRPCServer", "RetryRPCKeyFilePath")
 			if (nil == err) && ("" != globals.retryRPCKeyFilePath) {
@@ -263,6 +303,8 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 			globals.retryRPCCertPEM = nil
 			globals.retryRPCKeyPEM = nil
 			globals.retryRPCCertificate = tls.Certificate{}
+			globals.retryRPCClientCAFilePath = ""
+			globals.retryRPCClientCAPEM = nil
 		}
 	} else {
 		logger.Infof("failed to get JSONRPCServer.RetryRPCPort from config file - skipping......")
@@ -276,6 +318,8 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 		globals.retryRPCCertPEM = nil
 		globals.retryRPCKeyPEM = nil
 		globals.retryRPCCertificate = tls.Certificate{}
+		globals.retryRPCClientCAFilePath = ""
+		globals.retryRPCClientCAPEM = nil
 	}
 
 	// Set data path logging level to true, so that all trace logging is controlled by settings
@@ -304,6 +348,19 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 		globals.leaseInterruptLimit = 20
 	}
 
+	globals.authTokenValidationURL, err = confMap.FetchOptionValueString("JSONRPCServer", "AuthTokenValidationURL")
+	if nil == err {
+		globals.authTokenValidationURL = strings.TrimSuffix(globals.authTokenValidationURL, "/")
+	} else {
+		logger.Infof("failed to get JSONRPCServer.AuthTokenValidationURL from config file - defaulting to \"\"")
+		globals.authTokenValidationURL = ""
+	}
+	globals.authTokenValidationTimeout, err = confMap.FetchOptionValueDuration("JSONRPCServer", "AuthTokenValidationTimeout")
+	if nil != err {
+		logger.Infof("failed to get JSONRPCServer.AuthTokenValidationTimeout from config file - defaulting to 10s")
+		globals.authTokenValidationTimeout = 10 * time.Second
+	}
+
 	// Ensure gate starts out in the Exclusively Locked state
 	closeGate()
 
@@ -321,6 +378,11 @@ func (dummy *globalsStruct) Up(confMap conf.ConfMap) (err error) {
 	// Init Retry RPC server
 	retryRPCServerUp(jserver)
 
+	// Cancel Find jobs abandoned by their clients
+	globals.findJobReaperStopChan = make(chan struct{})
+	globals.findJobReaperWG.Add(1)
+	go findJobReaper()
+
 	err = nil
 	return
 }
@@ -347,6 +409,7 @@ func (dummy *globalsStruct) VolumeDestroyed(confMap conf.ConfMap, volumeName str
 func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string) (err error) {
 	var (
 		currentlyInVolumeMap bool
+		export               *exportStruct
 		volume               *volumeStruct
 		volumeHandle         fs.VolumeHandle
 	)
@@ -366,9 +429,16 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 		return
 	}
 
+	export, err = fetchExport(confMap, volumeName)
+	if nil != err {
+		globals.volumesLock.Unlock()
+		return
+	}
+
 	volume = &volumeStruct{
 		volumeName:                      volumeName,
 		volumeHandle:                    volumeHandle,
+		export:                          export,
 		acceptingMountsAndLeaseRequests: true,
 		delayedUnmountList:              list.New(),
 		mountMapByMountIDAsByteArray:    make(map[MountIDAsByteArray]*mountStruct),
@@ -376,6 +446,7 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 		inodeLeaseMap:                   make(map[inode.InodeNumber]*inodeLeaseStruct),
 		inodeLeaseLRU:                   list.New(),
 		ongoingLeaseEvictions:           0,
+		findJobMap:                      make(map[uint64]*findJobStruct),
 	}
 
 	volume.activeLeaseEvictLowLimit, err = confMap.FetchOptionValueUint64("Volume:"+volumeName, "ActiveLeaseEvictLowLimit")
@@ -393,6 +464,8 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 
 	globals.volumesLock.Unlock()
 
+	volumeHandle.RegisterForViewChanges(volume)
+
 	err = nil
 	return
 }
@@ -400,6 +473,8 @@ func (dummy *globalsStruct) ServeVolume(confMap conf.ConfMap, volumeName string)
 func (dummy *globalsStruct) UnserveVolume(confMap conf.ConfMap, volumeName string) (err error) {
 	var (
 		currentlyInVolumeMap bool
+		findJob              *findJobStruct
+		findJobMap           map[uint64]*findJobStruct
 		mountIDAsByteArray   MountIDAsByteArray
 		mountIDAsString      MountIDAsString
 		volume               *volumeStruct
@@ -431,8 +506,17 @@ func (dummy *globalsStruct) UnserveVolume(confMap conf.ConfMap, volumeName strin
 		delete(globals.mountMapByMountIDAsString, mountIDAsString)
 	}
 
+	findJobMap = volume.findJobMap
+	volume.findJobMap = make(map[uint64]*findJobStruct)
+
 	globals.volumesLock.Unlock()
 
+	volume.volumeHandle.UnregisterForViewChanges(volume)
+
+	for _, findJob = range findJobMap {
+		findJob.jobHandle.Cancel()
+	}
+
 	err = nil
 	return
 }
@@ -471,6 +555,9 @@ func (dummy *globalsStruct) Down(confMap conf.ConfMap) (err error) {
 
 	globals.halting = true
 
+	close(globals.findJobReaperStopChan)
+	globals.findJobReaperWG.Wait()
+
 	jsonRpcServerDown()
 	ioServerDown()
 	retryRPCServerDown()
@@ -210,7 +210,9 @@ var saveChannelSize int = 1000
 // Default values here are false
 var loggedOutOfStatsRoom map[OpType]bool = make(map[OpType]bool)
 
-func lookupVolumeHandleByMountIDAsByteArray(mountIDAsByteArray MountIDAsByteArray) (volumeHandle fs.VolumeHandle, err error) {
+// lookupVolumeHandleAndCredentialsByMountIDAsByteArray also returns the identity (see
+// mountStruct.callerCredentials()) under which a request on the mount is to be performed.
+func lookupVolumeHandleAndCredentialsByMountIDAsByteArray(mountIDAsByteArray MountIDAsByteArray) (volumeHandle fs.VolumeHandle, userID inode.InodeUserID, groupID inode.InodeGroupID, err error) {
 	var (
 		mount *mountStruct
 		ok    bool
@@ -252,6 +254,39 @@ func lookupVolumeHandleByMountIDAsString(mountIDAsString MountIDAsString) (volum
 	return
 }
 
+// lookupVolumeHandleAndCredentialsByMountIDAsString is lookupVolumeHandleByMountIDAsString() for
+// requests that carry no caller identity of their own.
+func lookupVolumeHandleAndCredentialsByMountIDAsString(mountIDAsString MountIDAsString) (volumeHandle fs.VolumeHandle, userID inode.InodeUserID, groupID inode.InodeGroupID, err error) {
+	var (
+		mount *mountStruct
+	)
+
+	mount, err = lookupMountByMountIDAsString(mountIDAsString)
+	if nil == err {
+		volumeHandle = mount.volume.volumeHandle
+		userID, groupID = mount.callerCredentials()
+	}
+
+	return
+}
+
+func lookupMountByMountIDAsString(mountIDAsString MountIDAsString) (mount *mountStruct, err error) {
+	var (
+		ok bool
+	)
+
+	globals.volumesLock.Lock()
+	mount, ok = globals.mountMapByMountIDAsString[mountIDAsString]
+	globals.volumesLock.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("MountID %s not found in jrpcfs globals.mountMapByMountIDAsString", mountIDAsString)
+		err = blunder.AddError(err, blunder.BadMountIDError)
+	}
+
+	return
+}
+
 func NewServer() *Server {
 	s := Server{}
 
@@ -616,22 +651,30 @@ func (s *Server) RpcChown(in *ChownRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	mount, err := lookupMountByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
+	volumeHandle := mount.volume.volumeHandle
+
+	userID, groupID, err := mount.mapOwner(in.UserID, in.GroupID)
+	if nil != err {
+		return
+	}
+
+	callerUserID, callerGroupID := mount.callerCredentials()
 
 	// NOTE: We currently just store and return per-inode ownership info.
 	//       We do not check/enforce it; that is the caller's responsibility.
 
 	stat := make(fs.Stat)
-	if in.UserID != -1 {
-		stat[fs.StatUserID] = uint64(in.UserID)
+	if userID != -1 {
+		stat[fs.StatUserID] = uint64(userID)
 	}
-	if in.GroupID != -1 {
-		stat[fs.StatGroupID] = uint64(in.GroupID)
+	if groupID != -1 {
+		stat[fs.StatGroupID] = uint64(groupID)
 	}
-	err = volumeHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), stat)
+	err = volumeHandle.Setstat(callerUserID, callerGroupID, nil, inode.InodeNumber(in.InodeNumber), stat)
 	return
 }
 
@@ -643,29 +686,37 @@ func (s *Server) RpcChownPath(in *ChownPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	mount, err := lookupMountByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+	volumeHandle := mount.volume.volumeHandle
+
+	userID, groupID, err := mount.mapOwner(in.UserID, in.GroupID)
 	if nil != err {
 		return
 	}
 
+	callerUserID, callerGroupID := mount.callerCredentials()
+
 	// NOTE: We currently just store and return per-inode ownership info.
 	//       We do not check/enforce it; that is the caller's responsibility.
 
 	// Get the inode
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(callerUserID, callerGroupID, nil, in.Fullpath)
 	if err != nil {
 		return
 	}
 
 	// Do the Setstat
 	stat := make(fs.Stat)
-	if in.UserID != -1 {
-		stat[fs.StatUserID] = uint64(in.UserID)
+	if userID != -1 {
+		stat[fs.StatUserID] = uint64(userID)
 	}
-	if in.GroupID != -1 {
-		stat[fs.StatGroupID] = uint64(in.GroupID)
+	if groupID != -1 {
+		stat[fs.StatGroupID] = uint64(groupID)
 	}
-	err = volumeHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, stat)
+	err = volumeHandle.Setstat(callerUserID, callerGroupID, nil, ino, stat)
 	return
 }
 
@@ -680,7 +731,7 @@ func (s *Server) RpcChmod(in *ChmodRequest, reply *Reply) (err error) {
 	// NOTE: We currently just store and return per-inode ownership info.
 	//       We do not check/enforce it; that is the caller's responsibility.
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
@@ -689,7 +740,7 @@ func (s *Server) RpcChmod(in *ChmodRequest, reply *Reply) (err error) {
 	// bits can be changed by SetStat().
 	stat := make(fs.Stat)
 	stat[fs.StatMode] = uint64(in.FileMode) & 07777
-	err = volumeHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), stat)
+	err = volumeHandle.Setstat(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), stat)
 	return
 }
 
@@ -701,7 +752,7 @@ func (s *Server) RpcChmodPath(in *ChmodPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
@@ -710,7 +761,7 @@ func (s *Server) RpcChmodPath(in *ChmodPathRequest, reply *Reply) (err error) {
 	//       We do not check/enforce it; that is the caller's responsibility.
 
 	// Get the inode
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, in.Fullpath)
 	if err != nil {
 		return
 	}
@@ -721,7 +772,7 @@ func (s *Server) RpcChmodPath(in *ChmodPathRequest, reply *Reply) (err error) {
 	// bits can be changed by SetStat().
 	stat := make(fs.Stat)
 	stat[fs.StatMode] = uint64(in.FileMode) & 07777
-	err = volumeHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, stat)
+	err = volumeHandle.Setstat(userID, groupID, nil, ino, stat)
 	return
 }
 
@@ -733,12 +784,15 @@ func (s *Server) RpcCreate(in *CreateRequest, reply *InodeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	mount, err := lookupMountByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
+	volumeHandle := mount.volume.volumeHandle
+
+	userID, groupID := mount.mapCredentials(in.UserID, in.GroupID)
 
-	fino, err := volumeHandle.Create(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeMode(in.FileMode))
+	fino, err := volumeHandle.Create(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeMode(in.FileMode))
 	reply.InodeNumber = int64(uint64(fino))
 	return
 }
@@ -751,10 +805,13 @@ func (s *Server) RpcCreatePath(in *CreatePathRequest, reply *InodeReply) (err er
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	mount, err := lookupMountByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
+	volumeHandle := mount.volume.volumeHandle
+
+	userID, groupID := mount.mapCredentials(in.UserID, in.GroupID)
 
 	// Ideally we would like all name/fullpath checking logic to be in the fs package,
 	// however since the fs.Create() and fs.Mkdir() APIs are inode-based, once we are
@@ -769,13 +826,13 @@ func (s *Server) RpcCreatePath(in *CreatePathRequest, reply *InodeReply) (err er
 	parentDir, basename := splitPath(in.Fullpath)
 
 	// Get the inode for the parent dir
-	ino, err := volumeHandle.LookupPath(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, parentDir)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, parentDir)
 	if err != nil {
 		return
 	}
 
 	// Do the create
-	fino, err := volumeHandle.Create(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, ino, basename, inode.InodeMode(in.FileMode))
+	fino, err := volumeHandle.Create(userID, groupID, nil, ino, basename, inode.InodeMode(in.FileMode))
 	reply.InodeNumber = int64(uint64(fino))
 	return
 }
@@ -788,7 +845,7 @@ func (s *Server) RpcFlock(in *FlockRequest, reply *FlockReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
@@ -800,7 +857,7 @@ func (s *Server) RpcFlock(in *FlockRequest, reply *FlockReply) (err error) {
 	flock.Len = in.FlockLen
 	flock.Pid = in.FlockPid
 
-	lockStruct, err := volumeHandle.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.FlockCmd, &flock)
+	lockStruct, err := volumeHandle.Flock(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.FlockCmd, &flock)
 	if lockStruct != nil {
 		reply.FlockType = lockStruct.Type
 		reply.FlockWhence = lockStruct.Whence
@@ -843,9 +900,9 @@ func (s *Server) RpcWrote(in *WroteRequest, reply *WroteReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil == err {
-		err = volumeHandle.Wrote(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.ContainerName, in.ObjectName, in.FileOffset, in.ObjectOffset, in.Length, in.WroteTimeNs)
+		err = volumeHandle.Wrote(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.ContainerName, in.ObjectName, in.FileOffset, in.ObjectOffset, in.Length, in.WroteTimeNs)
 	}
 
 	return
@@ -863,9 +920,9 @@ func (s *Server) RpcFetchExtentMapChunk(in *FetchExtentMapChunkRequest, reply *F
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil == err {
-		extentMapChunk, err = volumeHandle.FetchExtentMapChunk(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.FileOffset, in.MaxEntriesFromFileOffset, in.MaxEntriesBeforeFileOffset)
+		extentMapChunk, err = volumeHandle.FetchExtentMapChunk(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.FileOffset, in.MaxEntriesFromFileOffset, in.MaxEntriesBeforeFileOffset)
 		if nil == err {
 			reply.FileOffsetRangeStart = extentMapChunk.FileOffsetRangeStart
 			reply.FileOffsetRangeEnd = extentMapChunk.FileOffsetRangeEnd
@@ -903,9 +960,9 @@ func (s *Server) RpcFlush(in *FlushRequest, reply *Reply) (err error) {
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
 	profiler.AddEventNow("before fs.Flush()")
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil == err {
-		err = volumeHandle.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
+		err = volumeHandle.Flush(userID, groupID, nil, inode.InodeNumber(in.InodeNumber))
 	}
 	profiler.AddEventNow("after fs.Flush()")
 
@@ -948,9 +1005,9 @@ func (s *Server) RpcGetStat(in *GetStatRequest, reply *StatStruct) (err error) {
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
 	profiler.AddEventNow("before fs.Getstat()")
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil == err {
-		stat, err = volumeHandle.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
+		stat, err = volumeHandle.Getstat(userID, groupID, nil, inode.InodeNumber(in.InodeNumber))
 	}
 	profiler.AddEventNow("after fs.Getstat()")
 	if err == nil {
@@ -974,14 +1031,14 @@ func (s *Server) RpcGetStatPath(in *GetStatPathRequest, reply *StatStruct) (err
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
 	// Get the inode
 	profiler.AddEventNow("before fs.LookupPath()")
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, in.Fullpath)
 	profiler.AddEventNow("after fs.LookupPath()")
 	if err != nil {
 		// Save profiler with server op stats
@@ -992,7 +1049,7 @@ func (s *Server) RpcGetStatPath(in *GetStatPathRequest, reply *StatStruct) (err
 
 	// Do the GetStat
 	profiler.AddEventNow("before fs.Getstat()")
-	stat, err := volumeHandle.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ino))
+	stat, err := volumeHandle.Getstat(userID, groupID, nil, inode.InodeNumber(ino))
 	profiler.AddEventNow("after fs.Getstat()")
 	if err == nil {
 		reply.fsStatToStatStruct(stat)
@@ -1005,6 +1062,33 @@ func (s *Server) RpcGetStatPath(in *GetStatPathRequest, reply *StatStruct) (err
 	return
 }
 
+func (s *Server) RpcGetWORM(in *GetWORMRequest, reply *GetWORMReply) (err error) {
+	enterGate()
+	defer leaveGate()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+
+	wormState, err := volumeHandle.GetWORMState(userID, groupID, nil, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+
+	reply.Immutable = wormState.Immutable
+	reply.AppendOnly = wormState.AppendOnly
+	if !wormState.RetainUntil.IsZero() {
+		reply.RetainUntilNs = wormState.RetainUntil.UnixNano()
+	}
+
+	return
+}
+
 func (s *Server) RpcGetXAttr(in *GetXAttrRequest, reply *GetXAttrReply) (err error) {
 	var profiler = utils.NewProfilerIf(doProfiling, "getxattr")
 
@@ -1016,9 +1100,9 @@ func (s *Server) RpcGetXAttr(in *GetXAttrRequest, reply *GetXAttrReply) (err err
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
 	profiler.AddEventNow("before fs.GetXAttr()")
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil == err {
-		reply.AttrValue, err = volumeHandle.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.AttrName)
+		reply.AttrValue, err = volumeHandle.GetXAttr(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.AttrName)
 	}
 	profiler.AddEventNow("after fs.GetXAttr()")
 
@@ -1039,13 +1123,13 @@ func (s *Server) RpcGetXAttrPath(in *GetXAttrPathRequest, reply *GetXAttrReply)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
 	profiler.AddEventNow("before fs.LookupPath()")
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, in.Fullpath)
 	profiler.AddEventNow("after fs.LookupPath()")
 	if err != nil {
 		// Save profiler with server op stats
@@ -1055,7 +1139,7 @@ func (s *Server) RpcGetXAttrPath(in *GetXAttrPathRequest, reply *GetXAttrReply)
 	}
 
 	profiler.AddEventNow("before fs.GetXAttr()")
-	reply.AttrValue, err = volumeHandle.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ino), in.AttrName)
+	reply.AttrValue, err = volumeHandle.GetXAttr(userID, groupID, nil, inode.InodeNumber(ino), in.AttrName)
 	profiler.AddEventNow("after fs.GetXAttr()")
 	if err == nil {
 		reply.AttrValueSize = uint64(len(reply.AttrValue))
@@ -1086,13 +1170,13 @@ func (s *Server) RpcLookupPath(in *LookupPathRequest, reply *InodeReply) (err er
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
 	profiler.AddEventNow("before fs.LookupPath()")
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, in.Fullpath)
 	profiler.AddEventNow("after fs.LookupPath()")
 	if err == nil {
 		reply.InodeNumber = int64(uint64(ino))
@@ -1113,12 +1197,12 @@ func (s *Server) RpcLink(in *LinkRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	err = volumeHandle.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeNumber(in.TargetInodeNumber))
+	err = volumeHandle.Link(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeNumber(in.TargetInodeNumber))
 	return
 }
 
@@ -1130,7 +1214,7 @@ func (s *Server) RpcLinkPath(in *LinkPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
@@ -1139,19 +1223,19 @@ func (s *Server) RpcLinkPath(in *LinkPathRequest, reply *Reply) (err error) {
 	parentDir, basename := splitPath(in.Fullpath)
 
 	// Get the inode for the (source) parent dir
-	srcIno, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, parentDir)
+	srcIno, err := volumeHandle.LookupPath(userID, groupID, nil, parentDir)
 	if err != nil {
 		return
 	}
 
 	// Get the inode for the target
-	tgtIno, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.TargetFullpath)
+	tgtIno, err := volumeHandle.LookupPath(userID, groupID, nil, in.TargetFullpath)
 	if err != nil {
 		return
 	}
 
 	// Do the link
-	err = volumeHandle.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcIno, basename, tgtIno)
+	err = volumeHandle.Link(userID, groupID, nil, srcIno, basename, tgtIno)
 	return
 }
 
@@ -1163,12 +1247,12 @@ func (s *Server) RpcListXAttr(in *ListXAttrRequest, reply *ListXAttrReply) (err
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	reply.AttrNames, err = volumeHandle.ListXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
+	reply.AttrNames, err = volumeHandle.ListXAttr(userID, groupID, nil, inode.InodeNumber(in.InodeNumber))
 	return
 }
 
@@ -1180,17 +1264,17 @@ func (s *Server) RpcListXAttrPath(in *ListXAttrPathRequest, reply *ListXAttrRepl
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, in.Fullpath)
 	if err != nil {
 		return
 	}
 
-	reply.AttrNames, err = volumeHandle.ListXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ino))
+	reply.AttrNames, err = volumeHandle.ListXAttr(userID, groupID, nil, inode.InodeNumber(ino))
 	if err != nil {
 		return
 	}
@@ -1208,13 +1292,13 @@ func (s *Server) RpcLookup(in *LookupRequest, reply *InodeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
 	profiler.AddEventNow("before fs.Lookup()")
-	ino, err := volumeHandle.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename)
+	ino, err := volumeHandle.Lookup(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Basename)
 	profiler.AddEventNow("after fs.Lookup()")
 	// line below is for testing fault injection
 	//err = blunder.AddError(err, blunder.TryAgainError)
@@ -1237,17 +1321,17 @@ func (s *Server) RpcLookupPlus(in *LookupPlusRequest, reply *LookupPlusReply) (e
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	ino, err := volumeHandle.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename)
+	ino, err := volumeHandle.Lookup(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Basename)
 	if nil != err {
 		return
 	}
 
-	stat, err := volumeHandle.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino)
+	stat, err := volumeHandle.Getstat(userID, groupID, nil, ino)
 	if nil != err {
 		return
 	}
@@ -1266,12 +1350,15 @@ func (s *Server) RpcAccess(in *AccessRequest, reply *InodeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	mount, err := lookupMountByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
+	volumeHandle := mount.volume.volumeHandle
+
+	userID, groupID := mount.mapCredentials(in.UserID, in.GroupID)
 
-	ok := volumeHandle.Access(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), inode.InodeMode(in.AccessMode))
+	ok := volumeHandle.Access(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), inode.InodeMode(in.AccessMode))
 	if ok {
 		err = nil
 	} else {
@@ -1289,12 +1376,15 @@ func (s *Server) RpcMkdir(in *MkdirRequest, reply *InodeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	mount, err := lookupMountByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
+	volumeHandle := mount.volume.volumeHandle
 
-	ino, err := volumeHandle.Mkdir(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeMode(in.FileMode))
+	userID, groupID := mount.mapCredentials(in.UserID, in.GroupID)
+
+	ino, err := volumeHandle.Mkdir(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeMode(in.FileMode))
 	reply.InodeNumber = int64(uint64(ino))
 	return
 }
@@ -1307,10 +1397,13 @@ func (s *Server) RpcMkdirPath(in *MkdirPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	mount, err := lookupMountByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
+	volumeHandle := mount.volume.volumeHandle
+
+	userID, groupID := mount.mapCredentials(in.UserID, in.GroupID)
 
 	// Ideally we would like all name/fullpath checking logic to be in the fs package,
 	// however since the fs.Create() and fs.Mkdir() APIs are inode-based, once we are
@@ -1325,30 +1418,56 @@ func (s *Server) RpcMkdirPath(in *MkdirPathRequest, reply *Reply) (err error) {
 	parentDir, basename := splitPath(in.Fullpath)
 
 	// Get the inode for the parent dir
-	ino, err := volumeHandle.LookupPath(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, parentDir)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, parentDir)
 	if err != nil {
 		return
 	}
 
 	// Do the mkdir
-	_, err = volumeHandle.Mkdir(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, ino, basename, inode.InodeMode(in.FileMode))
+	_, err = volumeHandle.Mkdir(userID, groupID, nil, ino, basename, inode.InodeMode(in.FileMode))
 	return
 }
 
 func performMount(volumeHandle fs.VolumeHandle, authToken string, clientID uint64) (mountIDAsByteArray MountIDAsByteArray, mountIDAsString MountIDAsString, err error) {
 	var (
+		boundIdentity *identityStruct
 		i             int
+		identity      identityStruct
 		keepTrying    bool
 		mount         *mountStruct
 		ok            bool
+		principal     string
 		randByteSlice []byte
 		volume        *volumeStruct
 		volumeName    string
 	)
 
+	volumeName = volumeHandle.VolumeName()
+
+	// Authenticate before taking volumesLock as it may require a round-trip to Swift
+
 	globals.volumesLock.Lock()
+	volume, ok = globals.volumeMap[volumeName]
+	globals.volumesLock.Unlock()
 
-	volumeName = volumeHandle.VolumeName()
+	if !ok {
+		err = fmt.Errorf("performMount(volumeHandle.VolumeName==\"%s\") cannot be found in globals.volumeMap", volumeName)
+		return
+	}
+
+	principal, err = authenticateMount(volume.export, authToken, clientID)
+	if nil != err {
+		return
+	}
+
+	if "" != principal {
+		identity, ok = volume.export.principalMap[principal]
+		if ok {
+			boundIdentity = &identity
+		}
+	}
+
+	globals.volumesLock.Lock()
 
 	volume, ok = globals.volumeMap[volumeName]
 	if !ok {
@@ -1384,6 +1503,8 @@ func performMount(volumeHandle fs.VolumeHandle, authToken string, clientID uint6
 		mountIDAsByteArray:     mountIDAsByteArray,
 		mountIDAsString:        mountIDAsString,
 		authToken:              authToken,
+		principal:              principal,
+		boundIdentity:          boundIdentity,
 		retryRpcUniqueID:       clientID,
 		acceptingLeaseRequests: true,
 		leaseRequestMap:        make(map[inode.InodeNumber]*leaseRequestStruct),
@@ -1502,6 +1623,7 @@ func (s *Server) rpcReaddirInternal(in interface{}, reply *ReaddirReply, profile
 	var (
 		dirEnts      []inode.DirEntry
 		flog         logger.FuncCtx
+		groupID      inode.InodeGroupID
 		i            int
 		iH           InodeHandle
 		inByLoc      *ReaddirByLocRequest
@@ -1509,6 +1631,7 @@ func (s *Server) rpcReaddirInternal(in interface{}, reply *ReaddirReply, profile
 		maxEntries   uint64
 		okByName     bool
 		prevMarker   interface{}
+		userID       inode.InodeUserID
 		volumeHandle fs.VolumeHandle
 	)
 
@@ -1532,13 +1655,13 @@ func (s *Server) rpcReaddirInternal(in interface{}, reply *ReaddirReply, profile
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err = lookupVolumeHandleByMountIDAsString(iH.MountID)
+	volumeHandle, userID, groupID, err = lookupVolumeHandleAndCredentialsByMountIDAsString(iH.MountID)
 	if nil != err {
 		return
 	}
 
 	profiler.AddEventNow("before fs.Readdir()")
-	dirEnts, _, _, err = volumeHandle.Readdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(iH.InodeNumber), maxEntries, prevMarker)
+	dirEnts, _, _, err = volumeHandle.Readdir(userID, groupID, nil, inode.InodeNumber(iH.InodeNumber), maxEntries, prevMarker)
 	profiler.AddEventNow("after fs.Readdir()")
 
 	if nil == err {
@@ -1573,6 +1696,7 @@ func (s *Server) rpcReaddirPlusInternal(in interface{}, reply *ReaddirPlusReply,
 	var (
 		dirEnts      []inode.DirEntry
 		flog         logger.FuncCtx
+		groupID      inode.InodeGroupID
 		i            int
 		iH           InodeHandle
 		inByLoc      *ReaddirPlusByLocRequest
@@ -1580,6 +1704,7 @@ func (s *Server) rpcReaddirPlusInternal(in interface{}, reply *ReaddirPlusReply,
 		maxEntries   uint64
 		okByName     bool
 		prevMarker   interface{}
+		userID       inode.InodeUserID
 		statEnts     []fs.Stat
 		volumeHandle fs.VolumeHandle
 	)
@@ -1604,13 +1729,13 @@ func (s *Server) rpcReaddirPlusInternal(in interface{}, reply *ReaddirPlusReply,
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err = lookupVolumeHandleByMountIDAsString(iH.MountID)
+	volumeHandle, userID, groupID, err = lookupVolumeHandleAndCredentialsByMountIDAsString(iH.MountID)
 	if err != nil {
 		return
 	}
 
 	profiler.AddEventNow("before fs.ReaddirPlus()")
-	dirEnts, statEnts, _, _, err = volumeHandle.ReaddirPlus(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(iH.InodeNumber), maxEntries, prevMarker)
+	dirEnts, statEnts, _, _, err = volumeHandle.ReaddirPlus(userID, groupID, nil, inode.InodeNumber(iH.InodeNumber), maxEntries, prevMarker)
 	profiler.AddEventNow("after fs.ReaddirPlus()")
 
 	if nil == err {
@@ -1633,12 +1758,12 @@ func (s *Server) RpcReadSymlink(in *ReadSymlinkRequest, reply *ReadSymlinkReply)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	target, err := volumeHandle.Readsymlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
+	target, err := volumeHandle.Readsymlink(userID, groupID, nil, inode.InodeNumber(in.InodeNumber))
 	reply.Target = target
 	return
 }
@@ -1651,18 +1776,18 @@ func (s *Server) RpcReadSymlinkPath(in *ReadSymlinkPathRequest, reply *ReadSymli
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
 	// Get the inode
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, in.Fullpath)
 	if err != nil {
 		return
 	}
 
-	target, err := volumeHandle.Readsymlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino)
+	target, err := volumeHandle.Readsymlink(userID, groupID, nil, ino)
 	reply.Target = target
 	return
 }
@@ -1675,12 +1800,12 @@ func (s *Server) RpcRemoveXAttr(in *RemoveXAttrRequest, reply *Reply) (err error
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	err = volumeHandle.RemoveXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.AttrName)
+	err = volumeHandle.RemoveXAttr(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.AttrName)
 	return
 }
 
@@ -1692,17 +1817,17 @@ func (s *Server) RpcRemoveXAttrPath(in *RemoveXAttrPathRequest, reply *Reply) (e
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, in.Fullpath)
 	if err != nil {
 		return
 	}
 
-	err = volumeHandle.RemoveXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ino), in.AttrName)
+	err = volumeHandle.RemoveXAttr(userID, groupID, nil, inode.InodeNumber(ino), in.AttrName)
 	return
 }
 
@@ -1714,12 +1839,12 @@ func (s *Server) RpcRename(in *RenameRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	err = volumeHandle.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.SrcDirInodeNumber), in.SrcBasename, inode.InodeNumber(in.DstDirInodeNumber), in.DstBasename)
+	err = volumeHandle.Rename(userID, groupID, nil, inode.InodeNumber(in.SrcDirInodeNumber), in.SrcBasename, inode.InodeNumber(in.DstDirInodeNumber), in.DstBasename, inode.MoveFlagsNone)
 	return
 }
 
@@ -1731,7 +1856,7 @@ func (s *Server) RpcRenamePath(in *RenamePathRequest, reply *Reply) (err error)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
@@ -1740,7 +1865,7 @@ func (s *Server) RpcRenamePath(in *RenamePathRequest, reply *Reply) (err error)
 	srcParentDir, srcBasename := splitPath(in.Fullpath)
 
 	// Get the inode for the (source) parent dir
-	srcIno, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcParentDir)
+	srcIno, err := volumeHandle.LookupPath(userID, groupID, nil, srcParentDir)
 	if err != nil {
 		return
 	}
@@ -1749,13 +1874,13 @@ func (s *Server) RpcRenamePath(in *RenamePathRequest, reply *Reply) (err error)
 	dstParentDir, dstBasename := splitPath(in.DstFullpath)
 
 	// Get the inode for the dest parent dir
-	dstIno, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dstParentDir)
+	dstIno, err := volumeHandle.LookupPath(userID, groupID, nil, dstParentDir)
 	if err != nil {
 		return
 	}
 
 	// Do the rename
-	err = volumeHandle.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcIno, srcBasename, dstIno, dstBasename)
+	err = volumeHandle.Rename(userID, groupID, nil, srcIno, srcBasename, dstIno, dstBasename, inode.MoveFlagsNone)
 	return
 }
 
@@ -1771,12 +1896,12 @@ func (s *Server) RpcMove(in *MoveRequest, reply *MoveReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	toDestroyInodeNumber, err = volumeHandle.Move(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.SrcDirInodeNumber), in.SrcBasename, inode.InodeNumber(in.DstDirInodeNumber), in.DstBasename)
+	toDestroyInodeNumber, err = volumeHandle.Move(userID, groupID, nil, inode.InodeNumber(in.SrcDirInodeNumber), in.SrcBasename, inode.InodeNumber(in.DstDirInodeNumber), in.DstBasename, inode.MoveFlags(in.Flags))
 	reply.ToDestroyInodeNumber = int64(toDestroyInodeNumber)
 	return
 }
@@ -1789,12 +1914,12 @@ func (s *Server) RpcDestroy(in *DestroyRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	err = volumeHandle.Destroy(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
+	err = volumeHandle.Destroy(userID, groupID, nil, inode.InodeNumber(in.InodeNumber))
 	return
 }
 
@@ -1806,12 +1931,12 @@ func (s *Server) RpcResize(in *ResizeRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	err = volumeHandle.Resize(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.NewSize)
+	err = volumeHandle.Resize(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.NewSize)
 	return
 }
 
@@ -1823,12 +1948,12 @@ func (s *Server) RpcRmdir(in *UnlinkRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	err = volumeHandle.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename)
+	err = volumeHandle.Rmdir(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Basename)
 	return
 }
 
@@ -1840,7 +1965,7 @@ func (s *Server) RpcRmdirPath(in *UnlinkPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
@@ -1849,13 +1974,13 @@ func (s *Server) RpcRmdirPath(in *UnlinkPathRequest, reply *Reply) (err error) {
 	parentDir, basename := splitPath(in.Fullpath)
 
 	// Get the inode for the parent dir
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, parentDir)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, parentDir)
 	if err != nil {
 		return
 	}
 
 	// Do the rmdir
-	err = volumeHandle.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, basename)
+	err = volumeHandle.Rmdir(userID, groupID, nil, ino, basename)
 	return
 }
 
@@ -1867,7 +1992,7 @@ func (s *Server) RpcSetstat(in *SetstatRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
@@ -1880,7 +2005,7 @@ func (s *Server) RpcSetstat(in *SetstatRequest, reply *Reply) (err error) {
 	stat[fs.StatSize] = in.Size
 	stat[fs.StatNLink] = in.NumLinks
 	// TODO: add in mode/userid/groupid?
-	err = volumeHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), stat)
+	err = volumeHandle.Setstat(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), stat)
 	return
 }
 
@@ -1892,7 +2017,7 @@ func (s *Server) RpcSetTime(in *SetTimeRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
@@ -1906,7 +2031,7 @@ func (s *Server) RpcSetTime(in *SetTimeRequest, reply *Reply) (err error) {
 		stat[fs.StatATime] = in.ATimeNs
 	}
 
-	err = volumeHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), stat)
+	err = volumeHandle.Setstat(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), stat)
 
 	return
 }
@@ -1919,13 +2044,13 @@ func (s *Server) RpcSetTimePath(in *SetTimePathRequest, reply *Reply) (err error
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
 	// Get the inode
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, in.Fullpath)
 	if err != nil {
 		return
 	}
@@ -1939,8 +2064,33 @@ func (s *Server) RpcSetTimePath(in *SetTimePathRequest, reply *Reply) (err error
 		stat[fs.StatATime] = in.ATimeNs
 	}
 
-	err = volumeHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, stat)
+	err = volumeHandle.Setstat(userID, groupID, nil, ino, stat)
+
+	return
+}
+
+func (s *Server) RpcSetWORM(in *SetWORMRequest, reply *Reply) (err error) {
+	enterGate()
+	defer leaveGate()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+
+	wormState := inode.WORMStateStruct{
+		Immutable:  in.Immutable,
+		AppendOnly: in.AppendOnly,
+	}
+	if 0 != in.RetainUntilNs {
+		wormState.RetainUntil = time.Unix(0, in.RetainUntilNs)
+	}
 
+	err = volumeHandle.SetWORMState(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), wormState)
 	return
 }
 
@@ -1952,12 +2102,12 @@ func (s *Server) RpcSetXAttr(in *SetXAttrRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	err = volumeHandle.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.AttrName, in.AttrValue, in.AttrFlags)
+	err = volumeHandle.SetXAttr(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.AttrName, in.AttrValue, in.AttrFlags)
 	return
 }
 
@@ -1969,17 +2119,17 @@ func (s *Server) RpcSetXAttrPath(in *SetXAttrPathRequest, reply *Reply) (err err
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, in.Fullpath)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, in.Fullpath)
 	if err != nil {
 		return
 	}
 
-	err = volumeHandle.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ino), in.AttrName, in.AttrValue, in.AttrFlags)
+	err = volumeHandle.SetXAttr(userID, groupID, nil, inode.InodeNumber(ino), in.AttrName, in.AttrValue, in.AttrFlags)
 	return
 }
 
@@ -2025,12 +2175,15 @@ func (s *Server) RpcSymlink(in *SymlinkRequest, reply *InodeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	mount, err := lookupMountByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
+	volumeHandle := mount.volume.volumeHandle
 
-	ino, err := volumeHandle.Symlink(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), in.Basename, in.Target)
+	userID, groupID := mount.mapCredentials(in.UserID, in.GroupID)
+
+	ino, err := volumeHandle.Symlink(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Basename, in.Target)
 	reply.InodeNumber = int64(uint64(ino))
 	return
 }
@@ -2043,21 +2196,24 @@ func (s *Server) RpcSymlinkPath(in *SymlinkPathRequest, reply *Reply) (err error
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	mount, err := lookupMountByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
+	volumeHandle := mount.volume.volumeHandle
+
+	userID, groupID := mount.mapCredentials(in.UserID, in.GroupID)
 
 	// Split fullpath into (source) parent dir and new basename
 	srcParentDir, srcBasename := splitPath(in.Fullpath)
 
 	// Get the inode for the (source) parent dir
-	srcIno, err := volumeHandle.LookupPath(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, srcParentDir)
+	srcIno, err := volumeHandle.LookupPath(userID, groupID, nil, srcParentDir)
 	if err != nil {
 		return
 	}
 
-	_, err = volumeHandle.Symlink(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, srcIno, srcBasename, in.TargetFullpath)
+	_, err = volumeHandle.Symlink(userID, groupID, nil, srcIno, srcBasename, in.TargetFullpath)
 	return
 }
 
@@ -2069,12 +2225,12 @@ func (s *Server) RpcType(in *TypeRequest, reply *TypeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	ftype, err := volumeHandle.GetType(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
+	ftype, err := volumeHandle.GetType(userID, groupID, nil, inode.InodeNumber(in.InodeNumber))
 	// Cast as a uint16 here to get the underlying DT_* constant
 	reply.FileType = uint16(ftype)
 	return
@@ -2088,12 +2244,12 @@ func (s *Server) RpcUnlink(in *UnlinkRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
 
-	err = volumeHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename)
+	err = volumeHandle.Unlink(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Basename)
 	return
 }
 
@@ -2105,7 +2261,7 @@ func (s *Server) RpcUnlinkPath(in *UnlinkPathRequest, reply *Reply) (err error)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	volumeHandle, err := lookupVolumeHandleByMountIDAsString(in.MountID)
+	volumeHandle, userID, groupID, err := lookupVolumeHandleAndCredentialsByMountIDAsString(in.MountID)
 	if nil != err {
 		return
 	}
@@ -2114,13 +2270,13 @@ func (s *Server) RpcUnlinkPath(in *UnlinkPathRequest, reply *Reply) (err error)
 	parentDir, basename := splitPath(in.Fullpath)
 
 	// Get the inode for the parent dir
-	ino, err := volumeHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, parentDir)
+	ino, err := volumeHandle.LookupPath(userID, groupID, nil, parentDir)
 	if err != nil {
 		return
 	}
 
 	// Do the unlink
-	err = volumeHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, basename)
+	err = volumeHandle.Unlink(userID, groupID, nil, ino, basename)
 	return
 }
 
@@ -2249,3 +2405,326 @@ func (s *Server) RpcSnapShotLookupByName(in *SnapShotLookupByNameRequest, reply
 
 	return
 }
+
+func (s *Server) RpcSnapShotDiff(in *SnapShotDiffRequest, reply *SnapShotDiffReply) (err error) {
+	var (
+		fsVolumeHandle fs.VolumeHandle
+		mount          *mountStruct
+	)
+
+	mount, err = lookupMountByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+
+	// The diff names every changed inode (and all of its paths) throughout the volume
+
+	err = mount.requireAdmin("RpcSnapShotDiff")
+	if nil != err {
+		return
+	}
+
+	fsVolumeHandle = mount.volume.volumeHandle
+
+	reply.DiffEntries, reply.MoreEntries, err = fsVolumeHandle.SnapShotDiff(in.FromSnapShotID, in.ToSnapShotID, inode.InodeNumber(in.LastInodeNumber), in.MaxEntries)
+
+	return
+}
+
+func (s *Server) RpcSnapShotRevert(in *SnapShotRevertRequest, reply *SnapShotRevertReply) (err error) {
+	var (
+		mount *mountStruct
+	)
+
+	mount, err = lookupMountByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mount.requireAdmin("RpcSnapShotRevert")
+	if nil != err {
+		return
+	}
+
+	// Leases are recalled via our fs.ViewChangeListener (see volumeStruct.ViewChanging())
+
+	err = mount.volume.volumeHandle.SnapShotRevert(in.SnapShotID)
+
+	return
+}
+
+func (s *Server) RpcSnapShotRestore(in *SnapShotRestoreRequest, reply *SnapShotRestoreReply) (err error) {
+	var (
+		mount *mountStruct
+	)
+
+	mount, err = lookupMountByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mount.requireAdmin("RpcSnapShotRestore")
+	if nil != err {
+		return
+	}
+
+	// Leases are recalled via our fs.ViewChangeListener (see volumeStruct.ViewChanging())
+
+	err = mount.volume.volumeHandle.SnapShotRestore(in.SnapShotID, in.Path)
+
+	return
+}
+
+func (s *Server) RpcFetchChangeEvents(in *FetchChangeEventsRequest, reply *FetchChangeEventsReply) (err error) {
+	var (
+		fsVolumeHandle fs.VolumeHandle
+		maxWait        time.Duration
+		mount          *mountStruct
+	)
+
+	mount, err = lookupMountByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+
+	// ChangeEvents name the full path of every change across the volume
+
+	err = mount.requireAdmin("RpcFetchChangeEvents")
+	if nil != err {
+		return
+	}
+
+	fsVolumeHandle = mount.volume.volumeHandle
+
+	if in.MaxWaitMilliseconds < uint64(fs.ChangeEventsMaxWait/time.Millisecond) {
+		maxWait = time.Duration(in.MaxWaitMilliseconds) * time.Millisecond
+	} else {
+		maxWait = fs.ChangeEventsMaxWait
+	}
+
+	reply.ChangeEvents, reply.Epoch, reply.LatestSequenceNumber, reply.EventsLost, err = fsVolumeHandle.FetchChangeEvents(in.LastEpoch, in.LastSequenceNumber, in.MaxEvents, maxWait)
+
+	return
+}
+
+func (s *Server) RpcRecycleBinList(in *RecycleBinListRequest, reply *RecycleBinListReply) (err error) {
+	var (
+		fsVolumeHandle fs.VolumeHandle
+		mount          *mountStruct
+	)
+
+	mount, err = lookupMountByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mount.requireAdmin("RpcRecycleBinList")
+	if nil != err {
+		return
+	}
+
+	fsVolumeHandle = mount.volume.volumeHandle
+
+	reply.Entries, reply.MoreEntries, err = fsVolumeHandle.RecycleBinList(in.PrevName, in.MaxEntries)
+
+	return
+}
+
+func (s *Server) RpcRecycleBinRestore(in *RecycleBinRestoreRequest, reply *RecycleBinRestoreReply) (err error) {
+	var (
+		fsVolumeHandle fs.VolumeHandle
+		mount          *mountStruct
+	)
+
+	mount, err = lookupMountByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mount.requireAdmin("RpcRecycleBinRestore")
+	if nil != err {
+		return
+	}
+
+	fsVolumeHandle = mount.volume.volumeHandle
+
+	reply.Path, err = fsVolumeHandle.RecycleBinRestore(in.Name, in.Path)
+
+	return
+}
+
+func (s *Server) RpcRecycleBinPurge(in *RecycleBinPurgeRequest, reply *RecycleBinPurgeReply) (err error) {
+	var (
+		fsVolumeHandle fs.VolumeHandle
+		mount          *mountStruct
+	)
+
+	mount, err = lookupMountByMountIDAsString(in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mount.requireAdmin("RpcRecycleBinPurge")
+	if nil != err {
+		return
+	}
+
+	fsVolumeHandle = mount.volume.volumeHandle
+
+	err = fsVolumeHandle.RecycleBinPurge(in.Name)
+
+	return
+}
+
+func (s *Server) RpcFind(in *FindRequest, reply *FindReply) (err error) {
+	var (
+		groupID inode.InodeGroupID
+		mount   *mountStruct
+		ok      bool
+		userID  inode.InodeUserID
+	)
+
+	globals.volumesLock.Lock()
+
+	mount, ok = globals.mountMapByMountIDAsString[in.MountID]
+	if !ok {
+		globals.volumesLock.Unlock()
+		err = fmt.Errorf("MountID %s not found in jrpcfs globals.mountMapByMountIDAsString", in.MountID)
+		err = blunder.AddError(err, blunder.BadMountIDError)
+		return
+	}
+
+	userID, groupID = mount.callerCredentials()
+
+	globals.lastFindJobID++
+
+	reply.FindJobID = globals.lastFindJobID
+
+	mount.volume.findJobMap[reply.FindJobID] = &findJobStruct{
+		jobHandle:       fs.Find(mount.volume.volumeName, userID, groupID, nil, in.Path, in.Predicate),
+		mountIDAsString: in.MountID,
+		lastUsed:        time.Now(),
+	}
+
+	globals.volumesLock.Unlock()
+
+	err = nil
+	return
+}
+
+// findJobReaper periodically cancels Find jobs abandoned by their clients (i.e. those
+// not having seen an RpcFindFetch for findJobIdleTimeout).
+func findJobReaper() {
+	var (
+		findJob      *findJobStruct
+		findJobID    uint64
+		idleFindJobs []*findJobStruct
+		ticker       *time.Ticker
+		timeNow      time.Time
+		volume       *volumeStruct
+	)
+
+	defer globals.findJobReaperWG.Done()
+
+	ticker = time.NewTicker(findJobReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-globals.findJobReaperStopChan:
+			return
+		case timeNow = <-ticker.C:
+		}
+
+		idleFindJobs = make([]*findJobStruct, 0)
+
+		globals.volumesLock.Lock()
+
+		for _, volume = range globals.volumeMap {
+			for findJobID, findJob = range volume.findJobMap {
+				if timeNow.Sub(findJob.lastUsed) > findJobIdleTimeout {
+					idleFindJobs = append(idleFindJobs, findJob)
+					delete(volume.findJobMap, findJobID)
+				}
+			}
+		}
+
+		globals.volumesLock.Unlock()
+
+		for _, findJob = range idleFindJobs {
+			findJob.jobHandle.Cancel()
+		}
+	}
+}
+
+// lookupFindJob returns the Find job identified by findJobID if it was started via mountIDAsString.
+// If remove is true, the Find job is also removed from its volume's findJobMap.
+func lookupFindJob(mountIDAsString MountIDAsString, findJobID uint64, remove bool) (findJob *findJobStruct, err error) {
+	var (
+		mount *mountStruct
+		ok    bool
+	)
+
+	globals.volumesLock.Lock()
+	defer globals.volumesLock.Unlock()
+
+	mount, ok = globals.mountMapByMountIDAsString[mountIDAsString]
+	if !ok {
+		err = fmt.Errorf("MountID %s not found in jrpcfs globals.mountMapByMountIDAsString", mountIDAsString)
+		err = blunder.AddError(err, blunder.BadMountIDError)
+		return
+	}
+
+	findJob, ok = mount.volume.findJobMap[findJobID]
+	if !ok || (findJob.mountIDAsString != mountIDAsString) {
+		err = blunder.NewError(blunder.NotFoundError, "FindJobID %v not found for MountID %s", findJobID, mountIDAsString)
+		return
+	}
+
+	if remove {
+		delete(mount.volume.findJobMap, findJobID)
+	} else {
+		findJob.lastUsed = time.Now()
+	}
+
+	err = nil
+	return
+}
+
+func (s *Server) RpcFindFetch(in *FindFetchRequest, reply *FindFetchReply) (err error) {
+	var (
+		findJob *findJobStruct
+	)
+
+	findJob, err = lookupFindJob(in.MountID, in.FindJobID, false)
+	if nil != err {
+		return
+	}
+
+	reply.Results, reply.NextMarker, reply.Done = findJob.jobHandle.FetchResults(in.Marker, in.MaxResults, time.Duration(in.MaxWaitMilliseconds)*time.Millisecond)
+
+	if reply.Done {
+		reply.Errors = findJob.jobHandle.Error()
+
+		_, _ = lookupFindJob(in.MountID, in.FindJobID, true)
+	}
+
+	err = nil
+	return
+}
+
+func (s *Server) RpcFindCancel(in *FindCancelRequest, reply *FindCancelReply) (err error) {
+	var (
+		findJob *findJobStruct
+	)
+
+	findJob, err = lookupFindJob(in.MountID, in.FindJobID, true)
+	if nil != err {
+		return
+	}
+
+	findJob.jobHandle.Cancel()
+
+	err = nil
+	return
+}
@@ -0,0 +1,441 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package jrpcfs
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+// Mounts of a Volume may be required to prove an identity. Once established, the
+// mount's identity (if it appears in the Volume's PrincipalIDMapList) replaces the
+// UserID/GroupID supplied in each subsequent request. Otherwise, supplied IDs are
+// translated via the Volume's {User|Group}IDMapList and then squashed per the
+// Volume's {Root|All}Squash settings before being passed on to package fs.
+
+type mountAuthenticationType uint32
+
+const (
+	mountAuthenticationNone           mountAuthenticationType = iota // AuthToken is recorded but not checked
+	mountAuthenticationAuthToken                                     // AuthToken must be accepted by Swift for the Volume's Account
+	mountAuthenticationTLSCertificate                                // RetryRPC connection must have presented a verified client certificate
+)
+
+const (
+	mountAuthenticationNoneString           = "None"
+	mountAuthenticationAuthTokenString      = "AuthToken"
+	mountAuthenticationTLSCertificateString = "TLSCertificate"
+)
+
+const anonIDDefault = 65534 // a.k.a. "nobody" & "nogroup"
+
+type identityStruct struct {
+	userID  inode.InodeUserID
+	groupID inode.InodeGroupID
+}
+
+type exportStruct struct {
+	accountName         string
+	mountAuthentication mountAuthenticationType
+	rootSquash          bool
+	allSquash           bool
+	anonIdentity        identityStruct
+	userIDMap           map[inode.InodeUserID]inode.InodeUserID   // key == client-supplied UserID
+	groupIDMap          map[inode.InodeGroupID]inode.InodeGroupID // key == client-supplied GroupID
+	principalMap        map[string]identityStruct                 // key == authenticated principal (AccountName or certificate CommonName)
+}
+
+func fetchExport(confMap conf.ConfMap, volumeName string) (export *exportStruct, err error) {
+	var (
+		anonGroupID               uint32
+		anonUserID                uint32
+		clientID                  uint64
+		groupIDMapList            []string
+		identity                  identityStruct
+		mapElement                string
+		mountAuthenticationString string
+		principal                 string
+		principalIDMapList        []string
+		serverID                  uint64
+		userIDMapList             []string
+		volumeSectionName         string
+	)
+
+	volumeSectionName = "Volume:" + volumeName
+
+	export = &exportStruct{
+		userIDMap:    make(map[inode.InodeUserID]inode.InodeUserID),
+		groupIDMap:   make(map[inode.InodeGroupID]inode.InodeGroupID),
+		principalMap: make(map[string]identityStruct),
+	}
+
+	export.accountName, err = confMap.FetchOptionValueString(volumeSectionName, "AccountName")
+	if nil != err {
+		return
+	}
+
+	mountAuthenticationString, err = confMap.FetchOptionValueString(volumeSectionName, "MountAuthentication")
+	if nil != err {
+		mountAuthenticationString = mountAuthenticationNoneString // Default to no mount authentication if not present
+	}
+
+	switch mountAuthenticationString {
+	case mountAuthenticationNoneString:
+		export.mountAuthentication = mountAuthenticationNone
+	case mountAuthenticationAuthTokenString:
+		if "" == globals.authTokenValidationURL {
+			err = fmt.Errorf("%s.MountAuthentication == \"%s\" requires JSONRPCServer.AuthTokenValidationURL", volumeSectionName, mountAuthenticationString)
+			return
+		}
+		export.mountAuthentication = mountAuthenticationAuthToken
+	case mountAuthenticationTLSCertificateString:
+		if nil == globals.retryRPCClientCAPEM {
+			err = fmt.Errorf("%s.MountAuthentication == \"%s\" requires JSONRPCServer.RetryRPCClientCAFilePath", volumeSectionName, mountAuthenticationString)
+			return
+		}
+		export.mountAuthentication = mountAuthenticationTLSCertificate
+	default:
+		err = fmt.Errorf("%s.MountAuthentication == \"%s\" must be one of \"%s\", \"%s\", or \"%s\"", volumeSectionName, mountAuthenticationString, mountAuthenticationNoneString, mountAuthenticationAuthTokenString, mountAuthenticationTLSCertificateString)
+		return
+	}
+
+	export.rootSquash, err = confMap.FetchOptionValueBool(volumeSectionName, "RootSquash")
+	if nil != err {
+		export.rootSquash = false // Default to not squashing root if not present
+	}
+
+	export.allSquash, err = confMap.FetchOptionValueBool(volumeSectionName, "AllSquash")
+	if nil != err {
+		export.allSquash = false // Default to not squashing everybody if not present
+	}
+
+	anonUserID, err = confMap.FetchOptionValueUint32(volumeSectionName, "AnonUID")
+	if nil != err {
+		anonUserID = anonIDDefault // Default to "nobody" if not present
+	}
+	export.anonIdentity.userID = inode.InodeUserID(anonUserID)
+
+	anonGroupID, err = confMap.FetchOptionValueUint32(volumeSectionName, "AnonGID")
+	if nil != err {
+		anonGroupID = anonIDDefault // Default to "nogroup" if not present
+	}
+	export.anonIdentity.groupID = inode.InodeGroupID(anonGroupID)
+
+	userIDMapList, err = confMap.FetchOptionValueStringSlice(volumeSectionName, "UserIDMapList")
+	if nil != err {
+		userIDMapList = []string{} // Default to no UserID mapping if not present
+	}
+
+	for _, mapElement = range userIDMapList {
+		clientID, serverID, err = parseIDMapElement(mapElement)
+		if nil != err {
+			err = fmt.Errorf("%s.UserIDMapList element \"%s\" invalid: %v", volumeSectionName, mapElement, err)
+			return
+		}
+		export.userIDMap[inode.InodeUserID(clientID)] = inode.InodeUserID(serverID)
+	}
+
+	groupIDMapList, err = confMap.FetchOptionValueStringSlice(volumeSectionName, "GroupIDMapList")
+	if nil != err {
+		groupIDMapList = []string{} // Default to no GroupID mapping if not present
+	}
+
+	for _, mapElement = range groupIDMapList {
+		clientID, serverID, err = parseIDMapElement(mapElement)
+		if nil != err {
+			err = fmt.Errorf("%s.GroupIDMapList element \"%s\" invalid: %v", volumeSectionName, mapElement, err)
+			return
+		}
+		export.groupIDMap[inode.InodeGroupID(clientID)] = inode.InodeGroupID(serverID)
+	}
+
+	principalIDMapList, err = confMap.FetchOptionValueStringSlice(volumeSectionName, "PrincipalIDMapList")
+	if nil != err {
+		principalIDMapList = []string{} // Default to no bound identities if not present
+	}
+
+	for _, mapElement = range principalIDMapList {
+		principal, identity, err = parsePrincipalIDMapElement(mapElement)
+		if nil != err {
+			err = fmt.Errorf("%s.PrincipalIDMapList element \"%s\" invalid: %v", volumeSectionName, mapElement, err)
+			return
+		}
+		export.principalMap[principal] = identity
+	}
+
+	err = nil
+	return
+}
+
+// parseIDMapElement parses a "<clientID>:<serverID>" element of a {User|Group}IDMapList.
+func parseIDMapElement(mapElement string) (clientID uint64, serverID uint64, err error) {
+	var (
+		mapElementSplit []string
+	)
+
+	mapElementSplit = strings.Split(mapElement, ":")
+	if 2 != len(mapElementSplit) {
+		err = fmt.Errorf("expected <clientID>:<serverID>")
+		return
+	}
+
+	clientID, err = strconv.ParseUint(mapElementSplit[0], 10, 32)
+	if nil != err {
+		return
+	}
+	serverID, err = strconv.ParseUint(mapElementSplit[1], 10, 32)
+
+	return
+}
+
+// parsePrincipalIDMapElement parses a "<principal>:<UserID>:<GroupID>" element of a
+// PrincipalIDMapList. As the principal may itself contain ':', the IDs are taken from
+// the right.
+func parsePrincipalIDMapElement(mapElement string) (principal string, identity identityStruct, err error) {
+	var (
+		groupID      uint64
+		groupIDSep   int
+		userID       uint64
+		userIDSep    int
+		userIDString string
+	)
+
+	groupIDSep = strings.LastIndex(mapElement, ":")
+	if 0 > groupIDSep {
+		err = fmt.Errorf("expected <principal>:<UserID>:<GroupID>")
+		return
+	}
+	userIDSep = strings.LastIndex(mapElement[:groupIDSep], ":")
+	if 0 >= userIDSep {
+		err = fmt.Errorf("expected <principal>:<UserID>:<GroupID>")
+		return
+	}
+
+	principal = mapElement[:userIDSep]
+	userIDString = mapElement[userIDSep+1 : groupIDSep]
+
+	userID, err = strconv.ParseUint(userIDString, 10, 32)
+	if nil != err {
+		return
+	}
+	groupID, err = strconv.ParseUint(mapElement[groupIDSep+1:], 10, 32)
+	if nil != err {
+		return
+	}
+
+	identity.userID = inode.InodeUserID(userID)
+	identity.groupID = inode.InodeGroupID(groupID)
+
+	return
+}
+
+// authenticateMount establishes the principal of a mount request per the Volume's
+// MountAuthentication setting. For mountAuthenticationNone, principal will be "".
+func authenticateMount(export *exportStruct, authToken string, clientID uint64) (principal string, err error) {
+	switch export.mountAuthentication {
+	case mountAuthenticationNone:
+		principal = ""
+		err = nil
+	case mountAuthenticationAuthToken:
+		err = validateAuthToken(export.accountName, authToken)
+		if nil == err {
+			principal = export.accountName
+		}
+	case mountAuthenticationTLSCertificate:
+		principal, err = fetchClientCertificateCommonName(clientID)
+	default:
+		err = fmt.Errorf("authenticateMount() found unexpected mountAuthentication: %v", export.mountAuthentication)
+	}
+
+	if nil != err {
+		err = blunder.AddError(err, blunder.PermDeniedError)
+	}
+
+	return
+}
+
+// validateAuthToken asks Swift (via [JSONRPCServer]AuthTokenValidationURL) whether
+// authToken grants access to accountName.
+func validateAuthToken(accountName string, authToken string) (err error) {
+	var (
+		httpClient   *http.Client
+		httpRequest  *http.Request
+		httpResponse *http.Response
+	)
+
+	if "" == authToken {
+		err = fmt.Errorf("no AuthToken supplied for Account \"%s\"", accountName)
+		return
+	}
+
+	httpRequest, err = http.NewRequest(http.MethodHead, globals.authTokenValidationURL+"/v1/"+accountName, nil)
+	if nil != err {
+		return
+	}
+
+	httpRequest.Header.Add("X-Auth-Token", authToken)
+
+	httpClient = &http.Client{Timeout: globals.authTokenValidationTimeout}
+
+	httpResponse, err = httpClient.Do(httpRequest)
+	if nil != err {
+		err = fmt.Errorf("AuthToken validation for Account \"%s\" failed: %v", accountName, err)
+		return
+	}
+
+	_ = httpResponse.Body.Close()
+
+	if (http.StatusOK > httpResponse.StatusCode) || (http.StatusMultipleChoices <= httpResponse.StatusCode) {
+		err = fmt.Errorf("AuthToken rejected for Account \"%s\" (Status: %s)", accountName, httpResponse.Status)
+		return
+	}
+
+	err = nil
+	return
+}
+
+func fetchClientCertificateCommonName(clientID uint64) (commonName string, err error) {
+	globals.connLock.Lock()
+	rrSvr := globals.retryrpcSvr
+	globals.connLock.Unlock()
+
+	if nil == rrSvr {
+		err = fmt.Errorf("client certificate authentication requires RetryRPC")
+		return
+	}
+
+	certificate := rrSvr.ClientCertificate(clientID)
+	if nil == certificate {
+		err = fmt.Errorf("no verified client certificate presented by RetryRPC clientID %v", clientID)
+		return
+	}
+
+	commonName = certificate.Subject.CommonName
+	if "" == commonName {
+		err = fmt.Errorf("client certificate presented by RetryRPC clientID %v has no CommonName", clientID)
+		return
+	}
+
+	err = nil
+	return
+}
+
+// mapCredentials returns the identity to be passed to package fs for a request
+// supplying userID & groupID on this mount.
+func (mount *mountStruct) mapCredentials(userID int32, groupID int32) (mappedUserID inode.InodeUserID, mappedGroupID inode.InodeGroupID) {
+	var (
+		export *exportStruct
+		ok     bool
+	)
+
+	if nil != mount.boundIdentity {
+		mappedUserID = mount.boundIdentity.userID
+		mappedGroupID = mount.boundIdentity.groupID
+		return
+	}
+
+	export = mount.volume.export
+
+	mappedUserID, ok = export.userIDMap[inode.InodeUserID(userID)]
+	if !ok {
+		mappedUserID = inode.InodeUserID(userID)
+	}
+	mappedGroupID, ok = export.groupIDMap[inode.InodeGroupID(groupID)]
+	if !ok {
+		mappedGroupID = inode.InodeGroupID(groupID)
+	}
+
+	if export.allSquash {
+		mappedUserID = export.anonIdentity.userID
+		mappedGroupID = export.anonIdentity.groupID
+		return
+	}
+
+	if export.rootSquash {
+		if inode.InodeRootUserID == mappedUserID {
+			mappedUserID = export.anonIdentity.userID
+		}
+		if inode.InodeGroupID(0) == mappedGroupID {
+			mappedGroupID = export.anonIdentity.groupID
+		}
+	}
+
+	return
+}
+
+// callerCredentials returns the identity to be passed to package fs for a request on
+// this mount that (unlike e.g. Create or Access) supplies no UserID & GroupID. Such
+// requests have always been performed as root, so they are treated as having
+// supplied root's IDs...and are thus subject to binding and squashing like any other.
+func (mount *mountStruct) callerCredentials() (mappedUserID inode.InodeUserID, mappedGroupID inode.InodeGroupID) {
+	mappedUserID, mappedGroupID = mount.mapCredentials(0, 0)
+	return
+}
+
+// requireAdmin returns a NotPermError unless requests on this mount are performed as
+// root (i.e. the mount is neither squashed nor bound to a non-root identity). It guards
+// requests that act upon the Volume as a whole rather than upon particular inodes.
+func (mount *mountStruct) requireAdmin(rpcName string) (err error) {
+	var (
+		userID inode.InodeUserID
+	)
+
+	userID, _ = mount.callerCredentials()
+
+	if inode.InodeRootUserID != userID {
+		err = fmt.Errorf("%s not permitted on MountID %s", rpcName, mount.mountIDAsString)
+		err = blunder.AddError(err, blunder.NotPermError)
+	}
+
+	return
+}
+
+// mapOwner translates the new owner supplied to a Chown request on this mount (where
+// -1 means "unchanged"). As a Chown request carries no caller identity, it is refused
+// for mounts whose callers are bound to or squashed to a non-root identity.
+func (mount *mountStruct) mapOwner(userID int32, groupID int32) (mappedUserID int64, mappedGroupID int64, err error) {
+	var (
+		export *exportStruct
+		ok     bool
+		uid    inode.InodeUserID
+		gid    inode.InodeGroupID
+	)
+
+	export = mount.volume.export
+
+	if ((nil != mount.boundIdentity) && (inode.InodeRootUserID != mount.boundIdentity.userID)) || ((nil == mount.boundIdentity) && export.allSquash) {
+		err = fmt.Errorf("Chown not permitted on MountID %s", mount.mountIDAsString)
+		err = blunder.AddError(err, blunder.NotPermError)
+		return
+	}
+
+	if -1 == userID {
+		mappedUserID = -1
+	} else {
+		uid, ok = export.userIDMap[inode.InodeUserID(userID)]
+		if !ok {
+			uid = inode.InodeUserID(userID)
+		}
+		mappedUserID = int64(uid)
+	}
+
+	if -1 == groupID {
+		mappedGroupID = -1
+	} else {
+		gid, ok = export.groupIDMap[inode.InodeGroupID(groupID)]
+		if !ok {
+			gid = inode.InodeGroupID(groupID)
+		}
+		mappedGroupID = int64(gid)
+	}
+
+	err = nil
+	return
+}
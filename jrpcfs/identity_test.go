@@ -0,0 +1,174 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+package jrpcfs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/blunder"
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/inode"
+)
+
+func TestExportIdentityMapping(t *testing.T) {
+	var (
+		confMap                         conf.ConfMap
+		err                             error
+		export                          *exportStruct
+		groupID                         inode.InodeGroupID
+		mount                           *mountStruct
+		ownerGroupID                    int64
+		ownerUserID                     int64
+		principal                       string
+		priorAuthTokenValidationTimeout time.Duration
+		priorAuthTokenValidationURL     string
+		swiftAuthServer                 *httptest.Server
+		userID                          inode.InodeUserID
+	)
+
+	swiftAuthServer = httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if (http.MethodHead == request.Method) && ("/v1/AUTH_test" == request.URL.Path) && ("AUTH_tk_good" == request.Header.Get("X-Auth-Token")) {
+			responseWriter.WriteHeader(http.StatusNoContent)
+		} else {
+			responseWriter.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer swiftAuthServer.Close()
+
+	priorAuthTokenValidationURL = globals.authTokenValidationURL
+	priorAuthTokenValidationTimeout = globals.authTokenValidationTimeout
+	globals.authTokenValidationURL = swiftAuthServer.URL
+	globals.authTokenValidationTimeout = time.Second
+	defer func() {
+		globals.authTokenValidationURL = priorAuthTokenValidationURL
+		globals.authTokenValidationTimeout = priorAuthTokenValidationTimeout
+	}()
+
+	confMap, err = conf.MakeConfMapFromStrings([]string{
+		"Volume:TestExportVolume.AccountName=AUTH_test",
+		"Volume:TestExportVolume.MountAuthentication=AuthToken",
+		"Volume:TestExportVolume.RootSquash=true",
+		"Volume:TestExportVolume.AnonUID=99",
+		"Volume:TestExportVolume.AnonGID=98",
+		"Volume:TestExportVolume.UserIDMapList=1000:2000,0:0",
+		"Volume:TestExportVolume.GroupIDMapList=1000:3000",
+		"Volume:TestExportVolume.PrincipalIDMapList=AUTH_test:500:600",
+	})
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings() failed: %v", err)
+	}
+
+	export, err = fetchExport(confMap, "TestExportVolume")
+	if nil != err {
+		t.Fatalf("fetchExport() failed: %v", err)
+	}
+
+	if (mountAuthenticationAuthToken != export.mountAuthentication) || !export.rootSquash || export.allSquash {
+		t.Fatalf("fetchExport() returned unexpected export: %#v", export)
+	}
+
+	err = confMap.UpdateFromString("Volume:TestExportVolume.UserIDMapList=1000")
+	if nil != err {
+		t.Fatalf("confMap.UpdateFromString() failed: %v", err)
+	}
+	_, err = fetchExport(confMap, "TestExportVolume")
+	if nil == err {
+		t.Fatalf("fetchExport() should have failed for malformed UserIDMapList")
+	}
+
+	// Unbound mounts get mapped, then root squashed
+
+	mount = &mountStruct{volume: &volumeStruct{export: export}}
+
+	userID, groupID = mount.mapCredentials(1000, 1000)
+	if (2000 != userID) || (3000 != groupID) {
+		t.Fatalf("mapCredentials(1000, 1000) returned (%v, %v)", userID, groupID)
+	}
+	userID, groupID = mount.mapCredentials(0, 0)
+	if (99 != userID) || (98 != groupID) {
+		t.Fatalf("mapCredentials(0, 0) returned (%v, %v)", userID, groupID)
+	}
+	userID, groupID = mount.mapCredentials(5, 6)
+	if (5 != userID) || (6 != groupID) {
+		t.Fatalf("mapCredentials(5, 6) returned (%v, %v)", userID, groupID)
+	}
+
+	// Requests supplying no identity are performed as a (here squashed) root
+
+	userID, groupID = mount.callerCredentials()
+	if (99 != userID) || (98 != groupID) {
+		t.Fatalf("callerCredentials() returned (%v, %v)", userID, groupID)
+	}
+	err = mount.requireAdmin("RpcTest")
+	if !blunder.Is(err, blunder.NotPermError) {
+		t.Fatalf("requireAdmin() with RootSquash should have failed with NotPermError: %v", err)
+	}
+
+	export.rootSquash = false
+	err = mount.requireAdmin("RpcTest")
+	if nil != err {
+		t.Fatalf("requireAdmin() without RootSquash failed: %v", err)
+	}
+	export.rootSquash = true
+
+	ownerUserID, ownerGroupID, err = mount.mapOwner(1000, -1)
+	if (nil != err) || (2000 != ownerUserID) || (-1 != ownerGroupID) {
+		t.Fatalf("mapOwner(1000, -1) returned (%v, %v, %v)", ownerUserID, ownerGroupID, err)
+	}
+
+	// AllSquash overrides everything for unbound mounts
+
+	export.allSquash = true
+
+	userID, groupID = mount.mapCredentials(1000, 1000)
+	if (99 != userID) || (98 != groupID) {
+		t.Fatalf("mapCredentials(1000, 1000) with AllSquash returned (%v, %v)", userID, groupID)
+	}
+
+	_, _, err = mount.mapOwner(1000, 1000)
+	if !blunder.Is(err, blunder.NotPermError) {
+		t.Fatalf("mapOwner() with AllSquash should have failed with NotPermError: %v", err)
+	}
+
+	export.allSquash = false
+
+	// AuthToken authentication binds the mount to the principal's identity
+
+	_, err = authenticateMount(export, "", 0)
+	if !blunder.Is(err, blunder.PermDeniedError) {
+		t.Fatalf("authenticateMount() with no AuthToken should have failed with PermDeniedError: %v", err)
+	}
+	_, err = authenticateMount(export, "AUTH_tk_bad", 0)
+	if !blunder.Is(err, blunder.PermDeniedError) {
+		t.Fatalf("authenticateMount() with bad AuthToken should have failed with PermDeniedError: %v", err)
+	}
+	principal, err = authenticateMount(export, "AUTH_tk_good", 0)
+	if (nil != err) || ("AUTH_test" != principal) {
+		t.Fatalf("authenticateMount() with good AuthToken returned (\"%s\", %v)", principal, err)
+	}
+
+	identity := export.principalMap[principal]
+	mount.boundIdentity = &identity
+
+	userID, groupID = mount.mapCredentials(0, 0)
+	if (500 != userID) || (600 != groupID) {
+		t.Fatalf("mapCredentials(0, 0) on bound mount returned (%v, %v)", userID, groupID)
+	}
+
+	_, _, err = mount.mapOwner(0, 0)
+	if !blunder.Is(err, blunder.NotPermError) {
+		t.Fatalf("mapOwner() on mount bound to non-root identity should have failed with NotPermError: %v", err)
+	}
+	userID, groupID = mount.callerCredentials()
+	if (500 != userID) || (600 != groupID) {
+		t.Fatalf("callerCredentials() on bound mount returned (%v, %v)", userID, groupID)
+	}
+	err = mount.requireAdmin("RpcTest")
+	if !blunder.Is(err, blunder.NotPermError) {
+		t.Fatalf("requireAdmin() on mount bound to non-root identity should have failed with NotPermError: %v", err)
+	}
+}
@@ -366,6 +366,8 @@ func makeReq(bytes []byte, req *ioRequest) {
 
 func ioHandle(conn net.Conn) {
 	var (
+		groupID      inode.InodeGroupID
+		userID       inode.InodeUserID
 		volumeHandle fs.VolumeHandle
 	)
 
@@ -410,9 +412,9 @@ func ioHandle(conn net.Conn) {
 			}
 
 			profiler.AddEventNow("before fs.Write()")
-			volumeHandle, err = lookupVolumeHandleByMountIDAsByteArray(ctx.req.mountID)
+			volumeHandle, userID, groupID, err = lookupVolumeHandleAndCredentialsByMountIDAsByteArray(ctx.req.mountID)
 			if err == nil {
-				ctx.resp.ioSize, err = volumeHandle.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ctx.req.inodeID), ctx.req.offset, ctx.data, profiler)
+				ctx.resp.ioSize, err = volumeHandle.Write(userID, groupID, nil, inode.InodeNumber(ctx.req.inodeID), ctx.req.offset, ctx.data, profiler)
 			}
 			profiler.AddEventNow("after fs.Write()")
 
@@ -428,9 +430,9 @@ func ioHandle(conn net.Conn) {
 			}
 
 			profiler.AddEventNow("before fs.Read()")
-			volumeHandle, err = lookupVolumeHandleByMountIDAsByteArray(ctx.req.mountID)
+			volumeHandle, userID, groupID, err = lookupVolumeHandleAndCredentialsByMountIDAsByteArray(ctx.req.mountID)
 			if err == nil {
-				ctx.data, err = volumeHandle.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ctx.req.inodeID), ctx.req.offset, ctx.req.length, profiler)
+				ctx.data, err = volumeHandle.Read(userID, groupID, nil, inode.InodeNumber(ctx.req.inodeID), ctx.req.offset, ctx.req.length, profiler)
 			}
 			profiler.AddEventNow("after fs.Read()")
 
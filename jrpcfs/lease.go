@@ -77,10 +77,10 @@ func (s *Server) RpcLease(in *LeaseRequest, reply *LeaseReply) (err error) {
 	}()
 
 	if (in.LeaseRequestType == LeaseRequestTypeShared) || (in.LeaseRequestType == LeaseRequestTypeExclusive) {
-		if !volume.acceptingMountsAndLeaseRequests || !mount.acceptingLeaseRequests {
+		if !volume.acceptingMountsAndLeaseRequests || volume.recallingAllLeases || !mount.acceptingLeaseRequests {
 			globals.volumesLock.Unlock()
 			reply.LeaseReplyType = LeaseReplyTypeDenied
-			err = fmt.Errorf("LeaseRequestType %v not allowed while dismounting Volume", in.LeaseRequestType)
+			err = fmt.Errorf("LeaseRequestType %v not allowed while dismounting Volume or recalling all Leases", in.LeaseRequestType)
 			err = blunder.AddError(err, blunder.BadLeaseRequest)
 			return
 		}
@@ -1956,6 +1956,50 @@ RequestChanDrained:
 	runtime.Goexit()
 }
 
+// recallAllLeases is called prior to changing the contents of the volume out from under
+// lease holders (see ViewChanging()). Each inodeLease is evicted (just as for
+// the inodeLeaseLRU) such that its holders are sent an RPCInterruptTypeRelease and thus
+// flush and discard what they have cached. Shared & Exclusive LeaseRequests are denied
+// until the returned function is called (once the volume's contents have been changed).
+func (volume *volumeStruct) recallAllLeases() (recallDone func()) {
+	var (
+		inodeLease *inodeLeaseStruct
+	)
+
+	globals.volumesLock.Lock()
+
+	volume.recallingAllLeases = true
+
+	for _, inodeLease = range volume.inodeLeaseMap {
+		if !inodeLease.beingEvicted {
+			inodeLease.beingEvicted = true
+			close(inodeLease.stopChan)
+			volume.ongoingLeaseEvictions++
+		}
+	}
+
+	globals.volumesLock.Unlock()
+
+	volume.leaseHandlerWG.Wait()
+
+	recallDone = func() {
+		globals.volumesLock.Lock()
+		volume.recallingAllLeases = false
+		globals.volumesLock.Unlock()
+	}
+
+	return
+}
+
+// ViewChanging implements fs.ViewChangeListener. It is called by package fs prior to
+// changing the contents of the volume (e.g. via fs.SnapShotRevert() or
+// fs.RetainedCheckpointInspect()) regardless of whether the request arrived via jrpcfs
+// or some other path (e.g. httpserver) such that all leases are first recalled.
+func (volume *volumeStruct) ViewChanging() (viewChanged func()) {
+	viewChanged = volume.recallAllLeases()
+	return
+}
+
 // armReleaseOfAllLeasesWhileLocked is called to schedule releasing of all held leases
 // for a specific mountStruct. It is called while globals.volumesLock is locked. The
 // leaseReleaseStartWG is assumed to be a sync.WaitGroup with a count of 1 such that
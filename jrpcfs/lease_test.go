@@ -1822,6 +1822,24 @@ func TestRpcLease(t *testing.T) {
 	testRpcLeaseClient[1].sendLeaseRequest(LeaseRequestTypeRelease)
 	testRpcLeaseClient[1].validateChOutValueIsLeaseReplyType(LeaseReplyTypeReleased)
 
+	testRpcLeaseLogTestCase("1 Exclusive then recallAllLeases leading to Release", true)
+
+	testRpcLeaseClient[1].sendLeaseRequest(LeaseRequestTypeExclusive)
+	testRpcLeaseClient[1].validateChOutValueIsLeaseReplyType(LeaseReplyTypeExclusive)
+	recallDoneChan := make(chan func())
+	go func() {
+		recallDoneChan <- globals.volumeMap["SomeVolume"].recallAllLeases()
+	}()
+	testRpcLeaseClient[1].validateChOutValueIsRPCInterruptType(RPCInterruptTypeRelease)
+	testRpcLeaseClient[1].sendLeaseRequest(LeaseRequestTypeRelease)
+	testRpcLeaseClient[1].validateChOutValueIsLeaseReplyTypeIgnoringRPCInterruptType(LeaseReplyTypeReleased, RPCInterruptTypeRelease)
+	recallDone := <-recallDoneChan
+	recallDone()
+	testRpcLeaseClient[2].sendLeaseRequest(LeaseRequestTypeShared)
+	testRpcLeaseClient[2].validateChOutValueIsLeaseReplyType(LeaseReplyTypeShared)
+	testRpcLeaseClient[2].sendLeaseRequest(LeaseRequestTypeRelease)
+	testRpcLeaseClient[2].validateChOutValueIsLeaseReplyType(LeaseReplyTypeReleased)
+
 	testRpcLeaseLogTestCase(fmt.Sprintf("%v Shared", testRpcLeaseSingleNumInstances-1), false)
 
 	for instance = 1; instance < testRpcLeaseSingleNumInstances; instance++ {
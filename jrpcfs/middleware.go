@@ -96,7 +96,7 @@ func (s *Server) RpcDelete(in *DeleteReq, reply *DeleteReply) (err error) {
 	}
 
 	// Call fs to delete the baseName if it is a file or an empty directory.
-	err = volumeHandle.MiddlewareDelete(parentDir, baseName)
+	err = volumeHandle.MiddlewareDelete(parentDir, baseName, in.VersionID)
 
 	return err
 }
@@ -140,7 +140,7 @@ func (s *Server) RpcHead(in *HeadReq, reply *HeadReply) (err error) {
 		entityPath = entityPath + "/" + vObjectName
 	}
 
-	resp, err := volumeHandle.MiddlewareHeadResponse(entityPath)
+	resp, err := volumeHandle.MiddlewareHeadResponse(entityPath, in.VersionID)
 	if err != nil {
 		if !blunder.Is(err, blunder.NotFoundError) {
 			logger.ErrorfWithError(err, "RpcHead: error retrieving metadata for %s", in.VirtPath)
@@ -171,11 +171,11 @@ func (s *Server) RpcGetContainer(in *GetContainerReq, reply *GetContainerReply)
 		return err
 	}
 
-	entries, err := volumeHandle.MiddlewareGetContainer(vContainerName, in.MaxEntries, in.Marker, in.EndMarker, in.Prefix, in.Delimiter)
+	entries, err := volumeHandle.MiddlewareGetContainer(vContainerName, in.MaxEntries, in.Marker, in.EndMarker, in.Prefix, in.Delimiter, in.Versions)
 	if err != nil {
 		return err
 	}
-	resp, err := volumeHandle.MiddlewareHeadResponse(vContainerName)
+	resp, err := volumeHandle.MiddlewareHeadResponse(vContainerName, 0)
 	if err != nil {
 		return err
 	}
@@ -196,7 +196,7 @@ func (s *Server) RpcGetObject(in *GetObjectReq, reply *GetObjectReply) (err erro
 
 	mountRelativePath := vContainerName + "/" + objectName
 
-	resp, err := volumeHandle.MiddlewareGetObject(mountRelativePath, in.ReadEntsIn, &reply.ReadEntsOut)
+	resp, err := volumeHandle.MiddlewareGetObject(mountRelativePath, in.VersionID, in.ReadEntsIn, &reply.ReadEntsOut)
 	if err != nil {
 		if !blunder.Is(err, blunder.NotFoundError) {
 			logger.ErrorfWithError(err, "RpcGetObject(): error retrieving metadata for %s", in.VirtPath)
@@ -359,6 +359,105 @@ func (s *Server) RpcCoalesce(in *CoalesceReq, reply *CoalesceReply) (err error)
 	return
 }
 
+// RpcMultipartInitiate begins a multipart upload of an object, returning the UploadID
+// by which its parts are subsequently identified.
+func (s *Server) RpcMultipartInitiate(in *MultipartInitiateReq, reply *MultipartInitiateReply) (err error) {
+	enterGate()
+	defer leaveGate()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	_, containerName, objectName, _, volumeHandle, err := parseVirtPath(in.VirtPath)
+	if err != nil {
+		return
+	}
+
+	reply.UploadID, err = volumeHandle.MiddlewareMultipartInitiate(containerName, objectName, in.Metadata)
+	return
+}
+
+// RpcMultipartPutPart is used once the data of a part has been put in Swift (just as for
+// RpcPutComplete). Parts may be put concurrently and a part may be put more than once.
+func (s *Server) RpcMultipartPutPart(in *MultipartPutPartReq, reply *MultipartPutPartReply) (err error) {
+	enterGate()
+	defer leaveGate()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	_, containerName, objectName, _, volumeHandle, err := parseVirtPath(in.VirtPath)
+	if err != nil {
+		return
+	}
+
+	err = volumeHandle.MiddlewareMultipartPutPart(containerName, objectName, in.UploadID, in.PartNumber, in.PhysPaths, in.PhysLengths, in.ETag)
+	return
+}
+
+// RpcMultipartListParts returns (in part number order) the parts of a multipart upload
+// following PartNumberMarker.
+func (s *Server) RpcMultipartListParts(in *MultipartListPartsReq, reply *MultipartListPartsReply) (err error) {
+	enterGate()
+	defer leaveGate()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	_, containerName, objectName, _, volumeHandle, err := parseVirtPath(in.VirtPath)
+	if err != nil {
+		return
+	}
+
+	reply.Parts, reply.MoreParts, err = volumeHandle.MiddlewareMultipartListParts(containerName, objectName, in.UploadID, in.PartNumberMarker, in.MaxParts)
+	return
+}
+
+// RpcMultipartComplete assembles the listed parts of a multipart upload into the object
+// and discards the upload (including any parts not listed).
+func (s *Server) RpcMultipartComplete(in *MultipartCompleteReq, reply *MultipartCompleteReply) (err error) {
+	enterGate()
+	defer leaveGate()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	_, containerName, objectName, _, volumeHandle, err := parseVirtPath(in.VirtPath)
+	if err != nil {
+		return
+	}
+
+	mtime, ctime, ino, numWrites, err := volumeHandle.MiddlewareMultipartComplete(containerName, objectName, in.UploadID, in.Parts, in.Metadata)
+	reply.ModificationTime = mtime
+	reply.AttrChangeTime = ctime
+	reply.InodeNumber = int64(uint64(ino))
+	reply.NumWrites = numWrites
+
+	return
+}
+
+// RpcMultipartAbort discards a multipart upload along with all of its parts.
+func (s *Server) RpcMultipartAbort(in *MultipartAbortReq, reply *MultipartAbortReply) (err error) {
+	enterGate()
+	defer leaveGate()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	_, containerName, objectName, _, volumeHandle, err := parseVirtPath(in.VirtPath)
+	if err != nil {
+		return
+	}
+
+	err = volumeHandle.MiddlewareMultipartAbort(containerName, objectName, in.UploadID)
+	return
+}
+
 // Renew a lease, ensuring that the related file's log segments won't get deleted. This ensures that an HTTP client is
 // able to complete an object GET request regardless of concurrent FS writes or HTTP PUTs to that file.
 //
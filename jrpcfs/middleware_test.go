@@ -902,7 +902,7 @@ func testRpcPost(t *testing.T, server *Server) {
 	err = middlewarePost(server, virtPath, newContMetaData, oldContMetaData)
 	assert.Nil(err)
 
-	headResponse, err := volumeHandle.MiddlewareHeadResponse(testContainerName + "/" + emptyFile)
+	headResponse, err := volumeHandle.MiddlewareHeadResponse(testContainerName+"/"+emptyFile, 0)
 	assert.Nil(err)
 	assert.Equal(newContMetaData, headResponse.Metadata)
 
@@ -1023,7 +1023,7 @@ func TestPutObjectSimple(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(objData, contents)
 
-	headResponse, err := volumeHandle.MiddlewareHeadResponse(containerName + "/" + objName)
+	headResponse, err := volumeHandle.MiddlewareHeadResponse(containerName+"/"+objName, 0)
 	assert.Nil(err)
 	assert.Equal([]byte(objMetadata), headResponse.Metadata)
 }
@@ -1318,7 +1318,7 @@ func TestPutObjectCompound(t *testing.T) {
 	// 2 is the number of log segments we wrote
 	assert.Equal(uint64(2), putCompleteResp.NumWrites)
 
-	headResponse, err := volumeHandle.MiddlewareHeadResponse(containerName + "/" + objName)
+	headResponse, err := volumeHandle.MiddlewareHeadResponse(containerName+"/"+objName, 0)
 	assert.Nil(err)
 	assert.Equal([]byte(objMetadata), headResponse.Metadata)
 
@@ -26,6 +26,7 @@ func retryRPCServerUp(jserver *Server) {
 		DeadlineIO:      globals.retryRPCDeadlineIO,
 		KeepAlivePeriod: globals.retryRPCKeepAlivePeriod,
 		TLSCertificate:  globals.retryRPCCertificate,
+		ClientCAx509PEM: globals.retryRPCClientCAPEM,
 	}
 
 	rrSvr := retryrpc.NewServer(retryConfig)
@@ -34,27 +34,29 @@ const (
 
 func Format(mode Mode, volumeNameToFormat string, confFile string, confStrings []string, execArgs []string) (err error) {
 	var (
-		accountName           string
-		cancel                context.CancelFunc
-		checkpointEtcdKeyName string
-		confMap               conf.ConfMap
-		containerList         []string
-		containerName         string
-		ctx                   context.Context
-		etcdAutoSyncInterval  time.Duration
-		etcdClient            *etcd.Client
-		etcdDialTimeout       time.Duration
-		etcdEnabled           bool
-		etcdCertDir           string
-		etcdEndpoints         []string
-		etcdKV                etcd.KV
-		etcdOpTimeout         time.Duration
-		getResponse           *etcd.GetResponse
-		isEmpty               bool
-		objectList            []string
-		objectName            string
-		replayLogFileName     string
-		whoAmI                string
+		accountName             string
+		cancel                  context.CancelFunc
+		checkpointContainerName string
+		checkpointEtcdKeyName   string
+		cloneAccountNames       []string
+		confMap                 conf.ConfMap
+		containerList           []string
+		containerName           string
+		ctx                     context.Context
+		etcdAutoSyncInterval    time.Duration
+		etcdClient              *etcd.Client
+		etcdDialTimeout         time.Duration
+		etcdEnabled             bool
+		etcdCertDir             string
+		etcdEndpoints           []string
+		etcdKV                  etcd.KV
+		etcdOpTimeout           time.Duration
+		getResponse             *etcd.GetResponse
+		isEmpty                 bool
+		objectList              []string
+		objectName              string
+		replayLogFileName       string
+		whoAmI                  string
 	)
 
 	// Valid mode?
@@ -214,6 +216,34 @@ func Format(mode Mode, volumeNameToFormat string, confFile string, confStrings [
 		case ModeReformat:
 			// If Swift Account is not empty && ModeReformat, clear out accountName
 
+			// ...but first ensure no other volume was cloned from it and, should it
+			//    itself be a clone, release it from the volume it was cloned from
+
+			checkpointContainerName, err = confMap.FetchOptionValueString("Volume:"+volumeNameToFormat, "CheckpointContainerName")
+			if nil != err {
+				_ = transitions.Down(confMap)
+				return
+			}
+
+			cloneAccountNames, err = headhunter.FetchClones(accountName, checkpointContainerName)
+			if (nil != err) && (http.StatusNotFound != blunder.HTTPCode(err)) {
+				_ = transitions.Down(confMap)
+				err = fmt.Errorf("failed to HEAD %v/%v: %v", accountName, checkpointContainerName, err)
+				return
+			}
+			if (nil == err) && (0 < len(cloneAccountNames)) {
+				_ = transitions.Down(confMap)
+				err = fmt.Errorf("%v cannot be reformatted while cloned to %v", accountName, strings.Join(cloneAccountNames, ", "))
+				return
+			}
+
+			err = headhunter.ReleaseClone(accountName, checkpointContainerName)
+			if (nil != err) && (http.StatusNotFound != blunder.HTTPCode(err)) {
+				_ = transitions.Down(confMap)
+				err = fmt.Errorf("failed to release clone %v/%v: %v", accountName, checkpointContainerName, err)
+				return
+			}
+
 			for !isEmpty {
 				for _, containerName = range containerList {
 					_, objectList, err = swiftclient.ContainerGet(accountName, containerName)
@@ -0,0 +1,164 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+// Program pfs-archive exports a volume (or one of its SnapShots) to a portable, tar-compatible archive
+// or imports such an archive into an empty (e.g. freshly mkproxyfs-formatted) volume. The volume must
+// be served by this Peer (i.e. its VolumeGroup's PrimaryPeer must be Cluster.WhoAmI) and must not be
+// concurrently served by proxyfsd.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/fs"
+	"github.com/NVIDIA/proxyfs/transitions"
+)
+
+func usage(file *os.File) {
+	fmt.Fprintf(file, "Usage:\n")
+	fmt.Fprintf(file, "    %v export <volumeName>[@<snapShotName>] <archiveFile> conf-file [section.option=value]*\n", os.Args[0])
+	fmt.Fprintf(file, "    %v import <volumeName> <archiveFile> conf-file [section.option=value]*\n", os.Args[0])
+	fmt.Fprintf(file, "  where:\n")
+	fmt.Fprintf(file, "    export                  writes the volume (or the named SnapShot of it) to <archiveFile>\n")
+	fmt.Fprintf(file, "    import                  recreates the contents of <archiveFile> in the (empty) volume\n")
+	fmt.Fprintf(file, "    <archiveFile>           path of the archive (\"-\" for stdout/stdin)\n")
+	fmt.Fprintf(file, "    conf-file               input to conf.MakeConfMapFromFile()\n")
+	fmt.Fprintf(file, "    [section.option=value]* optional input to conf.UpdateFromStrings()\n")
+}
+
+func main() {
+	var (
+		archiveFile     *os.File
+		archiveFileName string
+		archiveReader   *bufio.Reader
+		archiveWriter   *bufio.Writer
+		command         string
+		confMap         conf.ConfMap
+		err             error
+		errString       string
+		exitCode        int
+		jobHandle       fs.JobHandle
+		snapShotName    string
+		volumeName      string
+	)
+
+	// Parse arguments
+
+	if 5 > len(os.Args) {
+		usage(os.Stderr)
+		os.Exit(1)
+	}
+
+	command = os.Args[1]
+
+	switch command {
+	case "export":
+		volumeName = os.Args[2]
+		if strings.Contains(volumeName, "@") {
+			snapShotName = strings.SplitN(volumeName, "@", 2)[1]
+			volumeName = strings.SplitN(volumeName, "@", 2)[0]
+		}
+	case "import":
+		volumeName = os.Args[2]
+	default:
+		fmt.Fprintf(os.Stderr, "os.Args[1] ('%v') must be one of 'export' or 'import'\n", os.Args[1])
+		os.Exit(1)
+	}
+
+	archiveFileName = os.Args[3]
+
+	confMap, err = conf.MakeConfMapFromFile(os.Args[4])
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "conf.MakeConfMapFromFile(\"%v\") failed: %v\n", os.Args[4], err)
+		os.Exit(1)
+	}
+
+	if 5 < len(os.Args) {
+		err = confMap.UpdateFromStrings(os.Args[5:])
+		if nil != err {
+			fmt.Fprintf(os.Stderr, "confMap.UpdateFromStrings(%#v) failed: %v\n", os.Args[5:], err)
+			os.Exit(1)
+		}
+	}
+
+	// Upgrade confMap if necessary
+	err = transitions.UpgradeConfMapIfNeeded(confMap)
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "Failed to upgrade config: %v", err)
+		os.Exit(1)
+	}
+
+	// Open the archive
+
+	switch {
+	case ("export" == command) && ("-" == archiveFileName):
+		archiveFile = os.Stdout
+	case "export" == command:
+		archiveFile, err = os.Create(archiveFileName)
+	case "-" == archiveFileName:
+		archiveFile = os.Stdin
+	default:
+		archiveFile, err = os.Open(archiveFileName)
+	}
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "Open of archive \"%v\" failed: %v\n", archiveFileName, err)
+		os.Exit(1)
+	}
+
+	// Start up needed ProxyFS components
+
+	err = transitions.Up(confMap)
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "transitions.Up() failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Run the job
+
+	if "export" == command {
+		archiveWriter = bufio.NewWriter(archiveFile)
+		jobHandle = fs.ExportVolume(volumeName, snapShotName, archiveWriter)
+	} else {
+		archiveReader = bufio.NewReader(archiveFile)
+		jobHandle = fs.ImportVolume(volumeName, archiveReader)
+	}
+
+	jobHandle.Wait()
+
+	if "export" == command {
+		err = archiveWriter.Flush()
+		if nil != err {
+			fmt.Fprintf(os.Stderr, "Flush of archive \"%v\" failed: %v\n", archiveFileName, err)
+			exitCode = 1
+		}
+	}
+
+	if (os.Stdout != archiveFile) && (os.Stdin != archiveFile) {
+		err = archiveFile.Close()
+		if nil != err {
+			fmt.Fprintf(os.Stderr, "Close of archive \"%v\" failed: %v\n", archiveFileName, err)
+			exitCode = 1
+		}
+	}
+
+	// Stop ProxyFS components launched above
+
+	err = transitions.Down(confMap)
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "transitions.Down() failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Report results
+
+	for _, errString = range jobHandle.Error() {
+		fmt.Fprintf(os.Stderr, "%v\n", errString)
+		exitCode = 1
+	}
+
+	os.Exit(exitCode)
+}
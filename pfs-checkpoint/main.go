@@ -0,0 +1,198 @@
+// Copyright (c) 2015-2021, NVIDIA CORPORATION.
+// SPDX-License-Identifier: Apache-2.0
+
+// Program pfs-checkpoint lists the checkpoints a volume has retained (per its CheckpointRetentionCount)
+// and, via the ProxyFS HTTP Server, either inspects (read-only) or reverts the volume to one of them.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/proxyfs/conf"
+	"github.com/NVIDIA/proxyfs/transitions"
+)
+
+type retainedCheckpointStruct struct {
+	ID   uint64
+	Time time.Time
+}
+
+type retainedCheckpointsStruct struct {
+	InspectedID         uint64
+	RetainedCheckpoints []retainedCheckpointStruct
+}
+
+func usage() {
+	fmt.Printf("%v {list|resume|inspect <checkpointID>|revert <checkpointID>} <volumeName> <confFile> [<confOverride>]*\n", os.Args[0])
+	fmt.Println("  where:")
+	fmt.Println("    list    reports the volume's retained checkpoints (oldest first)")
+	fmt.Println("    inspect presents (read-only) the retained checkpoint in place of the live volume")
+	fmt.Println("    resume  once again presents the live volume")
+	fmt.Println("    revert  makes the retained checkpoint the new state of the live volume")
+}
+
+func main() {
+	var (
+		argsIndex         int
+		checkpointID      uint64
+		command           string
+		confFile          string
+		confMap           conf.ConfMap
+		err               error
+		httpServerTCPPort uint16
+		ipAddrTCPPort     string
+		peerSectionName   string
+		privateIPAddr     string
+		volumeName        string
+		whoAmI            string
+	)
+
+	if 1 == len(os.Args) {
+		usage()
+		os.Exit(0)
+	}
+
+	command = os.Args[1]
+	argsIndex = 2
+
+	switch command {
+	case "list", "resume":
+		checkpointID = 0
+	case "inspect", "revert":
+		if argsIndex == len(os.Args) {
+			usage()
+			os.Exit(-1)
+		}
+		checkpointID, err = strconv.ParseUint(os.Args[argsIndex], 10, 64)
+		if (nil != err) || (0 == checkpointID) {
+			log.Fatalf("invalid checkpointID: \"%s\"", os.Args[argsIndex])
+		}
+		argsIndex++
+	default:
+		usage()
+		os.Exit(-1)
+	}
+
+	if (argsIndex + 2) > len(os.Args) {
+		usage()
+		os.Exit(-1)
+	}
+
+	volumeName = os.Args[argsIndex]
+	confFile = os.Args[argsIndex+1]
+
+	confMap, err = conf.MakeConfMapFromFile(confFile)
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	err = confMap.UpdateFromStrings(os.Args[argsIndex+2:])
+	if nil != err {
+		log.Fatalf("failed to apply config overrides: %v", err)
+	}
+
+	// Upgrade confMap if necessary
+	err = transitions.UpgradeConfMapIfNeeded(confMap)
+	if nil != err {
+		log.Fatalf("Failed to upgrade config: %v", err)
+	}
+
+	whoAmI, err = confMap.FetchOptionValueString("Cluster", "WhoAmI")
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	peerSectionName = "Peer:" + whoAmI
+
+	privateIPAddr, err = confMap.FetchOptionValueString(peerSectionName, "PrivateIPAddr")
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	httpServerTCPPort, err = confMap.FetchOptionValueUint16("HTTPServer", "TCPPort")
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	ipAddrTCPPort = net.JoinHostPort(privateIPAddr, strconv.Itoa(int(httpServerTCPPort)))
+
+	switch command {
+	case "list":
+		listRetainedCheckpoints(ipAddrTCPPort, volumeName)
+	case "resume":
+		postCheckpointAction(ipAddrTCPPort, "/volume/"+volumeName+"/checkpoint", "resume")
+	default:
+		postCheckpointAction(ipAddrTCPPort, fmt.Sprintf("/volume/%s/checkpoint/%d", volumeName, checkpointID), command)
+	}
+}
+
+func listRetainedCheckpoints(ipAddrTCPPort string, volumeName string) {
+	var (
+		err                 error
+		inspectedIndicator  string
+		response            *http.Response
+		responseBody        []byte
+		retainedCheckpoint  retainedCheckpointStruct
+		retainedCheckpoints retainedCheckpointsStruct
+	)
+
+	response, err = http.Get("http://" + ipAddrTCPPort + "/volume/" + volumeName + "/checkpoint?compact=true")
+	if nil != err {
+		log.Fatalf("GET of retained checkpoints failed: %v", err)
+	}
+
+	responseBody, err = ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if nil != err {
+		log.Fatalf("reading retained checkpoints failed: %v", err)
+	}
+
+	if http.StatusOK != response.StatusCode {
+		log.Fatalf("GET of retained checkpoints failed: %s", response.Status)
+	}
+
+	err = json.Unmarshal(responseBody, &retainedCheckpoints)
+	if nil != err {
+		log.Fatalf("parsing retained checkpoints failed: %v", err)
+	}
+
+	for _, retainedCheckpoint = range retainedCheckpoints.RetainedCheckpoints {
+		if retainedCheckpoint.ID == retainedCheckpoints.InspectedID {
+			inspectedIndicator = " (inspecting)"
+		} else {
+			inspectedIndicator = ""
+		}
+		if retainedCheckpoint.Time.IsZero() {
+			fmt.Printf("%20d  %-35s%s\n", retainedCheckpoint.ID, "(unknown)", inspectedIndicator)
+		} else {
+			fmt.Printf("%20d  %-35s%s\n", retainedCheckpoint.ID, retainedCheckpoint.Time.Format(time.RFC3339Nano), inspectedIndicator)
+		}
+	}
+}
+
+func postCheckpointAction(ipAddrTCPPort string, path string, action string) {
+	var (
+		err      error
+		response *http.Response
+	)
+
+	response, err = http.Post("http://"+ipAddrTCPPort+path, "application/x-www-form-urlencoded", strings.NewReader(url.Values{"action": {action}}.Encode()))
+	if nil != err {
+		log.Fatalf("POST of action=%s failed: %v", action, err)
+	}
+	_ = response.Body.Close()
+
+	if http.StatusNoContent != response.StatusCode {
+		log.Fatalf("POST of action=%s failed: %s", action, response.Status)
+	}
+}
@@ -55,6 +55,7 @@ differences that must be called out.
   /c/rainbow. The files /c/red et cetera will be deleted as a result of this
   request.
 """
+import base64
 import contextlib
 import datetime
 import eventlet
@@ -70,6 +71,7 @@ import socket
 import time
 import uuid
 import xml.etree.ElementTree as ET
+import zlib
 from six.moves.urllib import parse as urllib_parse
 from io import BytesIO
 
@@ -105,8 +107,15 @@ PATH_MAX = 4096
 # Used for content type of directories in container listings
 DIRECTORY_CONTENT_TYPE = "application/directory"
 
+# Used for content type of delete markers in versioned container listings
+DELETE_MARKER_CONTENT_TYPE = "application/x-deleted;swift_versions_deleted=1"
+
 ZERO_FILL_PATH = "/0"
 
+# Read plan entries whose data ProxyFS supplies directly (e.g. inlined in the
+# file's inode) are served from INLINE_DATA_PATH_PREFIX + <index>.
+INLINE_DATA_PATH_PREFIX = "/0/inline/"
+
 LEASE_RENEWAL_INTERVAL = 5  # seconds
 
 # Beware: ORIGINAL_MD5_HEADER is random case, not title case, but is
@@ -117,6 +126,10 @@ S3API_ETAG_HEADER = "X-Object-Sysmeta-S3Api-Etag"
 LISTING_ETAG_OVERRIDE_HEADER = \
     "X-Object-Sysmeta-Container-Update-Override-Etag"
 
+# The ID of the proxyfsd multipart upload session begun on behalf of an S3
+# multipart upload is saved on the upload's marker object.
+MULTIPART_UPLOAD_ID_HEADER = "X-Object-Sysmeta-Pfs-Multipart-Upload-Id"
+
 # They don't start with X-Object-(Meta|Sysmeta)-, but we save them anyway.
 SPECIAL_OBJECT_METADATA_HEADERS = {
     "Content-Type",
@@ -135,6 +148,7 @@ SPECIAL_CONTAINER_METADATA_HEADERS = {
     "X-Container-Write",
     "X-Container-Sync-Key",
     "X-Container-Sync-To",
+    "X-Versions-Enabled",
     "X-Versions-Location"}
 
 # ProxyFS directories don't know how many objects are under them, nor how
@@ -161,15 +175,26 @@ MD5_ETAG_RE = re.compile("^[a-f0-9]{32}$")
 
 EMPTY_OBJECT_ETAG = "d41d8cd98f00b204e9800998ecf8427e"
 
+# As enforced by proxyfsd for multipart uploads
+MULTIPART_MAX_PART_NUMBER = 10000
+
 RPC_TIMEOUT_DEFAULT = 30.0
 MAX_RPC_BODY_SIZE = 2 ** 20
 
 
-def listing_iter_from_read_plan(read_plan):
+def listing_iter_from_read_plan(read_plan, inline_data):
     """
     Takes a read plan from proxyfsd and turns it into an iterable of
     tuples suitable for passing to SegmentedIterable.
 
+    Entries carrying their (base64-encoded) "Data" in lieu of an
+    "ObjectPath" have that data appended to inline_data and are
+    referenced by their index therein for InlineDataFiller to serve.
+    Likewise, entries referencing a compressed frame (those with a
+    non-zero "CompressedLength") have a CompressedFrame appended to
+    inline_data that InlineDataFiller decompresses only when its turn
+    comes to be served.
+
     Example read plan:
 
     [
@@ -212,12 +237,28 @@ def listing_iter_from_read_plan(read_plan):
     # RPC-response parser all the way to here, but it's inefficient, in both
     # CPU cycles and programmer brainpower, to create some intermediate
     # representation just to avoid GoCase.
-    return [(rpe["ObjectPath"] or ZERO_FILL_PATH,
-             None,  # we don't know the segment's ETag
-             None,  # we don't know the segment's length
-             rpe["Offset"],
-             rpe["Offset"] + rpe["Length"] - 1)
-            for rpe in read_plan]
+    listing = []
+    for rpe in read_plan:
+        if rpe.get("Data") is not None:
+            inline_data.append(base64.b64decode(rpe["Data"]))
+            listing.append((
+                INLINE_DATA_PATH_PREFIX + str(len(inline_data) - 1),
+                None, None, 0, rpe["Length"] - 1))
+        elif rpe.get("CompressedLength"):
+            inline_data.append(CompressedFrame(
+                rpe["ObjectPath"], rpe["Offset"], rpe["CompressedLength"]))
+            listing.append((
+                INLINE_DATA_PATH_PREFIX + str(len(inline_data) - 1),
+                None, None, rpe["FrameOffset"],
+                rpe["FrameOffset"] + rpe["Length"] - 1))
+        else:
+            listing.append((
+                rpe["ObjectPath"] or ZERO_FILL_PATH,
+                None,  # we don't know the segment's ETag
+                None,  # we don't know the segment's length
+                rpe["Offset"],
+                rpe["Offset"] + rpe["Length"] - 1))
+    return listing
 
 
 def x_timestamp_from_epoch_ns(epoch_ns):
@@ -449,6 +490,19 @@ def extract_container_metadata_from_headers(req):
     return meta_headers
 
 
+def strip_slo_metadata(obj_metadata):
+    """
+    Remove the headers that apply only to SLO objects from obj_metadata.
+    """
+    unwanted_headers = ['X-Static-Large-Object']
+    for header in obj_metadata.keys():
+        if header.startswith("X-Object-Sysmeta-Slo-"):
+            unwanted_headers.append(header)
+    for header in unwanted_headers:
+        if header in obj_metadata:
+            del obj_metadata[header]
+
+
 def mung_etags(obj_metadata, etag, num_writes):
     '''
     Mung the ETag headers that will be stored with an object.  The
@@ -621,6 +675,71 @@ class ZeroFiller(object):
             yield self.ZEROES[:n]
 
 
+class CompressedFrame(object):
+    """
+    A frame of a log segment that ProxyFS stored compressed (as raw
+    DEFLATE data). Only the frame itself (at most 64 KiB once
+    decompressed) is ever fetched and held in memory.
+    """
+    def __init__(self, object_path, offset, compressed_length):
+        self.object_path = object_path
+        self.offset = offset
+        self.compressed_length = compressed_length
+
+    def fetch(self, app, req):
+        """
+        Fetch and decompress the frame.
+
+        :returns: (decompressed frame, None) on success or (None, error
+                  response) should the frame's log segment GET (or its
+                  decompression) fail.
+        """
+        frame_req = swob.Request(req.environ.copy())
+        frame_req.path_info = self.object_path
+        frame_req.range = "bytes=%d-%d" % (
+            self.offset, self.offset + self.compressed_length - 1)
+        frame_resp = frame_req.get_response(app)
+        if not frame_resp.is_success:
+            return None, frame_resp
+        try:
+            return zlib.decompress(frame_resp.body, -zlib.MAX_WBITS), None
+        except zlib.error:
+            return None, swob.HTTPInternalServerError(request=req)
+
+
+class InlineDataFiller(object):
+    """
+    Internal middleware to handle the portions of object GET responses whose
+    data ProxyFS supplied directly in the read plan or that reside in
+    compressed frames (see listing_iter_from_read_plan). One is constructed
+    per GET request.
+    """
+    def __init__(self, app, inline_data):
+        self.app = app
+        self.inline_data = inline_data
+
+    @swob.wsgify
+    def __call__(self, req):
+        if req.path.startswith(INLINE_DATA_PATH_PREFIX):
+            data = self.inline_data[
+                int(req.path[len(INLINE_DATA_PATH_PREFIX):])]
+            if isinstance(data, CompressedFrame):
+                data, error_resp = data.fetch(self.app, req)
+                if error_resp is not None:
+                    return error_resp
+            start, end = req.range.ranges[0]
+            nbytes = end - start + 1
+            resp = swob.Response(
+                request=req, status=206,
+                headers={"Content-Length": nbytes,
+                         "Content-Range": "%d-%d/%d" % (start, end,
+                                                        len(data))},
+                body=data[start:end + 1])
+            return resp
+        else:
+            return self.app
+
+
 class SnoopingInput(object):
     """
     Wrap WSGI input and call a provided callback every time data is read.
@@ -857,8 +976,13 @@ class PfsMiddleware(object):
                         return swob.HTTPMethodNotAllowed(request=req)
                     return self.app
 
-                # Otherwise, dispatch to a helper method
-                if method == 'GET' and obj:
+                # Otherwise, dispatch to a helper method (requests made on
+                # behalf of an S3 multipart upload first, as they may target
+                # the upload rather than the object or container named)
+                resp = self.s3_multipart(ctx)
+                if resp is not None:
+                    pass
+                elif method == 'GET' and obj:
                     resp = self.get_object(ctx)
                 elif method == 'HEAD' and obj:
                     resp = self.head_object(ctx)
@@ -1365,12 +1489,13 @@ class PfsMiddleware(object):
         end_marker = req.params.get('end_marker', '')
         prefix = req.params.get('prefix', '')
         delimiter = req.params.get('delimiter', '')
+        versions = 'versions' in req.params
         # For now, we only support "/" as a delimiter
         if delimiter not in ("", "/"):
             return swob.HTTPBadRequest(request=req)
         get_container_request = rpc.get_container_request(
             urllib_parse.unquote(req.path),
-            marker, end_marker, limit, prefix, delimiter)
+            marker, end_marker, limit, prefix, delimiter, versions)
         try:
             get_container_response = self.rpc_call(ctx, get_container_request)
         except utils.RpcError as err:
@@ -1390,7 +1515,7 @@ class PfsMiddleware(object):
                 container_ents)
         elif resp_content_type == "application/json":
             resp.body = self._json_container_get_response(
-                container_ents, ctx.account_name, delimiter)
+                container_ents, ctx.account_name, delimiter, versions)
         elif resp_content_type.endswith("/xml"):
             resp.body = self._xml_container_get_response(
                 container_ents, ctx.account_name, ctx.container_name)
@@ -1414,7 +1539,7 @@ class PfsMiddleware(object):
         return b''.join(chunks)
 
     def _json_container_get_response(self, container_entries, account_name,
-                                     delimiter):
+                                     delimiter, versions=False):
         json_entries = []
         for ent in container_entries:
             name = ent["Basename"]
@@ -1444,6 +1569,11 @@ class PfsMiddleware(object):
                 "content_type": content_type,
                 "hash": etag,
                 "last_modified": last_modified}
+            if versions and not ent["IsDir"]:
+                json_entry["version_id"] = str(ent.get("VersionID", 0))
+                json_entry["is_latest"] = ent.get("IsLatest", False)
+                if ent.get("IsDeleteMarker", False):
+                    json_entry["content_type"] = DELETE_MARKER_CONTENT_TYPE
             json_entries.append(json_entry)
 
             if delimiter != "" and "IsDir" in ent and ent["IsDir"]:
@@ -1606,12 +1736,80 @@ class PfsMiddleware(object):
         req = ctx.req
 
         virtual_path = urllib_parse.unquote(req.path)
-        put_location_req = rpc.put_location_request(virtual_path)
 
         request_etag = req.headers.get("ETag", "")
         hasher = hashlib.md5()
         wsgi_input = SnoopingInput(req.environ["wsgi.input"], hasher.update)
 
+        log_segments, error_response = self._put_log_segments(
+            ctx, virtual_path, wsgi_input)
+        if error_response:
+            return error_response
+
+        if should_validate_etag(request_etag) and \
+                hasher.hexdigest() != request_etag:
+            return swob.HTTPUnprocessableEntity(request=req)
+
+        # All the data is now in Swift; we just have to tell proxyfsd
+        # about it.  Mung any passed ETags values to include the
+        # number of writes to the file (basically, the object's update
+        # count) and supply the MD5 hash computed here which becomes
+        # object's future ETag value until the object updated.
+        obj_metadata = extract_object_metadata_from_headers(req.headers)
+        mung_etags(obj_metadata, hasher.hexdigest(), len(log_segments))
+
+        put_complete_req = rpc.put_complete_request(
+            virtual_path, log_segments, serialize_metadata(obj_metadata))
+        try:
+            mtime_ns, inode, __writes = rpc.parse_put_complete_response(
+                self.rpc_call(ctx, put_complete_req))
+        except utils.RpcError as err:
+            # We deliberately don't try to clean up our log segments on
+            # failure. ProxyFS is responsible for cleaning up unreferenced
+            # log segments.
+            if err.errno == pfs_errno.NotEmptyError:
+                return swob.HTTPConflict(
+                    request=req,
+                    headers={"Content-Type": "text/plain"},
+                    body="This is a non-empty directory")
+            elif err.errno == pfs_errno.NotDirError:
+                return swob.HTTPConflict(
+                    request=req,
+                    headers={"Content-Type": "text/plain"},
+                    body="Path element is a file, not a directory")
+            else:
+                # punt to top-level error handler
+                raise
+
+        # For reference, an object PUT response to plain Swift looks like:
+        # HTTP/1.1 201 Created
+        # Last-Modified: Thu, 08 Dec 2016 22:51:13 GMT
+        # Content-Length: 0
+        # Etag: 9303a8d23189779e71f347032d633327
+        # Content-Type: text/html; charset=UTF-8
+        # X-Trans-Id: tx7b3e2b88df2f4975a5476-005849e3e0dfw1
+        # Date: Thu, 08 Dec 2016 22:51:12 GMT
+        #
+        # We get Content-Length, X-Trans-Id, and Date for free, but we need
+        # to fill in the rest.
+        resp_headers = {
+            "Etag": hasher.hexdigest(),
+            "Content-Type": guess_content_type(req.path, False),
+            "Last-Modified": last_modified_from_epoch_ns(mtime_ns)}
+        return swob.HTTPCreated(request=req, headers=resp_headers, body="")
+
+    def _put_log_segments(self, ctx, virtual_path, wsgi_input):
+        """
+        Write the data read from wsgi_input to as many log segments
+        (provisioned on behalf of the object at virtual_path) as it takes.
+
+        :returns: 2-tuple (log segments, error response). The log segments
+            are a list of 2-tuples (segment-name, segment-size). The error
+            response is None unless the data could not be written.
+        """
+        req = ctx.req
+        put_location_req = rpc.put_location_request(virtual_path)
+
         # TODO: when the upload size is known (i.e. Content-Length is set),
         # ask for enough locations up front that we can consume the whole
         # request with only one call to RpcPutLocation(s).
@@ -1629,7 +1827,7 @@ class PfsMiddleware(object):
 
         error_response = swift_code.check_object_creation(req)
         if error_response:
-            return error_response
+            return None, error_response
 
         # Since this upload can be arbitrarily large, we split it across
         # multiple log segments.
@@ -1669,62 +1867,12 @@ class PfsMiddleware(object):
             subresp = subreq.get_response(self.app)
             if not 200 <= subresp.status_int < 299:
                 # Something went wrong; may as well bail out now
-                return subresp
+                return None, subresp
 
             log_segments.append((phys_path, subinput.bytes_read))
             i += 1
 
-        if should_validate_etag(request_etag) and \
-                hasher.hexdigest() != request_etag:
-            return swob.HTTPUnprocessableEntity(request=req)
-
-        # All the data is now in Swift; we just have to tell proxyfsd
-        # about it.  Mung any passed ETags values to include the
-        # number of writes to the file (basically, the object's update
-        # count) and supply the MD5 hash computed here which becomes
-        # object's future ETag value until the object updated.
-        obj_metadata = extract_object_metadata_from_headers(req.headers)
-        mung_etags(obj_metadata, hasher.hexdigest(), len(log_segments))
-
-        put_complete_req = rpc.put_complete_request(
-            virtual_path, log_segments, serialize_metadata(obj_metadata))
-        try:
-            mtime_ns, inode, __writes = rpc.parse_put_complete_response(
-                self.rpc_call(ctx, put_complete_req))
-        except utils.RpcError as err:
-            # We deliberately don't try to clean up our log segments on
-            # failure. ProxyFS is responsible for cleaning up unreferenced
-            # log segments.
-            if err.errno == pfs_errno.NotEmptyError:
-                return swob.HTTPConflict(
-                    request=req,
-                    headers={"Content-Type": "text/plain"},
-                    body="This is a non-empty directory")
-            elif err.errno == pfs_errno.NotDirError:
-                return swob.HTTPConflict(
-                    request=req,
-                    headers={"Content-Type": "text/plain"},
-                    body="Path element is a file, not a directory")
-            else:
-                # punt to top-level error handler
-                raise
-
-        # For reference, an object PUT response to plain Swift looks like:
-        # HTTP/1.1 201 Created
-        # Last-Modified: Thu, 08 Dec 2016 22:51:13 GMT
-        # Content-Length: 0
-        # Etag: 9303a8d23189779e71f347032d633327
-        # Content-Type: text/html; charset=UTF-8
-        # X-Trans-Id: tx7b3e2b88df2f4975a5476-005849e3e0dfw1
-        # Date: Thu, 08 Dec 2016 22:51:12 GMT
-        #
-        # We get Content-Length, X-Trans-Id, and Date for free, but we need
-        # to fill in the rest.
-        resp_headers = {
-            "Etag": hasher.hexdigest(),
-            "Content-Type": guess_content_type(req.path, False),
-            "Last-Modified": last_modified_from_epoch_ns(mtime_ns)}
-        return swob.HTTPCreated(request=req, headers=resp_headers, body="")
+        return log_segments, None
 
     def post_object(self, ctx):
         req = ctx.req
@@ -1764,10 +1912,13 @@ class PfsMiddleware(object):
     def get_object(self, ctx):
         req = ctx.req
         byteranges = req.range.ranges if req.range else ()
+        version_id = req.params.get('version-id')
+        if version_id is not None and not version_id.isdigit():
+            return swob.HTTPBadRequest(request=req)
 
         try:
             object_response = self.rpc_call(ctx, rpc.get_object_request(
-                urllib_parse.unquote(req.path), byteranges))
+                urllib_parse.unquote(req.path), byteranges, version_id))
         except utils.RpcError as err:
             if err.errno in (pfs_errno.NotFoundError, pfs_errno.NotDirError):
                 return swob.HTTPNotFound(request=req)
@@ -1833,7 +1984,8 @@ class PfsMiddleware(object):
         channel = eventlet.queue.Queue(0)
         eventlet.spawn_n(self._keep_lease_alive, ctx, channel, lease_id)
 
-        listing_iter = listing_iter_from_read_plan(read_plan)
+        inline_data = []
+        listing_iter = listing_iter_from_read_plan(read_plan, inline_data)
         # Make sure that nobody (like our __call__ method) messes with this
         # environment once we've started. Otherwise, the auth callback may
         # reappear, causing log-segment GET requests to fail. This may be
@@ -1866,7 +2018,8 @@ class PfsMiddleware(object):
             listing_iter, done_with_object_get)
 
         seg_iter = swift_code.SegmentedIterable(
-            copied_req, self.zero_filler_app, wrapped_listing_iter,
+            copied_req, InlineDataFiller(self.zero_filler_app, inline_data),
+            wrapped_listing_iter,
             self.max_get_time,
             self.logger, 'PFS', 'PFS',
             name=req.path)
@@ -1921,9 +2074,13 @@ class PfsMiddleware(object):
         channel.put("alright, it's done")
 
     def delete_object(self, ctx):
+        version_id = ctx.req.params.get('version-id')
+        if version_id is not None and not version_id.isdigit():
+            return swob.HTTPBadRequest(request=ctx.req)
+
         try:
             self.rpc_call(ctx, rpc.delete_request(
-                urllib_parse.unquote(ctx.req.path)))
+                urllib_parse.unquote(ctx.req.path), version_id))
         except utils.RpcError as err:
             if err.errno in (pfs_errno.NotFoundError, pfs_errno.NotDirError):
                 return swob.HTTPNotFound(request=ctx.req)
@@ -1935,7 +2092,12 @@ class PfsMiddleware(object):
 
     def head_object(self, ctx):
         req = ctx.req
-        head_request = rpc.head_request(urllib_parse.unquote(req.path))
+        version_id = req.params.get('version-id')
+        if version_id is not None and not version_id.isdigit():
+            return swob.HTTPBadRequest(request=req)
+
+        head_request = rpc.head_request(urllib_parse.unquote(req.path),
+                                        version_id)
         try:
             head_response = self.rpc_call(ctx, head_request)
         except utils.RpcError as err:
@@ -2045,13 +2207,7 @@ class PfsMiddleware(object):
         obj_metadata = extract_object_metadata_from_headers(req.headers)
 
         # strip out headers that apply only to SLO objects
-        unwanted_headers = ['X-Static-Large-Object']
-        for header in obj_metadata.keys():
-            if header.startswith("X-Object-Sysmeta-Slo-"):
-                unwanted_headers.append(header)
-        for header in unwanted_headers:
-            if header in obj_metadata:
-                del obj_metadata[header]
+        strip_slo_metadata(obj_metadata)
 
         # Now that we know the number of writes (really number of objects) we
         # can mung the sundry ETag headers.
@@ -2099,6 +2255,289 @@ class PfsMiddleware(object):
 
         return swob.HTTPCreated(request=req, headers=headers)
 
+    def s3_multipart(self, ctx):
+        """
+        Handle a request that s3_compat marked (see utils.ENV_S3_MULTIPART)
+        as made on behalf of an S3 multipart upload.
+
+        :returns: the response, or None should the request be handled as
+            if it were not marked. This is the case for an upload marker
+            PUT (once its session has been initiated) and DELETE (once its
+            session has been aborted) as well as for any upload initiated
+            without a session.
+        """
+        marking = ctx.req.environ.get(utils.ENV_S3_MULTIPART)
+        if not marking:
+            return None
+
+        if marking["op"] == "initiate":
+            return self._s3_multipart_initiate(ctx, marking)
+
+        upload_id = self._s3_multipart_upload_id(ctx, marking)
+        if upload_id is None:
+            return None
+
+        if marking["op"] == "put_part":
+            return self._s3_multipart_put_part(ctx, marking, upload_id)
+        elif marking["op"] == "head_part":
+            return self._s3_multipart_head_part(ctx, marking, upload_id)
+        elif marking["op"] == "list_parts":
+            return self._s3_multipart_list_parts(ctx, marking, upload_id)
+        elif marking["op"] == "complete":
+            return self._s3_multipart_complete(ctx, marking, upload_id)
+        elif marking["op"] == "abort":
+            return self._s3_multipart_abort(ctx, marking, upload_id)
+        else:
+            raise Exception("unexpected S3 multipart op %r" %
+                            (marking["op"],))
+
+    def _s3_multipart_upload_id(self, ctx, marking):
+        """
+        Return the ID of the session recorded on the upload marker, or None
+        if there is no such upload marker or it records no session.
+        """
+        try:
+            head_response = self.rpc_call(
+                ctx, rpc.head_request(marking["marker_path"]))
+        except utils.RpcError as err:
+            if err.errno in (pfs_errno.NotFoundError, pfs_errno.NotDirError):
+                return None
+            else:
+                raise
+
+        raw_md = rpc.parse_head_response(head_response)[0]
+        return deserialize_metadata(raw_md).get(MULTIPART_UPLOAD_ID_HEADER)
+
+    def _s3_multipart_initiate(self, ctx, marking):
+        req = ctx.req
+        obj_metadata = extract_object_metadata_from_headers(req.headers)
+
+        try:
+            upload_id = rpc.parse_multipart_initiate_response(
+                self.rpc_call(ctx, rpc.multipart_initiate_request(
+                    marking["object_path"],
+                    serialize_metadata(obj_metadata))))
+        except utils.RpcError as err:
+            if err.errno in (pfs_errno.NotFoundError, pfs_errno.NotDirError):
+                return swob.HTTPNotFound(request=req)
+            elif err.errno == pfs_errno.InvalidArgError:
+                return swob.HTTPBadRequest(request=req)
+            else:
+                raise
+
+        # The upload marker itself is PUT as usual. Should that fail, the
+        # session is left to expire.
+        req.headers[MULTIPART_UPLOAD_ID_HEADER] = upload_id
+        return None
+
+    def _s3_multipart_put_part(self, ctx, marking, upload_id):
+        req = ctx.req
+
+        request_etag = req.headers.get("ETag", "")
+        hasher = hashlib.md5()
+        wsgi_input = SnoopingInput(req.environ["wsgi.input"], hasher.update)
+
+        log_segments, error_response = self._put_log_segments(
+            ctx, marking["object_path"], wsgi_input)
+        if error_response:
+            return error_response
+
+        if should_validate_etag(request_etag) and \
+                hasher.hexdigest() != request_etag:
+            return swob.HTTPUnprocessableEntity(request=req)
+
+        try:
+            self.rpc_call(ctx, rpc.multipart_put_part_request(
+                marking["object_path"], upload_id, marking["part_number"],
+                log_segments, hasher.hexdigest()))
+        except utils.RpcError as err:
+            # As with an object PUT, ProxyFS is responsible for cleaning up
+            # our (now unreferenced) log segments.
+            if err.errno == pfs_errno.NotFoundError:
+                return swob.HTTPNotFound(request=req)
+            elif err.errno == pfs_errno.InvalidArgError:
+                return swob.HTTPBadRequest(request=req)
+            else:
+                raise
+
+        resp_headers = {
+            "Etag": hasher.hexdigest(),
+            "Content-Type": guess_content_type(req.path, False)}
+        return swob.HTTPCreated(request=req, headers=resp_headers, body="")
+
+    def _s3_multipart_head_part(self, ctx, marking, upload_id):
+        req = ctx.req
+        part_number = marking["part_number"]
+        if not 0 < part_number <= MULTIPART_MAX_PART_NUMBER:
+            return swob.HTTPNotFound(request=req)
+
+        # Listing from the start of the upload may first encounter parts
+        # not yet added, so only then is the default maximum used
+        try:
+            parts, _ = rpc.parse_multipart_list_parts_response(
+                self.rpc_call(ctx, rpc.multipart_list_parts_request(
+                    marking["object_path"], upload_id, part_number - 1,
+                    1 if part_number > 1 else 0)))
+        except utils.RpcError as err:
+            if err.errno == pfs_errno.NotFoundError:
+                return swob.HTTPNotFound(request=req)
+            else:
+                raise
+
+        for part in parts:
+            if part["PartNumber"] == part_number:
+                break
+        else:
+            return swob.HTTPNotFound(request=req)
+
+        headers = {
+            "Content-Length": part["Size"],
+            "Content-Type": guess_content_type(req.path, False),
+            "ETag": part["ETag"],
+            "Last-Modified": last_modified_from_epoch_ns(
+                part["ModificationTime"]),
+            "X-Timestamp": x_timestamp_from_epoch_ns(
+                part["ModificationTime"])}
+        return swob.HTTPOk(request=req, headers=headers,
+                           conditional_response=True)
+
+    def _s3_multipart_list_parts(self, ctx, marking, upload_id):
+        req = ctx.req
+        if swift_code.get_listing_content_type(req) != "application/json":
+            return None
+
+        limit = self._get_listing_limit(
+            req, self._default_container_listing_limit())
+
+        # Parts are listed in part number order starting after the part
+        # named by marker (if any)
+        prefix = utils.parse_path(marking["marker_path"])[3] + "/"
+        marker = req.params.get('marker', '')
+        part_number_marker = 0
+        if marker.startswith(prefix) and marker[len(prefix):].isdigit():
+            part_number_marker = min(int(marker[len(prefix):]),
+                                     MULTIPART_MAX_PART_NUMBER)
+
+        parts = []
+        while len(parts) < limit:
+            try:
+                page, more_parts = rpc.parse_multipart_list_parts_response(
+                    self.rpc_call(ctx, rpc.multipart_list_parts_request(
+                        marking["object_path"], upload_id,
+                        part_number_marker, 0)))
+            except utils.RpcError as err:
+                if err.errno == pfs_errno.NotFoundError:
+                    return swob.HTTPNotFound(request=req)
+                else:
+                    raise
+            parts.extend(page)
+            if not page or not more_parts:
+                break
+            part_number_marker = page[-1]["PartNumber"]
+
+        json_entries = []
+        for part in parts[:limit]:
+            json_entries.append({
+                "name": prefix + str(part["PartNumber"]),
+                "bytes": part["Size"],
+                "content_type": guess_content_type(req.path, False),
+                "hash": part["ETag"],
+                "last_modified": iso_timestamp_from_epoch_ns(
+                    part["ModificationTime"])})
+
+        resp = swob.HTTPOk(content_type="application/json", charset="utf-8",
+                           request=req,
+                           body=json.dumps(json_entries).encode('ascii'))
+        self._add_required_container_headers(resp)
+        return resp
+
+    def _s3_multipart_complete(self, ctx, marking, upload_id):
+        req = ctx.req
+
+        err = constraints.check_metadata(req, 'object')
+        if err:
+            return err
+
+        req_etag = req.headers.get('ETag')
+
+        obj_metadata = extract_object_metadata_from_headers(req.headers)
+        strip_slo_metadata(obj_metadata)
+        obj_metadata.pop(MULTIPART_UPLOAD_ID_HEADER, None)
+
+        # proxyfsd counts both the truncation of the object and the
+        # appending of each part as writes. Any writes to the object prior
+        # to its truncation (i.e. if it already existed) also count, so the
+        # munged ETags are corrected below should they not be current.
+        num_writes = len(marking["parts"]) + 1
+        munged_metadata = dict(obj_metadata)
+        mung_etags(munged_metadata, req_etag, num_writes)
+        raw_munged_metadata = serialize_metadata(munged_metadata)
+
+        try:
+            complete_response = self.rpc_call(
+                ctx, rpc.multipart_complete_request(
+                    marking["object_path"], upload_id, marking["parts"],
+                    raw_munged_metadata))
+        except utils.RpcError as err:
+            if err.errno == pfs_errno.NotFoundError:
+                return swob.HTTPNotFound(
+                    request=req,
+                    headers={"Content-Type": "text/plain"},
+                    body="No such upload")
+            elif err.errno == pfs_errno.InvalidArgError:
+                return swob.HTTPBadRequest(
+                    request=req,
+                    headers={"Content-Type": "text/plain"},
+                    body="One or more parts not found or not matching")
+            elif err.errno in (pfs_errno.NotDirError, pfs_errno.IsDirError):
+                return swob.HTTPConflict(
+                    request=req,
+                    headers={"Content-Type": "text/plain"},
+                    body="Path element is a file, not a directory, or the "
+                         "object is a directory")
+            else:
+                raise
+
+        last_modified_ns, inum, actual_num_writes = \
+            rpc.parse_multipart_complete_response(complete_response)
+
+        if actual_num_writes != num_writes:
+            remunged_metadata = dict(obj_metadata)
+            mung_etags(remunged_metadata, req_etag, actual_num_writes)
+            try:
+                self.rpc_call(ctx, rpc.post_request(
+                    marking["object_path"], raw_munged_metadata,
+                    serialize_metadata(remunged_metadata)))
+                munged_metadata = remunged_metadata
+            except utils.RpcError:
+                # The object has changed since, so its munged ETags are
+                # stale regardless
+                pass
+
+        unmung_etags(munged_metadata, actual_num_writes)
+        headers = {}
+        headers["Etag"] = best_possible_etag(
+            munged_metadata, ctx.account_name, inum, actual_num_writes)
+        headers["Last-Modified"] = last_modified_from_epoch_ns(
+            last_modified_ns)
+        headers["X-Timestamp"] = x_timestamp_from_epoch_ns(
+            last_modified_ns)
+
+        return swob.HTTPCreated(request=req, headers=headers)
+
+    def _s3_multipart_abort(self, ctx, marking, upload_id):
+        # swift3 also deletes the upload marker of a completed upload, whose
+        # session is gone by then
+        try:
+            self.rpc_call(ctx, rpc.multipart_abort_request(
+                marking["object_path"], upload_id))
+        except utils.RpcError as err:
+            if err.errno != pfs_errno.NotFoundError:
+                raise
+
+        # The upload marker itself is DELETEd as usual
+        return None
+
     def _unpack_owning_proxyfs(self, req):
         """
         Checks to see if an account is bimodal or not, and if so, which proxyfs
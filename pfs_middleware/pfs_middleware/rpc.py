@@ -173,13 +173,15 @@ def parse_is_account_bimodal_response(resp):
     return (is_bimodal, ip)
 
 
-def get_object_request(path, http_ranges):
+def get_object_request(path, http_ranges, version_id=None):
     """
     Return a JSON-RPC request to get a read plan and other info for a
     particular object.
 
     :param path: URL path component for the object, e.g. "/v1/acc/con/obj"
     :param ranges: HTTP byte-ranges from the HTTP request's Range header
+    :param version_id: version of the object to read (None for the current
+                       version)
     """
     # This RPC method takes one positional argument, which is a JSON object
     # with two fields: the path and the ranges.
@@ -199,9 +201,11 @@ def get_object_request(path, http_ranges):
             rpc_range = {"Offset": start, "Len": end - start + 1}
         rpc_ranges.append(rpc_range)
 
-    return jsonrpc_request("Server.RpcGetObject",
-                           [{"VirtPath": path,
-                             "ReadEntsIn": rpc_ranges}])
+    rpc_args = {"VirtPath": path, "ReadEntsIn": rpc_ranges}
+    if version_id:
+        rpc_args["VersionID"] = int(version_id)
+
+    return jsonrpc_request("Server.RpcGetObject", [rpc_args])
 
 
 def parse_get_object_response(read_plan_response):
@@ -317,6 +321,135 @@ def parse_put_complete_response(put_complete_response):
             put_complete_response["NumWrites"])
 
 
+def multipart_initiate_request(virtual_path, obj_metadata):
+    """
+    Return a JSON-RPC request to begin a multipart upload of an object.
+
+    :param virtual_path: path component for the object being uploaded,
+        e.g. "/v1/acc/con/obj"
+
+    :param obj_metadata: serialized object metadata (applied upon
+        completion unless other metadata is supplied then)
+    """
+    return jsonrpc_request("Server.RpcMultipartInitiate", [{
+        "VirtPath": virtual_path,
+        "Metadata": _encode_binary(obj_metadata)}])
+
+
+def parse_multipart_initiate_response(multipart_initiate_response):
+    """
+    Parse a response from RpcMultipartInitiate.
+
+    :returns: the upload ID
+    """
+    return multipart_initiate_response["UploadID"]
+
+
+def multipart_put_part_request(virtual_path, upload_id, part_number,
+                               log_segments, etag):
+    """
+    Return a JSON-RPC request to add a part to a multipart upload (replacing
+    any prior part of the same number).
+
+    This is used just like put_complete_request; the part's data has
+    already been written to the log segments.
+
+    :param log_segments: the log segments containing the data and their
+        sizes. Comes as a list of 2-tuples (segment-name, segment-size).
+
+    :param etag: the part's ETag (compared to that supplied upon
+        completion)
+    """
+    return jsonrpc_request("Server.RpcMultipartPutPart", [{
+        "VirtPath": virtual_path,
+        "UploadID": upload_id,
+        "PartNumber": part_number,
+        "PhysPaths": [ls[0] for ls in log_segments],
+        "PhysLengths": [ls[1] for ls in log_segments],
+        "ETag": etag}])
+
+
+# NB: there is no parse_multipart_put_part_response since a successful
+# response to RpcMultipartPutPart contains no useful information.
+
+
+def multipart_list_parts_request(virtual_path, upload_id, part_number_marker,
+                                 max_parts):
+    """
+    Return a JSON-RPC request to list (in part number order) the parts of a
+    multipart upload following part_number_marker (0 to start with the first
+    part).
+    """
+    return jsonrpc_request("Server.RpcMultipartListParts", [{
+        "VirtPath": virtual_path,
+        "UploadID": upload_id,
+        "PartNumberMarker": part_number_marker,
+        "MaxParts": max_parts}])
+
+
+def parse_multipart_list_parts_response(multipart_list_parts_response):
+    """
+    Parse a response from RpcMultipartListParts.
+
+    Returns: (parts, more parts)
+
+    The parts are a list of dictionaries with keys:
+
+        PartNumber: the part number
+
+        ETag: the part's ETag
+
+        Size: the part's size in bytes
+
+        ModificationTime: when the part was added, in nanoseconds since
+        the epoch
+    """
+    return (multipart_list_parts_response["Parts"] or [],
+            multipart_list_parts_response["MoreParts"])
+
+
+def multipart_complete_request(virtual_path, upload_id, parts, obj_metadata):
+    """
+    Return a JSON-RPC request to assemble the listed parts of a multipart
+    upload into the object.
+
+    :param parts: list of dictionaries with keys PartNumber and ETag, in
+        ascending order of part number
+
+    :param obj_metadata: serialized object metadata
+    """
+    return jsonrpc_request("Server.RpcMultipartComplete", [{
+        "VirtPath": virtual_path,
+        "UploadID": upload_id,
+        "Parts": parts,
+        "Metadata": _encode_binary(obj_metadata)}])
+
+
+def parse_multipart_complete_response(multipart_complete_response):
+    """
+    Parse a response from RpcMultipartComplete.
+
+    Returns (modification time, inode no., no. writes).
+    """
+    return (_ctime_or_mtime(multipart_complete_response),
+            multipart_complete_response["InodeNumber"],
+            multipart_complete_response["NumWrites"])
+
+
+def multipart_abort_request(virtual_path, upload_id):
+    """
+    Return a JSON-RPC request to discard a multipart upload along with all
+    of its parts.
+    """
+    return jsonrpc_request("Server.RpcMultipartAbort", [{
+        "VirtPath": virtual_path,
+        "UploadID": upload_id}])
+
+
+# NB: there is no parse_multipart_abort_response since a successful
+# response to RpcMultipartAbort contains no useful information.
+
+
 def get_account_request(path, marker, end_marker, limit):
     """
     Return a JSON-RPC request to get a account listing for a given
@@ -364,7 +497,8 @@ def parse_get_account_response(get_account_response):
         return (mtime, account_entries)
 
 
-def get_container_request(path, marker, end_marker, limit, prefix, delimiter):
+def get_container_request(path, marker, end_marker, limit, prefix, delimiter,
+                          versions=False):
     """
     Return a JSON-RPC request to get a container listing for a given
     container.
@@ -385,14 +519,20 @@ def get_container_request(path, marker, end_marker, limit, prefix, delimiter):
 
     :param delimiter: delimiter value, which returns the object names that are
                       nested in the container
+
+    :param versions: if True, each object's prior versions follow it in the
+                     listing
     """
     # This RPC method takes one positional argument, which is a JSON object
     # with two fields: the path and the ranges.
-    return jsonrpc_request("Server.RpcGetContainer",
-                           [{"VirtPath": path, "Marker": marker,
-                             "EndMarker": end_marker,
-                             "MaxEntries": limit, "Prefix": prefix,
-                             "Delimiter": delimiter}])
+    rpc_args = {"VirtPath": path, "Marker": marker,
+                "EndMarker": end_marker,
+                "MaxEntries": limit, "Prefix": prefix,
+                "Delimiter": delimiter}
+    if versions:
+        rpc_args["Versions"] = True
+
+    return jsonrpc_request("Server.RpcGetContainer", [rpc_args])
 
 
 def parse_get_container_response(get_container_response):
@@ -415,6 +555,9 @@ def parse_get_container_response(get_container_response):
 
         Metadata: object's serialized metadata, if any
 
+        VersionID, IsLatest, IsDeleteMarker: describe the object version
+        (only meaningful if versions were requested)
+
     The container's metadata is just a string. Presumably it's some
     JSON-serialized dictionary that this middleware previously set, but it
     could really be anything.
@@ -460,14 +603,20 @@ def parse_middleware_mkdir_response(mkdir_resp):
             mkdir_resp["NumWrites"])
 
 
-def head_request(path):
+def head_request(path, version_id=None):
     """
     Return a JSON-RPC request to HEAD a container or object
 
     :param path: URL path component for the container or object,
     e.g. "/v1/acc/con"
+    :param version_id: version of the object to describe (None for the
+                       current version)
     """
-    return jsonrpc_request("Server.RpcHead", [{"VirtPath": path}])
+    rpc_args = {"VirtPath": path}
+    if version_id:
+        rpc_args["VersionID"] = int(version_id)
+
+    return jsonrpc_request("Server.RpcHead", [rpc_args])
 
 
 def parse_head_response(head_response):
@@ -488,13 +637,20 @@ def parse_head_response(head_response):
             head_response["InodeNumber"], head_response["NumWrites"])
 
 
-def delete_request(path):
+def delete_request(path, version_id=None):
     """
     Return a JSON-RPC request to delete a file or directory.
 
     :param path: path to the object or container, e.g. "/v1/a/c/o"
+    :param version_id: version of the object to delete (None to delete the
+                       current version, leaving a delete marker if the
+                       container retains prior versions)
     """
-    return jsonrpc_request("Server.RpcDelete", [{"VirtPath": path}])
+    rpc_args = {"VirtPath": path}
+    if version_id:
+        rpc_args["VersionID"] = int(version_id)
+
+    return jsonrpc_request("Server.RpcDelete", [rpc_args])
 
 
 # NB: there is no parse_delete_response since a successful response to
@@ -10,10 +10,27 @@ API to access data in bimodal ProxyFS accounts.
 
 Features:
 
+  * S3 multipart uploads are assembled by proxyfsd's multipart upload
+    sessions rather than from objects in the "<bucket>+segments" container.
+    The requests swift3 makes of that container on behalf of an upload are
+    recognized here and marked (see utils.ENV_S3_MULTIPART) for
+    pfs_middleware to act upon:
+
+      - the PUT of the upload marker ("<object>/<upload-id>") initiates a
+        session whose ID is recorded on the marker
+      - the PUT of a part ("<object>/<upload-id>/<part-number>") adds the
+        part to the session rather than creating an object
+      - the HEAD of a part (e.g. by SLO, validating the manifest) and the
+        listing of an upload's parts describe the session's parts
+      - the DELETE of the upload marker aborts the session (if it has not
+        already been completed)
+
   * To handle an S3 Complete Multipart Upload request, swift3 PUTs a SLO
     manifest. This middleware converts that manifest to a COALESCE request
     so that the resulting object works as expected for both HTTP and
-    filesystem access.
+    filesystem access. Should the manifest list the parts of a session,
+    the request is marked such that pfs_middleware completes the session
+    instead. Only uploads initiated without a session are coalesced.
 """
 
 import json
@@ -24,10 +41,72 @@ from swift.common import swob
 from swift.common.utils import get_logger
 
 
+# swift3 keeps the marker and parts of each multipart upload of an object
+# in <bucket> in the container <bucket> + MULTIUPLOAD_SUFFIX
+MULTIUPLOAD_SUFFIX = '+segments'
+
+
 def convert_slo_to_coalesce(slo_manifest):
     return {"elements": [e["name"].lstrip("/") for e in slo_manifest]}
 
 
+def split_segment_name(segment_name):
+    """
+    Split the name of an object in a "<bucket>+segments" container into
+    (object name, upload ID, part number). The part number is None for an
+    upload marker. Returns None if the name is neither.
+    """
+    name_parts = segment_name.rsplit('/', 2)
+    if len(name_parts) == 3 and name_parts[2].isdigit():
+        return (name_parts[0], name_parts[1], int(name_parts[2]))
+    name_parts = segment_name.rsplit('/', 1)
+    if len(name_parts) == 2 and name_parts[0] and name_parts[1]:
+        return (name_parts[0], name_parts[1], None)
+    return None
+
+
+def s3_multipart_marking(op, ver, acc, con, obj, upload_id, **kwargs):
+    """
+    Return the utils.ENV_S3_MULTIPART marking of a request made on behalf of
+    the multipart upload upload_id of /<ver>/<acc>/<con>/<obj>.
+
+    :param op: one of "initiate", "put_part", "head_part", "list_parts",
+        "complete", or "abort"
+    """
+    marking = {
+        "op": op,
+        "object_path": "/%s/%s/%s/%s" % (ver, acc, con, obj),
+        "marker_path": "/%s/%s/%s%s/%s/%s" % (
+            ver, acc, con, MULTIUPLOAD_SUFFIX, obj, upload_id)}
+    marking.update(kwargs)
+    return marking
+
+
+def convert_slo_to_multipart(ver, acc, con, obj, slo_manifest):
+    """
+    Return the utils.ENV_S3_MULTIPART marking completing the multipart
+    upload whose parts are listed in slo_manifest, or None should they not
+    all be parts of the same upload of /<ver>/<acc>/<con>/<obj>.
+    """
+    upload_id = None
+    parts = []
+    for e in slo_manifest:
+        seg_con, _, seg_name = e["name"].lstrip("/").partition("/")
+        split = split_segment_name(seg_name)
+        if (seg_con != con + MULTIUPLOAD_SUFFIX or split is None or
+                split[0] != obj or split[2] is None or
+                upload_id not in (None, split[1])):
+            return None
+        upload_id = split[1]
+        parts.append({"PartNumber": split[2], "ETag": e["hash"]})
+
+    if upload_id is None:
+        return None
+
+    return s3_multipart_marking("complete", ver, acc, con, obj, upload_id,
+                                parts=parts)
+
+
 class S3Compat(object):
     def __init__(self, app, conf, logger=None):
         self.logger = logger or get_logger(conf, log_route='pfs_s3_compat')
@@ -48,6 +127,16 @@ class S3Compat(object):
             req.headers['Content-Length'] = str(len(coalesce_input))
             req.method = 'COALESCE'
             req.params.pop('multipart-manifest', None)
+
+            ver, acc, con, obj = utils.parse_path(req.path)
+            marking = convert_slo_to_multipart(ver, acc, con, obj,
+                                               slo_manifest)
+            if marking:
+                req.environ[utils.ENV_S3_MULTIPART] = marking
+        elif req.environ.get(utils.ENV_IS_BIMODAL):
+            marking = self._s3_multipart_segments_request(req)
+            if marking:
+                req.environ[utils.ENV_S3_MULTIPART] = marking
         return self.app
 
     def _is_s3_slo_manifest_put(self, req):
@@ -59,3 +148,51 @@ class S3Compat(object):
             # This is a somewhat brittle way of detecting a Swift3 request,
             # but it's all we've got.
             req.environ.get('swift.source') == 'S3')
+
+    def _s3_multipart_segments_request(self, req):
+        """
+        Return the utils.ENV_S3_MULTIPART marking of a request of a
+        "<bucket>+segments" container made on behalf of a multipart upload,
+        or None for any other request.
+        """
+        ver, acc, con, obj = utils.parse_path(req.path)
+        if not (con and con.endswith(MULTIUPLOAD_SUFFIX) and
+                len(con) > len(MULTIUPLOAD_SUFFIX)):
+            return None
+        bucket = con[:-len(MULTIUPLOAD_SUFFIX)]
+        is_swift3 = req.environ.get('swift.source') == 'S3'
+
+        if not obj:
+            # swift3 lists the parts of an upload by prefix
+            prefix = req.params.get('prefix', '')
+            split = (split_segment_name(prefix[:-1])
+                     if prefix.endswith('/') else None)
+            if (is_swift3 and req.method == 'GET' and split and
+                    split[2] is None):
+                return s3_multipart_marking(
+                    "list_parts", ver, acc, bucket, split[0], split[1])
+            return None
+
+        split = split_segment_name(obj)
+        if split is None:
+            return None
+        obj_name, upload_id, part_number = split
+
+        if part_number is None:
+            if is_swift3 and req.method == 'PUT':
+                op = "initiate"
+            elif is_swift3 and req.method == 'DELETE':
+                op = "abort"
+            else:
+                return None
+        else:
+            if is_swift3 and req.method == 'PUT':
+                op = "put_part"
+            elif req.method == 'HEAD':
+                # Not only swift3 but also SLO (validating the manifest)
+                op = "head_part"
+            else:
+                return None
+
+        return s3_multipart_marking(op, ver, acc, bucket, obj_name,
+                                    upload_id, part_number=part_number)
@@ -13,6 +13,7 @@ from six.moves.urllib import parse as urllib_parse
 ENV_IS_BIMODAL = 'pfs.is_bimodal'
 ENV_OWNING_PROXYFS = 'pfs.owning_proxyfs'
 ENV_BIMODAL_CHECKER = 'pfs.bimodal_checker'
+ENV_S3_MULTIPART = 'pfs.s3_multipart'
 
 
 class RpcError(Exception):
@@ -8,12 +8,15 @@ import hashlib
 import json
 import mock
 import unittest
+import zlib
 from io import BytesIO
 from swift.common import swob
 from xml.etree import ElementTree
 
 import pfs_middleware.middleware as mware
 import pfs_middleware.bimodal_checker as bimodal_checker
+import pfs_middleware.s3_compat as s3_compat
+import pfs_middleware.utils as utils
 from . import helpers
 
 
@@ -854,6 +857,98 @@ class TestObjectGet(BaseMiddlewareTest):
         self.assertEqual(status, "200 OK")
         self.assertEqual(body, b"sparse" + (b"\x00" * 10000) + b"file")
 
+    def test_GET_inline_data(self):
+        # Small files may have their data inlined in their inode, in which
+        # case the read plan carries the data itself rather than an
+        # ObjectPath.
+        self.app.register(
+            'GET', '/v1/AUTH_test/InternalContainerName/00000000000000D1',
+            200, {}, "tail")
+
+        def mock_RpcGetObject(get_object_req):
+            self.assertEqual(get_object_req['VirtPath'],
+                             "/v1/AUTH_test/c/inline-file")
+
+            return {
+                "error": None,
+                "result": {
+                    "FileSize": 14,
+                    "Metadata": "",
+                    "InodeNumber": 1246,
+                    "NumWrites": 1,
+                    "ModificationTime": 1481152134331862558,
+                    "IsDir": False,
+                    "LeaseId": "6840595b3370f109dc8ed388b41800a5",
+                    "ReadEntsOut": [{
+                        "ObjectPath": "",
+                        "Offset": 0,
+                        "Length": 6,
+                        "Data": base64.b64encode(b"inline").decode("ascii"),
+                    }, {
+                        "ObjectPath": "",  # empty path means zero-fill
+                        "Offset": 0,
+                        "Length": 4,
+                        "Data": None,
+                    }, {
+                        "ObjectPath": ("/v1/AUTH_test/InternalContainer"
+                                       "Name/00000000000000D1"),
+                        "Offset": 0,
+                        "Length": 4,
+                        "Data": None}]}}
+
+        req = swob.Request.blank('/v1/AUTH_test/c/inline-file')
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+        status, headers, body = self.call_pfs(req)
+
+        self.assertEqual(status, "200 OK")
+        self.assertEqual(body, b"inline" + (b"\x00" * 4) + b"tail")
+
+    def test_GET_compressed_frame(self):
+        # Compressed frames are referenced by the read plan (rather than
+        # decompressed by ProxyFS) and are decompressed here as needed.
+        frame = b"0123456789abcdefghijklmnopqrstuvwxyz"
+        compressor = zlib.compressobj(
+            zlib.Z_DEFAULT_COMPRESSION, zlib.DEFLATED, -zlib.MAX_WBITS)
+        compressed_frame = compressor.compress(frame) + compressor.flush()
+
+        self.app.register(
+            'GET', '/v1/AUTH_test/InternalContainerName/00000000000000D2',
+            200, {}, b"junk" + compressed_frame + b"junk")
+
+        def mock_RpcGetObject(get_object_req):
+            self.assertEqual(get_object_req['VirtPath'],
+                             "/v1/AUTH_test/c/compressed-file")
+
+            return {
+                "error": None,
+                "result": {
+                    "FileSize": 10,
+                    "Metadata": "",
+                    "InodeNumber": 1247,
+                    "NumWrites": 1,
+                    "ModificationTime": 1481152134331862558,
+                    "IsDir": False,
+                    "LeaseId": "fd1b3d4c5f6a7e8091a2b3c4d5e6f708",
+                    "ReadEntsOut": [{
+                        "ObjectPath": ("/v1/AUTH_test/InternalContainer"
+                                       "Name/00000000000000D2"),
+                        "Offset": 4,
+                        "Length": 10,
+                        "Data": None,
+                        "CompressedLength": len(compressed_frame),
+                        "FrameOffset": 6}]}}
+
+        req = swob.Request.blank('/v1/AUTH_test/c/compressed-file')
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+        status, headers, body = self.call_pfs(req)
+
+        self.assertEqual(status, "200 OK")
+        self.assertEqual(body, frame[6:16])
+
     def test_GET_multiple_segments(self):
         # Typically, a GET request will include data from multiple log
         # segments. Small files written all at once might fit in a single
@@ -4021,6 +4116,229 @@ class TestObjectHeadDir(BaseMiddlewareTest):
                          "Tue, 15 Nov 2016 01:26:09 GMT")
 
 
+class TestObjectVersions(BaseMiddlewareTest):
+    def setUp(self):
+        super(TestObjectVersions, self).setUp()
+
+        def dummy_rpc(request):
+            return {"error": None, "result": {}}
+
+        self.fake_rpc.register_handler("Server.RpcRenewLease", dummy_rpc)
+        self.fake_rpc.register_handler("Server.RpcReleaseLease", dummy_rpc)
+
+    def test_GET_version(self):
+        self.app.register(
+            'GET', '/v1/AUTH_test/InternalContainerName/0000000000c11fbd',
+            200, {}, "burritos")
+
+        def mock_RpcGetObject(get_object_req):
+            self.assertEqual(get_object_req['VirtPath'],
+                             "/v1/AUTH_test/notes/lunch")
+            self.assertEqual(get_object_req['VersionID'], 1245)
+
+            return {
+                "error": None,
+                "result": {
+                    "FileSize": 8,
+                    "Metadata": "",
+                    "InodeNumber": 1245,
+                    "NumWrites": 2424,
+                    "ModificationTime": 1481152134331862558,
+                    "IsDir": False,
+                    "LeaseId": "prominority-sarcocyst",
+                    "ReadEntsOut": [{
+                        "ObjectPath": ("/v1/AUTH_test/InternalContainer"
+                                       "Name/0000000000c11fbd"),
+                        "Offset": 0,
+                        "Length": 8}]}}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+
+        req = swob.Request.blank('/v1/AUTH_test/notes/lunch?version-id=1245')
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(headers["ETag"],
+                         mware.construct_etag("AUTH_test", 1245, 2424))
+        self.assertEqual(body, b'burritos')
+
+    def test_GET_current_version(self):
+        def mock_RpcGetObject(get_object_req):
+            self.assertNotIn('VersionID', get_object_req)
+
+            return {
+                "error": None,
+                "result": {
+                    "FileSize": 0,
+                    "Metadata": "",
+                    "InodeNumber": 1245,
+                    "NumWrites": 1,
+                    "ModificationTime": 1481152134331862558,
+                    "IsDir": False,
+                    "LeaseId": "prominority-sarcocyst",
+                    "ReadEntsOut": []}}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+
+        req = swob.Request.blank('/v1/AUTH_test/notes/lunch')
+        status, _, _ = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+    def test_GET_version_not_found(self):
+        def mock_RpcGetObject(get_object_req):
+            self.assertEqual(get_object_req['VersionID'], 1246)
+            return {"error": "errno: 2", "result": None}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+
+        req = swob.Request.blank('/v1/AUTH_test/notes/lunch?version-id=1246')
+        status, _, _ = self.call_pfs(req)
+        self.assertEqual(status, '404 Not Found')
+
+    def test_HEAD_version(self):
+        def mock_RpcHead(head_object_req):
+            self.assertEqual(head_object_req['VirtPath'],
+                             '/v1/AUTH_test/c/an-object.png')
+            self.assertEqual(head_object_req['VersionID'], 4591)
+
+            return {
+                "error": None,
+                "result": {
+                    "Metadata": "",
+                    "ModificationTime": 1479173168018879490,
+                    "FileSize": 2641863,
+                    "IsDir": False,
+                    "InodeNumber": 4591,
+                    "NumWrites": 874,
+                }}
+
+        self.fake_rpc.register_handler("Server.RpcHead", mock_RpcHead)
+
+        req = swob.Request.blank("/v1/AUTH_test/c/an-object.png"
+                                 "?version-id=4591",
+                                 environ={"REQUEST_METHOD": "HEAD"})
+        status, headers, _ = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(headers["Content-Length"], "2641863")
+        self.assertEqual(headers["ETag"],
+                         mware.construct_etag("AUTH_test", 4591, 874))
+
+    def test_HEAD_current_version(self):
+        def mock_RpcHead(head_object_req):
+            self.assertNotIn('VersionID', head_object_req)
+
+            return {
+                "error": None,
+                "result": {
+                    "Metadata": "",
+                    "ModificationTime": 1479173168018879490,
+                    "FileSize": 2641863,
+                    "IsDir": False,
+                    "InodeNumber": 4591,
+                    "NumWrites": 874,
+                }}
+
+        self.fake_rpc.register_handler("Server.RpcHead", mock_RpcHead)
+
+        req = swob.Request.blank("/v1/AUTH_test/c/an-object.png",
+                                 environ={"REQUEST_METHOD": "HEAD"})
+        status, _, _ = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+    def test_HEAD_version_not_found(self):
+        def mock_RpcHead(head_object_req):
+            self.assertEqual(head_object_req['VersionID'], 4592)
+            return {"error": "errno: 2", "result": None}
+
+        self.fake_rpc.register_handler("Server.RpcHead", mock_RpcHead)
+
+        req = swob.Request.blank("/v1/AUTH_test/c/an-object.png"
+                                 "?version-id=4592",
+                                 environ={"REQUEST_METHOD": "HEAD"})
+        status, _, _ = self.call_pfs(req)
+        self.assertEqual(status, '404 Not Found')
+
+    def test_DELETE_version(self):
+        def fake_RpcDelete(delete_request):
+            self.assertEqual(delete_request['VirtPath'],
+                             "/v1/AUTH_test/con/obj")
+            self.assertEqual(delete_request['VersionID'], 7)
+            return {"error": None, "result": {}}
+
+        self.fake_rpc.register_handler("Server.RpcDelete", fake_RpcDelete)
+
+        req = swob.Request.blank("/v1/AUTH_test/con/obj?version-id=7",
+                                 environ={"REQUEST_METHOD": "DELETE"})
+        status, _, _ = self.call_pfs(req)
+        self.assertEqual(status, "204 No Content")
+
+    def test_bad_version_id(self):
+        for method in ('GET', 'HEAD', 'DELETE'):
+            req = swob.Request.blank("/v1/AUTH_test/con/obj?version-id=abc",
+                                     environ={"REQUEST_METHOD": method})
+            status, _, _ = self.call_pfs(req)
+            self.assertEqual(status, "400 Bad Request")
+
+        # nothing was asked of proxyfsd beyond RpcIsAccountBimodal
+        self.assertEqual(
+            [method for method, _ in self.fake_rpc.calls
+             if method != "Server.RpcIsAccountBimodal"], [])
+
+    def test_container_listing(self):
+        def mock_RpcGetContainer(get_container_req):
+            self.assertTrue(get_container_req['Versions'])
+
+            return {
+                "error": None,
+                "result": {
+                    "Metadata": "",
+                    "ModificationTime": 1510790796076041000,
+                    "ContainerEntries": [{
+                        "Basename": "obj",
+                        "FileSize": 0,
+                        "ModificationTime": 1471915816859209471,
+                        "IsDir": False,
+                        "InodeNumber": 9213769,
+                        "NumWrites": 0,
+                        "Metadata": "",
+                        "VersionID": 9213769,
+                        "IsLatest": True,
+                        "IsDeleteMarker": True,
+                    }, {
+                        "Basename": "obj",
+                        "FileSize": 70,
+                        "ModificationTime": 1471915816359209849,
+                        "IsDir": False,
+                        "InodeNumber": 9213768,
+                        "NumWrites": 2,
+                        "Metadata": "",
+                        "VersionID": 9213768,
+                        "IsLatest": False,
+                        "IsDeleteMarker": False,
+                    }]}}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetContainer", mock_RpcGetContainer)
+
+        req = swob.Request.blank('/v1/AUTH_test/con?versions',
+                                 headers={"Accept": "application/json"})
+        status, _, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+        resp_data = json.loads(body)
+        self.assertEqual(len(resp_data), 2)
+        self.assertEqual(resp_data[0]["version_id"], "9213769")
+        self.assertTrue(resp_data[0]["is_latest"])
+        self.assertEqual(resp_data[0]["content_type"],
+                         mware.DELETE_MARKER_CONTENT_TYPE)
+        self.assertEqual(resp_data[1]["version_id"], "9213768")
+        self.assertFalse(resp_data[1]["is_latest"])
+        self.assertNotEqual(resp_data[1]["content_type"],
+                            mware.DELETE_MARKER_CONTENT_TYPE)
+
+
 class TestObjectCoalesce(BaseMiddlewareTest):
     def setUp(self):
         super(TestObjectCoalesce, self).setUp()
@@ -4435,6 +4753,374 @@ class TestObjectCoalesce(BaseMiddlewareTest):
         self.assertEqual(status, '500 Internal Error')
 
 
+class TestS3Multipart(BaseMiddlewareTest):
+    marker_path = "/v1/AUTH_test/con+segments/obj/1506721327.316611"
+
+    def setUp(self):
+        super(TestS3Multipart, self).setUp()
+
+        self.marker_metadata = {
+            mware.MULTIPART_UPLOAD_ID_HEADER: "0000000000000ABC"}
+
+        def mock_RpcHead(head_req):
+            if head_req["VirtPath"] != self.marker_path:
+                # the "+segments" container
+                return {
+                    "error": None,
+                    "result": {
+                        "Metadata": "",
+                        "ModificationTime": 1485814697697650000,
+                        "FileSize": 0,
+                        "IsDir": True,
+                        "InodeNumber": 1828,
+                        "NumWrites": 893,
+                    }}
+            if self.marker_metadata is None:
+                return {"error": "errno: 2", "result": None}
+            return {
+                "error": None,
+                "result": {
+                    "Metadata": base64.b64encode(json.dumps(
+                        self.marker_metadata).encode('ascii')).decode(
+                            'ascii'),
+                    "ModificationTime": 1485814697697650000,
+                    "FileSize": 0,
+                    "IsDir": False,
+                    "InodeNumber": 1829,
+                    "NumWrites": 1,
+                }}
+
+        def mock_RpcPutLocation(put_location_req):
+            phys_path = "/v1/AUTH_test/PhysContainer_1/0000000000000001"
+            self.app.register('PUT', phys_path, 201, {}, "")
+            return {
+                "error": None,
+                "result": {"PhysPath": phys_path}}
+
+        def mock_RpcPutComplete(put_complete_req):
+            return {"error": None, "result": {
+                "ModificationTime": 12345,
+                "InodeNumber": 678,
+                "NumWrites": 1}}
+
+        def mock_RpcMultipartInitiate(multipart_initiate_req):
+            return {"error": None, "result": {
+                "UploadID": "0000000000000ABC"}}
+
+        def mock_RpcMultipartPutPart(multipart_put_part_req):
+            return {"error": None, "result": {}}
+
+        def mock_RpcMultipartListParts(multipart_list_parts_req):
+            parts = [{
+                "PartNumber": part_number,
+                "ETag": "etag%d" % part_number,
+                "Size": 1000 + part_number,
+                "ModificationTime": 1485814697697650000,
+            } for part_number in (1, 2, 3)
+                if part_number > multipart_list_parts_req["PartNumberMarker"]]
+            max_parts = multipart_list_parts_req["MaxParts"]
+            if max_parts > 0:
+                return {"error": None, "result": {
+                    "Parts": parts[:max_parts],
+                    "MoreParts": len(parts) > max_parts}}
+            return {"error": None, "result": {
+                "Parts": parts,
+                "MoreParts": False}}
+
+        def mock_RpcMultipartComplete(multipart_complete_req):
+            return {"error": None, "result": {
+                "ModificationTime": 1488323796002909000,
+                "InodeNumber": 283253,
+                "NumWrites": len(multipart_complete_req["Parts"]) + 1}}
+
+        def mock_RpcMultipartAbort(multipart_abort_req):
+            return {"error": None, "result": {}}
+
+        def mock_RpcDelete(delete_req):
+            return {"error": None, "result": {}}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcHead", mock_RpcHead)
+        self.fake_rpc.register_handler(
+            "Server.RpcPutLocation", mock_RpcPutLocation)
+        self.fake_rpc.register_handler(
+            "Server.RpcPutComplete", mock_RpcPutComplete)
+        self.fake_rpc.register_handler(
+            "Server.RpcMultipartInitiate", mock_RpcMultipartInitiate)
+        self.fake_rpc.register_handler(
+            "Server.RpcMultipartPutPart", mock_RpcMultipartPutPart)
+        self.fake_rpc.register_handler(
+            "Server.RpcMultipartListParts", mock_RpcMultipartListParts)
+        self.fake_rpc.register_handler(
+            "Server.RpcMultipartComplete", mock_RpcMultipartComplete)
+        self.fake_rpc.register_handler(
+            "Server.RpcMultipartAbort", mock_RpcMultipartAbort)
+        self.fake_rpc.register_handler(
+            "Server.RpcDelete", mock_RpcDelete)
+
+    def marking(self, op, **kwargs):
+        return s3_compat.s3_multipart_marking(
+            op, "v1", "AUTH_test", "con", "obj", "1506721327.316611",
+            **kwargs)
+
+    def rpc_methods(self):
+        return [method for method, args in self.fake_rpc.calls]
+
+    def rpc_args(self, rpc_method):
+        for method, args in self.fake_rpc.calls:
+            if method == rpc_method:
+                return args[0]
+
+    def test_initiate(self):
+        req = swob.Request.blank(
+            self.marker_path,
+            environ={"REQUEST_METHOD": "PUT",
+                     "wsgi.input": BytesIO(b""),
+                     utils.ENV_S3_MULTIPART: self.marking("initiate")},
+            headers={"Content-Length": "0",
+                     "X-Object-Meta-Color": "blue"})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '201 Created')
+
+        self.assertIn("Server.RpcMultipartInitiate", self.rpc_methods())
+        args = self.rpc_args("Server.RpcMultipartInitiate")
+        self.assertEqual(args["VirtPath"], "/v1/AUTH_test/con/obj")
+        self.assertEqual(
+            json.loads(base64.b64decode(args["Metadata"]))[
+                "X-Object-Meta-Color"], "blue")
+
+        # The session is recorded on the upload marker
+        args = self.rpc_args("Server.RpcPutComplete")
+        self.assertEqual(args["VirtPath"], self.marker_path)
+        self.assertEqual(
+            json.loads(base64.b64decode(args["Metadata"]))[
+                mware.MULTIPART_UPLOAD_ID_HEADER], "0000000000000ABC")
+
+    def test_put_part(self):
+        wsgi_input = BytesIO(b"sparkleberry-displeasurably")
+        req = swob.Request.blank(
+            self.marker_path + "/2",
+            environ={"REQUEST_METHOD": "PUT",
+                     "wsgi.input": wsgi_input,
+                     utils.ENV_S3_MULTIPART: self.marking(
+                         "put_part", part_number=2)},
+            headers={"Content-Length": str(len(wsgi_input.getvalue()))})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '201 Created')
+        etag = hashlib.md5(wsgi_input.getvalue()).hexdigest()
+        self.assertEqual(headers["ETag"], etag)
+
+        self.assertNotIn("Server.RpcPutComplete", self.rpc_methods())
+        args = self.rpc_args("Server.RpcMultipartPutPart")
+        self.assertEqual(args["VirtPath"], "/v1/AUTH_test/con/obj")
+        self.assertEqual(args["UploadID"], "0000000000000ABC")
+        self.assertEqual(args["PartNumber"], 2)
+        self.assertEqual(args["PhysPaths"], [
+            "/v1/AUTH_test/PhysContainer_1/0000000000000001"])
+        self.assertEqual(args["PhysLengths"], [len(wsgi_input.getvalue())])
+        self.assertEqual(args["ETag"], etag)
+
+    def test_put_part_no_such_upload(self):
+        def mock_RpcMultipartPutPart(multipart_put_part_req):
+            return {"error": "errno: 2", "result": None}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcMultipartPutPart", mock_RpcMultipartPutPart)
+
+        req = swob.Request.blank(
+            self.marker_path + "/2",
+            environ={"REQUEST_METHOD": "PUT",
+                     "wsgi.input": BytesIO(b"data"),
+                     utils.ENV_S3_MULTIPART: self.marking(
+                         "put_part", part_number=2)},
+            headers={"Content-Length": "4"})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '404 Not Found')
+
+    def test_put_part_without_session(self):
+        # An upload initiated without a session has its parts PUT as
+        # ordinary objects
+        self.marker_metadata = {}
+
+        req = swob.Request.blank(
+            self.marker_path + "/2",
+            environ={"REQUEST_METHOD": "PUT",
+                     "wsgi.input": BytesIO(b"data"),
+                     utils.ENV_S3_MULTIPART: self.marking(
+                         "put_part", part_number=2)},
+            headers={"Content-Length": "4"})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '201 Created')
+
+        self.assertNotIn("Server.RpcMultipartPutPart", self.rpc_methods())
+        args = self.rpc_args("Server.RpcPutComplete")
+        self.assertEqual(args["VirtPath"], self.marker_path + "/2")
+
+    def test_head_part(self):
+        req = swob.Request.blank(
+            self.marker_path + "/2",
+            environ={"REQUEST_METHOD": "HEAD",
+                     utils.ENV_S3_MULTIPART: self.marking(
+                         "head_part", part_number=2)})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+        self.assertEqual(headers["Content-Length"], "1002")
+        self.assertEqual(headers["ETag"], "etag2")
+
+        args = self.rpc_args("Server.RpcMultipartListParts")
+        self.assertEqual(args["PartNumberMarker"], 1)
+        self.assertEqual(args["MaxParts"], 1)
+
+        req = swob.Request.blank(
+            self.marker_path + "/4",
+            environ={"REQUEST_METHOD": "HEAD",
+                     utils.ENV_S3_MULTIPART: self.marking(
+                         "head_part", part_number=4)})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '404 Not Found')
+
+    def test_list_parts(self):
+        req = swob.Request.blank(
+            "/v1/AUTH_test/con+segments?format=json&limit=2&"
+            "prefix=obj/1506721327.316611/&"
+            "marker=obj/1506721327.316611/1",
+            environ={"REQUEST_METHOD": "GET",
+                     utils.ENV_S3_MULTIPART: self.marking("list_parts")})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '200 OK')
+
+        entries = json.loads(body)
+        self.assertEqual(
+            [(e["name"], e["bytes"], e["hash"]) for e in entries],
+            [("obj/1506721327.316611/2", 1002, "etag2"),
+             ("obj/1506721327.316611/3", 1003, "etag3")])
+
+        args = self.rpc_args("Server.RpcMultipartListParts")
+        self.assertEqual(args["PartNumberMarker"], 1)
+
+    def test_complete(self):
+        request_headers = {
+            'X-Object-Sysmeta-S3Api-Etag':
+                'cb45770d6cf51effdfb2ea35322459c3-2',
+            'X-Object-Sysmeta-Slo-Etag': '363d958f0f4c8501a50408a728ba5599',
+            'X-Static-Large-Object': 'True',
+            'Etag': '10340ab593ac8c32290a278e36d1f8df',
+        }
+        parts = [{"PartNumber": 1, "ETag": "etag1"},
+                 {"PartNumber": 2, "ETag": "etag2"}]
+        req = swob.Request.blank(
+            "/v1/AUTH_test/con/obj",
+            headers=request_headers,
+            environ={"REQUEST_METHOD": "COALESCE",
+                     "wsgi.input": BytesIO(b'{"elements": []}'),
+                     utils.ENV_S3_MULTIPART: self.marking(
+                         "complete", parts=parts)})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '201 Created')
+        self.assertEqual(headers["Etag"], '10340ab593ac8c32290a278e36d1f8df')
+
+        self.assertNotIn("Server.RpcCoalesce", self.rpc_methods())
+        self.assertNotIn("Server.RpcPost", self.rpc_methods())
+        args = self.rpc_args("Server.RpcMultipartComplete")
+        self.assertEqual(args["VirtPath"], "/v1/AUTH_test/con/obj")
+        self.assertEqual(args["UploadID"], "0000000000000ABC")
+        self.assertEqual(args["Parts"], parts)
+
+        metadata = json.loads(base64.b64decode(args["Metadata"]))
+        self.assertNotIn("X-Static-Large-Object", metadata)
+        self.assertNotIn("X-Object-Sysmeta-Slo-Etag", metadata)
+        self.assertEqual(metadata["X-Object-Sysmeta-S3Api-Etag"],
+                         "3:cb45770d6cf51effdfb2ea35322459c3-2")
+
+    def test_complete_existing_object(self):
+        # The writes to an overwritten object count too, so the munged
+        # ETags are corrected afterward
+        def mock_RpcMultipartComplete(multipart_complete_req):
+            return {"error": None, "result": {
+                "ModificationTime": 1488323796002909000,
+                "InodeNumber": 283253,
+                "NumWrites": 7}}
+
+        def mock_RpcPost(post_req):
+            return {"error": None, "result": {}}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcMultipartComplete", mock_RpcMultipartComplete)
+        self.fake_rpc.register_handler(
+            "Server.RpcPost", mock_RpcPost)
+
+        parts = [{"PartNumber": 1, "ETag": "etag1"}]
+        req = swob.Request.blank(
+            "/v1/AUTH_test/con/obj",
+            headers={'Etag': '10340ab593ac8c32290a278e36d1f8df'},
+            environ={"REQUEST_METHOD": "COALESCE",
+                     "wsgi.input": BytesIO(b'{"elements": []}'),
+                     utils.ENV_S3_MULTIPART: self.marking(
+                         "complete", parts=parts)})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '201 Created')
+        self.assertEqual(headers["Etag"], '10340ab593ac8c32290a278e36d1f8df')
+
+        args = self.rpc_args("Server.RpcPost")
+        self.assertEqual(args["VirtPath"], "/v1/AUTH_test/con/obj")
+        self.assertEqual(
+            json.loads(base64.b64decode(args["OldMetaData"]))[
+                mware.ORIGINAL_MD5_HEADER],
+            "2:10340ab593ac8c32290a278e36d1f8df")
+        self.assertEqual(
+            json.loads(base64.b64decode(args["NewMetaData"]))[
+                mware.ORIGINAL_MD5_HEADER],
+            "7:10340ab593ac8c32290a278e36d1f8df")
+
+    def test_complete_part_mismatch(self):
+        def mock_RpcMultipartComplete(multipart_complete_req):
+            return {"error": "errno: 22", "result": None}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcMultipartComplete", mock_RpcMultipartComplete)
+
+        req = swob.Request.blank(
+            "/v1/AUTH_test/con/obj",
+            environ={"REQUEST_METHOD": "COALESCE",
+                     "wsgi.input": BytesIO(b'{"elements": []}'),
+                     utils.ENV_S3_MULTIPART: self.marking(
+                         "complete",
+                         parts=[{"PartNumber": 1, "ETag": "wrong"}])})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '400 Bad Request')
+
+    def test_abort(self):
+        req = swob.Request.blank(
+            self.marker_path,
+            environ={"REQUEST_METHOD": "DELETE",
+                     utils.ENV_S3_MULTIPART: self.marking("abort")})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '204 No Content')
+
+        args = self.rpc_args("Server.RpcMultipartAbort")
+        self.assertEqual(args["VirtPath"], "/v1/AUTH_test/con/obj")
+        self.assertEqual(args["UploadID"], "0000000000000ABC")
+
+        # The upload marker itself is deleted as usual
+        args = self.rpc_args("Server.RpcDelete")
+        self.assertEqual(args["VirtPath"], self.marker_path)
+
+    def test_abort_completed_upload(self):
+        def mock_RpcMultipartAbort(multipart_abort_req):
+            return {"error": "errno: 2", "result": None}
+
+        self.fake_rpc.register_handler(
+            "Server.RpcMultipartAbort", mock_RpcMultipartAbort)
+
+        req = swob.Request.blank(
+            self.marker_path,
+            environ={"REQUEST_METHOD": "DELETE",
+                     utils.ENV_S3_MULTIPART: self.marking("abort")})
+        status, headers, body = self.call_pfs(req)
+        self.assertEqual(status, '204 No Content')
+        self.assertIn("Server.RpcDelete", self.rpc_methods())
+
+
 class TestAuth(BaseMiddlewareTest):
     def setUp(self):
         super(TestAuth, self).setUp()
@@ -82,3 +82,98 @@ class TestS3Compat(unittest.TestCase):
                 "con-segments/obj/1506721327.316611/1",
                 "con-segments/obj/1506721327.316611/2",
                 "con-segments/obj/1506721327.316611/3"]})
+
+    def test_conversion_to_multipart(self):
+        self.app.register(
+            'COALESCE', '/v1/AUTH_test/con/obj',
+            201, {}, '')
+
+        slo_manifest = [{
+            "name": "/con+segments/obj/1506721327.316611/1",
+            "hash": "etag1",
+            "bytes": 12345678901,
+        }, {
+            "name": "/con+segments/obj/1506721327.316611/2",
+            "hash": "etag2",
+            "bytes": 12345678902,
+        }]
+        serialized_slo_manifest = json.dumps(slo_manifest).encode('utf-8')
+
+        req = swob.Request.blank(
+            "/v1/AUTH_test/con/obj?multipart-manifest=put",
+            environ={'REQUEST_METHOD': 'PUT',
+                     'wsgi.input': swob.WsgiBytesIO(serialized_slo_manifest),
+                     utils.ENV_IS_BIMODAL: True,
+                     'swift.source': 'S3'},
+            headers={'Content-Length': str(len(slo_manifest))})
+
+        resp = req.get_response(self.s3_compat)
+        self.assertEqual(resp.status_int, 201)
+        self.assertEqual(self.app.calls[0][0], 'COALESCE')
+        self.assertEqual(req.environ[utils.ENV_S3_MULTIPART], {
+            "op": "complete",
+            "object_path": "/v1/AUTH_test/con/obj",
+            "marker_path":
+                "/v1/AUTH_test/con+segments/obj/1506721327.316611",
+            "parts": [{"PartNumber": 1, "ETag": "etag1"},
+                      {"PartNumber": 2, "ETag": "etag2"}]})
+
+        # Parts of several uploads (or not of an upload at all) are only
+        # coalesced
+        slo_manifest[1]["name"] = "/con+segments/obj/1506721327.999999/2"
+        self.assertIsNone(s3_compat.convert_slo_to_multipart(
+            "v1", "AUTH_test", "con", "obj", slo_manifest))
+        slo_manifest[1]["name"] = "/con-segments/obj/1506721327.316611/2"
+        self.assertIsNone(s3_compat.convert_slo_to_multipart(
+            "v1", "AUTH_test", "con", "obj", slo_manifest))
+
+    def test_split_segment_name(self):
+        self.assertEqual(s3_compat.split_segment_name("a/b/obj/uid/12"),
+                         ("a/b/obj", "uid", 12))
+        self.assertEqual(s3_compat.split_segment_name("a/b/obj/uid"),
+                         ("a/b/obj", "uid", None))
+        self.assertIsNone(s3_compat.split_segment_name("obj"))
+
+    def _marking_of(self, method, path, swift_source='S3'):
+        self.app.register(method, path.split('?')[0], 200, {}, '')
+        req = swob.Request.blank(
+            path,
+            environ={'REQUEST_METHOD': method,
+                     utils.ENV_IS_BIMODAL: True,
+                     'swift.source': swift_source})
+        req.get_response(self.s3_compat)
+        return req.environ.get(utils.ENV_S3_MULTIPART)
+
+    def test_segments_requests(self):
+        marker_path = "/v1/AUTH_test/con+segments/obj/1506721327.316611"
+
+        marking = self._marking_of('PUT', marker_path)
+        self.assertEqual(marking["op"], "initiate")
+        self.assertEqual(marking["object_path"], "/v1/AUTH_test/con/obj")
+        self.assertEqual(marking["marker_path"], marker_path)
+
+        self.assertEqual(
+            self._marking_of('DELETE', marker_path)["op"], "abort")
+
+        marking = self._marking_of('PUT', marker_path + "/3")
+        self.assertEqual(marking["op"], "put_part")
+        self.assertEqual(marking["part_number"], 3)
+        self.assertEqual(marking["marker_path"], marker_path)
+
+        # SLO, too, HEADs the parts
+        marking = self._marking_of('HEAD', marker_path + "/3",
+                                   swift_source='SLO')
+        self.assertEqual(marking["op"], "head_part")
+
+        marking = self._marking_of(
+            'GET', "/v1/AUTH_test/con+segments?format=json&"
+            "prefix=obj/1506721327.316611/")
+        self.assertEqual(marking["op"], "list_parts")
+        self.assertEqual(marking["marker_path"], marker_path)
+
+        # Anything else is left alone
+        self.assertIsNone(self._marking_of('GET', marker_path))
+        self.assertIsNone(self._marking_of('PUT', marker_path,
+                                           swift_source=None))
+        self.assertIsNone(self._marking_of(
+            'PUT', "/v1/AUTH_test/con/obj/1506721327.316611"))
@@ -648,6 +648,7 @@ func (fileInode *fileInodeStruct) populateExtentMapHelper(fileOffset uint64) (ex
 			objectName:    extentMapEntry.ObjectName,
 			objectOffset:  extentMapEntry.LogSegmentOffset,
 			length:        extentMapEntry.Length,
+			data:          extentMapEntry.Data,
 		}
 
 		fileInode.updateExtentMap(curExtent)
@@ -788,6 +789,7 @@ func (fileInode *fileInodeStruct) updateExtentMap(newExtent *multiObjectExtentSt
 					objectName:    prevExtent.objectName,
 					objectOffset:  prevExtent.objectOffset + prevExtentNewLength,
 					length:        prevExtent.length - prevExtentNewLength,
+					data:          prevExtent.data,
 				}
 
 				prevExtent.length = prevExtentNewLength
@@ -855,6 +857,7 @@ func (fileInode *fileInodeStruct) updateExtentMap(newExtent *multiObjectExtentSt
 				objectName:    curExtent.objectName,
 				objectOffset:  curExtent.objectOffset + curExtentLostLength,
 				length:        curExtent.length - curExtentLostLength,
+				data:          curExtent.data,
 			}
 
 			ok, err = fileInode.extentMap.Put(splitExtent.fileOffset, splitExtent)
@@ -893,6 +896,7 @@ func (fileInode *fileInodeStruct) updateExtentMap(newExtent *multiObjectExtentSt
 //   singleObjectExtentWithLinkStruct - a reference to a portion of a LogSegment being written by a chunkedPutContextStruct
 //   multiObjectExtentStruct          - a reference to a portion of a LogSegment described by a fileInodeStruct.extentMap
 //   multiObjectExtentStruct          - a description of a zero-filled extent (.objectName == "")
+//                                      or of data supplied by ProxyFS itself (.data != nil)
 //
 func (fileInode *fileInodeStruct) getReadPlan(fileOffset uint64, length uint64) (readPlan []interface{}, readPlanSpan uint64) {
 	var (
@@ -949,6 +953,7 @@ func (fileInode *fileInodeStruct) getReadPlan(fileOffset uint64, length uint64)
 			objectName:    curMultiObjectExtent.objectName, // May be == ""
 			objectOffset:  curMultiObjectExtent.objectOffset + (curFileOffset - curMultiObjectExtent.fileOffset),
 			length:        curMultiObjectExtent.length - (curFileOffset - curMultiObjectExtent.fileOffset),
+			data:          curMultiObjectExtent.data,
 		}
 
 		if remainingLength < multiObjectReadPlanStep.length {
@@ -1114,6 +1119,7 @@ func (chunkedPutContext *chunkedPutContextStruct) getReadPlanHelper(fileOffset u
 						objectName:    inReadPlanStepAsMultiObjectExtent.objectName,
 						objectOffset:  inReadPlanStepAsMultiObjectExtent.objectOffset + (curFileOffset - inReadPlanStepAsMultiObjectExtent.fileOffset),
 						length:        overlapExtentWithLink.fileOffset - curFileOffset,
+						data:          inReadPlanStepAsMultiObjectExtent.data,
 					}
 
 					outReadPlan = append(outReadPlan, outReadPlanStepAsMultiObjectExtent)
@@ -1153,6 +1159,7 @@ func (chunkedPutContext *chunkedPutContextStruct) getReadPlanHelper(fileOffset u
 					objectName:    inReadPlanStepAsMultiObjectExtent.objectName,
 					objectOffset:  inReadPlanStepAsMultiObjectExtent.objectOffset + (curFileOffset - inReadPlanStepAsMultiObjectExtent.fileOffset),
 					length:        remainingLength,
+					data:          inReadPlanStepAsMultiObjectExtent.data,
 				}
 
 				outReadPlan = append(outReadPlan, outReadPlanStepAsMultiObjectExtent)
@@ -1811,12 +1818,13 @@ func (fileInode *fileInodeStruct) DumpValue(value sortedmap.Value) (valueAsStrin
 	valueAsMultiObjextExtentStruct, ok = value.(*multiObjectExtentStruct)
 	if ok {
 		valueAsString = fmt.Sprintf(
-			"{fileOffset:0x%016X,containerName:%s,objectName:%s,objectOffset:0x%016X,length:0x%016X}",
+			"{fileOffset:0x%016X,containerName:%s,objectName:%s,objectOffset:0x%016X,length:0x%016X,len(data):%d}",
 			valueAsMultiObjextExtentStruct.fileOffset,
 			valueAsMultiObjextExtentStruct.containerName,
 			valueAsMultiObjextExtentStruct.objectName,
 			valueAsMultiObjextExtentStruct.objectOffset,
-			valueAsMultiObjextExtentStruct.length)
+			valueAsMultiObjextExtentStruct.length,
+			len(valueAsMultiObjextExtentStruct.data))
 	} else {
 		err = fmt.Errorf("Failure of *fileInodeStruct.DumpValue(%v)", value)
 	}
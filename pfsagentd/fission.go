@@ -696,22 +696,18 @@ func (dummy *globalsStruct) DoSymLink(inHeader *fission.InHeader, symLinkIn *fis
 
 	symlinkReply = &jrpcfs.InodeReply{}
 
-	err = globals.retryRPCClient.Send("RpcSymlink", symlinkRequest, symlinkReply)
-	if nil != err {
-		errno = convertErrToErrno(err, syscall.EIO)
-		return
-	}
-
 	getStatRequest = &jrpcfs.GetStatRequest{
 		InodeHandle: jrpcfs.InodeHandle{
-			MountID:     globals.mountID,
-			InodeNumber: symlinkReply.InodeNumber,
+			MountID: globals.mountID,
 		},
 	}
 
 	getStatReply = &jrpcfs.StatStruct{}
 
-	err = globals.retryRPCClient.Send("RpcGetStat", getStatRequest, getStatReply)
+	err = doCompoundRequest([]*compoundStepStruct{
+		{method: "RpcSymlink", request: symlinkRequest, reply: symlinkReply},
+		{method: "RpcGetStat", request: getStatRequest, refs: []jrpcfs.CompoundStepRef{{RequestField: "InodeNumber", Step: 0, ReplyField: "InodeNumber"}}, reply: getStatReply},
+	})
 	if nil != err {
 		errno = convertErrToErrno(err, syscall.EIO)
 		return
@@ -790,22 +786,18 @@ func (dummy *globalsStruct) DoMkDir(inHeader *fission.InHeader, mkDirIn *fission
 
 	mkdirReply = &jrpcfs.InodeReply{}
 
-	err = globals.retryRPCClient.Send("RpcMkdir", mkdirRequest, mkdirReply)
-	if nil != err {
-		errno = convertErrToErrno(err, syscall.EIO)
-		return
-	}
-
 	getStatRequest = &jrpcfs.GetStatRequest{
 		InodeHandle: jrpcfs.InodeHandle{
-			MountID:     globals.mountID,
-			InodeNumber: mkdirReply.InodeNumber,
+			MountID: globals.mountID,
 		},
 	}
 
 	getStatReply = &jrpcfs.StatStruct{}
 
-	err = globals.retryRPCClient.Send("RpcGetStat", getStatRequest, getStatReply)
+	err = doCompoundRequest([]*compoundStepStruct{
+		{method: "RpcMkdir", request: mkdirRequest, reply: mkdirReply},
+		{method: "RpcGetStat", request: getStatRequest, refs: []jrpcfs.CompoundStepRef{{RequestField: "InodeNumber", Step: 0, ReplyField: "InodeNumber"}}, reply: getStatReply},
+	})
 	if nil != err {
 		errno = convertErrToErrno(err, syscall.EIO)
 		return
@@ -878,17 +870,6 @@ func (dummy *globalsStruct) DoUnlink(inHeader *fission.InHeader, unlinkIn *fissi
 
 	fileInode = lockInodeWithExclusiveLease(inode.InodeNumber(lookupReply.InodeNumber))
 
-	// Make sure potentially file inode didn't move before we were able to ExclusiveLease it
-
-	lookupReply = &jrpcfs.InodeReply{}
-
-	err = globals.retryRPCClient.Send("RpcLookup", lookupRequest, lookupReply)
-	if (nil != err) || (fileInode.InodeNumber != inode.InodeNumber(lookupReply.InodeNumber)) {
-		fileInode.unlock(true)
-		errno = convertErrToErrno(err, syscall.ENOENT)
-		return
-	}
-
 	fileInode.doFlushIfNecessary()
 
 	unlinkRequest = &jrpcfs.UnlinkRequest{
@@ -901,10 +882,19 @@ func (dummy *globalsStruct) DoUnlink(inHeader *fission.InHeader, unlinkIn *fissi
 
 	unlinkReply = &jrpcfs.Reply{}
 
-	err = globals.retryRPCClient.Send("RpcUnlink", unlinkRequest, unlinkReply)
+	// Make sure potentially file inode didn't move before we were able to ExclusiveLease it
+	// by checking a fresh RpcLookup in the same round-trip as the RpcUnlink
+
+	err = doCompoundRequest([]*compoundStepStruct{
+		{method: "RpcLookup", request: lookupRequest, reply: &jrpcfs.InodeReply{}},
+		{method: "RpcUnlink", request: unlinkRequest, checks: []jrpcfs.CompoundStepCheck{{Step: 0, ReplyField: "InodeNumber", Value: int64(fileInode.InodeNumber)}}, reply: unlinkReply},
+	})
 	if nil != err {
 		fileInode.unlock(true)
 		errno = convertErrToErrno(err, syscall.EIO)
+		if syscall.EAGAIN == errno {
+			errno = syscall.ENOENT
+		}
 		return
 	}
 
@@ -944,6 +934,16 @@ func (dummy *globalsStruct) DoRmDir(inHeader *fission.InHeader, rmDirIn *fission
 }
 
 func (dummy *globalsStruct) DoRename(inHeader *fission.InHeader, renameIn *fission.RenameIn) (errno syscall.Errno) {
+	_ = atomic.AddUint64(&globals.metrics.FUSE_DoRename_calls, 1)
+
+	errno = doRename(inode.InodeNumber(inHeader.NodeID), string(renameIn.OldName[:]), inode.InodeNumber(renameIn.NewDir), string(renameIn.NewName[:]), 0)
+
+	return
+}
+
+// doRename performs a DoRename() or DoRename2(). If the rename may replace (and
+// thus end up destroying) a file, that file is first ExclusiveLease'd and flushed.
+func doRename(srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, flags uint32) (errno syscall.Errno) {
 	var (
 		destroyReply   *jrpcfs.Reply
 		destroyRequest *jrpcfs.DestroyRequest
@@ -955,46 +955,66 @@ func (dummy *globalsStruct) DoRename(inHeader *fission.InHeader, renameIn *fissi
 		moveRequest    *jrpcfs.MoveRequest
 	)
 
-	_ = atomic.AddUint64(&globals.metrics.FUSE_DoRename_calls, 1)
-
-	lookupRequest = &jrpcfs.LookupRequest{
-		InodeHandle: jrpcfs.InodeHandle{
-			MountID:     globals.mountID,
-			InodeNumber: int64(renameIn.NewDir),
-		},
-		Basename: string(renameIn.NewName[:]),
+	moveRequest = &jrpcfs.MoveRequest{
+		MountID:           globals.mountID,
+		SrcDirInodeNumber: int64(srcDirInodeNumber),
+		SrcBasename:       srcBasename,
+		DstDirInodeNumber: int64(dstDirInodeNumber),
+		DstBasename:       dstBasename,
+		Flags:             flags,
 	}
 
-	lookupReply = &jrpcfs.InodeReply{}
+	moveReply = &jrpcfs.MoveReply{}
 
-	err = globals.retryRPCClient.Send("RpcLookup", lookupRequest, lookupReply)
-	if nil == err {
-		fileInode = lockInodeWithExclusiveLease(inode.InodeNumber(lookupReply.InodeNumber))
+	// Only a plain (i.e. no Flags) rename may end up destroying the target...
+	// RENAME_NOREPLACE fails if the target exists and RENAME_EXCHANGE keeps it
+
+	fileInode = nil
 
-		// Make sure potentially file inode didn't move before we were able to ExclusiveLease it
+	if 0 == flags {
+		lookupRequest = &jrpcfs.LookupRequest{
+			InodeHandle: jrpcfs.InodeHandle{
+				MountID:     globals.mountID,
+				InodeNumber: int64(dstDirInodeNumber),
+			},
+			Basename: dstBasename,
+		}
 
 		lookupReply = &jrpcfs.InodeReply{}
 
 		err = globals.retryRPCClient.Send("RpcLookup", lookupRequest, lookupReply)
-		if (nil != err) || (fileInode.InodeNumber != inode.InodeNumber(lookupReply.InodeNumber)) {
-			fileInode.unlock(true)
-			fileInode = nil
-		} else {
+		if nil == err {
+			fileInode = lockInodeWithExclusiveLease(inode.InodeNumber(lookupReply.InodeNumber))
+
 			fileInode.doFlushIfNecessary()
-		}
-	} else {
-		fileInode = nil
-	}
 
-	moveRequest = &jrpcfs.MoveRequest{
-		MountID:           globals.mountID,
-		SrcDirInodeNumber: int64(inHeader.NodeID),
-		SrcBasename:       string(renameIn.OldName[:]),
-		DstDirInodeNumber: int64(renameIn.NewDir),
-		DstBasename:       string(renameIn.NewName[:]),
-	}
+			// Make sure potentially file inode didn't move before we were able to ExclusiveLease it
+			// by checking a fresh RpcLookup in the same round-trip as the RpcMove
+
+			err = doCompoundRequest([]*compoundStepStruct{
+				{method: "RpcLookup", request: lookupRequest, reply: &jrpcfs.InodeReply{}},
+				{method: "RpcMove", request: moveRequest, checks: []jrpcfs.CompoundStepCheck{{Step: 0, ReplyField: "InodeNumber", Value: int64(fileInode.InodeNumber)}}, reply: moveReply},
+			})
+			if nil == err {
+				errno = 0
+			} else {
+				errno = convertErrToErrno(err, syscall.EIO)
+			}
 
-	moveReply = &jrpcfs.MoveReply{}
+			if (syscall.EAGAIN == errno) || (syscall.ENOENT == errno) {
+				// The target was replaced or removed (or the source is missing)... the
+				// file we ExclusiveLease'd won't be replaced, so simply RpcMove below
+
+				fileInode.unlock(true)
+				fileInode = nil
+			} else {
+				fileInode.unlock(false)
+				fileInode = nil
+
+				goto DestroyIfNecessary
+			}
+		}
+	}
 
 	err = globals.retryRPCClient.Send("RpcMove", moveRequest, moveReply)
 	if nil == err {
@@ -1003,9 +1023,7 @@ func (dummy *globalsStruct) DoRename(inHeader *fission.InHeader, renameIn *fissi
 		errno = convertErrToErrno(err, syscall.EIO)
 	}
 
-	if nil != fileInode {
-		fileInode.unlock(false)
-	}
+DestroyIfNecessary:
 
 	if 0 != moveReply.ToDestroyInodeNumber {
 		fileInode = lockInodeWithExclusiveLease(inode.InodeNumber(moveReply.ToDestroyInodeNumber))
@@ -1065,13 +1083,6 @@ func (dummy *globalsStruct) DoLink(inHeader *fission.InHeader, linkIn *fission.L
 
 	linkReply = &jrpcfs.Reply{}
 
-	err = globals.retryRPCClient.Send("RpcLink", linkRequest, linkReply)
-	if nil != err {
-		fileInode.unlock(true)
-		errno = convertErrToErrno(err, syscall.EIO)
-		return
-	}
-
 	getStatRequest = &jrpcfs.GetStatRequest{
 		InodeHandle: jrpcfs.InodeHandle{
 			MountID:     globals.mountID,
@@ -1081,7 +1092,10 @@ func (dummy *globalsStruct) DoLink(inHeader *fission.InHeader, linkIn *fission.L
 
 	getStatReply = &jrpcfs.StatStruct{}
 
-	err = globals.retryRPCClient.Send("RpcGetStat", getStatRequest, getStatReply)
+	err = doCompoundRequest([]*compoundStepStruct{
+		{method: "RpcLink", request: linkRequest, reply: linkReply},
+		{method: "RpcGetStat", request: getStatRequest, reply: getStatReply},
+	})
 	if nil != err {
 		fileInode.unlock(true)
 		errno = convertErrToErrno(err, syscall.EIO)
@@ -1322,7 +1336,11 @@ func (dummy *globalsStruct) DoRead(inHeader *fission.InHeader, readIn *fission.R
 			case *multiObjectExtentStruct:
 				readPlanStepAsMultiObjectExtentStruct = readPlanStepAsInterface.(*multiObjectExtentStruct)
 
-				if "" == readPlanStepAsMultiObjectExtentStruct.objectName {
+				if nil != readPlanStepAsMultiObjectExtentStruct.data {
+					// Data supplied by ProxyFS (e.g. inlined in the FileInode)
+
+					readOut.Data = append(readOut.Data, readPlanStepAsMultiObjectExtentStruct.data[readPlanStepAsMultiObjectExtentStruct.objectOffset:readPlanStepAsMultiObjectExtentStruct.objectOffset+readPlanStepAsMultiObjectExtentStruct.length]...)
+				} else if "" == readPlanStepAsMultiObjectExtentStruct.objectName {
 					// Zero-fill for readPlanStep.length
 
 					readOut.Data = append(readOut.Data, make([]byte, readPlanStepAsMultiObjectExtentStruct.length)...)
@@ -2233,22 +2251,18 @@ func (dummy *globalsStruct) DoCreate(inHeader *fission.InHeader, createIn *fissi
 
 	createReply = &jrpcfs.InodeReply{}
 
-	err = globals.retryRPCClient.Send("RpcCreate", createRequest, createReply)
-	if nil != err {
-		errno = convertErrToErrno(err, syscall.EIO)
-		return
-	}
-
 	getStatRequest = &jrpcfs.GetStatRequest{
 		InodeHandle: jrpcfs.InodeHandle{
-			MountID:     globals.mountID,
-			InodeNumber: createReply.InodeNumber,
+			MountID: globals.mountID,
 		},
 	}
 
 	getStatReply = &jrpcfs.StatStruct{}
 
-	err = globals.retryRPCClient.Send("RpcGetStat", getStatRequest, getStatReply)
+	err = doCompoundRequest([]*compoundStepStruct{
+		{method: "RpcCreate", request: createRequest, reply: createReply},
+		{method: "RpcGetStat", request: getStatRequest, refs: []jrpcfs.CompoundStepRef{{RequestField: "InodeNumber", Step: 0, ReplyField: "InodeNumber"}}, reply: getStatReply},
+	})
 	if nil != err {
 		errno = convertErrToErrno(err, syscall.EIO)
 		return
@@ -2480,90 +2494,9 @@ func (dummy *globalsStruct) DoReadDirPlus(inHeader *fission.InHeader, readDirPlu
 }
 
 func (dummy *globalsStruct) DoRename2(inHeader *fission.InHeader, rename2In *fission.Rename2In) (errno syscall.Errno) {
-	var (
-		destroyReply   *jrpcfs.Reply
-		destroyRequest *jrpcfs.DestroyRequest
-		err            error
-		fileInode      *fileInodeStruct
-		lookupReply    *jrpcfs.InodeReply
-		lookupRequest  *jrpcfs.LookupRequest
-		moveReply      *jrpcfs.MoveReply
-		moveRequest    *jrpcfs.MoveRequest
-	)
-
 	_ = atomic.AddUint64(&globals.metrics.FUSE_DoRename2_calls, 1)
 
-	lookupRequest = &jrpcfs.LookupRequest{
-		InodeHandle: jrpcfs.InodeHandle{
-			MountID:     globals.mountID,
-			InodeNumber: int64(rename2In.NewDir),
-		},
-		Basename: string(rename2In.NewName[:]),
-	}
-
-	lookupReply = &jrpcfs.InodeReply{}
-
-	err = globals.retryRPCClient.Send("RpcLookup", lookupRequest, lookupReply)
-	if nil == err {
-		fileInode = lockInodeWithExclusiveLease(inode.InodeNumber(lookupReply.InodeNumber))
-
-		// Make sure potentially file inode didn't move before we were able to ExclusiveLease it
-
-		lookupReply = &jrpcfs.InodeReply{}
-
-		err = globals.retryRPCClient.Send("RpcLookup", lookupRequest, lookupReply)
-		if (nil != err) || (fileInode.InodeNumber != inode.InodeNumber(lookupReply.InodeNumber)) {
-			fileInode.unlock(true)
-			fileInode = nil
-		} else {
-			fileInode.doFlushIfNecessary()
-		}
-	} else {
-		fileInode = nil
-	}
-
-	moveRequest = &jrpcfs.MoveRequest{
-		MountID:           globals.mountID,
-		SrcDirInodeNumber: int64(inHeader.NodeID),
-		SrcBasename:       string(rename2In.OldName[:]),
-		DstDirInodeNumber: int64(rename2In.NewDir),
-		DstBasename:       string(rename2In.NewName[:]),
-	}
-
-	moveReply = &jrpcfs.MoveReply{}
-
-	err = globals.retryRPCClient.Send("RpcMove", moveRequest, moveReply)
-	if nil == err {
-		errno = 0
-	} else {
-		errno = convertErrToErrno(err, syscall.EIO)
-	}
-
-	if nil != fileInode {
-		fileInode.unlock(false)
-	}
-
-	if 0 != moveReply.ToDestroyInodeNumber {
-		fileInode = lockInodeWithExclusiveLease(inode.InodeNumber(moveReply.ToDestroyInodeNumber))
-
-		fileInode.doFlushIfNecessary()
-
-		destroyRequest = &jrpcfs.DestroyRequest{
-			InodeHandle: jrpcfs.InodeHandle{
-				MountID:     globals.mountID,
-				InodeNumber: moveReply.ToDestroyInodeNumber,
-			},
-		}
-
-		destroyReply = &jrpcfs.Reply{}
-
-		err = globals.retryRPCClient.Send("RpcDestroy", destroyRequest, destroyReply)
-		if nil != err {
-			logWarnf("RpcDestroy(InodeHandle: %#v) failed: err", err)
-		}
-
-		fileInode.unlock(false)
-	}
+	errno = doRename(inode.InodeNumber(inHeader.NodeID), string(rename2In.OldName[:]), inode.InodeNumber(rename2In.NewDir), string(rename2In.NewName[:]), rename2In.Flags)
 
 	return
 }
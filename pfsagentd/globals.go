@@ -5,6 +5,7 @@ package main
 
 import (
 	"container/list"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -76,6 +77,8 @@ type configStruct struct {
 	RetryRPCDeadlineIO           time.Duration
 	RetryRPCKeepAlivePeriod      time.Duration
 	RetryRPCCACertFilePath       string
+	RetryRPCClientCertFilePath   string
+	RetryRPCClientKeyFilePath    string
 }
 
 type retryDelayElementStruct struct {
@@ -107,6 +110,14 @@ const (
 	fileInodeLeaseRequestRelease
 )
 
+type compoundSupportType uint32
+
+const (
+	compoundSupportUnknown compoundSupportType = iota // Probe (again) on next doCompoundRequest()
+	compoundSupportYes
+	compoundSupportNo
+)
+
 // singleObjectExtentStruct is used for chunkedPutContextStruct.extentMap.
 //
 type singleObjectExtentStruct struct {
@@ -128,14 +139,16 @@ type singleObjectExtentWithLinkStruct struct {
 // multiObjectExtentStruct is used for both the fileInodeStruct.extentMap as well
 // as for representing a ReadPlanStep. In this latter case, an objectName == ""
 // indicates a zero-filled extent rather than a read from a LogSegment already
-// persisted by Swift.
+// persisted by Swift. A non-nil data (e.g. inlined in the FileInode) supplies
+// the extent in lieu of a LogSegment with objectOffset indexing into it.
 //
 type multiObjectExtentStruct struct {
 	fileOffset    uint64 // Key in fileInodeStruct.extentMap
 	containerName string
-	objectName    string // If == "", implies a zero-filled extent/ReadPlanStep
+	objectName    string // If == "" (and data == nil), implies a zero-filled extent/ReadPlanStep
 	objectOffset  uint64
 	length        uint64
+	data          []byte // If != nil, extent is data[objectOffset:objectOffset+length]
 }
 
 const (
@@ -335,6 +348,7 @@ type globalsStruct struct {
 	config                          configStruct
 	logFile                         *os.File // == nil if configStruct.LogFilePath == ""
 	retryRPCCACertPEM               []byte
+	retryRPCClientCertificate       tls.Certificate
 	retryRPCClient                  *retryrpc.Client
 	entryValidSec                   uint64
 	entryValidNSec                  uint32
@@ -349,6 +363,7 @@ type globalsStruct struct {
 	swiftAuthToken                  string          // Protected by swiftAuthWaitGroup
 	swiftStorageURL                 string          // Protected by swiftAuthWaitGroup
 	mountID                         jrpcfs.MountIDAsString
+	compoundSupport                 uint32 // compoundSupportType via sync/atomic; if not Yes, doCompoundRequest() sends each step separately
 	fissionErrChan                  chan error
 	fissionVolume                   fission.Volume
 	fuseConn                        *fuse.Conn
@@ -666,6 +681,16 @@ func initializeGlobals(confMap conf.ConfMap) {
 		globals.config.RetryRPCCACertFilePath = ""
 	}
 
+	globals.config.RetryRPCClientCertFilePath, err = confMap.FetchOptionValueString("Agent", "RetryRPCClientCertFilePath")
+	if nil != err {
+		globals.config.RetryRPCClientCertFilePath = ""
+	}
+
+	globals.config.RetryRPCClientKeyFilePath, err = confMap.FetchOptionValueString("Agent", "RetryRPCClientKeyFilePath")
+	if nil != err {
+		globals.config.RetryRPCClientKeyFilePath = ""
+	}
+
 	configJSONified = utils.JSONify(globals.config, true)
 
 	logInfof("\n%s", configJSONified)
@@ -679,6 +704,15 @@ func initializeGlobals(confMap conf.ConfMap) {
 		}
 	}
 
+	if "" == globals.config.RetryRPCClientCertFilePath {
+		globals.retryRPCClientCertificate = tls.Certificate{}
+	} else {
+		globals.retryRPCClientCertificate, err = tls.LoadX509KeyPair(globals.config.RetryRPCClientCertFilePath, globals.config.RetryRPCClientKeyFilePath)
+		if nil != err {
+			logFatal(err)
+		}
+	}
+
 	globals.entryValidSec, globals.entryValidNSec = nsToUnixTime(uint64(globals.config.EntryDuration))
 	globals.attrValidSec, globals.attrValidNSec = nsToUnixTime(uint64(globals.config.AttrDuration))
 
@@ -766,6 +800,7 @@ func uninitializeGlobals() {
 
 	globals.logFile = nil
 	globals.retryRPCCACertPEM = nil
+	globals.retryRPCClientCertificate = tls.Certificate{}
 	globals.retryRPCClient = nil
 	globals.entryValidSec = 0
 	globals.entryValidNSec = 0
@@ -6,6 +6,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
@@ -15,8 +16,10 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/NVIDIA/proxyfs/blunder"
 	"github.com/NVIDIA/proxyfs/jrpcfs"
 	"github.com/NVIDIA/proxyfs/retryrpc"
 	"github.com/NVIDIA/proxyfs/version"
@@ -45,6 +48,7 @@ func doMountProxyFS() {
 		DNSOrIPAddr:              globals.config.RetryRPCPublicIPAddr,
 		Port:                     int(globals.config.RetryRPCPort),
 		RootCAx509CertificatePEM: globals.retryRPCCACertPEM,
+		TLSCertificate:           globals.retryRPCClientCertificate,
 		Callbacks:                &globals,
 		DeadlineIO:               globals.config.RetryRPCDeadlineIO,
 		KeepAlivePeriod:          globals.config.RetryRPCKeepAlivePeriod,
@@ -78,6 +82,34 @@ func doMountProxyFS() {
 
 	globals.mountID = mountReply.MountID
 
+	probeCompoundSupport()
+}
+
+// probeCompoundSupport determines whether ProxyFS supports RpcCompound by sending it an
+// empty CompoundRequest. An older ProxyFS lacks RpcCompound... and answers unknown methods
+// with the same errno (ENOENT) as, say, a failed RpcLookup step. As an empty CompoundRequest
+// cannot fail a step, only ENOENT means "unknown method". Any other failure (e.g. a transient
+// one) leaves globals.compoundSupport as compoundSupportUnknown so that the probe is retried
+// by the next doCompoundRequest().
+func probeCompoundSupport() (compoundSupport compoundSupportType) {
+	var (
+		err error
+	)
+
+	err = globals.retryRPCClient.Send("RpcCompound", &jrpcfs.CompoundRequest{}, &jrpcfs.CompoundReply{})
+	if nil == err {
+		compoundSupport = compoundSupportYes
+	} else if syscall.ENOENT == convertErrToErrno(err, 0) {
+		logWarnf("ProxyFS does not support RpcCompound (%v)... sending steps separately", err)
+		compoundSupport = compoundSupportNo
+	} else {
+		logWarnf("unable to determine if ProxyFS supports RpcCompound (%v)... will retry", err)
+		compoundSupport = compoundSupportUnknown
+	}
+
+	atomic.StoreUint32(&globals.compoundSupport, uint32(compoundSupport))
+
+	return
 }
 
 func doUnmountProxyFS() {
@@ -157,6 +189,107 @@ func doJRPCRequest(jrpcMethod string, jrpcParam interface{}, jrpcResult interfac
 	return
 }
 
+type compoundStepStruct struct {
+	method  string
+	request interface{}
+	refs    []jrpcfs.CompoundStepRef
+	checks  []jrpcfs.CompoundStepCheck
+	reply   interface{}
+}
+
+// doCompoundRequest performs steps in a single RpcCompound round-trip, decoding the
+// reply of each into its step.reply. The error of the first failing step is returned.
+//
+// If ProxyFS lacks RpcCompound (or whether it supports it is not yet known), the steps
+// are instead sent one at a time with their refs and checks applied here.
+func doCompoundRequest(steps []*compoundStepStruct) (err error) {
+	var (
+		compoundReply   *jrpcfs.CompoundReply
+		compoundRequest *jrpcfs.CompoundRequest
+		compoundSupport compoundSupportType
+		marshalErr      error
+		step            *compoundStepStruct
+		stepIndex       int
+		unmarshalErr    error
+	)
+
+	compoundSupport = compoundSupportType(atomic.LoadUint32(&globals.compoundSupport))
+	if compoundSupportUnknown == compoundSupport {
+		compoundSupport = probeCompoundSupport()
+	}
+
+	if compoundSupportYes != compoundSupport {
+		err = doCompoundRequestStepwise(steps)
+		return
+	}
+
+	compoundRequest = &jrpcfs.CompoundRequest{
+		Steps: make([]jrpcfs.CompoundStep, len(steps)),
+	}
+
+	for stepIndex, step = range steps {
+		compoundRequest.Steps[stepIndex].Method = step.method
+		compoundRequest.Steps[stepIndex].Refs = step.refs
+		compoundRequest.Steps[stepIndex].Checks = step.checks
+		compoundRequest.Steps[stepIndex].Request, marshalErr = json.Marshal(step.request)
+		if nil != marshalErr {
+			logFatalf("unable to marshal request (method=%s request=%#v): %v", step.method, step.request, marshalErr)
+		}
+	}
+
+	compoundReply = &jrpcfs.CompoundReply{}
+
+	err = globals.retryRPCClient.Send("RpcCompound", compoundRequest, compoundReply)
+	if nil != err {
+		return
+	}
+
+	if len(steps) != len(compoundReply.Replies) {
+		logFatalf("RpcCompound returned %d replies for %d steps", len(compoundReply.Replies), len(steps))
+	}
+
+	for stepIndex, step = range steps {
+		unmarshalErr = json.Unmarshal(compoundReply.Replies[stepIndex], step.reply)
+		if nil != unmarshalErr {
+			logFatalf("unable to unmarshal reply (method=%s): %v", step.method, unmarshalErr)
+		}
+	}
+
+	return
+}
+
+func doCompoundRequestStepwise(steps []*compoundStepStruct) (err error) {
+	var (
+		replies   []interface{}
+		step      *compoundStepStruct
+		stepIndex int
+	)
+
+	replies = make([]interface{}, 0, len(steps))
+
+	for stepIndex, step = range steps {
+		err = jrpcfs.CheckCompoundStep(stepIndex, step.checks, replies)
+		if nil == err {
+			err = jrpcfs.ApplyCompoundStepRefs(stepIndex, step.refs, step.request, replies)
+		}
+		if nil != err {
+			// Encode as ProxyFS would have so that convertErrToErrno() applies
+
+			err = fmt.Errorf("errno: %d", blunder.Errno(err))
+			return
+		}
+
+		err = globals.retryRPCClient.Send(step.method, step.request, step.reply)
+		if nil != err {
+			return
+		}
+
+		replies = append(replies, step.reply)
+	}
+
+	return
+}
+
 func doHTTPRequest(request *http.Request, okStatusCodes ...int) (response *http.Response, responseBody []byte, ok bool, statusCode int) {
 	var (
 		err              error
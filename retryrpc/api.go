@@ -46,6 +46,8 @@ type Server struct {
 	connections          *list.List
 	connWG               sync.WaitGroup
 	tlsCertificate       tls.Certificate
+	clientCAx509PEM      []byte // If !nil, Clients may present a certificate signed by this CA
+	requireClientCert    bool   // If true, Clients must present a certificate signed by clientCAx509PEM
 	listenersWG          sync.WaitGroup
 	receiver             reflect.Value          // Package receiver being served
 	perClientInfo        map[uint64]*clientInfo // Key: "clientID".  Tracks clients
@@ -68,6 +70,8 @@ type ServerConfig struct {
 	DeadlineIO        time.Duration   // How long I/Os on sockets wait even if idle
 	KeepAlivePeriod   time.Duration   // How frequently a KEEPALIVE is sent
 	TLSCertificate    tls.Certificate // TLS Certificate to present to Clients (or tls.Certificate{} if using TCP)
+	ClientCAx509PEM   []byte          // If TLS...CA used to verify optional Client certificates; If TCP or not verifying... nil
+	RequireClientCert bool            // If TLS and ClientCAx509PEM != nil...Clients lacking a verified certificate are refused
 	Logger            *log.Logger     // If nil, defaults to log.New()
 	dontStartTrimmers bool            // Used for testing
 }
@@ -83,7 +87,9 @@ func NewServer(config *ServerConfig) *Server {
 		keepAlivePeriod:   config.KeepAlivePeriod,
 		dontStartTrimmers: config.dontStartTrimmers,
 		logger:            config.Logger,
-		tlsCertificate:    config.TLSCertificate}
+		tlsCertificate:    config.TLSCertificate,
+		clientCAx509PEM:   config.ClientCAx509PEM,
+		requireClientCert: config.RequireClientCert}
 	if server.logger == nil {
 		var logBuf bytes.Buffer
 		server.logger = log.New(&logBuf, "", 0)
@@ -111,6 +117,20 @@ func (server *Server) Start() (err error) {
 		Certificates: []tls.Certificate{server.tlsCertificate},
 	}
 
+	if nil != server.clientCAx509PEM {
+		// Clients need not present a certificate, but any they do present must verify
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(server.clientCAx509PEM) {
+			err = fmt.Errorf("x509CertPool.AppendCertsFromPEM() of ClientCAx509PEM returned !ok")
+			return
+		}
+		if server.requireClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
 	listenConfig := &net.ListenConfig{KeepAlive: server.keepAlivePeriod}
 	server.netListener, err = listenConfig.Listen(context.Background(), "tcp", hostPortStr)
 	if nil != err {
@@ -201,6 +221,37 @@ func (server *Server) SendCallback(clientID uint64, msg []byte) {
 	server.returnResults(&localIOR, currentCtx)
 }
 
+// ClientCertificate returns the verified certificate presented by the
+// client on its current connection.
+//
+// If the client is unknown, is not using TLS, or did not present a
+// certificate, nil is returned.
+func (server *Server) ClientCertificate(clientID uint64) (certificate *x509.Certificate) {
+	server.Lock()
+	lci, ok := server.perClientInfo[clientID]
+	server.Unlock()
+	if !ok {
+		return
+	}
+
+	lci.Lock()
+	currentCtx := lci.cCtx
+	lci.Unlock()
+
+	tlsConn, ok := currentCtx.conn.(*tls.Conn)
+	if !ok {
+		return
+	}
+
+	connectionState := tlsConn.ConnectionState()
+	if (0 == len(connectionState.VerifiedChains)) || (0 == len(connectionState.PeerCertificates)) {
+		return
+	}
+
+	certificate = connectionState.PeerCertificates[0]
+	return
+}
+
 // Close stops the server
 func (server *Server) Close() {
 	server.Lock()
@@ -280,6 +331,9 @@ const (
 	// RETRANSMITTING means a goroutine is in the middle of recovering
 	// from a loss of a connection with the server
 	RETRANSMITTING
+	// DISCONNECTED means recovery from a loss of a connection with the
+	// server was abandoned (see ClientConfig.FailOnConnectionRetryLimit)
+	DISCONNECTED
 )
 
 type connectionTracker struct {
@@ -290,7 +344,8 @@ type connectionTracker struct {
 	tlsConn      *tls.Conn      // Our TLS connection to the server
 	tlsConfig    *tls.Config    //
 	x509CertPool *x509.CertPool // If nil, use TCP; if !nil, use TLS
-	hostPortStr  string         //
+	tlsCert      tls.Certificate
+	hostPortStr  string //
 }
 
 // Client tracking structure
@@ -313,6 +368,8 @@ type Client struct {
 	goroutineWG sync.WaitGroup // Used to track outstanding goroutines
 	logger      *log.Logger    // If nil, defaults to log.New()
 	stats       clientSideStatsInfo
+
+	failOnConnectionRetryLimit bool // If true, Send() fails rather than exiting once ConnectionRetryLimit is exceeded
 }
 
 // ClientCallbacks contains the methods required when supporting
@@ -326,13 +383,19 @@ type ClientCallbacks interface {
 
 // ClientConfig is used to configure a retryrpc Client
 type ClientConfig struct {
-	DNSOrIPAddr              string        // DNS name or IP Address of Server
-	Port                     int           // Port of Server
-	RootCAx509CertificatePEM []byte        // If TLS...Root certificate; If TCP... nil
-	Callbacks                interface{}   // Structure implementing ClientCallbacks
-	DeadlineIO               time.Duration // How long I/Os on sockets wait even if idle
-	KeepAlivePeriod          time.Duration // How frequently a KEEPALIVE is sent
-	Logger                   *log.Logger   // If nil, defaults to log.New()
+	DNSOrIPAddr              string          // DNS name or IP Address of Server
+	Port                     int             // Port of Server
+	RootCAx509CertificatePEM []byte          // If TLS...Root certificate; If TCP... nil
+	TLSCertificate           tls.Certificate // If TLS...Certificate to present to Server (or tls.Certificate{} if none); If TCP... ignored
+	Callbacks                interface{}     // Structure implementing ClientCallbacks
+	DeadlineIO               time.Duration   // How long I/Os on sockets wait even if idle
+	KeepAlivePeriod          time.Duration   // How frequently a KEEPALIVE is sent
+	Logger                   *log.Logger     // If nil, defaults to log.New()
+
+	// If FailOnConnectionRetryLimit is true, exceeding ConnectionRetryLimit fails
+	// the affected Send()'s rather than exiting the process. A subsequent Send()
+	// will attempt to reestablish the connection.
+	FailOnConnectionRetryLimit bool
 }
 
 // NewClient returns a Client structure
@@ -357,6 +420,8 @@ func NewClient(config *ClientConfig) (client *Client, err error) {
 		keepAlivePeriod: config.KeepAlivePeriod,
 		deadlineIO:      config.DeadlineIO,
 		logger:          config.Logger,
+
+		failOnConnectionRetryLimit: config.FailOnConnectionRetryLimit,
 	}
 
 	if client.logger == nil {
@@ -374,6 +439,7 @@ func NewClient(config *ClientConfig) (client *Client, err error) {
 	} else {
 		client.connection.useTLS = true
 		client.connection.netConn = nil
+		client.connection.tlsCert = config.TLSCertificate
 		// Add cert for root CA to our pool
 		client.connection.x509CertPool = x509.NewCertPool()
 		ok := client.connection.x509CertPool.AppendCertsFromPEM(config.RootCAx509CertificatePEM)
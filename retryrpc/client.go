@@ -80,6 +80,10 @@ func (client *Client) send(method string, rpcRequest interface{}, rpcReply inter
 			client.Unlock()
 			connectionRetryCount++
 			if connectionRetryCount > ConnectionRetryLimit {
+				if client.failOnConnectionRetryLimit {
+					err = fmt.Errorf("In send(), ConnectionRetryLimit (%v) on calling dial() exceeded: %v", ConnectionRetryLimit, err)
+					return
+				}
 				client.logger.Fatalf("In send(), ConnectionRetryLimit (%v) on calling dial() exceeded", ConnectionRetryLimit)
 			}
 			client.logger.Printf("initialDial() failed; retrying: %v\n", err)
@@ -90,6 +94,14 @@ func (client *Client) send(method string, rpcRequest interface{}, rpcReply inter
 				break
 			}
 		}
+	} else if client.connection.state == DISCONNECTED {
+		// A prior retransmit() gave up...make one attempt to reconnect
+
+		err = client.reDial()
+		if err != nil {
+			client.Unlock()
+			return
+		}
 	}
 
 	// Put request data into structure to be be marshaled into JSON
@@ -417,6 +429,10 @@ func (client *Client) retransmit(genNum uint64) {
 		client.Unlock()
 		connectionRetryCount++
 		if connectionRetryCount > ConnectionRetryLimit {
+			if client.failOnConnectionRetryLimit {
+				client.abandonOutstandingRequests(fmt.Errorf("In retransmit(), ConnectionRetryLimit (%v) on calling dial() exceeded: %v", ConnectionRetryLimit, err))
+				return
+			}
 			client.logger.Fatalf("In retransmit(), ConnectionRetryLimit (%v) on calling dial() exceeded", ConnectionRetryLimit)
 		}
 		time.Sleep(connectionRetryDelay)
@@ -438,6 +454,35 @@ func (client *Client) retransmit(genNum uint64) {
 	client.Unlock()
 }
 
+// abandonOutstandingRequests fails every outstanding request with err and
+// leaves the connection DISCONNECTED such that the next send() will attempt
+// to reconnect.
+//
+// NOTE: Client lock must not be held when this is called.
+func (client *Client) abandonOutstandingRequests(err error) {
+	var (
+		abandonedRequests []*reqCtx
+	)
+
+	client.Lock()
+	if client.halting {
+		client.Unlock()
+		return
+	}
+	client.connection.state = DISCONNECTED
+	abandonedRequests = make([]*reqCtx, 0, len(client.outstandingRequest))
+	for crID, ctx := range client.outstandingRequest {
+		delete(client.outstandingRequest, crID)
+		abandonedRequests = append(abandonedRequests, ctx)
+		go client.updateHighestConsecutiveNum(crID)
+	}
+	client.Unlock()
+
+	for _, ctx := range abandonedRequests {
+		ctx.answer <- replyCtx{err: err}
+	}
+}
+
 // Get myUniqueID from server.   This is called when the client is
 // creating this connection and wants a unique ID from the server.
 //
@@ -525,6 +570,9 @@ func (client *Client) reDial() (err error) {
 		client.connection.tlsConfig = &tls.Config{
 			RootCAs: client.connection.x509CertPool,
 		}
+		if 0 != len(client.connection.tlsCert.Certificate) {
+			client.connection.tlsConfig.Certificates = []tls.Certificate{client.connection.tlsCert}
+		}
 
 		d := &net.Dialer{KeepAlive: client.keepAlivePeriod}
 		tlsConn, dialErr := tls.DialWithDialer(d, "tcp", client.connection.hostPortStr, client.connection.tlsConfig)
@@ -626,6 +674,9 @@ func (client *Client) initialDial() (err error) {
 		client.connection.tlsConfig = &tls.Config{
 			RootCAs: client.connection.x509CertPool,
 		}
+		if 0 != len(client.connection.tlsCert.Certificate) {
+			client.connection.tlsConfig.Certificates = []tls.Certificate{client.connection.tlsCert}
+		}
 
 		d := &net.Dialer{KeepAlive: client.keepAlivePeriod}
 		tlsConn, dialErr := tls.DialWithDialer(d, "tcp", client.connection.hostPortStr, client.connection.tlsConfig)
@@ -461,3 +461,101 @@ func TestMethodStatsAndBucketstats(t *testing.T) {
 		bucketstats.UnRegister("proxyfs.retryrpc", mAndN(myUniqueClient1, ms))
 	}
 }
+
+// Test that a verified client certificate is reported for the presenting client only
+func TestTLSClientCertificate(t *testing.T) {
+	var (
+		clientCertPEMBlock []byte
+		clientKeyPEMBlock  []byte
+		clientTLSCert      tls.Certificate
+		err                error
+	)
+
+	assert := assert.New(t)
+
+	testTLSCertsAllocate(t)
+
+	clientCertPEMBlock, clientKeyPEMBlock, err = icertpkg.GenEndpointCert(
+		icertpkg.GenerateKeyAlgorithmEd25519,
+		pkix.Name{
+			CommonName:    "TestClient",
+			Organization:  []string{"Test Organization Client"},
+			Country:       []string{},
+			Province:      []string{},
+			Locality:      []string{},
+			StreetAddress: []string{},
+			PostalCode:    []string{},
+		},
+		[]string{},
+		[]net.IP{},
+		time.Hour,
+		testTLSCerts.caCertPEMBlock,
+		testTLSCerts.caKeyPEMBlock,
+		"",
+		"")
+	if nil != err {
+		t.Fatalf("icertpkg.genEndpointCert() failed: %v", err)
+	}
+
+	clientTLSCert, err = tls.X509KeyPair(clientCertPEMBlock, clientKeyPEMBlock)
+	if nil != err {
+		t.Fatalf("tls.LoadX509KeyPair() failed: %v", err)
+	}
+
+	rrSvr := NewServer(&ServerConfig{
+		LongTrim:        10 * time.Second,
+		ShortTrim:       100 * time.Millisecond,
+		DNSOrIPAddr:     testIPAddr,
+		Port:            testPort,
+		DeadlineIO:      60 * time.Second,
+		KeepAlivePeriod: 60 * time.Second,
+		TLSCertificate:  testTLSCerts.endpointTLSCert,
+		ClientCAx509PEM: testTLSCerts.caCertPEMBlock,
+		Logger:          newLogger(),
+	})
+
+	err = rrSvr.Register(&TestPingServer{})
+	assert.Nil(err)
+	err = rrSvr.Start()
+	assert.Nil(err)
+	rrSvr.Run()
+
+	anonClnt, err := NewClient(&ClientConfig{
+		DNSOrIPAddr:              testIPAddr,
+		Port:                     testPort,
+		RootCAx509CertificatePEM: testTLSCerts.caCertPEMBlock,
+		DeadlineIO:               60 * time.Second,
+		KeepAlivePeriod:          60 * time.Second,
+		Logger:                   newLogger(),
+	})
+	assert.Nil(err)
+
+	certClnt, err := NewClient(&ClientConfig{
+		DNSOrIPAddr:              testIPAddr,
+		Port:                     testPort,
+		RootCAx509CertificatePEM: testTLSCerts.caCertPEMBlock,
+		TLSCertificate:           clientTLSCert,
+		DeadlineIO:               60 * time.Second,
+		KeepAlivePeriod:          60 * time.Second,
+		Logger:                   newLogger(),
+	})
+	assert.Nil(err)
+
+	err = anonClnt.Send("RpcTestPing", &TestPingReq{Message: "Ping Me!"}, &TestPingReply{})
+	assert.Nil(err)
+	err = certClnt.Send("RpcTestPing", &TestPingReq{Message: "Ping Me!"}, &TestPingReply{})
+	assert.Nil(err)
+
+	assert.Nil(rrSvr.ClientCertificate(anonClnt.GetMyUniqueID()))
+
+	clientCert := rrSvr.ClientCertificate(certClnt.GetMyUniqueID())
+	if assert.NotNil(clientCert) {
+		assert.Equal("TestClient", clientCert.Subject.CommonName)
+	}
+
+	assert.Nil(rrSvr.ClientCertificate(0))
+
+	anonClnt.Close()
+	certClnt.Close()
+	rrSvr.Close()
+}